@@ -0,0 +1,146 @@
+// Package apply is the privileged half of an install. The unprivileged
+// updater downloads and verifies a release and then runs updater-apply,
+// through sudo, a setuid bit or systemd-run, with the staged file, the
+// target and the file's SHA-256 digest (see selfupdate.CommandApplier).
+// updater-apply only copies the file next to an allowlisted target,
+// checks the digest of the copy and renames it into place, so no network
+// or release parsing code runs with privileges.
+package apply
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// DefaultAllowlist lists the targets updater-apply may replace.
+const DefaultAllowlist = "/etc/updater/apply-targets"
+
+var (
+	// ErrDigestMismatch is returned when the copied file does not have
+	// the expected digest.
+	ErrDigestMismatch = errors.New("digest mismatch")
+	// ErrNotAllowed is returned for a target missing from the allowlist,
+	// or a file the invoking user does not own.
+	ErrNotAllowed = errors.New("not allowed")
+)
+
+// allowlistOwner is the user that must own the allowlist.
+var allowlistOwner = 0
+
+// Run installs args[0] over args[1] if args[1] is listed in the
+// allowlist file and the copy has the hex SHA-256 digest args[2]. The
+// allowlist holds one absolute path per line; blank lines and lines
+// starting with "#" are ignored. Where the platform has file owners, the
+// allowlist must belong to root and not be writable by others, and the
+// file to install must belong to the invoking user.
+func Run(allowlist string, args []string) error {
+	if len(args) != 3 {
+		return fmt.Errorf("usage: updater-apply <file> <target> <sha256>")
+	}
+	src, target, digest := args[0], filepath.Clean(args[1]), args[2]
+	allowed, err := Allowed(allowlist)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(allowed, target) {
+		return fmt.Errorf("%w: %s is not in %s", ErrNotAllowed, target, allowlist)
+	}
+	in, err := openSource(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return install(in, target, digest)
+}
+
+// Allowed reads the targets listed in the allowlist file at path.
+func Allowed(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkOwner(fi, allowlistOwner); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if fi.Mode().Perm()&0o022 != 0 {
+		return nil, fmt.Errorf("%w: %s is writable by group or others", ErrNotAllowed, path)
+	}
+	var targets []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !filepath.IsAbs(line) {
+			return nil, fmt.Errorf("%s: target %q is not absolute", path, line)
+		}
+		targets = append(targets, filepath.Clean(line))
+	}
+	return targets, sc.Err()
+}
+
+// Install copies src next to target, checks that the copy has the hex
+// SHA-256 digest sha256Hex, and renames it over target with mode 0755.
+// Since only the copy is checked, src may be writable by a less
+// privileged user.
+func Install(src, target, sha256Hex string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return install(in, target, sha256Hex)
+}
+
+func install(in *os.File, target, sha256Hex string) error {
+	want, err := hex.DecodeString(sha256Hex)
+	if err != nil || len(want) != sha256.Size {
+		return fmt.Errorf("invalid SHA-256 digest %q", sha256Hex)
+	}
+	dir := filepath.Dir(target)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(target)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, h), in)
+	if err == nil {
+		err = tmp.Chmod(0o755)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(h.Sum(nil), want) {
+		return fmt.Errorf("%w: %s", ErrDigestMismatch, in.Name())
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return err
+	}
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package apply
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func Test_Run(t *testing.T) {
+	defer func(uid int) { allowlistOwner = uid }(allowlistOwner)
+	allowlistOwner = os.Getuid()
+	dir := t.TempDir()
+	target, other := filepath.Join(dir, "app"), filepath.Join(dir, "other")
+	src := filepath.Join(t.TempDir(), "app.new")
+	os.WriteFile(target, []byte("old"), 0o755)
+	os.WriteFile(other, []byte("other"), 0o755)
+	os.WriteFile(src, []byte("new"), 0o600)
+	allowlist := filepath.Join(t.TempDir(), "apply-targets")
+	if err := os.WriteFile(allowlist, []byte("# installed by updater\n\n"+target+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	const newSHA256 = "11507a0e2f5e69d5dfa40a62a1bd7b6ee57e6bcd85c67c9b8431b36fff21c437"
+	verify := func(path, want string) {
+		t.Helper()
+		if b, _ := os.ReadFile(path); string(b) != want {
+			t.Errorf("%s: expected %q, got %q", filepath.Base(path), want, b)
+		}
+	}
+
+	if err := Run(allowlist, []string{src, other, newSHA256}); !errors.Is(err, ErrNotAllowed) {
+		t.Error("expected ErrNotAllowed for an unlisted target, got", err)
+	}
+	if err := Run(allowlist, []string{src, target, strings.Repeat("00", 32)}); !errors.Is(err, ErrDigestMismatch) {
+		t.Error("expected ErrDigestMismatch, got", err)
+	}
+	if err := Run(allowlist, []string{src, target}); err == nil {
+		t.Error("missing digest accepted")
+	}
+	verify(target, "old")
+	verify(other, "other")
+
+	if runtime.GOOS != "windows" {
+		link := filepath.Join(t.TempDir(), "link")
+		os.Symlink(src, link)
+		if err := Run(allowlist, []string{link, target, newSHA256}); err == nil {
+			t.Error("symlinked file accepted")
+		}
+		os.Chmod(allowlist, 0o666)
+		if err := Run(allowlist, []string{src, target, newSHA256}); !errors.Is(err, ErrNotAllowed) {
+			t.Error("expected ErrNotAllowed for a writable allowlist, got", err)
+		}
+		os.Chmod(allowlist, 0o644)
+	}
+
+	if err := Run(allowlist, []string{src, target, newSHA256}); err != nil {
+		t.Fatal(err)
+	}
+	verify(target, "new")
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Errorf("temporary files left behind: %v", entries)
+	}
+}
@@ -0,0 +1,40 @@
+//go:build unix
+
+package apply
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// checkOwner requires fi to belong to uid.
+func checkOwner(fi os.FileInfo, uid int) error {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok || int(st.Uid) != uid {
+		return fmt.Errorf("%w: not owned by uid %d", ErrNotAllowed, uid)
+	}
+	return nil
+}
+
+// openSource opens the file to install without following a symlink and
+// requires it to belong to the invoking (real) user, so that a setuid
+// updater-apply cannot be used to copy files the user cannot read.
+func openSource(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NOFOLLOW, 0)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err == nil && !fi.Mode().IsRegular() {
+		err = fmt.Errorf("%w: %s is not a regular file", ErrNotAllowed, path)
+	}
+	if uid := os.Getuid(); err == nil && uid != 0 {
+		err = checkOwner(fi, uid)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,198 @@
+// Package client calls the update endpoints of a node, as served by
+// selfupdate.Updater.Handler, so fleet tooling need not hand-roll the
+// requests:
+//
+//	c := client.New("http://node1:8080/api/v1/update")
+//	st, err := c.Status(ctx)
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/msmania/updater/selfupdate"
+)
+
+// Client calls the update endpoints below BaseURL.
+type Client struct {
+	// BaseURL is where the endpoints are mounted, e.g.
+	// "http://node1:8080/api/v1/update".
+	BaseURL string
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+	// Token, if set, is sent as "Authorization: Bearer <Token>", for a
+	// gateway in front of the node.
+	Token string
+	// Retries is the number of times a GET request is retried after a
+	// network error, a 429 or a 5xx response. POST requests start an
+	// update or a rollback and are never retried.
+	Retries int
+	// RetryWait is the wait before the first retry, doubled for each
+	// further one; default 1s.
+	RetryWait time.Duration
+}
+
+// New returns a Client for the endpoints below baseURL.
+func New(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// Error is a non-2xx response. It matches selfupdate.ErrBusy for a 409
+// and selfupdate.ErrNoRollback for a 412, as the node answers with them.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+func (e *Error) Is(target error) bool {
+	switch e.StatusCode {
+	case http.StatusConflict:
+		return target == selfupdate.ErrBusy
+	case http.StatusPreconditionFailed:
+		return target == selfupdate.ErrNoRollback
+	}
+	return false
+}
+
+// Status returns the outcome of the node's most recent update check.
+func (c *Client) Status(ctx context.Context) (selfupdate.Status, error) {
+	var st selfupdate.Status
+	err := c.do(ctx, http.MethodGet, "/status", &st)
+	return st, err
+}
+
+// Version returns the build the node runs.
+func (c *Client) Version(ctx context.Context) (selfupdate.BuildInfo, error) {
+	var bi selfupdate.BuildInfo
+	err := c.do(ctx, http.MethodGet, "/version", &bi)
+	return bi, err
+}
+
+// History returns the node's past updates and rollbacks.
+func (c *Client) History(ctx context.Context) ([]selfupdate.HistoryEntry, error) {
+	var h []selfupdate.HistoryEntry
+	err := c.do(ctx, http.MethodGet, "/history", &h)
+	return h, err
+}
+
+// Check makes the node check for a newer release. A failed check
+// returns what the node found along with its error.
+func (c *Client) Check(ctx context.Context) (*selfupdate.UpdateInfo, error) {
+	var out struct {
+		*selfupdate.UpdateInfo
+		Error string `json:"error"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/check", &out); err != nil {
+		return nil, err
+	}
+	if out.Error != "" {
+		return out.UpdateInfo, errors.New(out.Error)
+	}
+	return out.UpdateInfo, nil
+}
+
+// Trigger makes the node upgrade now if a newer release is available
+// and returns the resulting Status.
+func (c *Client) Trigger(ctx context.Context) (selfupdate.Status, error) {
+	var st selfupdate.Status
+	err := c.do(ctx, http.MethodPost, "/trigger", &st)
+	return st, err
+}
+
+// Rollback makes the node restore its previous version and returns
+// that version.
+func (c *Client) Rollback(ctx context.Context) (string, error) {
+	var out struct {
+		Version string `json:"version"`
+	}
+	err := c.do(ctx, http.MethodPost, "/rollback", &out)
+	return out.Version, err
+}
+
+// do sends a request to path and decodes the JSON response into v,
+// retrying GET requests as configured.
+func (c *Client) do(ctx context.Context, method, path string, v any) error {
+	wait := c.RetryWait
+	if wait <= 0 {
+		wait = time.Second
+	}
+	for attempt := 0; ; attempt++ {
+		err := c.once(ctx, method, path, v)
+		if err == nil || method != http.MethodGet || attempt >= c.Retries || !retryable(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+func (c *Client) once(ctx context.Context, method, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		return &Error{StatusCode: resp.StatusCode, Message: errorMessage(body)}
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+// errorMessage returns the message of an error response, which is
+// either plain text or the JSON envelope of the updater server.
+func errorMessage(body []byte) string {
+	var envelope struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &envelope) == nil && envelope.Error.Message != "" {
+		return envelope.Error.Message
+	}
+	return string(bytes.TrimSpace(body))
+}
+
+// retryable reports whether a failed GET request may succeed if retried.
+func retryable(err error) bool {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+	}
+	var ue *url.Error
+	return errors.As(err, &ue) && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/msmania/updater/selfupdate"
+)
+
+func Test_Client(t *testing.T) {
+	skipIfDisabled(t)
+	u := &selfupdate.Updater{Build: selfupdate.BuildInfo{Version: "v1.4.0"}}
+	mux := http.NewServeMux()
+	mux.Handle("/api/v1/update/", http.StripPrefix("/api/v1/update", u.Handler()))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	c := New(srv.URL + "/api/v1/update/")
+	ctx := context.Background()
+
+	if st, err := c.Status(ctx); err != nil || st.Current != "v1.4.0" || st.Result != selfupdate.ResultNotChecked {
+		t.Errorf("Status = %+v, %v", st, err)
+	}
+	if bi, err := c.Version(ctx); err != nil || bi.Version != "v1.4.0" {
+		t.Errorf("Version = %+v, %v", bi, err)
+	}
+	if h, err := c.History(ctx); err != nil || len(h) != 0 {
+		t.Errorf("History = %v, %v", h, err)
+	}
+	if _, err := c.Rollback(ctx); !errors.Is(err, selfupdate.ErrNoRollback) {
+		t.Errorf("expected ErrNoRollback, got %v", err)
+	}
+}
+
+func Test_Client_retries(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if calls < 3 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error": {"status": 503, "message": "update in progress"}}`))
+			return
+		}
+		w.Write([]byte(`{"current": "v1.4.0", "result": "up-to-date"}`))
+	}))
+	defer srv.Close()
+	c := &Client{BaseURL: srv.URL, Token: "secret", Retries: 2, RetryWait: time.Millisecond}
+	ctx := context.Background()
+
+	if st, err := c.Status(ctx); err != nil || st.Result != selfupdate.ResultUpToDate || calls != 3 {
+		t.Errorf("Status = %+v, %v after %d calls", st, err, calls)
+	}
+
+	calls = 0
+	_, err := c.Trigger(ctx)
+	var e *Error
+	if !errors.As(err, &e) || e.StatusCode != http.StatusServiceUnavailable || e.Message != "update in progress" || calls != 1 {
+		t.Errorf("POST must not be retried: %v after %d calls", err, calls)
+	}
+
+	calls = 0
+	c.Token = ""
+	if _, err := c.Status(ctx); !errors.As(err, &e) || e.StatusCode != http.StatusUnauthorized || calls != 1 {
+		t.Errorf("4xx must not be retried: %v after %d calls", err, calls)
+	}
+}
+
+// skipIfDisabled skips tests that need a working Updater in builds with
+// the noselfupdate tag.
+func skipIfDisabled(t *testing.T) {
+	t.Helper()
+	if !selfupdate.Enabled() {
+		t.Skip("built with the noselfupdate tag")
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/msmania/updater/selfupdate"
+)
+
+// adminACL restricts the admin routes, i.e. the update API, the
+// dashboard and the gRPC control API, to clients in Networks. An empty
+// ACL allows everyone.
+type adminACL struct {
+	Networks []netip.Prefix
+	// TrustProxy takes the client from X-Forwarded-For; see clientAddr.
+	TrustProxy bool
+}
+
+// parseCIDRs parses a comma-separated list of CIDR prefixes; a bare
+// address stands for itself alone.
+func parseCIDRs(s string) ([]netip.Prefix, error) {
+	var nets []netip.Prefix
+	for _, item := range splitList(s) {
+		if !strings.Contains(item, "/") {
+			addr, err := netip.ParseAddr(item)
+			if err != nil {
+				return nil, fmt.Errorf("invalid network %q: %w", item, err)
+			}
+			nets = append(nets, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q: %w", item, err)
+		}
+		nets = append(nets, p.Masked())
+	}
+	return nets, nil
+}
+
+// allows reports whether a client at addr may use the admin routes. An
+// empty or unparsable address is denied.
+func (a adminACL) allows(addr string) bool {
+	if len(a.Networks) == 0 {
+		return true
+	}
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return false
+	}
+	ip = ip.Unmap()
+	for _, n := range a.Networks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// permits reports whether the client that sent r may use the admin
+// routes. Clients of a unix socket have no address and are left to the
+// socket's permissions, unless a trusted proxy names the client.
+func (a adminACL) permits(r *http.Request) bool {
+	if onUnixSocket(r) && !(a.TrustProxy && forwardedFor(r) != "") {
+		return true
+	}
+	return a.allows(clientAddr(r, a.TrustProxy))
+}
+
+// onUnixSocket reports whether r arrived through a unix socket listener.
+func onUnixSocket(r *http.Request) bool {
+	addr, _ := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	return addr != nil && addr.Network() == "unix"
+}
+
+// grpcPermissionDenied is the gRPC status code of a denied call.
+const grpcPermissionDenied = 7
+
+// wrap rejects requests to next from clients outside the ACL with 403,
+// or PERMISSION_DENIED for gRPC calls.
+func (a adminACL) wrap(next http.Handler) http.Handler {
+	if len(a.Networks) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := clientAddr(r, a.TrustProxy)
+		switch {
+		case a.permits(r):
+			next.ServeHTTP(w, r)
+		case selfupdate.IsGRPC(r):
+			log.Printf("gRPC call from %s to %s denied", client, r.URL.Path)
+			w.Header().Set("Content-Type", "application/grpc+proto")
+			w.Header().Set("Grpc-Status", strconv.Itoa(grpcPermissionDenied))
+			w.WriteHeader(http.StatusOK)
+		default:
+			log.Printf("admin request from %s to %s denied", client, r.URL.Path)
+			writeAPIError(w, http.StatusForbidden)
+		}
+	})
+}
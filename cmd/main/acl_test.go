@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/msmania/updater/selfupdate"
+)
+
+func Test_parseCIDRs(t *testing.T) {
+	nets, err := parseCIDRs("10.0.0.0/8, 127.0.0.1, 192.168.1.7/24, fd00::/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"10.0.0.0/8", "127.0.0.1/32", "192.168.1.0/24", "fd00::/8"}
+	for i, n := range nets {
+		if i >= len(want) || n.String() != want[i] {
+			t.Errorf("got %v, want %v", nets, want)
+			break
+		}
+	}
+	for _, s := range []string{"10.0.0.0/33", "localhost"} {
+		if _, err := parseCIDRs(s); err == nil {
+			t.Errorf("%q accepted", s)
+		}
+	}
+}
+
+func Test_adminACL(t *testing.T) {
+	nets, _ := parseCIDRs("10.0.0.0/8,127.0.0.1/32")
+	mux := newServeMux(&selfupdate.Updater{}, adminACL{Networks: nets})
+	verify := func(remote, path, xff string, want int) {
+		t.Helper()
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = remote
+		if remote == "@" {
+			unix := &net.UnixAddr{Name: "/run/updater.sock", Net: "unix"}
+			req = req.WithContext(context.WithValue(req.Context(), http.LocalAddrContextKey, unix))
+		}
+		if xff != "" {
+			req.Header.Set("X-Forwarded-For", xff)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("%s from %s = %d, want %d", path, remote, rec.Code, want)
+		}
+	}
+	captureLog(t)
+	verify("10.1.2.3:5000", "/api/v1/update/status", "", 200)
+	verify("127.0.0.1:5000", "/update/status", "", 200)
+	verify("[::ffff:10.1.2.3]:5000", "/api/v1/update/status", "", 200)
+	verify("192.0.2.1:5000", "/api/v1/update/status", "", 403)
+	verify("192.0.2.1:5000", "/update/status", "", 403)
+	verify("192.0.2.1:5000", "/ui/", "", 403)
+	verify("192.0.2.1:5000", "/api/v1/version", "", 200)
+	verify("@", "/api/v1/update/status", "", 200)
+	// Only a unix socket listener makes a missing address acceptable.
+	verify("", "/api/v1/update/status", "", 403)
+	verify("@", "/api/v1/update/status", "192.0.2.1", 200)
+	// X-Forwarded-For is only believed with TrustProxy.
+	verify("192.0.2.1:5000", "/api/v1/update/status", "10.1.2.3", 403)
+	mux = newServeMux(&selfupdate.Updater{}, adminACL{Networks: nets, TrustProxy: true})
+	verify("192.0.2.1:5000", "/api/v1/update/status", "10.1.2.3", 200)
+	verify("@", "/api/v1/update/status", "10.1.2.3", 200)
+	verify("@", "/api/v1/update/status", "192.0.2.1", 403)
+	// Only the entry the proxy appended counts; the client forges the rest.
+	verify("192.0.2.1:5000", "/api/v1/update/status", "127.0.0.1, 192.0.2.1", 403)
+	verify("192.0.2.1:5000", "/api/v1/update/status", "192.0.2.1, 10.1.2.3", 200)
+	verify("192.0.2.1:5000", "/api/v1/update/status", ",", 403)
+	verify("192.0.2.1:5000", "/api/v1/update/status", ",anything", 403)
+	verify("192.0.2.1:5000", "/api/v1/update/status", "10.1.2.3,", 403)
+	verify("192.0.2.1:5000", "/api/v1/update/status", "not-an-ip", 403)
+}
+
+func Test_adminACL_allows(t *testing.T) {
+	nets, _ := parseCIDRs("127.0.0.1")
+	acl := adminACL{Networks: nets}
+	for _, addr := range []string{"", "@", " ", "127.0.0.1:80", "localhost"} {
+		if acl.allows(addr) {
+			t.Errorf("%q allowed", addr)
+		}
+	}
+	if !acl.allows("::ffff:127.0.0.1") {
+		t.Error("mapped loopback denied")
+	}
+	if !(adminACL{}).allows("") {
+		t.Error("empty ACL must allow everyone")
+	}
+}
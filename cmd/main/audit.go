@@ -0,0 +1,59 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/msmania/updater/selfupdate"
+)
+
+// verifyAudit checks the audit log at path against the public key in
+// keyPath.
+func verifyAudit(w io.Writer, path, keyPath string) error {
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return err
+	}
+	pub, err := selfupdate.ParseEd25519PublicKey(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", keyPath, err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	n, err := selfupdate.VerifyAuditLog(f, pub)
+	if err != nil {
+		return fmt.Errorf("%s: %w (%d valid records before)", path, err, n)
+	}
+	fmt.Fprintf(w, "%s: %d records verified\n", path, n)
+	return nil
+}
+
+// auditKeygen writes a new private key seed to out and prints the public
+// key.
+func auditKeygen(w io.Writer, out string) error {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	seed := base64.StdEncoding.EncodeToString(priv.Seed()) + "\n"
+	f, err := os.OpenFile(out, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(seed); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, base64.StdEncoding.EncodeToString(pub))
+	return nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/msmania/updater/selfupdate"
+)
+
+func Test_audit(t *testing.T) {
+	dir := t.TempDir()
+	keyPath, pubPath := filepath.Join(dir, "audit.key"), filepath.Join(dir, "audit.pub")
+	var out strings.Builder
+	if err := auditKeygen(&out, keyPath); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(pubPath, []byte(out.String()), 0o644)
+	if err := auditKeygen(&out, keyPath); err == nil {
+		t.Error("keygen must not overwrite an existing key")
+	}
+
+	data, _ := os.ReadFile(keyPath)
+	key, err := selfupdate.ParseEd25519PrivateKey(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logPath := filepath.Join(dir, "audit.log")
+	l := &selfupdate.AuditLog{Path: logPath, Key: key}
+	l.Append(selfupdate.AuditRecord{Time: time.Now(), Event: selfupdate.AuditInstall, To: "v1.1.0"})
+	out.Reset()
+	if err := verifyAudit(&out, logPath, pubPath); err != nil || !strings.Contains(out.String(), "1 records verified") {
+		t.Error("verification failed:", err, out.String())
+	}
+
+	data, _ = os.ReadFile(logPath)
+	os.WriteFile(logPath, []byte(strings.Replace(string(data), "v1.1.0", "v6.6.6", 1)), 0o600)
+	if err := verifyAudit(&out, logPath, pubPath); err == nil {
+		t.Error("tampered log verified")
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net"
+	"time"
+)
+
+// beaconTimeout bounds writing the version to a beacon client.
+const beaconTimeout = 5 * time.Second
+
+// serveBeacon starts answering TCP connections and UDP datagrams on addr
+// with version and a newline, in the background, for monitors that only
+// check ports and read a banner.
+func serveBeacon(addr, version string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	// The same port, also when addr asked for any.
+	pc, err := net.ListenPacket("udp", ln.Addr().String())
+	if err != nil {
+		ln.Close()
+		return err
+	}
+	log.Printf("Serving the version beacon at %s (tcp and udp)", ln.Addr())
+	go beaconTCP(ln, version)
+	go beaconUDP(pc, version)
+	return nil
+}
+
+// beaconTCP writes the banner to every connection accepted by ln and
+// closes it.
+func beaconTCP(ln net.Listener, version string) {
+	banner := []byte(version + "\n")
+	for {
+		conn, err := ln.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			log.Printf("beacon: %v", err)
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		go func() {
+			defer conn.Close()
+			conn.SetWriteDeadline(time.Now().Add(beaconTimeout))
+			conn.Write(banner)
+		}()
+	}
+}
+
+// beaconUDP answers every datagram received by pc with the banner.
+func beaconUDP(pc net.PacketConn, version string) {
+	banner := []byte(version + "\n")
+	buf := make([]byte, 512)
+	for {
+		_, from, err := pc.ReadFrom(buf)
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			log.Printf("beacon: %v", err)
+			continue
+		}
+		pc.WriteTo(banner, from)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func Test_beacon(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	pc, err := net.ListenPacket("udp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	go beaconTCP(ln, "v1.2.3")
+	go beaconUDP(pc, "v1.2.3")
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if b, err := io.ReadAll(conn); err != nil || string(b) != "v1.2.3\n" {
+		t.Errorf("tcp banner %q, %v", b, err)
+	}
+
+	uc, err := net.Dial("udp", pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer uc.Close()
+	uc.SetDeadline(time.Now().Add(5 * time.Second))
+	uc.Write([]byte("?"))
+	buf := make([]byte, 64)
+	if n, err := uc.Read(buf); err != nil || string(buf[:n]) != "v1.2.3\n" {
+		t.Errorf("udp banner %q, %v", buf[:n], err)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// errUsage is returned when a command is invoked with invalid arguments.
+// The usage text has already been printed by the time it is returned.
+var errUsage = errors.New("invalid usage")
+
+// command is a node in the CLI command tree. Besides dispatching, the
+// metadata carried here drives shell completion and man page generation.
+type command struct {
+	Name  string
+	Short string // one-line summary shown in listings
+	Long  string // paragraph shown in help and man pages
+	Usage string // positional argument synopsis, e.g. "<shell>"
+	// Args lists the fixed values accepted as the first positional
+	// argument, if any. Used for completion only.
+	Args     []string
+	Flags    *flag.FlagSet
+	Run      func(cmd *command, args []string) error
+	Children []*command
+
+	parent *command
+}
+
+// newCommand creates a command with an empty flag set.
+func newCommand(name, short string) *command {
+	c := &command{
+		Name:  name,
+		Short: short,
+		Flags: flag.NewFlagSet(name, flag.ContinueOnError),
+	}
+	c.Flags.Usage = func() { c.printUsage(c.Flags.Output()) }
+	return c
+}
+
+// add attaches subcommands to c and returns c for chaining.
+func (c *command) add(children ...*command) *command {
+	for _, child := range children {
+		child.parent = c
+		c.Children = append(c.Children, child)
+	}
+	return c
+}
+
+// path returns the space-separated command path from the root.
+func (c *command) path() string {
+	if c.parent == nil {
+		return c.Name
+	}
+	return c.parent.path() + " " + c.Name
+}
+
+// root returns the top of the command tree containing c.
+func (c *command) root() *command {
+	for c.parent != nil {
+		c = c.parent
+	}
+	return c
+}
+
+// lookup finds a direct subcommand by name.
+func (c *command) lookup(name string) *command {
+	for _, child := range c.Children {
+		if child.Name == name {
+			return child
+		}
+	}
+	return nil
+}
+
+// walk visits c and all of its descendants in depth-first order.
+func (c *command) walk(fn func(*command)) {
+	fn(c)
+	for _, child := range c.Children {
+		child.walk(fn)
+	}
+}
+
+// flagNames returns the names of all flags of c, sorted.
+func (c *command) flagNames() []string {
+	var names []string
+	c.Flags.VisitAll(func(f *flag.Flag) {
+		names = append(names, f.Name)
+	})
+	sort.Strings(names)
+	return names
+}
+
+// execute dispatches args to the matching subcommand, or parses them as
+// flags of c and runs it.
+func (c *command) execute(args []string) error {
+	if len(args) > 0 {
+		if sub := c.lookup(args[0]); sub != nil {
+			return sub.execute(args[1:])
+		}
+	}
+	args, err := resolveAliases(c.Flags, args)
+	if err != nil {
+		return err
+	}
+	if err := c.Flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return errUsage
+	}
+	if err := applyEnv(c.Flags, os.LookupEnv); err != nil {
+		return err
+	}
+	if c.Run == nil {
+		c.printUsage(os.Stderr)
+		return errUsage
+	}
+	return c.Run(c, c.Flags.Args())
+}
+
+// printUsage writes the help text for c.
+func (c *command) printUsage(w io.Writer) {
+	synopsis := c.path()
+	if hasFlags(c.Flags) {
+		synopsis += " [flags]"
+	}
+	if len(c.Children) > 0 && c.Run == nil {
+		synopsis += " <command>"
+	} else if len(c.Children) > 0 {
+		synopsis += " [command]"
+	}
+	if c.Usage != "" {
+		synopsis += " " + c.Usage
+	}
+	fmt.Fprintf(w, "Usage: %s\n", synopsis)
+	if c.Long != "" {
+		fmt.Fprintf(w, "\n%s\n", c.Long)
+	} else if c.Short != "" {
+		fmt.Fprintf(w, "\n%s\n", c.Short)
+	}
+	if len(c.Children) > 0 {
+		fmt.Fprintln(w, "\nCommands:")
+		for _, child := range c.Children {
+			fmt.Fprintf(w, "  %-12s %s\n", child.Name, child.Short)
+		}
+	}
+	if hasFlags(c.Flags) {
+		fmt.Fprintln(w, "\nFlags:")
+		c.Flags.SetOutput(w)
+		c.Flags.PrintDefaults()
+		fmt.Fprintf(w, "\n%s\n", envHelp(c.Flags))
+	}
+}
+
+// hasFlags reports whether fs defines at least one flag.
+func hasFlags(fs *flag.FlagSet) bool {
+	found := false
+	fs.VisitAll(func(*flag.Flag) { found = true })
+	return found
+}
+
+// isBoolFlag reports whether f can be given without a value.
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// oneOf validates that args holds exactly one of the values in c.Args.
+func (c *command) oneOf(args []string) (string, error) {
+	if len(args) != 1 {
+		c.printUsage(os.Stderr)
+		return "", errUsage
+	}
+	for _, a := range c.Args {
+		if a == args[0] {
+			return a, nil
+		}
+	}
+	return "", fmt.Errorf("unknown argument %q (expected one of %s)",
+		args[0], strings.Join(c.Args, ", "))
+}
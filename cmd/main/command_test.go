@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_command_execute(t *testing.T) {
+	var got []string
+	root := newCommand("app", "test app")
+	child := newCommand("child", "child command")
+	name := child.Flags.String("name", "", "a name")
+	child.Run = func(c *command, args []string) error {
+		got = append([]string{*name}, args...)
+		return nil
+	}
+	root.add(child)
+
+	if err := root.execute([]string{"child", "-name", "x", "y"}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, ",") != "x,y" {
+		t.Error("unexpected args: " + strings.Join(got, ","))
+	}
+	if child.path() != "app child" {
+		t.Error("unexpected path: " + child.path())
+	}
+	if err := root.execute(nil); err != errUsage {
+		t.Error("command without Run should report usage error")
+	}
+}
+
+func Test_writeCompletion(t *testing.T) {
+	root := newRootCommand()
+	for _, shell := range completionShells {
+		var b bytes.Buffer
+		if err := writeCompletion(&b, root, shell); err != nil {
+			t.Fatal(shell, err)
+		}
+		for _, want := range []string{"completion", "powershell", "man", "skip-upgrade"} {
+			if !strings.Contains(b.String(), want) {
+				t.Errorf("%s completion lacks %q", shell, want)
+			}
+		}
+	}
+	if err := writeCompletion(&bytes.Buffer{}, root, "tcsh"); err == nil {
+		t.Error("unsupported shell should fail")
+	}
+}
+
+func Test_writeManPage(t *testing.T) {
+	root := newRootCommand()
+	var b bytes.Buffer
+	date := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	if err := writeManPage(&b, root.lookup("docs").lookup("man"), date); err != nil {
+		t.Fatal(err)
+	}
+	page := b.String()
+	if !strings.HasPrefix(page, `.TH UPDATER-DOCS-MAN 1 "2025-01-02"`) {
+		t.Error("unexpected header: " + strings.SplitN(page, "\n", 2)[0])
+	}
+	if !strings.Contains(page, `\fB\-dir\fR \fIvalue\fR`) {
+		t.Error("flag missing from OPTIONS")
+	}
+	if !strings.Contains(page, ".SH ENVIRONMENT\n") || !strings.Contains(page, `\fBUPDATER_DIR\fR`) {
+		t.Error("environment variable missing from ENVIRONMENT")
+	}
+	if !strings.Contains(page, ".BR updater\\-docs (1)") {
+		t.Error("parent missing from SEE ALSO")
+	}
+}
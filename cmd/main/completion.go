@@ -0,0 +1,230 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// ---------------------------------------------------------------------
+// Shell completion scripts generated from the command tree
+// ---------------------------------------------------------------------
+
+var completionShells = []string{"bash", "zsh", "fish", "powershell"}
+
+// completionCandidates returns the words that may follow the command path of c.
+func completionCandidates(c *command) []string {
+	var out []string
+	for _, child := range c.Children {
+		out = append(out, child.Name)
+	}
+	out = append(out, c.Args...)
+	for _, name := range c.flagNames() {
+		out = append(out, "-"+name)
+	}
+	return out
+}
+
+// valueFlags returns every flag in the tree that consumes the next word.
+func valueFlags(root *command) []string {
+	seen := map[string]bool{}
+	root.walk(func(c *command) {
+		c.Flags.VisitAll(func(f *flag.Flag) {
+			if !isBoolFlag(f) {
+				seen["-"+f.Name] = true
+			}
+		})
+	})
+	var out []string
+	for name := range seen {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// writeCompletion writes the completion script for shell to w.
+func writeCompletion(w io.Writer, root *command, shell string) error {
+	switch shell {
+	case "bash":
+		return writeBashCompletion(w, root)
+	case "zsh":
+		return writeZshCompletion(w, root)
+	case "fish":
+		return writeFishCompletion(w, root)
+	case "powershell":
+		return writePowerShellCompletion(w, root)
+	}
+	return fmt.Errorf("unsupported shell %q", shell)
+}
+
+func writeBashCompletion(w io.Writer, root *command) error {
+	var b strings.Builder
+	fn := "_" + shellIdent(root.Name)
+	fmt.Fprintf(&b, "# bash completion for %s\n", root.Name)
+	fmt.Fprintf(&b, "%s() {\n", fn)
+	b.WriteString("    local cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	fmt.Fprintf(&b, "    local cmdpath=%q skip=0 i\n", root.Name)
+	b.WriteString("    for ((i = 1; i < COMP_CWORD; i++)); do\n")
+	b.WriteString("        if ((skip)); then skip=0; continue; fi\n")
+	b.WriteString("        case \"${COMP_WORDS[i]}\" in\n")
+	if vf := valueFlags(root); len(vf) > 0 {
+		fmt.Fprintf(&b, "            %s) skip=1 ;;\n", strings.Join(vf, "|"))
+	}
+	b.WriteString("            -*) ;;\n")
+	b.WriteString("            *) cmdpath=\"$cmdpath ${COMP_WORDS[i]}\" ;;\n")
+	b.WriteString("        esac\n")
+	b.WriteString("    done\n")
+	b.WriteString("    local candidates=\"\"\n")
+	b.WriteString("    case \"$cmdpath\" in\n")
+	root.walk(func(c *command) {
+		fmt.Fprintf(&b, "        %q) candidates=%q ;;\n",
+			c.path(), strings.Join(completionCandidates(c), " "))
+	})
+	b.WriteString("    esac\n")
+	b.WriteString("    COMPREPLY=($(compgen -W \"$candidates\" -- \"$cur\"))\n")
+	b.WriteString("}\n")
+	fmt.Fprintf(&b, "complete -F %s %s\n", fn, root.Name)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeZshCompletion(w io.Writer, root *command) error {
+	var b strings.Builder
+	fn := "_" + shellIdent(root.Name)
+	fmt.Fprintf(&b, "#compdef %s\n\n", root.Name)
+	fmt.Fprintf(&b, "%s() {\n", fn)
+	fmt.Fprintf(&b, "    local cmdpath=%q skip=0 i\n", root.Name)
+	b.WriteString("    local -a candidates\n")
+	b.WriteString("    for ((i = 2; i < CURRENT; i++)); do\n")
+	b.WriteString("        if ((skip)); then skip=0; continue; fi\n")
+	b.WriteString("        case \"${words[i]}\" in\n")
+	if vf := valueFlags(root); len(vf) > 0 {
+		fmt.Fprintf(&b, "            %s) skip=1 ;;\n", strings.Join(vf, "|"))
+	}
+	b.WriteString("            -*) ;;\n")
+	b.WriteString("            *) cmdpath=\"$cmdpath ${words[i]}\" ;;\n")
+	b.WriteString("        esac\n")
+	b.WriteString("    done\n")
+	b.WriteString("    case \"$cmdpath\" in\n")
+	root.walk(func(c *command) {
+		fmt.Fprintf(&b, "        %q) candidates=(%s) ;;\n",
+			c.path(), strings.Join(completionCandidates(c), " "))
+	})
+	b.WriteString("    esac\n")
+	b.WriteString("    compadd -a candidates\n")
+	b.WriteString("}\n\n")
+	fmt.Fprintf(&b, "if [ \"$funcstack[1]\" = %q ]; then\n", fn)
+	fmt.Fprintf(&b, "    %s \"$@\"\n", fn)
+	b.WriteString("else\n")
+	fmt.Fprintf(&b, "    compdef %s %s\n", fn, root.Name)
+	b.WriteString("fi\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeFishCompletion(w io.Writer, root *command) error {
+	var b strings.Builder
+	fn := "__" + shellIdent(root.Name) + "_cmdpath"
+	fmt.Fprintf(&b, "# fish completion for %s\n", root.Name)
+	fmt.Fprintf(&b, "function %s\n", fn)
+	b.WriteString("    set -l tokens (commandline -opc)\n")
+	fmt.Fprintf(&b, "    set -l cmdpath %s\n", root.Name)
+	b.WriteString("    set -l skip 0\n")
+	b.WriteString("    for tok in $tokens[2..-1]\n")
+	b.WriteString("        if test $skip -eq 1\n")
+	b.WriteString("            set skip 0\n")
+	b.WriteString("            continue\n")
+	b.WriteString("        end\n")
+	b.WriteString("        switch $tok\n")
+	if vf := valueFlags(root); len(vf) > 0 {
+		fmt.Fprintf(&b, "            case %s\n", strings.Join(vf, " "))
+		b.WriteString("                set skip 1\n")
+	}
+	b.WriteString("            case '-*'\n")
+	b.WriteString("            case '*'\n")
+	b.WriteString("                set cmdpath \"$cmdpath $tok\"\n")
+	b.WriteString("        end\n")
+	b.WriteString("    end\n")
+	b.WriteString("    echo $cmdpath\n")
+	b.WriteString("end\n\n")
+	fmt.Fprintf(&b, "complete -c %s -f\n", root.Name)
+	root.walk(func(c *command) {
+		cond := fmt.Sprintf("test (%s) = '%s'", fn, c.path())
+		for _, child := range c.Children {
+			fmt.Fprintf(&b, "complete -c %s -n \"%s\" -a %s -d %s\n",
+				root.Name, cond, child.Name, fishQuote(child.Short))
+		}
+		for _, arg := range c.Args {
+			fmt.Fprintf(&b, "complete -c %s -n \"%s\" -a %s\n", root.Name, cond, arg)
+		}
+		c.Flags.VisitAll(func(f *flag.Flag) {
+			line := fmt.Sprintf("complete -c %s -n \"%s\" -o %s -d %s",
+				root.Name, cond, f.Name, fishQuote(f.Usage))
+			if !isBoolFlag(f) {
+				line += " -r"
+			}
+			b.WriteString(line + "\n")
+		})
+	})
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writePowerShellCompletion(w io.Writer, root *command) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# powershell completion for %s\n", root.Name)
+	fmt.Fprintf(&b, "Register-ArgumentCompleter -Native -CommandName '%s' -ScriptBlock {\n", root.Name)
+	b.WriteString("    param($wordToComplete, $commandAst, $cursorPosition)\n")
+	b.WriteString("    $candidates = @{\n")
+	root.walk(func(c *command) {
+		var quoted []string
+		for _, s := range completionCandidates(c) {
+			quoted = append(quoted, psQuote(s))
+		}
+		fmt.Fprintf(&b, "        %s = @(%s)\n", psQuote(c.path()), strings.Join(quoted, ", "))
+	})
+	b.WriteString("    }\n")
+	var quoted []string
+	for _, s := range valueFlags(root) {
+		quoted = append(quoted, psQuote(s))
+	}
+	fmt.Fprintf(&b, "    $valueFlags = @(%s)\n", strings.Join(quoted, ", "))
+	fmt.Fprintf(&b, "    $cmdpath = %s\n", psQuote(root.Name))
+	b.WriteString("    $skip = $false\n")
+	b.WriteString("    $elements = $commandAst.CommandElements | Select-Object -Skip 1 |\n")
+	b.WriteString("        Where-Object { $_.Extent.EndOffset -lt $cursorPosition }\n")
+	b.WriteString("    foreach ($e in $elements) {\n")
+	b.WriteString("        $t = $e.ToString()\n")
+	b.WriteString("        if ($skip) { $skip = $false; continue }\n")
+	b.WriteString("        if ($valueFlags -contains $t) { $skip = $true; continue }\n")
+	b.WriteString("        if ($t.StartsWith('-')) { continue }\n")
+	b.WriteString("        $cmdpath = \"$cmdpath $t\"\n")
+	b.WriteString("    }\n")
+	b.WriteString("    $candidates[$cmdpath] | Where-Object { $_ -like \"$wordToComplete*\" } | ForEach-Object {\n")
+	b.WriteString("        [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)\n")
+	b.WriteString("    }\n")
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// shellIdent turns a command name into a valid shell function identifier.
+func shellIdent(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+func fishQuote(s string) string {
+	return "'" + strings.ReplaceAll(strings.ReplaceAll(s, `\`, `\\`), "'", `\'`) + "'"
+}
+
+func psQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
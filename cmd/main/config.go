@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// envPrefix starts the environment variable of every flag; see envName.
+const envPrefix = "UPDATER_"
+
+// envHelp documents the environment variables of the flags in fs and
+// their precedence.
+func envHelp(fs *flag.FlagSet) string {
+	help := "Every flag can also be set in the environment as " + envPrefix +
+		"<NAME>, upper-cased with dashes as underscores (e.g. UPDATER_SKIP_UPGRADE for -skip-upgrade). " +
+		"Command-line flags take precedence over environment variables"
+	if fs.Lookup("config") != nil {
+		help += ", which take precedence over the -config file"
+	}
+	return help + "."
+}
+
+// envName returns the environment variable setting the named flag, e.g.
+// UPDATER_CHANNEL for -channel.
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// flagAliases maps alternative flag names to the flag they set. An alias
+// works on the command line, in the environment and as a config key, but
+// is not a flag of its own, so a setting keeps a single value.
+var flagAliases = map[string]string{"staging-dir": "work-dir"}
+
+// canonicalFlag returns the flag of fs that name stands for.
+func canonicalFlag(fs *flag.FlagSet, name string) string {
+	if to, ok := flagAliases[name]; ok && fs.Lookup(to) != nil {
+		return to
+	}
+	return name
+}
+
+// resolveAliases rewrites the aliased flags in the command line args of
+// fs to their flag. Giving an alias and its flag different values is an
+// error.
+func resolveAliases(fs *flag.FlagSet, args []string) ([]string, error) {
+	args = append([]string(nil), args...)
+	type setting struct{ name, value string }
+	given := map[string]setting{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" || len(arg) < 2 || arg[0] != '-' {
+			break
+		}
+		dashes := "-"
+		if strings.HasPrefix(arg, "--") {
+			dashes = "--"
+		}
+		name, value, hasValue := strings.Cut(arg[len(dashes):], "=")
+		canon := canonicalFlag(fs, name)
+		f := fs.Lookup(canon)
+		if f == nil {
+			break // left to fs.Parse to report
+		}
+		if canon != name {
+			args[i] = dashes + canon + arg[len(dashes)+len(name):]
+		}
+		if !hasValue && !isBoolFlag(f) && i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+		if prev, ok := given[canon]; ok && prev.name != name && prev.value != value {
+			return nil, fmt.Errorf("-%s and -%s conflict", prev.name, name)
+		}
+		given[canon] = setting{name, value}
+	}
+	return args, nil
+}
+
+// applyEnv sets the flags of fs not given on the command line from their
+// environment variables, looked up with lookup. Since such flags count as
+// set, applyConfigFile leaves them alone.
+func applyEnv(fs *flag.FlagSet, lookup func(string) (string, bool)) error {
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		v, ok := lookup(envName(f.Name))
+		for alias, to := range flagAliases {
+			av, aok := lookup(envName(alias))
+			if to != f.Name || !aok {
+				continue
+			}
+			if ok && av != v && err == nil {
+				err = fmt.Errorf("%s and %s conflict", envName(f.Name), envName(alias))
+			}
+			v, ok = av, true
+		}
+		if !ok || explicit[f.Name] || err != nil {
+			return
+		}
+		if serr := fs.Set(f.Name, v); serr != nil {
+			err = fmt.Errorf("%s: %w", envName(f.Name), serr)
+		}
+	})
+	return err
+}
+
+// applyConfigFile sets the flags of fs from a JSON object whose keys are
+// flag names, e.g. {"allow-major-upgrade": true, "channel": "beta"}, or
+// their aliases. Flags already set, on the command line or by applyEnv,
+// take precedence over the file.
+func applyConfigFile(fs *flag.FlagSet, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var values map[string]any
+	if err := dec.Decode(&values); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	for key, v := range values {
+		name := canonicalFlag(fs, key)
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("%s: unknown key %q", path, key)
+		}
+		if other, ok := values[name]; ok && key != name && fmt.Sprint(other) != fmt.Sprint(v) {
+			return fmt.Errorf("%s: keys %q and %q conflict", path, key, name)
+		}
+		if explicit[name] {
+			continue
+		}
+		switch v.(type) {
+		case string, bool, json.Number:
+		default:
+			return fmt.Errorf("%s: key %q must be a string, number or boolean", path, key)
+		}
+		if err := fs.Set(name, fmt.Sprint(v)); err != nil {
+			return fmt.Errorf("%s: key %q: %w", path, key, err)
+		}
+	}
+	return nil
+}
+
+// liveFlags are the flags whose settings applyConfig applies at run
+// time. A reload reports changes to any other flag as waiting for a
+// restart; keep it in sync with applyConfig.
+var liveFlags = map[string]bool{
+	"checksum-asset": true, "manifest-keys": true, "manifest-threshold": true,
+	"download-connections": true, "download-segment-size": true, "memory-download-limit": true,
+	"channel": true, "constraint": true, "mirrors": true, "asset-fallbacks": true, "asset-regexp": true,
+	"allow-major-upgrade": true, "allow-packaged": true, "upgrade-with-package-manager": true,
+	"min-release-age": true, "release-rule": true, "canary-url": true, "canary-timeout": true,
+	"messages": true, "restart-services": true, "restart-services-timeout": true,
+	"update-policy": true, "maintenance-window": true, "install-mode": true,
+	"overlay-dir": true, "overlay-dropin": true, "overlay-profile": true,
+	"sbom-asset": true, "sbom-deny-licenses": true, "sbom-deny-packages": true, "sbom-deny-vulns": true,
+	"keyring": true, "signature-suffix": true,
+	"rekor-url": true, "rekor-key": true, "rekor-entry-suffix": true,
+	"advisory-url": true, "advisory-package": true, "advisory-ecosystem": true, "advisory-fail-open": true,
+	"fleet-url": true, "fleet-agent-id": true, "dns-source": true, "dns-resolver": true,
+}
+
+// configChanges lists the flags a reload changed, sorted.
+type configChanges struct {
+	Live    []string // applied by applyConfig
+	Restart []string // taking effect after a restart
+}
+
+// flagValues returns the values of the flags of fs by name.
+func flagValues(fs *flag.FlagSet) map[string]string {
+	values := map[string]string{}
+	fs.VisitAll(func(f *flag.Flag) { values[f.Name] = f.Value.String() })
+	return values
+}
+
+// diffFlags compares the flag values of a reload with those running,
+// which it updates with the changes that apply live.
+func diffFlags(running, reloaded map[string]string) configChanges {
+	var c configChanges
+	for name, v := range reloaded {
+		if old, ok := running[name]; !ok || old == v {
+			continue
+		}
+		if liveFlags[name] {
+			c.Live = append(c.Live, name)
+			running[name] = v
+		} else {
+			c.Restart = append(c.Restart, name)
+		}
+	}
+	sort.Strings(c.Live)
+	sort.Strings(c.Restart)
+	return c
+}
+
+// cloneFlag defines a flag like f on fs, at its default value. The value
+// has the type of that of f, so both print values alike.
+func cloneFlag(fs *flag.FlagSet, f *flag.Flag) {
+	if t := reflect.TypeOf(f.Value); t.Kind() == reflect.Pointer {
+		v := reflect.New(t.Elem()).Interface().(flag.Value)
+		if v.Set(f.DefValue) == nil {
+			fs.Var(v, f.Name, f.Usage)
+			return
+		}
+	}
+	fs.String(f.Name, f.DefValue, f.Usage)
+}
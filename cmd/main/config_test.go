@@ -0,0 +1,280 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/msmania/updater/selfupdate"
+)
+
+func Test_applyConfigFile(t *testing.T) {
+	newFlags := func() (*flag.FlagSet, *bool, *string, *time.Duration, *int) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		return fs,
+			fs.Bool("allow-major-upgrade", false, ""),
+			fs.String("channel", "stable", ""),
+			fs.Duration("dial-timeout", time.Second, ""),
+			fs.Int("download-connections", 1, "")
+	}
+	write := func(content string) string {
+		path := filepath.Join(t.TempDir(), "config.json")
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	fs, major, channel, dial, conns := newFlags()
+	fs.Parse([]string{"-channel", "rc"})
+	path := write(`{"allow-major-upgrade": true, "channel": "beta", "dial-timeout": "3s", "download-connections": 4}`)
+	if err := applyConfigFile(fs, path); err != nil {
+		t.Fatal(err)
+	}
+	if !*major || *dial != 3*time.Second || *conns != 4 {
+		t.Error("config values not applied")
+	}
+	if *channel != "rc" {
+		t.Error("command line must take precedence, got channel " + *channel)
+	}
+
+	verifyFail := func(content string) {
+		fs, _, _, _, _ := newFlags()
+		if err := applyConfigFile(fs, write(content)); err == nil {
+			t.Error("expected error for " + content)
+		}
+	}
+	verifyFail(`{"no-such-flag": 1}`)
+	verifyFail(`{"download-connections": "many"}`)
+	verifyFail(`{"channel": ["beta"]}`)
+	verifyFail(`not json`)
+}
+
+func Test_updaterFlags_reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("listen", ":8080", "")
+	f := addUpdaterFlags(fs)
+	write(`{"channel": "beta", "listen": ":9090", "download-connections": 2}`)
+	if err := fs.Parse([]string{"-config", path, "-download-connections", "4"}); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := f.load(fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Channel != "beta" || cfg.Connections != 4 {
+		t.Errorf("unexpected initial config: channel %s, %d connections", cfg.Channel, cfg.Connections)
+	}
+
+	write(`{"channel": "rc", "listen": ":9091", "download-connections": 8, "install-mode": "staged"}`)
+	if cfg, err = f.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Channel != "rc" || !cfg.Staged {
+		t.Errorf("config file not reloaded: channel %s, staged %v", cfg.Channel, cfg.Staged)
+	}
+	if cfg.Connections != 4 {
+		t.Errorf("command line must take precedence, got %d connections", cfg.Connections)
+	}
+	if c := cfg.Changes; strings.Join(c.Live, ",") != "channel,install-mode" || strings.Join(c.Restart, ",") != "listen" {
+		t.Errorf("unexpected changes %+v", c)
+	}
+	if cfg, err = f.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if c := cfg.Changes; len(c.Live) != 0 || strings.Join(c.Restart, ",") != "listen" {
+		t.Errorf("a pending restart must be reported until then, got %+v", c)
+	}
+
+	write(`{"channel": "nightly"}`)
+	if _, err := f.reload(); err == nil {
+		t.Error("invalid config file accepted")
+	}
+}
+
+func Test_liveFlags(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	addUpdaterFlags(fs)
+	for name := range liveFlags {
+		if fs.Lookup(name) == nil {
+			t.Errorf("unknown live flag -%s", name)
+		}
+	}
+}
+
+func Test_cloneFlag(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Duration("notify-interval", 24*time.Hour, "")
+	clone := flag.NewFlagSet("clone", flag.ContinueOnError)
+	cloneFlag(clone, fs.Lookup("notify-interval"))
+	fs.Set("notify-interval", "90m")
+	clone.Set("notify-interval", "1h30m")
+	if a, b := fs.Lookup("notify-interval").Value.String(), clone.Lookup("notify-interval").Value.String(); a != b {
+		t.Errorf("cloned flag prints %q, want %q", b, a)
+	}
+}
+
+func Test_applyEnv(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	f := addUpdaterFlags(fs)
+	path := filepath.Join(t.TempDir(), "config.json")
+	content := `{"channel": "beta", "constraint": "<2.0.0", "download-connections": 2}`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{
+		"UPDATER_CONFIG":               path,
+		"UPDATER_CHANNEL":              "rc",
+		"UPDATER_DOWNLOAD_CONNECTIONS": "3",
+		"UPDATER_ALLOW_MAJOR_UPGRADE":  "true",
+	}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+	fs.Parse([]string{"-download-connections", "4"})
+	if err := applyEnv(fs, lookup); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := f.load(fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Connections != 4 {
+		t.Errorf("command line must take precedence, got %d connections", cfg.Connections)
+	}
+	if cfg.Channel != "rc" || !cfg.AllowMajorUpgrade {
+		t.Errorf("environment must take precedence over the file, got channel %s", cfg.Channel)
+	}
+	if cfg.Constraint.String() != "<2.0.0" {
+		t.Errorf("config file not applied, got constraint %q", cfg.Constraint)
+	}
+
+	env = map[string]string{"UPDATER_DOWNLOAD_CONNECTIONS": "many"}
+	if err := applyEnv(flag.NewFlagSet("test", flag.ContinueOnError), lookup); err != nil {
+		t.Error(err)
+	}
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	addUpdaterFlags(fs)
+	if err := applyEnv(fs, lookup); err == nil || !strings.Contains(err.Error(), "UPDATER_DOWNLOAD_CONNECTIONS") {
+		t.Error("expected an error naming the variable, got", err)
+	}
+}
+
+func Test_flagAliases(t *testing.T) {
+	newFlags := func() (*flag.FlagSet, *string) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		fs.Bool("json", false, "")
+		return fs, fs.String("work-dir", "", "")
+	}
+	verifyArgs := func(args []string, want string, wantErr bool) {
+		t.Helper()
+		fs, dir := newFlags()
+		args, err := resolveAliases(fs, args)
+		if err == nil {
+			err = fs.Parse(args)
+		}
+		if (err != nil) != wantErr || err == nil && *dir != want {
+			t.Errorf("%v: got %q, %v", args, *dir, err)
+		}
+	}
+	verifyArgs([]string{"-staging-dir", "/a"}, "/a", false)
+	verifyArgs([]string{"--staging-dir=/a", "-json"}, "/a", false)
+	verifyArgs([]string{"-json", "-work-dir", "/a", "-staging-dir", "/a"}, "/a", false)
+	verifyArgs([]string{"-work-dir", "/a", "-staging-dir=/b"}, "", true)
+	verifyArgs([]string{"-work-dir", "/a", "--", "-staging-dir", "/b"}, "/a", false)
+	if fs := flag.NewFlagSet("test", flag.ContinueOnError); canonicalFlag(fs, "staging-dir") != "staging-dir" {
+		t.Error("alias resolved without its flag")
+	}
+
+	verifyEnv := func(env map[string]string, want string, wantErr bool) {
+		t.Helper()
+		fs, dir := newFlags()
+		err := applyEnv(fs, func(name string) (string, bool) {
+			v, ok := env[name]
+			return v, ok
+		})
+		if (err != nil) != wantErr || err == nil && *dir != want {
+			t.Errorf("%v: got %q, %v", env, *dir, err)
+		}
+	}
+	verifyEnv(map[string]string{"UPDATER_STAGING_DIR": "/a"}, "/a", false)
+	verifyEnv(map[string]string{"UPDATER_STAGING_DIR": "/a", "UPDATER_WORK_DIR": "/a"}, "/a", false)
+	verifyEnv(map[string]string{"UPDATER_STAGING_DIR": "/a", "UPDATER_WORK_DIR": "/b"}, "", true)
+
+	verifyConfig := func(content, want string, wantErr bool) {
+		t.Helper()
+		fs, dir := newFlags()
+		path := filepath.Join(t.TempDir(), "config.json")
+		os.WriteFile(path, []byte(content), 0o644)
+		err := applyConfigFile(fs, path)
+		if (err != nil) != wantErr || err == nil && *dir != want {
+			t.Errorf("%s: got %q, %v", content, *dir, err)
+		}
+	}
+	verifyConfig(`{"staging-dir": "/a"}`, "/a", false)
+	verifyConfig(`{"staging-dir": "/a", "work-dir": "/a"}`, "/a", false)
+	verifyConfig(`{"staging-dir": "/a", "work-dir": "/b"}`, "", true)
+}
+
+func Test_updaterFlags_policy(t *testing.T) {
+	verify := func(args []string, wantPolicy selfupdate.UpdatePolicy, wantWindows int, wantErr bool) {
+		t.Helper()
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		f := addUpdaterFlags(fs)
+		if err := fs.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := f.load(fs)
+		if (err != nil) != wantErr {
+			t.Errorf("%q: err = %v, want error %v", args, err, wantErr)
+			return
+		}
+		if err == nil && (cfg.Policy != wantPolicy || len(cfg.Windows) != wantWindows) {
+			t.Errorf("%q: policy %s with %d windows", args, cfg.Policy, len(cfg.Windows))
+		}
+	}
+	verify(nil, selfupdate.PolicyAuto, 0, false)
+	verify([]string{"-update-policy", "manual"}, selfupdate.PolicyManual, 0, false)
+	verify([]string{"-update-policy", "scheduled", "-maintenance-window", "Mon-Fri 02:00-04:00; Sat,Sun 22:00-06:00"},
+		selfupdate.PolicyScheduled, 2, false)
+	verify([]string{"-update-policy", "scheduled"}, "", 0, true)
+	verify([]string{"-maintenance-window", "02:00"}, "", 0, true)
+	verify([]string{"-update-policy", "sometimes"}, "", 0, true)
+}
+
+func Test_updaterFlags_network(t *testing.T) {
+	verify := func(args []string, wantNetwork, wantResolver string, wantErr bool) {
+		t.Helper()
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		f := addUpdaterFlags(fs)
+		if err := fs.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := f.load(fs)
+		if (err != nil) != wantErr {
+			t.Errorf("%q: err = %v, want error %v", args, err, wantErr)
+			return
+		}
+		if err == nil && (cfg.Transport.Network != wantNetwork || cfg.Transport.Resolver != wantResolver) {
+			t.Errorf("%q: network %q, resolver %q", args, cfg.Transport.Network, cfg.Transport.Resolver)
+		}
+	}
+	verify(nil, "", "", false)
+	verify([]string{"-force-ipv4", "-resolver", "192.0.2.53"}, "tcp4", "192.0.2.53:53", false)
+	verify([]string{"-force-ipv6", "-resolver", "[2001:db8::53]"}, "tcp6", "[2001:db8::53]:53", false)
+	verify([]string{"-resolver", "2001:db8::53"}, "", "[2001:db8::53]:53", false)
+	verify([]string{"-resolver", "dns.internal:5353"}, "", "dns.internal:5353", false)
+	verify([]string{"-force-ipv4", "-force-ipv6"}, "", "", true)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/msmania/updater/selfupdate"
+)
+
+// Exit codes of update -cron.
+const (
+	cronUpdated  = 10 // a release was installed or staged
+	cronFailed   = 11
+	cronLocked   = 12 // another run is in progress
+	cronTimedOut = 13
+)
+
+// cronLockFile is the lock below -state-dir keeping cron runs apart.
+const cronLockFile = "cron.lock"
+
+// exitStatus is returned by a command that has reported its outcome and
+// only needs main to exit with the status.
+type exitStatus int
+
+func (e exitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+// cronFlags are the flags of update -cron.
+type cronFlags struct {
+	enabled    bool
+	maxRuntime time.Duration
+}
+
+func addCronFlags(fs *flag.FlagSet) *cronFlags {
+	f := &cronFlags{}
+	fs.BoolVar(&f.enabled, "cron", false,
+		"Run as a cron job: print nothing unless a release was installed or the update failed, "+
+			"skip the run while another one holds the lock in -state-dir, and exit with a status telling what happened")
+	fs.DurationVar(&f.maxRuntime, "max-runtime", 9*time.Minute,
+		"With -cron, give up an update still running after this long (0 disables)")
+	return f
+}
+
+// runCron runs update as a cron job; see the -cron flag. The log goes to
+// logw, or if that is nil is held back and written to stderr only if the
+// run did something worth reporting, which report then writes to w.
+// Holding the lock of another run is not an error, just exit status
+// cronLocked.
+func runCron(w, stderr io.Writer, f *cronFlags, stateDir string, logw io.Writer,
+	update func(context.Context) (*selfupdate.UpdateInfo, error),
+	report func(io.Writer, *selfupdate.UpdateInfo, error) error) error {
+	if stateDir == "" {
+		return errors.New("-cron requires -state-dir for its lock")
+	}
+	var logs bytes.Buffer
+	var out io.Writer = &logs
+	if logw != nil {
+		out = logw
+	}
+	prev := log.Writer()
+	log.SetOutput(out)
+	defer log.SetOutput(prev)
+
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		return err
+	}
+	release, err := selfupdate.TryLock(filepath.Join(stateDir, cronLockFile))
+	if errors.Is(err, selfupdate.ErrLocked) {
+		log.Printf("Another run holds %s; skipping", filepath.Join(stateDir, cronLockFile))
+		return exitStatus(cronLocked)
+	}
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	ctx := context.Background()
+	if f.maxRuntime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.maxRuntime)
+		defer cancel()
+	}
+	info, err := update(ctx)
+	if info == nil {
+		info = &selfupdate.UpdateInfo{Decision: selfupdate.DecisionFailed}
+	}
+	status := 0
+	switch info.Decision {
+	case selfupdate.DecisionFailed:
+		status = cronFailed
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			status = cronTimedOut
+			err = fmt.Errorf("no result within -max-runtime %s: %w", f.maxRuntime, err)
+		}
+	case selfupdate.DecisionUpgraded, selfupdate.DecisionStaged, selfupdate.DecisionRolloutRequested,
+		selfupdate.DecisionRolledBack:
+		status = cronUpdated
+	default:
+		return nil
+	}
+	stderr.Write(logs.Bytes())
+	if rerr := report(w, info, err); rerr != nil && !errors.Is(rerr, errReported) {
+		fmt.Fprintln(stderr, "updater:", rerr)
+	}
+	return exitStatus(status)
+}
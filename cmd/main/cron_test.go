@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/msmania/updater/selfupdate"
+)
+
+func Test_runCron(t *testing.T) {
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "cron.log")
+	report := func(w io.Writer, info *selfupdate.UpdateInfo, err error) error {
+		return reportUpdate(w, nil, info, err, false)
+	}
+	var logw io.Writer
+	verify := func(f *cronFlags, decision selfupdate.Decision, wantStatus int, wantOut string) {
+		t.Helper()
+		var out, stderr bytes.Buffer
+		err := runCron(&out, &stderr, f, filepath.Join(dir, "state"), logw, func(ctx context.Context) (*selfupdate.UpdateInfo, error) {
+			log.Printf("checking")
+			info := &selfupdate.UpdateInfo{Current: "v1.0.0", Remote: "v1.1.0", Decision: decision}
+			switch decision {
+			case selfupdate.DecisionFailed:
+				if f.maxRuntime > 0 {
+					<-ctx.Done()
+					return info, ctx.Err()
+				}
+				return info, errors.New("boom")
+			}
+			return info, nil
+		}, report)
+		var status exitStatus
+		errors.As(err, &status)
+		if err != nil && status == 0 {
+			t.Fatal(err)
+		}
+		if int(status) != wantStatus {
+			t.Errorf("%s: exit status %d, want %d", decision, status, wantStatus)
+		}
+		got := out.String() + stderr.String()
+		if (wantOut == "") != (got == "") || !strings.Contains(got, wantOut) {
+			t.Errorf("%s: output %q, want %q", decision, got, wantOut)
+		}
+	}
+	verify(&cronFlags{}, selfupdate.DecisionUpToDate, 0, "")
+	verify(&cronFlags{}, selfupdate.DecisionDeferred, 0, "")
+	verify(&cronFlags{}, selfupdate.DecisionUpgraded, cronUpdated, "checking")
+	verify(&cronFlags{}, selfupdate.DecisionUpgraded, cronUpdated, "upgraded: current v1.0.0, release v1.1.0")
+	verify(&cronFlags{}, selfupdate.DecisionFailed, cronFailed, "updater: boom")
+	verify(&cronFlags{maxRuntime: time.Millisecond}, selfupdate.DecisionFailed, cronTimedOut, "-max-runtime 1ms")
+
+	lf, err := openLogFile(logFile, 0, 0, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lf.Close()
+	logw = lf
+	verify(&cronFlags{}, selfupdate.DecisionUpToDate, 0, "")
+	release, err := selfupdate.TryLock(filepath.Join(dir, "state", cronLockFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify(&cronFlags{}, selfupdate.DecisionUpgraded, cronLocked, "")
+	release()
+	logs, _ := os.ReadFile(logFile)
+	if strings.Count(string(logs), "checking") != 1 || !strings.Contains(string(logs), "Another run holds") {
+		t.Errorf("unexpected log file:\n%s", logs)
+	}
+
+	if err := runCron(io.Discard, io.Discard, &cronFlags{}, "", nil, nil, report); err == nil {
+		t.Error("-cron without -state-dir must fail")
+	}
+}
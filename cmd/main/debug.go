@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"expvar"
+	"log"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"sync"
+
+	"github.com/msmania/updater/selfupdate"
+)
+
+// Importing net/http/pprof and expvar registers their handlers on
+// http.DefaultServeMux, so the main server must use its own mux to keep
+// them off the public listener.
+
+// publishOnce guards the expvar names, which may only be published once.
+var publishOnce sync.Once
+
+// debugHandler serves the pprof profiles and expvar variables, including
+// the updater's build and last update status, and the diagnostics of
+// Updater.SelfCheck at /debug/selfcheck, answered with 503 if one fails
+// so that it can serve as -canary-url.
+func debugHandler(u *selfupdate.Updater) http.Handler {
+	publishOnce.Do(func() {
+		expvar.Publish("build", expvar.Func(func() any { return u.Build }))
+		expvar.Publish("update", expvar.Func(func() any { return u.Status() }))
+	})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/selfcheck", func(w http.ResponseWriter, r *http.Request) {
+		report := u.SelfCheck(r.Context())
+		w.Header().Set("Content-Type", "application/json")
+		if report.Status == selfupdate.CheckFail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(report)
+	})
+	return mux
+}
+
+// serveDebug starts the debug server on addr in the background.
+func serveDebug(addr string, u *selfupdate.Updater) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	if ta, ok := ln.Addr().(*net.TCPAddr); ok && !ta.IP.IsLoopback() {
+		log.Printf("WARNING: debug endpoints are reachable on %s", ln.Addr())
+	}
+	log.Printf("Serving debug endpoints at %s", ln.Addr())
+	go func() {
+		if err := http.Serve(ln, debugHandler(u)); err != nil {
+			log.Printf("debug server failed: %v", err)
+		}
+	}()
+	return nil
+}
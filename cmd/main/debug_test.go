@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/msmania/updater/selfupdate"
+)
+
+func Test_debugHandler(t *testing.T) {
+	u := &selfupdate.Updater{Build: selfupdate.BuildInfo{Version: "v1.2.3"}, APIURL: "http://127.0.0.1:1"}
+	rec := httptest.NewRecorder()
+	debugHandler(u).ServeHTTP(rec, httptest.NewRequest("GET", "/debug/vars", nil))
+	var vars struct {
+		Build selfupdate.BuildInfo `json:"build"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &vars); err != nil || vars.Build.Version != "v1.2.3" {
+		t.Error("unexpected /debug/vars:", rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	debugHandler(u).ServeHTTP(rec, httptest.NewRequest("GET", "/debug/pprof/", nil))
+	if rec.Code != 200 {
+		t.Error("pprof index not served:", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	debugHandler(u).ServeHTTP(rec, httptest.NewRequest("GET", "/debug/selfcheck", nil))
+	var report selfupdate.SelfCheckReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil || report.Version != "v1.2.3" || len(report.Checks) == 0 ||
+		(rec.Code == 503) != (report.Status == selfupdate.CheckFail) {
+		t.Error("unexpected /debug/selfcheck:", rec.Code, rec.Body.String())
+	}
+
+	for _, path := range []string{"/debug/vars", "/debug/pprof/", "/debug/selfcheck"} {
+		rec = httptest.NewRecorder()
+		newServeMux(u, adminACL{}).ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code == 200 && rec.Body.String() != "Hello, World!\n" {
+			t.Error(path + " must not be served on the public mux")
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Reasons recorded in lastExitFile.
+const (
+	exitUpgrade  = "upgrade-installed" // restarting into a new version
+	exitRollback = "rolled-back"       // restarting into an earlier version
+	exitFatal    = "fatal-error"
+	exitSignal   = "signal" // SIGINT or SIGTERM
+)
+
+// lastExitFile in -state-dir describes why the server last exited, for
+// supervisors telling a restart for an upgrade from a crash. It is
+// removed at startup, so a missing file after an exit means that the
+// process died without recording one, e.g. of a panic or SIGKILL.
+const lastExitFile = "last-exit.json"
+
+// exitRecord is the content of lastExitFile and of the final log line.
+type exitRecord struct {
+	Reason        string    `json:"reason"`
+	Version       string    `json:"version"`
+	Target        string    `json:"target,omitempty"` // version restarted into, if known
+	Signal        string    `json:"signal,omitempty"`
+	Error         string    `json:"error,omitempty"`
+	ExitCode      int       `json:"exit_code,omitempty"`
+	PID           int       `json:"pid"`
+	StartedAt     time.Time `json:"started_at"`
+	ExitedAt      time.Time `json:"exited_at"`
+	UptimeSeconds int64     `json:"uptime_seconds"`
+}
+
+// exitRecorder writes exitRecords for the server.
+type exitRecorder struct {
+	stateDir, version string
+}
+
+// start logs the record of the previous exit and removes it.
+func (e exitRecorder) start() {
+	if e.stateDir == "" {
+		return
+	}
+	path := filepath.Join(e.stateDir, lastExitFile)
+	if data, err := os.ReadFile(path); err == nil {
+		log.Printf("previous exit: %s", data)
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.Printf("cannot remove %s: %v", path, err)
+	}
+}
+
+// record completes rec, logs it as the final line and writes it to
+// lastExitFile.
+func (e exitRecorder) record(rec exitRecord) {
+	now := time.Now()
+	rec.Version, rec.PID = e.version, os.Getpid()
+	rec.StartedAt, rec.ExitedAt = processStart.UTC(), now.UTC()
+	rec.UptimeSeconds = int64(now.Sub(processStart).Seconds())
+	data, _ := json.Marshal(rec)
+	log.Printf("exit: %s", data)
+	if e.stateDir == "" {
+		return
+	}
+	path := filepath.Join(e.stateDir, lastExitFile)
+	tmp := path + "." + strconv.Itoa(rec.PID)
+	err := os.MkdirAll(e.stateDir, 0o755)
+	if err == nil {
+		err = os.WriteFile(tmp, append(data, '\n'), 0o644)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		log.Printf("cannot record the exit reason: %v", err)
+	}
+}
+
+// exit records rec and exits with rec.ExitCode.
+func (e exitRecorder) exit(rec exitRecord) {
+	e.record(rec)
+	os.Exit(rec.ExitCode)
+}
+
+// fatalf is log.Fatalf recording an exitFatal.
+func (e exitRecorder) fatalf(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	log.Print(msg)
+	e.exit(exitRecord{Reason: exitFatal, Error: msg, ExitCode: 1})
+}
+
+// onTerminate records SIGINT and SIGTERM before dying of them as without
+// a handler, so the supervisor sees the same exit status. cleanup runs
+// in between.
+func (e exitRecorder) onTerminate(cleanup func()) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-ch
+		e.record(exitRecord{Reason: exitSignal, Signal: sig.String()})
+		cleanup()
+		signal.Reset(os.Interrupt, syscall.SIGTERM)
+		if p, err := os.FindProcess(os.Getpid()); err == nil && p.Signal(sig) == nil {
+			time.Sleep(time.Second)
+		}
+		// Signals cannot be raised on Windows.
+		os.Exit(1)
+	}()
+}
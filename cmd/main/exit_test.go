@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_exitRecorder(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+	dir := filepath.Join(t.TempDir(), "state")
+	e := exitRecorder{stateDir: dir, version: "v1.2.3"}
+
+	e.start()
+	e.record(exitRecord{Reason: exitUpgrade, Target: "v1.3.0", ExitCode: 1})
+	data, err := os.ReadFile(filepath.Join(dir, lastExitFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rec exitRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Reason != exitUpgrade || rec.Version != "v1.2.3" || rec.Target != "v1.3.0" || rec.ExitCode != 1 ||
+		rec.PID != os.Getpid() || rec.ExitedAt.Before(rec.StartedAt) {
+		t.Errorf("unexpected record %s", data)
+	}
+	if !strings.Contains(logs.String(), "exit: "+strings.TrimSpace(string(data))) {
+		t.Errorf("final log line missing:\n%s", logs.String())
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("expected only %s, got %v", lastExitFile, entries)
+	}
+
+	logs.Reset()
+	e.start()
+	if !strings.Contains(logs.String(), `previous exit: {"reason":"upgrade-installed"`) {
+		t.Errorf("previous exit not logged:\n%s", logs.String())
+	}
+	if _, err := os.Stat(filepath.Join(dir, lastExitFile)); !os.IsNotExist(err) {
+		t.Errorf("%s should be removed at startup: %v", lastExitFile, err)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/msmania/updater/fleet"
+)
+
+// serveFleet runs the fleet server on addr with its state in statePath.
+func serveFleet(addr, statePath, tokenPath string) error {
+	var token string
+	if tokenPath != "" {
+		data, err := os.ReadFile(tokenPath)
+		if err != nil {
+			return err
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	srv, err := fleet.NewServer(fleet.FileStore{Path: statePath}, token)
+	if err != nil {
+		return err
+	}
+	ln, err := listen(addr, 0o660)
+	if err != nil {
+		return err
+	}
+	if token == "" {
+		log.Printf("No -admin-token-file: the admin API is disabled")
+	}
+	log.Printf("Serving fleet server at %s", ln.Addr())
+	return http.Serve(ln, logRequests(recoverPanics(srv.Handler()), false))
+}
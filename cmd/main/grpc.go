@@ -0,0 +1,47 @@
+package main
+
+import (
+	"log"
+	"net"
+	"net/http"
+
+	"github.com/msmania/updater/selfupdate"
+)
+
+// grpcShared is the -grpc-listen value serving gRPC on the -listen socket.
+const grpcShared = "shared"
+
+// newServer returns a server for h that also accepts unencrypted HTTP/2,
+// which gRPC clients speak when TLS is terminated elsewhere.
+func newServer(h http.Handler) *http.Server {
+	var p http.Protocols
+	p.SetHTTP1(true)
+	p.SetUnencryptedHTTP2(true)
+	return &http.Server{Handler: h, Protocols: &p}
+}
+
+// withGRPC sends gRPC requests to grpc and all others to next.
+func withGRPC(grpc, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if selfupdate.IsGRPC(r) {
+			grpc.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// serveGRPC starts the gRPC server on addr in the background.
+func serveGRPC(addr string, h http.Handler) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	log.Printf("Serving gRPC at %s", ln.Addr())
+	go func() {
+		if err := newServer(h).Serve(ln); err != nil {
+			log.Printf("gRPC server failed: %v", err)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/msmania/updater/selfupdate"
+)
+
+func Test_withGRPC(t *testing.T) {
+	u := &selfupdate.Updater{Build: buildInfo()}
+	srv := httptest.NewUnstartedServer(withGRPC(u.GRPCHandler(), newServeMux(u, adminACL{})))
+	srv.Config = newServer(srv.Config.Handler)
+	srv.Start()
+	defer srv.Close()
+
+	var h2c http.Protocols
+	h2c.SetUnencryptedHTTP2(true)
+	for _, proto := range []string{"HTTP/1.1", "HTTP/2.0"} {
+		client := srv.Client()
+		if proto == "HTTP/2.0" {
+			client = &http.Client{Transport: &http.Transport{Protocols: &h2c}}
+		}
+		resp, err := client.Get(srv.URL + "/version")
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.Proto != proto || strings.TrimSpace(string(body)) != buildInfo().Version {
+			t.Errorf("%s: unexpected response %s %q", proto, resp.Proto, body)
+		}
+	}
+
+	client := &http.Client{Transport: &http.Transport{Protocols: &h2c}}
+	req, _ := http.NewRequest("POST", srv.URL+"/"+selfupdate.GRPCService+"/GetStatus", strings.NewReader("\x00\x00\x00\x00\x00"))
+	req.Header.Set("Content-Type", "application/grpc")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "application/grpc+proto" || resp.Trailer.Get("Grpc-Status") != "0" {
+		t.Errorf("gRPC request not routed: %v %v", resp.Header, resp.Trailer)
+	}
+}
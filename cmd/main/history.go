@@ -0,0 +1,35 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/msmania/updater/selfupdate"
+)
+
+// printHistory writes the update history kept in stateDir, newest first.
+func printHistory(w io.Writer, stateDir string, asJSON bool) error {
+	history := (&selfupdate.Updater{StateDir: stateDir}).History()
+	if asJSON {
+		if history == nil {
+			history = []selfupdate.HistoryEntry{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(history)
+	}
+	if len(history) == 0 {
+		fmt.Fprintln(w, "No updates recorded.")
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tFROM\tTO\tRESULT\tTRIGGER\tDURATION\tERROR")
+	for _, e := range history {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", e.Time.Local().Format(time.DateTime),
+			e.From, e.To, e.Decision, e.Trigger, e.Durations.Total.Round(time.Millisecond), e.Error)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/msmania/updater/selfupdate"
+)
+
+func Test_printHistory(t *testing.T) {
+	dir := t.TempDir()
+	var b strings.Builder
+	if err := printHistory(&b, dir, false); err != nil || b.String() != "No updates recorded.\n" {
+		t.Errorf("unexpected empty history %q: %v", b.String(), err)
+	}
+
+	entries := []selfupdate.HistoryEntry{{
+		Time: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), From: "v1.6.1", To: "v1.6.2",
+		Decision: selfupdate.DecisionUpgraded, Trigger: "startup",
+		Durations: selfupdate.Durations{Total: 1500 * time.Millisecond},
+	}}
+	data, _ := json.Marshal(entries)
+	os.WriteFile(filepath.Join(dir, "history.json"), data, 0o644)
+
+	b.Reset()
+	if err := printHistory(&b, dir, false); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "TIME") ||
+		!strings.Contains(lines[1], "v1.6.1  v1.6.2  upgraded  startup  1.5s") {
+		t.Errorf("unexpected output:\n%s", b.String())
+	}
+
+	b.Reset()
+	var got []selfupdate.HistoryEntry
+	if err := printHistory(&b, dir, true); err != nil || json.Unmarshal([]byte(b.String()), &got) != nil ||
+		len(got) != 1 || got[0].To != "v1.6.2" || got[0].Durations.Total != 1500*time.Millisecond {
+		t.Errorf("unexpected JSON output %s: %v", b.String(), err)
+	}
+}
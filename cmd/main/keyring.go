@@ -0,0 +1,104 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/msmania/updater/selfupdate"
+)
+
+// trustedKeyring is the base64 keyring document built into the binary
+// with -ldflags "-X main.trustedKeyring=...", see "keyring sign". It is
+// the starting point of -keyring; without it the -keyring file is
+// trusted as is.
+var trustedKeyring string
+
+// loadKeyring returns the keyring pinned at path, starting from
+// trustedKeyring.
+func loadKeyring(path string) (*selfupdate.Keyring, error) {
+	embedded, err := base64.StdEncoding.DecodeString(trustedKeyring)
+	if err != nil {
+		return nil, fmt.Errorf("built-in keyring: %w", err)
+	}
+	if len(embedded) == 0 {
+		if embedded, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("no built-in keyring: %w", err)
+		}
+	}
+	return selfupdate.NewKeyring(embedded, path)
+}
+
+// keyringSign writes to w a keyring document of version listing keys,
+// given as "PUBKEY" or "PUBKEY@EXPIRY" (RFC 3339), and signed with the
+// private keys in the signer files.
+func keyringSign(w io.Writer, version int64, signerFiles []string, keys []string) error {
+	if len(keys) == 0 {
+		return errors.New("usage: keyring sign -version N -key KEYFILE PUBKEY[@EXPIRY]...")
+	}
+	var list []selfupdate.TrustedKey
+	for _, arg := range keys {
+		key, expiry, _ := strings.Cut(arg, "@")
+		pub, err := selfupdate.ParseEd25519PublicKey([]byte(key))
+		if err != nil {
+			return fmt.Errorf("%s: %w", arg, err)
+		}
+		tk := selfupdate.TrustedKey{PublicKey: pub}
+		if expiry != "" {
+			if tk.NotAfter, err = time.Parse(time.RFC3339, expiry); err != nil {
+				return fmt.Errorf("%s: %w", arg, err)
+			}
+		}
+		list = append(list, tk)
+	}
+	signers, err := readPrivateKeys(signerFiles)
+	if err != nil {
+		return err
+	}
+	doc, err := selfupdate.SignKeyring(version, list, signers...)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", doc)
+	return err
+}
+
+// readPrivateKeys reads the Ed25519 private key files written by audit
+// keygen.
+func readPrivateKeys(paths []string) ([]ed25519.PrivateKey, error) {
+	var keys []ed25519.PrivateKey
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key, err := selfupdate.ParseEd25519PrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// readPublicKeys reads Ed25519 public key files.
+func readPublicKeys(paths []string) ([]ed25519.PublicKey, error) {
+	var keys []ed25519.PublicKey
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key, err := selfupdate.ParseEd25519PublicKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
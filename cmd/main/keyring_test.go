@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_keyring(t *testing.T) {
+	dir := t.TempDir()
+	keyPath, pinned := filepath.Join(dir, "release.key"), filepath.Join(dir, "keyring.json")
+	var out strings.Builder
+	if err := auditKeygen(&out, keyPath); err != nil {
+		t.Fatal(err)
+	}
+	pub := strings.TrimSpace(out.String())
+
+	out.Reset()
+	if err := keyringSign(&out, 1, nil, []string{pub}); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(pinned, []byte(out.String()), 0o644)
+	k, err := loadKeyring(pinned)
+	if err != nil || k.Version() != 1 || len(k.Keys()) != 1 {
+		t.Fatalf("unexpected keyring: %v", err)
+	}
+
+	out.Reset()
+	expiry := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	if err := keyringSign(&out, 2, []string{keyPath}, []string{pub + "@" + expiry.Format(time.RFC3339)}); err != nil {
+		t.Fatal(err)
+	}
+	if rotated, err := k.Rotate([]byte(out.String()), time.Now()); !rotated || err != nil {
+		t.Fatal("rotation failed:", err)
+	}
+	if k, err = loadKeyring(pinned); err != nil || k.Version() != 2 || !k.Keys()[0].NotAfter.Equal(expiry) {
+		t.Errorf("rotation not pinned: %v", err)
+	}
+
+	for _, args := range [][]string{nil, {"not-a-key"}, {pub + "@tomorrow"}} {
+		if err := keyringSign(&out, 1, nil, args); err == nil {
+			t.Errorf("expected error for %q", args)
+		}
+	}
+}
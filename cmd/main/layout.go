@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/msmania/updater/selfupdate"
+)
+
+// invokedPath returns the absolute path this executable was started
+// through. Unlike os.Executable it does not resolve symlinks, so that the
+// versions layout repoints the link rather than replacing its target.
+func invokedPath() (string, error) {
+	path := os.Args[0]
+	if !strings.ContainsRune(path, filepath.Separator) && !strings.ContainsRune(path, '/') {
+		var err error
+		if path, err = exec.LookPath(path); err != nil {
+			return "", err
+		}
+	}
+	return filepath.Abs(path)
+}
+
+// printVersions lists the versions installed by vd, marking the one the
+// link at path points at.
+func printVersions(w io.Writer, vd selfupdate.VersionDirs, path string) error {
+	tags, err := vd.Versions()
+	if err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		fmt.Fprintf(w, "No versions installed in %s\n", vd.Root)
+		return nil
+	}
+	current := vd.Current(path)
+	for _, tag := range tags {
+		mark := " "
+		if tag == current {
+			mark = "*"
+		}
+		fmt.Fprintf(w, "%s %s\n", mark, tag)
+	}
+	return nil
+}
+
+// versionsLayout loads the configuration of a versions subcommand, which
+// requires -layout versions.
+func versionsLayout(f *updaterFlags, fs *flag.FlagSet) (selfupdate.VersionDirs, string, error) {
+	cfg, err := f.load(fs)
+	if err != nil {
+		return selfupdate.VersionDirs{}, "", err
+	}
+	vd, ok := cfg.Applier.(selfupdate.VersionDirs)
+	if !ok {
+		return selfupdate.VersionDirs{}, "", fmt.Errorf("requires -layout versions")
+	}
+	return vd, cfg.Path, nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/msmania/updater/selfupdate"
+)
+
+func Test_printVersions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+	dir := t.TempDir()
+	vd := selfupdate.VersionDirs{Root: dir}
+	link := filepath.Join(dir, "updater")
+	var out bytes.Buffer
+	if err := printVersions(&out, vd, link); err != nil || out.String() != "No versions installed in "+dir+"\n" {
+		t.Errorf("unexpected output %q (%v)", out.String(), err)
+	}
+	for _, tag := range []string{"v1.0.0", "v1.1.0"} {
+		staged := filepath.Join(dir, "staged")
+		os.WriteFile(staged, []byte(tag), 0o755)
+		if err := vd.Apply(context.Background(), selfupdate.Artifact{Path: staged, Tag: tag}, link); err != nil {
+			t.Fatal(err)
+		}
+	}
+	vd.Activate("v1.0.0", link)
+	out.Reset()
+	if err := printVersions(&out, vd, link); err != nil || out.String() != "  v1.1.0\n* v1.0.0\n" {
+		t.Errorf("unexpected output %q (%v)", out.String(), err)
+	}
+}
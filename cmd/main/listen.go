@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd socket
+// activation (SD_LISTEN_FDS_START).
+const listenFDsStart = 3
+
+// listen opens the server socket. With systemd socket activation the
+// inherited socket is used and addr is ignored. Otherwise addr is a TCP
+// "host:port" or "unix:/path/to.sock"; a Unix socket is created with
+// the permission bits in mode, replacing a stale socket file.
+func listen(addr string, mode os.FileMode) (net.Listener, error) {
+	if ln, err := activationListener(os.Getenv, os.Getpid()); ln != nil || err != nil {
+		return ln, err
+	}
+	path, ok := strings.CutPrefix(addr, "unix:")
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// activationListener returns the socket passed by systemd, or nil if the
+// process was not socket-activated. The environment variables are cleared
+// so child processes do not inherit them.
+func activationListener(getenv func(string) string, pid int) (net.Listener, error) {
+	if getenv("LISTEN_PID") != strconv.Itoa(pid) {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q", getenv("LISTEN_FDS"))
+	}
+	if n > 1 {
+		return nil, fmt.Errorf("expected 1 activated socket, got %d", n)
+	}
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	f := os.NewFile(listenFDsStart, "systemd-socket")
+	defer f.Close()
+	return net.FileListener(f)
+}
+
+// parseFileMode parses an octal permission string such as "0660".
+func parseFileMode(s string) (os.FileMode, error) {
+	m, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || m > 0o777 {
+		return 0, fmt.Errorf("invalid file mode %q", s)
+	}
+	return os.FileMode(m), nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_listen(t *testing.T) {
+	ln, err := listen("127.0.0.1:0", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.Close()
+
+	path := filepath.Join(t.TempDir(), "updater.sock")
+	for range 2 { // the second round replaces a stale socket
+		ln, err = listen("unix:"+path, 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fi, err := os.Stat(path)
+		if err != nil || fi.Mode().Perm() != 0o600 {
+			t.Error("unexpected socket mode", fi.Mode(), err)
+		}
+		// Simulate a crash leaving the socket file behind.
+		os.Link(path, path+".keep")
+		ln.Close()
+		os.Rename(path+".keep", path)
+	}
+}
+
+func Test_activationListener(t *testing.T) {
+	env := func(vars map[string]string) func(string) string {
+		return func(k string) string { return vars[k] }
+	}
+	if ln, err := activationListener(env(nil), 42); ln != nil || err != nil {
+		t.Error("not activated without LISTEN_PID")
+	}
+	if ln, err := activationListener(env(map[string]string{"LISTEN_PID": "7", "LISTEN_FDS": "1"}), 42); ln != nil || err != nil {
+		t.Error("LISTEN_PID of another process must be ignored")
+	}
+	if _, err := activationListener(env(map[string]string{"LISTEN_PID": "42", "LISTEN_FDS": "2"}), 42); err == nil {
+		t.Error("expected error for multiple sockets")
+	}
+}
+
+func Test_parseFileMode(t *testing.T) {
+	if m, err := parseFileMode("0660"); err != nil || m != 0o660 {
+		t.Error("unexpected mode", m, err)
+	}
+	for _, s := range []string{"", "rw", "0999", "01777"} {
+		if _, err := parseFileMode(s); err == nil {
+			t.Error("expected error for " + s)
+		}
+	}
+}
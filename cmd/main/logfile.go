@@ -0,0 +1,234 @@
+package main
+
+import (
+	"compress/gzip"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// logFlags write the log to a file rotated by the updater itself, for
+// hosts without journald.
+type logFlags struct {
+	path     string
+	maxSize  int // MiB
+	maxAge   time.Duration
+	keep     int
+	compress bool
+}
+
+func addLogFlags(fs *flag.FlagSet) *logFlags {
+	f := &logFlags{}
+	fs.StringVar(&f.path, "log-file", "",
+		"Append the log to this file instead of stderr; send SIGUSR2 to reopen it after logrotate moved it")
+	fs.IntVar(&f.maxSize, "log-max-size", 100, "Rotate -log-file before it grows past this many MiB (0 disables)")
+	fs.DurationVar(&f.maxAge, "log-max-age", 0, "Rotate -log-file once it has been written to for this long, e.g. 24h (0 disables)")
+	fs.IntVar(&f.keep, "log-keep", 7, "Number of rotated log files kept (0 keeps all)")
+	fs.BoolVar(&f.compress, "log-compress", true, "Gzip rotated log files")
+	return f
+}
+
+// open opens -log-file, or returns nil if it is not set.
+func (f *logFlags) open() (*logFile, error) {
+	if f.path == "" {
+		return nil, nil
+	}
+	if f.maxSize < 0 || f.maxAge < 0 || f.keep < 0 {
+		return nil, errors.New("-log-max-size, -log-max-age and -log-keep must not be negative")
+	}
+	return openLogFile(f.path, int64(f.maxSize)<<20, f.maxAge, f.keep, f.compress)
+}
+
+// logFile appends to a log file, rotating it before it grows past
+// maxSize or once it has been open for maxAge. A rotated file is renamed
+// after the time of rotation, e.g. "updater.log.20260102-150405", and
+// gzipped if compress is set; only the keep newest are kept.
+type logFile struct {
+	path     string
+	maxSize  int64
+	maxAge   time.Duration
+	keep     int
+	compress bool
+	now      func() time.Time
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+	wg     sync.WaitGroup // compression and pruning of rotated files
+}
+
+func openLogFile(path string, maxSize int64, maxAge time.Duration, keep int, compress bool) (*logFile, error) {
+	l := &logFile{path: path, maxSize: maxSize, maxAge: maxAge, keep: keep, compress: compress, now: time.Now}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// open opens l.path for appending. l.mu must be held.
+func (l *logFile) open() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.file, l.size, l.opened = f, st.Size(), l.now()
+	return nil
+}
+
+func (l *logFile) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return 0, os.ErrClosed
+	}
+	if l.size > 0 && (l.maxSize > 0 && l.size+int64(len(p)) > l.maxSize ||
+		l.maxAge > 0 && l.now().Sub(l.opened) >= l.maxAge) {
+		if err := l.rotate(); err != nil {
+			fmt.Fprintf(os.Stderr, "cannot rotate %s: %v\n", l.path, err)
+			if l.file == nil {
+				return 0, err
+			}
+		}
+	}
+	n, err := l.file.Write(p)
+	l.size += int64(n)
+	return n, err
+}
+
+// rotate moves the file aside and starts a new one. l.mu must be held.
+func (l *logFile) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	l.file = nil
+	stamp := l.path + "." + l.now().Format("20060102-150405")
+	name := stamp
+	for i := 1; exists(name) || exists(name+".gz"); i++ {
+		name = fmt.Sprintf("%s.%d", stamp, i)
+	}
+	renameErr := os.Rename(l.path, name)
+	if err := l.open(); err != nil {
+		return err
+	}
+	if renameErr != nil {
+		return renameErr
+	}
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		if l.compress {
+			if err := gzipFile(name); err != nil {
+				fmt.Fprintf(os.Stderr, "cannot compress %s: %v\n", name, err)
+			}
+		}
+		l.prune()
+	}()
+	return nil
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// gzipFile replaces name with name.gz.
+func gzipFile(name string) (err error) {
+	in, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(out.Name())
+		}
+	}()
+	zw := gzip.NewWriter(out)
+	if _, err := io.Copy(zw, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	in.Close()
+	return os.Remove(name)
+}
+
+// prune removes all but the l.keep newest rotated files.
+func (l *logFile) prune() {
+	if l.keep == 0 {
+		return
+	}
+	dir, base := filepath.Split(l.path)
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	files := map[string][]string{} // by rotation, with and without .gz
+	for _, e := range entries {
+		if name := e.Name(); strings.HasPrefix(name, base+".") {
+			key := strings.TrimSuffix(name, ".gz")
+			files[key] = append(files[key], name)
+		}
+	}
+	keys := make([]string, 0, len(files))
+	for k := range files {
+		keys = append(keys, k)
+	}
+	// Rotated names sort by time, and a ".N" suffix after the name without.
+	sort.Strings(keys)
+	for _, k := range keys[:max(len(keys)-l.keep, 0)] {
+		for _, name := range files[k] {
+			os.Remove(filepath.Join(dir, name))
+		}
+	}
+}
+
+// Reopen reopens the file, for logrotate to send SIGUSR2 once it moved
+// the file away.
+func (l *logFile) Reopen() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file != nil {
+		l.file.Close()
+	}
+	return l.open()
+}
+
+// Close closes the file once rotated files are compressed.
+func (l *logFile) Close() error {
+	l.wg.Wait()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_logFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "updater.log")
+	os.WriteFile(path, []byte("old\n"), 0o644)
+	l, err := openLogFile(path, 10, time.Hour, 2, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	l.now = func() time.Time { return now }
+	l.opened = now
+	files := func() []string {
+		t.Helper()
+		l.wg.Wait()
+		entries, _ := os.ReadDir(dir)
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		sort.Strings(names)
+		return names
+	}
+	verify := func(name, want string) {
+		t.Helper()
+		var r io.Reader
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		r = f
+		if strings.HasSuffix(name, ".gz") {
+			if r, err = gzip.NewReader(f); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got, _ := io.ReadAll(r); string(got) != want {
+			t.Errorf("%s holds %q, want %q", name, got, want)
+		}
+	}
+
+	io.WriteString(l, "12345\n") // fits in 10 bytes with "old\n"
+	io.WriteString(l, "abc\n")
+	if got := strings.Join(files(), " "); got != "updater.log updater.log.20260102-150405.gz" {
+		t.Fatalf("after a rotation by size: %s", got)
+	}
+	verify("updater.log.20260102-150405.gz", "old\n12345\n")
+	verify("updater.log", "abc\n")
+
+	io.WriteString(l, "0123456789\n") // too long for any file: rotate, then write
+	now = now.Add(2 * time.Hour)
+	io.WriteString(l, "aged\n")
+	want := "updater.log updater.log.20260102-150405.1.gz updater.log.20260102-170405.gz"
+	if got := strings.Join(files(), " "); got != want {
+		t.Fatalf("after rotations by size and age: %s, want %s", got, want)
+	}
+	verify("updater.log.20260102-170405.gz", "0123456789\n")
+
+	os.Rename(path, path+".1") // as logrotate does
+	if err := l.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(l, "new\n")
+	verify("updater.log", "new\n")
+	verify("updater.log.1", "aged\n")
+	if err := l.Close(); err != nil {
+		t.Error(err)
+	}
+	if _, err := io.WriteString(l, "closed\n"); err == nil {
+		t.Error("write after Close must fail")
+	}
+
+	if _, err := (&logFlags{path: path, keep: -1}).open(); err == nil {
+		t.Error("negative -log-keep must fail")
+	}
+}
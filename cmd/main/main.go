@@ -1,268 +1,1406 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"mime"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
-	"path/filepath"
-	"runtime"
-	"strconv"
+	"regexp"
 	"strings"
-)
-
-// version is set at build time via -ldflags "-X main.version=..."
-var version = "dev"
+	"sync"
+	"time"
 
-type (
-	PreReleaseType int
-	Prerelease     struct {
-		t       PreReleaseType
-		version int
-	}
-	versionStruct struct {
-		Original string
-		Parsed   bool
-		Numbers  [3]int
-		Pre      *Prerelease
-	}
+	"github.com/msmania/updater/selfupdate"
 )
 
-const (
-	PrereleaseAlpha PreReleaseType = iota
-	PrereleaseBeta
-	PrereleaseRC
+// version, commit and buildDate are set at build time via
+// -ldflags "-X main.version=... -X main.commit=... -X main.buildDate=...".
+// Without them, buildInfo falls back to the module and VCS information
+// embedded by the Go toolchain.
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildDate = "unknown"
 )
 
-var prereleaseTypeMap = map[string]PreReleaseType{
-	"alpha": PrereleaseAlpha,
-	"beta":  PrereleaseBeta,
-	"rc":    PrereleaseRC,
+// buildInfo returns the metadata of the running binary.
+func buildInfo() selfupdate.BuildInfo {
+	return selfupdate.NewBuildInfo(version, commit, buildDate)
 }
 
-func (v Prerelease) Compare(other Prerelease) int {
-	if v.t != other.t {
-		return int(v.t) - int(other.t)
+// ---------------------------------------------------------------------
+// HTTP handlers
+// ---------------------------------------------------------------------
+func helloHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, "Hello, World!")
+}
+
+// processStart approximates when this process started.
+var processStart = time.Now()
+
+// versionInfo is the JSON body of /version: the BuildInfo of the Updater
+// and the state of the running process.
+type versionInfo struct {
+	selfupdate.BuildInfo
+	Channel       selfupdate.Channel `json:"channel"`
+	StartedAt     time.Time          `json:"started_at"`
+	UptimeSeconds int64              `json:"uptime_seconds"`
+}
+
+// versionHandler answers with the version as plain text, or with a
+// versionInfo if the Accept header asks for JSON. A format query
+// parameter of "text" or "json" overrides the header for clients that
+// cannot set it.
+func versionHandler(u *selfupdate.Updater) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		asJSON := acceptsJSON(r)
+		switch r.URL.Query().Get("format") {
+		case "":
+		case "json":
+			asJSON = true
+		case "text":
+			asJSON = false
+		default:
+			writeAPIError(w, http.StatusBadRequest)
+			return
+		}
+		if !asJSON {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			fmt.Fprintln(w, u.Build.Version)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(versionInfo{
+			BuildInfo:     u.Build,
+			Channel:       u.Status().Channel,
+			StartedAt:     processStart.UTC(),
+			UptimeSeconds: int64(time.Since(processStart).Seconds()),
+		})
 	}
-	return v.version - other.version
 }
 
-func parsePreRelease(v string) *Prerelease {
-	for prefix, t := range prereleaseTypeMap {
-		v, found := strings.CutPrefix(v, prefix)
-		if found {
-			n, err := strconv.Atoi(v)
-			if err != nil {
-				return nil
-			}
-			return &Prerelease{
-				t:       t,
-				version: n,
-			}
+// acceptsJSON reports whether the Accept header asks for JSON.
+func acceptsJSON(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err == nil && mediaType == "application/json" {
+			return true
 		}
 	}
-	return nil
+	return false
 }
 
-func ParseVersion(v string) versionStruct {
-	vs := versionStruct{
-		Parsed:   false,
-		Original: v,
+// newRootCommand builds the CLI command tree. Without a subcommand the
+// binary behaves as before: check for an upgrade, then serve HTTP.
+func newRootCommand() *command {
+	root := newCommand("updater", "Self-updating HTTP server")
+	root.Long = "Checks GitHub for a newer release, replaces itself if one is found " +
+		"and exits so the supervisor restarts it; otherwise serves HTTP on the -listen " +
+		"address, or on the socket passed by systemd socket activation. -update-policy " +
+		"notify only prints a notice about a newer release, manual leaves installs to the " +
+		"update command and the admin endpoints, and scheduled installs inside " +
+		"-maintenance-window only. SIGHUP reloads " +
+		"the -config file and checks for a release right away; SIGUSR1 logs the updater state and SIGUSR2 " +
+		"reopens -log-file. " +
+		"With -watch-interval, changes to the -config file and the files it names are reloaded " +
+		"without a SIGHUP."
+	showVersion := root.Flags.Bool("version", false, "Print version and exit")
+	skipUpgrade := root.Flags.Bool("skip-upgrade", false, "Do not check for newer releases")
+	notifyInterval := root.Flags.Duration("notify-interval", selfupdate.DefaultNotifyInterval,
+		"How often the notify policy checks for a release; the result is cached in -state-dir")
+	listenAddr := root.Flags.String("listen", ":8080",
+		"Serve on this TCP host:port or unix:/path/to.sock")
+	socketMode := root.Flags.String("socket-mode", "0660", "Permissions of a unix: listen socket")
+	trustProxy := root.Flags.Bool("trust-proxy", false,
+		"Log the client address from X-Forwarded-For (only behind a reverse proxy)")
+	corsOrigins := root.Flags.String("cors-origins", "",
+		`Comma-separated origins whose browser pages may call the API, or "*" for any`)
+	corsMethods := root.Flags.String("cors-methods", "GET,POST", "Comma-separated methods allowed to -cors-origins")
+	corsHeaders := root.Flags.String("cors-headers", "Content-Type",
+		"Comma-separated request headers allowed to -cors-origins")
+	adminCIDRs := root.Flags.String("admin-allow-cidr", "",
+		"Comma-separated networks, e.g. 10.0.0.0/8,127.0.0.1/32, allowed to use the update API, "+
+			"the dashboard and the gRPC API; empty allows all (see -trust-proxy)")
+	var limits rateLimitConfig
+	root.Flags.Float64Var(&limits.Global.Rate, "rate-limit", 0,
+		"Requests per second served to all clients together; excess requests get 429 (0 disables)")
+	root.Flags.IntVar(&limits.Global.Burst, "rate-limit-burst", 0,
+		"Requests above -rate-limit allowed in a burst (0 means one second's worth)")
+	root.Flags.Float64Var(&limits.PerClient.Rate, "client-rate-limit", 0,
+		"Requests per second served to each client address (0 disables; see -trust-proxy)")
+	root.Flags.IntVar(&limits.PerClient.Burst, "client-rate-limit-burst", 0,
+		"Requests above -client-rate-limit allowed in a burst (0 means one second's worth)")
+	secHeaders := root.Flags.Bool("security-headers", true,
+		"Send Content-Security-Policy, X-Frame-Options and related hardening headers")
+	enableDebug := root.Flags.Bool("enable-debug", false,
+		"Serve /debug/pprof and /debug/vars on the -debug-listen address")
+	debugListen := root.Flags.String("debug-listen", "localhost:6060",
+		"TCP host:port of the debug endpoints")
+	beaconListen := root.Flags.String("beacon-listen", "",
+		"Answer TCP connections and UDP datagrams on this host:port with the version and a newline, "+
+			"for monitors that only check ports (empty disables)")
+	watchInterval := root.Flags.Duration("watch-interval", 0,
+		"Watch the -config file and the files it names, such as the -keyring, and reload them once they "+
+			"have not changed for this long, as on SIGHUP but without an update check (0 disables)")
+	grpcListen := root.Flags.String("grpc-listen", "",
+		`Serve the gRPC control API on this TCP host:port, or "shared" to serve it on the -listen socket`)
+	rootLog := addLogFlags(root.Flags)
+	rootFlags := addUpdaterFlags(root.Flags)
+	root.Run = func(c *command, args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("unknown command %q", args[0])
+		}
+		if *showVersion {
+			fmt.Println("updater " + buildInfo().String())
+			return nil
+		}
+		cfg, err := rootFlags.load(c.Flags)
+		if err != nil {
+			return fmt.Errorf("invalid configuration: %w (see \"updater config validate\")", err)
+		}
+		if cfg.LogFile, err = rootLog.open(); err != nil {
+			return err
+		}
+		if cfg.LogFile != nil {
+			log.SetOutput(cfg.LogFile)
+		}
+		cfg.SkipUpgrade = *skipUpgrade
+		cfg.NotifyInterval = *notifyInterval
+		cfg.Listen = *listenAddr
+		cfg.TrustProxy = *trustProxy
+		cfg.CORS = corsConfig{
+			Origins: splitList(*corsOrigins),
+			Methods: splitList(*corsMethods),
+			Headers: splitList(*corsHeaders),
+		}
+		cfg.SecurityHeaders = *secHeaders
+		if limits.Global.Rate < 0 || limits.PerClient.Rate < 0 {
+			return fmt.Errorf("rate limits must not be negative")
+		}
+		cfg.RateLimit = limits
+		cfg.RateLimit.TrustProxy = *trustProxy
+		cfg.Admin.TrustProxy = *trustProxy
+		if cfg.Admin.Networks, err = parseCIDRs(*adminCIDRs); err != nil {
+			return err
+		}
+		cfg.GRPCListen = *grpcListen
+		cfg.BeaconListen = *beaconListen
+		if *watchInterval < 0 {
+			return fmt.Errorf("invalid -watch-interval %s", *watchInterval)
+		}
+		cfg.WatchInterval = *watchInterval
+		if *enableDebug {
+			cfg.DebugListen = *debugListen
+		}
+		if cfg.SocketMode, err = parseFileMode(*socketMode); err != nil {
+			return err
+		}
+		serve(cfg, rootFlags.reload, rootFlags.watchedFiles)
+		return nil
+	}
+
+	check := newCommand("check", "Check for a newer release")
+	check.Long = "Reports whether a newer eligible release exists without downloading it."
+	checkFlags := addUpdaterFlags(check.Flags)
+	checkJSON := check.Flags.Bool("json", false, "Print the result as JSON")
+	checkPush := addPushFlags(check.Flags)
+	check.Run = func(c *command, args []string) error {
+		cfg, err := checkFlags.load(c.Flags)
+		if err != nil {
+			return err
+		}
+		u, flush := newUpdater(cfg)
+		defer flush()
+		pushers, err := checkPush.pushers(cfg, u.Build)
+		if err != nil {
+			return err
+		}
+		info, err := u.Check(context.Background())
+		pushRun(pushers, "check", info, err)
+		return reportUpdate(os.Stdout, u.Messages, info, err, *checkJSON)
 	}
 
-	v, found := strings.CutPrefix(v, "v")
-	if !found {
-		return vs
+	update := newCommand("update", "Install a newer release and exit")
+	update.Long = "Downloads and installs the newest eligible release over this " +
+		"executable, without starting the server.\n\n" +
+		"With -sandbox, the download, verification and extraction run confined by " +
+		"Landlock and seccomp (Linux): the process may only write below -work-dir " +
+		"and -state-dir and cannot execute programs. The release is staged, not " +
+		"installed; the server activates it at its next start or on SIGHUP. " +
+		"The sandbox needs a binary built with CGO_ENABLED=0.\n\n" +
+		"With -cron, the run is safe to schedule every few minutes: it prints nothing " +
+		"unless a release was installed or the update failed, holds a lock in -state-dir " +
+		"so that runs do not overlap, and gives up after -max-runtime. The exit status is " +
+		"0 if there was nothing to do, 10 if a release was installed or staged, 11 if the " +
+		"update failed, 12 if another run held the lock and 13 if -max-runtime ran out."
+	updateFlags := addUpdaterFlags(update.Flags)
+	updateJSON := update.Flags.Bool("json", false, "Print the result as JSON")
+	updateSandbox := update.Flags.Bool("sandbox", false,
+		"Stage the release in a sandbox that may only write below -work-dir and -state-dir (Linux)")
+	updatePush := addPushFlags(update.Flags)
+	updateCron := addCronFlags(update.Flags)
+	updateLog := addLogFlags(update.Flags)
+	update.Run = func(c *command, args []string) error {
+		cfg, err := updateFlags.load(c.Flags)
+		if err != nil {
+			return err
+		}
+		u, flush := newUpdater(cfg)
+		defer flush()
+		pushers, err := updatePush.pushers(cfg, u.Build)
+		if err != nil {
+			return err
+		}
+		logFile, err := updateLog.open()
+		if err != nil {
+			return err
+		}
+		var logw io.Writer
+		if logFile != nil {
+			defer logFile.Close()
+			logw = logFile
+		}
+		run := func(ctx context.Context, source string) (*selfupdate.UpdateInfo, error) {
+			if *updateSandbox {
+				if err := enterSandbox(u, cfg); err != nil {
+					return nil, err
+				}
+			}
+			info, err := u.Update(selfupdate.WithAuditSource(ctx, source))
+			pushRun(pushers, "update", info, err)
+			return info, err
+		}
+		report := func(w io.Writer, info *selfupdate.UpdateInfo, err error) error {
+			return reportUpdate(w, u.Messages, info, err, *updateJSON)
+		}
+		if updateCron.enabled {
+			return runCron(os.Stdout, os.Stderr, updateCron, cfg.StateDir, logw,
+				func(ctx context.Context) (*selfupdate.UpdateInfo, error) { return run(ctx, "cron") }, report)
+		}
+		if logw != nil {
+			log.SetOutput(logw)
+		}
+		info, err := run(context.Background(), "cli")
+		if info == nil {
+			return err
+		}
+		return report(os.Stdout, info, err)
 	}
 
-	parts := strings.SplitN(v, "-", 2)
-	if len(parts) == 2 {
-		pre := parsePreRelease(parts[1])
-		if pre == nil {
-			return vs
+	history := newCommand("history", "Show the update history")
+	history.Long = "Lists the installs, rollbacks and failed updates recorded in " +
+		"-state-dir, newest first."
+	historyStateDir := history.Flags.String("state-dir", selfupdate.DefaultStateDir("updater"),
+		"State directory of the server")
+	historyJSON := history.Flags.Bool("json", false, "Print the history as JSON")
+	history.Run = func(c *command, args []string) error {
+		if *historyStateDir == "" {
+			return fmt.Errorf("-state-dir is required")
 		}
-		vs.Pre = pre
+		return printHistory(os.Stdout, *historyStateDir, *historyJSON)
 	}
 
-	core := strings.SplitN(parts[0], ".", 3)
-	for i, num := range core {
-		n, err := strconv.Atoi(num)
+	versionsList := newCommand("list", "List the installed versions")
+	versionsList.Long = "Lists the versions installed in -versions-dir by the versions layout, " +
+		"newest first; the active one is marked with *."
+	versionsListFlags := addUpdaterFlags(versionsList.Flags)
+	versionsList.Run = func(c *command, args []string) error {
+		vd, path, err := versionsLayout(versionsListFlags, c.Flags)
 		if err != nil {
-			return vs
+			return err
 		}
-		vs.Numbers[i] = n
+		return printVersions(os.Stdout, vd, path)
 	}
+	versionsUse := newCommand("use", "Switch to an installed version")
+	versionsUse.Long = "Repoints the symlink this executable was started through at an installed " +
+		"version, without downloading it; restart the server to run it."
+	versionsUse.Usage = "<tag>"
+	versionsUseFlags := addUpdaterFlags(versionsUse.Flags)
+	versionsUse.Run = func(c *command, args []string) error {
+		if len(args) != 1 {
+			c.printUsage(os.Stderr)
+			return errUsage
+		}
+		vd, path, err := versionsLayout(versionsUseFlags, c.Flags)
+		if err != nil {
+			return err
+		}
+		if err := vd.Activate(args[0], path); err != nil {
+			return err
+		}
+		fmt.Printf("%s now runs %s\n", path, args[0])
+		return nil
+	}
+	versions := newCommand("versions", "Manage the versions layout").add(versionsList, versionsUse)
 
-	vs.Parsed = true
-	return vs
-}
+	validate := newCommand("validate", "Validate the configuration")
+	validate.Long = "Applies the environment and the -config file like the server does, " +
+		"prints the effective configuration as a config file, and checks that the " +
+		"release source is reachable and its newest release has the assets to install."
+	validateFlags := addUpdaterFlags(validate.Flags)
+	validateOffline := validate.Flags.Bool("offline", false, "Skip checking the release source")
+	validate.Run = func(c *command, args []string) error {
+		cfg, err := validateFlags.load(c.Flags)
+		if err != nil {
+			return fmt.Errorf("invalid configuration: %w", err)
+		}
+		if err := printEffectiveConfig(os.Stdout, c.Flags); err != nil {
+			return err
+		}
+		if *validateOffline {
+			return nil
+		}
+		u, flush := newUpdater(cfg)
+		defer flush()
+		return checkReleaseSource(context.Background(), os.Stderr, u)
+	}
+	configCmd := newCommand("config", "Work with the configuration").add(validate)
+
+	semaphore := newCommand("semaphore", "Run a rollout semaphore for a fleet")
+	semaphore.Long = "Serves a semaphore that replicas started with -coordinator-url " +
+		"ask for a slot before installing an update, so that at most -limit of them " +
+		"restart at the same time."
+	semListen := semaphore.Flags.String("listen", ":9090", "Serve on this TCP host:port or unix:/path/to.sock")
+	semLimit := semaphore.Flags.Int("limit", 1, "Number of replicas that may update at once")
+	semaphore.Run = func(c *command, args []string) error {
+		if *semLimit < 1 {
+			return fmt.Errorf("invalid -limit %d", *semLimit)
+		}
+		ln, err := listen(*semListen, 0o660)
+		if err != nil {
+			return err
+		}
+		log.Printf("Serving rollout semaphore (limit %d) at %s", *semLimit, ln.Addr())
+		return http.Serve(ln, logRequests(selfupdate.NewSemaphore(*semLimit), false))
+	}
+
+	fleetServer := newCommand("server", "Run the fleet update-control server")
+	fleetServer.Long = "Serves rollout policies to agents started with -fleet-url and " +
+		"records the version each agent reports. Operators manage channels, rollout " +
+		"percentages and the kill switch through the admin API under /v1/admin/."
+	fleetListen := fleetServer.Flags.String("listen", ":8090", "Serve on this TCP host:port or unix:/path/to.sock")
+	fleetState := fleetServer.Flags.String("state", "fleet.json", "File storing policies and agent check-ins")
+	fleetToken := fleetServer.Flags.String("admin-token-file", "",
+		"File holding the bearer token of the admin API (disabled if empty)")
+	fleetServer.Run = func(c *command, args []string) error {
+		return serveFleet(*fleetListen, *fleetState, *fleetToken)
+	}
 
-func (v versionStruct) Compare(other versionStruct) (int, error) {
-	if !v.Parsed || !other.Parsed {
-		return 0, errors.New("versionStruct not parsed")
+	remoteCheckCmd := newCommand("check", "Check many nodes for a newer release")
+	remoteCheckCmd.Long = "Asks the nodes listed in -hosts, -parallel at a time, to check for a " +
+		"newer release through their update API, and prints a summary of the results."
+	remoteCheckFlags := addRemoteFlags(remoteCheckCmd.Flags)
+	remoteCheckCmd.Run = func(c *command, args []string) error {
+		return runRemote(os.Stdout, remoteCheckFlags, remoteCheck)
 	}
-	for i := range 3 {
-		if v.Numbers[i] > other.Numbers[i] {
-			return 1, nil
-		} else if v.Numbers[i] < other.Numbers[i] {
-			return -1, nil
+	remoteUpdateCmd := newCommand("update", "Update many nodes")
+	remoteUpdateCmd.Long = "Triggers an update on the nodes listed in -hosts, -parallel at a " +
+		"time, through their update API, and prints the resulting status of each. " +
+		"Nodes that installed a release restart afterwards."
+	remoteUpdateFlags := addRemoteFlags(remoteUpdateCmd.Flags)
+	remoteUpdateCmd.Run = func(c *command, args []string) error {
+		return runRemote(os.Stdout, remoteUpdateFlags, remoteUpdate)
+	}
+	remote := newCommand("remote", "Run commands on many nodes").add(remoteCheckCmd, remoteUpdateCmd)
+
+	verifyCmd := newCommand("verify", "Check an installed binary against its release")
+	verifyCmd.Long = "Downloads the asset of release -version, verifies it with the " +
+		"configured checksums, signatures and transparency log as update would, and " +
+		"reports whether -file is the binary it contains. The exit status is 1 if " +
+		"it is not, for incident response and drift detection."
+	verifyFlags := addUpdaterFlags(verifyCmd.Flags)
+	verifyFile := verifyCmd.Flags.String("file", "", "Installed binary to check (default this executable)")
+	verifyVersion := verifyCmd.Flags.String("version", "", "Release tag the binary should be, e.g. v1.6.2 (default the running version)")
+	verifyJSON := verifyCmd.Flags.Bool("json", false, "Print the result as JSON")
+	verifyCmd.Run = func(c *command, args []string) error {
+		cfg, err := verifyFlags.load(c.Flags)
+		if err != nil {
+			return err
 		}
+		u, flush := newUpdater(cfg)
+		defer flush()
+		path, tag := *verifyFile, *verifyVersion
+		if path == "" {
+			if path, err = os.Executable(); err != nil {
+				return err
+			}
+		}
+		if tag == "" {
+			tag = u.Build.Version
+		}
+		check, err := u.VerifyInstalled(context.Background(), path, tag)
+		return reportInstallCheck(os.Stdout, path, check, err, *verifyJSON)
 	}
-	if v.Pre == nil && other.Pre == nil {
-		return 0, nil
+
+	mirrorSync := newCommand("sync", "Copy releases into a mirror directory")
+	mirrorSync.Long = "Downloads every asset of the given releases, or of the newest " +
+		"eligible one, including checksum files and signatures, into -dest as " +
+		"<dest>/<tag>/<asset>, the layout -mirrors expects of a mirror. Assets " +
+		"already present with the size and digest reported by GitHub are kept, " +
+		"so the command can be run repeatedly to keep a mirror warm."
+	mirrorSync.Usage = "[TAG...]"
+	mirrorSyncFlags := addUpdaterFlags(mirrorSync.Flags)
+	mirrorDest := mirrorSync.Flags.String("dest", "", "Mirror directory to fill, e.g. /srv/mirror")
+	mirrorSync.Run = func(c *command, args []string) error {
+		if *mirrorDest == "" {
+			c.printUsage(os.Stderr)
+			return errUsage
+		}
+		cfg, err := mirrorSyncFlags.load(c.Flags)
+		if err != nil {
+			return err
+		}
+		u, flush := newUpdater(cfg)
+		defer flush()
+		syncs, err := u.SyncMirror(context.Background(), *mirrorDest, args...)
+		printMirrorSync(os.Stdout, syncs)
+		return err
 	}
-	if v.Pre == nil {
-		return 1, nil
+	mirror := newCommand("mirror", "Maintain a release mirror").add(mirrorSync)
+
+	verify := newCommand("verify", "Verify an audit log")
+	verify.Long = "Checks the hash chain and signatures of an -audit-log file."
+	verify.Usage = "FILE"
+	verifyKey := verify.Flags.String("pubkey", "", "Ed25519 public key file printed by audit keygen")
+	verify.Run = func(c *command, args []string) error {
+		if len(args) != 1 || *verifyKey == "" {
+			c.printUsage(os.Stderr)
+			return errUsage
+		}
+		return verifyAudit(os.Stdout, args[0], *verifyKey)
 	}
-	if other.Pre == nil {
-		return -1, nil
+	keygen := newCommand("keygen", "Generate an audit signing key")
+	keygen.Long = "Writes a new Ed25519 private key to -out and prints its public key."
+	keygenOut := keygen.Flags.String("out", "audit.key", "Private key file to create")
+	keygen.Run = func(c *command, args []string) error {
+		return auditKeygen(os.Stdout, *keygenOut)
 	}
-	return v.Pre.Compare(*other.Pre), nil
-}
+	audit := newCommand("audit", "Manage the audit log").add(verify, keygen)
 
-// ---------------------------------------------------------------------
-// GitHub release information structures
-// ---------------------------------------------------------------------
-type ghRelease struct {
-	TagName string `json:"tag_name"`
-	Assets  []struct {
-		Name               string `json:"name"`
-		BrowserDownloadURL string `json:"browser_download_url"`
-	} `json:"assets"`
-}
+	sign := newCommand("sign", "Sign a list of trusted release keys")
+	sign.Long = "Prints a keyring document listing the given Ed25519 public keys, " +
+		"optionally with an RFC 3339 expiry, signed by the -key private keys. " +
+		"Attach it to a release as keyring.json, signed by a key the running " +
+		"binaries trust, to rotate the keys accepted by -keyring; the version " +
+		"must be higher than that of the current list. A document signed by " +
+		"no key can be built in with -ldflags \"-X main.trustedKeyring=<base64>\"."
+	sign.Usage = "PUBKEY[@EXPIRY]..."
+	signVersion := sign.Flags.Int64("version", 1, "Version of the key list")
+	signKeys := sign.Flags.String("key", "", "Comma-separated private key files to sign with (see audit keygen)")
+	sign.Run = func(c *command, args []string) error {
+		return keyringSign(os.Stdout, *signVersion, splitList(*signKeys), args)
+	}
+	keyring := newCommand("keyring", "Manage trusted release keys").add(sign)
 
-// getLatestRelease queries the GitHub API for the most recent release.
-func getLatestRelease(owner, repo, assetName string) (string, string, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/releases/latest", owner, repo)
-	resp, err := http.Get(url)
-	if err != nil {
-		return "", "", err
+	manifestSignCmd := newCommand("sign", "Sign a checksum manifest")
+	manifestSignCmd.Long = "Signs FILE, the release asset named by -checksum-asset, with the " +
+		"-key private keys (see audit keygen) and appends the signatures to FILE" +
+		selfupdate.DefaultManifestSignatureSuffix + ", the asset -manifest-keys checks. " +
+		"Signatures already listed are kept, so the signers of a k-of-n manifest can " +
+		"each sign in turn; attach both files to the release."
+	manifestSignCmd.Usage = "FILE"
+	manifestSignKeys := manifestSignCmd.Flags.String("key", "", "Comma-separated private key files to sign with")
+	manifestSignSigs := manifestSignCmd.Flags.String("sigs", "",
+		"Signature file to append to (default FILE"+selfupdate.DefaultManifestSignatureSuffix+")")
+	manifestSignCmd.Run = func(c *command, args []string) error {
+		if len(args) != 1 {
+			c.printUsage(os.Stderr)
+			return errUsage
+		}
+		return manifestSign(os.Stdout, splitList(*manifestSignKeys), args[0], *manifestSignSigs)
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return "", "", fmt.Errorf("github API returned %d", resp.StatusCode)
+	manifestVerifyCmd := newCommand("verify", "Verify the signatures of a checksum manifest")
+	manifestVerifyCmd.Long = "Checks that the signature file of FILE holds signatures by " +
+		"-threshold of the -keys public keys, as -manifest-keys and -manifest-threshold would."
+	manifestVerifyCmd.Usage = "FILE"
+	manifestVerifyKeys := manifestVerifyCmd.Flags.String("keys", "", "Comma-separated Ed25519 public key files of the signers")
+	manifestVerifyThreshold := manifestVerifyCmd.Flags.Int("threshold", 0, "Number of -keys that must have signed (0 requires all)")
+	manifestVerifySigs := manifestVerifyCmd.Flags.String("sigs", "",
+		"Signature file (default FILE"+selfupdate.DefaultManifestSignatureSuffix+")")
+	manifestVerifyCmd.Run = func(c *command, args []string) error {
+		if len(args) != 1 {
+			c.printUsage(os.Stderr)
+			return errUsage
+		}
+		return manifestVerify(os.Stdout, splitList(*manifestVerifyKeys), *manifestVerifyThreshold, args[0], *manifestVerifySigs)
 	}
-	var rel ghRelease
-	if err := json.NewDecoder(resp.Body).Decode(&rel); err != nil {
-		return "", "", err
+	manifest := newCommand("manifest", "Sign and verify checksum manifests").add(manifestSignCmd, manifestVerifyCmd)
+
+	completion := newCommand("completion", "Generate shell completion scripts")
+	completion.Long = "Prints a completion script for the given shell to standard output."
+	completion.Usage = "bash|zsh|fish|powershell"
+	completion.Args = completionShells
+	completion.Run = func(c *command, args []string) error {
+		shell, err := c.oneOf(args)
+		if err != nil {
+			return err
+		}
+		return writeCompletion(os.Stdout, c.root(), shell)
 	}
-	for _, a := range rel.Assets {
-		if a.Name == assetName {
-			return rel.TagName, a.BrowserDownloadURL, nil
+
+	man := newCommand("man", "Generate man pages")
+	man.Long = "Prints the top-level man page to standard output, or writes one " +
+		"page per command when -dir is given."
+	manDir := man.Flags.String("dir", "", "Write one page per command into this directory")
+	man.Run = func(c *command, args []string) error {
+		if *manDir != "" {
+			return writeManPages(*manDir, c.root(), manDate())
 		}
+		return writeManPage(os.Stdout, c.root(), manDate())
 	}
-	return rel.TagName, "", fmt.Errorf("asset %s not found in release %s", assetName, rel.TagName)
+	docs := newCommand("docs", "Generate documentation").add(man)
+
+	return root.add(check, update, history, versions, configCmd, verifyCmd, mirror, remote, fleetServer, semaphore,
+		audit, keyring, manifest, completion, docs)
 }
 
-// downloadFile streams a URL to dst and makes it executable.
-func downloadFile(url, dst string) error {
-	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o755)
-	if err != nil {
-		return err
+// updaterFlags holds the flags configuring the Updater, shared by the
+// server, check and update commands.
+type updaterFlags struct {
+	cfg           config
+	configPath    string
+	fs            *flag.FlagSet
+	cmdline       map[string]string // flags given on the command line or in the environment; see reload
+	running       map[string]string // flag values in effect; see diffFlags
+	mu            sync.Mutex        // serializes reloads
+	channel       string
+	constraint    string
+	mirrors       string
+	fallbacks     string
+	assetRE       string
+	mode          string
+	install       string
+	installHelper string
+	pkgCommand    string
+	msi           struct{ product, logDir string }
+	layout        string
+	versionsDir   string
+	keepVersions  int
+	audit         struct{ log, key string }
+	sbom          struct{ licenses, packages, vulns string }
+	rekorKey      string
+	keyring       string
+	policy        string
+	windows       string
+	goInstallRun  bool
+	manifestKeys  string
+	services      string
+	k8s           struct{ deployment, container, image string }
+	messages      string
+	releaseRule   string
+	socks5        string
+	ipv4, ipv6    bool
+	resolver      string
+}
+
+// addUpdaterFlags defines the Updater flags on fs.
+func addUpdaterFlags(fs *flag.FlagSet) *updaterFlags {
+	f := &updaterFlags{}
+	fs.StringVar(&f.configPath, "config", "",
+		"Read settings from this JSON file; keys are flag names, command-line flags take precedence")
+	fs.StringVar(&f.cfg.ChecksumAsset, "checksum-asset", "",
+		"Verify downloads against this sha256sum-format release asset (e.g. checksums.txt)")
+	fs.StringVar(&f.manifestKeys, "manifest-keys", "",
+		"Comma-separated Ed25519 public key files of the -checksum-asset signers, whose signatures are listed in <asset>"+
+			selfupdate.DefaultManifestSignatureSuffix)
+	fs.IntVar(&f.cfg.ManifestSigners.Threshold, "manifest-threshold", 0,
+		"Number of -manifest-keys that must have signed the -checksum-asset (0 requires all)")
+	fs.IntVar(&f.cfg.Connections, "download-connections", 1,
+		"Download the release over this many parallel ranged connections")
+	fs.Int64Var(&f.cfg.SegmentSize, "download-segment-size", selfupdate.DefaultSegmentSize,
+		"Segment size in bytes for parallel downloads")
+	fs.Int64Var(&f.cfg.MemoryDownloadLimit, "memory-download-limit", 0,
+		"Buffer assets of at most this many bytes in memory and write them only once verified (0 disables)")
+	fs.BoolVar(&f.cfg.AllowMajorUpgrade, "allow-major-upgrade", false,
+		"Install releases with a higher major version (otherwise reported in /api/v1/update/status)")
+	fs.StringVar(&f.cfg.Canary.URL, "canary-url", "",
+		"After an upgrade, roll back unless the new version answers this URL, e.g. http://localhost:8080/readyz, "+
+			"within -canary-timeout (requires -state-dir)")
+	fs.DurationVar(&f.cfg.Canary.Timeout, "canary-timeout", selfupdate.DefaultCanaryTimeout,
+		"How long a new version has to answer -canary-url")
+	fs.DurationVar(&f.cfg.Integrity.Interval, "integrity-interval", 0,
+		"Check this often that the executable is still the binary installed, warning and recording a drift "+
+			"in -audit-log if not, e.g. 1h (requires -state-dir; 0 disables)")
+	fs.BoolVar(&f.cfg.Integrity.Reinstall, "integrity-reinstall", false,
+		"Reinstall the recorded release over an executable -integrity-interval found modified")
+	fs.StringVar(&f.services, "restart-services", "",
+		`Comma-separated systemd units running the same executable, restarted one at a time after an upgrade, `+
+			`each with an optional readiness URL to wait for, e.g. "worker@1=http://localhost:8081/readyz,worker@2"`)
+	fs.DurationVar(&f.cfg.Services.Timeout, "restart-services-timeout", selfupdate.DefaultCanaryTimeout,
+		"How long each of -restart-services has to become ready before the remaining ones are left alone")
+	fs.DurationVar(&f.cfg.MinReleaseAge, "min-release-age", 0,
+		"Install releases only once they were published this long ago, e.g. 24h (reported as pending in the status)")
+	fs.StringVar(&f.releaseRule, "release-rule", "",
+		"Decide about each release with the CEL expression in this file, which evaluates to a bool or to "+
+			"allow(), deny(reason) or delay(reason); see selfupdate.ReleaseRule")
+	fs.StringVar(&f.policy, "update-policy", string(selfupdate.PolicyAuto),
+		"When releases are installed without being asked for: auto (at startup and on SIGHUP), "+
+			"notify (never; print a notice instead), manual (never) or scheduled (inside -maintenance-window only). "+
+			"The update command and the admin endpoints always install")
+	fs.StringVar(&f.windows, "maintenance-window", "",
+		`Semicolon-separated local-time windows for the scheduled policy, e.g. "Mon-Fri 02:00-04:00; Sat,Sun 22:00-06:00"`)
+	fs.BoolVar(&f.cfg.AllowPackaged, "allow-packaged", false,
+		"Replace this executable even if a package manager (dpkg, rpm, Homebrew, ...) installed it")
+	fs.BoolVar(&f.cfg.UsePackageManager, "upgrade-with-package-manager", false,
+		"Run the upgrade command of the package manager (brew, scoop, winget or choco) that installed this executable "+
+			"instead of leaving it alone")
+	fs.StringVar(&f.channel, "channel", string(selfupdate.ChannelStable),
+		"Release channel to follow: stable, rc, beta or alpha")
+	fs.StringVar(&f.constraint, "constraint", "",
+		`Only install versions matching this expression (e.g. ">=1.4.0, <2.0.0")`)
+	fs.StringVar(&f.fallbacks, "asset-fallbacks", "",
+		`Comma-separated asset name templates installed when a release lacks the usual asset, e.g. "{{.Repo}}-{{.OS}}-{{.Arch}}-musl"`)
+	fs.StringVar(&f.assetRE, "asset-regexp", "",
+		`Install the one release asset whose whole name matches this regular expression, e.g. "updater_.*_linux_amd64\.tar\.gz"`)
+	fs.StringVar(&f.cfg.DNS.Name, "dns-source", "",
+		`Discover releases from the TXT records of this domain instead of GitHub, e.g. "_updater.example.com"; `+
+			`see selfupdate.DNSSource`)
+	fs.StringVar(&f.cfg.DNS.DNSSECResolver, "dns-resolver", "",
+		"Query -dns-source at this DNSSEC-validating resolver host:port over TCP and require authenticated answers")
+	fs.StringVar(&f.mirrors, "mirrors", "",
+		"Comma-separated base URLs serving <tag>/<asset>, tried in order before GitHub (\"github\" places it explicitly; "+
+			"\"torrent\" downloads through the <asset>.torrent release asset)")
+	fs.DurationVar(&f.cfg.Transport.DialTimeout, "dial-timeout", 10*time.Second,
+		"Timeout for establishing connections to GitHub")
+	fs.DurationVar(&f.cfg.Transport.TLSHandshakeTimeout, "tls-handshake-timeout", 10*time.Second,
+		"Timeout for TLS handshakes with GitHub")
+	fs.StringVar(&f.socks5, "socks5", "",
+		"Send all requests of the updater through this SOCKS5 proxy, [user:password@]host:port, e.g. 127.0.0.1:9050 for Tor "+
+			"(host names are resolved by the proxy; set "+envName("socks5")+" to keep the password off the command line)")
+	fs.BoolVar(&f.ipv4, "force-ipv4", false, "Connect over IPv4 only")
+	fs.BoolVar(&f.ipv6, "force-ipv6", false, "Connect over IPv6 only, e.g. on IPv6-only hosts where IPv4 attempts only add delay")
+	fs.StringVar(&f.resolver, "resolver", "",
+		"Resolve host names with this DNS server, host[:port], instead of the system's")
+	fs.StringVar(&f.messages, "messages", "",
+		"Read the texts of log messages and notices from this JSON file of message names to text/templates, "+
+			`e.g. {"release-notice": "{{.Host}}: {{.Release}} ist verfügbar"}`)
+	fs.StringVar(&f.cfg.StateDir, "state-dir", selfupdate.DefaultStateDir("updater"),
+		"Keep update state, such as the last status, history and the binary for a rollback, in this directory (empty disables)")
+	fs.StringVar(&f.cfg.Overlay.Dir, "overlay-dir", "",
+		"Install updates into this writable directory when the executable is on a read-only file system, "+
+			"and re-execute from there")
+	fs.StringVar(&f.cfg.Overlay.DropIn, "overlay-dropin", "",
+		"systemd drop-in to write so the unit starts the -overlay-dir executable, "+
+			"e.g. /etc/systemd/system/updater.service.d/overlay.conf")
+	fs.StringVar(&f.cfg.Overlay.Profile, "overlay-profile", "",
+		"Shell profile script to write putting -overlay-dir on PATH, e.g. /etc/profile.d/updater-overlay.sh")
+	fs.StringVar(&f.cfg.WorkDir, "work-dir", selfupdate.DefaultCacheDir("updater"),
+		"Download and stage releases in this directory (empty: next to the executable if writable, else a cache directory); "+
+			"on another file system than the executable, a release is copied next to it before the rename; "+
+			"also accepted as -staging-dir")
+	fs.StringVar(&f.installHelper, "install-helper", "",
+		`Install through this command, given the staged file, the target and its SHA-256, for a read-only executable directory, e.g. "sudo -n /usr/local/libexec/updater-apply"`)
+	fs.IntVar(&f.cfg.CrashLoop.Threshold, "crash-loop-threshold", 3,
+		"Suspend updates and roll back after this many consecutive early exits (0 disables; needs -state-dir)")
+	fs.DurationVar(&f.cfg.CrashLoop.StableAfter, "crash-loop-stable-after", time.Minute,
+		"Runs shorter than this count as early exits")
+	fs.DurationVar(&f.cfg.CrashLoop.Backoff, "crash-loop-backoff", time.Hour,
+		"How long updates stay suspended after a crash loop")
+	fs.StringVar(&f.mode, "mode", "binary",
+		"How to apply updates: binary (replace this executable), package (install the .deb or .rpm asset "+
+			"selected by -asset-regexp with the package manager), msi (install the .msi asset selected by "+
+			"-asset-regexp with msiexec, on Windows), k8s (roll out the pod's Deployment) "+
+			"or go (print the go install command upgrading a binary installed with go install)")
+	fs.StringVar(&f.pkgCommand, "package-command", "",
+		`Install command in package mode, given the package file, e.g. "sudo -n apt-get install -y" (default: dpkg -i or rpm -U)`)
+	fs.StringVar(&f.msi.product, "msi-product", "",
+		"In msi mode, the product code or display name whose installed version, read from the registry, is the current version")
+	fs.StringVar(&f.msi.logDir, "msi-log-dir", "",
+		"In msi mode, keep the msiexec log of each install in this directory (default: where the package is downloaded)")
+	fs.BoolVar(&f.goInstallRun, "go-install-run", false,
+		"In go mode, run go install instead of printing the command")
+	fs.StringVar(&f.layout, "layout", "file",
+		"Install layout in binary mode: file (replace the executable) or versions (keep each release in "+
+			"-versions-dir/versions/<tag>/ and repoint the symlink this executable was started through)")
+	fs.StringVar(&f.versionsDir, "versions-dir", selfupdate.DefaultDataDir("updater"),
+		"Directory holding the installed versions of the versions layout")
+	fs.IntVar(&f.keepVersions, "keep-versions", 3, "Installed versions to keep in the versions layout (0 keeps all)")
+	fs.StringVar(&f.install, "install-mode", "immediate",
+		"When to install a verified release in binary mode: immediate, or staged until the next restart or SIGHUP")
+	fs.StringVar(&f.k8s.deployment, "k8s-deployment", "",
+		"Deployment to roll out in k8s mode (default: derived from the pod name)")
+	fs.StringVar(&f.k8s.container, "k8s-container", "",
+		"Container whose image -k8s-image sets in k8s mode")
+	fs.StringVar(&f.k8s.image, "k8s-image", "",
+		`Image of a release in k8s mode, e.g. "ghcr.io/msmania/updater:{{.Tag}}"`)
+	fs.BoolVar(&f.cfg.LeaderElection, "leader-election", false,
+		"Update only if no other process sharing the executable does; others observe")
+	fs.StringVar(&f.cfg.SBOMAsset, "sbom-asset", "",
+		"Require this SPDX or CycloneDX JSON release asset to parse before installing")
+	fs.StringVar(&f.sbom.licenses, "sbom-deny-licenses", "",
+		`Comma-separated license IDs that block a release, e.g. "GPL-3.0*,AGPL-3.0*"`)
+	fs.StringVar(&f.sbom.packages, "sbom-deny-packages", "",
+		"Comma-separated package names (or name@version) that block a release")
+	fs.StringVar(&f.sbom.vulns, "sbom-deny-vulns", "",
+		"Comma-separated vulnerability IDs that block a release if the SBOM lists them")
+	fs.StringVar(&f.cfg.SignatureSuffix, "signature-suffix", ".sig",
+		"Suffix of the release asset holding the Ed25519 signature of an asset, checked with -keyring")
+	fs.StringVar(&f.keyring, "keyring", "",
+		"Require a signature by a key of this keyring, which releases can rotate (see keyring sign)")
+	fs.StringVar(&f.cfg.Transparency.URL, "rekor-url", "",
+		"Require each installed asset to be logged in this Rekor transparency log (e.g. "+selfupdate.DefaultRekorURL+")")
+	fs.StringVar(&f.rekorKey, "rekor-key", "",
+		"PEM public key file of the -rekor-url log, as served at /api/v1/log/publicKey")
+	fs.StringVar(&f.cfg.Transparency.EntrySuffix, "rekor-entry-suffix", selfupdate.DefaultRekorEntrySuffix,
+		"Suffix of the release asset holding the log entry UUID of an asset")
+	fs.StringVar(&f.cfg.Advisories.URL, "advisory-url", "",
+		"OSV-style query endpoint (e.g. https://api.osv.dev/v1/query) that can veto a release")
+	fs.StringVar(&f.cfg.Advisories.Package, "advisory-package", "",
+		"Package name queried at -advisory-url (default: github.com/msmania/updater)")
+	fs.StringVar(&f.cfg.Advisories.Ecosystem, "advisory-ecosystem", "Go",
+		"Ecosystem queried at -advisory-url")
+	fs.BoolVar(&f.cfg.Advisories.FailOpen, "advisory-fail-open", false,
+		"Install when -advisory-url cannot be reached instead of failing the update")
+	fs.StringVar(&f.cfg.Fleet.URL, "fleet-url", "",
+		"Check in with this fleet server (see the server command) before every update check")
+	fs.StringVar(&f.cfg.Fleet.AgentID, "fleet-agent-id", "",
+		"Agent ID reported to -fleet-url (default: the host name)")
+	fs.StringVar(&f.cfg.Reports.URL, "report-url", "",
+		"POST this instance's host name, version, platform, last update result and uptime here periodically (e.g. <fleet server>/v1/report)")
+	fs.DurationVar(&f.cfg.Reports.Interval, "report-interval", selfupdate.DefaultReportInterval,
+		"Time between -report-url reports")
+	fs.StringVar(&f.cfg.TelemetryURL, "telemetry-url", "",
+		"Opt in to posting anonymous update statistics to this collector URL after each install or failure: "+
+			"version pair, platform, channel, duration and error class, without host names or error messages")
+	fs.StringVar(&f.cfg.CoordinatorURL, "coordinator-url", "",
+		"Install only when this semaphore (see the semaphore command) grants a rollout slot")
+	fs.StringVar(&f.audit.log, "audit-log", "",
+		"Append a signed record of every install, rollback and rollout to this file")
+	fs.StringVar(&f.audit.key, "audit-key", "",
+		"Ed25519 private key file signing -audit-log records (see audit keygen)")
+	fs.StringVar(&f.cfg.OTLPEndpoint, "otlp-endpoint", "",
+		"Export update traces to this OTLP/HTTP collector base URL (e.g. http://localhost:4318)")
+	return f
+}
+
+// load applies the config file, if any, and validates the flag values.
+// It must be called after fs has been parsed.
+func (f *updaterFlags) load(fs *flag.FlagSet) (config, error) {
+	if f.cmdline == nil {
+		f.fs, f.cmdline = fs, map[string]string{}
+		fs.Visit(func(fl *flag.Flag) { f.cmdline[fl.Name] = fl.Value.String() })
 	}
-	defer out.Close()
-	resp, err := http.Get(url)
-	if err != nil {
-		return err
+	if f.configPath != "" {
+		if err := applyConfigFile(fs, f.configPath); err != nil {
+			return config{}, err
+		}
+	}
+	if f.running == nil {
+		f.running = flagValues(fs)
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("download returned %d", resp.StatusCode)
+	cfg := f.cfg
+	var err error
+	if cfg.Policy, err = selfupdate.ParseUpdatePolicy(f.policy); err != nil {
+		return config{}, err
 	}
-	_, err = io.Copy(out, resp.Body)
-	return err
+	for _, s := range strings.Split(f.windows, ";") {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		w, err := selfupdate.ParseMaintenanceWindow(s)
+		if err != nil {
+			return config{}, err
+		}
+		cfg.Windows = append(cfg.Windows, w)
+	}
+	if cfg.Policy == selfupdate.PolicyScheduled && len(cfg.Windows) == 0 {
+		return config{}, fmt.Errorf("the scheduled update policy requires -maintenance-window")
+	}
+	if cfg.Channel, err = selfupdate.ParseChannel(f.channel); err != nil {
+		return config{}, err
+	}
+	if cfg.Constraint, err = selfupdate.ParseConstraint(f.constraint); err != nil {
+		return config{}, err
+	}
+	if f.audit.log != "" {
+		if f.audit.key == "" {
+			return config{}, fmt.Errorf("-audit-log requires -audit-key")
+		}
+		data, err := os.ReadFile(f.audit.key)
+		if err != nil {
+			return config{}, err
+		}
+		key, err := selfupdate.ParseEd25519PrivateKey(data)
+		if err != nil {
+			return config{}, fmt.Errorf("%s: %w", f.audit.key, err)
+		}
+		cfg.Audit = &selfupdate.AuditLog{Path: f.audit.log, Key: key}
+	}
+	if f.messages != "" {
+		data, err := os.ReadFile(f.messages)
+		if err != nil {
+			return config{}, err
+		}
+		var texts map[string]string
+		if err := json.Unmarshal(data, &texts); err != nil {
+			return config{}, fmt.Errorf("%s: %w", f.messages, err)
+		}
+		if cfg.Messages, err = selfupdate.NewMessageCatalog(texts); err != nil {
+			return config{}, fmt.Errorf("%s: %w", f.messages, err)
+		}
+	}
+	if f.releaseRule != "" {
+		data, err := os.ReadFile(f.releaseRule)
+		if err != nil {
+			return config{}, err
+		}
+		if cfg.ReleaseRule, err = selfupdate.ParseReleaseRule(string(data)); err != nil {
+			return config{}, fmt.Errorf("%s: %w", f.releaseRule, err)
+		}
+	}
+	switch f.mode {
+	case "binary":
+	case "package":
+		if f.assetRE == "" {
+			return config{}, fmt.Errorf("package mode requires -asset-regexp selecting the .deb or .rpm asset")
+		}
+		if f.installHelper != "" || f.layout != "file" {
+			return config{}, fmt.Errorf("package mode cannot use -install-helper or -layout")
+		}
+		cfg.Applier = selfupdate.PackageInstall{Command: strings.Fields(f.pkgCommand)}
+	case "msi":
+		if f.assetRE == "" {
+			return config{}, fmt.Errorf("msi mode requires -asset-regexp selecting the .msi asset")
+		}
+		if f.installHelper != "" || f.layout != "file" {
+			return config{}, fmt.Errorf("msi mode cannot use -install-helper or -layout")
+		}
+		if f.msi.product != "" {
+			if cfg.InstalledVersion, err = selfupdate.MSIProductVersion(f.msi.product); err != nil {
+				return config{}, fmt.Errorf("msi mode: %w", err)
+			}
+		}
+		cfg.Applier = selfupdate.MSIInstall{LogDir: f.msi.logDir}
+	case "k8s":
+		rollout, err := selfupdate.InClusterRollout(f.k8s.deployment)
+		if err != nil {
+			return config{}, fmt.Errorf("k8s mode: %w", err)
+		}
+		rollout.Container, rollout.ImageTemplate = f.k8s.container, f.k8s.image
+		cfg.Rollout = rollout
+	case "go":
+		exe, err := os.Executable()
+		if err != nil {
+			return config{}, err
+		}
+		rollout := selfupdate.DetectGoInstall(exe)
+		if rollout == nil {
+			return config{}, fmt.Errorf("go mode: %s was not installed with go install pkg@version", exe)
+		}
+		rollout.Run = f.goInstallRun
+		cfg.Rollout = rollout
+	default:
+		return config{}, fmt.Errorf("unknown mode %q", f.mode)
+	}
+	if f.installHelper != "" {
+		cfg.Applier = selfupdate.CommandApplier{Command: strings.Fields(f.installHelper)}
+	}
+	switch f.layout {
+	case "file":
+	case "versions":
+		if f.installHelper != "" {
+			return config{}, fmt.Errorf("the versions layout cannot use -install-helper")
+		}
+		if f.versionsDir == "" {
+			return config{}, fmt.Errorf("the versions layout requires -versions-dir")
+		}
+		if cfg.Path, err = invokedPath(); err != nil {
+			return config{}, fmt.Errorf("versions layout: %w", err)
+		}
+		cfg.Applier = selfupdate.VersionDirs{Root: f.versionsDir, Keep: f.keepVersions}
+	default:
+		return config{}, fmt.Errorf("unknown layout %q", f.layout)
+	}
+	switch f.install {
+	case "immediate":
+	case "staged":
+		cfg.Staged = true
+	default:
+		return config{}, fmt.Errorf("unknown install mode %q", f.install)
+	}
+	if cfg.Services.Services, err = selfupdate.ParseServices(f.services); err != nil {
+		return config{}, fmt.Errorf("-restart-services: %w", err)
+	}
+	cfg.SBOMPolicy = selfupdate.SBOMPolicy{
+		DenyLicenses:        splitList(f.sbom.licenses),
+		DenyPackages:        splitList(f.sbom.packages),
+		DenyVulnerabilities: splitList(f.sbom.vulns),
+	}
+	if !cfg.SBOMPolicy.IsZero() && cfg.SBOMAsset == "" {
+		return config{}, fmt.Errorf("-sbom-deny-* flags require -sbom-asset")
+	}
+	if cfg.ManifestSigners.Keys, err = readPublicKeys(splitList(f.manifestKeys)); err != nil {
+		return config{}, err
+	}
+	if m := cfg.ManifestSigners; m.Threshold > len(m.Keys) {
+		return config{}, fmt.Errorf("-manifest-threshold %d exceeds the %d -manifest-keys", m.Threshold, len(m.Keys))
+	}
+	if cfg.Integrity.Interval > 0 && cfg.StateDir == "" {
+		return config{}, fmt.Errorf("-integrity-interval requires -state-dir")
+	}
+	if len(cfg.ManifestSigners.Keys) > 0 && cfg.ChecksumAsset == "" {
+		return config{}, fmt.Errorf("-manifest-keys requires -checksum-asset")
+	}
+	if f.keyring != "" {
+		if cfg.Keyring, err = loadKeyring(f.keyring); err != nil {
+			return config{}, fmt.Errorf("%s: %w", f.keyring, err)
+		}
+	}
+	if cfg.Transparency.URL != "" {
+		if f.rekorKey == "" {
+			return config{}, fmt.Errorf("-rekor-url requires -rekor-key")
+		}
+		data, err := os.ReadFile(f.rekorKey)
+		if err != nil {
+			return config{}, err
+		}
+		if cfg.Transparency.PublicKey, err = selfupdate.ParseRekorPublicKey(data); err != nil {
+			return config{}, fmt.Errorf("%s: %w", f.rekorKey, err)
+		}
+	}
+	if f.fallbacks != "" {
+		cfg.AssetFallbacks = splitList(f.fallbacks)
+		if err := selfupdate.WithAssetFallbacks(cfg.AssetFallbacks...)(&selfupdate.Updater{}); err != nil {
+			return config{}, err
+		}
+	}
+	if f.assetRE != "" {
+		u := &selfupdate.Updater{}
+		if err := selfupdate.WithAssetRegexp(f.assetRE)(u); err != nil {
+			return config{}, err
+		}
+		cfg.AssetRegexp = u.AssetRegexp
+	}
+	if f.socks5 != "" {
+		if cfg.Transport.Proxy, err = parseSOCKS5(f.socks5); err != nil {
+			return config{}, err
+		}
+	}
+	switch {
+	case f.ipv4 && f.ipv6:
+		return config{}, fmt.Errorf("-force-ipv4 and -force-ipv6 are mutually exclusive")
+	case f.ipv4:
+		cfg.Transport.Network = "tcp4"
+	case f.ipv6:
+		cfg.Transport.Network = "tcp6"
+	}
+	if f.resolver != "" {
+		cfg.Transport.Resolver = f.resolver
+		if _, _, err := net.SplitHostPort(f.resolver); err != nil {
+			cfg.Transport.Resolver = net.JoinHostPort(strings.Trim(f.resolver, "[]"), "53")
+		}
+	}
+	if cfg.DNS.DNSSECResolver != "" && cfg.DNS.Name == "" {
+		return config{}, fmt.Errorf("-dns-resolver requires -dns-source")
+	}
+	if f.mirrors != "" {
+		cfg.Mirrors = splitList(f.mirrors)
+		if err := selfupdate.WithMirrors(cfg.Mirrors...)(&selfupdate.Updater{}); err != nil {
+			return config{}, err
+		}
+	}
+	return cfg, nil
 }
 
-// replaceSelf atomically swaps the running executable with the new file.
-func replaceSelf(tmpPath string) error {
-	exePath, err := os.Executable()
+// reload reads the config file again, keeping the command-line flags
+// given to load, and lists the changed flags in config.Changes. Only
+// liveFlags apply at run time; other flags of the command, such as
+// -listen, take effect after a restart and are listed as such until then.
+func (f *updaterFlags) reload() (config, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fs := flag.NewFlagSet("reload", flag.ContinueOnError)
+	g := addUpdaterFlags(fs)
+	f.fs.VisitAll(func(fl *flag.Flag) {
+		if fs.Lookup(fl.Name) == nil {
+			cloneFlag(fs, fl)
+		}
+	})
+	for name, value := range f.cmdline {
+		if err := fs.Set(name, value); err != nil {
+			return config{}, err
+		}
+	}
+	g.fs, g.cmdline = fs, f.cmdline
+	cfg, err := g.load(fs)
 	if err != nil {
-		return err
+		return config{}, err
 	}
-	return os.Rename(tmpPath, exePath)
+	cfg.Changes = diffFlags(f.running, g.running)
+	return cfg, nil
 }
 
-// maybeUpgrade checks for a newer GitHub release, downloads it and replaces self.
-func maybeUpgrade(skip bool) (bool, error) {
-	if skip {
-		return false, nil
+// watchedFiles returns the config file and the files named by the flag
+// values in effect, which reload reads again; see configWatcher.
+func (f *updaterFlags) watchedFiles() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var files []string
+	if f.configPath != "" {
+		files = append(files, f.configPath)
 	}
-	// Asset naming convention – adjust if you change the CI naming.
-	assetName := fmt.Sprintf("updater-%s-%s", runtime.GOOS, runtime.GOARCH)
-	owner := "msmania"
-	repo := "updater"
-	remoteTag, assetURL, err := getLatestRelease(owner, repo, assetName)
-	if err != nil {
-		return false, fmt.Errorf("cannot query latest release: %w", err)
+	for _, name := range []string{"keyring", "messages", "release-rule", "rekor-key"} {
+		if path := f.running[name]; path != "" {
+			files = append(files, path)
+		}
+	}
+	return append(files, splitList(f.running["manifest-keys"])...)
+}
+
+// parseSOCKS5 parses the -socks5 proxy, [user:password@]host:port.
+func parseSOCKS5(s string) (*url.URL, error) {
+	u, err := url.Parse("socks5://" + s)
+	if err != nil || u.Port() == "" || u.Path != "" {
+		// s may hold a password.
+		return nil, errors.New("invalid -socks5: want [user:password@]host:port")
 	}
+	return u, nil
+}
 
-	remoteVersion := ParseVersion(remoteTag)
-	localVersion := ParseVersion(version)
-	if cmp, err := remoteVersion.Compare(localVersion); err != nil ||
-		cmp <= 0 || remoteVersion.Pre != nil {
-		log.Printf(
-			"No newer release available (current=%s remote=%s)",
-			version,
-			remoteTag,
-		)
-		return false, nil
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
+	return items
+}
 
-	log.Printf("New version %s available (current=%s). Downloading…", remoteTag, version)
-	exePath, err := os.Executable()
-	if err != nil {
-		return false, err
+// config holds the settings of the default (server) command.
+type config struct {
+	SkipUpgrade         bool
+	NotifyInterval      time.Duration
+	WatchInterval       time.Duration // 0 disables the configWatcher
+	Policy              selfupdate.UpdatePolicy
+	Windows             []selfupdate.MaintenanceWindow
+	Listen              string
+	SocketMode          os.FileMode
+	TrustProxy          bool
+	CORS                corsConfig
+	SecurityHeaders     bool
+	RateLimit           rateLimitConfig
+	Admin               adminACL
+	DebugListen         string // empty disables the debug endpoints
+	GRPCListen          string // empty disables gRPC; see grpcShared
+	BeaconListen        string // empty disables the version beacon
+	LogFile             *logFile
+	ChecksumAsset       string
+	ManifestSigners     selfupdate.ManifestSigners
+	Connections         int
+	SegmentSize         int64
+	MemoryDownloadLimit int64
+	Channel             selfupdate.Channel
+	Constraint          selfupdate.Constraint
+	Mirrors             []string
+	AssetFallbacks      []string
+	AssetRegexp         *regexp.Regexp
+	AllowMajorUpgrade   bool
+	MinReleaseAge       time.Duration
+	ReleaseRule         *selfupdate.ReleaseRule
+	Canary              selfupdate.CanaryConfig
+	Integrity           selfupdate.IntegrityConfig
+	Services            selfupdate.ServiceRestart
+	AllowPackaged       bool
+	UsePackageManager   bool
+	Staged              bool
+	Transport           selfupdate.TransportConfig
+	StateDir            string
+	WorkDir             string
+	Path                string
+	InstalledVersion    string // overrides the build version; see -msi-product
+	Messages            *selfupdate.MessageCatalog
+	Overlay             selfupdate.OverlayConfig
+	Applier             selfupdate.Applier
+	CrashLoop           selfupdate.CrashLoopConfig
+	CoordinatorURL      string
+	SBOMAsset           string
+	SBOMPolicy          selfupdate.SBOMPolicy
+	SignatureSuffix     string
+	Keyring             *selfupdate.Keyring
+	Transparency        selfupdate.TransparencyConfig
+	Advisories          selfupdate.AdvisoryConfig
+	Fleet               selfupdate.FleetConfig
+	DNS                 selfupdate.DNSSource
+	Reports             selfupdate.ReportConfig
+	TelemetryURL        string
+	LeaderElection      bool
+	Audit               *selfupdate.AuditLog
+	Rollout             selfupdate.Rollout
+	OTLPEndpoint        string
+	Changes             configChanges // set by reload
+}
+
+// newUpdater builds the Updater for this binary. flush exports pending
+// traces and must be called before exiting.
+func newUpdater(cfg config) (u *selfupdate.Updater, flush func()) {
+	u = &selfupdate.Updater{
+		Owner:          "msmania",
+		Repo:           "updater",
+		Build:          buildInfo(),
+		Transport:      cfg.Transport,
+		StateDir:       cfg.StateDir,
+		WorkDir:        cfg.WorkDir,
+		Path:           cfg.Path,
+		Applier:        cfg.Applier,
+		CrashLoop:      cfg.CrashLoop,
+		Rollout:        cfg.Rollout,
+		LeaderElection: cfg.LeaderElection,
+		Audit:          cfg.Audit,
+		Reports:        cfg.Reports,
+		TelemetryURL:   cfg.TelemetryURL,
+		Integrity:      cfg.Integrity,
+	}
+	if cfg.InstalledVersion != "" {
+		u.Build.Version = cfg.InstalledVersion
 	}
-	dir := filepath.Dir(exePath)
-	tmpPath := filepath.Join(dir, "updater.new")
-	if err := downloadFile(assetURL, tmpPath); err != nil {
-		return false, fmt.Errorf("download failed: %w", err)
+	applyConfig(u, cfg)
+	if cfg.CoordinatorURL != "" {
+		u.Coordinator = &selfupdate.HTTPSemaphore{URL: cfg.CoordinatorURL}
 	}
-	if err := replaceSelf(tmpPath); err != nil {
-		return false, fmt.Errorf("replace failed: %w", err)
+	if cfg.OTLPEndpoint == "" {
+		return u, func() {}
+	}
+	tracer := selfupdate.NewOTLPTracer(cfg.OTLPEndpoint, "updater", u.Build)
+	u.Tracer = tracer
+	return u, func() {
+		if err := tracer.Flush(context.Background()); err != nil {
+			log.Printf("trace export error: %v", err)
+		}
 	}
-	log.Printf("Upgrade to %s succeeded – exiting for systemd restart.", remoteTag)
-	return true, nil
 }
 
-// ---------------------------------------------------------------------
-// HTTP handlers
-// ---------------------------------------------------------------------
-func helloHandler(w http.ResponseWriter, r *http.Request) {
-	fmt.Fprintln(w, "Hello, World!")
+// reportUpdate prints the outcome of a check or update. Outcomes that are
+// not failures, such as being up to date, are not returned as errors.
+func reportUpdate(w io.Writer, msgs *selfupdate.MessageCatalog, info *selfupdate.UpdateInfo, err error, asJSON bool) error {
+	failed := info.Decision == selfupdate.DecisionFailed
+	if asJSON {
+		out := struct {
+			*selfupdate.UpdateInfo
+			Error string `json:"error,omitempty"`
+		}{UpdateInfo: info}
+		if err != nil {
+			out.Error = err.Error()
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(out); err != nil {
+			return err
+		}
+		if failed {
+			return errReported
+		}
+		return nil
+	}
+	if failed {
+		return err
+	}
+	fmt.Fprintf(w, "%s: current %s", info.Decision, info.Current)
+	if info.Remote != "" {
+		fmt.Fprintf(w, ", release %s", info.Remote)
+	}
+	fmt.Fprintf(w, " (channel %s)\n", info.Channel)
+	data := selfupdate.MessageData{Current: info.Current, Release: info.Remote, Channel: info.Channel, Decision: info.Decision}
+	if err != nil {
+		data.Error = err.Error()
+	}
+	switch info.Decision {
+	case selfupdate.DecisionMajorBlocked:
+		fmt.Fprintln(w, msgs.Render(selfupdate.MsgMajorBlocked, data))
+	case selfupdate.DecisionPackageManaged:
+		fmt.Fprintln(w, msgs.Render(selfupdate.MsgPackageManaged, data))
+	case selfupdate.DecisionDeferred:
+		var age *selfupdate.ReleaseAgeError
+		if errors.As(err, &age) {
+			data.Until = age.InstallableAt
+			fmt.Fprintln(w, msgs.Render(selfupdate.MsgSoaking, data))
+		}
+	}
+	return nil
 }
 
-func versionHandler(w http.ResponseWriter, r *http.Request) {
-	fmt.Fprintln(w, version)
+// applyConfig sets the Updater fields that can change at run time; see
+// reloadConfig.
+func applyConfig(u *selfupdate.Updater, cfg config) {
+	u.ChecksumAsset, u.ManifestSigners = cfg.ChecksumAsset, cfg.ManifestSigners
+	u.Connections, u.SegmentSize = cfg.Connections, cfg.SegmentSize
+	u.MemoryDownloadLimit = cfg.MemoryDownloadLimit
+	u.Channel, u.Constraint = cfg.Channel, cfg.Constraint
+	u.Mirrors = cfg.Mirrors
+	u.AssetFallbacks, u.AssetRegexp = cfg.AssetFallbacks, cfg.AssetRegexp
+	u.AllowMajorUpgrade, u.AllowPackaged = cfg.AllowMajorUpgrade, cfg.AllowPackaged
+	u.UpgradeWithPackageManager = cfg.UsePackageManager
+	u.MinReleaseAge, u.ReleaseRule = cfg.MinReleaseAge, cfg.ReleaseRule
+	u.Canary = cfg.Canary
+	u.Messages = cfg.Messages
+	u.Services = cfg.Services
+	u.Policy, u.MaintenanceWindows = cfg.Policy, cfg.Windows
+	u.Staged = cfg.Staged
+	u.Overlay = cfg.Overlay
+	u.SBOMAsset, u.SBOMPolicy = cfg.SBOMAsset, cfg.SBOMPolicy
+	u.Keyring = cfg.Keyring
+	if cfg.Keyring != nil {
+		u.SignatureSuffix = cfg.SignatureSuffix
+	}
+	u.Transparency = cfg.Transparency
+	u.Advisories = cfg.Advisories
+	u.Fleet = cfg.Fleet
+	u.DNS = cfg.DNS
 }
 
-func main() {
-	// Flags
-	showVersion := flag.Bool("version", false, "Print version and exit")
-	skipUpgrade := flag.Bool("skip-upgrade", false, "Do not check for newer releases")
-	flag.Parse()
+// serve runs the auto-upgrade check and then the HTTP server. reload
+// reads the config file again on SIGHUP, and when the watchedFiles
+// change with -watch-interval.
+func serve(cfg config, reload func() (config, error), watchedFiles func() []string) {
+	log.Printf("updater %s", buildInfo())
 
-	if *showVersion {
-		fmt.Println(version)
-		return
+	ctx := context.Background()
+	u, flushTraces := newUpdater(cfg)
+	exits := exitRecorder{stateDir: cfg.StateDir, version: u.Build.Version}
+	exits.start()
+	exits.onTerminate(flushTraces)
+	if exe, err := os.Executable(); err == nil {
+		// Left behind by the Windows install strategy.
+		selfupdate.RemoveOld(exe)
+	}
+	// restart hands over to the installed version: by re-executing it
+	// when it went to the overlay directory, else by exiting for the
+	// supervisor to restart us. The -restart-services go first. rec
+	// says why.
+	restart := func(rec exitRecord) {
+		if err := u.RestartServices(ctx); err != nil {
+			log.Printf("WARNING: %v; not restarting the remaining services", err)
+		}
+		flushTraces()
+		if exe := u.OverlayExecutable(); exe != "" {
+			log.Printf("Re-executing %s", exe)
+			if err := selfupdate.Reexec(exe); err != nil {
+				log.Printf("%v", err)
+			}
+		}
+		rec.ExitCode = 1
+		exits.exit(rec)
+	}
+	u.AfterUpgrade = func() { restart(exitRecord{Reason: exitUpgrade}) }
+	if exe := u.OverlayExecutable(); exe != "" {
+		// Started from the read-only original by a unit without the drop-in.
+		log.Printf("Re-executing %s", exe)
+		log.Printf("%v; continuing with this executable", selfupdate.Reexec(exe))
+	}
+	if tag, err := u.ActivateStaged(selfupdate.WithAuditSource(ctx, "startup")); err != nil {
+		log.Printf("staged update: %v", err)
+	} else if tag != "" {
+		log.Printf("Restarting into the staged version %s", tag)
+		restart(exitRecord{Reason: exitUpgrade, Target: tag})
+	}
+	handleSignals(u, reload, u.AfterUpgrade, cfg.LogFile)
+	if cfg.WatchInterval > 0 {
+		w := newConfigWatcher(watchedFiles, func() error { return reloadConfig(u, reload) })
+		go func() {
+			if err := w.run(ctx, cfg.WatchInterval); err != nil {
+				log.Printf("watching configuration files: %v", err)
+			}
+		}()
+	}
+	if rolledBack, err := u.Started(ctx); err != nil {
+		log.Printf("crash-loop detection: %v", err)
+	} else if rolledBack {
+		log.Printf("Restarting into the rolled back version")
+		restart(exitRecord{Reason: exitRollback})
 	}
 
 	// Auto‑upgrade before starting the server
-	if upgraded, err := maybeUpgrade(*skipUpgrade); err != nil {
-		log.Printf("auto‑upgrade error: %v", err)
-	} else if upgraded {
-		os.Exit(1)
+	switch {
+	case cfg.SkipUpgrade, !selfupdate.Enabled():
+	case cfg.Policy == selfupdate.PolicyNotify:
+		notifyRelease(ctx, os.Stderr, u, cfg.NotifyInterval)
+	case cfg.Policy == selfupdate.PolicyManual:
+		log.Printf("Update policy manual: install releases with \"updater update\"")
+	default:
+		upgraded, err := u.MaybeUpgrade(selfupdate.WithAuditSource(ctx, "startup"))
+		switch {
+		case err == nil:
+		case errors.Is(err, selfupdate.ErrAlreadyLatest), errors.Is(err, selfupdate.ErrNoRelease):
+			log.Printf("Update check: %v", err)
+		case errors.Is(err, selfupdate.ErrMajorUpgrade):
+			log.Printf("Update check: %v (set -allow-major-upgrade to install)", err)
+		case errors.Is(err, selfupdate.ErrRateLimited), errors.Is(err, selfupdate.ErrCrashLoop),
+			errors.Is(err, selfupdate.ErrNotLeader), errors.Is(err, selfupdate.ErrRolloutPaused),
+			errors.Is(err, selfupdate.ErrDeferred):
+			log.Printf("auto‑upgrade skipped: %v", err)
+		default:
+			log.Printf("auto‑upgrade error: %v", err)
+		}
+		if upgraded {
+			restart(exitRecord{Reason: exitUpgrade})
+		}
+		flushTraces()
 	}
 
 	// Normal server operation
-	http.HandleFunc("/", helloHandler)
-	http.HandleFunc("/version", versionHandler)
-	fmt.Println("Starting server at :8080")
-	if err := http.ListenAndServe(":8080", nil); err != nil {
-		log.Fatalf("Server failed: %v", err)
+	go u.RunReports(ctx)
+	go u.RunScheduled(ctx)
+	go u.RunIntegrityChecks(ctx)
+	if cfg.DebugListen != "" {
+		if err := serveDebug(cfg.DebugListen, u); err != nil {
+			exits.fatalf("Debug server failed: %v", err)
+		}
+	}
+	if cfg.BeaconListen != "" {
+		if err := serveBeacon(cfg.BeaconListen, u.Build.Version); err != nil {
+			exits.fatalf("Beacon failed: %v", err)
+		}
+	}
+	ln, err := listen(cfg.Listen, cfg.SocketMode)
+	if err != nil {
+		exits.fatalf("Server failed: %v", err)
+	}
+	fmt.Printf("Starting server at %s\n", ln.Addr())
+	handler := limitRequests(u.Middleware(newServeMux(u, cfg.Admin)), cfg.RateLimit)
+	if len(cfg.CORS.Origins) > 0 {
+		handler = allowCORS(handler, cfg.CORS)
+	}
+	if cfg.SecurityHeaders {
+		handler = securityHeaders(handler)
+	}
+
+	switch cfg.GRPCListen {
+	case "":
+	case grpcShared:
+		handler = withGRPC(cfg.Admin.wrap(u.GRPCHandler()), handler)
+	default:
+		if err := serveGRPC(cfg.GRPCListen, logRequests(recoverPanics(cfg.Admin.wrap(u.GRPCHandler())), false)); err != nil {
+			exits.fatalf("gRPC server failed: %v", err)
+		}
+	}
+	handler = logRequests(recoverPanics(handler), cfg.TrustProxy)
+	if err := newServer(handler).Serve(ln); err != nil {
+		exits.fatalf("Server failed: %v", err)
+	}
+}
+
+// errReported is returned when a failure has already been written to the
+// output, so main only sets the exit status.
+var errReported = errors.New("failure already reported")
+
+func main() {
+	if err := newRootCommand().execute(os.Args[1:]); err != nil {
+		var status exitStatus
+		if errors.As(err, &status) {
+			os.Exit(int(status))
+		}
+		if !errors.Is(err, errUsage) && !errors.Is(err, errReported) {
+			fmt.Fprintln(os.Stderr, "updater:", err)
+		}
+		os.Exit(2)
 	}
 }
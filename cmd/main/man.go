@@ -0,0 +1,155 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------------------
+// Man page generation from the command tree
+// ---------------------------------------------------------------------
+
+// manPageName returns the page name for c, e.g. "updater-completion".
+func manPageName(c *command) string {
+	return strings.ReplaceAll(c.path(), " ", "-")
+}
+
+// manDate honors SOURCE_DATE_EPOCH so packagers get reproducible pages.
+func manDate() time.Time {
+	if s := os.Getenv("SOURCE_DATE_EPOCH"); s != "" {
+		if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return time.Unix(sec, 0).UTC()
+		}
+	}
+	return time.Now().UTC()
+}
+
+// writeManPage writes a section 1 man page for c in roff format.
+func writeManPage(w io.Writer, c *command, date time.Time) error {
+	var b strings.Builder
+	name := manPageName(c)
+	fmt.Fprintf(&b, ".TH %s 1 %q %q %q\n",
+		strings.ToUpper(name), date.Format("2006-01-02"),
+		c.root().Name+" "+version, "User Commands")
+
+	b.WriteString(".SH NAME\n")
+	fmt.Fprintf(&b, "%s \\- %s\n", roffEscape(name), roffEscape(c.Short))
+
+	b.WriteString(".SH SYNOPSIS\n")
+	fmt.Fprintf(&b, ".B %s\n", roffEscape(c.path()))
+	if hasFlags(c.Flags) {
+		b.WriteString("[\\fIflags\\fR]\n")
+	}
+	if len(c.Children) > 0 {
+		b.WriteString("[\\fIcommand\\fR]\n")
+	}
+	if c.Usage != "" {
+		fmt.Fprintf(&b, "\\fI%s\\fR\n", roffEscape(c.Usage))
+	}
+
+	b.WriteString(".SH DESCRIPTION\n")
+	if c.Long != "" {
+		b.WriteString(roffEscape(c.Long) + "\n")
+	} else {
+		b.WriteString(roffEscape(c.Short) + "\n")
+	}
+
+	if hasFlags(c.Flags) {
+		b.WriteString(".SH OPTIONS\n")
+		c.Flags.VisitAll(func(f *flag.Flag) {
+			b.WriteString(".TP\n")
+			if isBoolFlag(f) {
+				fmt.Fprintf(&b, "\\fB\\-%s\\fR\n", roffEscape(f.Name))
+			} else {
+				fmt.Fprintf(&b, "\\fB\\-%s\\fR \\fIvalue\\fR\n", roffEscape(f.Name))
+			}
+			usage := f.Usage
+			if f.DefValue != "" && f.DefValue != "false" {
+				usage += fmt.Sprintf(" (default %q)", f.DefValue)
+			}
+			b.WriteString(roffEscape(usage) + "\n")
+		})
+	}
+
+	if hasFlags(c.Flags) {
+		b.WriteString(".SH ENVIRONMENT\n")
+		b.WriteString(roffEscape(envHelp(c.Flags)) + "\n")
+		c.Flags.VisitAll(func(f *flag.Flag) {
+			fmt.Fprintf(&b, ".TP\n\\fB%s\\fR\nSets \\fB\\-%s\\fR.\n", roffEscape(envName(f.Name)), roffEscape(f.Name))
+		})
+	}
+
+	if len(c.Children) > 0 {
+		b.WriteString(".SH COMMANDS\n")
+		for _, child := range c.Children {
+			b.WriteString(".TP\n")
+			fmt.Fprintf(&b, "\\fB%s\\fR\n", roffEscape(child.Name))
+			b.WriteString(roffEscape(child.Short) + "\n")
+		}
+	}
+
+	var related []string
+	if c.parent != nil {
+		related = append(related, manPageName(c.parent))
+	}
+	for _, child := range c.Children {
+		related = append(related, manPageName(child))
+	}
+	if len(related) > 0 {
+		b.WriteString(".SH SEE ALSO\n")
+		for i, r := range related {
+			sep := ","
+			if i == len(related)-1 {
+				sep = ""
+			}
+			fmt.Fprintf(&b, ".BR %s (1)%s\n", roffEscape(r), sep)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeManPages writes one page per command in the tree into dir.
+func writeManPages(dir string, root *command, date time.Time) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	var firstErr error
+	root.walk(func(c *command) {
+		if firstErr != nil {
+			return
+		}
+		path := filepath.Join(dir, manPageName(c)+".1")
+		f, err := os.Create(path)
+		if err != nil {
+			firstErr = err
+			return
+		}
+		if err := writeManPage(f, c, date); err != nil {
+			f.Close()
+			firstErr = err
+			return
+		}
+		firstErr = f.Close()
+	})
+	return firstErr
+}
+
+// roffEscape protects text from being interpreted as roff requests.
+func roffEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\e`)
+	s = strings.ReplaceAll(s, "-", `\-`)
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		if strings.HasPrefix(l, ".") || strings.HasPrefix(l, "'") {
+			lines[i] = `\&` + l
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/msmania/updater/selfupdate"
+)
+
+// manifestSigs returns the signature file of manifest: sigs if set, else
+// the name -manifest-keys looks for.
+func manifestSigs(manifest, sigs string) string {
+	if sigs != "" {
+		return sigs
+	}
+	return manifest + selfupdate.DefaultManifestSignatureSuffix
+}
+
+// manifestSign appends the signatures of manifest by the private keys in
+// keyFiles to its signature file, leaving out those already listed, so
+// the signers of a k-of-n manifest can sign one after another.
+func manifestSign(w io.Writer, keyFiles []string, manifest, sigs string) error {
+	if len(keyFiles) == 0 {
+		return errors.New("usage: manifest sign -key KEYFILE[,KEYFILE...] FILE")
+	}
+	keys, err := readPrivateKeys(keyFiles)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(manifest)
+	if err != nil {
+		return err
+	}
+	sigs = manifestSigs(manifest, sigs)
+	old, err := os.ReadFile(sigs)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	listed := map[string]bool{}
+	for sc := bufio.NewScanner(bytes.NewReader(old)); sc.Scan(); {
+		listed[string(bytes.TrimSpace(sc.Bytes()))] = true
+	}
+	var add []byte
+	for sc := bufio.NewScanner(bytes.NewReader(selfupdate.SignManifest(data, keys...))); sc.Scan(); {
+		if line := sc.Text(); !listed[line] {
+			listed[line] = true
+			add = append(add, line+"\n"...)
+		}
+	}
+	if len(old) > 0 && old[len(old)-1] != '\n' {
+		add = append([]byte{'\n'}, add...)
+	}
+	f, err := os.OpenFile(sigs, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(add); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Signed %s with %d key(s); signatures in %s\n", manifest, len(keys), sigs)
+	return nil
+}
+
+// manifestVerify checks that the signature file of manifest holds
+// signatures by threshold of the public keys in keyFiles, as an updater
+// run with -manifest-keys and -manifest-threshold would.
+func manifestVerify(w io.Writer, keyFiles []string, threshold int, manifest, sigs string) error {
+	if len(keyFiles) == 0 {
+		return errors.New("usage: manifest verify -keys PUBKEYFILE[,PUBKEYFILE...] FILE")
+	}
+	m := selfupdate.ManifestSigners{Threshold: threshold}
+	var err error
+	if m.Keys, err = readPublicKeys(keyFiles); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(manifest)
+	if err != nil {
+		return err
+	}
+	sigs = manifestSigs(manifest, sigs)
+	sigData, err := os.ReadFile(sigs)
+	if err != nil {
+		return err
+	}
+	if err := m.Verify(data, sigData); err != nil {
+		return fmt.Errorf("%s: %w", manifest, err)
+	}
+	fmt.Fprintf(w, "%s: signatures verified\n", manifest)
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/msmania/updater/selfupdate"
+)
+
+func Test_manifestSign(t *testing.T) {
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "checksums.txt")
+	os.WriteFile(manifest, []byte("0123  app_linux_amd64.tar.gz\n"), 0o644)
+	var privs, pubs []string
+	for _, name := range []string{"a", "b", "c"} {
+		var out strings.Builder
+		priv := filepath.Join(dir, name+".key")
+		if err := auditKeygen(&out, priv); err != nil {
+			t.Fatal(err)
+		}
+		pub := filepath.Join(dir, name+".pub")
+		os.WriteFile(pub, []byte(out.String()), 0o644)
+		privs, pubs = append(privs, priv), append(pubs, pub)
+	}
+	verify := func(threshold int, ok bool) {
+		t.Helper()
+		var out strings.Builder
+		err := manifestVerify(&out, pubs, threshold, manifest, "")
+		if ok && err != nil || !ok && !errors.Is(err, selfupdate.ErrSignatureInvalid) {
+			t.Errorf("threshold %d: unexpected result %v", threshold, err)
+		}
+	}
+
+	var out strings.Builder
+	if err := manifestSign(&out, privs[:1], manifest, ""); err != nil {
+		t.Fatal(err)
+	}
+	verify(1, true)
+	verify(2, false)
+	// Signing again adds nothing; another signer appends.
+	manifestSign(&out, privs[:1], manifest, "")
+	verify(2, false)
+	if err := manifestSign(&out, privs[2:], manifest, ""); err != nil {
+		t.Fatal(err)
+	}
+	verify(2, true)
+	verify(0, false)
+	if data, _ := os.ReadFile(manifest + ".sigs"); strings.Count(string(data), "\n") != 2 {
+		t.Errorf("unexpected signature file:\n%s", data)
+	}
+
+	os.WriteFile(manifest, []byte("4567  app_linux_amd64.tar.gz\n"), 0o644)
+	verify(1, false)
+
+	if err := manifestSign(&out, nil, manifest, ""); err == nil {
+		t.Error("expected an error without keys")
+	}
+	if err := manifestVerify(&out, pubs, 1, manifest, filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected an error for a missing signature file")
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// responseRecorder captures the status and size of a response.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *responseRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (r *responseRecorder) Flush() {
+	http.NewResponseController(r.ResponseWriter).Flush()
+}
+
+// logRequests logs one line per request with the client address, method,
+// path, status, response size and latency. Behind a reverse proxy
+// (trustProxy) the client is taken from X-Forwarded-For.
+func logRequests(next http.Handler, trustProxy bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &responseRecorder{ResponseWriter: w}
+		defer func() {
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			log.Printf("%s %s %s %d %dB %s", clientAddr(r, trustProxy), r.Method,
+				r.URL.RequestURI(), status, rec.bytes, time.Since(start).Round(time.Microsecond))
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// clientAddr returns the address of the client that sent r. Behind a
+// trusted proxy that is the last X-Forwarded-For entry, the one the proxy
+// appended; earlier entries come from the client and may be forged.
+func clientAddr(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if xff := forwardedFor(r); xff != "" {
+			return strings.TrimSpace(xff[strings.LastIndex(xff, ",")+1:])
+		}
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// forwardedFor returns the X-Forwarded-For entries of r, joining repeated
+// headers in order.
+func forwardedFor(r *http.Request) string {
+	return strings.Join(r.Header.Values("X-Forwarded-For"), ",")
+}
+
+// recoverPanics turns a panicking handler into a 500 response and logs
+// the stack, instead of dropping the connection.
+func recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec, ok := w.(*responseRecorder)
+		if !ok {
+			rec = &responseRecorder{ResponseWriter: w}
+		}
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			log.Printf("panic serving %s %s: %v\n%s", r.Method, r.URL.Path, v, debug.Stack())
+			if rec.status == 0 {
+				http.Error(rec, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// contentSecurityPolicy allows the dashboard's inline script and style
+// and nothing from other origins.
+const contentSecurityPolicy = "default-src 'self'; script-src 'self' 'unsafe-inline'; " +
+	"style-src 'self' 'unsafe-inline'; frame-ancestors 'none'"
+
+// securityHeaders adds the usual browser hardening headers to every
+// response of next; Strict-Transport-Security only over TLS.
+func securityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("X-Frame-Options", "DENY")
+		h.Set("Referrer-Policy", "no-referrer")
+		h.Set("Content-Security-Policy", contentSecurityPolicy)
+		if r.TLS != nil {
+			h.Set("Strict-Transport-Security", "max-age=63072000")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// corsConfig lists what browsers on other origins may call.
+type corsConfig struct {
+	// Origins are allowed origins such as "https://admin.example.com",
+	// or "*" for any. Empty disables CORS.
+	Origins []string
+	// Methods and Headers are answered to preflight requests.
+	Methods []string
+	Headers []string
+}
+
+// allows reports whether origin may call the API.
+func (c corsConfig) allows(origin string) bool {
+	for _, o := range c.Origins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// corsPreflightMaxAge is how long, in seconds, browsers may cache a
+// preflight answer.
+const corsPreflightMaxAge = "600"
+
+// allowCORS answers CORS preflight requests from the origins of cfg and
+// marks the responses of next as readable by them. Requests from other
+// origins pass through unchanged, so browsers block them.
+func allowCORS(next http.Handler, cfg corsConfig) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		if origin == "" || !cfg.allows(origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		h.Set("Access-Control-Allow-Origin", origin)
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			h.Set("Access-Control-Allow-Methods", strings.Join(cfg.Methods, ", "))
+			h.Set("Access-Control-Allow-Headers", strings.Join(cfg.Headers, ", "))
+			h.Set("Access-Control-Max-Age", corsPreflightMaxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		h.Set("Access-Control-Expose-Headers", "X-App-Version, Retry-After")
+		next.ServeHTTP(w, r)
+	})
+}
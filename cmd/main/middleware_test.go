@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func captureLog(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &buf
+}
+
+func Test_logRequests(t *testing.T) {
+	buf := captureLog(t)
+	h := logRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("short and stout"))
+	}), true)
+	req := httptest.NewRequest("GET", "/pot?x=1", nil)
+	req.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if line := buf.String(); !strings.Contains(line, "10.0.0.1 GET /pot?x=1 418 15B") {
+		t.Error("unexpected log line: " + line)
+	}
+}
+
+func Test_clientAddr(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	if got := clientAddr(req, false); got != "192.0.2.1" {
+		t.Error("X-Forwarded-For must be ignored unless trusted, got " + got)
+	}
+	if got := clientAddr(req, true); got != "203.0.113.7" {
+		t.Error("unexpected trusted client " + got)
+	}
+	// The proxy appends the peer it saw, so leading entries are the
+	// client's own claims.
+	req.Header.Set("X-Forwarded-For", "127.0.0.1, 203.0.113.7")
+	if got := clientAddr(req, true); got != "203.0.113.7" {
+		t.Error("spoofed X-Forwarded-For believed, got " + got)
+	}
+	req.Header.Add("X-Forwarded-For", "198.51.100.2")
+	if got := clientAddr(req, true); got != "198.51.100.2" {
+		t.Error("repeated X-Forwarded-For not joined, got " + got)
+	}
+	req.Header.Set("X-Forwarded-For", "203.0.113.7,")
+	if got := clientAddr(req, true); got != "" {
+		t.Error("empty last entry ignored, got " + got)
+	}
+}
+
+func Test_recoverPanics(t *testing.T) {
+	buf := captureLog(t)
+	h := logRequests(recoverPanics(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})), false)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/crash", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Error("expected 500, got", rec.Code)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "panic serving GET /crash: boom") || !strings.Contains(out, "goroutine") {
+		t.Error("panic and stack not logged: " + out)
+	}
+	if !strings.Contains(out, "GET /crash 500") {
+		t.Error("recovered request not logged as 500: " + out)
+	}
+}
+
+func Test_securityHeaders(t *testing.T) {
+	h := securityHeaders(http.NotFoundHandler())
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Header().Get("X-Frame-Options") != "DENY" || rec.Header().Get("Content-Security-Policy") == "" {
+		t.Errorf("headers missing: %v", rec.Header())
+	}
+	if rec.Header().Get("Strict-Transport-Security") != "" {
+		t.Error("HSTS sent without TLS")
+	}
+}
+
+func Test_allowCORS(t *testing.T) {
+	cfg := corsConfig{Origins: []string{"https://admin.example.com"}, Methods: []string{"GET", "POST"},
+		Headers: []string{"Content-Type"}}
+	h := allowCORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}), cfg)
+	verify := func(method, origin string, wantCode int, wantAllowed bool) {
+		t.Helper()
+		req := httptest.NewRequest(method, "/api/v1/update/status", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if method == "OPTIONS" {
+			req.Header.Set("Access-Control-Request-Method", "POST")
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		allowed := rec.Header().Get("Access-Control-Allow-Origin") == origin && origin != ""
+		if rec.Code != wantCode || allowed != wantAllowed {
+			t.Errorf("%s from %q = %d %v", method, origin, rec.Code, rec.Header())
+		}
+	}
+	verify("GET", "https://admin.example.com", http.StatusTeapot, true)
+	verify("OPTIONS", "https://admin.example.com", http.StatusNoContent, true)
+	verify("GET", "https://evil.example.com", http.StatusTeapot, false)
+	verify("OPTIONS", "https://evil.example.com", http.StatusTeapot, false)
+	verify("GET", "", http.StatusTeapot, false)
+
+	req := httptest.NewRequest("OPTIONS", "/", nil)
+	req.Header.Set("Origin", "https://admin.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Header().Get("Access-Control-Allow-Methods") != "GET, POST" {
+		t.Errorf("unexpected preflight answer: %v", rec.Header())
+	}
+}
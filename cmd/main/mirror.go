@@ -0,0 +1,15 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/msmania/updater/selfupdate"
+)
+
+// printMirrorSync writes one line per release copied by mirror sync.
+func printMirrorSync(w io.Writer, syncs []selfupdate.MirrorSync) {
+	for _, s := range syncs {
+		fmt.Fprintf(w, "%s: %d fetched, %d up to date\n", s.Tag, len(s.Fetched), len(s.Current))
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/msmania/updater/selfupdate"
+)
+
+// notifyRelease writes a notice about a newer release to w, as found by
+// an earlier check, without blocking. The check running in the background
+// writes another notice if it finds a release not announced yet.
+func notifyRelease(ctx context.Context, w io.Writer, u *selfupdate.Updater, interval time.Duration) {
+	latest, fresh := u.LatestNotice(ctx, interval)
+	if latest != "" {
+		fmt.Fprintln(w, releaseNotice(u, latest))
+	}
+	go func() {
+		if tag, ok := <-fresh; ok && tag != "" && tag != latest {
+			fmt.Fprintln(w, releaseNotice(u, tag))
+		}
+	}()
+}
+
+func releaseNotice(u *selfupdate.Updater, latest string) string {
+	return u.Messages.Render(selfupdate.MsgReleaseNotice, selfupdate.MessageData{
+		Current: u.Build.Version, Release: latest, Channel: u.Channel})
+}
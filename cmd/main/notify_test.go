@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/msmania/updater/selfupdate"
+	"github.com/msmania/updater/selfupdate/selfupdatetest"
+)
+
+// lineWriter passes each write to a channel.
+type lineWriter chan string
+
+func (w lineWriter) Write(p []byte) (int, error) {
+	w <- string(p)
+	return len(p), nil
+}
+
+func Test_notifyRelease(t *testing.T) {
+	skipIfDisabled(t)
+	srv := selfupdatetest.NewServer("owner", "app",
+		selfupdatetest.Release{Tag: "v1.1.0", Assets: []selfupdatetest.Asset{{Name: "app-bin", Content: []byte("v1.1")}}},
+	)
+	defer srv.Close()
+	u := &selfupdate.Updater{Owner: "owner", Repo: "app", AssetName: "app-bin", APIURL: srv.URL,
+		Build: selfupdate.BuildInfo{Version: "v1.0.0"}, StateDir: t.TempDir()}
+	want := "updater v1.1.0 is available (current v1.0.0); run 'updater update' to install it.\n"
+	next := func(w lineWriter) string {
+		t.Helper()
+		select {
+		case s := <-w:
+			return s
+		case <-time.After(5 * time.Second):
+			t.Fatal("no notice")
+			return ""
+		}
+	}
+
+	// Nothing cached yet: the notice follows the background check.
+	w := make(lineWriter, 2)
+	notifyRelease(context.Background(), w, u, time.Hour)
+	if got := next(w); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	// Cached: printed right away, without checking again.
+	n := len(srv.Requests())
+	w = make(lineWriter, 2)
+	notifyRelease(context.Background(), w, u, time.Hour)
+	select {
+	case got := <-w:
+		if got != want {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	default:
+		t.Error("cached notice not printed synchronously")
+	}
+	if len(srv.Requests()) != n {
+		t.Error("checked again within -notify-interval")
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/msmania/updater/selfupdate"
+)
+
+// pushFlags are the flags of the check and update commands that push the
+// metrics of the run, which exits before it could be scraped.
+type pushFlags struct {
+	gateway, job string
+	otlp         bool
+}
+
+func addPushFlags(fs *flag.FlagSet) *pushFlags {
+	p := &pushFlags{}
+	fs.StringVar(&p.gateway, "pushgateway", "",
+		"Push the metrics of the run to this Prometheus Pushgateway base URL, e.g. http://pushgateway:9091")
+	fs.StringVar(&p.job, "push-job", "updater", "Job label of the metrics pushed to -pushgateway")
+	fs.BoolVar(&p.otlp, "otlp-metrics", false, "Push the metrics of the run to -otlp-endpoint")
+	return p
+}
+
+// pushTimeout bounds each push, so that an unreachable gateway does not
+// hold up the exit of a run.
+const pushTimeout = 10 * time.Second
+
+// pushers returns the configured pushers.
+func (p *pushFlags) pushers(cfg config, build selfupdate.BuildInfo) ([]selfupdate.MetricsPusher, error) {
+	if p.otlp && cfg.OTLPEndpoint == "" {
+		return nil, errors.New("-otlp-metrics requires -otlp-endpoint")
+	}
+	var out []selfupdate.MetricsPusher
+	if p.gateway != "" {
+		out = append(out, &selfupdate.Pushgateway{URL: p.gateway, Job: p.job,
+			Client: &http.Client{Timeout: pushTimeout}})
+	}
+	if p.otlp {
+		out = append(out, selfupdate.NewOTLPMetrics(cfg.OTLPEndpoint, "updater", build))
+	}
+	return out, nil
+}
+
+// pushRun sends the metrics of a run of command to pushers. Failures are
+// logged; they do not fail the run.
+func pushRun(pushers []selfupdate.MetricsPusher, command string, info *selfupdate.UpdateInfo, err error) {
+	m := selfupdate.NewRunMetrics(command, info, err)
+	for _, pusher := range pushers {
+		ctx, cancel := context.WithTimeout(context.Background(), pushTimeout)
+		if err := pusher.Push(ctx, m); err != nil {
+			log.Printf("metrics push error: %v", err)
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/msmania/updater/selfupdate"
+)
+
+func Test_pushFlags(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	p := addPushFlags(fs)
+	fs.Parse([]string{"-pushgateway", srv.URL, "-otlp-metrics"})
+	if _, err := p.pushers(config{}, selfupdate.BuildInfo{}); err == nil {
+		t.Error("-otlp-metrics without -otlp-endpoint must fail")
+	}
+	pushers, err := p.pushers(config{OTLPEndpoint: srv.URL}, selfupdate.BuildInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pushRun(pushers, "check", &selfupdate.UpdateInfo{Decision: selfupdate.DecisionUpToDate}, nil)
+	if len(paths) != 2 || !strings.HasPrefix(paths[0], "/metrics/job/updater/instance/") || paths[1] != "/v1/metrics" {
+		t.Errorf("pushed to %q", paths)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// rateLimit is a token bucket refilled at Rate requests per second up to
+// Burst requests. A zero Rate disables it.
+type rateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// burst returns Burst, defaulting to the requests of one second.
+func (l rateLimit) burst() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return math.Max(1, math.Ceil(l.Rate))
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take removes a token from b, filled at l, and returns 0, or else the
+// time until a token is available.
+func (b *tokenBucket) take(l rateLimit, now time.Time) time.Duration {
+	if b.last.IsZero() {
+		b.tokens = l.burst()
+	} else {
+		b.tokens = math.Min(l.burst(), b.tokens+now.Sub(b.last).Seconds()*l.Rate)
+	}
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / l.Rate * float64(time.Second))
+}
+
+// full reports whether b has refilled completely by now, so that
+// forgetting it changes nothing.
+func (b *tokenBucket) full(l rateLimit, now time.Time) bool {
+	return b.tokens+now.Sub(b.last).Seconds()*l.Rate >= l.burst()
+}
+
+// rateLimitConfig limits the requests of all clients together (Global)
+// and of each client address (PerClient).
+type rateLimitConfig struct {
+	Global, PerClient rateLimit
+	// TrustProxy takes the client from X-Forwarded-For; see clientAddr.
+	TrustProxy bool
+}
+
+// rateLimiter enforces a rateLimitConfig.
+type rateLimiter struct {
+	cfg rateLimitConfig
+	now func() time.Time
+
+	mu      sync.Mutex
+	global  tokenBucket
+	clients map[string]*tokenBucket
+	swept   time.Time
+}
+
+// clientSweepInterval is how often the buckets of idle clients are
+// dropped.
+const clientSweepInterval = time.Minute
+
+// allow takes a token for a request from client and returns 0, or how
+// long the client should wait before retrying.
+func (l *rateLimiter) allow(client string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if l.cfg.PerClient.Rate > 0 {
+		if now.Sub(l.swept) >= clientSweepInterval {
+			for addr, b := range l.clients {
+				if b.full(l.cfg.PerClient, now) {
+					delete(l.clients, addr)
+				}
+			}
+			l.swept = now
+		}
+		b := l.clients[client]
+		if b == nil {
+			b = &tokenBucket{}
+			l.clients[client] = b
+		}
+		if wait := b.take(l.cfg.PerClient, now); wait > 0 {
+			return wait
+		}
+	}
+	if l.cfg.Global.Rate > 0 {
+		return l.global.take(l.cfg.Global, now)
+	}
+	return 0
+}
+
+// limitRequests answers requests over the limits of cfg with 429 and a
+// Retry-After header, or returns next unchanged if cfg sets no limit.
+func limitRequests(next http.Handler, cfg rateLimitConfig) http.Handler {
+	if cfg.Global.Rate <= 0 && cfg.PerClient.Rate <= 0 {
+		return next
+	}
+	l := &rateLimiter{cfg: cfg, now: time.Now, clients: make(map[string]*tokenBucket)}
+	return l.wrap(next)
+}
+
+func (l *rateLimiter) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if wait := l.allow(clientAddr(r, l.cfg.TrustProxy)); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			writeAPIError(w, http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_limitRequests(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	if h := limitRequests(ok, rateLimitConfig{}); h == nil {
+		t.Fatal("nil handler")
+	}
+
+	now := time.Unix(1_700_000_000, 0)
+	l := &rateLimiter{
+		cfg: rateLimitConfig{
+			Global:    rateLimit{Rate: 10, Burst: 3},
+			PerClient: rateLimit{Rate: 1, Burst: 2},
+		},
+		now:     func() time.Time { return now },
+		clients: make(map[string]*tokenBucket),
+	}
+	h := l.wrap(ok)
+	get := func(addr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/version", nil)
+		req.RemoteAddr = addr + ":1234"
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	verify := func(name, addr string, want int) {
+		rec := get(addr)
+		if rec.Code != want {
+			t.Errorf("%s: got %d, want %d", name, rec.Code, want)
+		}
+		if want == http.StatusTooManyRequests && rec.Header().Get("Retry-After") == "" {
+			t.Errorf("%s: no Retry-After", name)
+		}
+	}
+
+	verify("burst 1", "192.0.2.1", http.StatusOK)
+	verify("burst 2", "192.0.2.1", http.StatusOK)
+	verify("client limit", "192.0.2.1", http.StatusTooManyRequests)
+	if rec := get("192.0.2.1"); rec.Header().Get("Retry-After") != "1" {
+		t.Error("unexpected Retry-After", rec.Header().Get("Retry-After"))
+	}
+	verify("other client", "192.0.2.2", http.StatusOK)
+	verify("global limit", "192.0.2.3", http.StatusTooManyRequests)
+
+	now = now.Add(time.Second)
+	verify("refilled", "192.0.2.1", http.StatusOK)
+
+	// Idle clients whose buckets have refilled are forgotten.
+	now = now.Add(clientSweepInterval)
+	get("192.0.2.4")
+	if len(l.clients) != 1 {
+		t.Errorf("%d client buckets kept", len(l.clients))
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/msmania/updater/client"
+)
+
+// remoteFlags holds the flags of the remote commands.
+type remoteFlags struct {
+	hosts     string
+	parallel  int
+	timeout   time.Duration
+	tokenFile string
+	asJSON    bool
+}
+
+func addRemoteFlags(fs *flag.FlagSet) *remoteFlags {
+	f := &remoteFlags{}
+	fs.StringVar(&f.hosts, "hosts", "", `File listing one node per line, as host:port or the URL of its update API ("-" for standard input)`)
+	fs.IntVar(&f.parallel, "parallel", 10, "Number of nodes called at once")
+	fs.DurationVar(&f.timeout, "timeout", 2*time.Minute, "Time allowed for each node")
+	fs.StringVar(&f.tokenFile, "token-file", "", "File holding a bearer token sent to the nodes")
+	fs.BoolVar(&f.asJSON, "json", false, "Print the results as JSON")
+	return f
+}
+
+// remoteOp calls one node: its Check or Trigger endpoint.
+type remoteOp func(ctx context.Context, c *client.Client) remoteResult
+
+// remoteResult is the outcome of a remote operation on one node.
+type remoteResult struct {
+	Host    string `json:"host"`
+	Current string `json:"current,omitempty"`
+	Latest  string `json:"latest,omitempty"`
+	Result  string `json:"result,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+func remoteCheck(ctx context.Context, c *client.Client) remoteResult {
+	info, err := c.Check(ctx)
+	var res remoteResult
+	if info != nil {
+		res = remoteResult{Current: info.Current, Latest: info.Remote, Result: string(info.Decision)}
+	}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	return res
+}
+
+func remoteUpdate(ctx context.Context, c *client.Client) remoteResult {
+	st, err := c.Trigger(ctx)
+	if err != nil {
+		return remoteResult{Error: err.Error()}
+	}
+	return remoteResult{Current: st.Current, Result: st.Result, Error: st.Error}
+}
+
+// runRemote calls op on the nodes listed by f and prints the results in
+// the order of the list. It fails if any node failed.
+func runRemote(w io.Writer, f *remoteFlags, op remoteOp) error {
+	if f.hosts == "" {
+		return fmt.Errorf("-hosts is required")
+	}
+	if f.parallel < 1 {
+		return fmt.Errorf("invalid -parallel %d", f.parallel)
+	}
+	hosts, err := readHosts(f.hosts)
+	if err != nil {
+		return err
+	}
+	var token string
+	if f.tokenFile != "" {
+		data, err := os.ReadFile(f.tokenFile)
+		if err != nil {
+			return err
+		}
+		token = strings.TrimSpace(string(data))
+	}
+
+	results := make([]remoteResult, len(hosts))
+	sem := make(chan struct{}, f.parallel)
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			c := client.New(nodeURL(host))
+			c.Token = token
+			ctx, cancel := context.WithTimeout(context.Background(), f.timeout)
+			defer cancel()
+			results[i] = op(ctx, c)
+			results[i].Host = host
+		}()
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, r := range results {
+		if r.Error != "" {
+			failed++
+		}
+	}
+	if f.asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			return err
+		}
+	} else {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "HOST\tCURRENT\tLATEST\tRESULT\tERROR")
+		for _, r := range results {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", r.Host, r.Current, r.Latest, r.Result, r.Error)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%d nodes: %d ok, %d failed\n", len(results), len(results)-failed, failed)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d nodes failed", failed, len(results))
+	}
+	return nil
+}
+
+// readHosts reads the node list at path, skipping blank lines and #
+// comments.
+func readHosts(path string) ([]string, error) {
+	r := io.Reader(os.Stdin)
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	var hosts []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		if line = strings.TrimSpace(line); line != "" {
+			hosts = append(hosts, line)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no hosts in %s", path)
+	}
+	return hosts, nil
+}
+
+// nodeURL returns the update API of the node host: host:port stands for
+// http://host:port/api/v1/update, and URLs without a path get that path.
+func nodeURL(host string) string {
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	scheme, rest, _ := strings.Cut(host, "://")
+	if !strings.Contains(strings.TrimSuffix(rest, "/"), "/") {
+		return scheme + "://" + strings.TrimSuffix(rest, "/") + apiPrefix + "/update"
+	}
+	return host
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/msmania/updater/client"
+)
+
+func Test_nodeURL(t *testing.T) {
+	verify := func(host, want string) {
+		t.Helper()
+		if got := nodeURL(host); got != want {
+			t.Errorf("nodeURL(%q) = %q, want %q", host, got, want)
+		}
+	}
+	verify("node1:8080", "http://node1:8080/api/v1/update")
+	verify("https://node1/", "https://node1/api/v1/update")
+	verify("http://gw/node1/update", "http://gw/node1/update")
+}
+
+func Test_runRemote(t *testing.T) {
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/update/check" || r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"current": "v1.0.0", "remote": "v1.1.0", "decision": "available"}`))
+	}))
+	defer node.Close()
+	dir := t.TempDir()
+	hosts := filepath.Join(dir, "hosts.txt")
+	os.WriteFile(hosts, []byte("# nodes\n"+strings.TrimPrefix(node.URL, "http://")+"\n\n127.0.0.1:1 # down\n"), 0o644)
+	token := filepath.Join(dir, "token")
+	os.WriteFile(token, []byte("secret\n"), 0o600)
+
+	var out bytes.Buffer
+	f := &remoteFlags{hosts: hosts, parallel: 2, timeout: 5 * time.Second, tokenFile: token, asJSON: true}
+	err := runRemote(&out, f, remoteCheck)
+	if err == nil || !strings.Contains(err.Error(), "1 of 2 nodes failed") {
+		t.Errorf("expected a failure of one node, got %v", err)
+	}
+	var results []remoteResult
+	if err := json.Unmarshal(out.Bytes(), &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Latest != "v1.1.0" || results[0].Result != "available" || results[0].Error != "" ||
+		results[1].Host != "127.0.0.1:1" || results[1].Error == "" {
+		t.Errorf("unexpected results %s", out.Bytes())
+	}
+
+	out.Reset()
+	f.asJSON = false
+	var running, peak int
+	var mu sync.Mutex
+	op := func(ctx context.Context, c *client.Client) remoteResult {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return remoteResult{Current: "v1.0.0", Result: "up-to-date"}
+	}
+	os.WriteFile(hosts, []byte("a:1\nb:1\nc:1\nd:1\n"), 0o644)
+	if err := runRemote(&out, f, op); err != nil {
+		t.Fatal(err)
+	}
+	if peak > 2 {
+		t.Errorf("%d nodes called at once, -parallel is 2", peak)
+	}
+	if !strings.Contains(out.String(), "4 nodes: 4 ok, 0 failed") || !strings.Contains(out.String(), "c:1  ") {
+		t.Errorf("unexpected summary:\n%s", out.String())
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/msmania/updater/selfupdate"
+)
+
+// apiPrefix is the path prefix of version 1 of the JSON API.
+const apiPrefix = "/api/v1"
+
+// newServeMux returns the routes of the public server:
+//
+//	GET /                  a greeting
+//	GET /api/v1/version    the version, or the BuildInfo and more as JSON; see versionHandler
+//	    /api/v1/update/... the update endpoints of selfupdate.Updater.Handler
+//	GET /ui/               the dashboard
+//	GET /readyz            200 once serving; see readyHandler
+//	GET /api/openapi.json  the OpenAPI document of these routes; see openAPISpec
+//
+// /version and /update/ remain as aliases for clients predating the
+// /api/v1 prefix. The update endpoints and the dashboard are subject to
+// acl. Unknown paths and methods get a JSON error envelope.
+func newServeMux(u *selfupdate.Updater, acl adminACL) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", helloHandler)
+	mux.HandleFunc("GET "+apiPrefix+"/version", versionHandler(u))
+	mux.Handle(apiPrefix+"/update/", acl.wrap(http.StripPrefix(apiPrefix+"/update", u.Handler())))
+	mux.HandleFunc("GET /version", versionHandler(u))
+	mux.Handle("/update/", acl.wrap(http.StripPrefix("/update", u.Handler())))
+	mux.Handle("GET /ui/", acl.wrap(uiHandler()))
+	mux.HandleFunc("GET /readyz", readyHandler)
+	mux.Handle("GET /api/openapi.json", openAPISpec(u))
+	return jsonErrors(mux)
+}
+
+// openAPISpec documents the routes of newServeMux, except for the
+// aliases and the dashboard. The update endpoints come from
+// Updater.Routes, so the document follows the library.
+func openAPISpec(u *selfupdate.Updater) *selfupdate.OpenAPI {
+	spec := selfupdate.NewOpenAPI("updater", u.Build.Version)
+	spec.Add("",
+		selfupdate.Route{Method: http.MethodGet, Path: "/", Summary: "A greeting", ContentType: "text/plain"},
+		selfupdate.Route{Method: http.MethodGet, Path: apiPrefix + "/version",
+			Summary: "The running version; ?format=text for the bare version", Response: versionInfo{},
+			Errors: []int{http.StatusBadRequest}},
+		selfupdate.Route{Method: http.MethodGet, Path: "/readyz", Summary: "Readiness probe",
+			Response: map[string]string{}, Errors: []int{http.StatusServiceUnavailable}},
+	)
+	spec.Add(apiPrefix+"/update", u.Routes()...)
+	return spec
+}
+
+// readyHandler reports that the server is ready, which it is once it
+// answers at all: while an update installs, Updater.Middleware answers
+// 503 instead. A new version proves itself with it after an upgrade
+// (-canary-url).
+func readyHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ready", "version": buildInfo().Version})
+}
+
+// apiError is the envelope of JSON error responses:
+//
+//	{"error": {"status": 405, "message": "Method Not Allowed"}}
+type apiError struct {
+	Error struct {
+		Status  int    `json:"status"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// writeAPIError answers with an apiError for code.
+func writeAPIError(w http.ResponseWriter, code int) {
+	var e apiError
+	e.Error.Status, e.Error.Message = code, http.StatusText(code)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(e)
+}
+
+// jsonErrors replaces the plain text 404 and 405 responses of net/http,
+// written by the muxes and file servers below next, with an apiError.
+// The Allow header of a 405 is kept.
+func jsonErrors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&errorWriter{ResponseWriter: w}, r)
+	})
+}
+
+// errorWriter rewrites plain text 404 and 405 responses; see jsonErrors.
+type errorWriter struct {
+	http.ResponseWriter
+	replaced bool
+}
+
+func (w *errorWriter) WriteHeader(code int) {
+	h := w.Header()
+	if (code == http.StatusNotFound || code == http.StatusMethodNotAllowed) &&
+		strings.HasPrefix(h.Get("Content-Type"), "text/plain") {
+		w.replaced = true
+		h.Del("Content-Length")
+		writeAPIError(w.ResponseWriter, code)
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write discards the plain text body of a replaced response.
+func (w *errorWriter) Write(b []byte) (int, error) {
+	if w.replaced {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *errorWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *errorWriter) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
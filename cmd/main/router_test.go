@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/msmania/updater/selfupdate"
+)
+
+func Test_newServeMux(t *testing.T) {
+	mux := newServeMux(&selfupdate.Updater{Build: buildInfo()}, adminACL{})
+	verify := func(method, path string, wantCode int, wantBody string) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		if rec.Code != wantCode || !strings.Contains(rec.Body.String(), wantBody) {
+			t.Errorf("%s %s = %d %q", method, path, rec.Code, rec.Body)
+		}
+		if rec.Code < 400 {
+			return
+		}
+		var e apiError
+		if err := json.Unmarshal(rec.Body.Bytes(), &e); err != nil || e.Error.Status != rec.Code ||
+			rec.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("%s %s: no error envelope: %q", method, path, rec.Body)
+		}
+	}
+	verify("GET", "/", 200, "Hello")
+	verify("GET", "/api/v1/version", 200, buildInfo().Version)
+	verify("GET", "/api/v1/update/status", 200, `"result"`)
+	verify("GET", "/update/status", 200, `"result"`)
+	verify("GET", "/version", 200, buildInfo().Version)
+	verify("GET", "/readyz", 200, `"ready"`)
+	verify("GET", "/api/openapi.json", 200, `"/api/v1/update/rollback":{"post"`)
+	verify("GET", "/nothing", 404, "Not Found")
+	verify("GET", "/api/v1/update/nothing", 404, "Not Found")
+	verify("GET", "/ui/nothing.js", 404, "Not Found")
+	verify("DELETE", "/", 405, "Method Not Allowed")
+	verify("POST", "/api/v1/version", 405, "Method Not Allowed")
+	verify("GET", "/api/v1/update/trigger", 405, "Method Not Allowed")
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/update/rollback", nil))
+	if rec.Header().Get("Allow") != "POST" {
+		t.Errorf("Allow header lost: %q", rec.Header().Get("Allow"))
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/msmania/updater/sandbox"
+	"github.com/msmania/updater/selfupdate"
+)
+
+// enterSandbox switches u to staged installs and confines this process to
+// writing below the work, state and audit log directories, for
+// "update -sandbox".
+func enterSandbox(u *selfupdate.Updater, cfg config) error {
+	if cfg.WorkDir == "" {
+		return errors.New("-sandbox needs -work-dir")
+	}
+	u.Staged = true
+	writable := []string{cfg.WorkDir}
+	if cfg.StateDir != "" {
+		writable = append(writable, cfg.StateDir)
+	}
+	if cfg.Audit != nil {
+		writable = append(writable, filepath.Dir(cfg.Audit.Path))
+	}
+	for _, dir := range writable {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("sandbox: %w", err)
+		}
+	}
+	if err := sandbox.Restrict(writable...); err != nil {
+		return fmt.Errorf("sandbox: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/msmania/updater/selfupdate"
+)
+
+// onHangup handles SIGHUP: it reloads the config file and then installs
+// the staged release, if any, or checks for a newer one right away,
+// calling exit to restart into an installed release.
+func onHangup(u *selfupdate.Updater, reload func() (config, error), exit func()) {
+	reloadConfig(u, reload)
+	ctx := selfupdate.WithAuditSource(context.Background(), "sighup")
+	tag, err := u.ActivateStaged(ctx)
+	if err != nil {
+		log.Printf("staged update: %v", err)
+	} else if tag != "" {
+		log.Printf("Restarting into the staged version %s", tag)
+		exit()
+		return
+	}
+	info, err := u.Update(ctx)
+	switch {
+	case info.Decision == selfupdate.DecisionUpgraded:
+		exit()
+	case info.Decision == selfupdate.DecisionStaged:
+		log.Printf("SIGHUP: %s staged; send SIGHUP again or restart to install it", info.Remote)
+	case err == nil, errors.Is(err, selfupdate.ErrAlreadyLatest), errors.Is(err, selfupdate.ErrNoRelease),
+		errors.Is(err, selfupdate.ErrDeferred):
+		log.Printf("SIGHUP: update check: %s", info.Decision)
+	default:
+		log.Printf("SIGHUP: update check: %v", err)
+	}
+}
+
+// reloadConfig applies the settings of the reloaded config file that can
+// change at run time; see applyConfig.
+func reloadConfig(u *selfupdate.Updater, reload func() (config, error)) error {
+	cfg, err := reload()
+	if err != nil {
+		log.Printf("config reload failed, keeping the old settings: %v", err)
+		return err
+	}
+	if err := u.Reconfigure(func(u *selfupdate.Updater) { applyConfig(u, cfg) }); err != nil {
+		log.Printf("config reload failed: %v", err)
+		return err
+	}
+	switch c := cfg.Changes; {
+	case len(c.Live) == 0 && len(c.Restart) == 0:
+		log.Printf("Config reloaded, nothing changed")
+	case len(c.Live) > 0:
+		log.Printf("Config reloaded, applied %s", flagList(c.Live))
+	}
+	if c := cfg.Changes.Restart; len(c) > 0 {
+		log.Printf("WARNING: the config changes %s only take effect after a restart", flagList(c))
+	}
+	return nil
+}
+
+// flagList formats flag names as "-a, -b".
+func flagList(names []string) string {
+	return "-" + strings.Join(names, ", -")
+}
+
+// dumpState logs the updater status and the last recorded update, for
+// SIGUSR1.
+func dumpState(u *selfupdate.Updater) {
+	st, _ := json.Marshal(u.Status())
+	log.Printf("updater status: %s", st)
+	if h := u.History(); len(h) > 0 {
+		last, _ := json.Marshal(h[0])
+		log.Printf("last update: %s", last)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package main
+
+import "github.com/msmania/updater/selfupdate"
+
+// handleSignals does nothing where SIGHUP and SIGUSR1 do not exist; use
+// the /update endpoints instead.
+func handleSignals(u *selfupdate.Updater, reload func() (config, error), exit func(), lf *logFile) {}
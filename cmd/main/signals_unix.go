@@ -0,0 +1,36 @@
+//go:build unix
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/msmania/updater/selfupdate"
+)
+
+// handleSignals runs onHangup on SIGHUP and dumpState on SIGUSR1, and
+// reopens lf, if any, on SIGUSR2.
+func handleSignals(u *selfupdate.Updater, reload func() (config, error), exit func(), lf *logFile) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		for sig := range ch {
+			switch sig {
+			case syscall.SIGUSR1:
+				dumpState(u)
+			case syscall.SIGUSR2:
+				if lf == nil {
+					continue
+				}
+				if err := lf.Reopen(); err != nil {
+					fmt.Fprintf(os.Stderr, "cannot reopen %s: %v\n", lf.path, err)
+				}
+			default:
+				onHangup(u, reload, exit)
+			}
+		}
+	}()
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// uiFiles is the dashboard served at /ui/. It calls the /api/v1/update API, so
+// it is only as protected as that API.
+//
+//go:embed ui
+var uiFiles embed.FS
+
+// uiHandler serves the dashboard below /ui/.
+func uiHandler() http.Handler {
+	sub, err := fs.Sub(uiFiles, "ui")
+	if err != nil {
+		panic(err)
+	}
+	return http.StripPrefix("/ui", http.FileServerFS(sub))
+}
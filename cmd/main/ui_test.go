@@ -0,0 +1,24 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/msmania/updater/selfupdate"
+)
+
+func Test_uiHandler(t *testing.T) {
+	mux := newServeMux(&selfupdate.Updater{}, adminACL{})
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/ui/", nil))
+	if rec.Code != 200 || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") ||
+		!strings.Contains(rec.Body.String(), `fetch(api + path`) {
+		t.Errorf("dashboard not served: %d %s", rec.Code, rec.Header())
+	}
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/ui", nil))
+	if rec.Header().Get("Location") != "/ui/" {
+		t.Error("expected a redirect to /ui/, got", rec.Code)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/msmania/updater/selfupdate"
+)
+
+// effectiveConfig returns the values of the flags of fs, once the
+// environment and config file have been applied, in the format of a
+// config file: booleans as JSON booleans and all other values as strings.
+// -config itself is left out, and the -socks5 password masked.
+func effectiveConfig(fs *flag.FlagSet) map[string]any {
+	values := map[string]any{}
+	fs.VisitAll(func(f *flag.Flag) {
+		switch {
+		case f.Name == "config":
+		case f.Name == "socks5" && f.Value.String() != "":
+			values[f.Name] = "invalid"
+			if u, err := parseSOCKS5(f.Value.String()); err == nil {
+				values[f.Name] = strings.TrimPrefix(u.Redacted(), "socks5://")
+			}
+		case isBoolFlag(f):
+			values[f.Name] = f.Value.String() == "true"
+		default:
+			values[f.Name] = f.Value.String()
+		}
+	})
+	return values
+}
+
+// printEffectiveConfig writes effectiveConfig(fs) as indented JSON.
+func printEffectiveConfig(w io.Writer, fs *flag.FlagSet) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(effectiveConfig(fs))
+}
+
+// checkReleaseSource verifies that u reaches its release source and finds
+// the assets to install, reporting the result to w.
+func checkReleaseSource(ctx context.Context, w io.Writer, u *selfupdate.Updater) error {
+	tag, asset, err := u.Resolve(ctx)
+	if tag == "" {
+		return fmt.Errorf("release source %s/%s: %w", u.Owner, u.Repo, err)
+	}
+	fmt.Fprintf(w, "Release source %s/%s: %s on channel %s\n", u.Owner, u.Repo, tag, u.Channel)
+	if asset != nil {
+		fmt.Fprintf(w, "Asset: %s (%d bytes)\n", asset.Name, asset.Size)
+	}
+	if err != nil {
+		return fmt.Errorf("release %s: %w", tag, err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/msmania/updater/selfupdate"
+	"github.com/msmania/updater/selfupdate/selfupdatetest"
+)
+
+func Test_effectiveConfig(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	addUpdaterFlags(fs)
+	fs.Parse([]string{"-channel", "beta", "-allow-major-upgrade", "-download-connections", "4",
+		"-socks5", "tor:s3cret@127.0.0.1:9050"})
+	var b bytes.Buffer
+	if err := printEffectiveConfig(&b, fs); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"channel": "beta"`, `"allow-major-upgrade": true`, `"download-connections": "4"`} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("%s missing from %s", want, b.String())
+		}
+	}
+	if strings.Contains(b.String(), "s3cret") || !strings.Contains(b.String(), `"socks5": "tor:xxxxx@127.0.0.1:9050"`) {
+		t.Errorf("-socks5 password not masked in %s", b.String())
+	}
+	if strings.Contains(b.String(), `"config"`) {
+		t.Error("-config must not be part of the effective config")
+	}
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fs2 := flag.NewFlagSet("test", flag.ContinueOnError)
+	f2 := addUpdaterFlags(fs2)
+	fs2.Parse([]string{"-config", path})
+	cfg, err := f2.load(fs2)
+	if err != nil {
+		t.Fatal("effective config is not a valid config file:", err)
+	}
+	if cfg.Channel != selfupdate.ChannelBeta || !cfg.AllowMajorUpgrade || cfg.Connections != 4 {
+		t.Errorf("effective config does not round-trip: %+v", cfg)
+	}
+}
+
+func Test_checkReleaseSource(t *testing.T) {
+	skipIfDisabled(t)
+	srv := selfupdatetest.NewServer("owner", "app",
+		selfupdatetest.Release{Tag: "v1.1.0", Assets: []selfupdatetest.Asset{{Name: "app-bin", Content: []byte("v1.1")}}},
+	)
+	defer srv.Close()
+	u := &selfupdate.Updater{Owner: "owner", Repo: "app", AssetName: "app-bin", APIURL: srv.URL}
+	var b bytes.Buffer
+	if err := checkReleaseSource(context.Background(), &b, u); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "v1.1.0") || !strings.Contains(b.String(), "Asset: app-bin (4 bytes)") {
+		t.Error("unexpected report: " + b.String())
+	}
+
+	u.ChecksumAsset, u.SBOMAsset = "checksums.txt", "sbom.json"
+	err := checkReleaseSource(context.Background(), &b, u)
+	if !errors.Is(err, selfupdate.ErrNoAsset) || !strings.Contains(err.Error(), "checksums.txt") ||
+		!strings.Contains(err.Error(), "sbom.json") {
+		t.Error("expected both missing assets to be reported, got", err)
+	}
+
+	u.AssetName = "app-typo"
+	if err := checkReleaseSource(context.Background(), &b, u); !errors.Is(err, selfupdate.ErrNoAsset) {
+		t.Error("expected ErrNoAsset, got", err)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/msmania/updater/selfupdate"
+)
+
+// reportInstallCheck prints the result of verify. Like reportUpdate, it
+// returns errReported for a failure already written as JSON.
+func reportInstallCheck(w io.Writer, path string, c *selfupdate.InstallCheck, err error, asJSON bool) error {
+	if asJSON {
+		out := struct {
+			File string `json:"file"`
+			*selfupdate.InstallCheck
+			Error string `json:"error,omitempty"`
+		}{File: path, InstallCheck: c}
+		if c == nil {
+			out.InstallCheck = &selfupdate.InstallCheck{}
+		}
+		if err != nil {
+			out.Error = err.Error()
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if eerr := enc.Encode(out); eerr != nil {
+			return eerr
+		}
+		if err != nil {
+			return errReported
+		}
+		return nil
+	}
+	if c == nil || c.Installed == "" {
+		return err
+	}
+	fmt.Fprintf(w, "release:   %s (%s", c.Tag, c.Asset)
+	if c.Digest != "" {
+		fmt.Fprintf(w, ", %s", c.Digest)
+	}
+	fmt.Fprintf(w, ")\npublished: %s\ninstalled: %s %s\n", c.Published, c.Installed, path)
+	if !c.Match {
+		fmt.Fprintln(w, "result:    MISMATCH")
+		return errReported
+	}
+	fmt.Fprintln(w, "result:    match")
+	return err
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/msmania/updater/selfupdate"
+)
+
+func Test_reportInstallCheck(t *testing.T) {
+	c := &selfupdate.InstallCheck{Tag: "v1.6.2", Asset: "app.tar.gz", Digest: "sha256:00",
+		Published: "aa", Installed: "aa", Match: true}
+	var b strings.Builder
+	if err := reportInstallCheck(&b, "/usr/bin/app", c, nil, false); err != nil ||
+		!strings.Contains(b.String(), "installed: aa /usr/bin/app\n") || !strings.HasSuffix(b.String(), "result:    match\n") {
+		t.Errorf("unexpected output %q: %v", b.String(), err)
+	}
+
+	c.Installed, c.Match = "bb", false
+	mismatch := selfupdate.ErrChecksumMismatch
+	b.Reset()
+	if err := reportInstallCheck(&b, "/usr/bin/app", c, mismatch, false); !errors.Is(err, errReported) ||
+		!strings.Contains(b.String(), "MISMATCH") {
+		t.Errorf("unexpected output %q: %v", b.String(), err)
+	}
+
+	b.Reset()
+	err := reportInstallCheck(&b, "/usr/bin/app", c, mismatch, true)
+	var out struct {
+		File  string `json:"file"`
+		Match bool   `json:"match"`
+		Error string `json:"error"`
+	}
+	if !errors.Is(err, errReported) || json.Unmarshal([]byte(b.String()), &out) != nil ||
+		out.File != "/usr/bin/app" || out.Match || out.Error == "" {
+		t.Errorf("unexpected JSON %s: %v", b.String(), err)
+	}
+
+	failure := errors.New("no release")
+	b.Reset()
+	if err := reportInstallCheck(&b, "/usr/bin/app", nil, failure, false); err != failure || b.Len() != 0 {
+		t.Errorf("unexpected output %q: %v", b.String(), err)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/msmania/updater/selfupdate"
+)
+
+// configWatcher reloads the configuration when the files behind it
+// change, for mounted config maps and secrets that are replaced without
+// a SIGHUP. It watches the directories holding the files rather than the
+// files themselves, whose watches are lost when such mounts swap the
+// symlink behind them, and reloads once the directories have been quiet
+// for a while, so that files written in several steps are read complete.
+type configWatcher struct {
+	files  func() []string // the files to watch; see updaterFlags.watchedFiles
+	reload func() error
+	// applied is the fingerprint of the files of the running
+	// configuration.
+	applied string
+}
+
+func newConfigWatcher(files func() []string, reload func() error) *configWatcher {
+	return &configWatcher{files: files, reload: reload, applied: fingerprint(files())}
+}
+
+// run watches the files until ctx is done and checks them once no event
+// arrived for debounce.
+func (w *configWatcher) run(ctx context.Context, debounce time.Duration) error {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer fsw.Close()
+	w.watch(fsw)
+	settled := time.NewTimer(debounce)
+	settled.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			settled.Stop()
+			return ctx.Err()
+		case _, ok := <-fsw.Events:
+			if !ok {
+				return nil
+			}
+			settled.Reset(debounce)
+		case err, ok := <-fsw.Errors:
+			if !ok {
+				return nil
+			}
+			log.Printf("watching configuration files: %v", err)
+		case <-settled.C:
+			if w.check() {
+				settled.Reset(debounce)
+			}
+			// The reloaded configuration may name other files.
+			w.watch(fsw)
+		}
+	}
+}
+
+// watch adds the directories of the files, and of the files their
+// symlinks point to, to fsw. Directories that do not exist yet are
+// skipped until the next call.
+func (w *configWatcher) watch(fsw *fsnotify.Watcher) {
+	for _, name := range w.files() {
+		dirs := []string{filepath.Dir(name)}
+		if target, err := filepath.EvalSymlinks(name); err == nil {
+			dirs = append(dirs, filepath.Dir(target))
+		}
+		for _, dir := range dirs {
+			fsw.Add(dir) // adding a watched directory again is a no-op
+		}
+	}
+}
+
+// check reloads the configuration if the files changed, and reports
+// whether to check again because the reload was refused during an
+// update. A reload that fails for an invalid file keeps the old settings
+// until the files change again.
+func (w *configWatcher) check() (retry bool) {
+	if fingerprint(w.files()) == w.applied {
+		return false
+	}
+	log.Printf("Configuration files changed, reloading")
+	if err := w.reload(); errors.Is(err, selfupdate.ErrBusy) {
+		return true
+	}
+	w.applied = fingerprint(w.files())
+	return false
+}
+
+// fingerprint hashes the names and contents of files; missing files
+// count as empty.
+func fingerprint(files []string) string {
+	h := sha256.New()
+	for _, name := range files {
+		data, _ := os.ReadFile(name)
+		sum := sha256.Sum256(data)
+		h.Write([]byte(name + "\x00"))
+		h.Write(sum[:])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/msmania/updater/selfupdate"
+)
+
+func Test_configWatcher(t *testing.T) {
+	dir := t.TempDir()
+	config, keyring := filepath.Join(dir, "config.json"), filepath.Join(dir, "keyring.json")
+	os.WriteFile(config, []byte(`{"channel": "stable"}`), 0o644)
+	files := []string{config}
+	reloads := make(chan struct{}, 10)
+	reloadErr := make(chan error, 10)
+	w := newConfigWatcher(func() []string { return files }, func() error {
+		files = []string{config, keyring} // named by the reloaded file
+		reloads <- struct{}{}
+		select {
+		case err := <-reloadErr:
+			return err
+		default:
+			return nil
+		}
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.run(ctx, 50*time.Millisecond) }()
+	time.Sleep(50 * time.Millisecond) // let run add its watches
+	verify := func(want int) {
+		t.Helper()
+		for i := 0; i < want; i++ {
+			select {
+			case <-reloads:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("%d reloads, want %d", i, want)
+			}
+		}
+		select {
+		case <-reloads:
+			t.Fatalf("more than %d reloads", want)
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+
+	// Writes in quick succession are reloaded once.
+	os.WriteFile(config, []byte(`{"channel": "beta"}`), 0o644)
+	os.WriteFile(config, []byte(`{"channel": "beta", "keyring": "keyring.json"}`), 0o644)
+	verify(1)
+	os.Chtimes(config, time.Now(), time.Now()) // an event without a change
+	verify(0)
+
+	reloadErr <- selfupdate.ErrBusy
+	os.WriteFile(keyring, []byte(`{}`), 0o644)
+	verify(2) // retried after an update
+	reloadErr <- os.ErrNotExist
+	os.WriteFile(keyring, []byte(`{"keys": []}`), 0o644)
+	verify(1) // an invalid file waits for the next change
+
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("run returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("run did not stop")
+	}
+}
+
+func Test_configWatcher_symlinkSwap(t *testing.T) {
+	// A mounted secret: config.json -> ..data/config.json, with ..data a
+	// symlink to a versioned directory that is replaced by a rename.
+	dir := t.TempDir()
+	write := func(version, content string) {
+		t.Helper()
+		os.Mkdir(filepath.Join(dir, version), 0o755)
+		os.WriteFile(filepath.Join(dir, version, "config.json"), []byte(content), 0o644)
+		if err := os.Symlink(version, filepath.Join(dir, "..data_tmp")); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("v1", `{"channel": "stable"}`)
+	config := filepath.Join(dir, "config.json")
+	if err := os.Symlink(filepath.Join("..data", "config.json"), config); err != nil {
+		t.Skip(err)
+	}
+	reloads := make(chan struct{}, 10)
+	w := newConfigWatcher(func() []string { return []string{config} }, func() error {
+		reloads <- struct{}{}
+		return nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.run(ctx, 50*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+
+	write("v2", `{"channel": "beta"}`)
+	os.RemoveAll(filepath.Join(dir, "v1"))
+	select {
+	case <-reloads:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the swapped secret was not reloaded")
+	}
+}
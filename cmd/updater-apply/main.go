@@ -0,0 +1,18 @@
+// Command updater-apply installs a release file, verified by an
+// unprivileged updater, over a root-owned executable listed in
+// /etc/updater/apply-targets. See package apply.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/msmania/updater/apply"
+)
+
+func main() {
+	if err := apply.Run(apply.DefaultAllowlist, os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "updater-apply:", err)
+		os.Exit(1)
+	}
+}
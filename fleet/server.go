@@ -0,0 +1,227 @@
+// Package fleet implements a central update-control server. Agents (an
+// selfupdate.Updater with Fleet.URL set) check in with their version and
+// receive a selfupdate.FleetManifest telling them which release to run,
+// whether they are part of the current rollout wave, and whether updates
+// are paused. Operators steer the fleet through an admin REST API.
+package fleet
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/msmania/updater/selfupdate"
+)
+
+// Server serves the agent and admin APIs:
+//
+//	POST   /v1/checkin                  agents: check in, get the manifest
+//	POST   /v1/report                   agents: an InventoryReport
+//	GET    /v1/admin/state              the whole State
+//	GET    /v1/admin/agents             agents, sorted by ID
+//	PUT    /v1/admin/channels/{name}    set a Channel policy
+//	DELETE /v1/admin/channels/{name}    remove a Channel policy
+//	PUT    /v1/admin/pause              {"paused": bool}, the kill switch
+//
+// Admin requests need "Authorization: Bearer <AdminToken>"; without an
+// AdminToken the admin API is disabled.
+type Server struct {
+	Store      Store
+	AdminToken string
+
+	mu    sync.Mutex
+	state State
+	now   func() time.Time
+}
+
+// NewServer returns a Server over the State in store.
+func NewServer(store Store, adminToken string) (*Server, error) {
+	state, err := store.Load()
+	if err != nil {
+		return nil, fmt.Errorf("cannot load fleet state: %w", err)
+	}
+	if state.Channels == nil {
+		state.Channels = map[string]Channel{}
+	}
+	if state.Agents == nil {
+		state.Agents = map[string]Agent{}
+	}
+	return &Server{Store: store, AdminToken: adminToken, state: state, now: time.Now}, nil
+}
+
+// Handler returns the HTTP API.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/checkin", s.serveCheckin)
+	mux.HandleFunc("POST /v1/report", s.serveReport)
+	mux.Handle("GET /v1/admin/state", s.admin(s.serveState))
+	mux.Handle("GET /v1/admin/agents", s.admin(s.serveAgents))
+	mux.Handle("PUT /v1/admin/channels/{name}", s.admin(s.servePutChannel))
+	mux.Handle("DELETE /v1/admin/channels/{name}", s.admin(s.serveDeleteChannel))
+	mux.Handle("PUT /v1/admin/pause", s.admin(s.servePause))
+	return mux
+}
+
+// manifest computes the answer to a check-in. s.mu must be held.
+func (s *Server) manifest(c selfupdate.FleetCheckin) selfupdate.FleetManifest {
+	ch := s.state.Channels[string(c.Channel)]
+	m := selfupdate.FleetManifest{
+		Target:         ch.Target,
+		RolloutPercent: ch.RolloutPercent,
+		Paused:         s.state.Paused || ch.Paused,
+	}
+	m.Eligible = ch.Target != "" && bucket(c.Agent, ch.Target) < ch.RolloutPercent
+	return m
+}
+
+// bucket places agent in [0, 100) for target, so that each release is
+// rolled out to a different first wave.
+func bucket(agent, target string) int {
+	h := fnv.New32a()
+	h.Write([]byte(agent + "/" + target))
+	return int(h.Sum32() % 100)
+}
+
+func (s *Server) serveCheckin(w http.ResponseWriter, r *http.Request) {
+	var c selfupdate.FleetCheckin
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&c); err != nil || c.Agent == "" {
+		http.Error(w, "invalid check-in", http.StatusBadRequest)
+		return
+	}
+	if c.Channel == "" {
+		c.Channel = selfupdate.ChannelStable
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := s.state.Agents[c.Agent]
+	a.ID, a.Version, a.Channel, a.LastSeen = c.Agent, c.Version, c.Channel, s.now().UTC()
+	s.state.Agents[c.Agent] = a
+	if err := s.Store.Save(s.state); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.manifest(c))
+}
+
+func (s *Server) serveReport(w http.ResponseWriter, r *http.Request) {
+	var rep selfupdate.InventoryReport
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&rep); err != nil || rep.Agent == "" {
+		http.Error(w, "invalid report", http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.Agents[rep.Agent] = Agent{
+		ID:            rep.Agent,
+		Version:       rep.Version,
+		Channel:       rep.Channel,
+		LastSeen:      s.now().UTC(),
+		Hostname:      rep.Hostname,
+		Platform:      rep.Platform,
+		LastResult:    rep.LastResult,
+		LastError:     rep.LastError,
+		StartedAt:     rep.StartedAt,
+		UptimeSeconds: rep.UptimeSeconds,
+	}
+	if err := s.Store.Save(s.state); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// admin guards an admin handler with the bearer token.
+func (s *Server) admin(h http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if s.AdminToken == "" || !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.AdminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		h(w, r)
+	})
+}
+
+func (s *Server) serveState(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.state)
+}
+
+func (s *Server) serveAgents(w http.ResponseWriter, r *http.Request) {
+	agents := make([]Agent, 0, len(s.state.Agents))
+	for _, a := range s.state.Agents {
+		agents = append(agents, a)
+	}
+	slices.SortFunc(agents, func(a, b Agent) int { return strings.Compare(a.ID, b.ID) })
+	writeJSON(w, http.StatusOK, agents)
+}
+
+func (s *Server) servePutChannel(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if _, err := selfupdate.ParseChannel(name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var ch Channel
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&ch); err != nil {
+		http.Error(w, "invalid channel: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if ch.RolloutPercent < 0 || ch.RolloutPercent > 100 {
+		http.Error(w, "rollout_percent must be within 0-100", http.StatusBadRequest)
+		return
+	}
+	if ch.Target != "" && !selfupdate.ParseVersion(ch.Target).Parsed {
+		http.Error(w, fmt.Sprintf("invalid target version %q", ch.Target), http.StatusBadRequest)
+		return
+	}
+	s.update(w, func() { s.state.Channels[name] = ch }, ch)
+}
+
+func (s *Server) serveDeleteChannel(w http.ResponseWriter, r *http.Request) {
+	s.update(w, func() { delete(s.state.Channels, r.PathValue("name")) }, nil)
+}
+
+func (s *Server) servePause(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Paused bool `json:"paused"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.update(w, func() { s.state.Paused = req.Paused }, req)
+}
+
+// update applies fn to the state, persists it and answers with v, or 204
+// if v is nil. s.mu must be held.
+func (s *Server) update(w http.ResponseWriter, fn func(), v any) {
+	prev := s.state
+	prev.Channels = maps.Clone(s.state.Channels)
+	fn()
+	if err := s.Store.Save(s.state); err != nil {
+		s.state = prev
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if v == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
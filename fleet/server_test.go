@@ -0,0 +1,117 @@
+package fleet
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/msmania/updater/selfupdate"
+)
+
+func request(t *testing.T, s *Server, method, path, token, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func checkin(t *testing.T, s *Server, agent, version string) selfupdate.FleetManifest {
+	t.Helper()
+	rec := request(t, s, "POST", "/v1/checkin", "",
+		`{"agent": "`+agent+`", "version": "`+version+`", "channel": "stable"}`)
+	var m selfupdate.FleetManifest
+	if err := json.Unmarshal(rec.Body.Bytes(), &m); rec.Code != 200 || err != nil {
+		t.Fatalf("check-in failed: %d %s", rec.Code, rec.Body.String())
+	}
+	return m
+}
+
+func Test_Server(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fleet.json")
+	s, err := NewServer(FileStore{Path: path}, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m := checkin(t, s, "a", "v1.0.0"); m.Target != "" || m.Eligible || m.Paused {
+		t.Errorf("unexpected manifest without policy %+v", m)
+	}
+
+	verifyStatus := func(method, path, token, body string, want int) {
+		if rec := request(t, s, method, path, token, body); rec.Code != want {
+			t.Errorf("%s %s: got %d, want %d (%s)", method, path, rec.Code, want, rec.Body.String())
+		}
+	}
+	verifyStatus("GET", "/v1/admin/agents", "", "", 401)
+	verifyStatus("GET", "/v1/admin/agents", "wrong", "", 401)
+	verifyStatus("PUT", "/v1/admin/channels/nightly", "secret", `{"target": "v1.1.0"}`, 400)
+	verifyStatus("PUT", "/v1/admin/channels/stable", "secret", `{"target": "1.1"}`, 400)
+	verifyStatus("PUT", "/v1/admin/channels/stable", "secret", `{"target": "v1.1.0", "rollout_percent": 101}`, 400)
+	verifyStatus("PUT", "/v1/admin/channels/stable", "secret", `{"target": "v1.1.0", "rollout_percent": 0}`, 200)
+
+	if m := checkin(t, s, "a", "v1.0.0"); m.Target != "v1.1.0" || m.Eligible {
+		t.Errorf("0%% rollout must not be eligible: %+v", m)
+	}
+	verifyStatus("PUT", "/v1/admin/channels/stable", "secret", `{"target": "v1.1.0", "rollout_percent": 100}`, 200)
+	if m := checkin(t, s, "a", "v1.0.0"); !m.Eligible {
+		t.Errorf("100%% rollout must be eligible: %+v", m)
+	}
+
+	// Roughly half of the agents fall in a 50% wave.
+	verifyStatus("PUT", "/v1/admin/channels/stable", "secret", `{"target": "v1.1.0", "rollout_percent": 50}`, 200)
+	eligible := 0
+	for i := range 200 {
+		if checkin(t, s, "agent-"+string(rune('a'+i%26))+strings.Repeat("x", i/26), "v1.0.0").Eligible {
+			eligible++
+		}
+	}
+	if eligible < 60 || eligible > 140 {
+		t.Errorf("%d of 200 agents eligible for a 50%% rollout", eligible)
+	}
+
+	verifyStatus("PUT", "/v1/admin/pause", "secret", `{"paused": true}`, 200)
+	if m := checkin(t, s, "a", "v1.0.0"); !m.Paused {
+		t.Error("kill switch not applied")
+	}
+
+	// State survives a restart.
+	s, err = NewServer(FileStore{Path: path}, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := request(t, s, "GET", "/v1/admin/state", "secret", "")
+	var st State
+	json.Unmarshal(rec.Body.Bytes(), &st)
+	if !st.Paused || st.Channels["stable"].RolloutPercent != 50 || st.Agents["a"].Version != "v1.0.0" {
+		t.Errorf("state not persisted: %s", rec.Body.String())
+	}
+	verifyStatus("DELETE", "/v1/admin/channels/stable", "secret", "", 204)
+	if m := checkin(t, s, "a", "v1.0.0"); m.Target != "" {
+		t.Error("channel not deleted")
+	}
+
+	rec = request(t, s, "POST", "/v1/report", "",
+		`{"agent": "a", "hostname": "host-a", "version": "v1.1.0", "platform": "linux/arm64", "last_result": "upgraded", "uptime_seconds": 42}`)
+	if rec.Code != 204 {
+		t.Error("report rejected:", rec.Code)
+	}
+	verifyStatus("POST", "/v1/report", "", `{"version": "v1.1.0"}`, 400)
+	checkin(t, s, "a", "v1.1.0")
+	rec = request(t, s, "GET", "/v1/admin/agents", "secret", "")
+	var agents []Agent
+	json.Unmarshal(rec.Body.Bytes(), &agents)
+	if len(agents) == 0 || agents[0].ID != "a" || agents[0].Hostname != "host-a" ||
+		agents[0].Platform != "linux/arm64" || agents[0].UptimeSeconds != 42 {
+		t.Errorf("report not in the inventory: %s", rec.Body.String())
+	}
+
+	if _, err := NewServer(&MemoryStore{}, ""); err != nil {
+		t.Fatal(err)
+	}
+}
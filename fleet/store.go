@@ -0,0 +1,109 @@
+package fleet
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/msmania/updater/selfupdate"
+)
+
+// State is everything the fleet server knows.
+type State struct {
+	// Paused is the global kill switch.
+	Paused   bool               `json:"paused"`
+	Channels map[string]Channel `json:"channels"`
+	Agents   map[string]Agent   `json:"agents"`
+}
+
+// Channel is the rollout policy of a release channel.
+type Channel struct {
+	// Target is the release agents on the channel should run.
+	Target string `json:"target"`
+	// RolloutPercent is the share of agents (0-100) that may install
+	// Target now. Each agent falls in a fixed bucket per target.
+	RolloutPercent int `json:"rollout_percent"`
+	// Paused stops installs on this channel only.
+	Paused bool `json:"paused"`
+}
+
+// Agent is what the server last heard from an agent: its check-in and,
+// if it sends them, its inventory report.
+type Agent struct {
+	ID       string             `json:"id"`
+	Version  string             `json:"version"`
+	Channel  selfupdate.Channel `json:"channel"`
+	LastSeen time.Time          `json:"last_seen"`
+
+	Hostname      string    `json:"hostname,omitempty"`
+	Platform      string    `json:"platform,omitempty"`
+	LastResult    string    `json:"last_result,omitempty"`
+	LastError     string    `json:"last_error,omitempty"`
+	StartedAt     time.Time `json:"started_at,omitzero"`
+	UptimeSeconds int64     `json:"uptime_seconds,omitempty"`
+}
+
+// Store persists the State.
+type Store interface {
+	Load() (State, error)
+	Save(State) error
+}
+
+// MemoryStore keeps the State in memory only.
+type MemoryStore struct {
+	mu    sync.Mutex
+	state State
+}
+
+func (m *MemoryStore) Load() (State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state, nil
+}
+
+func (m *MemoryStore) Save(s State) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state = s
+	return nil
+}
+
+// FileStore keeps the State in a JSON file, replaced atomically on every
+// change. A missing file is an empty State.
+type FileStore struct {
+	Path string
+}
+
+func (f FileStore) Load() (State, error) {
+	var s State
+	data, err := os.ReadFile(f.Path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	return s, json.Unmarshal(data, &s)
+}
+
+func (f FileStore) Save(s State) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.Path), filepath.Base(f.Path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.Path)
+}
@@ -0,0 +1,20 @@
+// Package sandbox confines the process that downloads, parses and
+// extracts a release, so that an exploit in the HTTP, archive or
+// decompression code cannot write outside the staging area. On Linux it
+// uses Landlock to limit file system writes and a seccomp filter to deny
+// program execution and other system calls an updater never needs.
+package sandbox
+
+// Restrict confines the calling process for good: afterwards it can
+// create, modify or remove files only below the writable directories, and
+// cannot execute programs or use the debugging, mounting and module
+// loading system calls. It applies to all threads and to child processes
+// and cannot be undone, so call it in a process that exits once the
+// restricted work is done. Reading files is not restricted.
+//
+// errors.ErrUnsupported is returned where the kernel lacks Landlock, the
+// binary uses cgo, or the platform is not Linux. The seccomp filter is
+// only installed on amd64 and arm64.
+func Restrict(writable ...string) error {
+	return restrict(writable)
+}
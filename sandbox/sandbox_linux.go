@@ -0,0 +1,165 @@
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// Landlock system calls, numbered alike on all architectures.
+const (
+	sysLandlockCreateRuleset = 444
+	sysLandlockAddRule       = 445
+	sysLandlockRestrictSelf  = 446
+
+	landlockCreateRulesetVersion = 1
+	landlockRulePathBeneath      = 1
+)
+
+// Landlock file system access rights. Only writes are handled, so reads
+// and directory listings stay allowed everywhere.
+const (
+	accessFSWriteFile  = 1 << 1
+	accessFSRemoveDir  = 1 << 4
+	accessFSRemoveFile = 1 << 5
+	accessFSMakeChar   = 1 << 6
+	accessFSMakeDir    = 1 << 7
+	accessFSMakeReg    = 1 << 8
+	accessFSMakeSock   = 1 << 9
+	accessFSMakeFifo   = 1 << 10
+	accessFSMakeBlock  = 1 << 11
+	accessFSMakeSym    = 1 << 12
+	accessFSRefer      = 1 << 13 // ABI 2
+	accessFSTruncate   = 1 << 14 // ABI 3
+
+	accessFSWrite = accessFSWriteFile | accessFSRemoveDir | accessFSRemoveFile |
+		accessFSMakeChar | accessFSMakeDir | accessFSMakeReg | accessFSMakeSock |
+		accessFSMakeFifo | accessFSMakeBlock | accessFSMakeSym
+)
+
+type landlockRulesetAttr struct {
+	handledAccessFS uint64
+}
+
+// landlockPathBeneathAttr is packed in C; its first 12 bytes match.
+type landlockPathBeneathAttr struct {
+	allowedAccess uint64
+	parentFD      int32
+}
+
+const prSetNoNewPrivs = 38
+
+func restrict(writable []string) error {
+	// Required for an unprivileged process to restrict itself.
+	if _, _, errno := syscall.AllThreadsSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno != 0 {
+		return unsupported("no_new_privs", errno)
+	}
+	if err := landlock(writable); err != nil {
+		return err
+	}
+	return seccomp()
+}
+
+// landlock allows writes only below the writable directories.
+func landlock(writable []string) error {
+	abi, _, errno := syscall.Syscall(sysLandlockCreateRuleset, 0, 0, landlockCreateRulesetVersion)
+	if errno != 0 {
+		return unsupported("landlock", errno)
+	}
+	access := uint64(accessFSWrite)
+	if abi >= 2 {
+		access |= accessFSRefer
+	}
+	if abi >= 3 {
+		access |= accessFSTruncate
+	}
+	attr := landlockRulesetAttr{handledAccessFS: access}
+	fd, _, errno := syscall.Syscall(sysLandlockCreateRuleset,
+		uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return fmt.Errorf("landlock: %w", errno)
+	}
+	defer syscall.Close(int(fd))
+	for _, dir := range writable {
+		d, err := os.Open(dir)
+		if err != nil {
+			return fmt.Errorf("landlock: %w", err)
+		}
+		rule := landlockPathBeneathAttr{allowedAccess: access, parentFD: int32(d.Fd())}
+		_, _, errno := syscall.Syscall6(sysLandlockAddRule, fd, landlockRulePathBeneath,
+			uintptr(unsafe.Pointer(&rule)), 0, 0, 0)
+		d.Close()
+		if errno != 0 {
+			return fmt.Errorf("landlock: %s: %w", dir, errno)
+		}
+	}
+	if _, _, errno := syscall.AllThreadsSyscall(sysLandlockRestrictSelf, fd, 0, 0); errno != 0 {
+		return unsupported("landlock", errno)
+	}
+	return nil
+}
+
+// Classic BPF and seccomp constants.
+const (
+	bpfLD  = 0x00
+	bpfW   = 0x00
+	bpfABS = 0x20
+	bpfJMP = 0x05
+	bpfJEQ = 0x10
+	bpfJGE = 0x30
+	bpfK   = 0x00
+	bpfRET = 0x06
+
+	seccompSetModeFilter   = 1
+	seccompFilterFlagTSync = 1
+	seccompRetAllow        = 0x7fff0000
+	seccompRetErrno        = 0x00050000
+)
+
+// seccomp makes the denied system calls fail with EPERM on all threads.
+func seccomp() error {
+	if sysSeccomp == 0 {
+		return nil
+	}
+	deny := seccompRetErrno | uint32(syscall.EPERM)
+	filter := []syscall.SockFilter{
+		{Code: bpfLD | bpfW | bpfABS, K: 4}, // seccomp_data.arch
+		{Code: bpfJMP | bpfJEQ | bpfK, Jt: 1, K: auditArch},
+		{Code: bpfRET | bpfK, K: deny},
+		{Code: bpfLD | bpfW | bpfABS, K: 0}, // seccomp_data.nr
+	}
+	if x32SyscallBit != 0 {
+		// x32 calls share the arch value but not the numbers; deny them all.
+		filter = append(filter,
+			syscall.SockFilter{Code: bpfJMP | bpfJGE | bpfK, Jf: 1, K: x32SyscallBit},
+			syscall.SockFilter{Code: bpfRET | bpfK, K: deny})
+	}
+	for _, nr := range deniedSyscalls {
+		filter = append(filter,
+			syscall.SockFilter{Code: bpfJMP | bpfJEQ | bpfK, Jf: 1, K: nr},
+			syscall.SockFilter{Code: bpfRET | bpfK, K: deny})
+	}
+	filter = append(filter, syscall.SockFilter{Code: bpfRET | bpfK, K: seccompRetAllow})
+	prog := syscall.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	r, _, errno := syscall.Syscall(sysSeccomp, seccompSetModeFilter, seccompFilterFlagTSync,
+		uintptr(unsafe.Pointer(&prog)))
+	if errno != 0 {
+		return fmt.Errorf("seccomp: %w", errno)
+	}
+	if r != 0 {
+		return fmt.Errorf("seccomp: cannot synchronize thread %d", r)
+	}
+	return nil
+}
+
+// unsupported wraps errno, reporting missing kernel support or a cgo
+// binary as errors.ErrUnsupported.
+func unsupported(what string, errno syscall.Errno) error {
+	switch errno {
+	case syscall.ENOSYS, syscall.EOPNOTSUPP:
+		return fmt.Errorf("%s: %w (%w)", what, errors.ErrUnsupported, errno)
+	}
+	return fmt.Errorf("%s: %w", what, errno)
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package sandbox
+
+import "errors"
+
+func restrict(writable []string) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,82 @@
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// Restrict cannot be undone, so Test_Restrict runs the checks in a child
+// process started with sandboxChildEnv set.
+const sandboxChildEnv = "SANDBOX_TEST_CHILD"
+
+func Test_Restrict(t *testing.T) {
+	if os.Getenv(sandboxChildEnv) != "" {
+		restrictChild()
+		return
+	}
+	allowed, denied := t.TempDir(), t.TempDir()
+	cmd := exec.Command(os.Args[0], "-test.run=^Test_Restrict$")
+	cmd.Env = append(os.Environ(), sandboxChildEnv+"="+allowed+string(os.PathListSeparator)+denied)
+	out, err := cmd.CombinedOutput()
+	if strings.Contains(string(out), "unsupported") {
+		t.Skipf("sandbox unavailable: %s", out)
+	}
+	if err != nil {
+		t.Fatalf("child failed: %v\n%s", err, out)
+	}
+
+	verify := func(path string, want bool) {
+		t.Helper()
+		if _, err := os.Stat(path); (err == nil) != want {
+			t.Errorf("%s exists = %v, want %v", path, err == nil, want)
+		}
+	}
+	verify(filepath.Join(allowed, "file"), true)
+	verify(filepath.Join(allowed, "dir"), true)
+	verify(filepath.Join(denied, "file"), false)
+}
+
+// restrictChild restricts itself and exits with a message on stdout when
+// the sandbox does not behave as expected.
+func restrictChild() {
+	dirs := filepath.SplitList(os.Getenv(sandboxChildEnv))
+	allowed, denied := dirs[0], dirs[1]
+	fail := func(format string, args ...any) {
+		fmt.Printf(format+"\n", args...)
+		os.Exit(1)
+	}
+	if err := Restrict(allowed); err != nil {
+		if errors.Is(err, errors.ErrUnsupported) {
+			fail("unsupported: %v", err)
+		}
+		fail("Restrict: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(allowed, "file"), []byte("x"), 0o644); err != nil {
+		fail("write allowed: %v", err)
+	}
+	if err := os.Mkdir(filepath.Join(allowed, "dir"), 0o755); err != nil {
+		fail("mkdir allowed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(denied, "file"), []byte("x"), 0o644); !errors.Is(err, os.ErrPermission) {
+		fail("write denied = %v, want permission error", err)
+	}
+	if _, err := os.ReadFile(os.Args[0]); err != nil {
+		fail("read: %v", err)
+	}
+	if runtime.GOARCH == "amd64" || runtime.GOARCH == "arm64" {
+		if err := exec.Command(os.Args[0], "-test.run=^$").Run(); !errors.Is(err, os.ErrPermission) {
+			fail("exec = %v, want permission error", err)
+		}
+	}
+	if err := x32Execve(); err != syscall.EPERM {
+		fail("x32 execve = %v, want EPERM", err)
+	}
+	os.Exit(0)
+}
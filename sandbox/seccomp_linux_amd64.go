@@ -0,0 +1,14 @@
+package sandbox
+
+const (
+	sysSeccomp = 317
+	auditArch  = 0xc000003e // AUDIT_ARCH_X86_64
+	// x32SyscallBit marks x32 ABI calls (__X32_SYSCALL_BIT), which carry
+	// AUDIT_ARCH_X86_64 too.
+	x32SyscallBit = 0x40000000
+)
+
+// deniedSyscalls: execve, execveat, ptrace, process_vm_writev, mount,
+// umount2, pivot_root, chroot, init_module, finit_module, delete_module,
+// kexec_load, bpf, unshare, setns and keyctl.
+var deniedSyscalls = []uint32{59, 322, 101, 311, 165, 166, 155, 161, 175, 313, 176, 246, 321, 272, 308, 250}
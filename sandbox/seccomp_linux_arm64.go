@@ -0,0 +1,13 @@
+package sandbox
+
+const (
+	sysSeccomp = 277
+	auditArch  = 0xc00000b7 // AUDIT_ARCH_AARCH64
+	// x32SyscallBit is zero: arm64 has no x32 ABI.
+	x32SyscallBit = 0
+)
+
+// deniedSyscalls: execve, execveat, ptrace, process_vm_writev, mount,
+// umount2, pivot_root, chroot, init_module, finit_module, delete_module,
+// kexec_load, bpf, unshare, setns and keyctl.
+var deniedSyscalls = []uint32{221, 281, 117, 271, 40, 39, 41, 51, 105, 273, 106, 104, 280, 97, 268, 219}
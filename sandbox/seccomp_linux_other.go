@@ -0,0 +1,12 @@
+//go:build linux && !amd64 && !arm64
+
+package sandbox
+
+// No seccomp filter is installed on other architectures.
+const (
+	sysSeccomp    = 0
+	auditArch     = 0
+	x32SyscallBit = 0
+)
+
+var deniedSyscalls []uint32
@@ -0,0 +1,10 @@
+//go:build !linux || !amd64
+
+package sandbox
+
+import "syscall"
+
+// x32Execve reports EPERM where there is no x32 ABI to test.
+func x32Execve() error {
+	return syscall.EPERM
+}
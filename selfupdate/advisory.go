@@ -0,0 +1,106 @@
+package selfupdate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// AdvisoryConfig enables a veto by an advisory feed speaking the OSV
+// query API (POST {"package": {...}, "version": ...}, answered with
+// {"vulns": [...]}), such as https://api.osv.dev/v1/query or an internal
+// feed. A version with any advisory is not installed, which stops a
+// rollout fleet-wide without republishing artifacts.
+type AdvisoryConfig struct {
+	// URL is the query endpoint. Empty disables the check.
+	URL string
+	// Package defaults to "github.com/<Owner>/<Repo>" and Ecosystem to
+	// "Go". Versions are sent without the "v" prefix.
+	Package   string
+	Ecosystem string
+	// FailOpen installs anyway, with a warning, when the feed cannot be
+	// queried. By default such an error fails the update.
+	FailOpen bool
+}
+
+// maxAdvisoryResponse bounds how much of an advisory response is read.
+const maxAdvisoryResponse = 4 << 20
+
+type osvQuery struct {
+	Package struct {
+		Name      string `json:"name"`
+		Ecosystem string `json:"ecosystem,omitempty"`
+	} `json:"package"`
+	Version string `json:"version"`
+}
+
+type osvResponse struct {
+	Vulns []struct {
+		ID      string `json:"id"`
+		Summary string `json:"summary"`
+	} `json:"vulns"`
+}
+
+// queryAdvisories returns the IDs of the advisories affecting version.
+func (f *fetcher) queryAdvisories(ctx context.Context, url string, q osvQuery) ([]string, error) {
+	body, err := json.Marshal(q)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := f.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer drainClose(resp.Body)
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+	var r osvResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxAdvisoryResponse)).Decode(&r); err != nil {
+		return nil, fmt.Errorf("invalid advisory response: %w", err)
+	}
+	ids := make([]string, len(r.Vulns))
+	for i, v := range r.Vulns {
+		ids[i] = v.ID
+	}
+	return ids, nil
+}
+
+// checkAdvisories vetoes tag if the advisory feed lists it.
+func (u *Updater) checkAdvisories(ctx context.Context, tag string) error {
+	cfg := u.Advisories
+	if cfg.URL == "" {
+		return nil
+	}
+	var q osvQuery
+	q.Package.Name = cfg.Package
+	if q.Package.Name == "" {
+		q.Package.Name = "github.com/" + u.Owner + "/" + u.Repo
+	}
+	q.Package.Ecosystem = cfg.Ecosystem
+	if q.Package.Ecosystem == "" {
+		q.Package.Ecosystem = "Go"
+	}
+	q.Version = strings.TrimPrefix(tag, "v")
+	ids, err := u.http().queryAdvisories(ctx, cfg.URL, q)
+	if err != nil {
+		if cfg.FailOpen {
+			u.logf("WARNING: advisory check of %s skipped: %v", tag, err)
+			return nil
+		}
+		return err
+	}
+	if len(ids) > 0 {
+		return fmt.Errorf("%w: %s is flagged by %s", ErrPolicyViolation, tag, strings.Join(ids, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package selfupdate_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/msmania/updater/selfupdate"
+	"github.com/msmania/updater/selfupdate/selfupdatetest"
+)
+
+func Test_Updater_advisories(t *testing.T) {
+	skipIfDisabled(t)
+	srv := selfupdatetest.NewServer("owner", "app",
+		selfupdatetest.Release{Tag: "v1.1.0", Assets: []selfupdatetest.Asset{{Name: "app-bin", Content: []byte("v1.1")}}},
+	)
+	defer srv.Close()
+	flagged := true
+	feed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if flagged {
+			w.Write([]byte(`{"vulns": [{"id": "OSV-2026-1"}]}`))
+			return
+		}
+		w.Write([]byte(`{"vulns": []}`))
+	}))
+	defer feed.Close()
+	u := newUpdater(t, srv, "v1.0.0")
+	u.Advisories.URL = feed.URL
+	ctx := context.Background()
+
+	info, err := u.Update(ctx)
+	if !errors.Is(err, selfupdate.ErrPolicyViolation) || info.Decision != selfupdate.DecisionPolicyBlocked {
+		t.Error("expected veto, got", info.Decision, err)
+	}
+	flagged = false
+	if _, err := u.Update(ctx); err != nil {
+		t.Error(err)
+	}
+}
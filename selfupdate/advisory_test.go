@@ -0,0 +1,46 @@
+package selfupdate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_checkAdvisories(t *testing.T) {
+	var got osvQuery
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		if got.Version == "1.2.0" {
+			w.Write([]byte(`{"vulns": [{"id": "GHSA-xxxx-yyyy-zzzz", "summary": "bad"}]}`))
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+	u := &Updater{Owner: "owner", Repo: "app", Advisories: AdvisoryConfig{URL: srv.URL}}
+	ctx := context.Background()
+
+	if err := u.checkAdvisories(ctx, "v1.1.0"); err != nil {
+		t.Error(err)
+	}
+	if got.Package.Name != "github.com/owner/app" || got.Package.Ecosystem != "Go" || got.Version != "1.1.0" {
+		t.Errorf("unexpected query %+v", got)
+	}
+	if err := u.checkAdvisories(ctx, "v1.2.0"); !errors.Is(err, ErrPolicyViolation) {
+		t.Error("expected ErrPolicyViolation, got", err)
+	}
+
+	dead := httptest.NewServer(nil)
+	dead.Close()
+	u.Advisories.URL = dead.URL
+	if err := u.checkAdvisories(ctx, "v1.1.0"); err == nil {
+		t.Error("unreachable feed must fail closed")
+	}
+	u.Advisories.FailOpen = true
+	if err := u.checkAdvisories(ctx, "v1.1.0"); err != nil {
+		t.Error("unreachable feed must be skipped with FailOpen:", err)
+	}
+}
@@ -0,0 +1,215 @@
+package selfupdate
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+)
+
+// Applier turns a verified artifact into the file at target. a.Path is a
+// staging file, in the same directory as target unless Updater.WorkDir is
+// set; the Applier takes ownership of it.
+type Applier interface {
+	Apply(ctx context.Context, a Artifact, target string) error
+}
+
+// applier returns u.Applier, or the default for the platform over u.FS.
+func (u *Updater) applier() Applier {
+	if u.Applier != nil {
+		return u.Applier
+	}
+	if runtime.GOOS == "windows" {
+		return WindowsTwoStep{FS: u.FS}
+	}
+	return AtomicRename{FS: u.FS}
+}
+
+// AtomicRename renames the artifact over target. It is atomic on POSIX
+// file systems and the default outside Windows. An artifact staged on
+// another file system (see Updater.WorkDir), whether detected up front or
+// by a failing rename, is copied next to target first, like CopyOverNFS
+// does.
+type AtomicRename struct {
+	// FS defaults to OSFS.
+	FS FS
+}
+
+func (r AtomicRename) Apply(ctx context.Context, a Artifact, target string) error {
+	fsys := orOS(r.FS)
+	_, onOS := fsys.(OSFS)
+	if onOS && filepath.Dir(a.Path) != filepath.Dir(target) {
+		if same, known := sameFileSystem(a.Path, filepath.Dir(target)); known && !same {
+			return CopyOverNFS{}.Apply(ctx, a, target)
+		}
+	}
+	err := fsys.Rename(a.Path, target)
+	if err == nil || filepath.Dir(a.Path) == filepath.Dir(target) {
+		return err
+	}
+	if onOS {
+		return CopyOverNFS{}.Apply(ctx, a, target)
+	}
+	if err := copyFileFS(fsys, a.Path, target); err != nil {
+		return err
+	}
+	fsys.Remove(a.Path)
+	return nil
+}
+
+// SymlinkSwitch keeps each release as "<target>-<tag>" in Dir (the
+// directory of target if empty) and atomically repoints the symlink
+// target at the new one, so the previous version stays on disk for a
+// rollback. Updater.Path must name the link itself, since os.Executable
+// resolves symlinks.
+type SymlinkSwitch struct {
+	Dir string
+}
+
+func (s SymlinkSwitch) Apply(_ context.Context, a Artifact, target string) error {
+	dir := s.Dir
+	if dir == "" {
+		dir = filepath.Dir(target)
+	}
+	versioned := filepath.Join(dir, filepath.Base(target)+"-"+a.Tag)
+	if err := os.Rename(a.Path, versioned); err != nil {
+		return err
+	}
+	link := target + ".link"
+	os.Remove(link)
+	if err := os.Symlink(versioned, link); err != nil {
+		return err
+	}
+	if err := os.Rename(link, target); err != nil {
+		os.Remove(link)
+		return err
+	}
+	return nil
+}
+
+// WindowsTwoStep moves the running executable aside to "<target>.old",
+// which Windows permits while it runs, and then moves the artifact into
+// place, restoring the original if that fails. The .old file is removed
+// by the next Apply; see also RemoveOld. It is the default on Windows.
+type WindowsTwoStep struct {
+	// FS defaults to OSFS.
+	FS FS
+}
+
+func (w WindowsTwoStep) Apply(_ context.Context, a Artifact, target string) error {
+	fsys := orOS(w.FS)
+	if filepath.Dir(a.Path) != filepath.Dir(target) {
+		next := target + ".new"
+		if err := copyFileFS(fsys, a.Path, next); err != nil {
+			return err
+		}
+		fsys.Remove(a.Path)
+		a.Path = next
+	}
+	old := target + ".old"
+	// A leftover from the previous update; it is no longer running.
+	fsys.Remove(old)
+	if err := fsys.Rename(target, old); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := fsys.Rename(a.Path, target); err != nil {
+		if rerr := fsys.Rename(old, target); rerr != nil {
+			return fmt.Errorf("%w (restoring %s: %v)", err, target, rerr)
+		}
+		return err
+	}
+	return nil
+}
+
+// RemoveOld deletes the "<path>.old" file left by WindowsTwoStep. Call it
+// on startup once the new executable runs.
+func RemoveOld(path string) error {
+	err := os.Remove(path + ".old")
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// CopyOverNFS copies the artifact next to target, flushes it to stable
+// storage and renames it into place. Use it when the staging file may be
+// on another file system, or on network file systems where a rename
+// without a prior fsync can expose a partially written file.
+type CopyOverNFS struct{}
+
+func (CopyOverNFS) Apply(_ context.Context, a Artifact, target string) error {
+	src, err := os.Open(a.Path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dir := filepath.Dir(target)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(target)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, src); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o755); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return err
+	}
+	syncDir(dir)
+	os.Remove(a.Path)
+	return nil
+}
+
+// syncDir flushes a directory entry change where the platform allows it.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// CommandApplier runs Command with the artifact, target and the hex
+// SHA-256 digest of the artifact appended as arguments, for installing
+// through a privileged helper when the service user cannot write the
+// directory of target, e.g. {"sudo", "-n", "/usr/local/libexec/updater-apply"}
+// (see package apply). Combine it with
+// Updater.WorkDir so that the service user needs no write access there.
+type CommandApplier struct {
+	Command []string
+}
+
+func (c CommandApplier) Apply(ctx context.Context, a Artifact, target string) error {
+	if len(c.Command) == 0 {
+		return fmt.Errorf("install command not set")
+	}
+	digest, err := fileSHA256(a.Path)
+	if err != nil {
+		return err
+	}
+	args := append(c.Command[1:len(c.Command):len(c.Command)], a.Path, target, hex.EncodeToString(digest))
+	out, err := exec.CommandContext(ctx, c.Command[0], args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %w: %s", c.Command[0], err, bytes.TrimSpace(out))
+	}
+	os.Remove(a.Path)
+	return nil
+}
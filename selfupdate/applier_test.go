@@ -0,0 +1,99 @@
+package selfupdate
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func Test_Applier(t *testing.T) {
+	setup := func() (Artifact, string) {
+		dir := t.TempDir()
+		target := filepath.Join(dir, "app")
+		staged := filepath.Join(dir, "app.new")
+		os.WriteFile(target, []byte("old"), 0o755)
+		os.WriteFile(staged, []byte("new"), 0o755)
+		return Artifact{Path: staged, Tag: "v1.1.0"}, target
+	}
+	verify := func(name string, ap Applier) string {
+		a, target := setup()
+		if err := ap.Apply(context.Background(), a, target); err != nil {
+			t.Error(name, err)
+			return target
+		}
+		if b, _ := os.ReadFile(target); string(b) != "new" {
+			t.Error(name, "target not replaced: "+string(b))
+		}
+		if _, err := os.Stat(a.Path); !os.IsNotExist(err) {
+			t.Error(name, "staging file left behind")
+		}
+		return target
+	}
+
+	verify("AtomicRename", AtomicRename{})
+	verify("CopyOverNFS", CopyOverNFS{})
+
+	target := verify("WindowsTwoStep", WindowsTwoStep{})
+	if b, _ := os.ReadFile(target + ".old"); string(b) != "old" {
+		t.Error("WindowsTwoStep must keep the old binary aside")
+	}
+	if err := RemoveOld(target); err != nil {
+		t.Error(err)
+	}
+	if _, err := os.Stat(target + ".old"); !os.IsNotExist(err) {
+		t.Error("RemoveOld left the old binary")
+	}
+
+	target = verify("SymlinkSwitch", SymlinkSwitch{})
+	if dest, err := os.Readlink(target); err != nil || dest != target+"-v1.1.0" {
+		t.Error("target must link to the versioned file", dest, err)
+	}
+
+	// Staged in Updater.WorkDir rather than next to target.
+	sameDir := setup
+	setup = func() (Artifact, string) {
+		a, target := sameDir()
+		staged := filepath.Join(t.TempDir(), "app.new")
+		os.Rename(a.Path, staged)
+		a.Path = staged
+		return a, target
+	}
+	verify("AtomicRename from WorkDir", AtomicRename{})
+	verify("WindowsTwoStep from WorkDir", WindowsTwoStep{})
+	if runtime.GOOS != "windows" {
+		verify("CommandApplier", CommandApplier{Command: []string{"sh", "-c", `[ ${#3} -eq 64 ] && cp "$1" "$2"`, "sh"}})
+	}
+	setup = sameDir
+
+	a, target := setup()
+	a.Path = filepath.Join(filepath.Dir(target), "missing")
+	if err := (WindowsTwoStep{}).Apply(context.Background(), a, target); err == nil {
+		t.Error("expected error for missing artifact")
+	}
+	if b, _ := os.ReadFile(target); string(b) != "old" {
+		t.Error("WindowsTwoStep must restore the original on failure")
+	}
+}
+
+func Test_sameFileSystem(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app")
+	os.WriteFile(file, nil, 0o644)
+	same, known := sameFileSystem(file, dir)
+	if !known {
+		t.Skip("file systems cannot be told apart on " + runtime.GOOS)
+	}
+	if !same {
+		t.Error("a file and its directory are on different file systems")
+	}
+	if runtime.GOOS == "linux" {
+		if same, known := sameFileSystem("/proc", dir); !known || same {
+			t.Errorf("/proc and %s: same %v, known %v", dir, same, known)
+		}
+	}
+	if _, known := sameFileSystem(filepath.Join(dir, "missing"), dir); known && runtime.GOOS != "windows" {
+		t.Error("a missing file has a known file system")
+	}
+}
@@ -0,0 +1,191 @@
+package selfupdate
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultMaxExtractSize bounds the decompressed size of an extracted
+// archive member when Updater.MaxExtractSize is zero.
+const DefaultMaxExtractSize = 1 << 30
+
+// archiveFormat returns "zip" or "tar" for archive asset names, or "" if
+// name is not an archive. Compression suffixes must already be stripped
+// (see compressionByName), so "app.tar.gz" is passed as "app.tar".
+func archiveFormat(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return "zip"
+	case strings.HasSuffix(lower, ".tar"):
+		return "tar"
+	}
+	return ""
+}
+
+// cleanMemberName validates an archive entry name, rejecting absolute
+// paths and any ".." component ("zip slip").
+func cleanMemberName(name string) (string, error) {
+	name = strings.ReplaceAll(name, `\`, "/")
+	if name == "" || path.IsAbs(name) || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("%w: absolute member path %q", ErrUnsafeArchive, name)
+	}
+	for _, elem := range strings.Split(name, "/") {
+		if elem == ".." {
+			return "", fmt.Errorf("%w: member path %q escapes archive", ErrUnsafeArchive, name)
+		}
+	}
+	return path.Clean(name), nil
+}
+
+// safeJoin resolves an archive member name below dir.
+func safeJoin(dir, name string) (string, error) {
+	clean, err := cleanMemberName(name)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.FromSlash(clean)), nil
+}
+
+// memberMatches reports whether the entry called name is the wanted
+// member: either the exact path or, for nested layouts such as
+// "app_1.0_linux_amd64/app", the base name.
+func memberMatches(name, want string) bool {
+	return name == want || path.Base(name) == want
+}
+
+// limitedReader fails once more than n bytes have been read, unlike
+// io.LimitReader which silently truncates.
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, fmt.Errorf("%w: decompressed size exceeds limit", ErrUnsafeArchive)
+	}
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, fmt.Errorf("%w: decompressed size exceeds limit", ErrUnsafeArchive)
+	}
+	return n, err
+}
+
+// extractMember copies the member called member from the archive at
+// archivePath into dst (mode 0755), decompressing at most limit bytes.
+// Only that member is read; the rest of the archive is never unpacked.
+// dec decompresses the whole archive first (tar only, e.g. ".tar.gz").
+func extractMember(archivePath, format string, dec Decompressor, member, dst string, limit int64) error {
+	if limit <= 0 {
+		limit = DefaultMaxExtractSize
+	}
+	switch format {
+	case "zip":
+		if dec != nil {
+			return fmt.Errorf("compressed zip archives are not supported")
+		}
+		return extractZipMember(archivePath, member, dst, limit)
+	case "tar":
+		f, err := os.Open(archivePath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		var r io.Reader = f
+		if dec != nil {
+			rc, err := dec(f)
+			if err != nil {
+				return err
+			}
+			defer rc.Close()
+			r = rc
+		}
+		return extractTarMember(r, member, dst, limit)
+	}
+	return fmt.Errorf("unsupported archive format %q", format)
+}
+
+func extractTarMember(r io.Reader, member, dst string, limit int64) error {
+	// The tar stream itself is bounded too, so a bomb made of many huge
+	// entries before the member cannot exhaust CPU indefinitely.
+	tr := tar.NewReader(&limitedReader{r: r, n: limit + 1<<20})
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("%w: %s not found in archive", ErrNoAsset, member)
+		}
+		if err != nil {
+			return err
+		}
+		name, err := cleanMemberName(hdr.Name)
+		if err != nil {
+			return err
+		}
+		if !memberMatches(name, member) {
+			continue
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return fmt.Errorf("%w: %s is not a regular file", ErrUnsafeArchive, hdr.Name)
+		}
+		if hdr.Size > limit {
+			return fmt.Errorf("%w: %s declares %d bytes", ErrUnsafeArchive, hdr.Name, hdr.Size)
+		}
+		return writeMember(tr, dst, limit)
+	}
+}
+
+func extractZipMember(archivePath, member, dst string, limit int64) error {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		name, err := cleanMemberName(f.Name)
+		if err != nil {
+			return err
+		}
+		if !memberMatches(name, member) || f.FileInfo().IsDir() {
+			continue
+		}
+		if !f.Mode().IsRegular() {
+			return fmt.Errorf("%w: %s is not a regular file", ErrUnsafeArchive, f.Name)
+		}
+		if f.UncompressedSize64 > uint64(limit) {
+			return fmt.Errorf("%w: %s declares %d bytes", ErrUnsafeArchive, f.Name, f.UncompressedSize64)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		return writeMember(rc, dst, limit)
+	}
+	return fmt.Errorf("%w: %s not found in archive", ErrNoAsset, member)
+}
+
+// writeMember streams r into dst, enforcing limit on the bytes written.
+func writeMember(r io.Reader, dst string, limit int64) error {
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, &limitedReader{r: r, n: limit}); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,109 @@
+package selfupdate
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type archiveEntry struct {
+	name    string
+	content string
+}
+
+func writeTarGz(t *testing.T, path string, entries ...archiveEntry) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		tw.WriteHeader(&tar.Header{Name: e.name, Mode: 0o755, Size: int64(len(e.content)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(e.content))
+	}
+	tw.Close()
+	gz.Close()
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeZip(t *testing.T, path string, entries ...archiveEntry) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		w, _ := zw.Create(e.name)
+		w.Write([]byte(e.content))
+	}
+	zw.Close()
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_extractMember(t *testing.T) {
+	dir := t.TempDir()
+	dst := filepath.Join(dir, "out")
+	verifyOk := func(archive, format, want string) {
+		base, dec := compressionByName(archive)
+		if err := extractMember(archive, archiveFormat(base), dec, "app", dst, 0); err != nil {
+			t.Fatal(err)
+		}
+		if b, _ := os.ReadFile(dst); string(b) != want {
+			t.Error("extracted content mismatch: " + string(b))
+		}
+	}
+	verifyErr := func(archive, format string, limit int64, target error) {
+		base, dec := compressionByName(archive)
+		err := extractMember(archive, archiveFormat(base), dec, "app", dst, limit)
+		if !errors.Is(err, target) {
+			t.Errorf("%s: expected %v, got %v", filepath.Base(archive), target, err)
+		}
+	}
+
+	tgz := filepath.Join(dir, "app_1.0_linux_amd64.tar.gz")
+	writeTarGz(t, tgz,
+		archiveEntry{"README.md", "docs"},
+		archiveEntry{"app_1.0_linux_amd64/app", "binary"})
+	verifyOk(tgz, "tar.gz", "binary")
+	verifyErr(tgz, "tar.gz", 3, ErrUnsafeArchive)
+
+	slip := filepath.Join(dir, "slip.tar.gz")
+	writeTarGz(t, slip, archiveEntry{"../../etc/app", "evil"}, archiveEntry{"app", "binary"})
+	verifyErr(slip, "tar.gz", 0, ErrUnsafeArchive)
+
+	zipPath := filepath.Join(dir, "app.zip")
+	writeZip(t, zipPath, archiveEntry{"bin/app", "zipped"}, archiveEntry{"LICENSE", "mit"})
+	verifyOk(zipPath, "zip", "zipped")
+	verifyErr(zipPath, "zip", 2, ErrUnsafeArchive)
+
+	missing := filepath.Join(dir, "missing.zip")
+	writeZip(t, missing, archiveEntry{"other", "x"})
+	verifyErr(missing, "zip", 0, ErrNoAsset)
+}
+
+func Test_archiveFormat(t *testing.T) {
+	for name, want := range map[string]string{
+		"app.zip": "zip", "app.TAR.GZ": "tar", "app.tgz": "tar", "app.tar.bz2": "tar",
+		"app.tar": "tar", "app-linux-amd64": "", "app.gz": "",
+	} {
+		base, _ := compressionByName(name)
+		if got := archiveFormat(base); got != want {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+}
+
+func Test_limitedReader(t *testing.T) {
+	if _, err := io.ReadAll(&limitedReader{r: strings.NewReader("12345"), n: 5}); err != nil {
+		t.Error("reading exactly the limit should succeed")
+	}
+	if _, err := io.ReadAll(&limitedReader{r: strings.NewReader("123456"), n: 5}); !errors.Is(err, ErrUnsafeArchive) {
+		t.Error("exceeding the limit should fail")
+	}
+}
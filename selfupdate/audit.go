@@ -0,0 +1,175 @@
+package selfupdate
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Audit events.
+const (
+	AuditInstall  = "install"
+	AuditRollback = "rollback"
+	AuditRollout  = "rollout"
+	// AuditDrift records an executable found modified; see
+	// IntegrityConfig.
+	AuditDrift = "drift"
+)
+
+// ErrAuditTampered is returned by VerifyAuditLog for a broken chain or
+// signature.
+var ErrAuditTampered = errors.New("audit log tampered")
+
+// AuditRecord is one line of an AuditLog.
+type AuditRecord struct {
+	Time   time.Time `json:"time"`
+	Event  string    `json:"event"`
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	Digest string    `json:"digest,omitempty"`
+	// Source is what started the update, e.g. "startup" or "http"; see
+	// WithAuditSource.
+	Source string `json:"source"`
+	// Prev is the hex SHA-256 of the previous line, empty for the first.
+	Prev string `json:"prev"`
+	// Signature is the base64 Ed25519 signature of the record encoded
+	// without it.
+	Signature string `json:"signature,omitempty"`
+}
+
+// AuditLog appends signed records of every install, rollback and rollout
+// to a JSON Lines file. Each record carries the hash of the previous line,
+// so editing, reordering or removing a record other than the last breaks
+// the chain; see VerifyAuditLog.
+type AuditLog struct {
+	Path string
+	Key  ed25519.PrivateKey
+
+	mu sync.Mutex
+}
+
+// Append signs r, links it to the last record and appends it.
+func (l *AuditLog) Append(r AuditRecord) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.OpenFile(l.Path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	last, err := lastLine(f)
+	if err != nil {
+		return err
+	}
+	if last != nil {
+		sum := sha256.Sum256(last)
+		r.Prev = hex.EncodeToString(sum[:])
+	}
+	r.Time = r.Time.UTC()
+	r.Signature = ""
+	msg, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	r.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(l.Key, msg))
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// lastLine returns the last line of f without its newline, or nil if f
+// is empty.
+func lastLine(f *os.File) ([]byte, error) {
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimRight(data, "\n")
+	if len(data) == 0 {
+		return nil, nil
+	}
+	return data[bytes.LastIndexByte(data, '\n')+1:], nil
+}
+
+// VerifyAuditLog checks the chain and signatures of an audit log against
+// pub and returns the number of valid records.
+func VerifyAuditLog(r io.Reader, pub ed25519.PublicKey) (int, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	var prev []byte
+	n := 0
+	for sc.Scan() {
+		line := sc.Bytes()
+		var rec AuditRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return n, fmt.Errorf("%w: record %d: %w", ErrAuditTampered, n+1, err)
+		}
+		want := ""
+		if prev != nil {
+			sum := sha256.Sum256(prev)
+			want = hex.EncodeToString(sum[:])
+		}
+		if rec.Prev != want {
+			return n, fmt.Errorf("%w: record %d does not follow its predecessor", ErrAuditTampered, n+1)
+		}
+		sig, err := base64.StdEncoding.DecodeString(rec.Signature)
+		rec.Signature = ""
+		msg, merr := json.Marshal(rec)
+		if err != nil || merr != nil || !ed25519.Verify(pub, msg, sig) {
+			return n, fmt.Errorf("%w: record %d has an invalid signature", ErrAuditTampered, n+1)
+		}
+		prev = append(prev[:0], line...)
+		n++
+	}
+	return n, sc.Err()
+}
+
+type auditSourceKey struct{}
+
+// WithAuditSource records in ctx what started an update, for the Source
+// of audit records written during it.
+func WithAuditSource(ctx context.Context, source string) context.Context {
+	return context.WithValue(ctx, auditSourceKey{}, source)
+}
+
+func auditSource(ctx context.Context) string {
+	if s, ok := ctx.Value(auditSourceKey{}).(string); ok {
+		return s
+	}
+	return "api"
+}
+
+// audit appends a record to u.Audit, if set. Failures are logged: the
+// change being recorded has already happened.
+func (u *Updater) audit(ctx context.Context, event, to, digest string) {
+	if u.Audit == nil {
+		return
+	}
+	err := u.Audit.Append(AuditRecord{
+		Time:   time.Now(),
+		Event:  event,
+		From:   u.Build.Version,
+		To:     to,
+		Digest: digest,
+		Source: auditSource(ctx),
+	})
+	if err != nil {
+		u.logf("WARNING: cannot write audit record: %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package selfupdate_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/msmania/updater/selfupdate"
+	"github.com/msmania/updater/selfupdate/selfupdatetest"
+)
+
+func Test_Updater_audit(t *testing.T) {
+	skipIfDisabled(t)
+	srv := selfupdatetest.NewServer("owner", "app",
+		selfupdatetest.Release{Tag: "v1.1.0", Assets: []selfupdatetest.Asset{{Name: "app-bin", Content: []byte("v1.1")}}},
+	)
+	defer srv.Close()
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	u := newUpdater(t, srv, "v1.0.0")
+	u.Audit = &selfupdate.AuditLog{Path: filepath.Join(t.TempDir(), "audit.log"), Key: priv}
+
+	if _, err := u.Update(selfupdate.WithAuditSource(context.Background(), "test")); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(u.Audit.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if n, err := selfupdate.VerifyAuditLog(f, pub); n != 1 || err != nil {
+		t.Fatal("unexpected audit log", n, err)
+	}
+	data, _ := os.ReadFile(u.Audit.Path)
+	var rec selfupdate.AuditRecord
+	json.Unmarshal(data, &rec)
+	sum := sha256.Sum256([]byte("v1.1"))
+	if rec.Event != selfupdate.AuditInstall || rec.From != "v1.0.0" || rec.To != "v1.1.0" ||
+		rec.Source != "test" || !strings.HasSuffix(rec.Digest, fmt.Sprintf("%x", sum)) {
+		t.Errorf("unexpected record %+v", rec)
+	}
+}
@@ -0,0 +1,49 @@
+package selfupdate
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_AuditLog(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l := &AuditLog{Path: path, Key: priv}
+	for _, to := range []string{"v1.1.0", "v1.2.0", "v1.3.0"} {
+		if err := l.Append(AuditRecord{Time: time.Now(), Event: AuditInstall, To: to, Source: "test"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, _ := os.ReadFile(path)
+	if n, err := VerifyAuditLog(bytes.NewReader(data), pub); n != 3 || err != nil {
+		t.Fatalf("valid log rejected after %d records: %v", n, err)
+	}
+
+	verifyFail := func(data []byte, key ed25519.PublicKey) {
+		if _, err := VerifyAuditLog(bytes.NewReader(data), key); !errors.Is(err, ErrAuditTampered) {
+			t.Error("expected ErrAuditTampered, got", err)
+		}
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	verifyFail([]byte(strings.Replace(string(data), "v1.2.0", "v1.9.0", 1)), pub)
+	verifyFail([]byte(lines[0]+lines[2]), pub)
+	verifyFail([]byte(lines[1]+lines[0]+lines[2]), pub)
+	other, _, _ := ed25519.GenerateKey(nil)
+	verifyFail(data, other)
+}
+
+func Test_audit_source(t *testing.T) {
+	if s := auditSource(context.Background()); s != "api" {
+		t.Error("unexpected default source " + s)
+	}
+	if s := auditSource(WithAuditSource(context.Background(), "http")); s != "http" {
+		t.Error("unexpected source " + s)
+	}
+}
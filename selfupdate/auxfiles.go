@@ -0,0 +1,264 @@
+package selfupdate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// AuxFile is a file installed alongside the executable from the same
+// release, such as a default config or shell completions.
+//
+// An AuxFile in its own asset is verified against the checksum asset or
+// the digest the release API reports, like the executable; one extracted
+// from the main archive is covered by the archive's verification. The
+// Verifier only sees the executable.
+type AuxFile struct {
+	// Asset is the release asset holding the file, a template like
+	// Updater.AssetTemplate. Empty means the main asset, which must then
+	// be an archive.
+	Asset string
+	// Member is the file to extract if the asset is an archive. Defaults
+	// to the base name of Path.
+	Member string
+	// Path is where the file is installed.
+	Path string
+	// Mode defaults to 0644.
+	Mode os.FileMode
+	// Optional files missing from a release are skipped instead of
+	// failing the update.
+	Optional bool
+}
+
+func (f AuxFile) mode() os.FileMode {
+	if f.Mode == 0 {
+		return 0o644
+	}
+	return f.Mode
+}
+
+func (f AuxFile) member() string {
+	if f.Member != "" {
+		return f.Member
+	}
+	return filepath.Base(f.Path)
+}
+
+// stagedAux is an AuxFile downloaded next to its destination.
+type stagedAux struct {
+	Path   string `json:"path"`
+	Staged string `json:"staged"`
+}
+
+// auxBackup records an auxiliary file replaced by the last update, kept
+// below StateDir for a rollback.
+type auxBackup struct {
+	Path string `json:"path"`
+	// Copy is the name of the copy below previousAuxDir, or empty if the
+	// file did not exist before the update, so a rollback removes it.
+	Copy string      `json:"copy,omitempty"`
+	Mode os.FileMode `json:"mode,omitempty"`
+}
+
+// previousAuxDir holds the kept copies of the auxiliary files below
+// StateDir.
+const previousAuxDir = "previous-aux"
+
+// stageAuxFiles downloads, verifies and extracts the AuxFiles of rel next
+// to their destinations. archive is the downloaded main asset if it is an
+// archive of the given format. On failure, nothing stays staged.
+func (u *Updater) stageAuxFiles(ctx context.Context, rel *ghRelease, archive, format string,
+	dec Decompressor, info *UpdateInfo) (staged []stagedAux, err error) {
+	defer func() {
+		if err != nil {
+			discardAux(staged)
+			staged = nil
+		}
+	}()
+	for _, f := range u.AuxFiles {
+		tmp := filepath.Join(filepath.Dir(f.Path), "."+filepath.Base(f.Path)+".new")
+		ok, err := u.stageAuxFile(ctx, rel, f, tmp, archive, format, dec, info)
+		if err != nil {
+			os.Remove(tmp)
+			return staged, fmt.Errorf("%s: %w", f.Path, err)
+		}
+		if !ok {
+			u.logf("Release %s has no %s; skipping optional %s", rel.TagName, f.member(), f.Path)
+			continue
+		}
+		if err := os.Chmod(tmp, f.mode()); err != nil {
+			os.Remove(tmp)
+			return staged, err
+		}
+		staged = append(staged, stagedAux{Path: f.Path, Staged: tmp})
+	}
+	return staged, nil
+}
+
+// stageAuxFile writes f to tmp. It returns false if an optional f is
+// missing from the release.
+func (u *Updater) stageAuxFile(ctx context.Context, rel *ghRelease, f AuxFile, tmp, archive, format string,
+	dec Decompressor, info *UpdateInfo) (bool, error) {
+	if f.Asset == "" {
+		if format == "" {
+			return false, fmt.Errorf("the release asset is not an archive")
+		}
+		return u.extractAux(f, archive, format, dec, tmp)
+	}
+	name, err := u.expandAssetTemplate(f.Asset, rel.TagName)
+	if err != nil {
+		return false, err
+	}
+	asset, err := rel.findAsset(name)
+	if err != nil {
+		return false, optionalMissing(f, err)
+	}
+	want, err := u.expectedDigest(ctx, rel, asset)
+	if err != nil {
+		return false, fmt.Errorf("cannot fetch checksums: %w", err)
+	}
+	base, dec := compressionByName(asset.Name)
+	format = archiveFormat(base)
+	download := tmp
+	var streamDec Decompressor
+	if format != "" {
+		download = tmp + ".download"
+		defer os.Remove(download)
+	} else {
+		streamDec = dec
+	}
+	auxInfo := &UpdateInfo{Asset: &AssetInfo{}}
+	_, err = u.fetchAsset(ctx, rel.TagName, asset, download, want, streamDec, auxInfo)
+	info.BytesDownloaded += auxInfo.BytesDownloaded
+	info.Retries += auxInfo.Retries
+	info.Durations.Download += auxInfo.Durations.Download
+	info.Durations.Verify += auxInfo.Durations.Verify
+	if err != nil {
+		return false, err
+	}
+	if format == "" {
+		return true, nil
+	}
+	return u.extractAux(f, download, format, dec, tmp)
+}
+
+func (u *Updater) extractAux(f AuxFile, archive, format string, dec Decompressor, tmp string) (bool, error) {
+	err := extractMember(archive, format, dec, f.member(), tmp, u.MaxExtractSize)
+	if err != nil {
+		return false, optionalMissing(f, fmt.Errorf("extract failed: %w", err))
+	}
+	return true, nil
+}
+
+// optionalMissing returns nil if err reports that an optional f is
+// missing.
+func optionalMissing(f AuxFile, err error) error {
+	if f.Optional && errors.Is(err, ErrNoAsset) {
+		return nil
+	}
+	return err
+}
+
+func discardAux(staged []stagedAux) {
+	for _, s := range staged {
+		os.Remove(s.Staged)
+	}
+}
+
+// installAux moves the staged files into place, keeping the replaced ones
+// as "<path>.old". It returns a function undoing the install, which must
+// be called if the executable cannot be installed, and one that commits
+// it by keeping the replaced files for a rollback.
+func (u *Updater) installAux(staged []stagedAux) (undo, commit func(), err error) {
+	type replaced struct {
+		path    string
+		existed bool
+		mode    os.FileMode
+	}
+	var done []replaced
+	undo = func() {
+		for _, r := range done {
+			os.Remove(r.path)
+			if r.existed {
+				os.Rename(r.path+".old", r.path)
+			}
+		}
+		discardAux(staged)
+	}
+	commit = func() {
+		var backups []auxBackup
+		for i, r := range done {
+			b := auxBackup{Path: r.path, Mode: r.mode}
+			if r.existed {
+				b.Copy = strconv.Itoa(i)
+				err := u.keepAux(r.path+".old", b.Copy)
+				os.Remove(r.path + ".old")
+				if err != nil {
+					u.logf("WARNING: cannot keep %s for rollback: %v", r.path, err)
+					continue
+				}
+			}
+			backups = append(backups, b)
+		}
+		if u.StateDir != "" {
+			if _, err := u.updateHealth(func(h *runHealth) { h.PreviousAux = backups }); err != nil {
+				u.logf("WARNING: cannot record auxiliary files for rollback: %v", err)
+			}
+		}
+	}
+	for _, s := range staged {
+		r := replaced{path: s.Path}
+		if fi, err := os.Stat(s.Path); err == nil {
+			r.existed, r.mode = true, fi.Mode().Perm()
+			if err := os.Rename(s.Path, s.Path+".old"); err != nil {
+				undo()
+				return nil, nil, err
+			}
+		}
+		if err := os.Rename(s.Staged, s.Path); err != nil {
+			if r.existed {
+				os.Rename(s.Path+".old", s.Path)
+			}
+			undo()
+			return nil, nil, err
+		}
+		done = append(done, r)
+	}
+	return undo, commit, nil
+}
+
+// keepAux copies the replaced file src below StateDir as name, for
+// Started or Rollback.
+func (u *Updater) keepAux(src, name string) error {
+	if u.StateDir == "" {
+		return nil
+	}
+	dir := filepath.Join(u.StateDir, previousAuxDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	return copyFile(src, filepath.Join(dir, name))
+}
+
+// restoreAux puts back the auxiliary files replaced by the last update.
+func (u *Updater) restoreAux(backups []auxBackup) error {
+	var errs []error
+	for _, b := range backups {
+		if b.Copy == "" {
+			if err := os.Remove(b.Path); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		kept := filepath.Join(u.StateDir, previousAuxDir, b.Copy)
+		if err := copyFile(kept, b.Path); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		errs = append(errs, os.Chmod(b.Path, b.Mode), os.Remove(kept))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,93 @@
+package selfupdate_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/msmania/updater/selfupdate"
+	"github.com/msmania/updater/selfupdate/selfupdatetest"
+)
+
+func Test_Updater_auxFiles(t *testing.T) {
+	skipIfDisabled(t)
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	for name, content := range map[string]string{"app": "v1.1", "app.conf": "conf 1.1"} {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0o755, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gz.Close()
+	srv := selfupdatetest.NewServer("owner", "app",
+		selfupdatetest.Release{Tag: "v1.1.0", Assets: []selfupdatetest.Asset{
+			{Name: "app.tar.gz", Content: archive.Bytes()},
+			{Name: "app.bash", Content: []byte("complete 1.1")},
+		}},
+	)
+	defer srv.Close()
+	u := newUpdater(t, srv, "v1.0.0")
+	u.AssetName, u.StateDir = "app.tar.gz", t.TempDir()
+	dir := filepath.Dir(u.Path)
+	conf, bash, zsh := filepath.Join(dir, "app.conf"), filepath.Join(dir, "app.bash"), filepath.Join(dir, "_app")
+	u.AuxFiles = []selfupdate.AuxFile{
+		{Path: conf, Mode: 0o600},
+		{Asset: "{{.Repo}}.bash", Path: bash},
+		{Asset: "app.zsh", Path: zsh, Optional: true},
+	}
+	if err := os.WriteFile(conf, []byte("conf 1.0"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	verify := func(path, want string) {
+		t.Helper()
+		b, err := os.ReadFile(path)
+		if want == "" {
+			if !os.IsNotExist(err) {
+				t.Errorf("%s should not exist: %q", filepath.Base(path), b)
+			}
+		} else if string(b) != want {
+			t.Errorf("%s: expected %q, got %q (%v)", filepath.Base(path), want, b, err)
+		}
+	}
+
+	if _, err := u.Update(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	verify(u.Path, "v1.1")
+	verify(conf, "conf 1.1")
+	verify(bash, "complete 1.1")
+	verify(zsh, "")
+	if fi, err := os.Stat(conf); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Errorf("unexpected mode of app.conf: %v", fi.Mode())
+	}
+
+	u.Build.Version = "v1.1.0"
+	if _, err := u.Rollback(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	verify(u.Path, "old")
+	verify(conf, "conf 1.0")
+	verify(bash, "")
+
+	srv.AddRelease(selfupdatetest.Release{Tag: "v1.2.0", Assets: []selfupdatetest.Asset{
+		{Name: "app.tar.gz", Content: archive.Bytes()},
+		{Name: "app.bash", Content: []byte("complete 1.2"), Digest: "sha256:" + strings.Repeat("00", 32)},
+	}})
+	u.Build.Version = "v1.0.0"
+	if _, err := u.Update(context.Background()); !errors.Is(err, selfupdate.ErrChecksumMismatch) {
+		t.Error("expected ErrChecksumMismatch, got", err)
+	}
+	verify(u.Path, "old")
+	verify(conf, "conf 1.0")
+	verify(bash, "")
+	if leftovers, _ := filepath.Glob(filepath.Join(dir, ".*.new*")); len(leftovers) != 0 {
+		t.Error("staged files left behind:", leftovers)
+	}
+}
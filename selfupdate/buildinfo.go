@@ -0,0 +1,103 @@
+package selfupdate
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// disableSelfUpdate turns self-update off in a build that still
+// contains the code, for distributors that cannot change build tags:
+//
+//	-ldflags "-X github.com/msmania/updater/selfupdate.disableSelfUpdate=true"
+var disableSelfUpdate string
+
+// Enabled reports whether this binary can update itself. It is false if
+// the binary was built with the noselfupdate tag or with the
+// disableSelfUpdate ldflags switch. Both turn self-update off at run
+// time; the update code stays in the binary. Update,
+// Check, Resolve and Rollback then fail with ErrDisabled, and
+// ActivateStaged, LatestNotice and RunScheduled do nothing.
+func Enabled() bool {
+	return compiledIn && disableSelfUpdate == ""
+}
+
+// BuildInfo describes the running binary. Version, Commit and Date are
+// normally injected at build time via -ldflags "-X ...".
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	Date      string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+	// SelfUpdate reports Enabled.
+	SelfUpdate bool `json:"self_update"`
+}
+
+// NewBuildInfo fills in the toolchain and platform fields for the given
+// ldflags values. Values that are empty or left at the "dev"/"unknown"
+// defaults fall back to what the toolchain embedded in the binary, so a
+// binary built by "go install module@v1.2.3" still reports v1.2.3.
+func NewBuildInfo(version, commit, date string) BuildInfo {
+	b := BuildInfo{
+		Version:    version,
+		Commit:     commit,
+		Date:       date,
+		GoVersion:  runtime.Version(),
+		Platform:   runtime.GOOS + "/" + runtime.GOARCH,
+		SelfUpdate: Enabled(),
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		b.fillFrom(bi)
+	}
+	return b
+}
+
+func isPlaceholder(s string) bool {
+	return s == "" || s == "dev" || s == "unknown"
+}
+
+// fillFrom replaces placeholder fields with the module version and the
+// VCS revision and commit time recorded in bi.
+func (b *BuildInfo) fillFrom(bi *debug.BuildInfo) {
+	if isPlaceholder(b.Version) && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+		b.Version = bi.Main.Version
+	}
+	var revision, vcsTime string
+	var modified bool
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.time":
+			vcsTime = s.Value
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	if isPlaceholder(b.Commit) && revision != "" {
+		b.Commit = revision[:min(len(revision), 12)]
+		if modified {
+			b.Commit += "-dirty"
+		}
+	}
+	if isPlaceholder(b.Date) && vcsTime != "" {
+		b.Date = vcsTime
+	}
+}
+
+// String returns a single-line summary suitable for --version and logs.
+func (b BuildInfo) String() string {
+	s := fmt.Sprintf("%s (commit %s, built %s, %s %s", b.Version, b.Commit, b.Date, b.GoVersion, b.Platform)
+	if !b.SelfUpdate {
+		s += ", self-update disabled"
+	}
+	return s + ")"
+}
+
+// UserAgent returns the User-Agent header value for requests made on
+// behalf of the program called name.
+func (b BuildInfo) UserAgent(name string) string {
+	return fmt.Sprintf("%s/%s (%s; commit %s) %s",
+		name, b.Version, b.Platform, b.Commit, b.GoVersion)
+}
@@ -0,0 +1,66 @@
+package selfupdate
+
+import (
+	"context"
+	"errors"
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+func Test_BuildInfo_fillFrom(t *testing.T) {
+	bi := &debug.BuildInfo{
+		Main: debug.Module{Path: "github.com/msmania/updater", Version: "v1.2.3"},
+		Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "0123456789abcdef0123"},
+			{Key: "vcs.time", Value: "2024-05-01T10:00:00Z"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	}
+
+	b := BuildInfo{Version: "dev", Commit: "unknown", Date: "unknown"}
+	b.fillFrom(bi)
+	if b.Version != "v1.2.3" || b.Commit != "0123456789ab-dirty" || b.Date != "2024-05-01T10:00:00Z" {
+		t.Errorf("placeholders not replaced: %+v", b)
+	}
+
+	b = BuildInfo{Version: "v2.0.0", Commit: "abc1234", Date: "2025-01-01T00:00:00Z"}
+	b.fillFrom(bi)
+	if b.Version != "v2.0.0" || b.Commit != "abc1234" || b.Date != "2025-01-01T00:00:00Z" {
+		t.Errorf("ldflags values must win: %+v", b)
+	}
+
+	b = BuildInfo{Version: "dev"}
+	b.fillFrom(&debug.BuildInfo{Main: debug.Module{Version: "(devel)"}})
+	if b.Version != "dev" {
+		t.Error("(devel) must not replace the version, got " + b.Version)
+	}
+}
+
+func Test_Enabled(t *testing.T) {
+	skipIfDisabled(t)
+	disableSelfUpdate = "true"
+	defer func() { disableSelfUpdate = "" }()
+
+	if b := NewBuildInfo("v1.0.0", "abc1234", "2025-01-01"); b.SelfUpdate ||
+		!strings.HasSuffix(b.String(), ", self-update disabled)") {
+		t.Errorf("capability not reported: %s", b)
+	}
+	u := &Updater{Owner: "o", Repo: "r", Build: BuildInfo{Version: "v1.0.0"}}
+	info, err := u.Update(context.Background())
+	if !errors.Is(err, ErrDisabled) || info.Decision != DecisionDisabled {
+		t.Errorf("Update = %s, %v", info.Decision, err)
+	}
+	if _, err := u.Check(context.Background()); !errors.Is(err, ErrDisabled) {
+		t.Errorf("Check = %v", err)
+	}
+}
+
+// skipIfDisabled skips tests that need a working Updater in builds with
+// the noselfupdate tag.
+func skipIfDisabled(t *testing.T) {
+	t.Helper()
+	if !Enabled() {
+		t.Skip("built with the noselfupdate tag")
+	}
+}
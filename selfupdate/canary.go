@@ -0,0 +1,160 @@
+package selfupdate
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// CanaryConfig makes a freshly installed version prove itself: on its
+// first start (see Updater.Started) it must answer URL with a 2xx status
+// within Timeout, or the version it replaced is restored as after a
+// crash loop and AfterUpgrade is called to restart into it. It requires
+// Updater.StateDir, where the install leaves a marker naming the version
+// under test, and the copy of the previous executable kept there.
+type CanaryConfig struct {
+	// URL is the readiness endpoint of the new process, e.g.
+	// "http://localhost:8080/readyz". Empty disables the check.
+	URL string
+	// Timeout defaults to DefaultCanaryTimeout.
+	Timeout time.Duration
+}
+
+// DefaultCanaryTimeout is how long a new version has to become ready
+// when CanaryConfig.Timeout is zero.
+const DefaultCanaryTimeout = time.Minute
+
+const (
+	// canaryFile marks the version that has to pass the readiness check.
+	canaryFile = "canary.json"
+	// canaryPollInterval is the time between readiness probes.
+	canaryPollInterval = time.Second
+)
+
+// canaryState is the marker persisted as canaryFile.
+type canaryState struct {
+	Version     string    `json:"version,omitempty"`
+	InstalledAt time.Time `json:"installed_at,omitzero"`
+}
+
+func (u *Updater) canaryEnabled() bool {
+	return u.Canary.URL != "" && u.StateDir != ""
+}
+
+// armCanary records that version, just installed, has to pass the
+// readiness check on its first start.
+func (u *Updater) armCanary(version string) {
+	if !u.canaryEnabled() {
+		return
+	}
+	c := canaryState{Version: version, InstalledAt: u.clock().Now().UTC()}
+	if err := u.writeState(canaryFile, c); err != nil {
+		u.logf("WARNING: cannot arm the readiness check of %s: %v", version, err)
+	}
+}
+
+// clearCanary resets the marker.
+func (u *Updater) clearCanary() {
+	if err := u.writeState(canaryFile, canaryState{}); err != nil {
+		u.logf("cannot clear the readiness check: %v", err)
+	}
+}
+
+// startCanary starts the readiness check if the running version was
+// installed with one pending. Automatic updates are deferred until it
+// finishes.
+func (u *Updater) startCanary(ctx context.Context) {
+	if !u.canaryEnabled() {
+		return
+	}
+	var c canaryState
+	if err := u.readState(canaryFile, &c); err != nil {
+		u.logf("cannot read the readiness check: %v", err)
+		return
+	}
+	switch c.Version {
+	case "":
+		return
+	case u.Build.Version:
+	default:
+		// Replaced before it ever ran, e.g. by a rollback.
+		u.clearCanary()
+		return
+	}
+	u.canaryPending.Store(true)
+	go u.verifyCanary(ctx, c.Version)
+}
+
+// verifyCanary probes Canary.URL until it is ready, and rolls back if it
+// is not within Canary.Timeout. It gives up without a verdict when ctx
+// is done, leaving the check to the next start.
+func (u *Updater) verifyCanary(ctx context.Context, version string) {
+	defer u.canaryPending.Store(false)
+	err := u.waitReady(ctx, u.Canary.URL, orDefault(u.Canary.Timeout, DefaultCanaryTimeout))
+	if err == nil {
+		u.clearCanary()
+		u.logf("%s passed its readiness check", version)
+		return
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	u.logf("WARNING: %s is not ready after %s: %v", version, orDefault(u.Canary.Timeout, DefaultCanaryTimeout), err)
+	u.clearCanary()
+	h, herr := u.readHealth()
+	if herr != nil || h.Previous == "" || h.Previous == version {
+		u.logf("WARNING: no previous version to roll back to")
+		return
+	}
+	if err := u.restorePrevious(WithAuditSource(ctx, "canary"), h.Previous); err != nil {
+		u.logf("WARNING: %v", err)
+		return
+	}
+	if u.AfterUpgrade != nil {
+		u.AfterUpgrade()
+	}
+}
+
+// waitReady probes url every canaryPollInterval on u's clock until it
+// answers with a 2xx status, and returns the last probe error if that
+// takes longer than timeout, or ctx.Err() if ctx is done first.
+func (u *Updater) waitReady(ctx context.Context, url string, timeout time.Duration) error {
+	deadline := u.clock().Now().Add(timeout)
+	for {
+		err := u.probeReady(ctx, url)
+		if err == nil {
+			return nil
+		}
+		now := u.clock().Now()
+		if !now.Before(deadline) {
+			return err
+		}
+		next := now.Add(canaryPollInterval)
+		if next.After(deadline) {
+			next = deadline
+		}
+		if !u.sleepUntil(ctx, next) {
+			return ctx.Err()
+		}
+	}
+}
+
+// probeReady requests url once.
+func (u *Updater) probeReady(ctx context.Context, url string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := u.Client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer drainClose(resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s returned %d", url, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package selfupdate_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/msmania/updater/selfupdate"
+	"github.com/msmania/updater/selfupdate/selfupdatetest"
+)
+
+func Test_Updater_canary(t *testing.T) {
+	skipIfDisabled(t)
+	srv := selfupdatetest.NewServer("owner", "app",
+		selfupdatetest.Release{Tag: "v1.1.0", Assets: []selfupdatetest.Asset{{Name: "app-bin", Content: []byte("v1.1")}}},
+	)
+	defer srv.Close()
+	var ready atomic.Bool
+	readyz := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !ready.Load() {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+		}
+	}))
+	defer readyz.Close()
+	ctx := context.Background()
+
+	install := func() *selfupdate.Updater {
+		t.Helper()
+		u := newUpdater(t, srv, "v1.0.0")
+		u.StateDir = t.TempDir()
+		u.Canary = selfupdate.CanaryConfig{URL: readyz.URL + "/readyz", Timeout: 3 * time.Second}
+		if _, err := u.Update(ctx); err != nil {
+			t.Fatal(err)
+		}
+		// The restarted process runs the new version.
+		return &selfupdate.Updater{Owner: u.Owner, Repo: u.Repo, AssetName: u.AssetName, APIURL: u.APIURL,
+			Path: u.Path, StateDir: u.StateDir, Canary: u.Canary, Build: selfupdate.BuildInfo{Version: "v1.1.0"},
+			Clock: selfupdatetest.NewClock(time.Now())}
+	}
+
+	// Not ready in time: the previous version is restored.
+	u := install()
+	clock := u.Clock.(*selfupdatetest.Clock)
+	restarted := make(chan struct{})
+	u.AfterUpgrade = func() { close(restarted) }
+	if _, err := u.Started(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+	}
+	select {
+	case <-restarted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no rollback after the readiness timeout")
+	}
+	if b, _ := os.ReadFile(u.Path); string(b) != "old" {
+		t.Errorf("previous binary not restored: %q", b)
+	}
+	if h := u.History(); len(h) == 0 || h[0].Decision != selfupdate.DecisionRolledBack || h[0].Trigger != "canary" {
+		t.Errorf("unexpected history %+v", h)
+	}
+
+	// Ready: the new version stays and the marker is cleared.
+	ready.Store(true)
+	u = install()
+	u.AfterUpgrade = func() { t.Error("rolled back a ready version") }
+	if _, err := u.Started(ctx); err != nil {
+		t.Fatal(err)
+	}
+	marker := filepath.Join(u.StateDir, "canary.json")
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if b, _ := os.ReadFile(marker); !strings.Contains(string(b), "v1.1.0") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("readiness check not completed")
+		}
+	}
+	if b, _ := os.ReadFile(u.Path); string(b) != "v1.1" {
+		t.Errorf("unexpected content %q", b)
+	}
+}
@@ -0,0 +1,48 @@
+package selfupdate
+
+import "fmt"
+
+// Channel selects which releases are eligible for installation.
+type Channel string
+
+// Channels from most to least conservative. Each admits the pre-releases
+// of the channels above it, e.g. ChannelBeta also installs rc releases.
+const (
+	ChannelStable Channel = "stable"
+	ChannelRC     Channel = "rc"
+	ChannelBeta   Channel = "beta"
+	ChannelAlpha  Channel = "alpha"
+)
+
+// ParseChannel validates a channel name. The empty string is ChannelStable.
+func ParseChannel(s string) (Channel, error) {
+	switch c := Channel(s); c {
+	case "":
+		return ChannelStable, nil
+	case ChannelStable, ChannelRC, ChannelBeta, ChannelAlpha:
+		return c, nil
+	}
+	return "", fmt.Errorf("unknown channel %q", s)
+}
+
+// minPrerelease is the least mature pre-release type c admits.
+func (c Channel) minPrerelease() PreReleaseType {
+	switch c {
+	case ChannelRC:
+		return PrereleaseRC
+	case ChannelBeta:
+		return PrereleaseBeta
+	case ChannelAlpha:
+		return PrereleaseAlpha
+	}
+	return PrereleaseNone
+}
+
+// allows reports whether a release tagged v may be installed from c.
+func (c Channel) allows(v Version) bool {
+	if !v.IsPrerelease() {
+		return true
+	}
+	lowest := c.minPrerelease()
+	return lowest != PrereleaseNone && v.Pre.t >= lowest
+}
@@ -0,0 +1,35 @@
+package selfupdate_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/msmania/updater/selfupdate"
+	"github.com/msmania/updater/selfupdate/selfupdatetest"
+)
+
+func Test_Updater_channels(t *testing.T) {
+	skipIfDisabled(t)
+	srv := selfupdatetest.NewServer("owner", "app",
+		selfupdatetest.Release{Tag: "v1.1.0-beta1", Prerelease: true, Assets: []selfupdatetest.Asset{{Name: "app-bin", Content: []byte("beta")}}},
+		selfupdatetest.Release{Tag: "v1.2.0-alpha1", Prerelease: true, Assets: []selfupdatetest.Asset{{Name: "app-bin", Content: []byte("alpha")}}},
+		selfupdatetest.Release{Tag: "v1.3.0", Draft: true, Assets: []selfupdatetest.Asset{{Name: "app-bin", Content: []byte("draft")}}},
+	)
+	defer srv.Close()
+	u := newUpdater(t, srv, "v1.0.0")
+	ctx := context.Background()
+
+	if _, err := u.MaybeUpgrade(ctx); !errors.Is(err, selfupdate.ErrNoRelease) {
+		t.Error("expected ErrNoRelease on stable, got", err)
+	}
+
+	u.Channel = selfupdate.ChannelBeta
+	if upgraded, err := u.MaybeUpgrade(ctx); err != nil || !upgraded {
+		t.Fatalf("beta upgrade failed: %v", err)
+	}
+	if b, _ := os.ReadFile(u.Path); string(b) != "beta" {
+		t.Error("expected beta binary, got " + string(b))
+	}
+}
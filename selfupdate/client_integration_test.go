@@ -0,0 +1,83 @@
+package selfupdate_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/msmania/updater/selfupdate"
+	"github.com/msmania/updater/selfupdate/selfupdatetest"
+)
+
+// countingTransport records the requests it carries.
+type countingTransport struct {
+	mu    sync.Mutex
+	paths []string
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	c.paths = append(c.paths, req.Method+" "+req.URL.Path)
+	c.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func Test_Updater_httpClient(t *testing.T) {
+	skipIfDisabled(t)
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	bin := []byte("v1.1")
+	sum := sha256.Sum256(bin)
+	srv := selfupdatetest.NewServer("owner", "app",
+		selfupdatetest.Release{Tag: "v1.1.0", Checksums: true, Assets: []selfupdatetest.Asset{
+			{Name: "app-bin", Content: bin},
+			{Name: "app-bin.sig", Content: ed25519.Sign(priv, sum[:])},
+		}},
+	)
+	defer srv.Close()
+	semSrv := httptest.NewServer(selfupdate.NewSemaphore(1))
+	defer semSrv.Close()
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer collector.Close()
+
+	rt := &countingTransport{}
+	u := newUpdater(t, srv, "v1.0.0")
+	u.HTTPClient = &http.Client{Transport: rt}
+	u.ChecksumAsset = "checksums.txt"
+	u.SignatureSuffix = ".sig"
+	u.Verifier = selfupdate.Ed25519Signature(pub)
+	u.Coordinator = &selfupdate.HTTPSemaphore{URL: semSrv.URL, Holder: "me"}
+	tracer := selfupdate.NewOTLPTracer(collector.URL, "app", u.Build)
+	u.Tracer = tracer
+	if u.Client() != u.HTTPClient {
+		t.Error("Client must return HTTPClient")
+	}
+	if _, err := u.Update(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := tracer.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The semaphore is acquired with a POST to its root.
+	got := strings.Join(rt.paths, "\n")
+	for _, want := range []string{
+		"GET /repos/owner/app/releases",
+		"GET /download/v1.1.0/checksums.txt",
+		"GET /download/v1.1.0/app-bin.sig",
+		"GET /download/v1.1.0/app-bin",
+		"POST ",
+		"POST /v1/traces",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("%q not sent through HTTPClient:\n%s", want, got)
+		}
+	}
+	if n := len(srv.Requests()); n > len(rt.paths) {
+		t.Errorf("%d requests bypassed HTTPClient", n-len(rt.paths))
+	}
+}
@@ -0,0 +1,201 @@
+package selfupdate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_NewTransport(t *testing.T) {
+	tr := NewTransport(TransportConfig{TLSHandshakeTimeout: 3 * time.Second})
+	if !tr.ForceAttemptHTTP2 {
+		t.Error("HTTP/2 should be enabled")
+	}
+	if tr.TLSHandshakeTimeout != 3*time.Second {
+		t.Error("TLSHandshakeTimeout not applied")
+	}
+	if tr.IdleConnTimeout != 90*time.Second || tr.MaxIdleConnsPerHost != 16 {
+		t.Error("defaults not applied")
+	}
+}
+
+// serveSOCKS5 runs a SOCKS5 proxy requiring user "u" with password "p",
+// connecting every request to target, and returns its address and the
+// host:port of the last request.
+func serveSOCKS5(t *testing.T, target string) (string, *atomic.Value) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	var requested atomic.Value
+	handle := func(c net.Conn) {
+		defer c.Close()
+		buf := make([]byte, 512)
+		// Greeting: offer user/password authentication.
+		io.ReadFull(c, buf[:2])
+		io.ReadFull(c, buf[:buf[1]])
+		c.Write([]byte{5, 2})
+		io.ReadFull(c, buf[:2])
+		user := make([]byte, buf[1])
+		io.ReadFull(c, user)
+		io.ReadFull(c, buf[:1])
+		pass := make([]byte, buf[0])
+		io.ReadFull(c, pass)
+		if string(user) != "u" || string(pass) != "p" {
+			c.Write([]byte{1, 1})
+			return
+		}
+		c.Write([]byte{1, 0})
+		// CONNECT to a domain name.
+		io.ReadFull(c, buf[:5])
+		if buf[3] != 3 {
+			t.Errorf("expected a host name for the proxy to resolve, got address type %d", buf[3])
+			return
+		}
+		host := make([]byte, buf[4])
+		io.ReadFull(c, host)
+		io.ReadFull(c, buf[:2])
+		requested.Store(fmt.Sprintf("%s:%d", host, int(buf[0])<<8|int(buf[1])))
+		up, err := net.Dial("tcp", target)
+		if err != nil {
+			c.Write([]byte{5, 1, 0, 1, 0, 0, 0, 0, 0, 0})
+			return
+		}
+		defer up.Close()
+		c.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+		go io.Copy(up, c)
+		io.Copy(c, up)
+	}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go handle(c)
+		}
+	}()
+	return ln.Addr().String(), &requested
+}
+
+func Test_NewTransport_socks5(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+	addr, requested := serveSOCKS5(t, srv.Listener.Addr().String())
+	proxy := &url.URL{Scheme: "socks5", Host: addr, User: url.UserPassword("u", "p")}
+	client := &http.Client{Transport: NewTransport(TransportConfig{Proxy: proxy})}
+	resp, err := client.Get("http://releases.example.com:8080/latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "ok" || requested.Load() != "releases.example.com:8080" {
+		t.Errorf("got %q through the proxy for %v", body, requested.Load())
+	}
+
+	proxy.User = url.UserPassword("u", "wrong")
+	client = &http.Client{Transport: NewTransport(TransportConfig{Proxy: proxy})}
+	if _, err := client.Get("http://releases.example.com:8080/latest"); err == nil {
+		t.Error("expected the proxy to reject a wrong password")
+	}
+}
+
+// serveResolver answers A queries over UDP with 127.0.0.1 and others with no
+// records, and returns its address.
+func serveResolver(t *testing.T) string {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			q := buf[:n]
+			end := 12
+			for end < n && q[end] != 0 {
+				end += int(q[end]) + 1
+			}
+			end += 5 // root label, type and class
+			if end > n {
+				continue
+			}
+			resp := append([]byte{q[0], q[1], 0x81, 0x80, 0, 1, 0, 0, 0, 0, 0, 0}, q[12:end]...)
+			if q[end-4] == 0 && q[end-3] == 1 { // type A
+				resp[7] = 1
+				resp = append(resp, 0xc0, 12, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4, 127, 0, 0, 1)
+			}
+			pc.WriteTo(resp, addr)
+		}
+	}()
+	return pc.LocalAddr().String()
+}
+
+func Test_NewTransport_network(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	resolver := serveResolver(t)
+	verify := func(cfg TransportConfig, target string, wantOK bool) {
+		t.Helper()
+		client := &http.Client{Transport: NewTransport(cfg), Timeout: 5 * time.Second}
+		resp, err := client.Get(target)
+		if err == nil {
+			resp.Body.Close()
+		}
+		if (err == nil) != wantOK {
+			t.Errorf("GET %s with %+v: %v", target, cfg, err)
+		}
+	}
+	verify(TransportConfig{Resolver: resolver}, "http://releases.example.test:"+port+"/", true)
+	verify(TransportConfig{Resolver: resolver, Network: "tcp4"}, "http://releases.example.test:"+port+"/", true)
+	verify(TransportConfig{Network: "tcp4"}, srv.URL, true)
+	verify(TransportConfig{Network: "tcp6"}, srv.URL, false)
+}
+
+func Test_fetcher_reuse(t *testing.T) {
+	var conns atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != "test" {
+			t.Error("missing User-Agent")
+		}
+		// Leave part of the body unread by the client.
+		w.Write(make([]byte, 4096))
+	}))
+	srv.Config.ConnState = func(_ net.Conn, s http.ConnState) {
+		if s == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	f := &fetcher{client: &http.Client{Transport: NewTransport(TransportConfig{})}, userAgent: "test"}
+	for range 3 {
+		resp, err := f.get(context.Background(), srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		drainClose(resp.Body)
+	}
+	if n := conns.Load(); n != 1 {
+		t.Errorf("connections = %d, want 1", n)
+	}
+}
@@ -0,0 +1,66 @@
+package selfupdate
+
+import (
+	"context"
+	"time"
+)
+
+// Clock is the time source of the scheduling done by an Updater:
+// maintenance windows (RunScheduled and the update policy), crash-loop
+// detection and backoff, release notice intervals, inventory reports and
+// the time stamps of Status. Durations measured for UpdateInfo and
+// traces always use the real clock. See selfupdatetest.Clock for a fake
+// that tests advance by hand.
+type Clock interface {
+	Now() time.Time
+	// NewTimer returns a Timer sending on its channel after d.
+	NewTimer(d time.Duration) Timer
+	// AfterFunc calls f in its own goroutine after d.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is the part of time.Timer used through a Clock. The channel of a
+// Timer created by AfterFunc is nil.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// SystemClock is the Clock of package time.
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time { return time.Now() }
+
+func (SystemClock) NewTimer(d time.Duration) Timer { return systemTimer{time.NewTimer(d)} }
+
+func (SystemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return systemTimer{time.AfterFunc(d, f)}
+}
+
+type systemTimer struct{ t *time.Timer }
+
+func (t systemTimer) C() <-chan time.Time        { return t.t.C }
+func (t systemTimer) Stop() bool                 { return t.t.Stop() }
+func (t systemTimer) Reset(d time.Duration) bool { return t.t.Reset(d) }
+
+// clock returns u.Clock, or SystemClock if it is nil.
+func (u *Updater) clock() Clock {
+	if u.Clock == nil {
+		return SystemClock{}
+	}
+	return u.Clock
+}
+
+// sleepUntil waits on u's clock until t and reports whether ctx is still
+// live.
+func (u *Updater) sleepUntil(ctx context.Context, t time.Time) bool {
+	timer := u.clock().NewTimer(t.Sub(u.clock().Now()))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C():
+		return true
+	}
+}
@@ -0,0 +1,142 @@
+package selfupdate
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// Decompressor wraps a compressed stream in a reader of the original bytes.
+type Decompressor func(r io.Reader) (io.ReadCloser, error)
+
+type compressionFormat struct {
+	ext      string // asset name suffix, e.g. ".gz"
+	encoding string // Content-Encoding token, "" if none
+	open     Decompressor
+}
+
+var (
+	compressionMu sync.RWMutex
+	compressions  = []compressionFormat{
+		{".gz", "gzip", func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) }},
+		{".bz2", "", func(r io.Reader) (io.ReadCloser, error) { return io.NopCloser(bzip2.NewReader(r)), nil }},
+		{".zst", "zstd", openZstd},
+		{".xz", "", openXZ},
+	}
+)
+
+// zstdMaxWindow bounds the memory a zstd frame may ask for.
+const zstdMaxWindow = 128 << 20
+
+func openZstd(r io.Reader) (io.ReadCloser, error) {
+	d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(zstdMaxWindow))
+	if err != nil {
+		return nil, err
+	}
+	return d.IOReadCloser(), nil
+}
+
+func openXZ(r io.Reader) (io.ReadCloser, error) {
+	x, err := xz.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(x), nil
+}
+
+// RegisterDecompressor makes assets whose name ends in ext, and responses
+// carrying the Content-Encoding encoding (if non-empty), decompress
+// transparently while downloading. Registering an existing ext replaces
+// it. gzip (".gz", "gzip"), bzip2 (".bz2"), zstd (".zst", "zstd") and xz
+// (".xz") are built in.
+func RegisterDecompressor(ext, encoding string, fn Decompressor) {
+	compressionMu.Lock()
+	defer compressionMu.Unlock()
+	ext = strings.ToLower(ext)
+	for i := range compressions {
+		if compressions[i].ext == ext {
+			compressions[i] = compressionFormat{ext, encoding, fn}
+			return
+		}
+	}
+	compressions = append(compressions, compressionFormat{ext, encoding, fn})
+}
+
+// compressionByName returns the decompressor for an asset name together
+// with the name stripped of its compression suffix. ".tgz" is treated as
+// ".tar.gz". fn is nil for uncompressed names.
+func compressionByName(name string) (base string, fn Decompressor) {
+	lower := strings.ToLower(name)
+	if strings.HasSuffix(lower, ".tgz") {
+		name, lower = name[:len(name)-len(".tgz")]+".tar.gz", lower[:len(lower)-len(".tgz")]+".tar.gz"
+	}
+	compressionMu.RLock()
+	defer compressionMu.RUnlock()
+	for _, c := range compressions {
+		if strings.HasSuffix(lower, c.ext) {
+			return name[:len(name)-len(c.ext)], c.open
+		}
+	}
+	return name, nil
+}
+
+// compressionByEncoding returns the decompressor for a Content-Encoding
+// token, or nil.
+func compressionByEncoding(encoding string) Decompressor {
+	encoding = strings.ToLower(strings.TrimSpace(encoding))
+	if encoding == "" || encoding == "identity" {
+		return nil
+	}
+	compressionMu.RLock()
+	defer compressionMu.RUnlock()
+	for _, c := range compressions {
+		if c.encoding == encoding {
+			return c.open
+		}
+	}
+	return nil
+}
+
+// acceptEncoding lists the registered Content-Encoding tokens.
+func acceptEncoding() string {
+	compressionMu.RLock()
+	defer compressionMu.RUnlock()
+	var encs []string
+	for _, c := range compressions {
+		if c.encoding != "" {
+			encs = append(encs, c.encoding)
+		}
+	}
+	return strings.Join(encs, ", ")
+}
+
+// decompressWriter returns a writer whose input is decompressed by fn into
+// dst, writing at most limit bytes. finish must be called once all input
+// has been written (with the writer-side error, if any); it returns the
+// first decompression or write error.
+func decompressWriter(dst io.Writer, fn Decompressor, limit int64) (w io.Writer, finish func(error) error) {
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		rc, err := fn(pr)
+		if err == nil {
+			_, err = io.Copy(dst, &limitedReader{r: rc, n: limit})
+			rc.Close()
+		}
+		// Unblock the writer if decoding stopped early.
+		pr.CloseWithError(err)
+		done <- err
+	}()
+	return pw, func(werr error) error {
+		pw.CloseWithError(werr)
+		if err := <-done; err != nil {
+			return err
+		}
+		return werr
+	}
+}
@@ -0,0 +1,124 @@
+package selfupdate
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write(data)
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func Test_compressionByName(t *testing.T) {
+	RegisterDecompressor(".rot13", "", func(r io.Reader) (io.ReadCloser, error) {
+		return io.NopCloser(r), nil
+	})
+	for name, want := range map[string]string{
+		"app.gz": "app", "app.tar.GZ": "app.tar", "app.tgz": "app.tar",
+		"app.bz2": "app", "app.zst": "app", "app.tar.xz": "app.tar", "app.rot13": "app",
+		"app": "app", "app.zip": "app.zip",
+	} {
+		base, fn := compressionByName(name)
+		if base != want || (fn == nil) != (base == name) {
+			t.Errorf("%s: got %q", name, base)
+		}
+	}
+	if compressionByEncoding("gzip") == nil || compressionByEncoding("zstd") == nil ||
+		compressionByEncoding("identity") != nil {
+		t.Error("encoding lookup mismatch")
+	}
+}
+
+func Test_builtinDecompressors(t *testing.T) {
+	plain := []byte(strings.Repeat("binary", 1000))
+	var zst, xzb bytes.Buffer
+	zw, _ := zstd.NewWriter(&zst)
+	zw.Write(plain)
+	zw.Close()
+	xw, _ := xz.NewWriter(&xzb)
+	xw.Write(plain)
+	xw.Close()
+	for name, compressed := range map[string][]byte{
+		"app.gz": gzipBytes(t, plain), "app.zst": zst.Bytes(), "app.xz": xzb.Bytes(),
+	} {
+		_, fn := compressionByName(name)
+		rc, err := fn(bytes.NewReader(compressed))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil || !bytes.Equal(b, plain) {
+			t.Errorf("%s: got %d bytes, %v", name, len(b), err)
+		}
+		if rc, err = fn(bytes.NewReader(compressed[:len(compressed)/2])); err == nil {
+			_, err = io.ReadAll(rc)
+			rc.Close()
+		}
+		if err == nil {
+			t.Errorf("%s: truncated stream accepted", name)
+		}
+	}
+}
+
+func Test_downloadFile_decompress(t *testing.T) {
+	plain := []byte(strings.Repeat("binary", 1000))
+	compressed := gzipBytes(t, plain)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/encoded" {
+			w.Header().Set("Content-Encoding", "gzip")
+		}
+		w.Write(compressed)
+	}))
+	defer srv.Close()
+	dst := filepath.Join(t.TempDir(), "out")
+	ctx := context.Background()
+
+	// A compressed asset is hashed as published and decoded into dst.
+	_, dec := compressionByName("app.gz")
+	res, err := testFetcher.downloadFile(ctx, srv.URL+"/app.gz", dst, downloadOptions{decompress: dec})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum := sha256.Sum256(compressed); !bytes.Equal(res.SHA256, sum[:]) {
+		t.Error("asset hash should cover the compressed bytes")
+	}
+	if b, _ := os.ReadFile(dst); !bytes.Equal(b, plain) {
+		t.Error("asset not decompressed")
+	}
+
+	// A Content-Encoding is transport framing: undone before hashing.
+	res, err = testFetcher.downloadFile(ctx, srv.URL+"/encoded", dst, downloadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum := sha256.Sum256(plain); !bytes.Equal(res.SHA256, sum[:]) {
+		t.Error("encoded response hash should cover the decoded bytes")
+	}
+
+	// Decompression is bounded.
+	_, err = testFetcher.downloadFile(ctx, srv.URL+"/app.gz", dst, downloadOptions{decompress: dec, maxSize: 100})
+	if !errors.Is(err, ErrUnsafeArchive) {
+		t.Error("expected ErrUnsafeArchive, got", err)
+	}
+}
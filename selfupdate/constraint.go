@@ -0,0 +1,110 @@
+package selfupdate
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Constraint restricts which versions may be installed, e.g.
+// ">=1.4.0, <2.0.0". Comma-separated terms must all hold. Supported
+// operators are =, !=, >, >=, <, <=, ~ (same minor: ~1.4.2 is
+// >=1.4.2, <1.5.0) and ^ (same major: ^1.4 is >=1.4.0, <2.0.0). A bare
+// version means =. The leading "v" is optional. An upper bound "<X.Y.Z"
+// also rejects X.Y.Z pre-releases. The zero Constraint admits every
+// version.
+type Constraint struct {
+	src   string
+	terms []constraintTerm
+}
+
+type constraintTerm struct {
+	op string
+	v  Version
+}
+
+// constraintOps is ordered so that two-character operators match first.
+var constraintOps = [...]string{">=", "<=", "!=", "==", ">", "<", "=", "~", "^"}
+
+// ParseConstraint parses a constraint expression. The empty string yields
+// the zero Constraint.
+func ParseConstraint(s string) (Constraint, error) {
+	c := Constraint{src: strings.TrimSpace(s)}
+	if c.src == "" {
+		return c, nil
+	}
+	for _, term := range strings.Split(c.src, ",") {
+		term = strings.TrimSpace(term)
+		op := "="
+		for _, o := range constraintOps {
+			if strings.HasPrefix(term, o) {
+				op, term = o, strings.TrimSpace(term[len(o):])
+				break
+			}
+		}
+		if op == "==" {
+			op = "="
+		}
+		if !strings.HasPrefix(term, "v") {
+			term = "v" + term
+		}
+		v := ParseVersion(term)
+		if !v.Parsed {
+			return Constraint{}, fmt.Errorf("invalid constraint %q: bad version %q", s, term)
+		}
+		switch op {
+		case "~":
+			c.terms = append(c.terms,
+				constraintTerm{">=", v},
+				constraintTerm{"<", Version{Parsed: true, Numbers: [3]int{v.Numbers[0], v.Numbers[1] + 1, 0}}})
+		case "^":
+			c.terms = append(c.terms,
+				constraintTerm{">=", v},
+				constraintTerm{"<", Version{Parsed: true, Numbers: [3]int{v.Numbers[0] + 1, 0, 0}}})
+		default:
+			c.terms = append(c.terms, constraintTerm{op, v})
+		}
+	}
+	return c, nil
+}
+
+// Check reports whether v satisfies every term of c. Unparsed versions
+// only satisfy the zero Constraint.
+func (c Constraint) Check(v Version) bool {
+	for _, t := range c.terms {
+		cmp, err := v.Compare(t.v)
+		if err != nil {
+			return false
+		}
+		var ok bool
+		switch t.op {
+		case "=":
+			ok = cmp == 0
+		case "!=":
+			ok = cmp != 0
+		case ">":
+			ok = cmp > 0
+		case ">=":
+			ok = cmp >= 0
+		case "<":
+			// "<2.0.0" is meant to stay below the 2.x line, so it also
+			// excludes the 2.0.0 pre-releases that sort just before it.
+			ok = cmp < 0 && !(v.IsPrerelease() && !t.v.IsPrerelease() && v.Numbers == t.v.Numbers)
+		case "<=":
+			ok = cmp <= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// IsZero reports whether c admits every version.
+func (c Constraint) IsZero() bool {
+	return len(c.terms) == 0
+}
+
+// String returns the expression c was parsed from.
+func (c Constraint) String() string {
+	return c.src
+}
@@ -0,0 +1,49 @@
+package selfupdate_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/msmania/updater/selfupdate"
+	"github.com/msmania/updater/selfupdate/selfupdatetest"
+)
+
+func Test_Updater_constraint(t *testing.T) {
+	skipIfDisabled(t)
+	srv := selfupdatetest.NewServer("owner", "app",
+		selfupdatetest.Release{Tag: "v1.4.0", Assets: []selfupdatetest.Asset{{Name: "app-bin", Content: []byte("v1.4")}}},
+		selfupdatetest.Release{Tag: "v2.0.0", Assets: []selfupdatetest.Asset{{Name: "app-bin", Content: []byte("v2")}}},
+	)
+	defer srv.Close()
+	u := newUpdater(t, srv, "v1.3.0")
+	u.Constraint, _ = selfupdate.ParseConstraint(">=1.0.0, <2.0.0")
+	if upgraded, err := u.MaybeUpgrade(context.Background()); err != nil || !upgraded {
+		t.Fatalf("constrained upgrade failed: %v", err)
+	}
+	if b, _ := os.ReadFile(u.Path); string(b) != "v1.4" {
+		t.Error("constraint crossed major version: " + string(b))
+	}
+}
+
+func Test_Updater_majorUpgrade(t *testing.T) {
+	skipIfDisabled(t)
+	srv := selfupdatetest.NewServer("owner", "app",
+		selfupdatetest.Release{Tag: "v2.0.0", Assets: []selfupdatetest.Asset{{Name: "app-bin", Content: []byte("v2")}}},
+	)
+	defer srv.Close()
+	u := newUpdater(t, srv, "v1.3.0")
+	var me *selfupdate.MajorUpgradeError
+	if _, err := u.MaybeUpgrade(context.Background()); !errors.As(err, &me) || me.Candidate != "v2.0.0" {
+		t.Fatal("expected MajorUpgradeError, got", err)
+	}
+	if b, _ := os.ReadFile(u.Path); string(b) != "old" {
+		t.Error("major upgrade installed without opt-in")
+	}
+
+	u.AllowMajorUpgrade = true
+	if upgraded, err := u.MaybeUpgrade(context.Background()); err != nil || !upgraded {
+		t.Fatalf("opted-in major upgrade failed: %v", err)
+	}
+}
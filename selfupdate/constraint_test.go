@@ -0,0 +1,42 @@
+package selfupdate
+
+import "testing"
+
+func Test_Constraint(t *testing.T) {
+	verify := func(expr, version string, want bool) {
+		c, err := ParseConstraint(expr)
+		if err != nil {
+			t.Error(expr, err)
+			return
+		}
+		if got := c.Check(ParseVersion(version)); got != want {
+			t.Error(expr, version, "got", got, "want", want)
+		}
+	}
+	verify("", "v9.9.9", true)
+	verify(">=1.4.0, <2.0.0", "v1.4.0", true)
+	verify(">=1.4.0, <2.0.0", "v1.9.12", true)
+	verify(">=1.4.0, <2.0.0", "v2.0.0", false)
+	verify(">=1.4.0, <2.0.0", "v2.0.0-rc1", false)
+	verify(">=1.4.0, <2.0.0", "v1.3.9", false)
+	verify("v1.2.3", "v1.2.3", true)
+	verify("==1.2", "v1.2.0", true)
+	verify("!=1.2.3", "v1.2.3", false)
+	verify("~1.4.2", "v1.4.9", true)
+	verify("~1.4.2", "v1.5.0", false)
+	verify("^1.4", "v1.99.0", true)
+	verify("^1.4", "v2.0.0", false)
+	verify("> 1.0.0", "v1.0.1", true)
+	verify("<=1.0.0", "v1.0.0-rc1", true)
+	verify(">=1.0.0", "garbage", false)
+
+	verifyFail := func(expr string) {
+		if _, err := ParseConstraint(expr); err == nil {
+			t.Error("expected error for", expr)
+		}
+	}
+	verifyFail(">=")
+	verifyFail(">=1.x")
+	verifyFail(">=1.0.0,")
+	verifyFail("=>1.0.0")
+}
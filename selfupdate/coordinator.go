@@ -0,0 +1,187 @@
+package selfupdate
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Coordinator limits how many replicas of a service install an update
+// and restart at the same time, so a fleet updates in waves. Adapters
+// for lock services such as etcd or Consul implement it; HTTPSemaphore
+// talks to a Semaphore over HTTP.
+type Coordinator interface {
+	// Acquire blocks until this instance may install and restart.
+	Acquire(ctx context.Context) error
+	// Release returns the slot. It is called when the install fails and,
+	// after the restart, once the new version runs stably (see Started).
+	// Releasing a slot that is not held is not an error.
+	Release(ctx context.Context) error
+}
+
+// DefaultLeaseTTL is how long an HTTPSemaphore slot is held when
+// HTTPSemaphore.TTL is zero. It bounds how long a replica that never
+// comes back blocks the next wave.
+const DefaultLeaseTTL = 10 * time.Minute
+
+// HTTPSemaphore is a Coordinator backed by a Semaphore served at URL.
+type HTTPSemaphore struct {
+	URL string
+	// Holder identifies this instance and must survive a restart.
+	// Defaults to the host name.
+	Holder string
+	// TTL is how long the slot is held unless released; see
+	// DefaultLeaseTTL.
+	TTL time.Duration
+	// PollInterval is the wait between attempts when the semaphore does
+	// not send Retry-After. Defaults to 10s.
+	PollInterval time.Duration
+	// Client defaults to that of the calling Updater; see Updater.Client.
+	Client *http.Client
+}
+
+func (s *HTTPSemaphore) holder() string {
+	if s.Holder != "" {
+		return s.Holder
+	}
+	name, _ := os.Hostname()
+	return name
+}
+
+func (s *HTTPSemaphore) do(ctx context.Context, method string, ttl time.Duration) (*http.Response, error) {
+	q := url.Values{"holder": {s.holder()}}
+	if ttl > 0 {
+		q.Set("ttl", strconv.Itoa(int(ttl.Seconds())))
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.URL+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return clientFor(ctx, s.Client).Do(req)
+}
+
+func (s *HTTPSemaphore) Acquire(ctx context.Context) error {
+	ttl := s.TTL
+	if ttl <= 0 {
+		ttl = DefaultLeaseTTL
+	}
+	for {
+		resp, err := s.do(ctx, http.MethodPost, ttl)
+		if err != nil {
+			return err
+		}
+		drainClose(resp.Body)
+		wait := s.PollInterval
+		if wait <= 0 {
+			wait = 10 * time.Second
+		}
+		switch resp.StatusCode {
+		case http.StatusOK:
+			return nil
+		case http.StatusConflict:
+			if sec, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && sec > 0 {
+				wait = time.Duration(sec) * time.Second
+			}
+		default:
+			return &HTTPError{URL: s.URL, StatusCode: resp.StatusCode}
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *HTTPSemaphore) Release(ctx context.Context) error {
+	resp, err := s.do(ctx, http.MethodDelete, 0)
+	if err != nil {
+		return err
+	}
+	drainClose(resp.Body)
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return &HTTPError{URL: s.URL, StatusCode: resp.StatusCode}
+	}
+	return nil
+}
+
+// Semaphore is an HTTP handler granting at most Limit concurrent leases,
+// the server side of HTTPSemaphore:
+//
+//	POST   ?holder=NAME&ttl=SECONDS  acquire or renew; 409 when full
+//	DELETE ?holder=NAME              release
+//	GET                              list the current leases
+//
+// Leases expire after their TTL so a replica that never returns does not
+// block the rollout. State is kept in memory.
+type Semaphore struct {
+	Limit int
+	// RetryAfter is suggested to waiting holders. Defaults to 10s.
+	RetryAfter time.Duration
+
+	mu     sync.Mutex
+	leases map[string]time.Time // holder -> expiry
+}
+
+// NewSemaphore returns a Semaphore granting limit concurrent leases.
+func NewSemaphore(limit int) *Semaphore {
+	return &Semaphore{Limit: limit}
+}
+
+func (s *Semaphore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	holder := r.URL.Query().Get("holder")
+	if r.Method != http.MethodGet && holder == "" {
+		http.Error(w, "missing holder", http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if s.leases == nil {
+		s.leases = map[string]time.Time{}
+	}
+	for h, exp := range s.leases {
+		if now.After(exp) {
+			delete(s.leases, h)
+		}
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.leases)
+	case http.MethodPost:
+		ttl := DefaultLeaseTTL
+		if v := r.URL.Query().Get("ttl"); v != "" {
+			sec, err := strconv.Atoi(v)
+			if err != nil || sec <= 0 {
+				http.Error(w, fmt.Sprintf("invalid ttl %q", v), http.StatusBadRequest)
+				return
+			}
+			ttl = time.Duration(sec) * time.Second
+		}
+		if _, held := s.leases[holder]; !held && len(s.leases) >= max(s.Limit, 1) {
+			retry := s.RetryAfter
+			if retry <= 0 {
+				retry = 10 * time.Second
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(int(retry.Seconds())))
+			http.Error(w, "all slots taken", http.StatusConflict)
+			return
+		}
+		s.leases[holder] = now.Add(ttl)
+		writeJSON(w, http.StatusOK, struct {
+			Holder  string    `json:"holder"`
+			Expires time.Time `json:"expires"`
+		}{holder, s.leases[holder]})
+	case http.MethodDelete:
+		delete(s.leases, holder)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,52 @@
+package selfupdate_test
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/msmania/updater/selfupdate"
+	"github.com/msmania/updater/selfupdate/selfupdatetest"
+)
+
+func Test_Updater_coordinator(t *testing.T) {
+	skipIfDisabled(t)
+	srv := selfupdatetest.NewServer("owner", "app",
+		selfupdatetest.Release{Tag: "v1.1.0", Assets: []selfupdatetest.Asset{{Name: "app-bin", Content: []byte("v1.1")}}},
+	)
+	defer srv.Close()
+	sem := selfupdate.NewSemaphore(1)
+	semSrv := httptest.NewServer(sem)
+	defer semSrv.Close()
+	ctx := context.Background()
+
+	other := &selfupdate.HTTPSemaphore{URL: semSrv.URL, Holder: "other"}
+	if err := other.Acquire(ctx); err != nil {
+		t.Fatal(err)
+	}
+	u := newUpdater(t, srv, "v1.0.0")
+	u.Coordinator = &selfupdate.HTTPSemaphore{URL: semSrv.URL, Holder: "me", PollInterval: time.Millisecond}
+	waitCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := u.Update(waitCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("install must wait for a slot, got", err)
+	}
+	if b, _ := os.ReadFile(u.Path); string(b) != "old" {
+		t.Error("installed without a slot")
+	}
+
+	other.Release(ctx)
+	if _, err := u.Update(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// The slot is held until the restarted process runs stably.
+	other.PollInterval = time.Millisecond
+	waitCtx, cancel = context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := other.Acquire(waitCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("slot released before the restart")
+	}
+}
@@ -0,0 +1,57 @@
+package selfupdate
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_Semaphore(t *testing.T) {
+	sem := NewSemaphore(1)
+	srv := httptest.NewServer(sem)
+	defer srv.Close()
+	ctx := context.Background()
+	a := &HTTPSemaphore{URL: srv.URL, Holder: "a", PollInterval: time.Millisecond}
+	b := &HTTPSemaphore{URL: srv.URL, Holder: "b", PollInterval: time.Millisecond}
+
+	if err := a.Acquire(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Acquire(ctx); err != nil {
+		t.Error("renewing a held slot failed:", err)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	if err := b.Acquire(waitCtx); err != context.DeadlineExceeded {
+		t.Error("second holder must wait, got", err)
+	}
+	cancel()
+
+	acquired := make(chan error, 1)
+	go func() { acquired <- b.Acquire(ctx) }()
+	if err := a.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-acquired; err != nil {
+		t.Error("slot not handed over:", err)
+	}
+	if err := a.Release(ctx); err != nil {
+		t.Error("releasing an unheld slot must succeed:", err)
+	}
+
+	rec := httptest.NewRecorder()
+	sem.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	var leases map[string]time.Time
+	if err := json.Unmarshal(rec.Body.Bytes(), &leases); err != nil || len(leases) != 1 || leases["b"].IsZero() {
+		t.Error("unexpected leases: " + rec.Body.String())
+	}
+
+	// Expired leases free their slot.
+	sem.mu.Lock()
+	sem.leases["b"] = time.Now().Add(-time.Second)
+	sem.mu.Unlock()
+	if err := a.Acquire(ctx); err != nil {
+		t.Error("expired lease still blocks:", err)
+	}
+}
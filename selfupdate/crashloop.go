@@ -0,0 +1,315 @@
+package selfupdate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// CrashLoopConfig enables crash-loop detection; see Updater.Started. It
+// requires Updater.StateDir.
+type CrashLoopConfig struct {
+	// Threshold is the number of consecutive early exits after which
+	// updates are suspended and the previous version is restored. Zero
+	// disables detection.
+	Threshold int
+	// StableAfter is how long a run must last to not count as an early
+	// exit. Defaults to one minute.
+	StableAfter time.Duration
+	// Backoff is how long updates stay suspended. Defaults to one hour.
+	Backoff time.Duration
+}
+
+const (
+	// healthFile is the name of the persisted run health below StateDir.
+	healthFile = "health.json"
+	// previousFile is the copy of the executable replaced by the last
+	// update, kept below StateDir for a rollback.
+	previousFile = "previous"
+)
+
+// runHealth tracks the starts of the installed version.
+type runHealth struct {
+	Version        string    `json:"version"`
+	EarlyExits     int       `json:"early_exits"`
+	SuspendedUntil time.Time `json:"suspended_until,omitzero"`
+	// Previous is the version saved as previousFile, if any.
+	Previous    string      `json:"previous,omitempty"`
+	PreviousAux []auxBackup `json:"previous_aux,omitempty"`
+	BadVersions []string    `json:"bad_versions,omitempty"`
+}
+
+func (u *Updater) crashLoopEnabled() bool {
+	return u.CrashLoop.Threshold > 0 && u.StateDir != ""
+}
+
+// updateHealth applies fn to the persisted run health.
+func (u *Updater) updateHealth(fn func(h *runHealth)) (runHealth, error) {
+	u.healthMu.Lock()
+	defer u.healthMu.Unlock()
+	var h runHealth
+	if err := u.readState(healthFile, &h); err != nil {
+		return h, err
+	}
+	fn(&h)
+	return h, u.writeState(healthFile, h)
+}
+
+func (u *Updater) readHealth() (runHealth, error) {
+	u.healthMu.Lock()
+	defer u.healthMu.Unlock()
+	var h runHealth
+	err := u.readState(healthFile, &h)
+	return h, err
+}
+
+// Started records a start of the running version for crash-loop
+// detection and should be called once on startup. A start counts as an
+// early exit until the process has run for CrashLoop.StableAfter. After
+// CrashLoop.Threshold early exits in a row, updates are suspended for
+// CrashLoop.Backoff, the crashing version is never installed again, and
+// the version it replaced is restored if a copy was kept; rolledBack then
+// reports that the caller should exit to be restarted. With a
+// Coordinator, the rollout slot is released once the run is stable.
+// After an install with Canary set, the readiness check of the new
+// version starts in the background.
+func (u *Updater) Started(ctx context.Context) (rolledBack bool, err error) {
+	u.startCanary(ctx)
+	if !u.crashLoopEnabled() {
+		u.whenStable(func() {})
+		return false, nil
+	}
+	version := u.Build.Version
+	var tripped bool
+	h, err := u.updateHealth(func(h *runHealth) {
+		if h.Version != version {
+			*h = runHealth{Version: version, Previous: h.Previous, PreviousAux: h.PreviousAux,
+				BadVersions: h.BadVersions, SuspendedUntil: h.SuspendedUntil}
+		}
+		h.EarlyExits++
+		if h.EarlyExits >= u.CrashLoop.Threshold {
+			tripped = true
+			h.SuspendedUntil = u.clock().Now().Add(orDefault(u.CrashLoop.Backoff, time.Hour))
+		}
+	})
+	if err != nil {
+		return false, fmt.Errorf("cannot record start: %w", err)
+	}
+	if !tripped {
+		u.whenStable(func() {
+			if _, err := u.updateHealth(func(h *runHealth) {
+				if h.Version == version {
+					h.EarlyExits = 0
+				}
+			}); err != nil {
+				u.logf("cannot record stable run: %v", err)
+			}
+		})
+		return false, nil
+	}
+
+	u.logf("WARNING: %s exited early %d times in a row; suspending updates until %s",
+		version, h.EarlyExits, h.SuspendedUntil.Format(time.RFC3339))
+	if h.Previous == "" || h.Previous == version {
+		return false, nil
+	}
+	if err := u.restorePrevious(WithAuditSource(ctx, "crash-loop"), h.Previous); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Rollback restores the version replaced by the last update, which is
+// kept below StateDir, and excludes the running version from future
+// updates as a crash loop would. It returns the restored version; the
+// caller should then exit to be restarted.
+func (u *Updater) Rollback(ctx context.Context) (string, error) {
+	if !Enabled() {
+		return "", ErrDisabled
+	}
+	if u.StateDir == "" {
+		return "", fmt.Errorf("%w: no StateDir", ErrNoRollback)
+	}
+	if !u.busy.CompareAndSwap(false, true) {
+		return "", ErrBusy
+	}
+	defer u.busy.Store(false)
+	h, err := u.readHealth()
+	if err != nil {
+		return "", err
+	}
+	if h.Previous == "" || h.Previous == u.Build.Version {
+		return "", ErrNoRollback
+	}
+	if err := u.restorePrevious(ctx, h.Previous); err != nil {
+		return "", err
+	}
+	return h.Previous, nil
+}
+
+// restorePrevious rolls back to the kept copy of version and records the
+// running version as bad.
+func (u *Updater) restorePrevious(ctx context.Context, version string) error {
+	from, start := u.Build.Version, time.Now()
+	entry := func() HistoryEntry {
+		return HistoryEntry{From: from, To: version, Decision: DecisionRolledBack,
+			Trigger: auditSource(ctx), Durations: Durations{Total: time.Since(start)}}
+	}
+	if err := u.rollback(ctx, version); err != nil {
+		e := entry()
+		e.Error = err.Error()
+		u.recordHistory(e)
+		return fmt.Errorf("rollback to %s failed: %w", version, err)
+	}
+	if h, err := u.readHealth(); err != nil {
+		u.logf("WARNING: cannot read auxiliary files to restore: %v", err)
+	} else if err := u.restoreAux(h.PreviousAux); err != nil {
+		u.logf("WARNING: cannot restore auxiliary files: %v", err)
+	}
+	_, err := u.updateHealth(func(h *runHealth) {
+		if !slices.Contains(h.BadVersions, from) {
+			h.BadVersions = append(h.BadVersions, from)
+		}
+		h.Version, h.EarlyExits, h.Previous, h.PreviousAux = version, 0, "", nil
+	})
+	u.audit(ctx, AuditRollback, version, "")
+	u.recordHistory(entry())
+	u.logf("%s", u.message(MsgRolledBack, MessageData{Current: from, Release: version}))
+	return err
+}
+
+// whenStable calls fn once the process has run for CrashLoop.StableAfter
+// and then releases the rollout slot, if any.
+func (u *Updater) whenStable(fn func()) {
+	if !u.crashLoopEnabled() && u.Coordinator == nil {
+		return
+	}
+	u.clock().AfterFunc(orDefault(u.CrashLoop.StableAfter, time.Minute), func() {
+		fn()
+		if u.Coordinator == nil {
+			return
+		}
+		ctx, cancel := context.WithTimeout(withClient(context.Background(), u.Client()), 30*time.Second)
+		defer cancel()
+		if err := u.Coordinator.Release(ctx); err != nil {
+			u.logf("cannot release rollout slot: %v", err)
+		}
+	})
+}
+
+// orDefault returns d, or def if d is not positive.
+func orDefault(d, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d
+}
+
+// suspended returns an ErrCrashLoop error while updates are suspended.
+func (u *Updater) suspended() error {
+	if !u.crashLoopEnabled() {
+		return nil
+	}
+	h, err := u.readHealth()
+	if err != nil {
+		return err
+	}
+	if u.clock().Now().Before(h.SuspendedUntil) {
+		return fmt.Errorf("%w until %s", ErrCrashLoop, h.SuspendedUntil.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// badVersion reports whether tag was rolled back.
+func (u *Updater) badVersion(tag string) bool {
+	if u.StateDir == "" {
+		return false
+	}
+	h, err := u.readHealth()
+	return err == nil && slices.Contains(h.BadVersions, tag)
+}
+
+// keepPrevious copies the executable about to be replaced into StateDir
+// so that Started or Rollback can restore it.
+func (u *Updater) keepPrevious(exePath string) error {
+	if u.StateDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(u.StateDir, 0o755); err != nil {
+		return err
+	}
+	if _, err := os.Stat(exePath); errors.Is(err, fs.ErrNotExist) {
+		// The first install into Overlay.Dir keeps the read-only original.
+		if exePath, err = u.path(); err != nil {
+			return err
+		}
+	}
+	if err := copyFile(exePath, filepath.Join(u.StateDir, previousFile)); err != nil {
+		return err
+	}
+	_, err := u.updateHealth(func(h *runHealth) { h.Previous = u.Build.Version })
+	return err
+}
+
+// rollback installs the kept copy of version over Path with the Applier.
+func (u *Updater) rollback(ctx context.Context, version string) error {
+	exePath, err := u.path()
+	if err == nil {
+		exePath, err = u.overlayPath(exePath)
+	}
+	if err != nil {
+		return err
+	}
+	applier := u.applier()
+	// Still installed, so no copy is needed.
+	if vd, ok := applier.(VersionDirs); ok && vd.Activate(version, exePath) == nil {
+		u.recordInstalled(version, exePath)
+		return os.Remove(filepath.Join(u.StateDir, previousFile))
+	}
+	staged, err := u.workPath(exePath, ".new")
+	if err != nil {
+		return err
+	}
+	if err := copyFile(filepath.Join(u.StateDir, previousFile), staged); err != nil {
+		return err
+	}
+	a := Artifact{Path: staged, Name: filepath.Base(exePath), Tag: version}
+	if err := applier.Apply(ctx, a, exePath); err != nil {
+		os.Remove(staged)
+		return err
+	}
+	u.recordInstalled(version, exePath)
+	return os.Remove(filepath.Join(u.StateDir, previousFile))
+}
+
+// copyFile copies src to dst with mode 0755, replacing dst atomically.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o755); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
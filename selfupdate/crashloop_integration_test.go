@@ -0,0 +1,67 @@
+package selfupdate_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/msmania/updater/selfupdate"
+	"github.com/msmania/updater/selfupdate/selfupdatetest"
+)
+
+func Test_Updater_rollback(t *testing.T) {
+	skipIfDisabled(t)
+	srv := selfupdatetest.NewServer("owner", "app",
+		selfupdatetest.Release{Tag: "v1.1.0", Assets: []selfupdatetest.Asset{{Name: "app-bin", Content: []byte("v1.1")}}},
+	)
+	defer srv.Close()
+	u := newUpdater(t, srv, "v1.0.0")
+	u.StateDir = t.TempDir()
+	ctx := context.Background()
+	if _, err := u.Update(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The restarted process rolls back through the API.
+	u = &selfupdate.Updater{Owner: u.Owner, Repo: u.Repo, AssetName: u.AssetName, APIURL: u.APIURL,
+		Path: u.Path, StateDir: u.StateDir, Build: selfupdate.BuildInfo{Version: "v1.1.0"}}
+	restarted := false
+	u.AfterUpgrade = func() { restarted = true }
+	rec := httptest.NewRecorder()
+	u.Handler().ServeHTTP(rec, httptest.NewRequest("POST", "/rollback", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"v1.0.0"`) || !restarted {
+		t.Fatalf("rollback failed: %d %s", rec.Code, rec.Body.String())
+	}
+	if b, _ := os.ReadFile(u.Path); string(b) != "old" {
+		t.Error("previous binary not restored: " + string(b))
+	}
+
+	rec = httptest.NewRecorder()
+	u.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/history", nil))
+	var history []selfupdate.HistoryEntry
+	json.Unmarshal(rec.Body.Bytes(), &history)
+	if len(history) != 2 || history[0].Decision != selfupdate.DecisionRolledBack ||
+		history[1].Decision != selfupdate.DecisionUpgraded || history[1].To != "v1.1.0" ||
+		history[0].Trigger != "http" || history[1].Trigger != "api" || history[1].Durations.Total <= 0 {
+		t.Errorf("unexpected history %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	u.Handler().ServeHTTP(rec, httptest.NewRequest("POST", "/rollback", nil))
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Error("second rollback must fail, got", rec.Code)
+	}
+
+	u.Build.Version = "v1.0.0"
+	rec = httptest.NewRecorder()
+	u.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/check", nil))
+	var info selfupdate.UpdateInfo
+	json.Unmarshal(rec.Body.Bytes(), &info)
+	if info.Decision != selfupdate.DecisionExcluded || info.Remote != "v1.1.0" {
+		t.Errorf("rolled back release must stay excluded: %s", rec.Body.String())
+	}
+}
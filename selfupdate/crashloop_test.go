@@ -0,0 +1,122 @@
+package selfupdate
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_Started_rollback(t *testing.T) {
+	dir := t.TempDir()
+	u := &Updater{
+		Build:     BuildInfo{Version: "v1.0.0"},
+		Path:      filepath.Join(dir, "app"),
+		StateDir:  filepath.Join(dir, "state"),
+		CrashLoop: CrashLoopConfig{Threshold: 3, StableAfter: time.Hour},
+	}
+	ctx := context.Background()
+	os.WriteFile(u.Path, []byte("v1"), 0o755)
+	if err := u.keepPrevious(u.Path); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(u.Path, []byte("v2"), 0o755)
+
+	u.Build.Version = "v2.0.0"
+	for i := range 2 {
+		if rolledBack, err := u.Started(ctx); rolledBack || err != nil {
+			t.Fatalf("start %d: unexpected rollback (%v)", i, err)
+		}
+	}
+	if err := u.suspended(); err != nil {
+		t.Error("updates suspended too early:", err)
+	}
+	rolledBack, err := u.Started(ctx)
+	if !rolledBack || err != nil {
+		t.Fatalf("expected rollback, got %v", err)
+	}
+	if b, _ := os.ReadFile(u.Path); string(b) != "v1" {
+		t.Error("previous binary not restored: " + string(b))
+	}
+	if err := u.suspended(); !errors.Is(err, ErrCrashLoop) {
+		t.Error("expected ErrCrashLoop, got", err)
+	}
+	if !u.badVersion("v2.0.0") || u.badVersion("v1.0.0") {
+		t.Error("crashing version not recorded")
+	}
+	if h, _ := u.readHealth(); h.Version != "v1.0.0" || h.EarlyExits != 0 || h.Previous != "" {
+		t.Errorf("unexpected health %+v", h)
+	}
+}
+
+func Test_Started_stable(t *testing.T) {
+	u := &Updater{
+		Build:     BuildInfo{Version: "v1.0.0"},
+		StateDir:  t.TempDir(),
+		CrashLoop: CrashLoopConfig{Threshold: 2, StableAfter: 10 * time.Millisecond},
+	}
+	if _, err := u.Started(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if h, _ := u.readHealth(); h.EarlyExits != 1 {
+		t.Errorf("start not counted: %+v", h)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		h, _ := u.readHealth()
+		if h.EarlyExits == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("stable run not recorded")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	// Without a kept copy the loop only suspends updates.
+	u.CrashLoop.StableAfter = time.Hour
+	u.Started(context.Background())
+	if rolledBack, err := u.Started(context.Background()); rolledBack || err != nil {
+		t.Error("nothing to roll back to", err)
+	}
+	if err := u.suspended(); !errors.Is(err, ErrCrashLoop) {
+		t.Error("expected ErrCrashLoop, got", err)
+	}
+}
+
+func Test_Rollback(t *testing.T) {
+	skipIfDisabled(t)
+	dir := t.TempDir()
+	u := &Updater{Build: BuildInfo{Version: "v1.0.0"}, Path: filepath.Join(dir, "app")}
+	ctx := context.Background()
+	if _, err := u.Rollback(ctx); !errors.Is(err, ErrNoRollback) {
+		t.Error("expected ErrNoRollback without StateDir, got", err)
+	}
+	u.StateDir = filepath.Join(dir, "state")
+	if _, err := u.Rollback(ctx); !errors.Is(err, ErrNoRollback) {
+		t.Error("expected ErrNoRollback without a kept copy, got", err)
+	}
+
+	os.WriteFile(u.Path, []byte("v1"), 0o755)
+	if err := u.keepPrevious(u.Path); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(u.Path, []byte("v2"), 0o755)
+	u.Build.Version = "v2.0.0"
+	if v, err := u.Rollback(ctx); v != "v1.0.0" || err != nil {
+		t.Fatalf("rollback failed: %q %v", v, err)
+	}
+	if b, _ := os.ReadFile(u.Path); string(b) != "v1" {
+		t.Error("previous binary not restored: " + string(b))
+	}
+	if !u.badVersion("v2.0.0") {
+		t.Error("rolled back version not excluded")
+	}
+	if h := u.History(); len(h) != 1 || h[0].Decision != DecisionRolledBack || h[0].From != "v2.0.0" || h[0].To != "v1.0.0" {
+		t.Errorf("unexpected history %+v", h)
+	}
+	if _, err := u.Rollback(ctx); !errors.Is(err, ErrNoRollback) {
+		t.Error("the kept copy must be used only once, got", err)
+	}
+}
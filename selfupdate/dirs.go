@@ -0,0 +1,79 @@
+package selfupdate
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// DefaultStateDir returns a directory for the persistent state of the
+// named program, for Updater.StateDir: $STATE_DIRECTORY as set by
+// systemd's StateDirectory=, /var/lib/<name> when running as root, and
+// otherwise $XDG_STATE_HOME/<name> or ~/.local/state/<name>. On Windows
+// it is <name> below %LocalAppData%. It returns "" if no home directory
+// is known.
+func DefaultStateDir(name string) string {
+	return defaultDir("STATE_DIRECTORY", "/var/lib", name, func() (string, error) {
+		if runtime.GOOS == "windows" {
+			dir, err := os.UserCacheDir()
+			return filepath.Join(dir, name), err
+		}
+		if dir := os.Getenv("XDG_STATE_HOME"); filepath.IsAbs(dir) {
+			return filepath.Join(dir, name), nil
+		}
+		home, err := os.UserHomeDir()
+		return filepath.Join(home, ".local", "state", name), err
+	})
+}
+
+// DefaultCacheDir returns a directory for the downloads of the named
+// program, for Updater.WorkDir: $CACHE_DIRECTORY as set by systemd's
+// CacheDirectory=, /var/cache/<name> when running as root, and otherwise
+// <name> below os.UserCacheDir, or <name>\cache on Windows. It returns ""
+// if no home directory is known.
+func DefaultCacheDir(name string) string {
+	return defaultDir("CACHE_DIRECTORY", "/var/cache", name, func() (string, error) {
+		dir, err := os.UserCacheDir()
+		if runtime.GOOS == "windows" {
+			return filepath.Join(dir, name, "cache"), err
+		}
+		return filepath.Join(dir, name), err
+	})
+}
+
+// DefaultDataDir returns a directory for the installed files of the
+// named program, for VersionDirs.Root: /opt/<name> when running as root,
+// and otherwise $XDG_DATA_HOME/<name> or ~/.local/share/<name>. On
+// Windows it is <name> below %LocalAppData%. It returns "" if no home
+// directory is known.
+func DefaultDataDir(name string) string {
+	return defaultDir("", "/opt", name, func() (string, error) {
+		if runtime.GOOS == "windows" {
+			dir, err := os.UserCacheDir()
+			return filepath.Join(dir, name), err
+		}
+		if dir := os.Getenv("XDG_DATA_HOME"); filepath.IsAbs(dir) {
+			return filepath.Join(dir, name), nil
+		}
+		home, err := os.UserHomeDir()
+		return filepath.Join(home, ".local", "share", name), err
+	})
+}
+
+// defaultDir implements DefaultStateDir, DefaultCacheDir and
+// DefaultDataDir. The
+// directories systemd passes in env already end in the unit's name.
+func defaultDir(env, system, name string, user func() (string, error)) string {
+	if dirs := os.Getenv(env); dirs != "" {
+		return strings.Split(dirs, ":")[0]
+	}
+	if runtime.GOOS != "windows" && os.Geteuid() == 0 {
+		return filepath.Join(system, name)
+	}
+	dir, err := user()
+	if err != nil {
+		return ""
+	}
+	return dir
+}
@@ -0,0 +1,46 @@
+package selfupdate_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/msmania/updater/selfupdate"
+	"github.com/msmania/updater/selfupdate/selfupdatetest"
+)
+
+func Test_Updater_workDir(t *testing.T) {
+	skipIfDisabled(t)
+	srv := selfupdatetest.NewServer("owner", "app",
+		selfupdatetest.Release{Tag: "v1.1.0", Assets: []selfupdatetest.Asset{{Name: "app-bin", Content: []byte("v1.1")}}},
+	)
+	defer srv.Close()
+	u := newUpdater(t, srv, "v1.0.0")
+	u.WorkDir, u.Staged = filepath.Join(t.TempDir(), "cache"), true
+	verify := func(dir string, want ...string) {
+		t.Helper()
+		var names []string
+		entries, _ := os.ReadDir(dir)
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		if strings.Join(names, " ") != strings.Join(want, " ") {
+			t.Errorf("%s: expected %v, got %v", dir, want, names)
+		}
+	}
+	if info, err := u.Update(context.Background()); err != nil || info.Decision != selfupdate.DecisionStaged {
+		t.Fatalf("expected a staged update, got %s: %v", info.Decision, err)
+	}
+	verify(filepath.Dir(u.Path), "app")
+	verify(u.WorkDir, "app.staged", "app.staged.json")
+	if _, err := u.ActivateStaged(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(u.Path); string(b) != "v1.1" {
+		t.Error("staged binary not installed: " + string(b))
+	}
+	verify(filepath.Dir(u.Path), "app")
+	verify(u.WorkDir)
+}
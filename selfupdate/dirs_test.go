@@ -0,0 +1,71 @@
+package selfupdate
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func Test_DefaultStateDir(t *testing.T) {
+	t.Setenv("STATE_DIRECTORY", "/var/lib/app:/var/lib/other")
+	t.Setenv("CACHE_DIRECTORY", "/var/cache/app")
+	if dir := DefaultStateDir("app"); dir != "/var/lib/app" {
+		t.Error("systemd state directory not used: " + dir)
+	}
+	if dir := DefaultCacheDir("app"); dir != "/var/cache/app" {
+		t.Error("systemd cache directory not used: " + dir)
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	t.Setenv("STATE_DIRECTORY", "")
+	t.Setenv("CACHE_DIRECTORY", "")
+	xdg := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", filepath.Join(xdg, "state"))
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(xdg, "cache"))
+	t.Setenv("XDG_DATA_HOME", filepath.Join(xdg, "data"))
+	wantState, wantCache := filepath.Join(xdg, "state", "app"), filepath.Join(xdg, "cache", "app")
+	wantData := filepath.Join(xdg, "data", "app")
+	if runtime.GOOS == "darwin" {
+		wantCache = DefaultCacheDir("app") // os.UserCacheDir ignores XDG there
+	}
+	if os.Geteuid() == 0 {
+		wantState, wantCache, wantData = "/var/lib/app", "/var/cache/app", "/opt/app"
+	}
+	if dir := DefaultStateDir("app"); dir != wantState {
+		t.Errorf("expected state directory %s, got %s", wantState, dir)
+	}
+	if dir := DefaultCacheDir("app"); dir != wantCache {
+		t.Errorf("expected cache directory %s, got %s", wantCache, dir)
+	}
+	if dir := DefaultDataDir("app"); dir != wantData {
+		t.Errorf("expected data directory %s, got %s", wantData, dir)
+	}
+}
+
+func Test_Updater_workPath(t *testing.T) {
+	cache := t.TempDir()
+	t.Setenv("CACHE_DIRECTORY", cache)
+	exeDir, workDir := t.TempDir(), filepath.Join(t.TempDir(), "work")
+	exe := filepath.Join(exeDir, "app")
+	verify := func(u *Updater, want string) {
+		t.Helper()
+		if got, err := u.workPath(exe, ".new"); err != nil || got != want {
+			t.Errorf("workPath() = %s, %v, want %s", got, err, want)
+		}
+	}
+	verify(&Updater{}, filepath.Join(exeDir, "app.new"))
+	verify(&Updater{WorkDir: workDir}, filepath.Join(workDir, "app.new"))
+	if entries, _ := os.ReadDir(exeDir); len(entries) != 0 {
+		t.Errorf("workPath left %d files behind", len(entries))
+	}
+
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		return // permissions do not stop the probe
+	}
+	os.Chmod(exeDir, 0o555)
+	defer os.Chmod(exeDir, 0o755)
+	verify(&Updater{}, filepath.Join(cache, "app.new"))
+}
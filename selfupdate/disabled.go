@@ -0,0 +1,9 @@
+//go:build noselfupdate
+
+package selfupdate
+
+// compiledIn is false in builds with the noselfupdate tag. The update
+// code is still linked into such builds; the tag only makes Enabled
+// report false, like the disableSelfUpdate ldflags switch, but cannot be
+// undone without rebuilding.
+const compiledIn = false
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package selfupdate
+
+import "errors"
+
+func diskFree(dir string) (uint64, error) {
+	return 0, errors.New("free space is not known on this platform")
+}
+
+func sameFileSystem(a, b string) (same, known bool) {
+	return false, false
+}
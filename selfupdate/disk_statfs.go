@@ -0,0 +1,25 @@
+//go:build linux || darwin || freebsd
+
+package selfupdate
+
+import "syscall"
+
+// diskFree returns the bytes available to this user on the file system
+// holding dir.
+func diskFree(dir string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
+
+// sameFileSystem reports whether the existing paths a and b are on one
+// file system; known is false if that cannot be told.
+func sameFileSystem(a, b string) (same, known bool) {
+	var sa, sb syscall.Stat_t
+	if syscall.Stat(a, &sa) != nil || syscall.Stat(b, &sb) != nil {
+		return false, false
+	}
+	return sa.Dev == sb.Dev, true
+}
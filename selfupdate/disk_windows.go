@@ -0,0 +1,41 @@
+//go:build windows
+
+package selfupdate
+
+import (
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+var procGetDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// diskFree returns the bytes available to this user on the volume
+// holding dir.
+func diskFree(dir string) (uint64, error) {
+	p, err := syscall.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, err
+	}
+	var free uint64
+	r, _, err := procGetDiskFreeSpaceEx.Call(uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(&free)), 0, 0)
+	if r == 0 {
+		return 0, err
+	}
+	return free, nil
+}
+
+// sameFileSystem reports whether the paths a and b are on one volume;
+// known is false if that cannot be told.
+func sameFileSystem(a, b string) (same, known bool) {
+	va, err := filepath.Abs(a)
+	if err != nil {
+		return false, false
+	}
+	vb, err := filepath.Abs(b)
+	if err != nil {
+		return false, false
+	}
+	return strings.EqualFold(filepath.VolumeName(va), filepath.VolumeName(vb)), true
+}
@@ -0,0 +1,262 @@
+package selfupdate
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// DNSSource discovers the latest release from DNS TXT records instead of
+// the GitHub API, for devices too constrained for it. Each record of Name
+// describes one build:
+//
+//	"v=updater1 version=v1.4.0 url=https://dl.example.com/app-linux-amd64 sha256=9f86d081…"
+//
+// Optional os= and arch= keys restrict a record to a platform. The record
+// for the running platform is installed, or else the one without them.
+// Channels and constraints apply to its version as to GitHub releases;
+// checksum assets, keyrings and the other features reading further
+// release assets do not work with it.
+type DNSSource struct {
+	// Name is the domain holding the records, e.g. "_updater.example.com".
+	Name string
+	// DNSSECResolver, if set, is the host:port of a validating resolver,
+	// queried over TCP instead of the system resolver. Answers it does
+	// not mark as authenticated are rejected.
+	DNSSECResolver string
+}
+
+// dnsRecordVersion is the v= key of the records of a DNSSource.
+const dnsRecordVersion = "updater1"
+
+// lookupTXT resolves TXT records with the system resolver; a variable
+// for tests.
+var lookupTXT = net.DefaultResolver.LookupTXT
+
+// dnsRelease returns the release described by the TXT records of u.DNS,
+// which must be tagged pin if set.
+func (u *Updater) dnsRelease(ctx context.Context, pin string) (*ghRelease, *ghAsset, error) {
+	var records []string
+	var err error
+	if u.DNS.DNSSECResolver != "" {
+		records, err = queryTXTSecure(ctx, u.DNS.DNSSECResolver, u.DNS.Name)
+	} else {
+		records, err = lookupTXT(ctx, u.DNS.Name)
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return nil, nil, fmt.Errorf("%w: no TXT records at %s", ErrNoRelease, u.DNS.Name)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("release lookup at %s: %w", u.DNS.Name, err)
+	}
+	var generic, native map[string]string
+	for _, r := range records {
+		fields := parseDNSRecord(r)
+		if fields["v"] != dnsRecordVersion {
+			continue
+		}
+		switch {
+		case fields["os"] == "" && fields["arch"] == "":
+			generic = fields
+		case fields["os"] == runtime.GOOS && fields["arch"] == runtime.GOARCH,
+			fields["os"] == runtime.GOOS && fields["arch"] == "",
+			fields["os"] == "" && fields["arch"] == runtime.GOARCH:
+			native = fields
+		}
+	}
+	fields := native
+	if fields == nil {
+		fields = generic
+	}
+	if fields == nil {
+		return nil, nil, fmt.Errorf("%w: no %s record for %s/%s at %s", ErrNoRelease, dnsRecordVersion,
+			runtime.GOOS, runtime.GOARCH, u.DNS.Name)
+	}
+	tag, url := fields["version"], fields["url"]
+	if tag == "" || url == "" {
+		return nil, nil, fmt.Errorf("record at %s lacks version= or url=", u.DNS.Name)
+	}
+	if !ParseVersion(tag).Parsed {
+		return nil, nil, fmt.Errorf("record at %s: invalid version %q", u.DNS.Name, tag)
+	}
+	if pin != "" && pin != tag {
+		return nil, nil, fmt.Errorf("%w: no release tagged %s at %s", ErrNoRelease, pin, u.DNS.Name)
+	}
+	asset := ghAsset{Name: path.Base(url), BrowserDownloadURL: url}
+	if sum := fields["sha256"]; sum != "" {
+		if b, err := hex.DecodeString(sum); err != nil || len(b) != 32 {
+			return nil, nil, fmt.Errorf("record at %s: invalid sha256 %q", u.DNS.Name, sum)
+		}
+		asset.Digest = "sha256:" + strings.ToLower(sum)
+	}
+	rel := &ghRelease{TagName: tag, Assets: []ghAsset{asset}}
+	return rel, &rel.Assets[0], nil
+}
+
+// parseDNSRecord splits a record into its key=value fields.
+func parseDNSRecord(r string) map[string]string {
+	fields := map[string]string{}
+	for _, f := range strings.Fields(r) {
+		if k, v, ok := strings.Cut(f, "="); ok {
+			fields[strings.ToLower(k)] = v
+		}
+	}
+	return fields
+}
+
+// DNS message constants used by queryTXTSecure.
+const (
+	dnsTypeTXT   = 16
+	dnsTypeOPT   = 41
+	dnsFlagRD    = 0x0100
+	dnsFlagAD    = 0x0020
+	dnsFlagTC    = 0x0200
+	dnsFlagQR    = 0x8000
+	dnsEDNSDO    = 0x8000
+	dnsRcodeMask = 0x000f
+	dnsNXDomain  = 3
+)
+
+// queryTXTSecure asks the resolver at server, over TCP with the DNSSEC OK
+// bit, for the TXT records of name, and fails unless the answer is
+// authenticated.
+func queryTXTSecure(ctx context.Context, server, name string) ([]string, error) {
+	query, id, err := buildTXTQuery(name)
+	if err != nil {
+		return nil, err
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(10 * time.Second)
+	}
+	conn.SetDeadline(deadline)
+	msg := binary.BigEndian.AppendUint16(nil, uint16(len(query)))
+	if _, err := conn.Write(append(msg, query...)); err != nil {
+		return nil, err
+	}
+	var size [2]byte
+	if _, err := io.ReadFull(conn, size[:]); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, binary.BigEndian.Uint16(size[:]))
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return nil, err
+	}
+	return parseTXTResponse(resp, id, name)
+}
+
+// buildTXTQuery returns a recursive TXT query for name with an EDNS
+// record setting the DNSSEC OK bit, and its ID.
+func buildTXTQuery(name string) ([]byte, uint16, error) {
+	var idb [2]byte
+	rand.Read(idb[:])
+	id := binary.BigEndian.Uint16(idb[:])
+	msg := binary.BigEndian.AppendUint16(nil, id)
+	msg = binary.BigEndian.AppendUint16(msg, dnsFlagRD|dnsFlagAD)
+	msg = append(msg, 0, 1, 0, 0, 0, 0, 0, 1) // one question, one additional record
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return nil, 0, fmt.Errorf("invalid DNS name %q", name)
+		}
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0, 0, dnsTypeTXT, 0, 1)    // root, TXT, IN
+	msg = append(msg, 0, 0, dnsTypeOPT, 0x10, 0) // root, OPT, 4096 bytes
+	msg = binary.BigEndian.AppendUint32(msg, dnsEDNSDO)
+	msg = append(msg, 0, 0) // no options
+	return msg, id, nil
+}
+
+var errBadDNSMessage = errors.New("malformed DNS response")
+
+// parseTXTResponse returns the TXT records answered in msg, which must
+// answer query id and be authenticated.
+func parseTXTResponse(msg []byte, id uint16, name string) ([]string, error) {
+	if len(msg) < 12 || binary.BigEndian.Uint16(msg) != id {
+		return nil, errBadDNSMessage
+	}
+	flags := binary.BigEndian.Uint16(msg[2:])
+	switch {
+	case flags&dnsFlagQR == 0, flags&dnsFlagTC != 0:
+		return nil, errBadDNSMessage
+	case flags&dnsRcodeMask == dnsNXDomain:
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	case flags&dnsRcodeMask != 0:
+		return nil, fmt.Errorf("DNS query for %s failed with rcode %d", name, flags&dnsRcodeMask)
+	case flags&dnsFlagAD == 0:
+		return nil, fmt.Errorf("the answer for %s is not authenticated by DNSSEC", name)
+	}
+	questions, answers := binary.BigEndian.Uint16(msg[4:]), binary.BigEndian.Uint16(msg[6:])
+	off := 12
+	var err error
+	for range questions {
+		if off, err = skipDNSName(msg, off); err != nil {
+			return nil, err
+		}
+		off += 4
+	}
+	var records []string
+	for range answers {
+		if off, err = skipDNSName(msg, off); err != nil {
+			return nil, err
+		}
+		if off+10 > len(msg) {
+			return nil, errBadDNSMessage
+		}
+		typ, length := binary.BigEndian.Uint16(msg[off:]), int(binary.BigEndian.Uint16(msg[off+8:]))
+		off += 10
+		if off+length > len(msg) {
+			return nil, errBadDNSMessage
+		}
+		if typ == dnsTypeTXT {
+			var b strings.Builder
+			for data := msg[off : off+length]; len(data) > 0; {
+				n := int(data[0])
+				if 1+n > len(data) {
+					return nil, errBadDNSMessage
+				}
+				b.Write(data[1 : 1+n])
+				data = data[1+n:]
+			}
+			records = append(records, b.String())
+		}
+		off += length
+	}
+	if len(records) == 0 {
+		return nil, &net.DNSError{Err: "no TXT records", Name: name, IsNotFound: true}
+	}
+	return records, nil
+}
+
+// skipDNSName returns the offset following the possibly compressed name
+// at off.
+func skipDNSName(msg []byte, off int) (int, error) {
+	for off < len(msg) {
+		switch n := int(msg[off]); {
+		case n == 0:
+			return off + 1, nil
+		case n&0xc0 == 0xc0:
+			return off + 2, nil
+		default:
+			off += 1 + n
+		}
+	}
+	return 0, errBadDNSMessage
+}
@@ -0,0 +1,131 @@
+package selfupdate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func Test_DNSSource(t *testing.T) {
+	skipIfDisabled(t)
+	content := []byte("v1.1")
+	sum := sha256.Sum256(content)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(content)
+	}))
+	defer srv.Close()
+	var records []string
+	lookupTXT = func(ctx context.Context, name string) ([]string, error) {
+		if name != "_updater.example.com" {
+			return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+		}
+		return records, nil
+	}
+	defer func() { lookupTXT = net.DefaultResolver.LookupTXT }()
+
+	path := filepath.Join(t.TempDir(), "app")
+	os.WriteFile(path, []byte("old"), 0o755)
+	u := &Updater{Build: BuildInfo{Version: "v1.0.0"}, Path: path, DNS: DNSSource{Name: "_updater.example.com"}}
+	records = []string{
+		"unrelated",
+		"v=updater1 version=v1.1.0 url=" + srv.URL + "/generic sha256=" + strings.Repeat("0", 64),
+		"v=updater1 os=" + runtime.GOOS + " arch=" + runtime.GOARCH + " version=v1.1.0 url=" + srv.URL +
+			"/app-native sha256=" + hex.EncodeToString(sum[:]),
+	}
+	rel, asset, err := u.dnsRelease(context.Background(), "")
+	if err != nil || rel.TagName != "v1.1.0" || asset.Name != "app-native" {
+		t.Fatalf("unexpected release %+v, %+v: %v", rel, asset, err)
+	}
+	if _, _, err := u.dnsRelease(context.Background(), "v1.2.0"); !errors.Is(err, ErrNoRelease) {
+		t.Errorf("expected ErrNoRelease for another pinned version, got %v", err)
+	}
+	info, err := u.Update(context.Background())
+	if err != nil || info.Decision != DecisionUpgraded {
+		t.Fatalf("expected an upgrade, got %s: %v", info.Decision, err)
+	}
+	if b, _ := os.ReadFile(path); string(b) != "v1.1" {
+		t.Errorf("unexpected content %q", b)
+	}
+
+	records = records[:2]
+	u.Build.Version = "v1.1.0"
+	if _, err := u.Check(context.Background()); !errors.Is(err, ErrAlreadyLatest) {
+		t.Errorf("expected ErrAlreadyLatest, got %v", err)
+	}
+	u.DNS.Name = "_nothing.example.com"
+	if _, err := u.Check(context.Background()); !errors.Is(err, ErrNoRelease) {
+		t.Errorf("expected ErrNoRelease, got %v", err)
+	}
+}
+
+// serveDNS answers one TCP query with the TXT records, authenticated if ad.
+func serveDNS(t *testing.T, ad bool, records ...string) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var size [2]byte
+		io.ReadFull(conn, size[:])
+		query := make([]byte, binary.BigEndian.Uint16(size[:]))
+		io.ReadFull(conn, query)
+		qend := 12
+		for query[qend] != 0 {
+			qend += 1 + int(query[qend])
+		}
+		qend += 5
+		flags := uint16(dnsFlagQR | dnsFlagRD)
+		if ad {
+			flags |= dnsFlagAD
+		}
+		resp := append([]byte{}, query[:2]...)
+		resp = binary.BigEndian.AppendUint16(resp, flags)
+		resp = append(resp, 0, 1, 0, byte(len(records)), 0, 0, 0, 0)
+		resp = append(resp, query[12:qend]...)
+		for _, r := range records {
+			resp = append(resp, 0xc0, 12, 0, dnsTypeTXT, 0, 1, 0, 0, 1, 0)
+			// Split into character strings of at most 10 bytes.
+			var data []byte
+			for s := r; s != ""; {
+				n := min(len(s), 10)
+				data = append(append(data, byte(n)), s[:n]...)
+				s = s[n:]
+			}
+			resp = binary.BigEndian.AppendUint16(resp, uint16(len(data)))
+			resp = append(resp, data...)
+		}
+		conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(resp))), resp...))
+	}()
+	return ln.Addr().String()
+}
+
+func Test_queryTXTSecure(t *testing.T) {
+	record := "v=updater1 version=v1.1.0 url=https://dl.example.com/app"
+	addr := serveDNS(t, true, record)
+	got, err := queryTXTSecure(context.Background(), addr, "_updater.example.com")
+	if err != nil || len(got) != 1 || got[0] != record {
+		t.Errorf("queryTXTSecure = %q, %v", got, err)
+	}
+	addr = serveDNS(t, false, record)
+	if _, err := queryTXTSecure(context.Background(), addr, "_updater.example.com"); err == nil ||
+		!strings.Contains(err.Error(), "not authenticated") {
+		t.Errorf("expected an unauthenticated answer to fail, got %v", err)
+	}
+}
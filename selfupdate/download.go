@@ -0,0 +1,528 @@
+package selfupdate
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Digest is a hash of an artifact, e.g. taken from a checksum file.
+type Digest struct {
+	Algorithm string // "sha256" or "sha512"
+	Sum       []byte
+}
+
+func (d Digest) String() string {
+	return d.Algorithm + ":" + hex.EncodeToString(d.Sum)
+}
+
+// parseDigest parses the "<algorithm>:<hex>" form of String.
+func parseDigest(s string) (Digest, error) {
+	algo, sumHex, ok := strings.Cut(s, ":")
+	sum, err := hex.DecodeString(sumHex)
+	if !ok || err != nil {
+		return Digest{}, fmt.Errorf("malformed digest %q", s)
+	}
+	size := map[string]int{"sha256": sha256.Size, "sha512": sha512.Size}[algo]
+	if size == 0 {
+		return Digest{}, fmt.Errorf("unsupported digest algorithm %q", algo)
+	}
+	if len(sum) != size {
+		return Digest{}, fmt.Errorf("malformed digest %q", s)
+	}
+	return Digest{Algorithm: algo, Sum: sum}, nil
+}
+
+// downloadResult describes a completed download. Hashes are computed while
+// streaming so the file never has to be read back.
+type downloadResult struct {
+	Size    int64
+	SHA256  []byte
+	SHA512  []byte // nil unless requested
+	Retries int    // segment retries of a parallel download
+	// data holds an asset buffered in memory, which is written to its
+	// destination only once verified; see Updater.MemoryDownloadLimit.
+	data []byte
+}
+
+// verify compares the streamed hash with want.
+func (r downloadResult) verify(want Digest) error {
+	return matchDigest(want, r.SHA256, r.SHA512)
+}
+
+// downloadOptions tunes downloadFile.
+type downloadOptions struct {
+	withSHA512 bool
+	// connections > 1 enables segmented downloading over that many
+	// parallel ranged requests.
+	connections int
+	segmentSize int64
+	// decompress, if set, decodes the asset into dst, writing at most
+	// maxSize bytes.
+	decompress Decompressor
+	maxSize    int64
+	// expectedSize, if positive, is the size of the asset as published.
+	// Responses announcing or delivering another size are rejected.
+	expectedSize int64
+	// progress, if set, is called with the number of bytes received so
+	// far after every write.
+	progress func(n int64)
+}
+
+// DefaultSegmentSize is the segment size used for parallel downloads when
+// Updater.SegmentSize is zero.
+const DefaultSegmentSize = 8 << 20
+
+// segmentAttempts is how many times a single segment is tried.
+const segmentAttempts = 3
+
+// hashWriter writes to w while hashing and counting the bytes.
+type hashWriter struct {
+	w      io.Writer
+	h256   hash.Hash
+	h512   hash.Hash
+	hashes io.Writer
+	n      int64
+
+	progress func(n int64)
+}
+
+func newHashWriter(w io.Writer, withSHA512 bool) *hashWriter {
+	hw := &hashWriter{w: w, h256: sha256.New()}
+	if withSHA512 {
+		hw.h512 = sha512.New()
+		hw.hashes = io.MultiWriter(hw.h256, hw.h512)
+	} else {
+		hw.hashes = hw.h256
+	}
+	return hw
+}
+
+func (hw *hashWriter) Write(p []byte) (int, error) {
+	n, err := hw.w.Write(p)
+	hw.hashes.Write(p[:n])
+	hw.n += int64(n)
+	if hw.progress != nil {
+		hw.progress(hw.n)
+	}
+	return n, err
+}
+
+func (hw *hashWriter) result() downloadResult {
+	res := downloadResult{Size: hw.n, SHA256: hw.h256.Sum(nil)}
+	if hw.h512 != nil {
+		res.SHA512 = hw.h512.Sum(nil)
+	}
+	return res
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// downloadFile streams a URL to dst and makes it executable, hashing the
+// bytes on the way. SHA-512 is only computed when requested. A body
+// shorter or longer than the advertised size is rejected, and so is one
+// whose size differs from opts.expectedSize; an announced size that
+// differs fails before any of the body is read. With more than
+// one connection the asset is fetched in ranged segments that are written
+// in order; servers without Range support get a single stream.
+//
+// Hashes always cover the asset as published: a Content-Encoding applied
+// by the server is undone before hashing, whereas opts.decompress (for
+// compressed assets such as "app.gz") is applied after.
+func (f *fetcher) downloadFile(ctx context.Context, url, dst string, opts downloadOptions) (res downloadResult, err error) {
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o755)
+	if err != nil {
+		return downloadResult{}, err
+	}
+	defer func() {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	return f.downloadTo(ctx, url, out, opts)
+}
+
+// downloadTo is downloadFile writing to out.
+func (f *fetcher) downloadTo(ctx context.Context, url string, out io.Writer, opts downloadOptions) (res downloadResult, err error) {
+	hw, finish := opts.sink(out)
+	defer func() { err = finish(err) }()
+
+	req, err := newGetRequest(ctx, url)
+	if err != nil {
+		return downloadResult{}, err
+	}
+	segmented := opts.connections > 1
+	if segmented {
+		if opts.segmentSize <= 0 {
+			opts.segmentSize = DefaultSegmentSize
+		}
+		req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", opts.segmentSize-1))
+	} else if enc := acceptEncoding(); enc != "" {
+		req.Header.Set("Accept-Encoding", enc)
+	}
+	resp, err := f.do(req)
+	if err != nil {
+		return downloadResult{}, err
+	}
+	defer drainClose(resp.Body)
+
+	if segmented && resp.StatusCode == http.StatusPartialContent {
+		if _, _, total, err := parseContentRange(resp.Header.Get("Content-Range")); err == nil {
+			if err := checkSize(total, opts.expectedSize); err != nil {
+				return downloadResult{}, err
+			}
+		}
+		retries, err := f.downloadSegments(ctx, url, resp, hw, opts)
+		res := hw.result()
+		res.Retries = retries
+		return res, err
+	}
+
+	if err := checkResponse(resp); err != nil {
+		return downloadResult{}, err
+	}
+	enc := resp.Header.Get("Content-Encoding")
+	if enc == "" && resp.ContentLength >= 0 {
+		if err := checkSize(resp.ContentLength, opts.expectedSize); err != nil {
+			return downloadResult{}, err
+		}
+	}
+	raw := &countingReader{r: interruptReader{resp.Body}}
+	var body io.Reader = raw
+	if enc != "" {
+		dec := compressionByEncoding(enc)
+		if dec == nil {
+			return downloadResult{}, fmt.Errorf("unsupported Content-Encoding %q", enc)
+		}
+		rc, err := dec(raw)
+		if err != nil {
+			return downloadResult{}, err
+		}
+		defer rc.Close()
+		body = rc
+	}
+	if opts.expectedSize > 0 {
+		// Stop one byte past the expected size to detect overlong bodies
+		// without downloading all of them.
+		body = io.LimitReader(body, opts.expectedSize+1)
+	}
+	if _, err := io.Copy(hw, body); err != nil {
+		return hw.result(), err
+	}
+	overlong := opts.expectedSize > 0 && hw.n > opts.expectedSize
+	if !overlong && resp.ContentLength >= 0 && raw.n != resp.ContentLength {
+		return hw.result(), fmt.Errorf("%w: received %d of %d bytes",
+			ErrDownloadInterrupted, raw.n, resp.ContentLength)
+	}
+	return hw.result(), checkSize(hw.n, opts.expectedSize)
+}
+
+// sink returns the hashWriter the bytes of an asset are written to on
+// their way to out, decompressing them after hashing if requested. finish
+// must be called with the outcome of the writes; it returns the first
+// error.
+func (opts downloadOptions) sink(out io.Writer) (hw *hashWriter, finish func(error) error) {
+	var w io.Writer = out
+	finish = func(err error) error { return err }
+	if opts.decompress != nil {
+		limit := opts.maxSize
+		if limit <= 0 {
+			limit = DefaultMaxExtractSize
+		}
+		w, finish = decompressWriter(out, opts.decompress, limit)
+	}
+	hw = newHashWriter(w, opts.withSHA512)
+	hw.progress = opts.progress
+	return hw, finish
+}
+
+// checkSize compares the size of an asset with the expected one, if known.
+func checkSize(size, expected int64) error {
+	if expected > 0 && size != expected {
+		return fmt.Errorf("%w: got %d bytes, expected %d", ErrSizeMismatch, size, expected)
+	}
+	return nil
+}
+
+// headSize asks the server for the size of url without downloading it.
+// It returns -1 if the size is not announced.
+func (f *fetcher) headSize(ctx context.Context, url string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := f.do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return 0, err
+	}
+	if resp.Header.Get("Content-Encoding") != "" {
+		return -1, nil
+	}
+	return resp.ContentLength, nil
+}
+
+// downloadSegments completes a segmented download whose first segment is
+// the body of first. Segments are fetched by opts.connections workers and
+// handed to w strictly in order; at most 2*connections segments are held
+// in memory. It returns the number of segment retries.
+func (f *fetcher) downloadSegments(ctx context.Context, url string, first *http.Response,
+	w io.Writer, opts downloadOptions) (int, error) {
+	start, end, total, err := parseContentRange(first.Header.Get("Content-Range"))
+	if err != nil || start != 0 {
+		return 0, fmt.Errorf("bad Content-Range %q", first.Header.Get("Content-Range"))
+	}
+	firstData, err := readSegment(first, end-start+1)
+	if err != nil {
+		return 0, err
+	}
+	etag := first.Header.Get("ETag")
+
+	count := int((total + opts.segmentSize - 1) / opts.segmentSize)
+	results := make([]chan []byte, count)
+	for i := range results {
+		results[i] = make(chan []byte, 1)
+	}
+	results[0] <- firstData
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	window := make(chan struct{}, 2*opts.connections)
+	indices := make(chan int)
+	go func() {
+		defer close(indices)
+		for i := 1; i < count; i++ {
+			select {
+			case window <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case indices <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		retries  int
+		firstErr error
+	)
+	fail := func(err error) {
+		mu.Lock()
+		if firstErr == nil {
+			firstErr = err
+		}
+		mu.Unlock()
+		cancel()
+	}
+	for range opts.connections {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				from := int64(i) * opts.segmentSize
+				to := min(from+opts.segmentSize, total) - 1
+				data, n, err := f.fetchSegment(ctx, url, etag, from, to)
+				mu.Lock()
+				retries += n
+				mu.Unlock()
+				if err != nil {
+					fail(fmt.Errorf("segment %d: %w", i, err))
+					return
+				}
+				results[i] <- data
+			}
+		}()
+	}
+
+	var werr error
+	for i := 0; i < count && werr == nil; i++ {
+		select {
+		case data := <-results[i]:
+			if _, err := w.Write(data); err != nil {
+				werr = err
+			}
+			if i > 0 {
+				<-window
+			}
+		case <-ctx.Done():
+			werr = ctx.Err()
+		}
+	}
+	cancel()
+	wg.Wait()
+	mu.Lock()
+	defer mu.Unlock()
+	if firstErr != nil {
+		return retries, firstErr
+	}
+	return retries, werr
+}
+
+// fetchSegment downloads bytes [from, to] of url, retrying transient
+// failures. It returns the data and the number of retries used. A
+// changed ETag fails the segment rather than mixing two versions.
+func (f *fetcher) fetchSegment(ctx context.Context, url, etag string, from, to int64) ([]byte, int, error) {
+	var lastErr error
+	for attempt := range segmentAttempts {
+		if attempt > 0 {
+			select {
+			case <-time.After(time.Duration(attempt) * 500 * time.Millisecond):
+			case <-ctx.Done():
+				return nil, attempt - 1, ctx.Err()
+			}
+		}
+		data, err := func() ([]byte, error) {
+			req, err := newGetRequest(ctx, url)
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", from, to))
+			if etag != "" {
+				req.Header.Set("If-Match", etag)
+			}
+			resp, err := f.do(req)
+			if err != nil {
+				return nil, err
+			}
+			defer drainClose(resp.Body)
+			if resp.StatusCode != http.StatusPartialContent {
+				if err := checkResponse(resp); err != nil {
+					return nil, err
+				}
+				return nil, fmt.Errorf("server ignored range request")
+			}
+			start, end, _, err := parseContentRange(resp.Header.Get("Content-Range"))
+			if err != nil || start != from || end != to {
+				return nil, fmt.Errorf("unexpected Content-Range %q", resp.Header.Get("Content-Range"))
+			}
+			return readSegment(resp, to-from+1)
+		}()
+		if err == nil {
+			return data, attempt, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			return nil, attempt, ctx.Err()
+		}
+	}
+	return nil, segmentAttempts - 1, lastErr
+}
+
+// readSegment reads exactly size bytes of a ranged response.
+func readSegment(resp *http.Response, size int64) ([]byte, error) {
+	data := make([]byte, size)
+	if _, err := io.ReadFull(interruptReader{resp.Body}, data); err != nil {
+		if err == io.ErrUnexpectedEOF || err == io.EOF {
+			return nil, fmt.Errorf("%w: short segment", ErrDownloadInterrupted)
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+// parseContentRange parses "bytes START-END/TOTAL". An unknown total ("*")
+// is an error since segmenting needs the full size.
+func parseContentRange(s string) (start, end, total int64, err error) {
+	rest, ok := strings.CutPrefix(s, "bytes ")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", s)
+	}
+	rng, size, ok := strings.Cut(rest, "/")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", s)
+	}
+	from, to, ok := strings.Cut(rng, "-")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", s)
+	}
+	if start, err = strconv.ParseInt(from, 10, 64); err != nil {
+		return 0, 0, 0, err
+	}
+	if end, err = strconv.ParseInt(to, 10, 64); err != nil {
+		return 0, 0, 0, err
+	}
+	if total, err = strconv.ParseInt(size, 10, 64); err != nil {
+		return 0, 0, 0, err
+	}
+	if start < 0 || end < start || end >= total {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", s)
+	}
+	return start, end, total, nil
+}
+
+// maxChecksumFileSize bounds how much of a checksum asset is read.
+const maxChecksumFileSize = 1 << 20
+
+// fetchSmall downloads a small asset such as a checksum file or signature
+// into memory, reading at most limit bytes.
+func (f *fetcher) fetchSmall(ctx context.Context, url string, limit int64) ([]byte, error) {
+	resp, err := f.get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer drainClose(resp.Body)
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(io.LimitReader(interruptReader{resp.Body}, limit))
+}
+
+// parseChecksums reads sha256sum/sha512sum output ("<hex>  <name>", with
+// "*" marking binary mode). The algorithm is inferred from the digest
+// length; malformed lines are ignored.
+func parseChecksums(data []byte) map[string]Digest {
+	sums := map[string]Digest{}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		sum, err := hex.DecodeString(fields[0])
+		if err != nil {
+			continue
+		}
+		var algo string
+		switch len(sum) {
+		case sha256.Size:
+			algo = "sha256"
+		case sha512.Size:
+			algo = "sha512"
+		default:
+			continue
+		}
+		name := strings.TrimPrefix(fields[1], "*")
+		sums[name] = Digest{Algorithm: algo, Sum: sum}
+	}
+	return sums
+}
@@ -0,0 +1,94 @@
+package selfupdate_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/msmania/updater/selfupdate"
+	"github.com/msmania/updater/selfupdate/selfupdatetest"
+)
+
+func Test_Updater_checksums(t *testing.T) {
+	skipIfDisabled(t)
+	good := selfupdatetest.Asset{Name: "app-bin", Content: []byte("v1.1")}
+	srv := selfupdatetest.NewServer("owner", "app",
+		selfupdatetest.Release{Tag: "v1.1.0", Assets: []selfupdatetest.Asset{good}, Checksums: true},
+	)
+	defer srv.Close()
+	u := newUpdater(t, srv, "v1.0.0")
+	u.ChecksumAsset = "checksums.txt"
+	if upgraded, err := u.MaybeUpgrade(context.Background()); err != nil || !upgraded {
+		t.Fatalf("verified upgrade failed: %v", err)
+	}
+
+	tampered := selfupdatetest.Asset{Name: "checksums.txt", Content: selfupdatetest.ChecksumFile([]selfupdatetest.Asset{{Name: "app-bin", Content: []byte("other")}})}
+	srv.AddRelease(selfupdatetest.Release{Tag: "v1.2.0", Assets: []selfupdatetest.Asset{good, tampered}})
+	u.Build.Version = "v1.1.0"
+	if _, err := u.MaybeUpgrade(context.Background()); !errors.Is(err, selfupdate.ErrChecksumMismatch) {
+		t.Error("expected ErrChecksumMismatch, got", err)
+	}
+	if _, err := os.Stat(u.Path + ".new"); !os.IsNotExist(err) {
+		t.Error("rejected download should be removed")
+	}
+}
+
+func Test_Updater_memoryDownload(t *testing.T) {
+	skipIfDisabled(t)
+	other := sha256.Sum256([]byte("other"))
+	srv := selfupdatetest.NewServer("owner", "app",
+		selfupdatetest.Release{Tag: "v1.1.0", Assets: []selfupdatetest.Asset{{Name: "app-bin", Content: []byte("v1.1"),
+			Digest: fmt.Sprintf("sha256:%x", other)}}},
+	)
+	defer srv.Close()
+	u := newUpdater(t, srv, "v1.0.0")
+	u.MemoryDownloadLimit = 1 << 10
+	// A directory in place of the download file makes any write fail, so
+	// the checksum error shows the file was never opened.
+	if err := os.Mkdir(u.Path+".new", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u.MaybeUpgrade(context.Background()); !errors.Is(err, selfupdate.ErrChecksumMismatch) {
+		t.Error("expected ErrChecksumMismatch before writing, got", err)
+	}
+	os.Remove(u.Path + ".new")
+
+	srv.AddRelease(selfupdatetest.Release{Tag: "v1.2.0", Assets: []selfupdatetest.Asset{{Name: "app-bin", Content: []byte("v1.2")}}})
+	if upgraded, err := u.MaybeUpgrade(context.Background()); err != nil || !upgraded {
+		t.Fatalf("buffered upgrade failed: %v", err)
+	}
+	if b, _ := os.ReadFile(u.Path); string(b) != "v1.2" {
+		t.Error("binary not replaced: " + string(b))
+	}
+}
+
+func Test_Updater_assetDigest(t *testing.T) {
+	skipIfDisabled(t)
+	wrong := "sha256:" + strings.Repeat("00", 32)
+	verify := func(asset selfupdatetest.Asset, checksums bool, wantErr error) {
+		t.Helper()
+		srv := selfupdatetest.NewServer("owner", "app", selfupdatetest.Release{Tag: "v1.1.0", Assets: []selfupdatetest.Asset{asset}, Checksums: checksums})
+		defer srv.Close()
+		u := newUpdater(t, srv, "v1.0.0")
+		if checksums {
+			u.ChecksumAsset = "checksums.txt"
+		}
+		info, err := u.Update(context.Background())
+		if !errors.Is(err, wantErr) {
+			t.Errorf("digest %q: expected %v, got %v", asset.Digest, wantErr, err)
+		}
+		if err == nil && (info.Asset == nil || !strings.HasPrefix(info.Asset.Digest, "sha256:")) && asset.Digest == "" {
+			t.Errorf("digest %q: verified digest not reported: %+v", asset.Digest, info.Asset)
+		}
+	}
+	content := []byte("v1.1")
+	verify(selfupdatetest.Asset{Name: "app-bin", Content: content}, false, nil)
+	verify(selfupdatetest.Asset{Name: "app-bin", Content: content, Digest: wrong}, false, selfupdate.ErrChecksumMismatch)
+	verify(selfupdatetest.Asset{Name: "app-bin", Content: content, Digest: selfupdatetest.NoDigest}, false, nil)
+	verify(selfupdatetest.Asset{Name: "app-bin", Content: content, Digest: "crc32:1234"}, false, nil)
+	verify(selfupdatetest.Asset{Name: "app-bin", Content: content, Digest: wrong}, true, selfupdate.ErrChecksumMismatch)
+}
@@ -0,0 +1,207 @@
+package selfupdate
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testFetcher = &fetcher{client: http.DefaultClient, userAgent: "test"}
+
+func Test_parseChecksums(t *testing.T) {
+	s256 := sha256.Sum256([]byte("a"))
+	s512 := sha512.Sum512([]byte("b"))
+	data := hex.EncodeToString(s256[:]) + "  app-linux-amd64\n" +
+		hex.EncodeToString(s512[:]) + " *app-linux-arm64\n" +
+		"garbage line with words\n" +
+		"abcd  too-short\n"
+	sums := parseChecksums([]byte(data))
+	if len(sums) != 2 {
+		t.Fatalf("expected 2 checksums, got %d", len(sums))
+	}
+	if d := sums["app-linux-amd64"]; d.Algorithm != "sha256" || string(d.Sum) != string(s256[:]) {
+		t.Error("sha256 entry mismatch")
+	}
+	if d := sums["app-linux-arm64"]; d.Algorithm != "sha512" || string(d.Sum) != string(s512[:]) {
+		t.Error("sha512 entry (binary mode) mismatch")
+	}
+}
+
+func Test_parseDigest(t *testing.T) {
+	s256 := sha256.Sum256([]byte("a"))
+	d, err := parseDigest("sha256:" + hex.EncodeToString(s256[:]))
+	if err != nil || d.Algorithm != "sha256" || string(d.Sum) != string(s256[:]) {
+		t.Errorf("unexpected digest %v: %v", d, err)
+	}
+	for _, s := range []string{"", "sha256", "sha256:abcd", "sha256:xyz", "md5:" + hex.EncodeToString(s256[:16])} {
+		if _, err := parseDigest(s); err == nil {
+			t.Errorf("%q must not parse", s)
+		}
+	}
+}
+
+func Test_downloadFile(t *testing.T) {
+	content := strings.Repeat("payload", 1000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(content))
+	}))
+	defer srv.Close()
+
+	dst := filepath.Join(t.TempDir(), "out")
+	res, err := testFetcher.downloadFile(context.Background(), srv.URL, dst, downloadOptions{withSHA512: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s256 := sha256.Sum256([]byte(content))
+	s512 := sha512.Sum512([]byte(content))
+	if res.Size != int64(len(content)) {
+		t.Error("size mismatch")
+	}
+	if err := res.verify(Digest{"sha256", s256[:]}); err != nil {
+		t.Error(err)
+	}
+	if err := res.verify(Digest{"sha512", s512[:]}); err != nil {
+		t.Error(err)
+	}
+	if err := res.verify(Digest{"sha256", s512[:32]}); !errors.Is(err, ErrChecksumMismatch) {
+		t.Error("expected ErrChecksumMismatch, got", err)
+	}
+	if b, _ := os.ReadFile(dst); string(b) != content {
+		t.Error("file content mismatch")
+	}
+
+	res, _ = testFetcher.downloadFile(context.Background(), srv.URL, dst, downloadOptions{})
+	if res.SHA512 != nil {
+		t.Error("SHA-512 should only be computed on request")
+	}
+}
+
+func Test_downloadFile_segmented(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789abcdef", 4096)) // 64 KiB
+	var mu sync.Mutex
+	ranges := 0
+	failOnce := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		if r.Header.Get("Range") != "" {
+			ranges++
+		}
+		fail := failOnce && r.Header.Get("Range") == "bytes=20000-29999"
+		if fail {
+			failOnce = false
+		}
+		mu.Unlock()
+		if fail {
+			http.Error(w, "flaky", http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "asset", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	dst := filepath.Join(t.TempDir(), "out")
+	res, err := testFetcher.downloadFile(context.Background(), srv.URL, dst, downloadOptions{
+		connections: 3,
+		segmentSize: 10000,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := sha256.Sum256(content)
+	if err := res.verify(Digest{"sha256", want[:]}); err != nil {
+		t.Error(err)
+	}
+	if b, _ := os.ReadFile(dst); !bytes.Equal(b, content) {
+		t.Error("segments not merged in order")
+	}
+	if ranges != 8 || res.Retries != 1 {
+		t.Errorf("expected 8 ranged requests with 1 retry, got %d and %d", ranges, res.Retries)
+	}
+}
+
+func Test_downloadFile_noRangeFallback(t *testing.T) {
+	content := strings.Repeat("x", 50000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(content)) // ignores Range
+	}))
+	defer srv.Close()
+
+	dst := filepath.Join(t.TempDir(), "out")
+	res, err := testFetcher.downloadFile(context.Background(), srv.URL, dst, downloadOptions{
+		connections: 4,
+		segmentSize: 1000,
+	})
+	if err != nil || res.Size != int64(len(content)) {
+		t.Fatalf("fallback failed: %v (size %d)", err, res.Size)
+	}
+}
+
+func Test_downloadFile_expectedSize(t *testing.T) {
+	content := strings.Repeat("y", 5000)
+	var gets int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			gets++
+		}
+		if r.URL.Path == "/chunked" {
+			// Flushing first makes the response chunked, without Content-Length.
+			w.(http.Flusher).Flush()
+		} else {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		}
+		w.Write([]byte(content))
+	}))
+	defer srv.Close()
+	dst := filepath.Join(t.TempDir(), "out")
+	ctx := context.Background()
+
+	verifyOk := func(path string, size int64) {
+		if _, err := testFetcher.downloadFile(ctx, srv.URL+path, dst, downloadOptions{expectedSize: size}); err != nil {
+			t.Error(path, size, err)
+		}
+	}
+	verifyFail := func(path string, size int64) {
+		if _, err := testFetcher.downloadFile(ctx, srv.URL+path, dst, downloadOptions{expectedSize: size}); !errors.Is(err, ErrSizeMismatch) {
+			t.Error(path, size, "expected ErrSizeMismatch, got", err)
+		}
+	}
+	verifyOk("/", 5000)
+	verifyOk("/", 0)
+	verifyOk("/chunked", 5000)
+	verifyFail("/", 4000)
+	verifyFail("/", 6000)
+	verifyFail("/chunked", 4000)
+	verifyFail("/chunked", 6000)
+
+	if size, err := testFetcher.headSize(ctx, srv.URL); err != nil || size != 5000 {
+		t.Error("unexpected HEAD size", size, err)
+	}
+	if gets != 7 {
+		t.Error("HEAD must not download the body, GETs:", gets)
+	}
+}
+
+func Test_parseContentRange(t *testing.T) {
+	start, end, total, err := parseContentRange("bytes 100-199/1000")
+	if err != nil || start != 100 || end != 199 || total != 1000 {
+		t.Error("valid Content-Range rejected")
+	}
+	for _, s := range []string{"", "bytes 0-9/*", "bytes 9-0/10", "bytes 0-10/10", "items 0-1/2"} {
+		if _, _, _, err := parseContentRange(s); err == nil {
+			t.Error("should reject " + s)
+		}
+	}
+}
@@ -0,0 +1,6 @@
+//go:build !noselfupdate
+
+package selfupdate
+
+// compiledIn is false in builds with the noselfupdate tag.
+const compiledIn = true
@@ -0,0 +1,199 @@
+package selfupdate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sentinel errors identifying the failure class. Errors returned by the
+// package wrap one of these (or a typed error that matches them) so callers
+// can branch with errors.Is.
+var (
+	ErrNoAsset             = errors.New("asset not found")
+	ErrAmbiguousAsset      = errors.New("several assets match")
+	ErrRateLimited         = errors.New("rate limited")
+	ErrChecksumMismatch    = errors.New("checksum mismatch")
+	ErrSignatureInvalid    = errors.New("signature invalid")
+	ErrAlreadyLatest       = errors.New("no newer release available")
+	ErrNoRelease           = errors.New("no eligible release")
+	ErrMajorUpgrade        = errors.New("major version upgrade requires opt-in")
+	ErrBusy                = errors.New("update already in progress")
+	ErrCrashLoop           = errors.New("updates suspended after repeated early exits")
+	ErrNotLeader           = errors.New("another instance leads updates")
+	ErrPolicyViolation     = errors.New("release violates policy")
+	ErrRolloutPaused       = errors.New("rollout paused by the fleet server")
+	ErrNoRollback          = errors.New("no previous version to roll back to")
+	ErrDownloadInterrupted = errors.New("download interrupted")
+	ErrSizeMismatch        = errors.New("size mismatch")
+	ErrUnsafeArchive       = errors.New("unsafe archive")
+	ErrNotLogged           = errors.New("not in the transparency log")
+	ErrPackageManaged      = errors.New("executable is managed by a package manager")
+	ErrDeferred            = errors.New("update deferred by the update policy")
+	ErrDisabled            = errors.New("self-update is disabled in this build")
+	ErrLocked              = errors.New("locked by another process")
+	ErrModified            = errors.New("executable modified")
+)
+
+// HTTPError reports an unexpected HTTP status from the release API or an
+// asset download.
+type HTTPError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("%s returned %d", e.URL, e.StatusCode)
+}
+
+// RateLimitError is returned when the server refuses requests until Reset.
+// It matches ErrRateLimited.
+type RateLimitError struct {
+	Reset time.Time
+}
+
+func (e *RateLimitError) Error() string {
+	if e.Reset.IsZero() {
+		return ErrRateLimited.Error()
+	}
+	return fmt.Sprintf("%s until %s", ErrRateLimited, e.Reset.Format(time.RFC3339))
+}
+
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// MajorUpgradeError is returned when the selected release has a higher
+// major version than the running one and Updater.AllowMajorUpgrade is
+// false. It matches ErrMajorUpgrade.
+type MajorUpgradeError struct {
+	Current   string `json:"current"`
+	Candidate string `json:"candidate"`
+}
+
+func (e *MajorUpgradeError) Error() string {
+	return fmt.Sprintf("%s: %s -> %s", ErrMajorUpgrade, e.Current, e.Candidate)
+}
+
+func (e *MajorUpgradeError) Is(target error) bool {
+	return target == ErrMajorUpgrade
+}
+
+// PackageManagedError is returned when the executable belongs to a
+// package manager; see Updater.AllowPackaged. It matches
+// ErrPackageManaged.
+type PackageManagedError struct {
+	Path    string  `json:"path"`
+	Package Package `json:"package"`
+}
+
+func (e *PackageManagedError) Error() string {
+	msg := fmt.Sprintf("%s: %s is installed by %s", ErrPackageManaged, e.Path, e.Package.Manager)
+	if cmd := e.Package.UpgradeCommand(); cmd != nil {
+		msg += "; upgrade it with: " + strings.Join(cmd, " ")
+	}
+	return msg
+}
+
+func (e *PackageManagedError) Is(target error) bool {
+	return target == ErrPackageManaged
+}
+
+// ReleaseAgeError is returned while the selected release is younger than
+// Updater.MinReleaseAge. It matches ErrDeferred.
+type ReleaseAgeError struct {
+	Tag           string    `json:"tag"`
+	PublishedAt   time.Time `json:"published_at"`
+	InstallableAt time.Time `json:"installable_at"`
+}
+
+func (e *ReleaseAgeError) Error() string {
+	return fmt.Sprintf("%s: %s was published at %s and soaks until %s", ErrDeferred, e.Tag,
+		e.PublishedAt.Format(time.RFC3339), e.InstallableAt.Format(time.RFC3339))
+}
+
+func (e *ReleaseAgeError) Is(target error) bool {
+	return target == ErrDeferred
+}
+
+// checkResponse converts a non-200 response into an HTTPError.
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	return &HTTPError{URL: resp.Request.URL.String(), StatusCode: resp.StatusCode}
+}
+
+// checkAPIResponse is checkResponse for the GitHub release API, whose 403
+// and 429 responses may be rate limits; those become a RateLimitError
+// with a reset relative to now. Other servers' 429s are no reason to stop
+// asking GitHub, so only the release API is checked this way.
+func checkAPIResponse(resp *http.Response, now time.Time) error {
+	if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests {
+		if reset, ok := rateLimitReset(resp.Header, now); ok {
+			return &RateLimitError{Reset: reset}
+		}
+		if secondaryRateLimit(resp) {
+			return &RateLimitError{Reset: now.Add(secondaryRateLimitDelay)}
+		}
+	}
+	return checkResponse(resp)
+}
+
+// rateLimitReset extracts the time requests may resume from GitHub's rate
+// limit headers, with a Retry-After delay counted from now. ok is false
+// if the response is not a rate limit.
+func rateLimitReset(h http.Header, now time.Time) (reset time.Time, ok bool) {
+	if s := h.Get("Retry-After"); s != "" {
+		if sec, err := strconv.Atoi(s); err == nil {
+			return now.Add(time.Duration(sec) * time.Second), true
+		}
+		if t, err := http.ParseTime(s); err == nil {
+			return t, true
+		}
+	}
+	if h.Get("X-RateLimit-Remaining") == "0" {
+		if sec, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			return time.Unix(sec, 0), true
+		}
+		return time.Time{}, true
+	}
+	return time.Time{}, false
+}
+
+// secondaryRateLimitDelay is how long to wait after a secondary rate
+// limit that states no delay, as GitHub advises.
+const secondaryRateLimitDelay = time.Minute
+
+// secondaryRateLimit reports whether resp, a 403 or 429 without rate
+// limit headers, is a GitHub secondary rate limit, which only the message
+// in the body tells apart from a refusal. Any 429 counts as one.
+func secondaryRateLimit(resp *http.Response) bool {
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	if resp.Body == nil {
+		return false
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+	return bytes.Contains(bytes.ToLower(body), []byte("secondary rate limit"))
+}
+
+// interruptReader marks read errors of a download body as interruptions,
+// distinguishing them from local write failures.
+type interruptReader struct {
+	r io.Reader
+}
+
+func (ir interruptReader) Read(p []byte) (int, error) {
+	n, err := ir.r.Read(p)
+	if err != nil && err != io.EOF {
+		err = fmt.Errorf("%w: %w", ErrDownloadInterrupted, err)
+	}
+	return n, err
+}
@@ -0,0 +1,80 @@
+package selfupdate
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func Test_checkAPIResponse(t *testing.T) {
+	newResp := func(code int, headers map[string]string) *http.Response {
+		resp := &http.Response{
+			StatusCode: code,
+			Header:     http.Header{},
+			Request:    &http.Request{URL: &url.URL{Scheme: "https", Host: "example.com"}},
+		}
+		for k, v := range headers {
+			resp.Header.Set(k, v)
+		}
+		return resp
+	}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if err := checkAPIResponse(newResp(200, nil), now); err != nil {
+		t.Error("200 should succeed")
+	}
+
+	err := checkAPIResponse(newResp(403, map[string]string{
+		"X-RateLimit-Remaining": "0",
+		"X-RateLimit-Reset":     "1700000000",
+	}), now)
+	var rl *RateLimitError
+	if !errors.Is(err, ErrRateLimited) || !errors.As(err, &rl) || rl.Reset.Unix() != 1700000000 {
+		t.Error("primary rate limit not detected")
+	}
+	err = checkAPIResponse(newResp(429, map[string]string{"Retry-After": "60"}), now)
+	if !errors.As(err, &rl) || !rl.Reset.Equal(now.Add(time.Minute)) {
+		t.Errorf("Retry-After not counted from now: %v", err)
+	}
+
+	date := now.Add(time.Hour)
+	err = checkAPIResponse(newResp(403, map[string]string{"Retry-After": date.Format(http.TimeFormat)}), now)
+	if !errors.As(err, &rl) || !rl.Reset.Equal(date) {
+		t.Errorf("Retry-After date not detected: %v", err)
+	}
+	secondary := newResp(403, nil)
+	secondary.Body = io.NopCloser(strings.NewReader(`{"message":"You have exceeded a secondary rate limit."}`))
+	if err := checkAPIResponse(secondary, now); !errors.As(err, &rl) || !rl.Reset.Equal(now.Add(secondaryRateLimitDelay)) {
+		t.Errorf("secondary rate limit not detected: %v", err)
+	}
+	if err := checkAPIResponse(newResp(429, nil), now); !errors.Is(err, ErrRateLimited) {
+		t.Error("429 without headers not detected")
+	}
+
+	err = checkAPIResponse(newResp(403, nil), now)
+	var he *HTTPError
+	if errors.Is(err, ErrRateLimited) || !errors.As(err, &he) || he.StatusCode != 403 {
+		t.Error("plain 403 should be an HTTPError")
+	}
+
+	// Other servers' rate limits are plain HTTP errors.
+	err = checkResponse(newResp(429, map[string]string{"Retry-After": "60"}))
+	if errors.Is(err, ErrRateLimited) || !errors.As(err, &he) || he.StatusCode != 429 {
+		t.Errorf("429 outside the release API = %v, want an HTTPError", err)
+	}
+}
+
+func Test_interruptReader(t *testing.T) {
+	_, err := io.ReadAll(interruptReader{strings.NewReader("ok")})
+	if err != nil {
+		t.Error("EOF should pass through")
+	}
+	_, err = io.ReadAll(interruptReader{iotest.ErrReader(io.ErrUnexpectedEOF)})
+	if !errors.Is(err, ErrDownloadInterrupted) || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Error("read error should wrap ErrDownloadInterrupted")
+	}
+}
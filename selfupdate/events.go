@@ -0,0 +1,128 @@
+package selfupdate
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Event types.
+const (
+	// EventStageStarted and EventStageFinished bracket each pipeline
+	// stage; Stage is one of the Span* names.
+	EventStageStarted  = "stage-started"
+	EventStageFinished = "stage-finished"
+	// EventDownloadProgress reports the bytes downloaded so far, at most
+	// once per percent (or per MiB when the size is unknown).
+	EventDownloadProgress = "download-progress"
+	// EventStatus reports a new Status after an update check.
+	EventStatus = "status"
+)
+
+// Event reports the progress of an update; see Updater.Subscribe.
+type Event struct {
+	Time  time.Time `json:"time"`
+	Type  string    `json:"type"`
+	Stage string    `json:"stage,omitempty"`
+	// Bytes and Total are the progress of an EventDownloadProgress;
+	// Total is zero if the size of the asset is unknown.
+	Bytes int64 `json:"bytes,omitempty"`
+	Total int64 `json:"total,omitempty"`
+	// Result is the Status.Result of an EventStatus.
+	Result string `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// eventBufferSize is the number of events a slow subscriber may fall
+// behind before further events are dropped for it.
+const eventBufferSize = 64
+
+// eventBus fans events out to subscribers.
+type eventBus struct {
+	mu   sync.Mutex
+	subs map[chan Event]struct{}
+}
+
+// Subscribe returns a channel receiving the Updater's events until
+// cancel is called. Events are dropped rather than blocking the update
+// if the subscriber falls behind.
+func (u *Updater) Subscribe() (events <-chan Event, cancel func()) {
+	ch := make(chan Event, eventBufferSize)
+	b := &u.events
+	b.mu.Lock()
+	if b.subs == nil {
+		b.subs = map[chan Event]struct{}{}
+	}
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+func (b *eventBus) publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// downloadProgress returns a downloadOptions.progress callback that
+// publishes EventDownloadProgress for an asset of total bytes.
+func (u *Updater) downloadProgress(total int64) func(int64) {
+	step := int64(1 << 20)
+	if total > 0 {
+		step = max(total/100, 1)
+	}
+	var next int64
+	return func(n int64) {
+		if n < next && n != total {
+			return
+		}
+		next = (n/step + 1) * step
+		u.events.publish(Event{Time: time.Now().UTC(), Type: EventDownloadProgress, Bytes: n, Total: total})
+	}
+}
+
+// eventTracer publishes stage events around the spans of next.
+type eventTracer struct {
+	next Tracer
+	bus  *eventBus
+}
+
+type eventSpan struct {
+	Span
+	name string
+	bus  *eventBus
+	err  error
+}
+
+func (t eventTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	ctx, span := t.next.Start(ctx, name)
+	t.bus.publish(Event{Time: time.Now().UTC(), Type: EventStageStarted, Stage: name})
+	return ctx, &eventSpan{Span: span, name: name, bus: t.bus}
+}
+
+func (s *eventSpan) RecordError(err error) {
+	s.err = err
+	s.Span.RecordError(err)
+}
+
+func (s *eventSpan) End() {
+	e := Event{Time: time.Now().UTC(), Type: EventStageFinished, Stage: s.name}
+	if s.err != nil {
+		e.Error = s.err.Error()
+	}
+	s.bus.publish(e)
+	s.Span.End()
+}
@@ -0,0 +1,74 @@
+package selfupdate_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/msmania/updater/selfupdate"
+	"github.com/msmania/updater/selfupdate/selfupdatetest"
+)
+
+func Test_Updater_events(t *testing.T) {
+	skipIfDisabled(t)
+	srv := selfupdatetest.NewServer("owner", "app",
+		selfupdatetest.Release{Tag: "v1.1.0", Assets: []selfupdatetest.Asset{{Name: "app-bin", Content: []byte("v1.1")}}},
+	)
+	defer srv.Close()
+	u := newUpdater(t, srv, "v1.0.0")
+	api := httptest.NewServer(u.Handler())
+	defer api.Close()
+
+	resp, err := http.Get(api.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatal("unexpected content type", resp.Header)
+	}
+	events := make(chan selfupdate.Event)
+	go func() {
+		defer close(events)
+		sc := bufio.NewScanner(resp.Body)
+		for sc.Scan() {
+			if data, ok := strings.CutPrefix(sc.Text(), "data: "); ok {
+				var e selfupdate.Event
+				json.Unmarshal([]byte(data), &e)
+				events <- e
+			}
+		}
+	}()
+	if e := <-events; e.Type != selfupdate.EventStatus || e.Result != selfupdate.ResultNotChecked {
+		t.Errorf("stream must start with the status, got %+v", e)
+	}
+
+	go http.Post(api.URL+"/trigger", "", nil)
+	var got []string
+	for e := range events {
+		switch e.Type {
+		case selfupdate.EventDownloadProgress:
+			got = append(got, fmt.Sprintf("progress %d/%d", e.Bytes, e.Total))
+		case selfupdate.EventStatus:
+			got = append(got, "status "+e.Result)
+		default:
+			got = append(got, e.Type+" "+e.Stage)
+		}
+		if e.Type == selfupdate.EventStatus {
+			break
+		}
+	}
+	want := []string{
+		"stage-started update", "stage-started check", "stage-finished check",
+		"stage-started download", "progress 4/4", "stage-finished download",
+		"stage-started verify", "stage-finished verify", "stage-started install", "stage-finished install",
+		"stage-started restart", "stage-finished restart", "stage-finished update", "status upgraded",
+	}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("unexpected events:\n got %v\nwant %v", got, want)
+	}
+}
@@ -0,0 +1,91 @@
+package selfupdate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// FleetConfig makes the Updater check in with a fleet server (see package
+// fleet) before every check. The server learns the running version and
+// may pin the release to install, hold this agent back from a partial
+// rollout, or pause updates altogether.
+type FleetConfig struct {
+	// URL is the fleet server's base URL. Empty disables check-ins.
+	URL string
+	// AgentID identifies this agent. Defaults to the host name.
+	AgentID string
+}
+
+// FleetCheckin is what an agent reports to the fleet server.
+type FleetCheckin struct {
+	Agent   string  `json:"agent"`
+	Version string  `json:"version"`
+	Channel Channel `json:"channel"`
+}
+
+// FleetManifest is the fleet server's answer to a check-in.
+type FleetManifest struct {
+	// Target is the release the fleet should run; empty leaves the choice
+	// to the Updater's own channel and constraint.
+	Target string `json:"target,omitempty"`
+	// Eligible reports whether this agent is in the current rollout
+	// wave, i.e. may install Target now.
+	Eligible       bool `json:"eligible"`
+	RolloutPercent int  `json:"rollout_percent"`
+	// Paused is the kill switch: agents keep checking but install
+	// nothing, reporting ErrRolloutPaused, until it is cleared.
+	Paused bool `json:"paused,omitempty"`
+}
+
+// fleetCheckinPath is where agents check in below FleetConfig.URL.
+const fleetCheckinPath = "/v1/checkin"
+
+// fleetCheckin reports to the fleet server and returns its manifest, or
+// nil if no fleet server is configured.
+func (u *Updater) fleetCheckin(ctx context.Context) (*FleetManifest, error) {
+	if u.Fleet.URL == "" {
+		return nil, nil
+	}
+	body, err := json.Marshal(FleetCheckin{Agent: u.agentID(), Version: u.Build.Version, Channel: u.channel()})
+	if err != nil {
+		return nil, err
+	}
+	url := strings.TrimSuffix(u.Fleet.URL, "/") + fleetCheckinPath
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := u.http().do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer drainClose(resp.Body)
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+	var m FleetManifest
+	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+		return nil, fmt.Errorf("invalid fleet manifest: %w", err)
+	}
+	return &m, nil
+}
+
+// releaseByTag fetches the release tagged tag.
+func (f *fetcher) releaseByTag(ctx context.Context, apiURL, owner, repo, tag string) (*ghRelease, error) {
+	var rel ghRelease
+	_, err := f.getJSON(ctx, releasesURL(apiURL, owner, repo)+"/tags/"+tag, &rel)
+	var he *HTTPError
+	if errors.As(err, &he) && he.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: no release tagged %s", ErrNoRelease, tag)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rel, nil
+}
@@ -0,0 +1,69 @@
+package selfupdate_test
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/msmania/updater/fleet"
+	"github.com/msmania/updater/selfupdate"
+	"github.com/msmania/updater/selfupdate/selfupdatetest"
+)
+
+func Test_Updater_fleet(t *testing.T) {
+	skipIfDisabled(t)
+	srv := selfupdatetest.NewServer("owner", "app",
+		selfupdatetest.Release{Tag: "v1.1.0", Assets: []selfupdatetest.Asset{{Name: "app-bin", Content: []byte("v1.1")}}},
+		selfupdatetest.Release{Tag: "v1.2.0", Assets: []selfupdatetest.Asset{{Name: "app-bin", Content: []byte("v1.2")}}},
+	)
+	defer srv.Close()
+	store := &fleet.MemoryStore{}
+	store.Save(fleet.State{Channels: map[string]fleet.Channel{
+		"stable": {Target: "v1.1.0", RolloutPercent: 100},
+	}})
+	fs, err := fleet.NewServer(store, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fleetSrv := httptest.NewServer(fs.Handler())
+	defer fleetSrv.Close()
+	ctx := context.Background()
+
+	u := newUpdater(t, srv, "v1.0.0")
+	u.Fleet = selfupdate.FleetConfig{URL: fleetSrv.URL, AgentID: "agent-1"}
+	info, err := u.Update(ctx)
+	if err != nil || info.Remote != "v1.1.0" {
+		t.Fatalf("pinned target not installed %+v: %v", info, err)
+	}
+	if st, _ := store.Load(); st.Agents["agent-1"].Version != "v1.0.0" {
+		t.Error("check-in not recorded", st.Agents)
+	}
+
+	// The kill switch holds back v1.2.0 while checks go on.
+	st, _ := store.Load()
+	st.Paused = true
+	st.Channels["stable"] = fleet.Channel{Target: "v1.2.0", RolloutPercent: 100}
+	store.Save(st)
+	fs, _ = fleet.NewServer(store, "")
+	fleetSrv.Config.Handler = fs.Handler()
+	u.Build.Version = "v1.1.0"
+	info, err = u.Update(ctx)
+	if !errors.Is(err, selfupdate.ErrRolloutPaused) || info.Decision != selfupdate.DecisionSuspended || info.Remote != "v1.2.0" {
+		t.Error("expected paused rollout, got", info.Decision, info.Remote, err)
+	}
+	if upgraded, _ := u.MaybeUpgrade(ctx); upgraded || u.Status().Result != selfupdate.ResultPaused {
+		t.Error("expected paused status, got", u.Status())
+	}
+	if st, _ := store.Load(); st.Agents["agent-1"].Version != "v1.1.0" {
+		t.Error("paused agent stopped checking in")
+	}
+
+	st.Paused = false
+	store.Save(st)
+	fs, _ = fleet.NewServer(store, "")
+	fleetSrv.Config.Handler = fs.Handler()
+	if info, err := u.Update(ctx); err != nil || info.Remote != "v1.2.0" {
+		t.Error("install not resumed after un-pausing:", err)
+	}
+}
@@ -0,0 +1,233 @@
+package selfupdate
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FS is the file system the state below Updater.StateDir is kept in and
+// the default Appliers install into. OSFS is the real one; MemFS keeps
+// everything in memory so tests run without touching the disk.
+//
+// Paths are OS paths as taken by package os, not the slash-separated
+// paths of io/fs.
+type FS interface {
+	ReadFile(name string) ([]byte, error)
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	Rename(oldpath, newpath string) error
+	Remove(name string) error
+	MkdirAll(path string, perm fs.FileMode) error
+	Stat(name string) (fs.FileInfo, error)
+}
+
+// OSFS is the FS of package os.
+type OSFS struct{}
+
+func (OSFS) ReadFile(name string) ([]byte, error) { return os.ReadFile(name) }
+func (OSFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return os.WriteFile(name, data, perm)
+}
+func (OSFS) Rename(oldpath, newpath string) error         { return os.Rename(oldpath, newpath) }
+func (OSFS) Remove(name string) error                     { return os.Remove(name) }
+func (OSFS) MkdirAll(path string, perm fs.FileMode) error { return os.MkdirAll(path, perm) }
+func (OSFS) Stat(name string) (fs.FileInfo, error)        { return os.Stat(name) }
+
+// orOS returns fsys, or OSFS if it is nil.
+func orOS(fsys FS) FS {
+	if fsys == nil {
+		return OSFS{}
+	}
+	return fsys
+}
+
+// fs returns the FS of u.
+func (u *Updater) fs() FS {
+	return orOS(u.FS)
+}
+
+// copyFileFS copies src to dst within fsys with mode 0755, replacing dst
+// atomically. On OSFS it streams the file rather than reading it whole.
+func copyFileFS(fsys FS, src, dst string) error {
+	if _, ok := fsys.(OSFS); ok {
+		return copyFile(src, dst)
+	}
+	data, err := fsys.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	tmp := dst + ".tmp"
+	if err := fsys.WriteFile(tmp, data, 0o755); err != nil {
+		return err
+	}
+	if err := fsys.Rename(tmp, dst); err != nil {
+		fsys.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// MemFS is an FS held in memory. The zero value is an empty file system
+// with only the root directories; it is safe for concurrent use.
+type MemFS struct {
+	mu    sync.Mutex
+	files map[string]*memFile
+	dirs  map[string]time.Time
+}
+
+type memFile struct {
+	data    []byte
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func memPathError(op, name string, err error) error {
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+// isDir reports whether the cleaned path is a directory. m.mu must be
+// held.
+func (m *MemFS) isDir(name string) bool {
+	if filepath.Dir(name) == name {
+		return true // "/", "." or a volume root
+	}
+	_, ok := m.dirs[name]
+	return ok
+}
+
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[filepath.Clean(name)]
+	if !ok {
+		return nil, memPathError("open", name, fs.ErrNotExist)
+	}
+	return append([]byte(nil), f.data...), nil
+}
+
+func (m *MemFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name = filepath.Clean(name)
+	if !m.isDir(filepath.Dir(name)) {
+		return memPathError("open", name, fs.ErrNotExist)
+	}
+	if m.isDir(name) {
+		return memPathError("open", name, errors.New("is a directory"))
+	}
+	if m.files == nil {
+		m.files = make(map[string]*memFile)
+	}
+	if f, ok := m.files[name]; ok {
+		perm = f.mode // like os.WriteFile, keep the mode of an existing file
+	}
+	m.files[name] = &memFile{data: append([]byte(nil), data...), mode: perm.Perm(), modTime: time.Now()}
+	return nil
+}
+
+func (m *MemFS) Rename(oldpath, newpath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	oldpath, newpath = filepath.Clean(oldpath), filepath.Clean(newpath)
+	f, ok := m.files[oldpath]
+	if !ok {
+		if m.isDir(oldpath) {
+			return memPathError("rename", oldpath, errors.ErrUnsupported)
+		}
+		return memPathError("rename", oldpath, fs.ErrNotExist)
+	}
+	if !m.isDir(filepath.Dir(newpath)) {
+		return memPathError("rename", newpath, fs.ErrNotExist)
+	}
+	if m.isDir(newpath) {
+		return memPathError("rename", newpath, errors.New("is a directory"))
+	}
+	delete(m.files, oldpath)
+	m.files[newpath] = f
+	return nil
+}
+
+func (m *MemFS) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name = filepath.Clean(name)
+	if _, ok := m.files[name]; ok {
+		delete(m.files, name)
+		return nil
+	}
+	if _, ok := m.dirs[name]; !ok {
+		return memPathError("remove", name, fs.ErrNotExist)
+	}
+	prefix := name + string(filepath.Separator)
+	for p := range m.files {
+		if strings.HasPrefix(p, prefix) {
+			return memPathError("remove", name, errors.New("directory not empty"))
+		}
+	}
+	for p := range m.dirs {
+		if strings.HasPrefix(p, prefix) {
+			return memPathError("remove", name, errors.New("directory not empty"))
+		}
+	}
+	delete(m.dirs, name)
+	return nil
+}
+
+func (m *MemFS) MkdirAll(path string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for dir := filepath.Clean(path); !m.isDir(dir); dir = filepath.Dir(dir) {
+		if _, ok := m.files[dir]; ok {
+			return memPathError("mkdir", dir, errors.New("not a directory"))
+		}
+		if m.dirs == nil {
+			m.dirs = make(map[string]time.Time)
+		}
+		m.dirs[dir] = time.Now()
+	}
+	return nil
+}
+
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name = filepath.Clean(name)
+	if f, ok := m.files[name]; ok {
+		return memInfo{name: filepath.Base(name), size: int64(len(f.data)), mode: f.mode, modTime: f.modTime}, nil
+	}
+	if m.isDir(name) {
+		return memInfo{name: filepath.Base(name), mode: fs.ModeDir | 0o755, modTime: m.dirs[name]}, nil
+	}
+	return nil, memPathError("stat", name, fs.ErrNotExist)
+}
+
+// Files returns the paths of all files in m, sorted.
+func (m *MemFS) Files() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.files))
+	for name := range m.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type memInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return i.size }
+func (i memInfo) Mode() fs.FileMode  { return i.mode }
+func (i memInfo) ModTime() time.Time { return i.modTime }
+func (i memInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i memInfo) Sys() any           { return nil }
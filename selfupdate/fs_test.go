@@ -0,0 +1,109 @@
+package selfupdate
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_MemFS(t *testing.T) {
+	m := &MemFS{}
+	dir := filepath.FromSlash("/srv/app")
+	name := filepath.Join(dir, "app")
+	if err := m.WriteFile(name, []byte("v1"), 0o755); !errors.Is(err, fs.ErrNotExist) {
+		t.Error("writing into a missing directory must fail, got", err)
+	}
+	if err := m.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.WriteFile(name, []byte("v1"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := m.Stat(name); err != nil || fi.Size() != 2 || fi.Mode() != 0o755 {
+		t.Error("unexpected stat", fi, err)
+	}
+	if fi, err := m.Stat(dir); err != nil || !fi.IsDir() {
+		t.Error("directory not listed", err)
+	}
+	if err := m.Remove(dir); err == nil {
+		t.Error("removed a directory that is not empty")
+	}
+
+	moved := filepath.Join(dir, "app.old")
+	if err := m.Rename(name, moved); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.ReadFile(name); !errors.Is(err, fs.ErrNotExist) {
+		t.Error("renamed file still present:", err)
+	}
+	if b, err := m.ReadFile(moved); err != nil || string(b) != "v1" {
+		t.Error("renamed file lost:", string(b), err)
+	}
+	if got := m.Files(); !reflect.DeepEqual(got, []string{moved}) {
+		t.Error("unexpected files", got)
+	}
+	if err := m.Remove(moved); err != nil {
+		t.Error(err)
+	}
+	if err := m.Remove(dir); err != nil {
+		t.Error("empty directory not removed:", err)
+	}
+}
+
+func Test_Applier_memFS(t *testing.T) {
+	setup := func(stagedDir string) (*MemFS, Artifact, string) {
+		m := &MemFS{}
+		target := filepath.FromSlash("/opt/app/app")
+		staged := filepath.Join(stagedDir, "app.new")
+		m.MkdirAll(filepath.Dir(target), 0o755)
+		m.MkdirAll(stagedDir, 0o755)
+		m.WriteFile(target, []byte("old"), 0o755)
+		m.WriteFile(staged, []byte("new"), 0o755)
+		return m, Artifact{Path: staged, Tag: "v1.1.0"}, target
+	}
+	verify := func(name string, stagedDir string, newApplier func(FS) Applier) *MemFS {
+		m, a, target := setup(filepath.FromSlash(stagedDir))
+		if err := newApplier(m).Apply(context.Background(), a, target); err != nil {
+			t.Error(name, err)
+			return m
+		}
+		if b, _ := m.ReadFile(target); string(b) != "new" {
+			t.Error(name, "target not replaced: "+string(b))
+		}
+		if _, err := m.Stat(a.Path); !errors.Is(err, fs.ErrNotExist) {
+			t.Error(name, "staging file left behind")
+		}
+		return m
+	}
+	atomic := func(m FS) Applier { return AtomicRename{FS: m} }
+	twoStep := func(m FS) Applier { return WindowsTwoStep{FS: m} }
+
+	verify("AtomicRename", "/opt/app", atomic)
+	verify("AtomicRename across directories", "/var/tmp", atomic)
+	m := verify("WindowsTwoStep", "/var/tmp", twoStep)
+	if b, _ := m.ReadFile(filepath.FromSlash("/opt/app/app.old")); string(b) != "old" {
+		t.Error("WindowsTwoStep must keep the old binary aside")
+	}
+}
+
+func Test_state_memFS(t *testing.T) {
+	m := &MemFS{}
+	u := &Updater{StateDir: filepath.FromSlash("/var/lib/app"), FS: m}
+	want := Status{Result: "upgraded", Current: "v1.1.0"}
+	if err := u.writeState(statusFile, want); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Files(); !reflect.DeepEqual(got, []string{filepath.Join(u.StateDir, statusFile)}) {
+		t.Error("unexpected state files", got)
+	}
+	var got Status
+	if err := u.readState(statusFile, &got); err != nil || got.Result != want.Result || got.Current != want.Current {
+		t.Errorf("read back %+v, %v", got, err)
+	}
+	if err := u.readState("missing.json", &got); err != nil {
+		t.Error("a missing state file is not an error, got", err)
+	}
+}
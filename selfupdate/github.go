@@ -0,0 +1,191 @@
+package selfupdate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultAPIURL is the GitHub REST API endpoint used when Updater.APIURL
+// is empty.
+const DefaultAPIURL = "https://api.github.com"
+
+// ---------------------------------------------------------------------
+// GitHub release information structures
+// ---------------------------------------------------------------------
+type ghAsset struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+	// Digest is "<algorithm>:<hex>", on assets uploaded since GitHub
+	// started recording it.
+	Digest             string    `json:"digest"`
+	BrowserDownloadURL string    `json:"browser_download_url"`
+	ContentType        string    `json:"content_type"`
+	DownloadCount      int       `json:"download_count"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+type ghRelease struct {
+	TagName     string    `json:"tag_name"`
+	Name        string    `json:"name"`
+	Body        string    `json:"body"`
+	Draft       bool      `json:"draft"`
+	Prerelease  bool      `json:"prerelease"`
+	CreatedAt   time.Time `json:"created_at"`
+	PublishedAt time.Time `json:"published_at"`
+	HTMLURL     string    `json:"html_url"`
+	Author      struct {
+		Login string `json:"login"`
+	} `json:"author"`
+	Assets []ghAsset `json:"assets"`
+
+	raw json.RawMessage // see UnmarshalJSON
+}
+
+// findAsset returns the asset called name.
+func (r *ghRelease) findAsset(name string) (*ghAsset, error) {
+	for i := range r.Assets {
+		if r.Assets[i].Name == name {
+			return &r.Assets[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s in release %s", ErrNoAsset, name, r.TagName)
+}
+
+// matchAsset returns the only asset whose whole name matches re.
+func (r *ghRelease) matchAsset(re *regexp.Regexp) (*ghAsset, error) {
+	var matches, names []string
+	var found *ghAsset
+	for i, a := range r.Assets {
+		names = append(names, a.Name)
+		if loc := re.FindStringIndex(a.Name); loc != nil && loc[0] == 0 && loc[1] == len(a.Name) {
+			matches = append(matches, a.Name)
+			found = &r.Assets[i]
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("%w: no asset matches %s in release %s (assets: %s)",
+			ErrNoAsset, re, r.TagName, strings.Join(names, ", "))
+	case 1:
+		return found, nil
+	}
+	return nil, fmt.Errorf("%w: %s matches %s in release %s",
+		ErrAmbiguousAsset, re, strings.Join(matches, ", "), r.TagName)
+}
+
+// Listing is bounded so a repository with a long history costs at most
+// maxReleasePages API requests per check.
+const (
+	releasesPerPage = 100
+	maxReleasePages = 3
+)
+
+// releasesURL returns the releases endpoint of owner/repo.
+func releasesURL(apiURL, owner, repo string) string {
+	return fmt.Sprintf("%s/repos/%s/%s/releases", strings.TrimSuffix(apiURL, "/"), owner, repo)
+}
+
+// getJSON fetches url from the GitHub API and decodes the body into v. It
+// returns the response header for pagination.
+func (f *fetcher) getJSON(ctx context.Context, url string, v any) (http.Header, error) {
+	req, err := newGetRequest(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := f.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer drainClose(resp.Body)
+	if err := checkAPIResponse(resp, f.now()); err != nil {
+		return nil, err
+	}
+	return resp.Header, json.NewDecoder(resp.Body).Decode(v)
+}
+
+// latestRelease queries the GitHub API for the most recent release. GitHub
+// answers 404 when every release is a draft or pre-release; that case is
+// reported as ErrNoRelease.
+func (f *fetcher) latestRelease(ctx context.Context, apiURL, owner, repo string) (*ghRelease, error) {
+	var rel ghRelease
+	_, err := f.getJSON(ctx, releasesURL(apiURL, owner, repo)+"/latest", &rel)
+	var he *HTTPError
+	if errors.As(err, &he) && he.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: no published stable release", ErrNoRelease)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rel, nil
+}
+
+// listReleases returns the releases of owner/repo, newest first as ordered
+// by the API, following pagination up to maxReleasePages.
+func (f *fetcher) listReleases(ctx context.Context, apiURL, owner, repo string) ([]ghRelease, error) {
+	url := fmt.Sprintf("%s?per_page=%d", releasesURL(apiURL, owner, repo), releasesPerPage)
+	var all []ghRelease
+	for page := 0; url != "" && page < maxReleasePages; page++ {
+		var rels []ghRelease
+		h, err := f.getJSON(ctx, url, &rels)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, rels...)
+		url = nextLink(h.Get("Link"))
+	}
+	return all, nil
+}
+
+// nextLink extracts the rel="next" URL from a Link header.
+func nextLink(link string) string {
+	for _, part := range strings.Split(link, ",") {
+		target, params, ok := strings.Cut(part, ";")
+		if !ok || !strings.Contains(params, `rel="next"`) {
+			continue
+		}
+		return strings.Trim(strings.TrimSpace(target), "<>")
+	}
+	return ""
+}
+
+// selectRelease picks the highest version among rels that channel and
+// constraint admit. Drafts and unparsable tags are skipped; API ordering
+// is ignored because a backported patch may be published after a newer
+// minor release.
+func selectRelease(rels []ghRelease, channel Channel, constraint Constraint) (*ghRelease, error) {
+	var best *ghRelease
+	var bestVersion Version
+	for i := range rels {
+		rel := &rels[i]
+		if rel.Draft {
+			continue
+		}
+		v := ParseVersion(rel.TagName)
+		if !v.Parsed || !channel.allows(v) || !constraint.Check(v) {
+			continue
+		}
+		if rel.Prerelease && channel == ChannelStable {
+			continue
+		}
+		if best != nil {
+			if cmp, _ := v.Compare(bestVersion); cmp <= 0 {
+				continue
+			}
+		}
+		best, bestVersion = rel, v
+	}
+	if best == nil {
+		if !constraint.IsZero() {
+			return nil, fmt.Errorf("%w on channel %s matching %q", ErrNoRelease, channel, constraint)
+		}
+		return nil, fmt.Errorf("%w on channel %s", ErrNoRelease, channel)
+	}
+	return best, nil
+}
@@ -0,0 +1,87 @@
+package selfupdate
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func Test_selectRelease(t *testing.T) {
+	rels := []ghRelease{
+		{TagName: "v1.2.1"}, // backport published last
+		{TagName: "v2.0.0-alpha1", Prerelease: true},
+		{TagName: "v1.3.0-rc2", Prerelease: true},
+		{TagName: "v1.4.0", Draft: true},
+		{TagName: "nightly", Prerelease: true},
+		{TagName: "v1.3.0-beta1", Prerelease: true},
+		{TagName: "v1.2.5"},
+	}
+	verifyOk := func(channel Channel, want string) {
+		rel, err := selectRelease(rels, channel, Constraint{})
+		if err != nil {
+			t.Error(channel, err)
+			return
+		}
+		if rel.TagName != want {
+			t.Error(channel, "selected", rel.TagName, "want", want)
+		}
+	}
+	verifyOk(ChannelStable, "v1.2.5")
+	verifyOk(ChannelRC, "v1.3.0-rc2")
+	verifyOk(ChannelBeta, "v1.3.0-rc2")
+	verifyOk(ChannelAlpha, "v2.0.0-alpha1")
+
+	c, _ := ParseConstraint("<1.2.5")
+	if rel, err := selectRelease(rels, ChannelStable, c); err != nil || rel.TagName != "v1.2.1" {
+		t.Error("constraint not applied:", rel, err)
+	}
+
+	if _, err := selectRelease(rels[1:3], ChannelStable, Constraint{}); !errors.Is(err, ErrNoRelease) {
+		t.Error("expected ErrNoRelease, got", err)
+	}
+}
+
+func Test_ParseChannel(t *testing.T) {
+	if c, err := ParseChannel(""); err != nil || c != ChannelStable {
+		t.Error("empty channel should be stable")
+	}
+	if c, err := ParseChannel("beta"); err != nil || c != ChannelBeta {
+		t.Error("beta not parsed")
+	}
+	if _, err := ParseChannel("nightly"); err == nil {
+		t.Error("unknown channel accepted")
+	}
+}
+
+func Test_nextLink(t *testing.T) {
+	link := `<https://api.github.com/repos/o/r/releases?page=1>; rel="prev", ` +
+		`<https://api.github.com/repos/o/r/releases?page=3>; rel="next", ` +
+		`<https://api.github.com/repos/o/r/releases?page=9>; rel="last"`
+	if got := nextLink(link); got != "https://api.github.com/repos/o/r/releases?page=3" {
+		t.Error("unexpected next link", got)
+	}
+	if got := nextLink(`<https://x/?page=1>; rel="prev"`); got != "" {
+		t.Error("expected no next link, got", got)
+	}
+}
+
+func Test_matchAsset(t *testing.T) {
+	rel := &ghRelease{TagName: "v1.2.3", Assets: []ghAsset{
+		{Name: "myapp_1.2.3_linux_amd64.tar.gz"},
+		{Name: "myapp_1.2.3_linux_amd64.tar.gz.sha256"},
+		{Name: "myapp_1.2.3_linux_arm64.tar.gz"},
+	}}
+	a, err := rel.matchAsset(regexp.MustCompile(`myapp_.*_linux_amd64\.tar\.gz`))
+	if err != nil || a.Name != "myapp_1.2.3_linux_amd64.tar.gz" {
+		t.Errorf("unexpected match %v: %v", a, err)
+	}
+	_, err = rel.matchAsset(regexp.MustCompile(`myapp_.*_darwin_.*`))
+	if !errors.Is(err, ErrNoAsset) || !strings.Contains(err.Error(), "myapp_1.2.3_linux_arm64.tar.gz") {
+		t.Error("expected ErrNoAsset listing the assets, got", err)
+	}
+	_, err = rel.matchAsset(regexp.MustCompile(`myapp_.*\.tar\.gz`))
+	if !errors.Is(err, ErrAmbiguousAsset) || !strings.Contains(err.Error(), "linux_amd64.tar.gz, myapp_1.2.3_linux_arm64") {
+		t.Error("expected ErrAmbiguousAsset listing the matches, got", err)
+	}
+}
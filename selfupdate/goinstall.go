@@ -0,0 +1,122 @@
+package selfupdate
+
+import (
+	"context"
+	"debug/buildinfo"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// GoInstallRollout upgrades an executable installed with "go install"
+// the way it was installed, running "go install <Package>@<tag>", so the
+// binary keeps matching the module version and build information the Go
+// toolchain recorded. Without Run it only prints that command to Output,
+// leaving the upgrade to the user.
+type GoInstallRollout struct {
+	// Package is the main package, e.g.
+	// "github.com/msmania/updater/cmd/main"; see DetectGoInstall.
+	Package string
+	// Dir is where the executable lives; the command installs there
+	// through GOBIN.
+	Dir string
+	// Run executes the command instead of printing it.
+	Run bool
+	// GoCommand defaults to "go".
+	GoCommand string
+	// Output receives the printed command, and the command's output with
+	// Run. Defaults to os.Stderr.
+	Output io.Writer
+}
+
+// Command returns the arguments of the go install command for tag.
+func (g *GoInstallRollout) Command(tag string) []string {
+	goCmd := g.GoCommand
+	if goCmd == "" {
+		goCmd = "go"
+	}
+	return []string{goCmd, "install", g.Package + "@" + tag}
+}
+
+func (g *GoInstallRollout) Rollout(ctx context.Context, info *UpdateInfo) error {
+	out := g.Output
+	if out == nil {
+		out = os.Stderr
+	}
+	args := g.Command(info.Remote)
+	if !g.Run {
+		fmt.Fprintf(out, "%s was installed with go install; upgrade it with:\n\tGOBIN=%s %s\n",
+			info.Remote, g.Dir, strings.Join(args, " "))
+		return nil
+	}
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), "GOBIN="+g.Dir)
+	cmd.Stdout, cmd.Stderr = out, out
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w", strings.Join(args, " "), err)
+	}
+	return nil
+}
+
+// readBuildInfo is replaced in tests.
+var readBuildInfo = buildinfo.ReadFile
+
+// goBinDirs returns the directories "go install" writes to: GOBIN, or
+// the bin directory of every GOPATH entry, defaulting to ~/go.
+func goBinDirs() []string {
+	if gobin := os.Getenv("GOBIN"); gobin != "" {
+		return []string{gobin}
+	}
+	gopath := os.Getenv("GOPATH")
+	if gopath == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil
+		}
+		gopath = filepath.Join(home, "go")
+	}
+	var dirs []string
+	for _, p := range filepath.SplitList(gopath) {
+		dirs = append(dirs, filepath.Join(p, "bin"))
+	}
+	return dirs
+}
+
+// DetectGoInstall reports whether the executable at path was installed
+// with "go install pkg@version": it lives in a GOBIN or GOPATH bin
+// directory and its build information records a module version rather
+// than "(devel)". It returns the GoInstallRollout upgrading it, or nil.
+func DetectGoInstall(path string) *GoInstallRollout {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	dir := filepath.Dir(path)
+	inBin := false
+	for _, bin := range goBinDirs() {
+		if resolved, err := filepath.EvalSymlinks(bin); err == nil {
+			bin = resolved
+		}
+		inBin = inBin || sameDir(bin, dir)
+	}
+	if !inBin {
+		return nil
+	}
+	bi, err := readBuildInfo(path)
+	if err != nil || bi.Path == "" || !strings.HasPrefix(bi.Main.Version, "v") {
+		return nil
+	}
+	return &GoInstallRollout{Package: bi.Path, Dir: dir}
+}
+
+// sameDir compares two cleaned directory names, ignoring case where the
+// file system usually does.
+func sameDir(a, b string) bool {
+	a, b = filepath.Clean(a), filepath.Clean(b)
+	if filepath.Separator == '\\' {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
@@ -0,0 +1,77 @@
+package selfupdate
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+func Test_DetectGoInstall(t *testing.T) {
+	defer func(f func(string) (*debug.BuildInfo, error)) { readBuildInfo = f }(readBuildInfo)
+	version := "v1.2.3"
+	readBuildInfo = func(string) (*debug.BuildInfo, error) {
+		return &debug.BuildInfo{Path: "example.com/app/cmd/app", Main: debug.Module{Path: "example.com/app", Version: version}}, nil
+	}
+	gopath := t.TempDir()
+	t.Setenv("GOPATH", t.TempDir()+string(os.PathListSeparator)+gopath)
+	t.Setenv("GOBIN", "")
+	installed := filepath.Join(gopath, "bin", "app")
+	elsewhere := filepath.Join(t.TempDir(), "app")
+	for _, p := range []string{installed, elsewhere} {
+		os.MkdirAll(filepath.Dir(p), 0o755)
+		os.WriteFile(p, nil, 0o755)
+	}
+
+	g := DetectGoInstall(installed)
+	if g == nil || g.Package != "example.com/app/cmd/app" || !sameDir(g.Dir, filepath.Dir(installed)) {
+		t.Fatalf("unexpected detection %+v", g)
+	}
+	if got := strings.Join(g.Command("v1.3.0"), " "); got != "go install example.com/app/cmd/app@v1.3.0" {
+		t.Errorf("unexpected command %q", got)
+	}
+	if g := DetectGoInstall(elsewhere); g != nil {
+		t.Errorf("detected outside GOPATH: %+v", g)
+	}
+	t.Setenv("GOBIN", filepath.Dir(elsewhere))
+	if g := DetectGoInstall(elsewhere); g == nil {
+		t.Error("not detected in GOBIN")
+	}
+	version = "(devel)"
+	if g := DetectGoInstall(elsewhere); g != nil {
+		t.Errorf("detected a development build: %+v", g)
+	}
+}
+
+func Test_GoInstallRollout(t *testing.T) {
+	var out strings.Builder
+	g := &GoInstallRollout{Package: "example.com/app/cmd/app", Dir: "/opt/bin", Output: &out}
+	if err := g.Rollout(context.Background(), &UpdateInfo{Remote: "v1.3.0"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "GOBIN=/opt/bin go install example.com/app/cmd/app@v1.3.0") {
+		t.Errorf("unexpected guidance %q", out.String())
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	dir := t.TempDir()
+	fakeGo := filepath.Join(dir, "go")
+	os.WriteFile(fakeGo, []byte("#!/bin/sh\necho \"$GOBIN $*\"\n"), 0o755)
+	out.Reset()
+	g.Run, g.GoCommand, g.Dir = true, fakeGo, dir
+	if err := g.Rollout(context.Background(), &UpdateInfo{Remote: "v1.3.0"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := dir + " install example.com/app/cmd/app@v1.3.0\n"; out.String() != want {
+		t.Errorf("expected %q, got %q", want, out.String())
+	}
+	g.GoCommand = filepath.Join(dir, "missing")
+	if err := g.Rollout(context.Background(), &UpdateInfo{Remote: "v1.3.0"}); err == nil {
+		t.Error("expected error for a failing command")
+	}
+}
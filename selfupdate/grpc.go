@@ -0,0 +1,198 @@
+package selfupdate
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------------------
+// gRPC control API
+// ---------------------------------------------------------------------
+
+// GRPCService is the full name of the service in updater.proto.
+const GRPCService = "updater.v1.Updater"
+
+// gRPC status codes used by the handler.
+const (
+	grpcOK            = 0
+	grpcAborted       = 10
+	grpcUnimplemented = 12
+	grpcInternal      = 13
+)
+
+// GRPCHandler serves the gRPC service defined in updater.proto: the same
+// operations as Handler, plus a stream of progress events. gRPC runs over
+// HTTP/2, so serve it with TLS or, on a trusted network, enable
+// unencrypted HTTP/2 (http.Protocols.SetUnencryptedHTTP2). IsGRPC tells
+// its requests apart when it shares a listener with other handlers.
+func (u *Updater) GRPCHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /"+GRPCService+"/CheckUpdate", func(w http.ResponseWriter, r *http.Request) {
+		info, err := u.Check(r.Context())
+		writeGRPC(w, updateInfoProto(info, err), grpcOK, "")
+	})
+	mux.HandleFunc("POST /"+GRPCService+"/ApplyUpdate", u.serveGRPCApply)
+	mux.HandleFunc("POST /"+GRPCService+"/GetStatus", func(w http.ResponseWriter, r *http.Request) {
+		writeGRPC(w, statusProto(u.Status()), grpcOK, "")
+	})
+	mux.HandleFunc("POST /"+GRPCService+"/StreamEvents", u.serveGRPCEvents)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeGRPC(w, nil, grpcUnimplemented, "unknown method "+r.URL.Path)
+	})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !IsGRPC(r) {
+			http.Error(w, "gRPC requests only", http.StatusUnsupportedMediaType)
+			return
+		}
+		// Requests carry no fields; read them to keep the stream tidy.
+		io.Copy(io.Discard, io.LimitReader(r.Body, 64<<10))
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// IsGRPC reports whether r is a gRPC request.
+func IsGRPC(r *http.Request) bool {
+	return r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc")
+}
+
+func (u *Updater) serveGRPCApply(w http.ResponseWriter, r *http.Request) {
+	ctx := WithAuditSource(context.WithoutCancel(r.Context()), "grpc")
+	info, err := u.Update(ctx)
+	if errors.Is(err, ErrBusy) {
+		writeGRPC(w, nil, grpcAborted, err.Error())
+		return
+	}
+	writeGRPC(w, updateInfoProto(info, err), grpcOK, "")
+	if info.Decision == DecisionUpgraded && u.AfterUpgrade != nil {
+		u.AfterUpgrade()
+	}
+}
+
+func (u *Updater) serveGRPCEvents(w http.ResponseWriter, r *http.Request) {
+	events, cancel := u.Subscribe()
+	defer cancel()
+	writeGRPCHeader(w)
+	f, _ := w.(http.Flusher)
+	if f != nil {
+		f.Flush()
+	}
+	for {
+		select {
+		case <-r.Context().Done():
+			writeGRPCTrailer(w, grpcOK, "")
+			return
+		case e := <-events:
+			if _, err := w.Write(grpcFrame(eventProto(e))); err != nil {
+				return
+			}
+			if f != nil {
+				f.Flush()
+			}
+		}
+	}
+}
+
+func writeGRPCHeader(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/grpc+proto")
+	w.WriteHeader(http.StatusOK)
+}
+
+func writeGRPCTrailer(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(code))
+	if msg != "" {
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", msg)
+	}
+}
+
+// writeGRPC answers a unary call with msg, unless code is not grpcOK.
+func writeGRPC(w http.ResponseWriter, msg []byte, code int, errMsg string) {
+	writeGRPCHeader(w)
+	if code == grpcOK {
+		w.Write(grpcFrame(msg))
+	}
+	writeGRPCTrailer(w, code, errMsg)
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// grpcFrame prefixes msg with the uncompressed-flag and length header of
+// the gRPC wire format.
+func grpcFrame(msg []byte) []byte {
+	b := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(b[1:], uint32(len(msg)))
+	return append(b, msg...)
+}
+
+func updateInfoProto(info *UpdateInfo, err error) []byte {
+	var b protoBuf
+	b.str(1, info.Current)
+	b.str(2, info.Remote)
+	b.str(3, string(info.Channel))
+	b.str(4, string(info.Decision))
+	if info.Asset != nil {
+		b.str(5, info.Asset.Name)
+	}
+	b.int(6, info.BytesDownloaded)
+	if err != nil {
+		b.str(7, err.Error())
+	}
+	return b
+}
+
+func statusProto(st Status) []byte {
+	var b protoBuf
+	b.str(1, st.Current)
+	b.str(2, string(st.Channel))
+	b.time(3, st.CheckedAt)
+	b.str(4, st.Result)
+	b.str(5, st.Error)
+	b.str(6, st.Role)
+	b.str(7, st.Leader)
+	return b
+}
+
+func eventProto(e Event) []byte {
+	var b protoBuf
+	b.time(1, e.Time)
+	b.str(2, e.Type)
+	b.str(3, e.Stage)
+	b.str(4, e.Result)
+	b.str(5, e.Error)
+	b.int(6, e.Bytes)
+	b.int(7, e.Total)
+	return b
+}
+
+// protoBuf appends proto3 fields in the protobuf wire format. Zero values
+// are omitted, as proto3 requires.
+type protoBuf []byte
+
+func (b *protoBuf) str(field int, s string) {
+	if s == "" {
+		return
+	}
+	*b = binary.AppendUvarint(*b, uint64(field)<<3|2)
+	*b = binary.AppendUvarint(*b, uint64(len(s)))
+	*b = append(*b, s...)
+}
+
+func (b *protoBuf) int(field int, v int64) {
+	if v == 0 {
+		return
+	}
+	*b = binary.AppendUvarint(*b, uint64(field)<<3)
+	*b = binary.AppendUvarint(*b, uint64(v))
+}
+
+func (b *protoBuf) time(field int, t time.Time) {
+	if !t.IsZero() {
+		b.int(field, t.UnixNano())
+	}
+}
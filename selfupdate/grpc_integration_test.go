@@ -0,0 +1,115 @@
+package selfupdate_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bufbuild/protocompile"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/msmania/updater/selfupdate"
+	"github.com/msmania/updater/selfupdate/selfupdatetest"
+)
+
+// Test_Updater_grpc calls GRPCHandler with grpc-go, decoding the answers
+// with the messages compiled from updater.proto, so that the hand-written
+// encoding is checked against both.
+func Test_Updater_grpc(t *testing.T) {
+	skipIfDisabled(t)
+	files, err := (&protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{}),
+	}).Compile(t.Context(), "updater.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := files[0].Services().ByName("Updater")
+	if string(svc.FullName()) != selfupdate.GRPCService {
+		t.Fatalf("updater.proto declares %s, want %s", svc.FullName(), selfupdate.GRPCService)
+	}
+
+	srv := selfupdatetest.NewServer("owner", "app",
+		selfupdatetest.Release{Tag: "v1.1.0", Assets: []selfupdatetest.Asset{{Name: "app-bin", Content: []byte("v1.1")}}},
+	)
+	defer srv.Close()
+	u := newUpdater(t, srv, "v1.0.0")
+	var p http.Protocols
+	p.SetUnencryptedHTTP2(true)
+	api := httptest.NewUnstartedServer(u.GRPCHandler())
+	api.Config.Protocols = &p
+	api.Start()
+	defer api.Close()
+	conn, err := grpc.NewClient(strings.TrimPrefix(api.URL, "http://"),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx := t.Context()
+
+	invoke := func(method string) (*dynamicpb.Message, error) {
+		t.Helper()
+		m := svc.Methods().ByName(protoreflect.Name(method))
+		out := dynamicpb.NewMessage(m.Output())
+		err := conn.Invoke(ctx, "/"+selfupdate.GRPCService+"/"+method, dynamicpb.NewMessage(m.Input()), out)
+		return out, err
+	}
+	field := func(m *dynamicpb.Message, name string) protoreflect.Value {
+		t.Helper()
+		f := m.Descriptor().Fields().ByName(protoreflect.Name(name))
+		if f == nil {
+			t.Fatalf("%s has no field %s", m.Descriptor().FullName(), name)
+		}
+		return m.Get(f)
+	}
+
+	info, err := invoke("CheckUpdate")
+	if err != nil || field(info, "current").String() != "v1.0.0" || field(info, "remote").String() != "v1.1.0" ||
+		field(info, "decision").String() != string(selfupdate.DecisionAvailable) {
+		t.Fatalf("unexpected check result %v: %v", info, err)
+	}
+
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, "/"+selfupdate.GRPCService+"/StreamEvents")
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := svc.Methods().ByName("StreamEvents")
+	if err := stream.SendMsg(dynamicpb.NewMessage(events.Input())); err != nil {
+		t.Fatal(err)
+	}
+	stream.CloseSend()
+	if _, err := stream.Header(); err != nil { // sent once subscribed
+		t.Fatal(err)
+	}
+
+	info, err = invoke("ApplyUpdate")
+	if err != nil || field(info, "decision").String() != string(selfupdate.DecisionUpgraded) ||
+		field(info, "asset_name").String() != "app-bin" || field(info, "bytes_downloaded").Int() != 4 {
+		t.Fatalf("unexpected update result %v: %v", info, err)
+	}
+	e := dynamicpb.NewMessage(events.Output())
+	if err := stream.RecvMsg(e); err != nil {
+		t.Fatal(err)
+	}
+	if field(e, "type").String() != selfupdate.EventStageStarted || field(e, "stage").String() != "update" ||
+		field(e, "time_unix_nano").Int() == 0 {
+		t.Errorf("unexpected event %v", e)
+	}
+
+	st, err := invoke("GetStatus")
+	if err != nil || field(st, "result").String() != selfupdate.ResultUpgraded ||
+		field(st, "checked_at_unix_nano").Int() == 0 {
+		t.Errorf("unexpected status %v: %v", st, err)
+	}
+
+	err = conn.Invoke(ctx, "/"+selfupdate.GRPCService+"/Reboot", dynamicpb.NewMessage(st.Descriptor()), st)
+	if status.Code(err) != codes.Unimplemented {
+		t.Error("unknown method must be unimplemented, got", err)
+	}
+}
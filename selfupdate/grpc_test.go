@@ -0,0 +1,106 @@
+package selfupdate
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// parseProto decodes the varint and length-delimited fields of msg.
+func parseProto(t *testing.T, msg []byte) map[uint64]any {
+	t.Helper()
+	fields := map[uint64]any{}
+	for len(msg) > 0 {
+		key, n := binary.Uvarint(msg)
+		msg = msg[n:]
+		v, n := binary.Uvarint(msg)
+		msg = msg[n:]
+		switch key & 7 {
+		case 0:
+			fields[key>>3] = int64(v)
+		case 2:
+			fields[key>>3] = string(msg[:v])
+			msg = msg[v:]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+	}
+	return fields
+}
+
+func Test_GRPCHandler(t *testing.T) {
+	u := &Updater{Build: BuildInfo{Version: "v1.0.0"}}
+	var p http.Protocols
+	p.SetUnencryptedHTTP2(true)
+	srv := httptest.NewUnstartedServer(u.GRPCHandler())
+	srv.Config.Protocols = &p
+	srv.Start()
+	defer srv.Close()
+	client := &http.Client{Transport: &http.Transport{Protocols: &p}}
+
+	call := func(method string) *http.Response {
+		req, _ := http.NewRequest("POST", srv.URL+"/"+GRPCService+"/"+method, bytes.NewReader(grpcFrame(nil)))
+		req.Header.Set("Content-Type", "application/grpc")
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	readFrame := func(r *bufio.Reader) []byte {
+		var hdr [5]byte
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			t.Fatal(err)
+		}
+		msg := make([]byte, binary.BigEndian.Uint32(hdr[1:]))
+		io.ReadFull(r, msg)
+		return msg
+	}
+
+	resp := call("GetStatus")
+	st := parseProto(t, readFrame(bufio.NewReader(resp.Body)))
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if st[1] != "v1.0.0" || st[4] != ResultNotChecked || resp.Trailer.Get("Grpc-Status") != "0" {
+		t.Errorf("unexpected status %v, trailer %v", st, resp.Trailer)
+	}
+
+	resp = call("Reboot")
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.Trailer.Get("Grpc-Status") != "12" {
+		t.Error("unknown method must be unimplemented, got", resp.Trailer)
+	}
+
+	resp = call("StreamEvents")
+	defer resp.Body.Close()
+	u.recordStatus(DecisionUpToDate, nil)
+	ev := parseProto(t, readFrame(bufio.NewReader(resp.Body)))
+	if ev[2] != EventStatus || ev[4] != ResultUpToDate || ev[1] == nil {
+		t.Errorf("unexpected event %v", ev)
+	}
+}
+
+func Test_Subscribe(t *testing.T) {
+	u := &Updater{}
+	events, cancel := u.Subscribe()
+	_, span := u.tracer().Start(t.Context(), SpanCheck)
+	span.RecordError(io.EOF)
+	span.End()
+	if e := <-events; e.Type != EventStageStarted || e.Stage != SpanCheck {
+		t.Errorf("unexpected event %+v", e)
+	}
+	if e := <-events; e.Type != EventStageFinished || e.Error != "EOF" {
+		t.Errorf("unexpected event %+v", e)
+	}
+	cancel()
+	cancel()
+	if _, ok := <-events; ok {
+		t.Error("channel not closed")
+	}
+	u.recordStatus(DecisionUpToDate, nil)
+}
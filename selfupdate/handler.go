@@ -0,0 +1,338 @@
+package selfupdate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// ---------------------------------------------------------------------
+// HTTP surface for host applications
+// ---------------------------------------------------------------------
+
+// Results reported in Status.Result.
+const (
+	ResultNotChecked = "not-checked"
+	ResultUpToDate   = "up-to-date"
+	ResultUpgraded   = "upgraded"
+	ResultError      = "error"
+	// ResultRolloutRequested: Updater.Rollout was asked to deploy a release.
+	ResultRolloutRequested = "rollout-requested"
+	// ResultStaged: a release is staged for the next restart.
+	ResultStaged = "staged"
+	// ResultObserving: another instance leads updates.
+	ResultObserving = "observing"
+	// ResultPaused: a newer release is held back by the fleet server's
+	// kill switch.
+	ResultPaused = "paused"
+	// ResultDeferred: a newer release is held back by the update policy
+	// or Updater.MinReleaseAge.
+	ResultDeferred = "deferred"
+	// ResultRateLimited: the release API refused requests until
+	// Status.RateLimitedUntil.
+	ResultRateLimited = "rate-limited"
+)
+
+// Status is the outcome of the most recent update check.
+type Status struct {
+	Current   string    `json:"current"`
+	Channel   Channel   `json:"channel"`
+	CheckedAt time.Time `json:"checked_at,omitzero"`
+	Result    string    `json:"result"`
+	Error     string    `json:"error,omitempty"`
+	// PendingMajorUpgrade is set when a release was held back by the
+	// major version gate (see Updater.AllowMajorUpgrade).
+	PendingMajorUpgrade *MajorUpgradeError `json:"pending_major_upgrade,omitempty"`
+	// PendingRelease is set while a release soaks for
+	// Updater.MinReleaseAge.
+	PendingRelease *ReleaseAgeError `json:"pending_release,omitempty"`
+	// Role and Leader are set with Updater.LeaderElection: this
+	// instance's role and the instance currently leading.
+	Role   string `json:"role,omitempty"`
+	Leader string `json:"leader,omitempty"`
+	// RateLimitedUntil is set while the release API rate limits this
+	// instance; checks before then fail at once with a RateLimitError.
+	RateLimitedUntil time.Time `json:"rate_limited_until,omitzero"`
+}
+
+// Status returns the outcome of the most recent MaybeUpgrade call. With a
+// StateDir, a status recorded before a restart is returned until the next
+// check.
+func (u *Updater) Status() Status {
+	u.statusMu.Lock()
+	defer u.statusMu.Unlock()
+	if u.status.Result == "" && u.StateDir != "" {
+		if err := u.readState(statusFile, &u.status); err != nil {
+			u.logf("cannot read update status: %v", err)
+		}
+	}
+	st := u.status
+	if st.Result == "" {
+		st = Status{Current: u.Build.Version, Channel: u.channel(), Result: ResultNotChecked}
+	}
+	if u.rateLimited.After(st.RateLimitedUntil) {
+		st.RateLimitedUntil = u.rateLimited
+	}
+	if !u.clock().Now().Before(st.RateLimitedUntil) {
+		st.RateLimitedUntil = time.Time{}
+	}
+	if u.LeaderElection {
+		st.Role = RoleObserver
+		if u.leading() {
+			st.Role = RoleLeader
+		}
+		st.Leader = u.leaderID()
+	}
+	return st
+}
+
+// recordStatus stores the outcome of a MaybeUpgrade call.
+func (u *Updater) recordStatus(d Decision, err error) {
+	st := Status{
+		Current:   u.Build.Version,
+		Channel:   u.channel(),
+		CheckedAt: u.clock().Now().UTC(),
+	}
+	var me *MajorUpgradeError
+	var age *ReleaseAgeError
+	switch {
+	case d == DecisionUpgraded:
+		st.Result = ResultUpgraded
+	case d == DecisionRolloutRequested:
+		st.Result = ResultRolloutRequested
+	case d == DecisionStaged:
+		st.Result = ResultStaged
+	case errors.Is(err, ErrNotLeader):
+		st.Result = ResultObserving
+	case errors.Is(err, ErrRolloutPaused):
+		st.Result = ResultPaused
+	case err == nil, errors.Is(err, ErrAlreadyLatest), errors.Is(err, ErrNoRelease):
+		st.Result = ResultUpToDate
+	case errors.As(err, &me):
+		st.Result = ResultUpToDate
+		st.PendingMajorUpgrade = me
+	case errors.As(err, &age):
+		st.Result = ResultDeferred
+		st.PendingRelease = age
+	case errors.Is(err, ErrDeferred):
+		st.Result = ResultDeferred
+	case errors.Is(err, ErrRateLimited):
+		st.Result = ResultRateLimited
+		st.Error = err.Error()
+	default:
+		st.Result = ResultError
+		st.Error = err.Error()
+	}
+	u.events.publish(Event{Time: st.CheckedAt, Type: EventStatus, Result: st.Result, Error: st.Error})
+	u.statusMu.Lock()
+	defer u.statusMu.Unlock()
+	if u.clock().Now().Before(u.rateLimited) {
+		st.RateLimitedUntil = u.rateLimited
+	}
+	u.status = st
+	if u.StateDir != "" {
+		if err := u.writeState(statusFile, st); err != nil {
+			u.logf("cannot persist update status: %v", err)
+		}
+	}
+}
+
+// noteRateLimit remembers until when err, if a RateLimitError, refuses
+// requests.
+func (u *Updater) noteRateLimit(err error) {
+	var rl *RateLimitError
+	if !errors.As(err, &rl) {
+		return
+	}
+	until := rl.Reset
+	if until.IsZero() {
+		until = u.clock().Now().Add(secondaryRateLimitDelay)
+	}
+	u.statusMu.Lock()
+	defer u.statusMu.Unlock()
+	if until.After(u.rateLimited) {
+		u.rateLimited = until
+	}
+}
+
+// rateLimitedUntil returns when the release API may be asked again after
+// a rate limit, or zero if it may be now. A limit in the Status persisted
+// in StateDir counts too, so short-lived processes respect it as well.
+func (u *Updater) rateLimitedUntil() time.Time {
+	return u.Status().RateLimitedUntil
+}
+
+// Handler serves the update endpoints relative to its mount point:
+//
+//	GET  /status   the current Status as JSON
+//	GET  /check    run Check now and return the UpdateInfo
+//	POST /trigger  run MaybeUpgrade now and return the resulting Status
+//	POST /rollback run Rollback and return the restored version
+//	GET  /history  the History as JSON
+//	GET  /version  the running BuildInfo as JSON
+//	GET  /events   progress Events as Server-Sent Events
+//
+// Mount it with http.StripPrefix, e.g.
+//
+//	mux.Handle("/api/v1/update/", http.StripPrefix("/api/v1/update", u.Handler()))
+func (u *Updater) Handler() http.Handler {
+	mux := http.NewServeMux()
+	for _, rt := range u.Routes() {
+		mux.HandleFunc(rt.Method+" "+rt.Path, rt.Handler)
+	}
+	return mux
+}
+
+// Routes returns the endpoints served by Handler, for documenting them
+// with OpenAPI.
+func (u *Updater) Routes() []Route {
+	return []Route{
+		{Method: http.MethodGet, Path: "/status", Summary: "The current update status", Response: Status{},
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, http.StatusOK, u.Status())
+			}},
+		{Method: http.MethodGet, Path: "/check", Summary: "Check for a newer release", Response: checkResult{},
+			Handler: u.serveCheck},
+		{Method: http.MethodPost, Path: "/trigger", Summary: "Upgrade now if a newer release is available",
+			Response: Status{}, Errors: []int{http.StatusConflict}, Handler: u.serveTrigger},
+		{Method: http.MethodPost, Path: "/rollback", Summary: "Restore the previous version", Response: rollbackResult{},
+			Errors:  []int{http.StatusConflict, http.StatusPreconditionFailed, http.StatusInternalServerError},
+			Handler: u.serveRollback},
+		{Method: http.MethodGet, Path: "/history", Summary: "Past updates and rollbacks", Response: []HistoryEntry{},
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, http.StatusOK, u.History())
+			}},
+		{Method: http.MethodGet, Path: "/version", Summary: "The running build", Response: BuildInfo{},
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, http.StatusOK, u.Build)
+			}},
+		{Method: http.MethodGet, Path: "/events", Summary: "Progress events as Server-Sent Events",
+			ContentType: "text/event-stream", Handler: u.serveEvents},
+	}
+}
+
+// sseKeepAlive is the interval of comments keeping an idle event stream
+// open through proxies.
+const sseKeepAlive = 30 * time.Second
+
+// serveEvents streams Events, named by their type, starting with the
+// current Status, until the client disconnects.
+func (u *Updater) serveEvents(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	events, cancel := u.Subscribe()
+	defer cancel()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	st := u.Status()
+	writeSSE(w, Event{Time: time.Now().UTC(), Type: EventStatus, Result: st.Result, Error: st.Error})
+	if err := rc.Flush(); err != nil {
+		return
+	}
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			io.WriteString(w, ": keep-alive\n\n")
+		case e := <-events:
+			writeSSE(w, e)
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeSSE(w io.Writer, e Event) {
+	data, _ := json.Marshal(e)
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+}
+
+func (u *Updater) serveTrigger(w http.ResponseWriter, r *http.Request) {
+	// The update outlives a client that disconnects mid-download.
+	ctx := WithAuditSource(context.WithoutCancel(r.Context()), "http")
+	upgraded, err := u.MaybeUpgrade(ctx)
+	if errors.Is(err, ErrBusy) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	writeJSON(w, http.StatusOK, u.Status())
+	if upgraded && u.AfterUpgrade != nil {
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		u.AfterUpgrade()
+	}
+}
+
+// checkResult is the response of GET /check.
+type checkResult struct {
+	*UpdateInfo
+	Error string `json:"error,omitempty"`
+}
+
+// rollbackResult is the response of POST /rollback.
+type rollbackResult struct {
+	Version string `json:"version"`
+}
+
+func (u *Updater) serveCheck(w http.ResponseWriter, r *http.Request) {
+	info, err := u.Check(r.Context())
+	out := checkResult{UpdateInfo: info}
+	if err != nil {
+		out.Error = err.Error()
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (u *Updater) serveRollback(w http.ResponseWriter, r *http.Request) {
+	ctx := WithAuditSource(context.WithoutCancel(r.Context()), "http")
+	version, err := u.Rollback(ctx)
+	switch {
+	case errors.Is(err, ErrBusy):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, ErrNoRollback):
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, rollbackResult{Version: version})
+	if u.AfterUpgrade != nil {
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		u.AfterUpgrade()
+	}
+}
+
+// Middleware adds an X-App-Version header to every response of next and,
+// once an install has started, answers new requests with 503 so load
+// balancers drain the instance before it restarts.
+func (u *Updater) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-App-Version", u.Build.Version)
+		if u.draining.Load() {
+			w.Header().Set("Connection", "close")
+			w.Header().Set("Retry-After", "5")
+			http.Error(w, "update in progress", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
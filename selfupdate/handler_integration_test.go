@@ -0,0 +1,31 @@
+package selfupdate_test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/msmania/updater/selfupdate"
+	"github.com/msmania/updater/selfupdate/selfupdatetest"
+)
+
+func Test_Updater_trigger(t *testing.T) {
+	skipIfDisabled(t)
+	srv := selfupdatetest.NewServer("owner", "app",
+		selfupdatetest.Release{Tag: "v1.1.0", Assets: []selfupdatetest.Asset{{Name: "app-bin", Content: []byte("v1.1")}}},
+	)
+	defer srv.Close()
+	u := newUpdater(t, srv, "v1.0.0")
+	restarted := false
+	u.AfterUpgrade = func() { restarted = true }
+
+	rec := httptest.NewRecorder()
+	u.Handler().ServeHTTP(rec, httptest.NewRequest("POST", "/trigger", nil))
+	var st selfupdate.Status
+	if err := json.Unmarshal(rec.Body.Bytes(), &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.Result != selfupdate.ResultUpgraded || !restarted {
+		t.Error("triggered upgrade not performed: " + rec.Body.String())
+	}
+}
@@ -0,0 +1,173 @@
+package selfupdate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_recordStatus(t *testing.T) {
+	u := &Updater{Build: BuildInfo{Version: "v1.4.0"}}
+	if st := u.Status(); st.Result != ResultNotChecked || st.Channel != ChannelStable {
+		t.Error("unexpected initial status", st)
+	}
+
+	u.recordStatus(DecisionMajorBlocked, fmt.Errorf("wrapped: %w", &MajorUpgradeError{Current: "v1.4.0", Candidate: "v2.0.0"}))
+	rec := httptest.NewRecorder()
+	u.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/status", nil))
+	var st Status
+	if err := json.Unmarshal(rec.Body.Bytes(), &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.Result != ResultUpToDate || st.PendingMajorUpgrade == nil || st.PendingMajorUpgrade.Candidate != "v2.0.0" {
+		t.Error("pending major upgrade not reported: " + rec.Body.String())
+	}
+
+	u.recordStatus(DecisionFailed, errors.New("boom"))
+	if st := u.Status(); st.Result != ResultError || st.Error != "boom" || st.PendingMajorUpgrade != nil {
+		t.Error("error not recorded", st)
+	}
+}
+
+func Test_Handler(t *testing.T) {
+	skipIfDisabled(t)
+	u := &Updater{Build: BuildInfo{Version: "v1.4.0"}}
+	h := u.Handler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/version", nil))
+	var bi BuildInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &bi); err != nil || bi.Version != "v1.4.0" {
+		t.Error("unexpected version response: " + rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/trigger", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Error("trigger must require POST, got", rec.Code)
+	}
+
+	u.busy.Store(true)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/trigger", nil))
+	if rec.Code != http.StatusConflict {
+		t.Error("expected 409 while busy, got", rec.Code)
+	}
+}
+
+func Test_Middleware(t *testing.T) {
+	u := &Updater{Build: BuildInfo{Version: "v1.4.0"}}
+	h := u.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("X-App-Version") != "v1.4.0" {
+		t.Error("missing X-App-Version header")
+	}
+
+	u.draining.Store(true)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Error("expected 503 while draining, got", rec.Code)
+	}
+}
+
+func Test_Status_stateDir(t *testing.T) {
+	dir := t.TempDir()
+	u := &Updater{Build: BuildInfo{Version: "v1.0.0"}, StateDir: dir}
+	u.recordStatus(DecisionUpgraded, nil)
+
+	restarted := &Updater{Build: BuildInfo{Version: "v1.1.0"}, StateDir: dir}
+	if st := restarted.Status(); st.Result != ResultUpgraded || st.Current != "v1.0.0" {
+		t.Error("status not restored from state dir", st)
+	}
+}
+
+func Test_Updater_rateLimited(t *testing.T) {
+	skipIfDisabled(t)
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"message":"You have exceeded a secondary rate limit. Please wait a few minutes before you try again."}`)
+	}))
+	defer srv.Close()
+	dir := t.TempDir()
+	u := &Updater{Owner: "o", Repo: "r", APIURL: srv.URL, StateDir: dir, Build: BuildInfo{Version: "v1.0.0"}}
+	ctx := context.Background()
+
+	var limited *RateLimitError
+	if _, err := u.Update(ctx); !errors.As(err, &limited) || limited.Reset.Before(time.Now()) {
+		t.Fatalf("Update() = %v, want a RateLimitError", err)
+	}
+	st := u.Status()
+	if st.Result != ResultRateLimited || !st.RateLimitedUntil.Equal(limited.Reset) {
+		t.Errorf("unexpected status %+v", st)
+	}
+	if _, err := u.Check(ctx); !errors.Is(err, ErrRateLimited) || requests.Load() != 1 {
+		t.Errorf("Check() during the limit = %v after %d requests", err, requests.Load())
+	}
+
+	// A new process waits as well.
+	restarted := &Updater{Owner: "o", Repo: "r", APIURL: srv.URL, StateDir: dir, Build: BuildInfo{Version: "v1.0.0"}}
+	if _, err := restarted.Check(ctx); !errors.Is(err, ErrRateLimited) || requests.Load() != 1 {
+		t.Errorf("Check() after a restart = %v after %d requests", err, requests.Load())
+	}
+
+	// Once the limit ends, checks reach the API again.
+	u.Clock = fixedClock{now: limited.Reset.Add(time.Second)}
+	if st := u.Status(); !st.RateLimitedUntil.IsZero() {
+		t.Errorf("limit still reported after it ended: %v", st.RateLimitedUntil)
+	}
+	u.Check(ctx)
+	if requests.Load() != 2 {
+		t.Errorf("%d requests after the limit ended, want 2", requests.Load())
+	}
+}
+
+func Test_Updater_rateLimited_clock(t *testing.T) {
+	skipIfDisabled(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	u := &Updater{Owner: "o", Repo: "r", APIURL: srv.URL, Clock: fixedClock{now: now},
+		Build: BuildInfo{Version: "v1.0.0"}}
+	var limited *RateLimitError
+	if _, err := u.Check(context.Background()); !errors.As(err, &limited) || !limited.Reset.Equal(now.Add(2*time.Minute)) {
+		t.Fatalf("Check() = %v, want a limit until %v", err, now.Add(2*time.Minute))
+	}
+	if st := u.Status(); !st.RateLimitedUntil.Equal(limited.Reset) {
+		t.Errorf("RateLimitedUntil = %v, want %v", st.RateLimitedUntil, limited.Reset)
+	}
+
+	// A 429 from another server, here the fleet server, is not a limit of
+	// the release API.
+	u = &Updater{Owner: "o", Repo: "r", APIURL: srv.URL, Fleet: FleetConfig{URL: srv.URL},
+		Clock: fixedClock{now: now}, Build: BuildInfo{Version: "v1.0.0"}}
+	if _, err := u.Update(context.Background()); err == nil || errors.Is(err, ErrRateLimited) {
+		t.Errorf("Update() = %v, want a plain HTTP error", err)
+	}
+	if st := u.Status(); !st.RateLimitedUntil.IsZero() || st.Result == ResultRateLimited {
+		t.Errorf("fleet server 429 postponed release checks: %+v", st)
+	}
+}
+
+// fixedClock is the system clock stopped at now.
+type fixedClock struct {
+	SystemClock
+	now time.Time
+}
+
+func (c fixedClock) Now() time.Time { return c.now }
@@ -0,0 +1,55 @@
+package selfupdate
+
+import (
+	"slices"
+	"time"
+)
+
+const (
+	// historyFile is the name of the persisted history below StateDir.
+	historyFile = "history.json"
+	// historySize is the number of entries History keeps.
+	historySize = 100
+)
+
+// HistoryEntry records an install, rollout, rollback or failed update.
+// Checks that change nothing are not recorded.
+type HistoryEntry struct {
+	Time     time.Time `json:"time"`
+	From     string    `json:"from"`
+	To       string    `json:"to,omitempty"`
+	Decision Decision  `json:"decision"`
+	// Trigger is what started it, as in AuditRecord.Source.
+	Trigger   string    `json:"trigger,omitempty"`
+	Durations Durations `json:"durations"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// History returns the most recent updates, newest first. With a
+// StateDir it survives restarts, so it includes the update that started
+// the running version.
+func (u *Updater) History() []HistoryEntry {
+	u.statusMu.Lock()
+	defer u.statusMu.Unlock()
+	if u.history == nil && u.StateDir != "" {
+		if err := u.readState(historyFile, &u.history); err != nil {
+			u.logf("cannot read update history: %v", err)
+		}
+	}
+	return slices.Clone(u.history)
+}
+
+func (u *Updater) recordHistory(e HistoryEntry) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	u.History() // loads the persisted entries
+	u.statusMu.Lock()
+	defer u.statusMu.Unlock()
+	u.history = append([]HistoryEntry{e}, u.history[:min(len(u.history), historySize-1)]...)
+	if u.StateDir != "" {
+		if err := u.writeState(historyFile, u.history); err != nil {
+			u.logf("cannot persist update history: %v", err)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package selfupdate
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Decision is the outcome of an update check.
+type Decision string
+
+const (
+	// DecisionUpgraded: the release was installed (Update only).
+	DecisionUpgraded Decision = "upgraded"
+	// DecisionStaged: the release was downloaded, verified and staged
+	// for ActivateStaged (Update only, with Updater.Staged).
+	DecisionStaged Decision = "staged"
+	// DecisionRolloutRequested: Updater.Rollout was asked to deploy the
+	// release (Update only).
+	DecisionRolloutRequested Decision = "rollout-requested"
+	// DecisionAvailable: a newer eligible release exists (Check only).
+	DecisionAvailable Decision = "available"
+	// DecisionUpToDate: the selected release is not newer.
+	DecisionUpToDate Decision = "up-to-date"
+	// DecisionExcluded: the selected release is newer but excluded by the
+	// channel or constraint, or was rolled back.
+	DecisionExcluded Decision = "excluded"
+	// DecisionNoRelease: no release is eligible at all.
+	DecisionNoRelease Decision = "no-release"
+	// DecisionUnparsable: the current or remote version cannot be parsed,
+	// e.g. a "dev" build.
+	DecisionUnparsable Decision = "unparsable-version"
+	// DecisionMajorBlocked: a newer major version awaits opt-in.
+	DecisionMajorBlocked Decision = "major-blocked"
+	// DecisionPolicyBlocked: the release failed a policy check, i.e. the
+	// SBOM policy, an advisory feed or Updater.AcceptRelease.
+	DecisionPolicyBlocked Decision = "policy-blocked"
+	// DecisionPackageManaged: the executable belongs to a system
+	// package and is left to the package manager (Update only).
+	DecisionPackageManaged Decision = "package-managed"
+	// DecisionDeferred: the update policy holds back an automatic
+	// update of a newer release (Update only; see UpdatePolicy), or the
+	// release is younger than Updater.MinReleaseAge.
+	DecisionDeferred Decision = "deferred"
+	// DecisionSuspended: updates are suspended after a crash loop or by
+	// the fleet server's kill switch.
+	DecisionSuspended Decision = "suspended"
+	// DecisionRolledBack: the previous version was restored (history
+	// only; see Updater.Rollback).
+	DecisionRolledBack Decision = "rolled-back"
+	// DecisionDisabled: the binary cannot update itself; see Enabled.
+	DecisionDisabled Decision = "disabled"
+	// DecisionObserving: another instance leads updates of the target.
+	DecisionObserving Decision = "observing"
+	// DecisionFailed: the check or installation failed; see the error.
+	DecisionFailed Decision = "failed"
+)
+
+// UpdateInfo describes what Check or Update found and did.
+type UpdateInfo struct {
+	Current         string     `json:"current"`
+	Remote          string     `json:"remote,omitempty"`
+	Channel         Channel    `json:"channel"`
+	Decision        Decision   `json:"decision"`
+	Asset           *AssetInfo `json:"asset,omitempty"`
+	BytesDownloaded int64      `json:"bytes_downloaded,omitempty"`
+	Retries         int        `json:"retries,omitempty"`
+	Durations       Durations  `json:"durations"`
+}
+
+// AssetInfo describes the release asset selected for installation.
+type AssetInfo struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	Size int64  `json:"size,omitempty"`
+	// Digest is the expected digest, if a checksum asset was consulted.
+	Digest string `json:"digest,omitempty"`
+}
+
+// Durations records the time spent in each stage. Stages that did not run
+// are zero. In JSON they are duration strings such as "1.5s".
+type Durations struct {
+	Check    time.Duration
+	Download time.Duration
+	Verify   time.Duration
+	Install  time.Duration
+	Total    time.Duration
+}
+
+type durationsJSON struct {
+	Check    string `json:"check,omitempty"`
+	Download string `json:"download,omitempty"`
+	Verify   string `json:"verify,omitempty"`
+	Install  string `json:"install,omitempty"`
+	Total    string `json:"total,omitempty"`
+}
+
+func (d Durations) MarshalJSON() ([]byte, error) {
+	format := func(d time.Duration) string {
+		if d == 0 {
+			return ""
+		}
+		return d.String()
+	}
+	return json.Marshal(durationsJSON{
+		format(d.Check), format(d.Download), format(d.Verify), format(d.Install), format(d.Total),
+	})
+}
+
+func (d *Durations) UnmarshalJSON(b []byte) error {
+	var j durationsJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	for _, f := range []struct {
+		s   string
+		dst *time.Duration
+	}{{j.Check, &d.Check}, {j.Download, &d.Download}, {j.Verify, &d.Verify}, {j.Install, &d.Install}, {j.Total, &d.Total}} {
+		if f.s == "" {
+			continue
+		}
+		v, err := time.ParseDuration(f.s)
+		if err != nil {
+			return err
+		}
+		*f.dst = v
+	}
+	return nil
+}
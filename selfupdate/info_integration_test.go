@@ -0,0 +1,50 @@
+package selfupdate_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/msmania/updater/selfupdate"
+	"github.com/msmania/updater/selfupdate/selfupdatetest"
+)
+
+func Test_Updater_updateInfo(t *testing.T) {
+	skipIfDisabled(t)
+	srv := selfupdatetest.NewServer("owner", "app",
+		selfupdatetest.Release{Tag: "v1.1.0", Assets: []selfupdatetest.Asset{{Name: "app-bin", Content: []byte("v1.1")}}, Checksums: true},
+	)
+	defer srv.Close()
+	u := newUpdater(t, srv, "v1.0.0")
+	u.ChecksumAsset = "checksums.txt"
+	ctx := context.Background()
+
+	info, err := u.Check(ctx)
+	if err != nil || info.Decision != selfupdate.DecisionAvailable || info.Remote != "v1.1.0" {
+		t.Fatalf("unexpected check result %+v: %v", info, err)
+	}
+	if b, _ := os.ReadFile(u.Path); string(b) != "old" {
+		t.Error("Check must not install")
+	}
+
+	info, err = u.Update(ctx)
+	if err != nil || info.Decision != selfupdate.DecisionUpgraded {
+		t.Fatalf("unexpected update result %+v: %v", info, err)
+	}
+	if info.Asset == nil || info.Asset.Name != "app-bin" || info.Asset.Size != 4 || info.Asset.Digest == "" {
+		t.Errorf("unexpected asset %+v", info.Asset)
+	}
+	if info.BytesDownloaded != 4 || info.Durations.Total == 0 || info.Durations.Download == 0 {
+		t.Errorf("unexpected stats %+v", info)
+	}
+
+	u.Build.Version = "v1.1.0"
+	if info, err := u.Update(ctx); !errors.Is(err, selfupdate.ErrAlreadyLatest) || info.Decision != selfupdate.DecisionUpToDate {
+		t.Error("expected up-to-date decision, got", info.Decision, err)
+	}
+	u.Build.Version = "dev"
+	if info, _ := u.Check(ctx); info.Decision != selfupdate.DecisionUnparsable {
+		t.Error("expected unparsable decision, got", info.Decision)
+	}
+}
@@ -0,0 +1,25 @@
+package selfupdate
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func Test_Durations_JSON(t *testing.T) {
+	d := Durations{Check: 150 * time.Millisecond, Total: 2 * time.Second}
+	b, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"check":"150ms","total":"2s"}` {
+		t.Error("unexpected JSON " + string(b))
+	}
+	var back Durations
+	if err := json.Unmarshal(b, &back); err != nil || back != d {
+		t.Error("round trip failed", back, err)
+	}
+	if err := json.Unmarshal([]byte(`{"check":"soon"}`), &back); err == nil {
+		t.Error("expected error for malformed duration")
+	}
+}
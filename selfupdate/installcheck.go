@@ -0,0 +1,122 @@
+package selfupdate
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// InstallCheck is the result of VerifyInstalled.
+type InstallCheck struct {
+	Tag   string `json:"tag"`
+	Asset string `json:"asset"`
+	// Digest is the published digest of Asset the download was checked
+	// against, empty if the release states none.
+	Digest string `json:"digest,omitempty"`
+	// Published and Installed are the hex SHA-256 digests of the binary
+	// in the release and of the file checked.
+	Published string `json:"published,omitempty"`
+	Installed string `json:"installed,omitempty"`
+	Match     bool   `json:"match"`
+}
+
+// VerifyInstalled checks whether the file at path is the binary released
+// as tag, for incident response and drift detection. It downloads the
+// asset Update would install from that release, verifies it as Update
+// would (checksums, signature and transparency log, as configured),
+// extracts the binary and compares it with path. A file that differs is
+// reported in the result and with an error wrapping ErrChecksumMismatch.
+func (u *Updater) VerifyInstalled(ctx context.Context, path, tag string) (*InstallCheck, error) {
+	dir, err := os.MkdirTemp(u.WorkDir, "verify-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	binary := filepath.Join(dir, "binary")
+	a, want, err := u.fetchBinary(ctx, tag, filepath.Base(path), binary)
+	if a.Tag == "" {
+		return nil, err
+	}
+	c := &InstallCheck{Tag: a.Tag, Asset: a.Name}
+	if want.Algorithm != "" {
+		c.Digest = want.String()
+	}
+	if err != nil {
+		return c, err
+	}
+	published, err := fileSHA256(binary)
+	if err != nil {
+		return c, err
+	}
+	installed, err := fileSHA256(path)
+	if err != nil {
+		return c, err
+	}
+	c.Published, c.Installed = hex.EncodeToString(published), hex.EncodeToString(installed)
+	if c.Match = bytes.Equal(published, installed); !c.Match {
+		return c, fmt.Errorf("%w: %s is not the binary of %s in %s", ErrChecksumMismatch, path, a.Name, a.Tag)
+	}
+	return c, nil
+}
+
+// fetchBinary downloads the asset Update would install from the release
+// tagged tag, verifies it as Update would, and writes the binary it
+// holds, the archive member ArchiveMember or else member, to dst. The
+// returned artifact names the release and asset once they are known,
+// also on error; want is the digest the asset was checked against.
+func (u *Updater) fetchBinary(ctx context.Context, tag, member, dst string) (a Artifact, want Digest, err error) {
+	if tag == "" {
+		return a, want, fmt.Errorf("%w: no release to verify against", ErrNoRelease)
+	}
+	ctx = withClient(ctx, u.Client())
+	rel, asset, err := u.check(ctx, tag)
+	if err != nil {
+		return a, want, err
+	}
+	a.Tag, a.Name = rel.TagName, asset.Name
+	if want, err = u.expectedDigest(ctx, rel, asset); err != nil {
+		return a, want, fmt.Errorf("cannot fetch checksums: %w", err)
+	}
+	if want.Algorithm == "" && u.Verifier == nil && u.Keyring == nil {
+		u.logf("WARNING: %s states no digest and no verifier is configured; only what GitHub serves is known", asset.Name)
+	}
+	base, dec := compressionByName(asset.Name)
+	format := archiveFormat(base)
+	var streamDec Decompressor
+	downloadPath := dst
+	if format == "" {
+		streamDec = dec
+	} else {
+		downloadPath = dst + ".download"
+		defer os.Remove(downloadPath)
+	}
+	res, err := u.fetchAsset(ctx, rel.TagName, asset, downloadPath, want, streamDec, &UpdateInfo{Asset: &AssetInfo{}})
+	if err != nil {
+		return a, want, err
+	}
+	if err := u.checkTransparency(ctx, rel, asset.Name, res); err != nil {
+		os.Remove(downloadPath)
+		return a, want, fmt.Errorf("transparency log check failed: %w", err)
+	}
+	if format != "" {
+		if u.ArchiveMember != "" {
+			member = u.ArchiveMember
+		}
+		if err := extractMember(downloadPath, format, dec, member, dst, u.MaxExtractSize); err != nil {
+			return a, want, fmt.Errorf("extract failed: %w", err)
+		}
+	}
+	a.Path, a.Size, a.SHA256, a.SHA512 = dst, res.Size, res.SHA256, res.SHA512
+	if a.Signature, err = u.signature(ctx, rel, asset.Name); err != nil {
+		os.Remove(dst)
+		return a, want, fmt.Errorf("cannot fetch signature: %w", err)
+	}
+	if err := u.verifyArtifact(ctx, a); err != nil {
+		os.Remove(dst)
+		return a, want, fmt.Errorf("verification failed: %w", err)
+	}
+	return a, want, nil
+}
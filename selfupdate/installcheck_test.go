@@ -0,0 +1,61 @@
+package selfupdate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func Test_Updater_VerifyInstalled(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "app_linux_amd64.tar.gz")
+	writeTarGz(t, archive, archiveEntry{"README", "docs"}, archiveEntry{"app", "release binary"})
+	data, _ := os.ReadFile(archive)
+	sum := sha256.Sum256(data)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/o/r/releases/tags/v1.6.2":
+			fmt.Fprintf(w, `{"tag_name":"v1.6.2","assets":[{"name":"app_linux_amd64.tar.gz","size":%d,`+
+				`"digest":%q,"browser_download_url":"%s/app.tar.gz"}]}`, len(data), digest, srv.URL)
+		case "/app.tar.gz":
+			w.Write(data)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	u := &Updater{Owner: "o", Repo: "r", APIURL: srv.URL, WorkDir: dir,
+		AssetRegexp: regexp.MustCompile(`app_linux_amd64\.tar\.gz`)}
+	installed := filepath.Join(t.TempDir(), "app")
+
+	os.WriteFile(installed, []byte("release binary"), 0o755)
+	c, err := u.VerifyInstalled(context.Background(), installed, "v1.6.2")
+	want := sha256.Sum256([]byte("release binary"))
+	if err != nil || !c.Match || c.Tag != "v1.6.2" || c.Digest != digest ||
+		c.Published != hex.EncodeToString(want[:]) || c.Installed != c.Published {
+		t.Fatalf("VerifyInstalled = %+v, %v", c, err)
+	}
+
+	os.WriteFile(installed, []byte("patched binary"), 0o755)
+	if c, err = u.VerifyInstalled(context.Background(), installed, "v1.6.2"); !errors.Is(err, ErrChecksumMismatch) ||
+		c.Match || c.Installed == c.Published {
+		t.Errorf("VerifyInstalled of a modified binary = %+v, %v", c, err)
+	}
+
+	if _, err := u.VerifyInstalled(context.Background(), installed, "v9.9.9"); err == nil {
+		t.Error("expected an error for an unknown release")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("%d files left in the work directory, want 1", len(entries))
+	}
+}
@@ -0,0 +1,36 @@
+package selfupdate_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/msmania/updater/selfupdate"
+	"github.com/msmania/updater/selfupdate/selfupdatetest"
+)
+
+// skipIfDisabled skips tests that need a working Updater in builds with
+// the noselfupdate tag.
+func skipIfDisabled(t *testing.T) {
+	t.Helper()
+	if !selfupdate.Enabled() {
+		t.Skip("built with the noselfupdate tag")
+	}
+}
+
+// newUpdater returns an Updater of version current installing the
+// releases of srv over a temporary executable holding "old".
+func newUpdater(t *testing.T, srv *selfupdatetest.Server, current string) *selfupdate.Updater {
+	path := filepath.Join(t.TempDir(), "app")
+	if err := os.WriteFile(path, []byte("old"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return &selfupdate.Updater{
+		Owner:     "owner",
+		Repo:      "app",
+		AssetName: "app-bin",
+		Build:     selfupdate.BuildInfo{Version: current},
+		APIURL:    srv.URL,
+		Path:      path,
+	}
+}
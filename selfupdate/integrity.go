@@ -0,0 +1,153 @@
+package selfupdate
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// IntegrityConfig makes the updater check periodically that the
+// executable on disk is still the one it installed (see
+// RunIntegrityChecks), to notice when something else replaced or
+// modified it. It requires Updater.StateDir, where every install records
+// the SHA-256 of the binary it wrote. An executable the updater never
+// installed is recorded on the first check and trusted from then on.
+type IntegrityConfig struct {
+	// Interval is the time between checks. Zero disables them.
+	Interval time.Duration
+	// Reinstall installs the recorded version again, downloaded and
+	// verified as by Update, over an executable found modified.
+	Reinstall bool
+	// OnDrift, if set, is called with the error of every check that found
+	// the executable modified, e.g. to raise an alert.
+	OnDrift func(err error)
+}
+
+// installedFile records the binary last installed.
+const installedFile = "installed.json"
+
+// installedState is persisted as installedFile.
+type installedState struct {
+	Version string `json:"version"`
+	Path    string `json:"path"`
+	SHA256  string `json:"sha256"`
+}
+
+func (u *Updater) integrityEnabled() bool {
+	return u.Integrity.Interval > 0 && u.StateDir != ""
+}
+
+// recordInstalled records the digest of exePath, just installed as
+// version.
+func (u *Updater) recordInstalled(version, exePath string) {
+	if !u.integrityEnabled() {
+		return
+	}
+	sum, err := fileSHA256(exePath)
+	if err == nil {
+		err = u.writeState(installedFile, installedState{Version: version, Path: exePath, SHA256: hex.EncodeToString(sum)})
+	}
+	if err != nil {
+		u.logf("WARNING: cannot record the digest of %s: %v", exePath, err)
+	}
+}
+
+// CheckIntegrity compares the executable with the digest recorded when
+// it was installed and returns an error wrapping ErrModified if it
+// differs, after reinstalling the recorded version if
+// Integrity.Reinstall is set. Without a record, as before the first
+// update, the executable is recorded as the running version.
+func (u *Updater) CheckIntegrity(ctx context.Context) error {
+	if u.StateDir == "" {
+		return errors.New("integrity check requires StateDir")
+	}
+	exePath, err := u.path()
+	if err == nil {
+		exePath, err = u.overlayPath(exePath)
+	}
+	if err != nil {
+		return err
+	}
+	var rec installedState
+	if err := u.readState(installedFile, &rec); err != nil {
+		return err
+	}
+	sum, err := fileSHA256(exePath)
+	if err != nil {
+		return err
+	}
+	got := hex.EncodeToString(sum)
+	if rec.SHA256 == "" || rec.Path != exePath {
+		u.logf("Recording the digest of %s as %s", exePath, u.Build.Version)
+		return u.writeState(installedFile, installedState{Version: u.Build.Version, Path: exePath, SHA256: got})
+	}
+	if got == rec.SHA256 {
+		return nil
+	}
+	err = fmt.Errorf("%w: %s has sha256:%s, not sha256:%s as installed with %s",
+		ErrModified, exePath, got, rec.SHA256, rec.Version)
+	u.audit(WithAuditSource(ctx, "integrity"), AuditDrift, rec.Version, "sha256:"+got)
+	if !u.Integrity.Reinstall {
+		return err
+	}
+	if rerr := u.reinstall(ctx, rec, exePath); rerr != nil {
+		return fmt.Errorf("%w; reinstall failed: %w", err, rerr)
+	}
+	return fmt.Errorf("%w; reinstalled %s", err, rec.Version)
+}
+
+// reinstall installs rec.Version over exePath and checks that the result
+// is the binary recorded.
+func (u *Updater) reinstall(ctx context.Context, rec installedState, exePath string) error {
+	if !u.busy.CompareAndSwap(false, true) {
+		return ErrBusy
+	}
+	defer u.busy.Store(false)
+	u.logf("Reinstalling %s over %s", rec.Version, exePath)
+	tmpPath, err := u.workPath(exePath, ".new")
+	if err != nil {
+		return err
+	}
+	a, _, err := u.fetchBinary(ctx, rec.Version, filepath.Base(exePath), tmpPath)
+	if err != nil {
+		return err
+	}
+	sum, err := fileSHA256(tmpPath)
+	if err == nil && hex.EncodeToString(sum) != rec.SHA256 {
+		err = fmt.Errorf("%w: the binary of %s is not the one recorded", ErrChecksumMismatch, rec.Version)
+	}
+	if err == nil {
+		err = u.applier().Apply(ctx, a, exePath)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	u.audit(WithAuditSource(ctx, "integrity"), AuditInstall, a.Tag, Digest{"sha256", a.SHA256}.String())
+	return nil
+}
+
+// RunIntegrityChecks runs CheckIntegrity every Integrity.Interval until
+// ctx is done, logging and passing to Integrity.OnDrift what it finds. It
+// returns at once unless Integrity.Interval and StateDir are set.
+func (u *Updater) RunIntegrityChecks(ctx context.Context) {
+	if !u.integrityEnabled() || !Enabled() {
+		return
+	}
+	for {
+		err := u.CheckIntegrity(ctx)
+		if err != nil && ctx.Err() == nil {
+			u.logf("WARNING: integrity check: %v", err)
+			if u.Integrity.OnDrift != nil && errors.Is(err, ErrModified) {
+				u.Integrity.OnDrift(err)
+			}
+		}
+		if !u.sleepUntil(ctx, u.clock().Now().Add(u.Integrity.Interval)) {
+			return
+		}
+	}
+}
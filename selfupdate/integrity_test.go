@@ -0,0 +1,83 @@
+package selfupdate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Updater_CheckIntegrity(t *testing.T) {
+	skipIfDisabled(t)
+	served := "v1 binary"
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/o/r/releases/tags/v1.0.0":
+			fmt.Fprintf(w, `{"tag_name":"v1.0.0","assets":[{"name":"app","size":%d,"browser_download_url":"%s/app"}]}`,
+				len(served), srv.URL)
+		case "/app":
+			fmt.Fprint(w, served)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	dir := t.TempDir()
+	exe := filepath.Join(dir, "app")
+	os.WriteFile(exe, []byte("v1 binary"), 0o755)
+	u := &Updater{Owner: "o", Repo: "r", APIURL: srv.URL, Path: exe, StateDir: filepath.Join(dir, "state"),
+		Build: BuildInfo{Version: "v1.0.0"}, AssetRegexp: regexp.MustCompile("app"),
+		Integrity: IntegrityConfig{Interval: time.Hour}}
+	ctx := context.Background()
+	verify := func(wantErr error, wantContent string) {
+		t.Helper()
+		if err := u.CheckIntegrity(ctx); !errors.Is(err, wantErr) {
+			t.Errorf("CheckIntegrity() = %v, want %v", err, wantErr)
+		}
+		if data, _ := os.ReadFile(exe); string(data) != wantContent {
+			t.Errorf("executable holds %q, want %q", data, wantContent)
+		}
+	}
+	verify(nil, "v1 binary") // records the baseline
+	verify(nil, "v1 binary")
+
+	os.WriteFile(exe, []byte("patched"), 0o755)
+	verify(ErrModified, "patched")
+
+	u.Integrity.Reinstall = true
+	served = "other binary"
+	verify(ErrChecksumMismatch, "patched")
+	served = "v1 binary"
+	err := u.CheckIntegrity(ctx)
+	if !errors.Is(err, ErrModified) || !strings.Contains(err.Error(), "reinstalled v1.0.0") {
+		t.Errorf("CheckIntegrity() = %v", err)
+	}
+	verify(nil, "v1 binary")
+
+	// An install records the new binary.
+	os.WriteFile(exe, []byte("v2 binary"), 0o755)
+	u.recordInstalled("v2.0.0", exe)
+	verify(nil, "v2 binary")
+
+	// RunIntegrityChecks reports drift to OnDrift.
+	u.Integrity.Reinstall = false
+	os.WriteFile(exe, []byte("patched"), 0o755)
+	ctx, cancel := context.WithCancel(ctx)
+	var drifts []error
+	u.Integrity.OnDrift = func(err error) {
+		drifts = append(drifts, err)
+		cancel()
+	}
+	u.RunIntegrityChecks(ctx)
+	if len(drifts) != 1 || !errors.Is(drifts[0], ErrModified) {
+		t.Errorf("OnDrift got %v", drifts)
+	}
+}
@@ -0,0 +1,283 @@
+package selfupdate
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DefaultKeyringAsset is the default Keyring.Asset.
+const DefaultKeyringAsset = "keyring.json"
+
+// ErrKeyringRollback is returned when a release carries a key list that
+// conflicts with the pinned one.
+var ErrKeyringRollback = errors.New("key list conflicts with the pinned keyring")
+
+// TrustedKey is an Ed25519 key of a Keyring.
+type TrustedKey struct {
+	PublicKey ed25519.PublicKey `json:"public_key"`
+	// NotAfter, if set, is when the key stops being trusted, both for
+	// release signatures and for signing key lists.
+	NotAfter time.Time `json:"not_after,omitzero"`
+}
+
+// ID returns the first 8 bytes of the key's SHA-256, in hex.
+func (k TrustedKey) ID() string {
+	sum := sha256.Sum256(k.PublicKey)
+	return hex.EncodeToString(sum[:8])
+}
+
+// valid reports whether k is trusted at now.
+func (k TrustedKey) valid(now time.Time) bool {
+	return k.NotAfter.IsZero() || now.Before(k.NotAfter)
+}
+
+// keyList is the signed content of a keyring document.
+type keyList struct {
+	Version int64        `json:"version"`
+	Keys    []TrustedKey `json:"keys"`
+}
+
+// keyringDocument is the file format of a keyring: a base64 JSON keyList
+// and signatures of those payload bytes. The payload is kept encoded so
+// signatures do not depend on a JSON canonicalization.
+type keyringDocument struct {
+	Payload    string             `json:"payload"`
+	Signatures []keyringSignature `json:"signatures,omitempty"`
+}
+
+type keyringSignature struct {
+	KeyID     string `json:"key_id"`
+	Signature []byte `json:"sig"`
+}
+
+// SignKeyring returns a keyring document listing keys as the given
+// version, signed by signers. Version must grow with every change.
+func SignKeyring(version int64, keys []TrustedKey, signers ...ed25519.PrivateKey) ([]byte, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("keyring lists no keys")
+	}
+	payload, err := json.Marshal(keyList{Version: version, Keys: keys})
+	if err != nil {
+		return nil, err
+	}
+	doc := keyringDocument{Payload: base64.StdEncoding.EncodeToString(payload)}
+	for _, s := range signers {
+		pub := TrustedKey{PublicKey: s.Public().(ed25519.PublicKey)}
+		doc.Signatures = append(doc.Signatures, keyringSignature{KeyID: pub.ID(), Signature: ed25519.Sign(s, payload)})
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// parseKeyring decodes a keyring document, returning its key list and
+// the raw payload its signatures cover.
+func parseKeyring(data []byte) (*keyList, *keyringDocument, []byte, error) {
+	var doc keyringDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid keyring: %w", err)
+	}
+	payload, err := base64.StdEncoding.DecodeString(doc.Payload)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid keyring payload: %w", err)
+	}
+	var list keyList
+	if err := json.Unmarshal(payload, &list); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid keyring payload: %w", err)
+	}
+	if len(list.Keys) == 0 {
+		return nil, nil, nil, errors.New("keyring lists no keys")
+	}
+	for _, k := range list.Keys {
+		if len(k.PublicKey) != ed25519.PublicKeySize {
+			return nil, nil, nil, errors.New("keyring lists a malformed key")
+		}
+	}
+	return &list, &doc, payload, nil
+}
+
+// Keyring is a rotatable set of trusted release signing keys. It starts
+// from a key list built into the binary and can be replaced by a newer
+// list that a release carries as Asset, signed by a key trusted at that
+// time. Accepted lists are pinned to Path, so a later start, or an older
+// release served again, cannot bring back retired keys:
+//
+//   - a list with a lower version than the pinned one is ignored;
+//   - a different list with the same version is rejected;
+//   - a higher version needs a signature by an unexpired pinned key.
+type Keyring struct {
+	// Path, if set, is where the current key list is pinned.
+	Path string
+	// Asset names the release asset carrying a key list; releases
+	// without it keep the current keys. Defaults to DefaultKeyringAsset.
+	Asset string
+
+	mu      sync.Mutex
+	list    keyList
+	payload []byte
+}
+
+// NewKeyring returns a Keyring starting from the keyring document
+// embedded, trusted as is, or from the one pinned at path if that has a
+// higher version. path may be empty.
+func NewKeyring(embedded []byte, path string) (*Keyring, error) {
+	list, _, payload, err := parseKeyring(embedded)
+	if err != nil {
+		return nil, err
+	}
+	k := &Keyring{Path: path, list: *list, payload: payload}
+	if path == "" {
+		return k, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return k, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	pinned, _, payload, err := parseKeyring(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if pinned.Version >= list.Version {
+		k.list, k.payload = *pinned, payload
+	}
+	return k, nil
+}
+
+// Version returns the version of the current key list.
+func (k *Keyring) Version() int64 {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.list.Version
+}
+
+// Keys returns the current key list, including expired keys.
+func (k *Keyring) Keys() []TrustedKey {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return slices.Clone(k.list.Keys)
+}
+
+// asset returns the name of the release asset carrying a key list.
+func (k *Keyring) asset() string {
+	if k.Asset == "" {
+		return DefaultKeyringAsset
+	}
+	return k.Asset
+}
+
+// Rotate replaces the current key list with the one in the keyring
+// document data, following the rules of Keyring, and pins it. It reports
+// whether the keys changed.
+func (k *Keyring) Rotate(data []byte, now time.Time) (bool, error) {
+	list, doc, payload, err := parseKeyring(data)
+	if err != nil {
+		return false, err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	switch {
+	case list.Version < k.list.Version:
+		return false, nil
+	case list.Version == k.list.Version:
+		if bytes.Equal(payload, k.payload) {
+			return false, nil
+		}
+		return false, fmt.Errorf("%w: another list has version %d", ErrKeyringRollback, list.Version)
+	}
+	if !k.signedByTrusted(doc, payload, now) {
+		return false, fmt.Errorf("%w: key list %d is not signed by a trusted key", ErrSignatureInvalid, list.Version)
+	}
+	if k.Path != "" {
+		if err := writeFileAtomic(k.Path, data); err != nil {
+			return false, fmt.Errorf("cannot pin keyring: %w", err)
+		}
+	}
+	k.list, k.payload = *list, payload
+	return true, nil
+}
+
+// signedByTrusted reports whether one of doc's signatures of payload is
+// by a current key valid at now. k.mu is held.
+func (k *Keyring) signedByTrusted(doc *keyringDocument, payload []byte, now time.Time) bool {
+	for _, s := range doc.Signatures {
+		for _, key := range k.list.Keys {
+			if key.ID() == s.KeyID && key.valid(now) && ed25519.Verify(key.PublicKey, payload, s.Signature) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Verifier returns a Verifier accepting artifacts whose Signature is an
+// Ed25519 signature of the raw SHA-256 digest, as for Ed25519Signature,
+// by a key of the current list that has not expired.
+func (k *Keyring) Verifier() Verifier {
+	return VerifierFunc(func(ctx context.Context, a Artifact) error {
+		now := time.Now()
+		var keys []ed25519.PublicKey
+		for _, key := range k.Keys() {
+			if key.valid(now) {
+				keys = append(keys, key.PublicKey)
+			}
+		}
+		if len(keys) == 0 {
+			return fmt.Errorf("%w: every trusted key has expired", ErrSignatureInvalid)
+		}
+		return Ed25519Signature(keys...).Verify(ctx, a)
+	})
+}
+
+// rotateKeyring applies the key list carried by rel, if any.
+func (u *Updater) rotateKeyring(ctx context.Context, rel *ghRelease) error {
+	if u.Keyring == nil {
+		return nil
+	}
+	asset, err := rel.findAsset(u.Keyring.asset())
+	if err != nil {
+		return nil // the release keeps the current keys
+	}
+	data, err := u.http().fetchSmall(ctx, asset.BrowserDownloadURL, maxSignatureSize)
+	if err != nil {
+		return err
+	}
+	rotated, err := u.Keyring.Rotate(data, time.Now())
+	if err != nil {
+		return err
+	}
+	if rotated {
+		u.logf("Keyring rotated to version %d by %s", u.Keyring.Version(), rel.TagName)
+	}
+	return nil
+}
+
+// writeFileAtomic replaces path with data through a temporary file in
+// the same directory.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
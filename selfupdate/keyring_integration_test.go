@@ -0,0 +1,61 @@
+package selfupdate_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/msmania/updater/selfupdate"
+	"github.com/msmania/updater/selfupdate/selfupdatetest"
+)
+
+func Test_Updater_keyring(t *testing.T) {
+	skipIfDisabled(t)
+	oldPub, oldKey, _ := ed25519.GenerateKey(nil)
+	newPub, newKey, _ := ed25519.GenerateKey(nil)
+	embedded, _ := selfupdate.SignKeyring(1, []selfupdate.TrustedKey{{PublicKey: oldPub}})
+	rotation, _ := selfupdate.SignKeyring(2, []selfupdate.TrustedKey{{PublicKey: newPub}}, oldKey)
+	signed := func(content string, key ed25519.PrivateKey) []selfupdatetest.Asset {
+		sum := sha256.Sum256([]byte(content))
+		return []selfupdatetest.Asset{
+			{Name: "app-bin", Content: []byte(content)},
+			{Name: "app-bin.sig", Content: ed25519.Sign(key, sum[:])},
+		}
+	}
+	srv := selfupdatetest.NewServer("owner", "app",
+		selfupdatetest.Release{Tag: "v1.1.0", Assets: append(signed("v1.1", newKey), selfupdatetest.Asset{Name: "keyring.json", Content: rotation})},
+	)
+	defer srv.Close()
+	u := newUpdater(t, srv, "v1.0.0")
+	pinned := filepath.Join(t.TempDir(), "keyring.json")
+	keyring, err := selfupdate.NewKeyring(embedded, pinned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.SignatureSuffix, u.Keyring = ".sig", keyring
+
+	// Signed by the key it introduces, with the list signed by the old key.
+	if _, err := u.Update(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(u.Path); string(b) != "v1.1" {
+		t.Errorf("unexpected content %q", b)
+	}
+	if keyring.Version() != 2 {
+		t.Errorf("expected keyring version 2, got %d", keyring.Version())
+	}
+
+	// The old key is retired, even when an old key list is served again.
+	u.Build.Version = "v1.1.0"
+	srv.AddRelease(selfupdatetest.Release{Tag: "v1.2.0", Assets: append(signed("v1.2", oldKey), selfupdatetest.Asset{Name: "keyring.json", Content: embedded})})
+	if _, err := u.Update(context.Background()); !errors.Is(err, selfupdate.ErrSignatureInvalid) {
+		t.Fatal("expected ErrSignatureInvalid, got", err)
+	}
+	if k, err := selfupdate.NewKeyring(embedded, pinned); err != nil || k.Version() != 2 {
+		t.Errorf("pinned keyring not kept: %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package selfupdate
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_Keyring(t *testing.T) {
+	now := time.Now()
+	oldPub, oldKey, _ := ed25519.GenerateKey(nil)
+	newPub, newKey, _ := ed25519.GenerateKey(nil)
+	_, rogueKey, _ := ed25519.GenerateKey(nil)
+	sign := func(version int64, keys []TrustedKey, signers ...ed25519.PrivateKey) []byte {
+		t.Helper()
+		data, err := SignKeyring(version, keys, signers...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	embedded := sign(1, []TrustedKey{{PublicKey: oldPub}})
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	k, err := NewKeyring(embedded, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify := func(name string, data []byte, wantRotated bool, wantErr error, wantVersion int64) {
+		t.Helper()
+		rotated, err := k.Rotate(data, now)
+		if rotated != wantRotated || (wantErr == nil) != (err == nil) || wantErr != nil && !errors.Is(err, wantErr) {
+			t.Errorf("%s: unexpected result %v, %v", name, rotated, err)
+		}
+		if v := k.Version(); v != wantVersion {
+			t.Errorf("%s: expected version %d, got %d", name, wantVersion, v)
+		}
+	}
+
+	v2 := sign(2, []TrustedKey{{PublicKey: oldPub, NotAfter: now.Add(time.Hour)}, {PublicKey: newPub}}, oldKey)
+	verify("unsigned", sign(2, []TrustedKey{{PublicKey: newPub}}), false, ErrSignatureInvalid, 1)
+	verify("rogue", sign(2, []TrustedKey{{PublicKey: newPub}}, rogueKey), false, ErrSignatureInvalid, 1)
+	verify("rotate", v2, true, nil, 2)
+	verify("same", v2, false, nil, 2)
+	verify("equivocation", sign(2, []TrustedKey{{PublicKey: oldPub}}, oldKey), false, ErrKeyringRollback, 2)
+	verify("downgrade", embedded, false, nil, 2)
+	if b, err := os.ReadFile(path); err != nil || string(b) != string(v2) {
+		t.Errorf("keyring not pinned: %v", err)
+	}
+
+	// The pinned list wins over an older embedded one after a restart.
+	k, err = NewKeyring(embedded, path)
+	if err != nil || k.Version() != 2 || len(k.Keys()) != 2 {
+		t.Fatalf("pinned keyring not loaded: %v", err)
+	}
+	// An expired key can neither sign key lists nor releases.
+	retire := sign(3, []TrustedKey{{PublicKey: oldPub}}, oldKey)
+	if _, err := k.Rotate(retire, now.Add(2*time.Hour)); !errors.Is(err, ErrSignatureInvalid) {
+		t.Error("expected ErrSignatureInvalid for a list signed by an expired key, got", err)
+	}
+	sum := sha256.Sum256([]byte("artifact"))
+	for _, tc := range []struct {
+		key  ed25519.PrivateKey
+		want error
+	}{{newKey, nil}, {oldKey, nil}, {rogueKey, ErrSignatureInvalid}} {
+		a := Artifact{Name: "app", SHA256: sum[:], Signature: ed25519.Sign(tc.key, sum[:])}
+		if err := k.Verifier().Verify(context.Background(), a); !errors.Is(err, tc.want) {
+			t.Errorf("expected %v, got %v", tc.want, err)
+		}
+	}
+
+	if _, err := NewKeyring([]byte(`{"payload": "e30="}`), ""); err == nil {
+		t.Error("expected error for a keyring without keys")
+	}
+}
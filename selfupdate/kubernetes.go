@@ -0,0 +1,152 @@
+package selfupdate
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// Rollout replaces installing over the executable when something else
+// owns it, such as a container image. Update calls it with the selected
+// release instead of downloading, and reports DecisionRolloutRequested.
+type Rollout interface {
+	Rollout(ctx context.Context, info *UpdateInfo) error
+}
+
+// ReleaseAnnotation is set on the pod template by KubernetesRollout to
+// the tag of the requested release.
+const ReleaseAnnotation = "updater.msmania.github.io/release"
+
+// serviceAccountDir holds the in-cluster credentials of a pod.
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// KubernetesRollout rolls out a new release of the Deployment running
+// this pod by annotating its pod template with ReleaseAnnotation and,
+// with ImageTemplate, pointing Container at the release's image. Patching
+// the same release twice is a no-op, so repeated checks do not restart
+// the pods again. The service account needs "patch" on deployments.
+type KubernetesRollout struct {
+	APIURL     string
+	Namespace  string
+	Deployment string
+	// Container is the container whose image ImageTemplate sets.
+	Container string
+	// ImageTemplate is a text/template over .Tag and .Version, e.g.
+	// "ghcr.io/msmania/updater:{{.Tag}}". If empty, only the annotation
+	// changes, which restarts the pods with their current image
+	// reference (useful with mutable tags and imagePullPolicy Always).
+	ImageTemplate string
+	// TokenFile is re-read for every request since projected service
+	// account tokens rotate.
+	TokenFile string
+	// Client defaults to that of the calling Updater; see Updater.Client.
+	// InClusterRollout sets one trusting the cluster CA.
+	Client *http.Client
+}
+
+// InClusterRollout returns a KubernetesRollout for deployment using the
+// pod's service account. An empty deployment is derived from the pod
+// name, which a Deployment generates as "<deployment>-<hash>-<suffix>".
+func InClusterRollout(deployment string) (*KubernetesRollout, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running in a Kubernetes pod")
+	}
+	ns, err := os.ReadFile(serviceAccountDir + "/namespace")
+	if err != nil {
+		return nil, err
+	}
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no certificates in %s/ca.crt", serviceAccountDir)
+	}
+	if deployment == "" {
+		if deployment = deploymentOfPod(os.Getenv("HOSTNAME")); deployment == "" {
+			return nil, fmt.Errorf("cannot derive the deployment from pod name %q", os.Getenv("HOSTNAME"))
+		}
+	}
+	transport := NewTransport(TransportConfig{})
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	return &KubernetesRollout{
+		APIURL:     "https://" + net.JoinHostPort(host, port),
+		Namespace:  strings.TrimSpace(string(ns)),
+		Deployment: deployment,
+		TokenFile:  serviceAccountDir + "/token",
+		Client:     &http.Client{Transport: transport},
+	}, nil
+}
+
+// deploymentOfPod strips the ReplicaSet hash and pod suffix from a pod
+// name, or returns "" if it does not have that shape.
+func deploymentOfPod(pod string) string {
+	parts := strings.Split(pod, "-")
+	if len(parts) < 3 {
+		return ""
+	}
+	return strings.Join(parts[:len(parts)-2], "-")
+}
+
+func (k *KubernetesRollout) Rollout(ctx context.Context, info *UpdateInfo) error {
+	podTemplate := map[string]any{
+		"metadata": map[string]any{
+			"annotations": map[string]string{ReleaseAnnotation: info.Remote},
+		},
+	}
+	if k.ImageTemplate != "" {
+		if k.Container == "" {
+			return fmt.Errorf("an image template requires a container name")
+		}
+		image, err := k.image(info.Remote)
+		if err != nil {
+			return err
+		}
+		podTemplate["spec"] = map[string]any{
+			"containers": []map[string]string{{"name": k.Container, "image": image}},
+		}
+	}
+	patch, err := json.Marshal(map[string]any{"spec": map[string]any{"template": podTemplate}})
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("%s/apis/apps/v1/namespaces/%s/deployments/%s", k.APIURL, k.Namespace, k.Deployment)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, url, bytes.NewReader(patch))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/strategic-merge-patch+json")
+	if k.TokenFile != "" {
+		token, err := os.ReadFile(k.TokenFile)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	resp, err := clientFor(ctx, k.Client).Do(req)
+	if err != nil {
+		return err
+	}
+	defer drainClose(resp.Body)
+	return checkResponse(resp)
+}
+
+func (k *KubernetesRollout) image(tag string) (string, error) {
+	tmpl, err := template.New("image").Option("missingkey=error").Parse(k.ImageTemplate)
+	if err != nil {
+		return "", fmt.Errorf("invalid image template: %w", err)
+	}
+	var b strings.Builder
+	err = tmpl.Execute(&b, struct{ Tag, Version string }{tag, strings.TrimPrefix(tag, "v")})
+	return b.String(), err
+}
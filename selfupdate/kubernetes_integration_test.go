@@ -0,0 +1,48 @@
+package selfupdate_test
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/msmania/updater/selfupdate"
+	"github.com/msmania/updater/selfupdate/selfupdatetest"
+)
+
+type recordRollout struct{ tags []string }
+
+func (r *recordRollout) Rollout(ctx context.Context, info *selfupdate.UpdateInfo) error {
+	r.tags = append(r.tags, info.Remote)
+	return nil
+}
+
+func Test_Updater_rollout(t *testing.T) {
+	skipIfDisabled(t)
+	srv := selfupdatetest.NewServer("owner", "app",
+		selfupdatetest.Release{Tag: "v1.1.0", Assets: []selfupdatetest.Asset{{Name: "app-bin", Content: []byte("v1.1")}}},
+	)
+	defer srv.Close()
+	u := newUpdater(t, srv, "v1.0.0")
+	rollout := &recordRollout{}
+	u.Rollout = rollout
+
+	info, err := u.Update(context.Background())
+	if err != nil || info.Decision != selfupdate.DecisionRolloutRequested {
+		t.Fatalf("unexpected result %+v: %v", info, err)
+	}
+	if len(rollout.tags) != 1 || rollout.tags[0] != "v1.1.0" {
+		t.Error("rollout not requested", rollout.tags)
+	}
+	if b, _ := os.ReadFile(u.Path); string(b) != "old" {
+		t.Error("binary must not be replaced in rollout mode")
+	}
+	if st := u.Status(); st.Result != selfupdate.ResultRolloutRequested {
+		t.Error("unexpected status", st.Result)
+	}
+	for _, r := range srv.Requests() {
+		if strings.Contains(r, "/download/") {
+			t.Error("asset downloaded in rollout mode: " + r)
+		}
+	}
+}
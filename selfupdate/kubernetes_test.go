@@ -0,0 +1,68 @@
+package selfupdate
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_deploymentOfPod(t *testing.T) {
+	verify := func(pod, want string) {
+		if got := deploymentOfPod(pod); got != want {
+			t.Errorf("%s: got %q, want %q", pod, got, want)
+		}
+	}
+	verify("updater-7d4b9c8f6-x2v9q", "updater")
+	verify("my-app-5f6d7c-abcde", "my-app")
+	verify("standalone", "")
+	verify("", "")
+}
+
+func Test_KubernetesRollout(t *testing.T) {
+	var got struct {
+		method, path, contentType, auth string
+		patch                           map[string]any
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.method, got.path = r.Method, r.URL.Path
+		got.contentType, got.auth = r.Header.Get("Content-Type"), r.Header.Get("Authorization")
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &got.patch)
+		w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+	token := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(token, []byte("secret\n"), 0o600)
+
+	k := &KubernetesRollout{
+		APIURL:        srv.URL,
+		Namespace:     "prod",
+		Deployment:    "updater",
+		Container:     "app",
+		ImageTemplate: "ghcr.io/msmania/updater:{{.Version}}",
+		TokenFile:     token,
+	}
+	if err := k.Rollout(context.Background(), &UpdateInfo{Remote: "v1.2.0"}); err != nil {
+		t.Fatal(err)
+	}
+	if got.method != "PATCH" || got.path != "/apis/apps/v1/namespaces/prod/deployments/updater" ||
+		got.contentType != "application/strategic-merge-patch+json" || got.auth != "Bearer secret" {
+		t.Errorf("unexpected request %+v", got)
+	}
+	b, _ := json.Marshal(got.patch)
+	want := `{"spec":{"template":{"metadata":{"annotations":{"updater.msmania.github.io/release":"v1.2.0"}},` +
+		`"spec":{"containers":[{"image":"ghcr.io/msmania/updater:1.2.0","name":"app"}]}}}}`
+	if string(b) != want {
+		t.Error("unexpected patch " + string(b))
+	}
+
+	k.Container = ""
+	if err := k.Rollout(context.Background(), &UpdateInfo{Remote: "v1.2.0"}); err == nil {
+		t.Error("image template without container must fail")
+	}
+}
@@ -0,0 +1,105 @@
+package selfupdate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Roles reported in Status.Role when Updater.LeaderElection is set.
+const (
+	RoleLeader   = "leader"
+	RoleObserver = "observer"
+)
+
+// leadership is the lock held by the leading Updater of a target.
+type leadership struct {
+	mu   sync.Mutex
+	file *os.File // open and locked while leading
+}
+
+// lockPath is the lock file guarding the target at exePath.
+func lockPath(exePath string) string {
+	return exePath + ".lock"
+}
+
+// TryLock takes an exclusive lock on the file at path, creating it, for
+// work that must not run twice at once, such as a cron job. It fails with
+// ErrLocked while another process holds the lock. The lock lasts until
+// release is called or the process exits.
+func TryLock(path string) (release func(), err error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		if errors.Is(err, ErrLocked) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("cannot lock %s: %w", path, err)
+	}
+	return func() { f.Close() }, nil
+}
+
+// lead makes u the leader for its target if no other process is, and
+// reports whether it leads. Leadership lasts until the process exits,
+// which releases the lock, so an observer takes over from a leader that
+// died on its next attempt.
+func (u *Updater) lead() (bool, error) {
+	l := &u.leader
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file != nil {
+		return true, nil
+	}
+	exePath, err := u.path()
+	if err != nil {
+		return false, err
+	}
+	f, err := os.OpenFile(lockPath(exePath), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return false, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		if errors.Is(err, ErrLocked) {
+			return false, nil
+		}
+		return false, fmt.Errorf("cannot lock %s: %w", f.Name(), err)
+	}
+	// Record who leads for the observers' status.
+	f.Truncate(0)
+	f.WriteAt([]byte(instanceID()+"\n"), 0)
+	l.file = f
+	u.logf("Leading updates of %s as %s", exePath, instanceID())
+	return true, nil
+}
+
+// leading reports whether u holds the lock of its target.
+func (u *Updater) leading() bool {
+	u.leader.mu.Lock()
+	defer u.leader.mu.Unlock()
+	return u.leader.file != nil
+}
+
+// leaderID returns the instance recorded in the lock file of u's target.
+func (u *Updater) leaderID() string {
+	exePath, err := u.path()
+	if err != nil {
+		return ""
+	}
+	data, err := os.ReadFile(lockPath(exePath))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// instanceID identifies this process as "pid@host".
+func instanceID() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%d@%s", os.Getpid(), host)
+}
@@ -0,0 +1,56 @@
+package selfupdate
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_LeaderElection(t *testing.T) {
+	skipIfDisabled(t)
+	path := filepath.Join(t.TempDir(), "app")
+	a := &Updater{Path: path, LeaderElection: true}
+	b := &Updater{Path: path, LeaderElection: true, APIURL: "http://127.0.0.1:0"}
+
+	if leading, err := a.lead(); !leading || err != nil {
+		t.Fatal("first instance must lead", err)
+	}
+	if leading, err := b.lead(); leading || err != nil {
+		t.Fatal("second instance must observe", err)
+	}
+	info, err := b.Update(context.Background())
+	if !errors.Is(err, ErrNotLeader) || info.Decision != DecisionObserving {
+		t.Error("expected ErrNotLeader, got", info.Decision, err)
+	}
+	if st := a.Status(); st.Role != RoleLeader || !strings.Contains(st.Leader, "@") {
+		t.Errorf("unexpected leader status %+v", st)
+	}
+	if st := b.Status(); st.Role != RoleObserver || st.Result != ResultObserving || st.Leader != a.Status().Leader {
+		t.Errorf("unexpected observer status %+v", st)
+	}
+
+	// The lock is released when the leader's process exits.
+	a.leader.file.Close()
+	if leading, err := b.lead(); !leading || err != nil {
+		t.Error("observer must take over", err)
+	}
+}
+
+func Test_TryLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cron.lock")
+	release, err := TryLock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := TryLock(path); !errors.Is(err, ErrLocked) {
+		t.Errorf("second lock: %v, want ErrLocked", err)
+	}
+	release()
+	release, err = TryLock(path)
+	if err != nil {
+		t.Fatal("lock not released:", err)
+	}
+	release()
+}
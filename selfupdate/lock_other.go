@@ -0,0 +1,12 @@
+//go:build !unix && !windows
+
+package selfupdate
+
+import (
+	"errors"
+	"os"
+)
+
+func lockFile(f *os.File) error {
+	return errors.New("file locking is not supported on this platform")
+}
@@ -0,0 +1,111 @@
+package selfupdate
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+)
+
+// DefaultManifestSignatureSuffix is the default ManifestSigners.Suffix.
+const DefaultManifestSignatureSuffix = ".sigs"
+
+// ManifestSigners requires the checksum manifest, Updater.ChecksumAsset,
+// to be signed by Threshold of Keys before any digest it lists is
+// trusted, so a single compromised signer, or a mirror serving a forged
+// manifest, cannot push an update. The signatures are Ed25519 signatures
+// of the manifest bytes, one per line (hex or base64), in the release
+// asset named after the manifest plus Suffix. Each key counts once.
+type ManifestSigners struct {
+	// Keys are the n signers. None disables the check.
+	Keys []ed25519.PublicKey
+	// Threshold is k, the number of Keys that must have signed. Zero
+	// requires all of them.
+	Threshold int
+	// Suffix defaults to DefaultManifestSignatureSuffix.
+	Suffix string
+}
+
+func (m ManifestSigners) suffix() string {
+	if m.Suffix == "" {
+		return DefaultManifestSignatureSuffix
+	}
+	return m.Suffix
+}
+
+func (m ManifestSigners) threshold() int {
+	if m.Threshold <= 0 {
+		return len(m.Keys)
+	}
+	return m.Threshold
+}
+
+// Verify checks that sigs, one signature per line, include signatures of
+// manifest by at least the threshold of distinct keys.
+func (m ManifestSigners) Verify(manifest, sigs []byte) error {
+	need := m.threshold()
+	if need > len(m.Keys) {
+		return fmt.Errorf("threshold %d exceeds the %d manifest keys", need, len(m.Keys))
+	}
+	signed := make([]bool, len(m.Keys))
+	count := 0
+	sc := bufio.NewScanner(bytes.NewReader(sigs))
+	for sc.Scan() {
+		sig, err := decodeSignature(sc.Bytes())
+		if err != nil {
+			continue
+		}
+		for i, key := range m.Keys {
+			if !signed[i] && ed25519.Verify(key, manifest, sig) {
+				signed[i] = true
+				count++
+				break
+			}
+		}
+	}
+	if count < need {
+		return fmt.Errorf("%w: manifest signed by %d of the %d required keys", ErrSignatureInvalid, count, need)
+	}
+	return nil
+}
+
+// SignManifest returns the signatures of manifest by signers in the
+// format Verify reads, one base64 signature per line. Signers signing in
+// turn append their lines to the same file.
+func SignManifest(manifest []byte, signers ...ed25519.PrivateKey) []byte {
+	var b bytes.Buffer
+	for _, s := range signers {
+		b.WriteString(base64.StdEncoding.EncodeToString(ed25519.Sign(s, manifest)))
+		b.WriteByte('\n')
+	}
+	return b.Bytes()
+}
+
+// fetchManifest downloads the checksum manifest and, with ManifestSigners,
+// checks its signatures before parsing it.
+func (u *Updater) fetchManifest(ctx context.Context, rel *ghRelease) (map[string]Digest, error) {
+	sumAsset, err := rel.findAsset(u.ChecksumAsset)
+	if err != nil {
+		return nil, err
+	}
+	data, err := u.http().fetchSmall(ctx, sumAsset.BrowserDownloadURL, maxChecksumFileSize)
+	if err != nil {
+		return nil, err
+	}
+	if m := u.ManifestSigners; len(m.Keys) > 0 {
+		sigAsset, err := rel.findAsset(u.ChecksumAsset + m.suffix())
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrSignatureInvalid, err)
+		}
+		sigs, err := u.http().fetchSmall(ctx, sigAsset.BrowserDownloadURL, maxSignatureSize)
+		if err != nil {
+			return nil, err
+		}
+		if err := m.Verify(data, sigs); err != nil {
+			return nil, fmt.Errorf("%s: %w", u.ChecksumAsset, err)
+		}
+	}
+	return parseChecksums(data), nil
+}
@@ -0,0 +1,50 @@
+package selfupdate_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/msmania/updater/selfupdate"
+	"github.com/msmania/updater/selfupdate/selfupdatetest"
+)
+
+func Test_Updater_manifestSigners(t *testing.T) {
+	skipIfDisabled(t)
+	var keys []ed25519.PublicKey
+	var privs []ed25519.PrivateKey
+	for range 3 {
+		pub, priv, _ := ed25519.GenerateKey(nil)
+		keys, privs = append(keys, pub), append(privs, priv)
+	}
+	release := func(tag, content string, signers ...ed25519.PrivateKey) selfupdatetest.Release {
+		assets := []selfupdatetest.Asset{{Name: "app-bin", Content: []byte(content)}}
+		manifest := selfupdatetest.ChecksumFile(assets)
+		var sigs bytes.Buffer
+		for _, s := range signers {
+			fmt.Fprintf(&sigs, "%x\n", ed25519.Sign(s, manifest))
+		}
+		return selfupdatetest.Release{Tag: tag, Assets: append(assets,
+			selfupdatetest.Asset{Name: "checksums.txt", Content: manifest},
+			selfupdatetest.Asset{Name: "checksums.txt.sigs", Content: sigs.Bytes()})}
+	}
+	srv := selfupdatetest.NewServer("owner", "app", release("v1.1.0", "v1.1", privs[1]))
+	defer srv.Close()
+	u := newUpdater(t, srv, "v1.0.0")
+	u.ChecksumAsset = "checksums.txt"
+	u.ManifestSigners = selfupdate.ManifestSigners{Keys: keys, Threshold: 2}
+	if _, err := u.Update(context.Background()); !errors.Is(err, selfupdate.ErrSignatureInvalid) {
+		t.Fatal("expected ErrSignatureInvalid with one signature, got", err)
+	}
+	srv.AddRelease(release("v1.2.0", "v1.2", privs[0], privs[2]))
+	if _, err := u.Update(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(u.Path); string(b) != "v1.2" {
+		t.Errorf("unexpected content %q", b)
+	}
+}
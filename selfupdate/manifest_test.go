@@ -0,0 +1,52 @@
+package selfupdate
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func Test_ManifestSigners(t *testing.T) {
+	manifest := []byte("0123  app\n")
+	var keys []ed25519.PublicKey
+	var privs []ed25519.PrivateKey
+	for range 3 {
+		pub, priv, _ := ed25519.GenerateKey(nil)
+		keys, privs = append(keys, pub), append(privs, priv)
+	}
+	_, rogue, _ := ed25519.GenerateKey(nil)
+	sigs := func(signers ...ed25519.PrivateKey) []byte {
+		var lines []string
+		for i, s := range signers {
+			sig := ed25519.Sign(s, manifest)
+			if i%2 == 0 {
+				lines = append(lines, hex.EncodeToString(sig))
+			} else {
+				lines = append(lines, base64.StdEncoding.EncodeToString(sig))
+			}
+		}
+		return []byte(strings.Join(append(lines, "garbage"), "\n"))
+	}
+	verify := func(m ManifestSigners, sigs []byte, ok bool) {
+		t.Helper()
+		err := m.Verify(manifest, sigs)
+		if ok && err != nil || !ok && !errors.Is(err, ErrSignatureInvalid) {
+			t.Errorf("threshold %d: unexpected result %v", m.Threshold, err)
+		}
+	}
+	twoOfThree := ManifestSigners{Keys: keys, Threshold: 2}
+	verify(twoOfThree, sigs(privs[0], privs[2]), true)
+	verify(twoOfThree, sigs(privs[1]), false)
+	verify(twoOfThree, sigs(privs[1], privs[1]), false)
+	verify(twoOfThree, sigs(privs[1], rogue), false)
+	verify(ManifestSigners{Keys: keys}, sigs(privs[0], privs[1]), false)
+	verify(ManifestSigners{Keys: keys}, sigs(privs...), true)
+	verify(twoOfThree, append(SignManifest(manifest, privs[2]), SignManifest(manifest, privs[0])...), true)
+	verify(twoOfThree, SignManifest([]byte("other"), privs[0], privs[1]), false)
+	if err := (ManifestSigners{Keys: keys, Threshold: 4}).Verify(manifest, sigs(privs...)); err == nil {
+		t.Error("expected error for a threshold above the number of keys")
+	}
+}
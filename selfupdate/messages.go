@@ -0,0 +1,134 @@
+package selfupdate
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Names of the messages of a MessageCatalog. The library logs the first
+// ones; the rest are for the notices and hints of programs built on it.
+const (
+	// MsgDeferred is logged when a release waits for the update policy.
+	MsgDeferred = "deferred"
+	// MsgDownloading is logged when an upgrade starts downloading.
+	MsgDownloading = "downloading"
+	// MsgRollout is logged when an upgrade is handed to Updater.Rollout.
+	MsgRollout = "rollout"
+	// MsgUpgraded is logged once an upgrade is installed.
+	MsgUpgraded = "upgraded"
+	// MsgRolledBack is logged after a rollback to an earlier version.
+	MsgRolledBack = "rolled-back"
+	// MsgReleaseNotice tells a user about a newer release.
+	MsgReleaseNotice = "release-notice"
+	// MsgMajorBlocked explains a release held back by its major version.
+	MsgMajorBlocked = "major-blocked"
+	// MsgPackageManaged explains why a packaged executable is left alone.
+	MsgPackageManaged = "package-managed"
+	// MsgSoaking tells when a release passes Updater.MinReleaseAge.
+	MsgSoaking = "soaking"
+)
+
+// DefaultMessages are the English texts of a MessageCatalog.
+var DefaultMessages = map[string]string{
+	MsgDeferred:       "{{.Release}} is available (current={{.Current}}); {{.Error}}",
+	MsgDownloading:    "New version {{.Release}} available (current={{.Current}}). Downloading…",
+	MsgRollout:        "New version {{.Release}} available (current={{.Current}}). Requesting rollout…",
+	MsgUpgraded:       "Upgrade to {{.Release}} succeeded – exiting for systemd restart.",
+	MsgRolledBack:     "Rolled back from {{.Current}} to {{.Release}}",
+	MsgReleaseNotice:  "updater {{.Release}} is available (current {{.Current}}); run 'updater update' to install it.",
+	MsgMajorBlocked:   "Use -allow-major-upgrade to install it.",
+	MsgPackageManaged: "{{.Error}}\nUpgrade it with the package manager (or -upgrade-with-package-manager), or use -allow-packaged to replace it anyway.",
+	MsgSoaking:        `It soaks until {{.Until.Local.Format "2006-01-02T15:04:05Z07:00"}} (-min-release-age).`,
+}
+
+// MessageData holds the fields available to message templates.
+type MessageData struct {
+	// Current is the running version and Release the one the message
+	// is about.
+	Current, Release string
+	Channel          Channel
+	// Host is the host name, filled in by Render.
+	Host     string
+	Decision Decision
+	// Error is the text of the error behind the message, if any.
+	Error string
+	// Duration is how long the operation took, and Until when a wait
+	// ends, where they apply.
+	Duration time.Duration
+	Until    time.Time
+}
+
+// MessageCatalog renders the log messages and notices of an Updater
+// from text/template texts, so operators can reword or translate them.
+// The nil catalog renders DefaultMessages.
+type MessageCatalog struct {
+	templates map[string]*template.Template
+}
+
+// NewMessageCatalog parses texts, keyed by the Msg names, over
+// DefaultMessages. Unknown names are rejected, so that typos do not go
+// unnoticed.
+func NewMessageCatalog(texts map[string]string) (*MessageCatalog, error) {
+	c := &MessageCatalog{templates: make(map[string]*template.Template, len(DefaultMessages))}
+	for name, text := range DefaultMessages {
+		c.templates[name] = template.Must(template.New(name).Parse(text))
+	}
+	names := make([]string, 0, len(texts))
+	for name := range texts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := DefaultMessages[name]; !ok {
+			return nil, fmt.Errorf("unknown message %q", name)
+		}
+		t, err := template.New(name).Option("missingkey=error").Parse(texts[name])
+		if err != nil {
+			return nil, err
+		}
+		c.templates[name] = t
+	}
+	return c, nil
+}
+
+// defaultCatalog renders DefaultMessages for the nil catalog.
+var defaultCatalog, _ = NewMessageCatalog(nil)
+
+// Render returns the message name for data, with Host filled in. A
+// template that fails to execute falls back to the default text.
+func (c *MessageCatalog) Render(name string, data MessageData) string {
+	if data.Host == "" {
+		data.Host, _ = os.Hostname()
+	}
+	if c == nil {
+		c = defaultCatalog
+	}
+	t := c.templates[name]
+	if t == nil {
+		return name
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		if c == defaultCatalog {
+			return name
+		}
+		return defaultCatalog.Render(name, data)
+	}
+	return b.String()
+}
+
+// message renders name with u.Messages, adding the running version and
+// channel.
+func (u *Updater) message(name string, data MessageData) string {
+	if data.Current == "" {
+		data.Current = u.Build.Version
+	}
+	if data.Channel == "" {
+		data.Channel = u.channel()
+	}
+	return u.Messages.Render(name, data)
+}
@@ -0,0 +1,49 @@
+package selfupdate
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_MessageCatalog(t *testing.T) {
+	data := MessageData{Current: "v1.0.0", Release: "v1.1.0"}
+	var defaults *MessageCatalog
+	if got := defaults.Render(MsgDownloading, data); got != "New version v1.1.0 available (current=v1.0.0). Downloading…" {
+		t.Errorf("unexpected default text %q", got)
+	}
+	if got := defaults.Render("no-such-message", data); got != "no-such-message" {
+		t.Errorf("unexpected text for an unknown message %q", got)
+	}
+
+	c, err := NewMessageCatalog(map[string]string{
+		MsgReleaseNotice: "{{.Host}}: {{.Release}} ist verfügbar (installiert: {{.Current}})",
+		MsgSoaking:       `bis {{.Until.UTC.Format "02.01.2006 15:04"}}`,
+		MsgUpgraded:      "{{.Nope}}",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, _ := os.Hostname()
+	if got := c.Render(MsgReleaseNotice, data); got != host+": v1.1.0 ist verfügbar (installiert: v1.0.0)" {
+		t.Errorf("unexpected custom text %q", got)
+	}
+	data.Until = time.Date(2026, 10, 15, 8, 30, 0, 0, time.UTC)
+	if got := c.Render(MsgSoaking, data); got != "bis 15.10.2026 08:30" {
+		t.Errorf("unexpected custom text %q", got)
+	}
+	if got := c.Render(MsgUpgraded, data); got != "Upgrade to v1.1.0 succeeded – exiting for systemd restart." {
+		t.Errorf("a failing template should fall back to the default, got %q", got)
+	}
+	if got := c.Render(MsgMajorBlocked, data); got != DefaultMessages[MsgMajorBlocked] {
+		t.Errorf("messages not overridden should keep the default, got %q", got)
+	}
+
+	if _, err := NewMessageCatalog(map[string]string{"relase-notice": "x"}); err == nil || !strings.Contains(err.Error(), "relase-notice") {
+		t.Errorf("expected an unknown message error, got %v", err)
+	}
+	if _, err := NewMessageCatalog(map[string]string{MsgUpgraded: "{{.Release"}); err == nil {
+		t.Error("expected a template parse error")
+	}
+}
@@ -0,0 +1,184 @@
+package selfupdate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GitHubMirror may appear in Updater.Mirrors to place the asset's GitHub
+// download URL in the failover order. It is tried last if omitted.
+const GitHubMirror = "github"
+
+// mirrorsFile is the name of the persisted mirror health below StateDir.
+const mirrorsFile = "mirrors.json"
+
+// mirrorHealth is what is remembered about a mirror between updates.
+type mirrorHealth struct {
+	Successes           int       `json:"successes"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	BytesPerSecond      float64   `json:"bytes_per_second,omitempty"`
+	LastError           string    `json:"last_error,omitempty"`
+	LastUsed            time.Time `json:"last_used,omitzero"`
+}
+
+type mirrorSet struct {
+	mu     sync.Mutex
+	loaded bool
+	health map[string]*mirrorHealth
+}
+
+type mirrorCandidate struct {
+	key string // entry of Updater.Mirrors
+	url string
+}
+
+// mirrorURL returns the URL of the named asset of tag below base, laid out
+// like GitHub's download paths: "<base>/<tag>/<name>".
+func mirrorURL(base, tag, name string) string {
+	return strings.TrimSuffix(base, "/") + "/" + tag + "/" + name
+}
+
+// orderedMirrors returns the download candidates for asset: mirrors that
+// failed on their last attempt go last, and the others are ordered by
+// measured throughput, fastest first, then by configuration order.
+func (u *Updater) orderedMirrors(tag string, asset *ghAsset) []mirrorCandidate {
+	keys := append([]string(nil), u.Mirrors...)
+	hasGitHub := false
+	for _, k := range keys {
+		hasGitHub = hasGitHub || k == GitHubMirror
+	}
+	if !hasGitHub {
+		keys = append(keys, GitHubMirror)
+	}
+
+	m := &u.mirrors
+	m.mu.Lock()
+	u.loadMirrorHealth()
+	health := func(k string) mirrorHealth {
+		if h := m.health[k]; h != nil {
+			return *h
+		}
+		return mirrorHealth{}
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		hi, hj := health(keys[i]), health(keys[j])
+		if (hi.ConsecutiveFailures > 0) != (hj.ConsecutiveFailures > 0) {
+			return hj.ConsecutiveFailures > 0
+		}
+		return hi.BytesPerSecond > hj.BytesPerSecond
+	})
+	m.mu.Unlock()
+
+	out := make([]mirrorCandidate, len(keys))
+	for i, k := range keys {
+		url := asset.BrowserDownloadURL
+		switch k {
+		case GitHubMirror:
+		case TorrentMirror:
+			url += TorrentSuffix
+		default:
+			url = mirrorURL(k, tag, asset.Name)
+		}
+		out[i] = mirrorCandidate{key: k, url: url}
+	}
+	return out
+}
+
+// loadMirrorHealth reads persisted health once. u.mirrors.mu must be held.
+func (u *Updater) loadMirrorHealth() {
+	m := &u.mirrors
+	if m.loaded {
+		return
+	}
+	m.loaded = true
+	m.health = map[string]*mirrorHealth{}
+	if u.StateDir == "" {
+		return
+	}
+	if err := u.readState(mirrorsFile, &m.health); err != nil {
+		u.logf("cannot read mirror health: %v", err)
+	}
+}
+
+// recordMirror updates the health of mirror key after an attempt.
+func (u *Updater) recordMirror(key string, size int64, elapsed time.Duration, err error) {
+	m := &u.mirrors
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u.loadMirrorHealth()
+	h := m.health[key]
+	if h == nil {
+		h = &mirrorHealth{}
+		m.health[key] = h
+	}
+	h.LastUsed = time.Now().UTC()
+	if err != nil {
+		h.ConsecutiveFailures++
+		h.LastError = err.Error()
+	} else {
+		h.Successes++
+		h.ConsecutiveFailures = 0
+		h.LastError = ""
+		if elapsed > 0 {
+			h.BytesPerSecond = float64(size) / elapsed.Seconds()
+		}
+	}
+	if u.StateDir != "" {
+		if err := u.writeState(mirrorsFile, m.health); err != nil {
+			u.logf("cannot persist mirror health: %v", err)
+		}
+	}
+}
+
+// fetchAsset downloads asset to dst from the first mirror whose bytes pass
+// verification against want, recording the health of each mirror tried.
+// Assets buffered in memory are only written to dst once verified.
+func (u *Updater) fetchAsset(ctx context.Context, tag string, asset *ghAsset, dst string, want Digest,
+	dec Decompressor, info *UpdateInfo) (downloadResult, error) {
+	candidates := u.orderedMirrors(tag, asset)
+	var errs []error
+	for _, c := range candidates {
+		start := time.Now()
+		var res downloadResult
+		var err error
+		if c.key == TorrentMirror {
+			res, err = u.torrentDownload(ctx, asset, dst, want, dec)
+		} else {
+			res, err = u.download(ctx, c.url, dst, asset.Size, want, dec)
+		}
+		info.Durations.Download += time.Since(start)
+		info.BytesDownloaded += res.Size
+		info.Retries += res.Retries
+		if err != nil {
+			err = fmt.Errorf("download failed: %w", err)
+		} else {
+			stage := time.Now()
+			if err = u.verify(ctx, res, want); err != nil {
+				err = fmt.Errorf("verification failed: %w", err)
+			}
+			info.Durations.Verify += time.Since(stage)
+			if err == nil && res.data != nil {
+				err = os.WriteFile(dst, res.data, 0o755)
+				res.data = nil
+			}
+		}
+		u.recordMirror(c.key, res.Size, time.Since(start), err)
+		if err == nil {
+			info.Asset.URL = c.url
+			return res, nil
+		}
+		os.Remove(dst)
+		if len(candidates) == 1 || ctx.Err() != nil {
+			return res, err
+		}
+		u.logf("Mirror %s failed: %v", c.key, err)
+		errs = append(errs, fmt.Errorf("mirror %s: %w", c.key, err))
+	}
+	return downloadResult{}, errors.Join(errs...)
+}
@@ -0,0 +1,62 @@
+package selfupdate_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/msmania/updater/selfupdate"
+	"github.com/msmania/updater/selfupdate/selfupdatetest"
+)
+
+func Test_Updater_mirrors(t *testing.T) {
+	skipIfDisabled(t)
+	release := selfupdatetest.Release{Tag: "v1.1.0", Assets: []selfupdatetest.Asset{{Name: "app-bin", Content: []byte("v1.1")}}, Checksums: true}
+	srv := selfupdatetest.NewServer("owner", "app", release)
+	defer srv.Close()
+	good := selfupdatetest.NewServer("owner", "app", release)
+	defer good.Close()
+	stale := selfupdatetest.NewServer("owner", "app",
+		selfupdatetest.Release{Tag: "v1.1.0", Assets: []selfupdatetest.Asset{{Name: "app-bin", Content: []byte("v1.x")}}},
+	)
+	defer stale.Close()
+
+	u := newUpdater(t, srv, "v1.0.0")
+	u.ChecksumAsset = "checksums.txt"
+	u.StateDir = t.TempDir()
+	u.Mirrors = []string{stale.URL + "/download", good.URL + "/download/"}
+	ctx := context.Background()
+
+	info, err := u.Update(ctx)
+	if err != nil || info.Asset.URL != good.DownloadURL("v1.1.0", "app-bin") {
+		t.Fatalf("failover to working mirror failed %+v: %v", info.Asset, err)
+	}
+	if b, _ := os.ReadFile(u.Path); string(b) != "v1.1" {
+		t.Error("binary not replaced: " + string(b))
+	}
+
+	// A fresh updater reads the persisted health and skips the bad mirror.
+	next := newUpdater(t, srv, "v1.0.0")
+	next.ChecksumAsset, next.StateDir, next.Mirrors = u.ChecksumAsset, u.StateDir, u.Mirrors
+	n := len(stale.Requests())
+	if _, err := next.Update(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(stale.Requests()) != n {
+		t.Error("failed mirror should be tried last")
+	}
+
+	all := newUpdater(t, srv, "v1.0.0")
+	all.ChecksumAsset = u.ChecksumAsset
+	all.Mirrors = []string{stale.URL + "/download", selfupdate.GitHubMirror}
+	good.Close()
+	if _, err := all.Update(ctx); err != nil {
+		t.Error("GitHub fallback failed:", err)
+	}
+	all.Mirrors = []string{stale.URL + "/download"}
+	srv.FailNext("/download/v1.1.0/app-bin", 1, selfupdatetest.Failure{Status: 404})
+	if _, err := all.Update(ctx); !errors.Is(err, selfupdate.ErrChecksumMismatch) {
+		t.Error("expected joined ErrChecksumMismatch, got", err)
+	}
+}
@@ -0,0 +1,124 @@
+package selfupdate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MirrorSync reports what SyncMirror did for one release.
+type MirrorSync struct {
+	Tag string
+	// Fetched and Current list the assets downloaded and those already
+	// present.
+	Fetched, Current []string
+}
+
+// SyncMirror copies every asset of the releases tagged tags, or of the
+// newest release of u's channel if none is given, below dest in the
+// layout of Updater.Mirrors, "<dest>/<tag>/<asset>". Checksum files and
+// signatures are release assets too, so updaters using the mirror find
+// them as well. Files already present with the size and digest GitHub
+// reports are kept; others are downloaded under a temporary name, checked
+// against that size and digest, and renamed into place.
+func (u *Updater) SyncMirror(ctx context.Context, dest string, tags ...string) ([]MirrorSync, error) {
+	var rels []*ghRelease
+	if len(tags) == 0 {
+		rel, err := u.newestRelease(ctx)
+		if err != nil {
+			return nil, err
+		}
+		rels = append(rels, rel)
+	}
+	for _, tag := range tags {
+		rel, err := u.http().releaseByTag(ctx, u.apiURL(), u.Owner, u.Repo, tag)
+		if err != nil {
+			return nil, err
+		}
+		rels = append(rels, rel)
+	}
+	var out []MirrorSync
+	for _, rel := range rels {
+		sync, err := u.syncRelease(ctx, dest, rel)
+		out = append(out, sync)
+		if err != nil {
+			return out, fmt.Errorf("release %s: %w", rel.TagName, err)
+		}
+	}
+	return out, nil
+}
+
+// syncRelease copies the assets of rel below dest/<tag>.
+func (u *Updater) syncRelease(ctx context.Context, dest string, rel *ghRelease) (MirrorSync, error) {
+	sync := MirrorSync{Tag: rel.TagName}
+	if !mirrorSafeName(rel.TagName) {
+		return sync, fmt.Errorf("unsafe tag %q", rel.TagName)
+	}
+	dir := filepath.Join(dest, rel.TagName)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return sync, err
+	}
+	for i := range rel.Assets {
+		asset := &rel.Assets[i]
+		if !mirrorSafeName(asset.Name) {
+			return sync, fmt.Errorf("unsafe asset name %q", asset.Name)
+		}
+		var want Digest
+		if asset.Digest != "" {
+			d, err := parseDigest(asset.Digest)
+			if err != nil {
+				return sync, fmt.Errorf("asset %s: %w", asset.Name, err)
+			}
+			want = d
+		}
+		path := filepath.Join(dir, asset.Name)
+		if mirrorCurrent(path, asset.Size, want) {
+			sync.Current = append(sync.Current, asset.Name)
+			continue
+		}
+		tmp := path + ".part"
+		res, err := u.http().downloadFile(ctx, asset.BrowserDownloadURL, tmp,
+			downloadOptions{expectedSize: asset.Size, withSHA512: want.Algorithm == "sha512"})
+		if err == nil && want.Algorithm != "" {
+			err = res.verify(want)
+		}
+		if err == nil {
+			os.Chmod(tmp, 0o644)
+			err = os.Rename(tmp, path)
+		}
+		if err != nil {
+			os.Remove(tmp)
+			return sync, fmt.Errorf("asset %s: %w", asset.Name, err)
+		}
+		u.logf("Mirrored %s/%s (%d bytes)", rel.TagName, asset.Name, res.Size)
+		sync.Fetched = append(sync.Fetched, asset.Name)
+	}
+	return sync, nil
+}
+
+// mirrorSafeName reports whether a tag or asset name from the release
+// API is a plain file name.
+func mirrorSafeName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`) && filepath.IsLocal(name)
+}
+
+// mirrorCurrent reports whether the file at path has size, if known, and
+// the SHA-256 digest want, if known. Other digests are not checked, so
+// such files are fetched again.
+func mirrorCurrent(path string, size int64, want Digest) bool {
+	st, err := os.Stat(path)
+	if err != nil || !st.Mode().IsRegular() || size > 0 && st.Size() != size {
+		return false
+	}
+	switch want.Algorithm {
+	case "":
+		return size > 0
+	case "sha256":
+		sum, err := fileSHA256(path)
+		return err == nil && bytes.Equal(sum, want.Sum)
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package selfupdate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func Test_Updater_SyncMirror(t *testing.T) {
+	names := []string{"app_linux_amd64.tar.gz", "checksums.txt", "checksums.txt.sig"}
+	content := func(tag, name string) string { return tag + "/" + name }
+	var downloads atomic.Int32
+	var tampered atomic.Bool
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		release := func(tag string) {
+			var assets []string
+			for _, name := range names {
+				sum := sha256.Sum256([]byte(content(tag, name)))
+				assets = append(assets, fmt.Sprintf(
+					`{"name":%q,"size":%d,"digest":"sha256:%s","browser_download_url":"%s/download/%s/%s"}`,
+					name, len(content(tag, name)), hex.EncodeToString(sum[:]), srv.URL, tag, name))
+			}
+			fmt.Fprintf(w, `{"tag_name":%q,"assets":[%s]}`, tag, strings.Join(assets, ","))
+		}
+		switch path := r.URL.Path; {
+		case path == "/repos/o/r/releases/latest":
+			release("v1.2.0")
+		case path == "/repos/o/r/releases/tags/v1.1.0":
+			release("v1.1.0")
+		case path == "/repos/o/r/releases/tags/bad":
+			fmt.Fprint(w, `{"tag_name":"..","assets":[]}`)
+		case strings.HasPrefix(path, "/download/"):
+			downloads.Add(1)
+			body := strings.TrimPrefix(path, "/download/")
+			if tampered.Load() {
+				body = strings.ToUpper(body)
+			}
+			fmt.Fprint(w, body)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	u := &Updater{Owner: "o", Repo: "r", APIURL: srv.URL}
+	dest := t.TempDir()
+
+	verify := func(tags []string, wantTag string, wantFetched, wantCurrent int) {
+		t.Helper()
+		syncs, err := u.SyncMirror(context.Background(), dest, tags...)
+		if err != nil {
+			t.Fatalf("SyncMirror(%v): %v", tags, err)
+		}
+		if len(syncs) != 1 || syncs[0].Tag != wantTag {
+			t.Fatalf("SyncMirror(%v) = %+v", tags, syncs)
+		}
+		if s := syncs[0]; len(s.Fetched) != wantFetched || len(s.Current) != wantCurrent {
+			t.Errorf("%s: fetched %v, current %v", s.Tag, s.Fetched, s.Current)
+		}
+		for _, name := range names {
+			got, err := os.ReadFile(filepath.Join(dest, wantTag, name))
+			if err != nil || string(got) != content(wantTag, name) {
+				t.Errorf("%s/%s = %q, %v", wantTag, name, got, err)
+			}
+		}
+	}
+	verify(nil, "v1.2.0", 3, 0)
+	verify(nil, "v1.2.0", 0, 3)
+	if n := downloads.Load(); n != 3 {
+		t.Errorf("%d downloads, want 3", n)
+	}
+
+	// A stale file is replaced.
+	stale := filepath.Join(dest, "v1.2.0", "checksums.txt")
+	os.WriteFile(stale, []byte("v1.2.0/CHECKSUMS.TXT"), 0o644)
+	verify(nil, "v1.2.0", 1, 2)
+	verify([]string{"v1.1.0"}, "v1.1.0", 3, 0)
+
+	// A corrupt download is not kept.
+	os.Remove(stale)
+	tampered.Store(true)
+	if _, err := u.SyncMirror(context.Background(), dest); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("SyncMirror of a corrupt asset: %v", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(stale)); len(entries) != 2 {
+		t.Errorf("%d files left after a corrupt download, want 2", len(entries))
+	}
+
+	if _, err := u.SyncMirror(context.Background(), dest, "bad"); err == nil {
+		t.Error("SyncMirror accepted the tag ..")
+	}
+}
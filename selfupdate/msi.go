@@ -0,0 +1,92 @@
+package selfupdate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// MSIInstall installs releases published as Windows Installer packages
+// by running "msiexec /i <package> /qn /norestart" with a verbose log.
+// Select the .msi asset with Updater.AssetRegexp. The target is ignored:
+// the package decides where its files go. Since the version of the
+// product is not that of the running executable, use MSIProductVersion
+// for Updater.Build.Version.
+type MSIInstall struct {
+	// LogDir receives the log of each install as "msiexec-<tag>.log";
+	// it defaults to the directory the package was downloaded to. Logs
+	// are kept for troubleshooting.
+	LogDir string
+	// Properties are public properties passed to msiexec, e.g.
+	// "INSTALLDIR=C:\App".
+	Properties []string
+}
+
+// Exit codes of msiexec that mean success.
+const (
+	msiSuccess               = 0
+	msiSuccessRebootRequired = 3010
+	msiSuccessRebootStarted  = 1641
+)
+
+// runMSIExec runs msiexec with args and returns its exit code. Replaced
+// in tests.
+var runMSIExec = func(ctx context.Context, args []string) (int, error) {
+	err := exec.CommandContext(ctx, "msiexec", args...).Run()
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		return exit.ExitCode(), nil
+	}
+	return 0, err
+}
+
+func (m MSIInstall) Apply(ctx context.Context, a Artifact, target string) error {
+	defer os.Remove(a.Path)
+	if !strings.EqualFold(filepath.Ext(a.Name), ".msi") {
+		return fmt.Errorf("%s is not an .msi package", a.Name)
+	}
+	// msiexec rejects packages without the extension.
+	pkg := a.Path + ".msi"
+	if err := os.Rename(a.Path, pkg); err != nil {
+		return err
+	}
+	defer os.Remove(pkg)
+	dir := m.LogDir
+	if dir == "" {
+		dir = filepath.Dir(a.Path)
+	}
+	logPath := filepath.Join(dir, "msiexec-"+a.Tag+".log")
+	args := append([]string{"/i", pkg, "/qn", "/norestart", "/l*v", logPath}, m.Properties...)
+	code, err := runMSIExec(ctx, args)
+	if err != nil {
+		return fmt.Errorf("msiexec: %w", err)
+	}
+	switch code {
+	case msiSuccess:
+		return nil
+	case msiSuccessRebootRequired, msiSuccessRebootStarted:
+		// The files are in place; replaced ones in use take effect after a
+		// reboot, which is left to the administrator.
+		return nil
+	}
+	return fmt.Errorf("msiexec exited with %d; see %s", code, logPath)
+}
+
+// MSIProductVersion returns the version Windows records for an installed
+// product, named by its product code ("{GUID}") or display name, as a
+// tag with a "v" prefix, e.g. "v1.4.0". It fails outside Windows and for
+// products that are not installed.
+func MSIProductVersion(product string) (string, error) {
+	v, err := msiProductVersion(product)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(v, "v") {
+		v = "v" + v
+	}
+	return v, nil
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+
+package selfupdate
+
+import "errors"
+
+func msiProductVersion(product string) (string, error) {
+	return "", errors.New("installed products can only be looked up on Windows")
+}
@@ -0,0 +1,56 @@
+package selfupdate
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func Test_MSIInstall(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the expected paths use slashes")
+	}
+	defer func(r func(context.Context, []string) (int, error)) { runMSIExec = r }(runMSIExec)
+	var ran []string
+	var content string
+	code := 0
+	runMSIExec = func(ctx context.Context, args []string) (int, error) {
+		ran = args
+		b, _ := os.ReadFile(args[1])
+		content = string(b)
+		return code, nil
+	}
+	dir := t.TempDir()
+	verify := func(m MSIInstall, name, wantArgs, wantErr string) {
+		t.Helper()
+		staged := filepath.Join(dir, "app.new")
+		os.WriteFile(staged, []byte("msi"), 0o644)
+		ran, content = nil, ""
+		err := m.Apply(context.Background(), Artifact{Path: staged, Name: name, Tag: "v1.1.0"}, filepath.Join(dir, "app.exe"))
+		if wantErr == "" && err != nil || wantErr != "" && (err == nil || !strings.Contains(err.Error(), wantErr)) {
+			t.Errorf("%s: expected error %q, got %v", name, wantErr, err)
+		}
+		want := strings.ReplaceAll(wantArgs, "DIR", dir)
+		if got := strings.Join(ran, " "); got != want {
+			t.Errorf("%s: expected %q, got %q", name, want, got)
+		}
+		if ran != nil && content != "msi" {
+			t.Errorf("%s: msiexec did not see the package, got %q", name, content)
+		}
+		if left, _ := filepath.Glob(filepath.Join(dir, "app.new*")); len(left) != 0 {
+			t.Errorf("%s: left behind %v", name, left)
+		}
+	}
+	verify(MSIInstall{}, "app-1.1.0-x64.msi", "/i DIR/app.new.msi /qn /norestart /l*v DIR/msiexec-v1.1.0.log", "")
+	verify(MSIInstall{LogDir: "/var/log/app", Properties: []string{"INSTALLFOLDER=C:\\App"}}, "app.MSI",
+		`/i DIR/app.new.msi /qn /norestart /l*v /var/log/app/msiexec-v1.1.0.log INSTALLFOLDER=C:\App`, "")
+	verify(MSIInstall{}, "app.zip", "", "app.zip is not an .msi package")
+	code = 3010
+	verify(MSIInstall{}, "app.msi", "/i DIR/app.new.msi /qn /norestart /l*v DIR/msiexec-v1.1.0.log", "")
+	code = 1603
+	verify(MSIInstall{}, "app.msi", "/i DIR/app.new.msi /qn /norestart /l*v DIR/msiexec-v1.1.0.log",
+		"msiexec exited with 1603; see "+filepath.Join(dir, "msiexec-v1.1.0.log"))
+}
@@ -0,0 +1,84 @@
+//go:build windows
+
+package selfupdate
+
+import (
+	"fmt"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// uninstallKeys are the registry keys below HKEY_LOCAL_MACHINE listing
+// installed products, native ones first.
+var uninstallKeys = []string{
+	`SOFTWARE\Microsoft\Windows\CurrentVersion\Uninstall`,
+	`SOFTWARE\WOW6432Node\Microsoft\Windows\CurrentVersion\Uninstall`,
+}
+
+// msiProductVersion returns the DisplayVersion of the uninstall entry
+// whose key is the product code product or whose DisplayName is product.
+func msiProductVersion(product string) (string, error) {
+	for _, path := range uninstallKeys {
+		root, err := openKey(syscall.HKEY_LOCAL_MACHINE, path)
+		if err != nil {
+			continue
+		}
+		v, ok := findProduct(root, product)
+		syscall.RegCloseKey(root)
+		if ok {
+			return v, nil
+		}
+	}
+	return "", fmt.Errorf("%s is not installed", product)
+}
+
+func findProduct(root syscall.Handle, product string) (string, bool) {
+	for i := uint32(0); ; i++ {
+		buf := make([]uint16, 256)
+		n := uint32(len(buf))
+		if err := syscall.RegEnumKeyEx(root, i, &buf[0], &n, nil, nil, nil, nil); err != nil {
+			// ERROR_NO_MORE_ITEMS ends the enumeration.
+			return "", false
+		}
+		name := syscall.UTF16ToString(buf[:n])
+		k, err := openKey(root, name)
+		if err != nil {
+			continue
+		}
+		display, _ := regString(k, "DisplayName")
+		version, ok := regString(k, "DisplayVersion")
+		syscall.RegCloseKey(k)
+		if ok && (strings.EqualFold(name, product) || strings.EqualFold(display, product)) {
+			return version, true
+		}
+	}
+}
+
+func openKey(parent syscall.Handle, path string) (syscall.Handle, error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	var k syscall.Handle
+	err = syscall.RegOpenKeyEx(parent, p, 0, syscall.KEY_READ, &k)
+	return k, err
+}
+
+// regString reads a string value of k.
+func regString(k syscall.Handle, name string) (string, bool) {
+	p, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return "", false
+	}
+	var typ, n uint32
+	if err := syscall.RegQueryValueEx(k, p, nil, &typ, nil, &n); err != nil || n == 0 ||
+		typ != syscall.REG_SZ && typ != syscall.REG_EXPAND_SZ {
+		return "", false
+	}
+	buf := make([]uint16, n/2+1)
+	if err := syscall.RegQueryValueEx(k, p, nil, &typ, (*byte)(unsafe.Pointer(&buf[0])), &n); err != nil {
+		return "", false
+	}
+	return syscall.UTF16ToString(buf), true
+}
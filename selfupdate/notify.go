@@ -0,0 +1,95 @@
+package selfupdate
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// DefaultNotifyInterval is how often LatestNotice checks for a release by
+// default.
+const DefaultNotifyInterval = 24 * time.Hour
+
+// notifyFile caches the result of the last LatestNotice check.
+const notifyFile = "notify.json"
+
+type notifyState struct {
+	CheckedAt time.Time `json:"checked_at"`
+	// Latest is the newest eligible release found, empty if the running
+	// version was the newest.
+	Latest string `json:"latest,omitempty"`
+	// RetryAt postpones the next check until a rate limit ends.
+	RetryAt time.Time `json:"retry_at,omitzero"`
+}
+
+// LatestNotice supports notifying about releases without installing
+// them. It returns, without blocking, the newer release found by the
+// last check, or "" if none is known, and checks again in the background
+// if that check is older than interval (DefaultNotifyInterval if zero).
+// The result is cached in StateDir, so short-lived processes check at
+// most once per interval. fresh receives the newest release after that
+// check ("" if none is newer) and is closed; it is closed without a
+// value if no check ran or the check failed.
+func (u *Updater) LatestNotice(ctx context.Context, interval time.Duration) (latest string, fresh <-chan string) {
+	if !Enabled() {
+		ch := make(chan string)
+		close(ch)
+		return "", ch
+	}
+	if interval <= 0 {
+		interval = DefaultNotifyInterval
+	}
+	var s notifyState
+	if u.StateDir != "" {
+		if err := u.readState(notifyFile, &s); err != nil {
+			u.logf("Cannot read the release notice cache: %v", err)
+		}
+	}
+	if u.isNewer(s.Latest) {
+		latest = s.Latest
+	}
+	ch := make(chan string, 1)
+	if now := u.clock().Now(); now.Sub(s.CheckedAt) < interval || now.Before(s.RetryAt) {
+		close(ch)
+		return latest, ch
+	}
+	go func() {
+		defer close(ch)
+		info, err := u.Check(ctx)
+		checked := notifyState{CheckedAt: u.clock().Now().UTC(), Latest: s.Latest}
+		ok := true
+		var limited *RateLimitError
+		switch {
+		case err == nil:
+			checked.Latest = info.Remote
+		case errors.Is(err, ErrAlreadyLatest), errors.Is(err, ErrNoRelease), errors.Is(err, ErrMajorUpgrade):
+			checked.Latest = ""
+		case errors.As(err, &limited):
+			checked.RetryAt = limited.Reset.UTC()
+			ok = false
+		default:
+			// Recorded anyway, so a failing check is not retried on every
+			// start.
+			u.logf("Release check failed: %v", err)
+			ok = false
+		}
+		if u.StateDir != "" {
+			if err := u.writeState(notifyFile, checked); err != nil {
+				u.logf("Cannot cache the release notice: %v", err)
+			}
+		}
+		if ok {
+			ch <- checked.Latest
+		}
+	}()
+	return latest, ch
+}
+
+// isNewer reports whether tag is newer than the running version.
+func (u *Updater) isNewer(tag string) bool {
+	if tag == "" {
+		return false
+	}
+	c, err := ParseVersion(tag).Compare(ParseVersion(u.Build.Version))
+	return err == nil && c > 0
+}
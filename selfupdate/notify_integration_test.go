@@ -0,0 +1,44 @@
+package selfupdate_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/msmania/updater/selfupdate/selfupdatetest"
+)
+
+func Test_Updater_latestNotice(t *testing.T) {
+	skipIfDisabled(t)
+	srv := selfupdatetest.NewServer("owner", "app",
+		selfupdatetest.Release{Tag: "v1.1.0", Assets: []selfupdatetest.Asset{{Name: "app-bin", Content: []byte("v1.1")}}},
+	)
+	defer srv.Close()
+	u := newUpdater(t, srv, "v1.0.0")
+	u.StateDir = t.TempDir()
+	verify := func(wantLatest, wantFresh string, wantCheck bool) {
+		t.Helper()
+		latest, fresh := u.LatestNotice(context.Background(), time.Hour)
+		if latest != wantLatest {
+			t.Errorf("expected cached %q, got %q", wantLatest, latest)
+		}
+		tag, checked := <-fresh
+		if checked != wantCheck || tag != wantFresh {
+			t.Errorf("expected check %v with %q, got %v with %q", wantCheck, wantFresh, checked, tag)
+		}
+	}
+	verify("", "v1.1.0", true)
+	n := len(srv.Requests())
+	verify("v1.1.0", "", false)
+	if len(srv.Requests()) != n {
+		t.Error("checked again within the interval")
+	}
+	if b, _ := os.ReadFile(u.Path); string(b) != "old" {
+		t.Errorf("notice installed the release: %q", b)
+	}
+
+	// Installed since: the cached release is no longer newer.
+	u.Build.Version = "v1.1.0"
+	verify("", "", false)
+}
@@ -0,0 +1,189 @@
+package selfupdate
+
+import (
+	"encoding/json"
+	"go/token"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Route is an endpoint of an HTTP API; see Updater.Routes and OpenAPI.
+type Route struct {
+	Method, Path string
+	Summary      string
+	// Response is a value of the type of the body of a 200 response, from
+	// which its schema is derived; nil if there is no JSON body.
+	Response any
+	// ContentType of a 200 response; defaults to application/json if
+	// Response is set.
+	ContentType string
+	// Errors lists the other status codes the endpoint answers with.
+	Errors []int
+	// Handler serves the endpoint; it is not needed for documentation.
+	Handler http.HandlerFunc
+}
+
+// OpenAPI builds an OpenAPI 3.0 document from Routes. Response schemas
+// are derived from the Go types with their json tags, so the document
+// follows the code it describes.
+type OpenAPI struct {
+	title, version string
+	paths          map[string]map[string]any
+	schemas        map[string]any
+}
+
+// NewOpenAPI returns an empty document for the API title at version.
+func NewOpenAPI(title, version string) *OpenAPI {
+	return &OpenAPI{title: title, version: version,
+		paths: make(map[string]map[string]any), schemas: make(map[string]any)}
+}
+
+// Add documents routes below the path prefix, e.g. where Handler is
+// mounted.
+func (o *OpenAPI) Add(prefix string, routes ...Route) {
+	for _, rt := range routes {
+		path := prefix + rt.Path
+		ok := map[string]any{"description": "OK"}
+		contentType := rt.ContentType
+		if contentType == "" && rt.Response != nil {
+			contentType = "application/json"
+		}
+		if contentType != "" {
+			media := map[string]any{}
+			if rt.Response != nil {
+				media["schema"] = o.schema(reflect.TypeOf(rt.Response))
+			}
+			ok["content"] = map[string]any{contentType: media}
+		}
+		responses := map[string]any{"200": ok}
+		for _, code := range rt.Errors {
+			responses[strconv.Itoa(code)] = map[string]any{"description": http.StatusText(code)}
+		}
+		op := map[string]any{"summary": rt.Summary, "responses": responses}
+		if o.paths[path] == nil {
+			o.paths[path] = make(map[string]any)
+		}
+		o.paths[path][strings.ToLower(rt.Method)] = op
+	}
+}
+
+func (o *OpenAPI) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"openapi":    "3.0.3",
+		"info":       map[string]any{"title": o.title, "version": o.version},
+		"paths":      o.paths,
+		"components": map[string]any{"schemas": o.schemas},
+	})
+}
+
+// ServeHTTP serves the document as JSON.
+func (o *OpenAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, o)
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	rawType  = reflect.TypeOf(json.RawMessage(nil))
+	// encodedAs maps types with a MarshalJSON method to the type they
+	// encode like.
+	encodedAs = map[reflect.Type]reflect.Type{
+		reflect.TypeOf(Durations{}): reflect.TypeOf(durationsJSON{}),
+	}
+)
+
+// schema returns the JSON schema of t, registering exported struct types
+// as components.
+func (o *OpenAPI) schema(t reflect.Type) map[string]any {
+	if enc, ok := encodedAs[t]; ok {
+		t = enc
+	}
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == rawType:
+		return map[string]any{}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return o.schema(t.Elem())
+	case reflect.Struct:
+		if !token.IsExported(t.Name()) {
+			return o.object(t)
+		}
+		if _, ok := o.schemas[t.Name()]; !ok {
+			o.schemas[t.Name()] = map[string]any{} // ends recursion
+			o.schemas[t.Name()] = o.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": o.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": o.schema(t.Elem())}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return map[string]any{"type": "integer"}
+	case reflect.Int64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	}
+	return map[string]any{}
+}
+
+// object returns the inline schema of the struct type t.
+func (o *OpenAPI) object(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	var required []string
+	o.fields(t, props, &required)
+	s := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		sort.Strings(required)
+		s["required"] = required
+	}
+	return s
+}
+
+// fields adds the JSON fields of the struct type t, including those of
+// embedded structs, as encoding/json does.
+func (o *OpenAPI) fields(t reflect.Type, props map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		if f.Anonymous && name == "" {
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				o.fields(ft, props, required)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = o.schema(ft)
+		if !strings.Contains(opts, "omitempty") && !strings.Contains(opts, "omitzero") && ft.Kind() != reflect.Pointer {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package selfupdate
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func Test_OpenAPI(t *testing.T) {
+	u := &Updater{Build: BuildInfo{Version: "v1.4.0"}}
+	spec := NewOpenAPI("app", "v1.4.0")
+	spec.Add("/api/v1/update", u.Routes()...)
+	data, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		OpenAPI    string                               `json:"openapi"`
+		Paths      map[string]map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]map[string]any `json:"properties"`
+				Required   []string                  `json:"required"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("unexpected openapi version %q", doc.OpenAPI)
+	}
+	for _, rt := range u.Routes() {
+		if doc.Paths["/api/v1/update"+rt.Path][strings.ToLower(rt.Method)] == nil {
+			t.Errorf("%s %s not documented", rt.Method, rt.Path)
+		}
+	}
+	if _, ok := doc.Paths["/api/v1/update/rollback"]["post"]["responses"].(map[string]any)["412"]; !ok {
+		t.Error("error responses not documented")
+	}
+
+	st := doc.Components.Schemas["Status"]
+	if st.Properties["checked_at"]["format"] != "date-time" || st.Properties["pending_major_upgrade"]["$ref"] == nil {
+		t.Errorf("unexpected Status schema %v", st.Properties)
+	}
+	if strings.Join(st.Required, ",") != "channel,current,result" {
+		t.Errorf("unexpected required fields %v", st.Required)
+	}
+	check := doc.Paths["/api/v1/update/check"]["get"]["responses"].(map[string]any)["200"]
+	if s, _ := json.Marshal(check); !strings.Contains(string(s), `"decision"`) || !strings.Contains(string(s), `"error"`) {
+		t.Errorf("embedded UpdateInfo not inlined: %s", s)
+	}
+	for name, s := range doc.Components.Schemas {
+		if d, ok := s.Properties["durations"]; ok && d["$ref"] != nil {
+			t.Errorf("%s: Durations should be described as encoded: %v", name, d)
+		}
+	}
+}
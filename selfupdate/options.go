@@ -0,0 +1,161 @@
+package selfupdate
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+)
+
+// Option configures an Updater created by New.
+type Option func(*Updater) error
+
+// New returns an Updater for the GitHub repository owner/repo. Without
+// options it behaves like &Updater{Owner: owner, Repo: repo}: the stable
+// channel, the "<repo>-<GOOS>-<GOARCH>" asset and the running executable.
+func New(owner, repo string, opts ...Option) (*Updater, error) {
+	u := &Updater{Owner: owner, Repo: repo}
+	for _, opt := range opts {
+		if err := opt(u); err != nil {
+			return nil, err
+		}
+	}
+	return u, nil
+}
+
+// WithBuild sets the metadata of the running binary, notably its version.
+func WithBuild(b BuildInfo) Option {
+	return func(u *Updater) error {
+		u.Build = b
+		return nil
+	}
+}
+
+// WithHTTPClient makes every request use c instead of the shared
+// transport configured by Updater.Transport.
+func WithHTTPClient(c *http.Client) Option {
+	return func(u *Updater) error {
+		u.HTTPClient = c
+		return nil
+	}
+}
+
+// WithChannel selects the release channel.
+func WithChannel(c Channel) Option {
+	return func(u *Updater) error {
+		_, err := ParseChannel(string(c))
+		u.Channel = c
+		return err
+	}
+}
+
+// WithAssetTemplate names the asset to install with a text/template; see
+// Updater.AssetTemplate. The template is validated here.
+func WithAssetTemplate(tmpl string) Option {
+	return func(u *Updater) error {
+		if _, err := parseAssetTemplate(tmpl); err != nil {
+			return err
+		}
+		u.AssetTemplate = tmpl
+		return nil
+	}
+}
+
+// WithAssetFallbacks sets the templates of assets installed when the
+// release lacks the primary one; see Updater.AssetFallbacks. The
+// templates are validated here.
+func WithAssetFallbacks(tmpls ...string) Option {
+	return func(u *Updater) error {
+		for _, tmpl := range tmpls {
+			if _, err := parseAssetTemplate(tmpl); err != nil {
+				return err
+			}
+		}
+		u.AssetFallbacks = tmpls
+		return nil
+	}
+}
+
+// WithAssetRegexp selects the asset by a regular expression matching its
+// whole name; see Updater.AssetRegexp.
+func WithAssetRegexp(expr string) Option {
+	return func(u *Updater) error {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("invalid asset regexp: %w", err)
+		}
+		u.AssetRegexp = re
+		return nil
+	}
+}
+
+// WithAuxFiles sets the files installed alongside the executable; see
+// Updater.AuxFiles. Each needs a Path, and Asset templates are validated
+// here.
+func WithAuxFiles(files ...AuxFile) Option {
+	return func(u *Updater) error {
+		for _, f := range files {
+			if f.Path == "" {
+				return fmt.Errorf("auxiliary file without a path")
+			}
+			if f.Asset == "" {
+				continue
+			}
+			if _, err := parseAssetTemplate(f.Asset); err != nil {
+				return err
+			}
+		}
+		u.AuxFiles = files
+		return nil
+	}
+}
+
+// WithMirrors sets the download mirrors tried before GitHub; see
+// Updater.Mirrors. Each must be GitHubMirror or an http(s) base URL.
+func WithMirrors(mirrors ...string) Option {
+	return func(u *Updater) error {
+		for _, m := range mirrors {
+			if m == GitHubMirror || m == TorrentMirror {
+				continue
+			}
+			if p, err := url.Parse(m); err != nil || (p.Scheme != "http" && p.Scheme != "https") || p.Host == "" {
+				return fmt.Errorf("invalid mirror %q", m)
+			}
+		}
+		u.Mirrors = mirrors
+		return nil
+	}
+}
+
+// WithVerifier adds v to the checks a download must pass.
+func WithVerifier(v Verifier) Option {
+	return func(u *Updater) error {
+		u.Verifier = v
+		return nil
+	}
+}
+
+// WithApplier selects how the verified artifact is installed.
+func WithApplier(a Applier) Option {
+	return func(u *Updater) error {
+		u.Applier = a
+		return nil
+	}
+}
+
+// WithLogger directs the Updater's log output to l.
+func WithLogger(l *log.Logger) Option {
+	return func(u *Updater) error {
+		u.Logger = l
+		return nil
+	}
+}
+
+// WithStateDir persists update state, such as the last Status, in dir.
+func WithStateDir(dir string) Option {
+	return func(u *Updater) error {
+		u.StateDir = dir
+		return nil
+	}
+}
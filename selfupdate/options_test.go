@@ -0,0 +1,75 @@
+package selfupdate
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func Test_New(t *testing.T) {
+	u, err := New("owner", "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Owner != "owner" || u.Repo != "app" || u.channel() != ChannelStable {
+		t.Error("unexpected defaults")
+	}
+	if name, _ := u.assetName("v1.0.0"); name != "app-"+runtime.GOOS+"-"+runtime.GOARCH {
+		t.Error("unexpected default asset name " + name)
+	}
+
+	var buf bytes.Buffer
+	client := &http.Client{}
+	u, err = New("owner", "app",
+		WithChannel(ChannelBeta),
+		WithHTTPClient(client),
+		WithLogger(log.New(&buf, "", 0)),
+		WithAssetTemplate("{{.Repo}}_{{.Version}}_{{.OS}}_{{.Arch}}.tar.gz"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.http().client != client {
+		t.Error("HTTP client not injected")
+	}
+	if name, _ := u.assetName("v1.2.3"); name != "app_1.2.3_"+runtime.GOOS+"_"+runtime.GOARCH+".tar.gz" {
+		t.Error("unexpected templated asset name " + name)
+	}
+	if err := WithAssetFallbacks("{{.Repo}}-{{.OS}}-{{.Arch}}-musl", "{{.Repo}}_{{.Version}}_all.tar.gz")(u); err != nil {
+		t.Fatal(err)
+	}
+	names, _ := u.assetNames("v1.2.3")
+	want := []string{"app_1.2.3_" + runtime.GOOS + "_" + runtime.GOARCH + ".tar.gz",
+		"app-" + runtime.GOOS + "-" + runtime.GOARCH + "-musl", "app_1.2.3_all.tar.gz"}
+	if strings.Join(names, " ") != strings.Join(want, " ") {
+		t.Errorf("unexpected asset names %v", names)
+	}
+	if err := WithAssetFallbacks("{{.Nope"); err == nil {
+		t.Error("invalid fallback template accepted")
+	}
+	if err := WithAuxFiles(AuxFile{Asset: "{{.Repo}}.bash"})(u); err == nil {
+		t.Error("auxiliary file without a path accepted")
+	}
+	if err := WithAuxFiles(AuxFile{Asset: "{{.Repo", Path: "/etc/app.bash"})(u); err == nil {
+		t.Error("invalid auxiliary asset template accepted")
+	}
+	u.logf("hello")
+	if !strings.Contains(buf.String(), "hello") {
+		t.Error("logger not used")
+	}
+
+	verifyFail := func(opt Option) {
+		if _, err := New("owner", "app", opt); err == nil {
+			t.Error("expected error")
+		}
+	}
+	verifyFail(WithChannel("nightly"))
+	verifyFail(WithAssetTemplate("{{.Repo"))
+	verifyFail(WithMirrors("https://cache.internal", "cdn.example.com"))
+	if _, err := New("owner", "app", WithMirrors("https://cache.internal/app", GitHubMirror, TorrentMirror)); err != nil {
+		t.Error(err)
+	}
+}
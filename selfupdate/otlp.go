@@ -0,0 +1,277 @@
+package selfupdate
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OTLPTracer exports spans to an OpenTelemetry collector using the
+// OTLP/HTTP JSON encoding. Finished spans are buffered and sent in
+// batches; call Flush before the process exits.
+type OTLPTracer struct {
+	// Client sends the batches. If nil, they are sent with the client of
+	// the Updater whose Tracer t is (see Updater.Client), or with a 10s
+	// timeout until that Updater first uses t.
+	Client *http.Client
+
+	endpoint string
+	resource []otlpKeyValue
+
+	mu      sync.Mutex
+	pending []otlpSpan
+	// updaterClient is the client of the Updater using t.
+	updaterClient *http.Client
+}
+
+// otlpBatchSize is the number of finished spans that triggers an export.
+const otlpBatchSize = 64
+
+// NewOTLPTracer creates a tracer sending to endpoint, the collector base
+// URL (e.g. "http://localhost:4318"). Spans carry service and host
+// attributes so rollouts can be correlated across machines.
+func NewOTLPTracer(endpoint, serviceName string, build BuildInfo) *OTLPTracer {
+	host, _ := os.Hostname()
+	return &OTLPTracer{
+		endpoint: strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		resource: []otlpKeyValue{
+			otlpAttr("service.name", serviceName),
+			otlpAttr("service.version", build.Version),
+			otlpAttr("host.name", host),
+		},
+	}
+}
+
+// attach makes t export with c unless it has a Client of its own.
+func (t *OTLPTracer) attach(c *http.Client) {
+	t.mu.Lock()
+	t.updaterClient = c
+	t.mu.Unlock()
+}
+
+// client returns the client exports are sent with.
+func (t *OTLPTracer) client() *http.Client {
+	if t.Client != nil {
+		return t.Client
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.updaterClient != nil {
+		return t.updaterClient
+	}
+	return otlpDefaultClient
+}
+
+var otlpDefaultClient = &http.Client{Timeout: 10 * time.Second}
+
+type otlpSpanContextKey struct{}
+
+type otlpSpanContext struct {
+	traceID string
+	spanID  string
+}
+
+// Start begins a span, parented to any span already in ctx.
+func (t *OTLPTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	sc := otlpSpanContext{spanID: randomHex(8)}
+	var parentID string
+	if parent, ok := ctx.Value(otlpSpanContextKey{}).(otlpSpanContext); ok {
+		sc.traceID = parent.traceID
+		parentID = parent.spanID
+	} else {
+		sc.traceID = randomHex(16)
+	}
+	s := &otlpLiveSpan{
+		tracer: t,
+		data: otlpSpan{
+			TraceID:      sc.traceID,
+			SpanID:       sc.spanID,
+			ParentSpanID: parentID,
+			Name:         name,
+			Kind:         1, // SPAN_KIND_INTERNAL
+			StartTime:    strconv.FormatInt(time.Now().UnixNano(), 10),
+		},
+	}
+	return context.WithValue(ctx, otlpSpanContextKey{}, sc), s
+}
+
+// Flush sends all buffered spans to the collector.
+func (t *OTLPTracer) Flush(ctx context.Context) error {
+	t.mu.Lock()
+	spans := t.pending
+	t.pending = nil
+	t.mu.Unlock()
+	if len(spans) == 0 {
+		return nil
+	}
+	return t.export(ctx, spans)
+}
+
+func (t *OTLPTracer) finish(s otlpSpan) {
+	t.mu.Lock()
+	t.pending = append(t.pending, s)
+	var batch []otlpSpan
+	if len(t.pending) >= otlpBatchSize {
+		batch = t.pending
+		t.pending = nil
+	}
+	t.mu.Unlock()
+	if batch != nil {
+		go t.export(context.Background(), batch)
+	}
+}
+
+func (t *OTLPTracer) export(ctx context.Context, spans []otlpSpan) error {
+	payload := otlpTraceRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: t.resource},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "github.com/msmania/updater/selfupdate"},
+			Spans: spans,
+		}},
+	}}}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := t.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("otlp collector returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+type otlpLiveSpan struct {
+	tracer *OTLPTracer
+	once   sync.Once
+	mu     sync.Mutex
+	data   otlpSpan
+}
+
+func (s *otlpLiveSpan) SetAttributes(attrs ...Attribute) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range attrs {
+		s.data.Attributes = append(s.data.Attributes, otlpAttr(a.Key, a.Value))
+	}
+}
+
+func (s *otlpLiveSpan) RecordError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Status = &otlpStatus{Code: 2, Message: err.Error()} // STATUS_CODE_ERROR
+	s.data.Events = append(s.data.Events, otlpEvent{
+		TimeUnixNano: strconv.FormatInt(time.Now().UnixNano(), 10),
+		Name:         "exception",
+		Attributes:   []otlpKeyValue{otlpAttr("exception.message", err.Error())},
+	})
+}
+
+func (s *otlpLiveSpan) End() {
+	s.once.Do(func() {
+		s.mu.Lock()
+		s.data.EndTime = strconv.FormatInt(time.Now().UnixNano(), 10)
+		data := s.data
+		s.mu.Unlock()
+		s.tracer.finish(data)
+	})
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ---------------------------------------------------------------------
+// OTLP/JSON wire structures (subset)
+// ---------------------------------------------------------------------
+type (
+	otlpTraceRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID      string         `json:"traceId"`
+		SpanID       string         `json:"spanId"`
+		ParentSpanID string         `json:"parentSpanId,omitempty"`
+		Name         string         `json:"name"`
+		Kind         int            `json:"kind"`
+		StartTime    string         `json:"startTimeUnixNano"`
+		EndTime      string         `json:"endTimeUnixNano"`
+		Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+		Events       []otlpEvent    `json:"events,omitempty"`
+		Status       *otlpStatus    `json:"status,omitempty"`
+	}
+	otlpEvent struct {
+		TimeUnixNano string         `json:"timeUnixNano"`
+		Name         string         `json:"name"`
+		Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+		IntValue    *string  `json:"intValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+	}
+)
+
+func otlpAttr(key string, value any) otlpKeyValue {
+	kv := otlpKeyValue{Key: key}
+	switch v := value.(type) {
+	case bool:
+		kv.Value.BoolValue = &v
+	case int:
+		s := strconv.Itoa(v)
+		kv.Value.IntValue = &s
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		kv.Value.IntValue = &s
+	case float64:
+		kv.Value.DoubleValue = &v
+	case string:
+		kv.Value.StringValue = &v
+	default:
+		s := fmt.Sprint(v)
+		kv.Value.StringValue = &s
+	}
+	return kv
+}
@@ -0,0 +1,61 @@
+package selfupdate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_OTLPTracer(t *testing.T) {
+	var got otlpTraceRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			t.Error("unexpected path " + r.URL.Path)
+		}
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer srv.Close()
+
+	tracer := NewOTLPTracer(srv.URL+"/", "test", BuildInfo{Version: "v1.0.0"})
+	ctx, parent := tracer.Start(context.Background(), SpanUpdate)
+	_, child := tracer.Start(ctx, SpanDownload)
+	child.SetAttributes(Attr("updater.bytes", int64(42)), Attr("updater.asset", "a"))
+	child.RecordError(errors.New("boom"))
+	child.End()
+	child.End() // ending twice must not duplicate the span
+	parent.End()
+	if err := tracer.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := got.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	c, p := spans[0], spans[1]
+	if c.Name != SpanDownload || p.Name != SpanUpdate {
+		t.Error("unexpected span order")
+	}
+	if c.TraceID != p.TraceID || c.ParentSpanID != p.SpanID || p.ParentSpanID != "" {
+		t.Error("child span should be linked to parent")
+	}
+	if len(c.TraceID) != 32 || len(c.SpanID) != 16 {
+		t.Error("trace and span IDs should be hex encoded")
+	}
+	if c.Status == nil || c.Status.Code != 2 || c.Status.Message != "boom" {
+		t.Error("error status not recorded")
+	}
+	if v := c.Attributes[0].Value.IntValue; v == nil || *v != "42" {
+		t.Error("int attribute not encoded as string")
+	}
+	if err := tracer.Flush(context.Background()); err != nil {
+		t.Error("flush with nothing pending should succeed")
+	}
+}
@@ -0,0 +1,148 @@
+package selfupdate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// OverlayConfig makes Update install into a writable directory when the
+// executable lives on a read-only file system, as on immutable OS images
+// such as Fedora CoreOS. The process then has to be started from the new
+// location: the caller re-executes into OverlayExecutable, and the
+// optional DropIn and Profile files make later starts use it too.
+type OverlayConfig struct {
+	// Dir receives the executable, under its original base name. Empty
+	// disables the fallback. It is created if missing.
+	Dir string
+	// DropIn, if set, is a systemd drop-in written to start the overlay
+	// executable with the current arguments, e.g.
+	// /etc/systemd/system/app.service.d/overlay.conf. systemd is
+	// reloaded after it changes.
+	DropIn string
+	// Profile, if set, is a shell script written to put Dir first on
+	// PATH, e.g. /etc/profile.d/app-overlay.sh.
+	Profile string
+}
+
+// daemonReload makes systemd read a changed drop-in. Replaced in tests.
+var daemonReload = func() error {
+	if _, err := exec.LookPath("systemctl"); err != nil {
+		return nil
+	}
+	return exec.Command("systemctl", "daemon-reload").Run()
+}
+
+// readOnly reports whether dir is on a read-only file system. Replaced
+// in tests.
+var readOnly = func(dir string) bool {
+	f, err := os.CreateTemp(dir, ".probe-*")
+	if err != nil {
+		return errors.Is(err, syscall.EROFS)
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return false
+}
+
+// overlayPath returns the file Update installs to for exePath: exePath
+// itself, or its place in Overlay.Dir if exePath is read-only.
+func (u *Updater) overlayPath(exePath string) (string, error) {
+	if u.Overlay.Dir == "" || sameDir(filepath.Dir(exePath), u.Overlay.Dir) || !readOnly(filepath.Dir(exePath)) {
+		return exePath, nil
+	}
+	if err := os.MkdirAll(u.Overlay.Dir, 0o755); err != nil {
+		return "", err
+	}
+	target := filepath.Join(u.Overlay.Dir, filepath.Base(exePath))
+	u.logf("%s is on a read-only file system; installing into %s", filepath.Dir(exePath), target)
+	return target, nil
+}
+
+// OverlayExecutable returns the executable in Overlay.Dir that should run
+// instead of this one, or "" if this is it or there is none. Callers
+// re-execute into it, see Reexec, at startup and after an upgrade.
+func (u *Updater) OverlayExecutable() string {
+	exePath, err := u.path()
+	if err != nil || u.Overlay.Dir == "" || sameDir(filepath.Dir(exePath), u.Overlay.Dir) {
+		return ""
+	}
+	target := filepath.Join(u.Overlay.Dir, filepath.Base(exePath))
+	if fi, err := os.Stat(target); err != nil || !fi.Mode().IsRegular() {
+		return ""
+	}
+	return target
+}
+
+// pointToOverlay writes Overlay.DropIn and Overlay.Profile for the
+// executable target. Failures are logged: the update itself succeeded.
+func (u *Updater) pointToOverlay(target string) {
+	if u.Overlay.DropIn != "" {
+		args := []string{systemdQuote(target)}
+		for _, a := range os.Args[1:] {
+			args = append(args, systemdQuote(a))
+		}
+		content := "# Written by the updater: the executable moved to a writable overlay.\n" +
+			"[Service]\nExecStart=\nExecStart=" + strings.Join(args, " ") + "\n"
+		changed, err := writeFileIfChanged(u.Overlay.DropIn, content)
+		if err != nil {
+			u.logf("WARNING: cannot write systemd drop-in %s: %v", u.Overlay.DropIn, err)
+		} else if changed {
+			if err := daemonReload(); err != nil {
+				u.logf("WARNING: systemctl daemon-reload failed: %v", err)
+			}
+		}
+	}
+	if u.Overlay.Profile != "" {
+		content := "# Written by the updater: the executable moved to a writable overlay.\n" +
+			"export PATH=" + shellQuote(u.Overlay.Dir) + `:"$PATH"` + "\n"
+		if _, err := writeFileIfChanged(u.Overlay.Profile, content); err != nil {
+			u.logf("WARNING: cannot write %s: %v", u.Overlay.Profile, err)
+		}
+	}
+}
+
+// writeFileIfChanged atomically replaces path with content unless it
+// already holds it, creating its directory, and reports whether it did.
+func writeFileIfChanged(path, content string) (bool, error) {
+	if old, err := os.ReadFile(path); err == nil && string(old) == content {
+		return false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return false, err
+	}
+	if err := writeFileAtomic(path, []byte(content)); err != nil {
+		return false, err
+	}
+	return true, os.Chmod(path, 0o644)
+}
+
+// systemdQuote quotes s as one word of an ExecStart line.
+func systemdQuote(s string) string {
+	s = strings.ReplaceAll(s, "%", "%%")
+	if s != "" && !strings.ContainsAny(s, " \t\"'\\;$") {
+		return s
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", "$$")
+	return `"` + r.Replace(s) + `"`
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Reexec replaces the running process with the executable at path,
+// keeping its arguments and environment, so that a supervisor tracking
+// the process ID sees no restart. It only returns on failure, and always
+// on Windows with errors.ErrUnsupported.
+func Reexec(path string) error {
+	if err := reexec(path, append([]string{path}, os.Args[1:]...), os.Environ()); err != nil {
+		return fmt.Errorf("cannot re-execute %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package selfupdate
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func Test_systemdQuote(t *testing.T) {
+	verify := func(in, want string) {
+		t.Helper()
+		if got := systemdQuote(in); got != want {
+			t.Errorf("systemdQuote(%q) = %s, want %s", in, got, want)
+		}
+	}
+	verify("/opt/bin/app", "/opt/bin/app")
+	verify("-listen=:8080", "-listen=:8080")
+	verify("a b", `"a b"`)
+	verify(`say "hi"`, `"say \"hi\""`)
+	verify("100%", "100%%")
+	verify("$HOME", `"$$HOME"`)
+	verify("", `""`)
+}
+
+func Test_Updater_overlay(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no systemd on Windows")
+	}
+	dir := t.TempDir()
+	ro := filepath.Join(dir, "usr", "bin")
+	os.MkdirAll(ro, 0o755)
+	exe := filepath.Join(ro, "app")
+	os.WriteFile(exe, []byte("old"), 0o755)
+	saved, savedReload := readOnly, daemonReload
+	defer func() { readOnly, daemonReload = saved, savedReload }()
+	readOnly = func(d string) bool { return d == ro }
+	reloads := 0
+	daemonReload = func() error { reloads++; return nil }
+
+	u := &Updater{Path: exe, Overlay: OverlayConfig{
+		Dir:     filepath.Join(dir, "overlay"),
+		DropIn:  filepath.Join(dir, "app.service.d", "overlay.conf"),
+		Profile: filepath.Join(dir, "profile.d", "app.sh"),
+	}}
+	if got := u.OverlayExecutable(); got != "" {
+		t.Errorf("no overlay executable yet, got %s", got)
+	}
+	target, err := u.overlayPath(exe)
+	if err != nil || target != filepath.Join(dir, "overlay", "app") {
+		t.Fatalf("overlayPath = %s, %v", target, err)
+	}
+	os.WriteFile(target, []byte("new"), 0o755)
+	u.pointToOverlay(target)
+	u.pointToOverlay(target)
+	if b, _ := os.ReadFile(u.Overlay.DropIn); !strings.Contains(string(b), "\nExecStart=\nExecStart="+target) {
+		t.Errorf("unexpected drop-in:\n%s", b)
+	}
+	if reloads != 1 {
+		t.Errorf("systemd reloaded %d times, want 1", reloads)
+	}
+	if b, _ := os.ReadFile(u.Overlay.Profile); !strings.Contains(string(b), "export PATH='"+u.Overlay.Dir+"':\"$PATH\"") {
+		t.Errorf("unexpected profile script:\n%s", b)
+	}
+	if got := u.OverlayExecutable(); got != target {
+		t.Errorf("OverlayExecutable = %q, want %s", got, target)
+	}
+
+	// Running from the overlay, updates replace it in place.
+	u.Path = target
+	if got, _ := u.overlayPath(target); got != target {
+		t.Errorf("overlayPath = %s, want %s", got, target)
+	}
+	if got := u.OverlayExecutable(); got != "" {
+		t.Errorf("the overlay executable must not re-execute itself, got %s", got)
+	}
+
+	// Writable directories are updated in place.
+	u.Path = filepath.Join(dir, "app")
+	if got, _ := u.overlayPath(u.Path); got != u.Path {
+		t.Errorf("overlayPath = %s, want %s", got, u.Path)
+	}
+}
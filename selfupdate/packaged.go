@@ -0,0 +1,188 @@
+package selfupdate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Package identifies the package an executable was installed from.
+type Package struct {
+	// Manager is the package manager, e.g. "homebrew" or "scoop".
+	Manager string `json:"manager"`
+	// Name is the package, or empty where the path does not tell.
+	Name string `json:"name,omitempty"`
+}
+
+// packagePath maps a path fragment to the package manager owning the
+// files below it, where the path element that follows names the
+// package unless noName is set.
+type packagePath struct {
+	fragment, manager string
+	noName            bool
+}
+
+var packagePaths = []packagePath{
+	{"/Cellar/", "homebrew", false},
+	{"/Caskroom/", "homebrew", false},
+	{"/nix/store/", "nix", true},
+	{"/snap/", "snap", false},
+}
+
+// windowsPackagePaths are matched case-insensitively against paths with
+// forward slashes.
+var windowsPackagePaths = []packagePath{
+	{"/scoop/apps/", "scoop", false},
+	{"/winget/packages/", "winget", false},
+	{"/chocolatey/lib/", "chocolatey", false},
+	{"/chocolatey/bin/", "chocolatey", true},
+}
+
+// packageAt returns the package of the first of paths matching path.
+func packageAt(path string, paths []packagePath, fold bool) (Package, bool) {
+	match := path
+	if fold {
+		match = strings.ToLower(path)
+	}
+	for _, p := range paths {
+		i := strings.Index(match, p.fragment)
+		if i < 0 {
+			continue
+		}
+		pkg := Package{Manager: p.manager}
+		if !p.noName {
+			pkg.Name, _, _ = strings.Cut(path[i+len(p.fragment):], "/")
+		}
+		if p.manager == "winget" {
+			// "<id>_<source>", e.g. "BurntSushi.ripgrep.MSVC_Microsoft.Winget.Source_8wekyb3d8bbwe".
+			pkg.Name, _, _ = strings.Cut(pkg.Name, "_")
+		}
+		return pkg, true
+	}
+	return Package{}, false
+}
+
+// UpgradeCommand returns the command upgrading p, or nil for managers
+// without one or packages without a name.
+func (p Package) UpgradeCommand() []string {
+	if p.Name == "" {
+		return nil
+	}
+	switch p.Manager {
+	case "homebrew":
+		return []string{"brew", "upgrade", p.Name}
+	case "scoop":
+		return []string{"scoop", "update", p.Name}
+	case "winget":
+		return []string{"winget", "upgrade", "--id", p.Name, "--exact", "--silent",
+			"--accept-source-agreements", "--accept-package-agreements"}
+	case "chocolatey":
+		return []string{"choco", "upgrade", p.Name, "-y"}
+	}
+	return nil
+}
+
+// packageQueries ask a package database whether it owns a file; the
+// path is appended to the command.
+var packageQueries = []struct {
+	manager string
+	command []string
+}{
+	{"dpkg", []string{"dpkg-query", "-S"}},
+	{"rpm", []string{"rpm", "-qf"}},
+}
+
+// queryPackage runs a package query and reports whether it succeeded,
+// i.e. whether the database owns the file. Replaced in tests.
+var queryPackage = func(command []string) bool {
+	if _, err := exec.LookPath(command[0]); err != nil {
+		return false
+	}
+	return exec.Command(command[0], command[1:]...).Run() == nil
+}
+
+// PackageManager returns the name of the package manager that appears to
+// have installed the executable at path, or "" if none did; see
+// DetectPackage.
+func PackageManager(path string) string {
+	return DetectPackage(path).Manager
+}
+
+// DetectPackage returns the package that appears to have installed the
+// executable at path, with an empty Manager if none did. Replacing such
+// a file makes it drift from the package, and the next package upgrade
+// overwrites it again. The manager is
+//
+//   - "homebrew", "nix" or "snap" by where the file resolves to;
+//   - "dpkg" or "rpm" if their database owns it (Name is then empty);
+//   - "system" for any other file below /usr but outside /usr/local;
+//   - on Windows, "scoop", "winget" or "chocolatey" by where the file is.
+func DetectPackage(path string) Package {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	if runtime.GOOS == "windows" {
+		pkg, _ := packageAt(filepath.ToSlash(path), windowsPackagePaths, true)
+		return pkg
+	}
+	if pkg, ok := packageAt(path, packagePaths, false); ok {
+		return pkg
+	}
+	for _, q := range packageQueries {
+		if queryPackage(append(q.command[:len(q.command):len(q.command)], path)) {
+			return Package{Manager: q.manager}
+		}
+	}
+	if strings.HasPrefix(path, "/usr/") && !strings.HasPrefix(path, "/usr/local/") {
+		return Package{Manager: "system"}
+	}
+	return Package{}
+}
+
+// checkPackaged refuses to replace an executable installed by a package
+// manager unless AllowPackaged is set or the package manager itself
+// installs the release (PackageInstall). It returns a
+// *PackageManagedError.
+func (u *Updater) checkPackaged(exePath string) error {
+	if _, viaPackage := u.Applier.(PackageInstall); u.AllowPackaged || viaPackage {
+		return nil
+	}
+	u.packagedMu.Lock()
+	if u.packagedPath != exePath {
+		// Cached, since package queries run external commands.
+		u.packagedPath, u.packagedBy = exePath, DetectPackage(exePath)
+	}
+	pkg := u.packagedBy
+	u.packagedMu.Unlock()
+	if pkg.Manager == "" {
+		return nil
+	}
+	err := &PackageManagedError{Path: exePath, Package: pkg}
+	if cmd := pkg.UpgradeCommand(); cmd != nil {
+		u.logf("%s is managed by %s; not replacing it. Upgrade it with: %s", exePath, pkg.Manager, strings.Join(cmd, " "))
+	} else {
+		u.logf("%s is managed by %s; not replacing it. Upgrade it with the package manager instead.", exePath, pkg.Manager)
+	}
+	return err
+}
+
+// upgradePackage runs the upgrade command of pkg for Update when
+// UpgradeWithPackageManager is set.
+func (u *Updater) upgradePackage(ctx context.Context, pkg Package, info *UpdateInfo) error {
+	cmd := pkg.UpgradeCommand()
+	u.logf("New version %s available (current=%s). Running %s…", info.Remote, info.Current, strings.Join(cmd, " "))
+	stage := time.Now()
+	out, err := runPackageManager(ctx, cmd)
+	info.Durations.Install = time.Since(stage)
+	if err != nil {
+		return fmt.Errorf("%s: %w: %s", cmd[0], err, bytes.TrimSpace(out))
+	}
+	info.Decision = DecisionUpgraded
+	u.audit(ctx, AuditInstall, info.Remote, pkg.Manager+":"+pkg.Name)
+	return nil
+}
@@ -0,0 +1,43 @@
+package selfupdate_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/msmania/updater/selfupdate"
+	"github.com/msmania/updater/selfupdate/selfupdatetest"
+)
+
+func Test_Updater_packageManaged(t *testing.T) {
+	skipIfDisabled(t)
+	if runtime.GOOS == "windows" {
+		t.Skip("no package managers on Windows")
+	}
+	srv := selfupdatetest.NewServer("owner", "app",
+		selfupdatetest.Release{Tag: "v1.1.0", Assets: []selfupdatetest.Asset{{Name: "app-bin", Content: []byte("v1.1")}}},
+	)
+	defer srv.Close()
+	u := newUpdater(t, srv, "v1.0.0")
+	u.Path = filepath.Join(t.TempDir(), "Cellar", "app")
+	os.MkdirAll(filepath.Dir(u.Path), 0o755)
+	os.WriteFile(u.Path, []byte("old"), 0o755)
+
+	info, err := u.Update(context.Background())
+	if !errors.Is(err, selfupdate.ErrPackageManaged) || info.Decision != selfupdate.DecisionPackageManaged {
+		t.Fatalf("expected ErrPackageManaged, got %s: %v", info.Decision, err)
+	}
+	if b, _ := os.ReadFile(u.Path); string(b) != "old" {
+		t.Errorf("packaged executable replaced: %q", b)
+	}
+	u.AllowPackaged = true
+	if _, err := u.Update(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(u.Path); string(b) != "v1.1" {
+		t.Errorf("unexpected content %q", b)
+	}
+}
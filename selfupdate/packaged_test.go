@@ -0,0 +1,110 @@
+package selfupdate
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func Test_PackageManager(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no package managers on Windows")
+	}
+	owned := map[string]string{}
+	defer func(q func([]string) bool) { queryPackage = q }(queryPackage)
+	queryPackage = func(command []string) bool {
+		return owned[command[len(command)-1]] == command[0]
+	}
+	dir := t.TempDir()
+	cellar := filepath.Join(dir, "Cellar", "app", "1.0", "bin", "app")
+	link := filepath.Join(dir, "bin", "app")
+	plain := filepath.Join(dir, "app")
+	for _, p := range []string{cellar, plain} {
+		os.MkdirAll(filepath.Dir(p), 0o755)
+		os.WriteFile(p, nil, 0o755)
+	}
+	os.MkdirAll(filepath.Dir(link), 0o755)
+	if err := os.Symlink(cellar, link); err != nil {
+		t.Fatal(err)
+	}
+	owned[plain] = "rpm"
+	for path, want := range map[string]string{
+		cellar:                   "homebrew",
+		link:                     "homebrew",
+		plain:                    "rpm",
+		"/usr/local/bin/app":     "",
+		"/usr/lib/app/bin/app":   "system",
+		"/nix/store/abc-app/app": "nix",
+		filepath.Join(dir, "x"):  "",
+	} {
+		if got := PackageManager(path); got != want {
+			t.Errorf("%s: expected %q, got %q", path, want, got)
+		}
+	}
+}
+
+func Test_packageAt_windows(t *testing.T) {
+	for path, want := range map[string]Package{
+		`C:\Users\me\scoop\apps\updater\1.2.0\updater.exe`:                                                                      {"scoop", "updater"},
+		`C:\ProgramData\scoop\apps\updater\current\updater.exe`:                                                                 {"scoop", "updater"},
+		`C:\Users\me\AppData\Local\Microsoft\WinGet\Packages\msmania.updater_Microsoft.Winget.Source_8wekyb3d8bbwe\updater.exe`: {"winget", "msmania.updater"},
+		`C:\Program Files\WinGet\Packages\msmania.updater_Microsoft.Winget.Source_8wekyb3d8bbwe\updater.exe`:                    {"winget", "msmania.updater"},
+		`C:\ProgramData\chocolatey\lib\updater\tools\updater.exe`:                                                               {"chocolatey", "updater"},
+		`C:\ProgramData\chocolatey\bin\updater.exe`:                                                                             {"chocolatey", ""},
+		`C:\Program Files\updater\updater.exe`:                                                                                  {},
+	} {
+		if got, _ := packageAt(strings.ReplaceAll(path, `\`, "/"), windowsPackagePaths, true); got != want {
+			t.Errorf("%s: expected %+v, got %+v", path, want, got)
+		}
+	}
+}
+
+func Test_Package_UpgradeCommand(t *testing.T) {
+	for pkg, want := range map[Package]string{
+		{"homebrew", "updater"}: "brew upgrade updater",
+		{"scoop", "updater"}:    "scoop update updater",
+		{"winget", "msmania.updater"}: "winget upgrade --id msmania.updater --exact --silent " +
+			"--accept-source-agreements --accept-package-agreements",
+		{"chocolatey", "updater"}: "choco upgrade updater -y",
+		{"chocolatey", ""}:        "",
+		{"dpkg", ""}:              "",
+		{"system", ""}:            "",
+	} {
+		if got := strings.Join(pkg.UpgradeCommand(), " "); got != want {
+			t.Errorf("%+v: expected %q, got %q", pkg, want, got)
+		}
+	}
+	err := &PackageManagedError{Path: "/opt/homebrew/Cellar/updater/1.0/bin/updater", Package: Package{"homebrew", "updater"}}
+	if !errors.Is(err, ErrPackageManaged) || !strings.HasSuffix(err.Error(), "installed by homebrew; upgrade it with: brew upgrade updater") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func Test_Updater_upgradePackage(t *testing.T) {
+	defer func(r func(context.Context, []string) ([]byte, error)) { runPackageManager = r }(runPackageManager)
+	var ran string
+	runPackageManager = func(ctx context.Context, command []string) ([]byte, error) {
+		ran = strings.Join(command, " ")
+		if command[2] == "broken" {
+			return []byte("Couldn't find manifest for 'broken'.\n"), errors.New("exit status 1")
+		}
+		return nil, nil
+	}
+	u := &Updater{}
+	info := &UpdateInfo{Current: "v1.0.0", Remote: "v1.1.0"}
+	if err := u.upgradePackage(context.Background(), Package{"scoop", "updater"}, info); err != nil || info.Decision != DecisionUpgraded {
+		t.Errorf("expected an upgrade, got %s (%v)", info.Decision, err)
+	}
+	if ran != "scoop update updater" {
+		t.Errorf("unexpected command %q", ran)
+	}
+	info = &UpdateInfo{Current: "v1.0.0", Remote: "v1.1.0"}
+	err := u.upgradePackage(context.Background(), Package{"scoop", "broken"}, info)
+	if err == nil || err.Error() != "scoop: exit status 1: Couldn't find manifest for 'broken'." || info.Decision != "" {
+		t.Errorf("expected a failure, got %s (%v)", info.Decision, err)
+	}
+}
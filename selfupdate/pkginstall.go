@@ -0,0 +1,73 @@
+package selfupdate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// PackageInstall installs releases published as .deb or .rpm assets with
+// the system package manager instead of replacing the executable, so an
+// application installed from a package keeps being owned by it while
+// still following the version checks, policies and verification of the
+// Updater. Select the package asset with Updater.AssetRegexp. The target
+// is ignored: the package decides where its files go. Packaged
+// executables are not refused with ErrPackageManaged under this Applier.
+type PackageInstall struct {
+	// Command installs a package whose path is appended, e.g.
+	// {"apt-get", "install", "-y"} to also pull in new dependencies. It
+	// defaults to "dpkg -i" for .deb and "rpm -U" for .rpm assets.
+	Command []string
+}
+
+// packageCommands are the default install commands by asset suffix.
+var packageCommands = map[string][]string{
+	".deb": {"dpkg", "-i"},
+	".rpm": {"rpm", "-U"},
+}
+
+// packageSuffix returns the package type of an asset name, ".deb" or
+// ".rpm", or "".
+func packageSuffix(name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	if _, ok := packageCommands[ext]; ok {
+		return ext
+	}
+	return ""
+}
+
+// runPackageManager runs an install command. Replaced in tests.
+var runPackageManager = func(ctx context.Context, command []string) ([]byte, error) {
+	return exec.CommandContext(ctx, command[0], command[1:]...).CombinedOutput()
+}
+
+func (p PackageInstall) Apply(ctx context.Context, a Artifact, target string) error {
+	defer os.Remove(a.Path)
+	ext := packageSuffix(a.Name)
+	if ext == "" {
+		return fmt.Errorf("%s is not a .deb or .rpm package", a.Name)
+	}
+	command := p.Command
+	if len(command) == 0 {
+		command = packageCommands[ext]
+	}
+	// apt-get only treats arguments as files if they look like paths to
+	// a package, and rpm and dpkg report the file name in their errors.
+	pkg, err := filepath.Abs(a.Path + ext)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(a.Path, pkg); err != nil {
+		return err
+	}
+	defer os.Remove(pkg)
+	args := append(command[:len(command):len(command)], pkg)
+	if out, err := runPackageManager(ctx, args); err != nil {
+		return fmt.Errorf("%s: %w: %s", command[0], err, bytes.TrimSpace(out))
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package selfupdate
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_PackageInstall(t *testing.T) {
+	defer func(r func(context.Context, []string) ([]byte, error)) { runPackageManager = r }(runPackageManager)
+	var ran []string
+	var content string
+	fail := false
+	runPackageManager = func(ctx context.Context, command []string) ([]byte, error) {
+		ran = command
+		b, _ := os.ReadFile(command[len(command)-1])
+		content = string(b)
+		if fail {
+			return []byte("dependency problems\n"), errors.New("exit status 1")
+		}
+		return nil, nil
+	}
+	dir := t.TempDir()
+	verify := func(p PackageInstall, name, wantCommand, wantErr string) {
+		t.Helper()
+		staged := filepath.Join(dir, "app.new")
+		os.WriteFile(staged, []byte("package"), 0o644)
+		ran, content = nil, ""
+		err := p.Apply(context.Background(), Artifact{Path: staged, Name: name, Tag: "v1.1.0"}, filepath.Join(dir, "app"))
+		if wantErr == "" && err != nil || wantErr != "" && (err == nil || !strings.Contains(err.Error(), wantErr)) {
+			t.Errorf("%s: expected error %q, got %v", name, wantErr, err)
+		}
+		if got := strings.Join(ran, " "); got != wantCommand {
+			t.Errorf("%s: expected %q, got %q", name, wantCommand, got)
+		}
+		if ran != nil && content != "package" {
+			t.Errorf("%s: the command did not see the package, got %q", name, content)
+		}
+		if left, _ := filepath.Glob(filepath.Join(dir, "app.new*")); len(left) != 0 {
+			t.Errorf("%s: left behind %v", name, left)
+		}
+	}
+	verify(PackageInstall{}, "app_1.1.0_amd64.deb", "dpkg -i "+filepath.Join(dir, "app.new.deb"), "")
+	verify(PackageInstall{}, "app-1.1.0.x86_64.RPM", "rpm -U "+filepath.Join(dir, "app.new.rpm"), "")
+	verify(PackageInstall{Command: []string{"apt-get", "install", "-y"}}, "app.deb",
+		"apt-get install -y "+filepath.Join(dir, "app.new.deb"), "")
+	verify(PackageInstall{}, "app.tar.gz", "", "app.tar.gz is not a .deb or .rpm package")
+	fail = true
+	verify(PackageInstall{}, "app.deb", "dpkg -i "+filepath.Join(dir, "app.new.deb"), "dpkg: exit status 1: dependency problems")
+}
+
+func Test_Updater_checkPackaged_packageInstall(t *testing.T) {
+	u := &Updater{Applier: PackageInstall{}}
+	u.packagedPath, u.packagedBy = "/usr/bin/app", Package{Manager: "dpkg"}
+	if err := u.checkPackaged("/usr/bin/app"); err != nil {
+		t.Errorf("PackageInstall should install packaged executables, got %v", err)
+	}
+	u.Applier = nil
+	if err := u.checkPackaged("/usr/bin/app"); !errors.Is(err, ErrPackageManaged) {
+		t.Errorf("expected ErrPackageManaged, got %v", err)
+	}
+}
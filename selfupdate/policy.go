@@ -0,0 +1,267 @@
+package selfupdate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UpdatePolicy decides whether automatic updates install a release.
+// Updates requested by a person, through the CLI, the HTTP handler or
+// gRPC, always may. Automatic ones are those whose audit source (see
+// WithAuditSource) is "startup", "sighup" or "schedule":
+//
+//   - PolicyAuto installs them (the default);
+//   - PolicyNotify and PolicyManual defer them with ErrDeferred, the
+//     former meant for printing a notice instead (see LatestNotice);
+//   - PolicyScheduled installs them inside MaintenanceWindows only.
+type UpdatePolicy string
+
+const (
+	PolicyAuto      UpdatePolicy = "auto"
+	PolicyNotify    UpdatePolicy = "notify"
+	PolicyManual    UpdatePolicy = "manual"
+	PolicyScheduled UpdatePolicy = "scheduled"
+)
+
+// ParseUpdatePolicy parses a policy name; "" is PolicyAuto.
+func ParseUpdatePolicy(s string) (UpdatePolicy, error) {
+	switch p := UpdatePolicy(s); p {
+	case "":
+		return PolicyAuto, nil
+	case PolicyAuto, PolicyNotify, PolicyManual, PolicyScheduled:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown update policy %q (want auto, notify, manual or scheduled)", s)
+}
+
+// automaticSource reports whether an update from source was not
+// requested by a person.
+func automaticSource(source string) bool {
+	switch source {
+	case "startup", "sighup", "schedule":
+		return true
+	}
+	return false
+}
+
+// deferred returns an ErrDeferred error if u.Policy holds back the update
+// requested by ctx at now, or while the readiness check of the running
+// version is pending (see CanaryConfig).
+func (u *Updater) deferred(ctx context.Context, now time.Time) error {
+	if !automaticSource(auditSource(ctx)) {
+		return nil
+	}
+	if u.canaryPending.Load() {
+		return fmt.Errorf("%w: %s awaits its readiness check", ErrDeferred, u.Build.Version)
+	}
+	switch u.Policy {
+	case "", PolicyAuto:
+		return nil
+	case PolicyScheduled:
+		if inWindows(u.MaintenanceWindows, now) {
+			return nil
+		}
+		return fmt.Errorf("%w: outside the maintenance windows", ErrDeferred)
+	}
+	return fmt.Errorf("%w: update policy %s", ErrDeferred, u.Policy)
+}
+
+// checkReleaseAge returns a ReleaseAgeError if rel was published less
+// than u.MinReleaseAge ago. Releases without a publication time pass.
+func (u *Updater) checkReleaseAge(rel *ghRelease) error {
+	if u.MinReleaseAge <= 0 || rel.PublishedAt.IsZero() {
+		return nil
+	}
+	until := rel.PublishedAt.Add(u.MinReleaseAge)
+	if !u.clock().Now().Before(until) {
+		return nil
+	}
+	return &ReleaseAgeError{Tag: rel.TagName, PublishedAt: rel.PublishedAt.UTC(), InstallableAt: until.UTC()}
+}
+
+// MaintenanceWindow is a daily period, in the local time zone, during
+// which PolicyScheduled installs releases. End before Start spans
+// midnight, belonging to the day it starts.
+type MaintenanceWindow struct {
+	// Days lists the days the window opens; empty means every day.
+	Days []time.Weekday
+	// Start and End are offsets from midnight.
+	Start, End time.Duration
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseMaintenanceWindow parses "[DAYS ]HH:MM-HH:MM", where DAYS is a
+// comma-separated list of days or day ranges, e.g. "Mon-Fri 02:00-04:00",
+// "Sat,Sun 22:00-06:00" or "03:00-05:00".
+func ParseMaintenanceWindow(s string) (MaintenanceWindow, error) {
+	var w MaintenanceWindow
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 2 {
+		return w, fmt.Errorf("invalid maintenance window %q", s)
+	}
+	if len(fields) == 2 {
+		for _, part := range strings.Split(fields[0], ",") {
+			from, to, isRange := strings.Cut(strings.ToLower(part), "-")
+			first, ok1 := weekdays[from]
+			last, ok2 := weekdays[to]
+			if !isRange {
+				last, ok2 = first, ok1
+			}
+			if !ok1 || !ok2 {
+				return w, fmt.Errorf("invalid days %q in maintenance window %q", fields[0], s)
+			}
+			for d := first; ; d = (d + 1) % 7 {
+				if !slices.Contains(w.Days, d) {
+					w.Days = append(w.Days, d)
+				}
+				if d == last {
+					break
+				}
+			}
+		}
+	}
+	start, end, ok := strings.Cut(fields[len(fields)-1], "-")
+	var err1, err2 error
+	w.Start, err1 = parseClock(start)
+	w.End, err2 = parseClock(end)
+	if !ok || err1 != nil || err2 != nil || w.Start == w.End {
+		return w, fmt.Errorf("invalid times in maintenance window %q", s)
+	}
+	return w, nil
+}
+
+// parseClock parses "HH:MM" as an offset from midnight.
+func parseClock(s string) (time.Duration, error) {
+	h, m, ok := strings.Cut(s, ":")
+	hours, err1 := strconv.Atoi(h)
+	minutes, err2 := strconv.Atoi(m)
+	if !ok || err1 != nil || err2 != nil || hours < 0 || hours > 24 || minutes < 0 || minutes > 59 ||
+		hours == 24 && minutes != 0 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute, nil
+}
+
+func (w MaintenanceWindow) onDay(d time.Weekday) bool {
+	return len(w.Days) == 0 || slices.Contains(w.Days, d)
+}
+
+// opening returns when the window opens on the day of t.
+func (w MaintenanceWindow) opening(t time.Time, days int) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d+days, 0, 0, 0, 0, t.Location()).Add(w.Start)
+}
+
+// Contains reports whether t falls into the window.
+func (w MaintenanceWindow) Contains(t time.Time) bool {
+	for days := -1; days <= 0; days++ {
+		open := w.opening(t, days)
+		if w.onDay(open.Weekday()) && !t.Before(open) && t.Before(open.Add(w.length())) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w MaintenanceWindow) length() time.Duration {
+	if w.End > w.Start {
+		return w.End - w.Start
+	}
+	return w.End + 24*time.Hour - w.Start
+}
+
+// Next returns t if it falls into the window, or else when the window
+// opens next.
+func (w MaintenanceWindow) Next(t time.Time) time.Time {
+	if w.Contains(t) {
+		return t
+	}
+	for days := 0; days <= 7; days++ {
+		if open := w.opening(t, days); w.onDay(open.Weekday()) && open.After(t) {
+			return open
+		}
+	}
+	return time.Time{}
+}
+
+func (w MaintenanceWindow) String() string {
+	var days []string
+	for _, d := range w.Days {
+		days = append(days, d.String()[:3])
+	}
+	clock := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+	}
+	s := clock(w.Start) + "-" + clock(w.End)
+	if len(days) > 0 {
+		s = strings.Join(days, ",") + " " + s
+	}
+	return s
+}
+
+func inWindows(windows []MaintenanceWindow, t time.Time) bool {
+	return slices.ContainsFunc(windows, func(w MaintenanceWindow) bool { return w.Contains(t) })
+}
+
+// nextWindow returns t if it falls into a window, or else when the first
+// window opens next; zero without windows.
+func nextWindow(windows []MaintenanceWindow, t time.Time) time.Time {
+	var next time.Time
+	for _, w := range windows {
+		if n := w.Next(t); !n.IsZero() && (next.IsZero() || n.Before(next)) {
+			next = n
+		}
+	}
+	return next
+}
+
+// scheduledRecheck is how long RunScheduled waits after a check before
+// checking again, within the same window or at the next.
+const scheduledRecheck = time.Hour
+
+// RunScheduled checks for a release whenever a maintenance window opens,
+// and hourly while it stays open, until ctx is done or a release was
+// installed, after which it calls AfterUpgrade. A rate limit postpones
+// the next check until it ends. It returns at once unless Policy is
+// PolicyScheduled with MaintenanceWindows.
+func (u *Updater) RunScheduled(ctx context.Context) {
+	if u.Policy != PolicyScheduled || !Enabled() {
+		return
+	}
+	ctx = WithAuditSource(ctx, "schedule")
+	for {
+		window := nextWindow(u.MaintenanceWindows, u.clock().Now())
+		if window.IsZero() || !u.sleepUntil(ctx, window) {
+			return
+		}
+		info, err := u.Update(ctx)
+		next := u.clock().Now().Add(scheduledRecheck)
+		var limited *RateLimitError
+		switch {
+		case info.Decision == DecisionUpgraded:
+			if u.AfterUpgrade != nil {
+				u.AfterUpgrade()
+			}
+			return
+		case errors.As(err, &limited):
+			if limited.Reset.After(next) {
+				next = limited.Reset
+			}
+			u.logf("Scheduled update postponed: %v", err)
+		case err != nil && !errors.Is(err, ErrAlreadyLatest) && !errors.Is(err, ErrNoRelease) && ctx.Err() == nil:
+			u.logf("Scheduled update failed: %v", err)
+		}
+		if !u.sleepUntil(ctx, next) {
+			return
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package selfupdate_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/msmania/updater/selfupdate"
+	"github.com/msmania/updater/selfupdate/selfupdatetest"
+)
+
+func Test_Updater_updatePolicy(t *testing.T) {
+	skipIfDisabled(t)
+	srv := selfupdatetest.NewServer("owner", "app",
+		selfupdatetest.Release{Tag: "v1.1.0", Assets: []selfupdatetest.Asset{{Name: "app-bin", Content: []byte("v1.1")}}},
+	)
+	defer srv.Close()
+	u := newUpdater(t, srv, "v1.0.0")
+	u.Policy = selfupdate.PolicyManual
+	info, err := u.Update(selfupdate.WithAuditSource(context.Background(), "startup"))
+	if !errors.Is(err, selfupdate.ErrDeferred) || info.Decision != selfupdate.DecisionDeferred || info.Remote != "v1.1.0" {
+		t.Fatalf("expected a deferred update of v1.1.0, got %s %s: %v", info.Decision, info.Remote, err)
+	}
+	if b, _ := os.ReadFile(u.Path); string(b) != "old" {
+		t.Errorf("deferred update installed: %q", b)
+	}
+	info, err = u.Update(selfupdate.WithAuditSource(context.Background(), "cli"))
+	if err != nil || info.Decision != selfupdate.DecisionUpgraded {
+		t.Fatalf("expected the CLI to install, got %s: %v", info.Decision, err)
+	}
+	if b, _ := os.ReadFile(u.Path); string(b) != "v1.1" {
+		t.Errorf("unexpected content %q", b)
+	}
+}
+
+func Test_Updater_minReleaseAge(t *testing.T) {
+	skipIfDisabled(t)
+	published := time.Date(2026, time.March, 2, 12, 0, 0, 0, time.UTC)
+	srv := selfupdatetest.NewServer("owner", "app",
+		selfupdatetest.Release{Tag: "v1.1.0", PublishedAt: published, Assets: []selfupdatetest.Asset{{Name: "app-bin", Content: []byte("v1.1")}}},
+	)
+	defer srv.Close()
+	clock := selfupdatetest.NewClock(published.Add(time.Hour))
+	u := newUpdater(t, srv, "v1.0.0")
+	u.Clock = clock
+	u.MinReleaseAge = 24 * time.Hour
+	info, err := u.Update(context.Background())
+	var age *selfupdate.ReleaseAgeError
+	if !errors.As(err, &age) || !errors.Is(err, selfupdate.ErrDeferred) || info.Decision != selfupdate.DecisionDeferred {
+		t.Fatalf("expected the release to soak, got %s: %v", info.Decision, err)
+	}
+	if want := published.Add(24 * time.Hour); !age.InstallableAt.Equal(want) || age.Tag != "v1.1.0" {
+		t.Errorf("unexpected soak %+v", age)
+	}
+	st := u.Status()
+	if st.Result != selfupdate.ResultDeferred || st.PendingRelease == nil || st.PendingRelease.Tag != "v1.1.0" {
+		t.Errorf("unexpected status %+v", st)
+	}
+	if b, _ := os.ReadFile(u.Path); string(b) != "old" {
+		t.Errorf("soaking release installed: %q", b)
+	}
+
+	clock.Advance(23 * time.Hour)
+	if info, err := u.Update(context.Background()); err != nil || info.Decision != selfupdate.DecisionUpgraded {
+		t.Fatalf("expected the aged release to install, got %s: %v", info.Decision, err)
+	}
+	if st := u.Status(); st.PendingRelease != nil {
+		t.Error("pending release kept after the install")
+	}
+}
+
+func Test_Updater_scheduled(t *testing.T) {
+	skipIfDisabled(t)
+	srv := selfupdatetest.NewServer("owner", "app",
+		selfupdatetest.Release{Tag: "v1.0.0", Assets: []selfupdatetest.Asset{{Name: "app-bin", Content: []byte("v1")}}},
+	)
+	defer srv.Close()
+	start := time.Date(2026, time.January, 5, 10, 0, 0, 0, time.Local)
+	clock := selfupdatetest.NewClock(start)
+	u := newUpdater(t, srv, "v1.0.0")
+	u.Clock = clock
+	u.Policy = selfupdate.PolicyScheduled
+	u.MaintenanceWindows = []selfupdate.MaintenanceWindow{{Start: 2 * time.Hour, End: 4 * time.Hour}}
+	upgradedAt := make(chan time.Time, 1)
+	u.AfterUpgrade = func() { upgradedAt <- clock.Now() }
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		u.RunScheduled(ctx)
+	}()
+
+	// Nothing newer when the window opens at 02:00 the next day.
+	clock.BlockUntil(1)
+	clock.Advance(16 * time.Hour)
+	// The hourly recheck within the window finds the new release.
+	clock.BlockUntil(1)
+	srv.AddRelease(selfupdatetest.Release{Tag: "v1.1.0", Assets: []selfupdatetest.Asset{{Name: "app-bin", Content: []byte("v1.1")}}})
+	clock.Advance(time.Hour)
+	select {
+	case at := <-upgradedAt:
+		if want := start.Add(17 * time.Hour); !at.Equal(want) {
+			t.Errorf("upgraded at %s, want %s", at, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no upgrade in the maintenance window")
+	}
+	<-done
+	if b, _ := os.ReadFile(u.Path); string(b) != "v1.1" {
+		t.Errorf("unexpected content %q", b)
+	}
+}
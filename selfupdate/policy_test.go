@@ -0,0 +1,107 @@
+package selfupdate
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func Test_ParseMaintenanceWindow(t *testing.T) {
+	for s, want := range map[string]string{
+		"Mon-Fri 02:00-04:00": "Mon,Tue,Wed,Thu,Fri 02:00-04:00",
+		"sat,Sun 22:00-06:00": "Sat,Sun 22:00-06:00",
+		"Fri-Mon 01:30-02:00": "Fri,Sat,Sun,Mon 01:30-02:00",
+		"03:00-05:00":         "03:00-05:00",
+		"00:00-24:00":         "00:00-24:00",
+	} {
+		w, err := ParseMaintenanceWindow(s)
+		if err != nil || w.String() != want {
+			t.Errorf("%s: expected %s, got %s (%v)", s, want, w, err)
+		}
+	}
+	for _, s := range []string{"", "Mon", "Mon 02:00", "Funday 02:00-03:00", "02:00-02:00", "25:00-01:00", "1:60-2:00", "Mon Tue 01:00-02:00"} {
+		if _, err := ParseMaintenanceWindow(s); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+}
+
+func Test_MaintenanceWindow(t *testing.T) {
+	at := func(day int, clock string) time.Time {
+		d, _ := parseClock(clock)
+		// 2024-01-01 is a Monday.
+		return time.Date(2024, 1, day, 0, 0, 0, 0, time.Local).Add(d)
+	}
+	weekend, _ := ParseMaintenanceWindow("Sat,Sun 22:00-06:00")
+	daily, _ := ParseMaintenanceWindow("03:00-05:00")
+	for _, tc := range []struct {
+		w    MaintenanceWindow
+		t    time.Time
+		in   bool
+		next time.Time
+	}{
+		{daily, at(1, "03:00"), true, at(1, "03:00")},
+		{daily, at(1, "05:00"), false, at(2, "03:00")},
+		{daily, at(1, "01:00"), false, at(1, "03:00")},
+		{weekend, at(6, "23:00"), true, at(6, "23:00")},
+		{weekend, at(7, "05:59"), true, at(7, "05:59")}, // Saturday's window
+		{weekend, at(8, "05:00"), true, at(8, "05:00")}, // Sunday's window, on Monday
+		{weekend, at(8, "22:30"), false, at(13, "22:00")},
+		{weekend, at(6, "05:00"), false, at(6, "22:00")},
+	} {
+		if got := tc.w.Contains(tc.t); got != tc.in {
+			t.Errorf("%s at %s: expected %v", tc.w, tc.t.Format(time.ANSIC), tc.in)
+		}
+		if got := tc.w.Next(tc.t); !got.Equal(tc.next) {
+			t.Errorf("%s after %s: expected %s, got %s", tc.w, tc.t.Format(time.ANSIC),
+				tc.next.Format(time.ANSIC), got.Format(time.ANSIC))
+		}
+	}
+	if next := nextWindow([]MaintenanceWindow{weekend, daily}, at(1, "06:00")); !next.Equal(at(2, "03:00")) {
+		t.Errorf("unexpected next window %s", next.Format(time.ANSIC))
+	}
+}
+
+func Test_Updater_deferred(t *testing.T) {
+	night, _ := ParseMaintenanceWindow("02:00-04:00")
+	noon := time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local)
+	for _, tc := range []struct {
+		policy   UpdatePolicy
+		source   string
+		now      time.Time
+		deferred bool
+	}{
+		{"", "startup", noon, false},
+		{PolicyAuto, "sighup", noon, false},
+		{PolicyNotify, "startup", noon, true},
+		{PolicyManual, "sighup", noon, true},
+		{PolicyManual, "cli", noon, false},
+		{PolicyManual, "http", noon, false},
+		{PolicyScheduled, "schedule", noon, true},
+		{PolicyScheduled, "schedule", noon.Add(-9 * time.Hour), false},
+		{PolicyScheduled, "grpc", noon, false},
+	} {
+		u := &Updater{Policy: tc.policy, MaintenanceWindows: []MaintenanceWindow{night}}
+		err := u.deferred(WithAuditSource(context.Background(), tc.source), tc.now)
+		if errors.Is(err, ErrDeferred) != tc.deferred {
+			t.Errorf("%s policy, %s at %s: unexpected result %v", tc.policy, tc.source, tc.now.Format(time.Kitchen), err)
+		}
+	}
+	u := &Updater{}
+	u.canaryPending.Store(true)
+	if err := u.deferred(WithAuditSource(context.Background(), "startup"), noon); !errors.Is(err, ErrDeferred) {
+		t.Error("automatic updates must wait for a pending readiness check, got", err)
+	}
+	if err := u.deferred(WithAuditSource(context.Background(), "cli"), noon); err != nil {
+		t.Error("requested updates need not wait, got", err)
+	}
+	for s, want := range map[string]UpdatePolicy{"": PolicyAuto, "notify": PolicyNotify, "scheduled": PolicyScheduled} {
+		if p, err := ParseUpdatePolicy(s); p != want || err != nil {
+			t.Errorf("%q: expected %s, got %s (%v)", s, want, p, err)
+		}
+	}
+	if _, err := ParseUpdatePolicy("never"); err == nil {
+		t.Error("expected error for an unknown policy")
+	}
+}
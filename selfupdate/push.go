@@ -0,0 +1,207 @@
+package selfupdate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RunMetrics are the metrics of one check or update. Short-lived runs,
+// such as the check and update commands run from cron, exit before they
+// can be scraped, so they push them with a MetricsPusher instead.
+type RunMetrics struct {
+	Command  string // e.g. "check" or "update"
+	Current  string
+	Latest   string
+	Decision Decision
+	// Success is false if the run failed, as opposed to deciding not to
+	// update.
+	Success         bool
+	Duration        time.Duration
+	BytesDownloaded int64
+	Time            time.Time
+}
+
+// NewRunMetrics returns the metrics of a run of command that returned
+// info and err.
+func NewRunMetrics(command string, info *UpdateInfo, err error) RunMetrics {
+	return RunMetrics{
+		Command:         command,
+		Current:         info.Current,
+		Latest:          info.Remote,
+		Decision:        info.Decision,
+		Success:         err == nil && info.Decision != DecisionFailed,
+		Duration:        info.Durations.Total,
+		BytesDownloaded: info.BytesDownloaded,
+		Time:            time.Now(),
+	}
+}
+
+// labels returns the labels of every metric of m.
+func (m RunMetrics) labels() [][2]string {
+	return [][2]string{
+		{"command", m.Command}, {"decision", string(m.Decision)},
+		{"current", m.Current}, {"latest", m.Latest},
+	}
+}
+
+type runGauge struct {
+	name  string
+	value float64
+}
+
+// gauges returns the metrics of m.
+func (m RunMetrics) gauges() []runGauge {
+	success := 0.0
+	if m.Success {
+		success = 1
+	}
+	return []runGauge{
+		{"updater_run_success", success},
+		{"updater_run_duration_seconds", m.Duration.Seconds()},
+		{"updater_run_bytes_downloaded", float64(m.BytesDownloaded)},
+		{"updater_run_timestamp_seconds", float64(m.Time.UnixNano()) / 1e9},
+	}
+}
+
+// MetricsPusher sends the metrics of a run to a monitoring system.
+type MetricsPusher interface {
+	Push(ctx context.Context, m RunMetrics) error
+}
+
+// Pushgateway pushes RunMetrics to a Prometheus Pushgateway. Each push
+// replaces the group of Job and the host name, so the gateway holds the
+// last run of every host.
+type Pushgateway struct {
+	URL    string // base URL, e.g. "http://pushgateway:9091"
+	Job    string // defaults to "updater"
+	Client *http.Client
+}
+
+func (p *Pushgateway) Push(ctx context.Context, m RunMetrics) error {
+	job := p.Job
+	if job == "" {
+		job = "updater"
+	}
+	host, _ := os.Hostname()
+	var body bytes.Buffer
+	var labels []string
+	for _, l := range m.labels() {
+		labels = append(labels, l[0]+`="`+escapeLabel(l[1])+`"`)
+	}
+	for _, g := range m.gauges() {
+		fmt.Fprintf(&body, "# TYPE %s gauge\n%s{%s} %s\n", g.name, g.name, strings.Join(labels, ","),
+			strconv.FormatFloat(g.value, 'g', -1, 64))
+	}
+	target := strings.TrimSuffix(p.URL, "/") + "/metrics/job/" + url.PathEscape(job) +
+		"/instance/" + url.PathEscape(host)
+	return pushMetrics(ctx, p.Client, http.MethodPut, target, "text/plain; version=0.0.4", body.Bytes())
+}
+
+// escapeLabel escapes a label value of the Prometheus text format.
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// OTLPMetrics pushes RunMetrics as gauges to an OpenTelemetry collector
+// using the OTLP/HTTP JSON encoding.
+type OTLPMetrics struct {
+	// Client sends the metrics; NewOTLPMetrics sets one with a 10s
+	// timeout.
+	Client *http.Client
+
+	endpoint string
+	resource []otlpKeyValue
+}
+
+// NewOTLPMetrics creates a pusher sending to endpoint, the collector
+// base URL (e.g. "http://localhost:4318"), with the resource attributes
+// of NewOTLPTracer.
+func NewOTLPMetrics(endpoint, serviceName string, build BuildInfo) *OTLPMetrics {
+	t := NewOTLPTracer(endpoint, serviceName, build)
+	return &OTLPMetrics{
+		endpoint: strings.TrimSuffix(endpoint, "/") + "/v1/metrics",
+		resource: t.resource,
+		Client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (o *OTLPMetrics) Push(ctx context.Context, m RunMetrics) error {
+	var attrs []otlpKeyValue
+	for _, l := range m.labels() {
+		attrs = append(attrs, otlpAttr("updater."+l[0], l[1]))
+	}
+	now := strconv.FormatInt(m.Time.UnixNano(), 10)
+	var metrics []otlpMetric
+	for _, g := range m.gauges() {
+		metrics = append(metrics, otlpMetric{Name: g.name, Gauge: otlpGauge{DataPoints: []otlpDataPoint{{
+			Attributes: attrs, TimeUnixNano: now, AsDouble: g.value,
+		}}}})
+	}
+	body, err := json.Marshal(otlpMetricsRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource: otlpResource{Attributes: o.resource},
+		ScopeMetrics: []otlpScopeMetrics{{
+			Scope:   otlpScope{Name: "github.com/msmania/updater/selfupdate"},
+			Metrics: metrics,
+		}},
+	}}})
+	if err != nil {
+		return err
+	}
+	return pushMetrics(ctx, o.Client, http.MethodPost, o.endpoint, "application/json", body)
+}
+
+// pushMetrics sends body to target, expecting a 2xx response.
+func pushMetrics(ctx context.Context, client *http.Client, method, target, contentType string, body []byte) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s returned %d", target, resp.StatusCode)
+	}
+	return nil
+}
+
+// OTLP/JSON metric structures (subset).
+type (
+	otlpMetricsRequest struct {
+		ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+	}
+	otlpResourceMetrics struct {
+		Resource     otlpResource       `json:"resource"`
+		ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+	}
+	otlpScopeMetrics struct {
+		Scope   otlpScope    `json:"scope"`
+		Metrics []otlpMetric `json:"metrics"`
+	}
+	otlpMetric struct {
+		Name  string    `json:"name"`
+		Gauge otlpGauge `json:"gauge"`
+	}
+	otlpGauge struct {
+		DataPoints []otlpDataPoint `json:"dataPoints"`
+	}
+	otlpDataPoint struct {
+		Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+		TimeUnixNano string         `json:"timeUnixNano"`
+		AsDouble     float64        `json:"asDouble"`
+	}
+)
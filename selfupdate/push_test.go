@@ -0,0 +1,84 @@
+package selfupdate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Pushgateway(t *testing.T) {
+	var method, path, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		method, path, body = r.Method, r.URL.EscapedPath(), string(b)
+	}))
+	defer srv.Close()
+
+	info := &UpdateInfo{Current: "v1.0.0", Remote: `v1.1.0"`, Decision: DecisionUpgraded,
+		BytesDownloaded: 2048, Durations: Durations{Total: 1500 * time.Millisecond}}
+	m := NewRunMetrics("update", info, nil)
+	m.Time = time.Unix(1700000000, 0)
+	p := &Pushgateway{URL: srv.URL + "/", Job: "cron/updater"}
+	if err := p.Push(context.Background(), m); err != nil {
+		t.Fatal(err)
+	}
+	host, _ := os.Hostname()
+	if method != "PUT" || path != "/metrics/job/cron%2Fupdater/instance/"+host {
+		t.Errorf("pushed with %s %s", method, path)
+	}
+	labels := `{command="update",decision="upgraded",current="v1.0.0",latest="v1.1.0\""}`
+	for _, want := range []string{
+		"# TYPE updater_run_success gauge\nupdater_run_success" + labels + " 1\n",
+		"updater_run_duration_seconds" + labels + " 1.5\n",
+		"updater_run_bytes_downloaded" + labels + " 2048\n",
+		"updater_run_timestamp_seconds" + labels + " 1.7e+09\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q in\n%s", want, body)
+		}
+	}
+
+	m = NewRunMetrics("check", &UpdateInfo{Decision: DecisionFailed}, errors.New("boom"))
+	if m.Success {
+		t.Error("a failed run must not count as a success")
+	}
+	srv.Close()
+	if err := p.Push(context.Background(), m); err == nil {
+		t.Error("expected an error from an unreachable gateway")
+	}
+}
+
+func Test_OTLPMetrics(t *testing.T) {
+	var got otlpMetricsRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/metrics" {
+			t.Error("unexpected path " + r.URL.Path)
+		}
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer srv.Close()
+
+	p := NewOTLPMetrics(srv.URL, "test", BuildInfo{Version: "v1.0.0"})
+	info := &UpdateInfo{Current: "v1.0.0", Decision: DecisionUpToDate}
+	if err := p.Push(context.Background(), NewRunMetrics("check", info, nil)); err != nil {
+		t.Fatal(err)
+	}
+	metrics := got.ResourceMetrics[0].ScopeMetrics[0].Metrics
+	if len(metrics) != 4 || metrics[0].Name != "updater_run_success" {
+		t.Fatalf("unexpected metrics %+v", metrics)
+	}
+	dp := metrics[0].Gauge.DataPoints[0]
+	if dp.AsDouble != 1 || dp.Attributes[0].Key != "updater.command" || *dp.Attributes[0].Value.StringValue != "check" {
+		t.Errorf("unexpected data point %+v", dp)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package selfupdate
+
+import "errors"
+
+func reexec(path string, argv, env []string) error {
+	return errors.ErrUnsupported
+}
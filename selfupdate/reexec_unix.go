@@ -0,0 +1,9 @@
+//go:build unix
+
+package selfupdate
+
+import "syscall"
+
+func reexec(path string, argv, env []string) error {
+	return syscall.Exec(path, argv, env)
+}
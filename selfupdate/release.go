@@ -0,0 +1,109 @@
+package selfupdate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Release is a release as described by the GitHub API, for policies and
+// tools built on the library; see Updater.Releases and
+// Updater.AcceptRelease.
+type Release struct {
+	Tag         string         `json:"tag"`
+	Name        string         `json:"name,omitempty"`
+	Body        string         `json:"body,omitempty"`
+	Draft       bool           `json:"draft"`
+	Prerelease  bool           `json:"prerelease"`
+	CreatedAt   time.Time      `json:"created_at,omitzero"`
+	PublishedAt time.Time      `json:"published_at,omitzero"`
+	Author      string         `json:"author,omitempty"` // login
+	URL         string         `json:"url,omitempty"`    // the release page
+	Assets      []ReleaseAsset `json:"assets"`
+	// Raw is the JSON object the API returned, for fields not listed
+	// above.
+	Raw json.RawMessage `json:"-"`
+}
+
+// ReleaseAsset is a file attached to a Release.
+type ReleaseAsset struct {
+	Name          string    `json:"name"`
+	Size          int64     `json:"size"`
+	ContentType   string    `json:"content_type,omitempty"`
+	Digest        string    `json:"digest,omitempty"`
+	DownloadURL   string    `json:"download_url"`
+	DownloadCount int       `json:"download_count"`
+	UpdatedAt     time.Time `json:"updated_at,omitzero"`
+}
+
+// UnmarshalJSON keeps the raw object alongside the decoded fields.
+func (r *ghRelease) UnmarshalJSON(data []byte) error {
+	type plain ghRelease
+	if err := json.Unmarshal(data, (*plain)(r)); err != nil {
+		return err
+	}
+	r.raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// release converts r to the exported model.
+func (r *ghRelease) release() *Release {
+	out := &Release{
+		Tag:         r.TagName,
+		Name:        r.Name,
+		Body:        r.Body,
+		Draft:       r.Draft,
+		Prerelease:  r.Prerelease,
+		CreatedAt:   r.CreatedAt,
+		PublishedAt: r.PublishedAt,
+		Author:      r.Author.Login,
+		URL:         r.HTMLURL,
+		Assets:      make([]ReleaseAsset, 0, len(r.Assets)),
+		Raw:         r.raw,
+	}
+	for _, a := range r.Assets {
+		out.Assets = append(out.Assets, ReleaseAsset{
+			Name:          a.Name,
+			Size:          a.Size,
+			ContentType:   a.ContentType,
+			Digest:        a.Digest,
+			DownloadURL:   a.BrowserDownloadURL,
+			DownloadCount: a.DownloadCount,
+			UpdatedAt:     a.UpdatedAt,
+		})
+	}
+	return out
+}
+
+// Releases returns the releases of the repository, newest first as
+// ordered by the API, including drafts visible to the token and
+// releases outside the channel.
+func (u *Updater) Releases(ctx context.Context) ([]Release, error) {
+	if !Enabled() {
+		return nil, ErrDisabled
+	}
+	rels, err := u.http().listReleases(ctx, u.apiURL(), u.Owner, u.Repo)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Release, 0, len(rels))
+	for i := range rels {
+		out = append(out, *rels[i].release())
+	}
+	return out, nil
+}
+
+// acceptRelease runs u.AcceptRelease on rel. Its errors match
+// ErrPolicyViolation.
+func (u *Updater) acceptRelease(rel *ghRelease) error {
+	if u.AcceptRelease == nil {
+		return nil
+	}
+	err := u.AcceptRelease(rel.release())
+	if err == nil || errors.Is(err, ErrPolicyViolation) {
+		return err
+	}
+	return fmt.Errorf("%w: %s: %w", ErrPolicyViolation, rel.TagName, err)
+}
@@ -0,0 +1,61 @@
+package selfupdate_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/msmania/updater/selfupdate"
+	"github.com/msmania/updater/selfupdate/selfupdatetest"
+)
+
+func Test_Updater_releases(t *testing.T) {
+	skipIfDisabled(t)
+	published := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	srv := selfupdatetest.NewServer("owner", "app",
+		selfupdatetest.Release{Tag: "v1.0.0", PublishedAt: published.Add(-48 * time.Hour)},
+		selfupdatetest.Release{Tag: "v1.1.0", PublishedAt: published, Author: "releaser", Body: "notes",
+			Assets: []selfupdatetest.Asset{{Name: "app-bin", Content: []byte("v1.1")}}},
+	)
+	defer srv.Close()
+	u := newUpdater(t, srv, "v1.0.0")
+	rels, err := u.Releases(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rels) != 2 {
+		t.Fatalf("got %d releases", len(rels))
+	}
+	r := rels[0]
+	if r.Tag != "v1.1.0" || r.Author != "releaser" || r.Body != "notes" || !r.PublishedAt.Equal(published) ||
+		len(r.Assets) != 1 || r.Assets[0].Size != 4 || r.Assets[0].DownloadURL != srv.DownloadURL("v1.1.0", "app-bin") {
+		t.Errorf("unexpected release %+v", r)
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(r.Raw, &raw); err != nil || raw["tag_name"] != "v1.1.0" || raw["html_url"] != r.URL {
+		t.Errorf("unexpected raw release %s: %v", r.Raw, err)
+	}
+
+	var seen []string
+	u.AcceptRelease = func(r *selfupdate.Release) error {
+		seen = append(seen, r.Tag)
+		if age := time.Since(r.PublishedAt); age < 24*time.Hour {
+			return fmt.Errorf("published %s ago", age.Round(time.Minute))
+		}
+		return nil
+	}
+	info, err := u.Update(context.Background())
+	if !errors.Is(err, selfupdate.ErrPolicyViolation) || info.Decision != selfupdate.DecisionPolicyBlocked {
+		t.Fatalf("expected a young release to be held back, got %s: %v", info.Decision, err)
+	}
+	if b, _ := os.ReadFile(u.Path); string(b) != "old" {
+		t.Errorf("held back release installed: %q", b)
+	}
+	if len(seen) != 1 || seen[0] != "v1.1.0" {
+		t.Error("unexpected AcceptRelease calls", seen)
+	}
+}
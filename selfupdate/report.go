@@ -0,0 +1,112 @@
+package selfupdate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"time"
+)
+
+// DefaultReportInterval is how often RunReports posts an inventory report
+// when ReportConfig.Interval is zero.
+const DefaultReportInterval = 5 * time.Minute
+
+// ReportConfig makes the Updater post an InventoryReport to a collector,
+// such as the fleet server's /v1/report, so the fleet can be listed by
+// version. See RunReports.
+type ReportConfig struct {
+	// URL receives each report as a JSON POST. Empty disables reports.
+	URL string
+	// Interval is the time between reports; see DefaultReportInterval.
+	Interval time.Duration
+}
+
+// InventoryReport describes a running instance.
+type InventoryReport struct {
+	// Agent is the fleet agent ID (FleetConfig.AgentID), which defaults
+	// to Hostname.
+	Agent         string    `json:"agent"`
+	Hostname      string    `json:"hostname"`
+	Version       string    `json:"version"`
+	Platform      string    `json:"platform"`
+	Channel       Channel   `json:"channel"`
+	LastResult    string    `json:"last_result"`
+	LastError     string    `json:"last_error,omitempty"`
+	LastCheckedAt time.Time `json:"last_checked_at,omitzero"`
+	StartedAt     time.Time `json:"started_at"`
+	UptimeSeconds int64     `json:"uptime_seconds"`
+}
+
+// processStart approximates when this process started.
+var processStart = time.Now()
+
+// agentID returns the ID reported to fleet servers and collectors.
+func (u *Updater) agentID() string {
+	if u.Fleet.AgentID != "" {
+		return u.Fleet.AgentID
+	}
+	name, _ := os.Hostname()
+	return name
+}
+
+// inventory returns the current InventoryReport.
+func (u *Updater) inventory() InventoryReport {
+	host, _ := os.Hostname()
+	st := u.Status()
+	return InventoryReport{
+		Agent:         u.agentID(),
+		Hostname:      host,
+		Version:       u.Build.Version,
+		Platform:      u.Build.Platform,
+		Channel:       u.channel(),
+		LastResult:    st.Result,
+		LastError:     st.Error,
+		LastCheckedAt: st.CheckedAt,
+		StartedAt:     processStart.UTC(),
+		UptimeSeconds: int64(time.Since(processStart).Seconds()),
+	}
+}
+
+// Report posts one InventoryReport to Reports.URL.
+func (u *Updater) Report(ctx context.Context) error {
+	body, err := json.Marshal(u.inventory())
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.Reports.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := u.http().do(req)
+	if err != nil {
+		return err
+	}
+	defer drainClose(resp.Body)
+	return checkResponse(resp)
+}
+
+// RunReports posts an InventoryReport now and then every
+// Reports.Interval until ctx is done. Failures are logged and retried at
+// the next interval. It returns at once if Reports.URL is empty.
+func (u *Updater) RunReports(ctx context.Context) {
+	if u.Reports.URL == "" {
+		return
+	}
+	interval := orDefault(u.Reports.Interval, DefaultReportInterval)
+	t := u.clock().NewTimer(interval)
+	defer t.Stop()
+	for {
+		if err := u.Report(ctx); err != nil && ctx.Err() == nil {
+			u.logf("inventory report failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C():
+			t.Reset(interval)
+		}
+	}
+}
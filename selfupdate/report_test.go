@@ -0,0 +1,52 @@
+package selfupdate
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_RunReports(t *testing.T) {
+	reports := make(chan InventoryReport, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rep InventoryReport
+		if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&rep) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		reports <- rep
+	}))
+	defer srv.Close()
+	u := &Updater{
+		Build:   BuildInfo{Version: "v1.0.0", Platform: "linux/amd64"},
+		Fleet:   FleetConfig{AgentID: "agent-1"},
+		Reports: ReportConfig{URL: srv.URL, Interval: 10 * time.Millisecond},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		u.RunReports(ctx)
+		close(done)
+	}()
+	for range 2 {
+		select {
+		case rep := <-reports:
+			if rep.Agent != "agent-1" || rep.Version != "v1.0.0" || rep.Platform != "linux/amd64" ||
+				rep.LastResult != ResultNotChecked || rep.StartedAt.IsZero() || rep.Hostname == "" {
+				t.Errorf("unexpected report %+v", rep)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("no periodic report")
+		}
+	}
+	cancel()
+	<-done
+
+	srv.Config.Handler = http.NotFoundHandler()
+	if err := u.Report(context.Background()); err == nil {
+		t.Error("a rejected report must fail")
+	}
+}
@@ -0,0 +1,183 @@
+package selfupdate
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/ext"
+)
+
+// ReleaseRule is a CEL expression (https://cel.dev) deciding about every
+// release that would be installed, for eligibility rules the other
+// settings cannot express. It evaluates either to a bool, true installing
+// the release, or to a verdict built with
+//
+//	allow()         install the release
+//	deny(reason)    hold it back; Update and Check fail with ErrPolicyViolation
+//	delay(reason)   defer it with ErrDeferred until a later check allows it
+//
+// The expression sees the variables of RuleData: release (a Release, with
+// its fields named as in JSON, e.g. release.tag and release.published_at),
+// current, channel, host, now (a timestamp) and age (a duration). For
+// example:
+//
+//	now.getDayOfWeek("Europe/Berlin") == 5 ? delay("no deploys on Fridays")
+//	: !release.body.matches("CHG-[0-9]+") ? deny("no change ticket in the notes")
+//	: release.tag.endsWith("-hotfix") ? deny("hotfixes are installed by hand")
+//	: allow()
+//
+// References to unknown variables or fields and results of another type
+// are rejected by ParseReleaseRule. A rule that fails to evaluate denies.
+type ReleaseRule struct {
+	text string
+	prg  cel.Program
+}
+
+// RuleData holds the values available to a ReleaseRule.
+type RuleData struct {
+	Release *Release
+	// Current is the running version.
+	Current string
+	Channel Channel
+	Host    string
+	// Now is the time of the check and Age the time since the release was
+	// published, or 0 if unknown.
+	Now time.Time
+	Age time.Duration
+}
+
+// ruleCostLimit bounds the work of one evaluation, so a rule cannot stall
+// a check.
+const ruleCostLimit = 1_000_000
+
+// verdictType is the CEL type of allow(), deny and delay.
+var verdictType = cel.OpaqueType("selfupdate.Verdict")
+
+// verdict is the value of allow(), deny and delay.
+type verdict struct {
+	action, reason string
+}
+
+func (v verdict) ConvertToNative(t reflect.Type) (any, error) {
+	return nil, fmt.Errorf("verdict cannot be converted to %v", t)
+}
+
+func (v verdict) ConvertToType(t ref.Type) ref.Val {
+	if t == types.TypeType {
+		return verdictType
+	}
+	return types.NewErr("verdict cannot be converted to %s", t.TypeName())
+}
+
+func (v verdict) Equal(other ref.Val) ref.Val {
+	o, ok := other.(verdict)
+	return types.Bool(ok && o == v)
+}
+
+func (v verdict) Type() ref.Type { return verdictType }
+func (v verdict) Value() any     { return v }
+
+// ruleEnv declares the variables and functions of a ReleaseRule.
+var ruleEnv = sync.OnceValues(func() (*cel.Env, error) {
+	reason := func(action string) cel.OverloadOpt {
+		return cel.UnaryBinding(func(r ref.Val) ref.Val {
+			return verdict{action: action, reason: string(r.(types.String))}
+		})
+	}
+	return cel.NewEnv(
+		ext.NativeTypes(reflect.TypeOf(Release{}), ext.ParseStructTag("json")),
+		ext.Strings(),
+		cel.Variable("release", cel.ObjectType("selfupdate.Release")),
+		cel.Variable("current", cel.StringType),
+		cel.Variable("channel", cel.StringType),
+		cel.Variable("host", cel.StringType),
+		cel.Variable("now", cel.TimestampType),
+		cel.Variable("age", cel.DurationType),
+		cel.Function("allow", cel.Overload("allow", nil, verdictType,
+			cel.FunctionBinding(func(...ref.Val) ref.Val { return verdict{action: "allow"} }))),
+		cel.Function("deny", cel.Overload("deny_string", []*cel.Type{cel.StringType}, verdictType, reason("deny"))),
+		cel.Function("delay", cel.Overload("delay_string", []*cel.Type{cel.StringType}, verdictType, reason("delay"))),
+	)
+})
+
+// ParseReleaseRule compiles and type-checks the CEL expression of a
+// ReleaseRule.
+func ParseReleaseRule(text string) (*ReleaseRule, error) {
+	env, err := ruleEnv()
+	if err != nil {
+		return nil, err
+	}
+	ast, iss := env.Compile(text)
+	if err := iss.Err(); err != nil {
+		return nil, err
+	}
+	if out := ast.OutputType(); !out.IsExactType(cel.BoolType) && !out.IsExactType(verdictType) {
+		return nil, fmt.Errorf("release rule evaluates to %s, want bool or a verdict", out)
+	}
+	prg, err := env.Program(ast, cel.CostLimit(ruleCostLimit))
+	if err != nil {
+		return nil, err
+	}
+	return &ReleaseRule{text: text, prg: prg}, nil
+}
+
+func (r *ReleaseRule) String() string {
+	return r.text
+}
+
+// Eval evaluates r for data and returns nil if it allows the release, or
+// an error matching ErrPolicyViolation or ErrDeferred.
+func (r *ReleaseRule) Eval(data RuleData) error {
+	out, _, err := r.prg.Eval(map[string]any{
+		"release": data.Release,
+		"current": data.Current,
+		"channel": string(data.Channel),
+		"host":    data.Host,
+		"now":     data.Now,
+		"age":     data.Age,
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %s: release rule: %w", ErrPolicyViolation, data.Release.Tag, err)
+	}
+	v := verdict{action: "deny", reason: "release rule"}
+	switch val := out.Value().(type) {
+	case bool:
+		if val {
+			v.action = "allow"
+		}
+	case verdict:
+		v = val
+	default:
+		return fmt.Errorf("%w: %s: release rule returned %v", ErrPolicyViolation, data.Release.Tag, val)
+	}
+	if v.reason == "" {
+		v.reason = "release rule"
+	}
+	switch v.action {
+	case "deny":
+		return fmt.Errorf("%w: %s: %s", ErrPolicyViolation, data.Release.Tag, v.reason)
+	case "delay":
+		return fmt.Errorf("%w: %s: %s", ErrDeferred, data.Release.Tag, v.reason)
+	}
+	return nil
+}
+
+// checkReleaseRule evaluates u.ReleaseRule for rel.
+func (u *Updater) checkReleaseRule(rel *ghRelease) error {
+	if u.ReleaseRule == nil {
+		return nil
+	}
+	now := u.clock().Now()
+	data := RuleData{Release: rel.release(), Current: u.Build.Version, Channel: u.channel(), Now: now}
+	data.Host, _ = os.Hostname()
+	if !rel.PublishedAt.IsZero() {
+		data.Age = now.Sub(rel.PublishedAt)
+	}
+	return u.ReleaseRule.Eval(data)
+}
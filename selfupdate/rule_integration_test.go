@@ -0,0 +1,46 @@
+package selfupdate_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/msmania/updater/selfupdate"
+	"github.com/msmania/updater/selfupdate/selfupdatetest"
+)
+
+func Test_Updater_releaseRule(t *testing.T) {
+	skipIfDisabled(t)
+	published := time.Date(2026, time.March, 5, 12, 0, 0, 0, time.UTC) // a Thursday
+	srv := selfupdatetest.NewServer("owner", "app",
+		selfupdatetest.Release{Tag: "v1.1.0", PublishedAt: published, Assets: []selfupdatetest.Asset{{Name: "app-bin", Content: []byte("v1.1")}}},
+	)
+	defer srv.Close()
+	clock := selfupdatetest.NewClock(published.Add(24 * time.Hour))
+	u := newUpdater(t, srv, "v1.0.0")
+	u.Clock = clock
+	var err error
+	u.ReleaseRule, err = selfupdate.ParseReleaseRule(
+		`now.getDayOfWeek() == 5 ? delay("not on Fridays") : age < duration("72h") ? deny("too new") : allow()`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := u.Update(context.Background())
+	if !errors.Is(err, selfupdate.ErrDeferred) || info.Decision != selfupdate.DecisionDeferred ||
+		!strings.Contains(err.Error(), "not on Fridays") {
+		t.Fatalf("expected the release to be delayed, got %s: %v", info.Decision, err)
+	}
+	if st := u.Status(); st.Result != selfupdate.ResultDeferred {
+		t.Errorf("unexpected status %+v", st)
+	}
+	clock.Advance(24 * time.Hour)
+	if info, err := u.Update(context.Background()); !errors.Is(err, selfupdate.ErrPolicyViolation) || info.Decision != selfupdate.DecisionPolicyBlocked {
+		t.Fatalf("expected the release to be denied, got %s: %v", info.Decision, err)
+	}
+	clock.Advance(24 * time.Hour)
+	if info, err := u.Update(context.Background()); err != nil || info.Decision != selfupdate.DecisionUpgraded {
+		t.Fatalf("expected the release to install, got %s: %v", info.Decision, err)
+	}
+}
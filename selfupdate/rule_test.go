@@ -0,0 +1,60 @@
+package selfupdate
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_ReleaseRule(t *testing.T) {
+	r, err := ParseReleaseRule(`now.getDayOfWeek("UTC") == 5 ? delay("no deploys on Fridays")
+		: !release.body.matches("CHG-[0-9]+") ? deny("no change ticket")
+		: release.tag.endsWith("-hotfix") ? deny("")
+		: allow()`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify := func(r *ReleaseRule, tag, body string, now time.Time, want error, wantText string) {
+		t.Helper()
+		err := r.Eval(RuleData{Release: &Release{Tag: tag, Body: body}, Now: now})
+		if want == nil && err != nil || want != nil && (!errors.Is(err, want) || !strings.Contains(err.Error(), wantText)) {
+			t.Errorf("%s %q at %s: got %v, want %v (%s)", tag, body, now.Weekday(), err, want, wantText)
+		}
+	}
+	thursday := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	friday := thursday.Add(24 * time.Hour)
+	verify(r, "v1.1.0", "Fixes CHG-123", thursday, nil, "")
+	verify(r, "v1.1.0", "Fixes CHG-123", friday, ErrDeferred, "v1.1.0: no deploys on Fridays")
+	verify(r, "v1.1.0", "Fixes things", thursday, ErrPolicyViolation, "no change ticket")
+	verify(r, "v1.1.0-hotfix", "CHG-1", thursday, ErrPolicyViolation, "release rule")
+
+	// A bool allows or denies.
+	r, err = ParseReleaseRule(`!release.prerelease && release.assets.exists(a, a.name == "app")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Eval(RuleData{Release: &Release{Tag: "v1.1.0", Assets: []ReleaseAsset{{Name: "app"}}}}); err != nil {
+		t.Errorf("rule denied a matching release: %v", err)
+	}
+	verify(r, "v1.1.0", "", thursday, ErrPolicyViolation, "release rule")
+
+	// An evaluation error denies.
+	r, err = ParseReleaseRule(`release.body.matches("(")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify(r, "v1.1.0", "", thursday, ErrPolicyViolation, "release rule")
+
+	for _, text := range []string{
+		`release.nope == "x"`,     // unknown field
+		`tag == "v1"`,             // unknown variable
+		`"allow"`,                 // neither bool nor verdict
+		`deny(1)`,                 // wrong argument type
+		`age > duration("1h") &&`, // syntax
+	} {
+		if _, err := ParseReleaseRule(text); err == nil {
+			t.Errorf("%s: expected a compile error", text)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package selfupdate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// maxSBOMSize bounds how much of an SBOM asset is read.
+const maxSBOMSize = 16 << 20
+
+// SBOM is the part of an SPDX or CycloneDX JSON document that policies
+// look at.
+type SBOM struct {
+	Format          string // "spdx" or "cyclonedx"
+	Packages        []SBOMPackage
+	Vulnerabilities []string // IDs listed by a CycloneDX document
+}
+
+// SBOMPackage is a package or component of an SBOM.
+type SBOMPackage struct {
+	Name     string
+	Version  string
+	Licenses []string // SPDX license IDs or expressions
+}
+
+type spdxDocument struct {
+	SPDXVersion string `json:"spdxVersion"`
+	Packages    []struct {
+		Name             string `json:"name"`
+		VersionInfo      string `json:"versionInfo"`
+		LicenseConcluded string `json:"licenseConcluded"`
+		LicenseDeclared  string `json:"licenseDeclared"`
+	} `json:"packages"`
+}
+
+type cycloneDXDocument struct {
+	BOMFormat  string `json:"bomFormat"`
+	Components []struct {
+		Name     string `json:"name"`
+		Version  string `json:"version"`
+		Licenses []struct {
+			License struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"license"`
+			Expression string `json:"expression"`
+		} `json:"licenses"`
+	} `json:"components"`
+	Vulnerabilities []struct {
+		ID string `json:"id"`
+	} `json:"vulnerabilities"`
+}
+
+// ParseSBOM reads an SPDX 2.x or CycloneDX document in JSON format.
+func ParseSBOM(data []byte) (*SBOM, error) {
+	var probe struct {
+		SPDXVersion string `json:"spdxVersion"`
+		BOMFormat   string `json:"bomFormat"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("invalid SBOM: %w", err)
+	}
+	switch {
+	case probe.SPDXVersion != "":
+		var doc spdxDocument
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("invalid SPDX document: %w", err)
+		}
+		s := &SBOM{Format: "spdx"}
+		for _, p := range doc.Packages {
+			pkg := SBOMPackage{Name: p.Name, Version: p.VersionInfo}
+			for _, l := range []string{p.LicenseConcluded, p.LicenseDeclared} {
+				if l != "" && l != "NOASSERTION" && l != "NONE" {
+					pkg.Licenses = append(pkg.Licenses, l)
+				}
+			}
+			s.Packages = append(s.Packages, pkg)
+		}
+		return s, nil
+	case probe.BOMFormat == "CycloneDX":
+		var doc cycloneDXDocument
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("invalid CycloneDX document: %w", err)
+		}
+		s := &SBOM{Format: "cyclonedx"}
+		for _, c := range doc.Components {
+			pkg := SBOMPackage{Name: c.Name, Version: c.Version}
+			for _, l := range c.Licenses {
+				for _, v := range []string{l.License.ID, l.License.Name, l.Expression} {
+					if v != "" {
+						pkg.Licenses = append(pkg.Licenses, v)
+					}
+				}
+			}
+			s.Packages = append(s.Packages, pkg)
+		}
+		for _, v := range doc.Vulnerabilities {
+			s.Vulnerabilities = append(s.Vulnerabilities, v.ID)
+		}
+		return s, nil
+	}
+	return nil, errors.New("invalid SBOM: neither SPDX nor CycloneDX")
+}
+
+// SBOMPolicy rejects releases whose SBOM lists denied licenses, packages
+// or vulnerabilities. Entries are matched case-insensitively, and a
+// trailing "*" matches any suffix, e.g. "GPL-3.0*".
+type SBOMPolicy struct {
+	// DenyLicenses are SPDX license IDs; expressions such as
+	// "MIT OR GPL-3.0-only" are split into their IDs.
+	DenyLicenses []string
+	// DenyPackages are package names, optionally as "name@version".
+	DenyPackages []string
+	// DenyVulnerabilities are advisory IDs such as "CVE-2024-1234".
+	DenyVulnerabilities []string
+}
+
+// IsZero reports whether the policy denies nothing.
+func (p SBOMPolicy) IsZero() bool {
+	return len(p.DenyLicenses) == 0 && len(p.DenyPackages) == 0 && len(p.DenyVulnerabilities) == 0
+}
+
+// Check returns an ErrPolicyViolation error listing what s violates.
+func (p SBOMPolicy) Check(s *SBOM) error {
+	var violations []string
+	for _, pkg := range s.Packages {
+		for _, deny := range p.DenyPackages {
+			if denyMatches(deny, pkg.Name) || denyMatches(deny, pkg.Name+"@"+pkg.Version) {
+				violations = append(violations, "package "+pkg.Name+"@"+pkg.Version)
+			}
+		}
+		for _, expr := range pkg.Licenses {
+			for _, id := range licenseIDs(expr) {
+				for _, deny := range p.DenyLicenses {
+					if denyMatches(deny, id) {
+						violations = append(violations, fmt.Sprintf("license %s of %s", id, pkg.Name))
+					}
+				}
+			}
+		}
+	}
+	for _, id := range s.Vulnerabilities {
+		for _, deny := range p.DenyVulnerabilities {
+			if denyMatches(deny, id) {
+				violations = append(violations, "vulnerability "+id)
+			}
+		}
+	}
+	if len(violations) > 0 {
+		return fmt.Errorf("%w: SBOM lists %s", ErrPolicyViolation, strings.Join(violations, ", "))
+	}
+	return nil
+}
+
+// licenseIDs splits an SPDX license expression into license IDs.
+func licenseIDs(expr string) []string {
+	var ids []string
+	for _, tok := range strings.FieldsFunc(expr, func(r rune) bool { return r == ' ' || r == '(' || r == ')' }) {
+		switch strings.ToUpper(tok) {
+		case "AND", "OR", "WITH":
+			continue
+		}
+		ids = append(ids, tok)
+	}
+	return ids
+}
+
+// denyMatches matches value against a denylist entry.
+func denyMatches(deny, value string) bool {
+	if prefix, ok := strings.CutSuffix(deny, "*"); ok {
+		return len(value) >= len(prefix) && strings.EqualFold(value[:len(prefix)], prefix)
+	}
+	return strings.EqualFold(deny, value)
+}
+
+// checkSBOM downloads the release's SBOM asset, if configured, and
+// applies SBOMPolicy to it.
+func (u *Updater) checkSBOM(ctx context.Context, rel *ghRelease) error {
+	if u.SBOMAsset == "" {
+		return nil
+	}
+	asset, err := rel.findAsset(u.SBOMAsset)
+	if err != nil {
+		return err
+	}
+	data, err := u.http().fetchSmall(ctx, asset.BrowserDownloadURL, maxSBOMSize)
+	if err != nil {
+		return err
+	}
+	sbom, err := ParseSBOM(data)
+	if err != nil {
+		return err
+	}
+	return u.SBOMPolicy.Check(sbom)
+}
@@ -0,0 +1,46 @@
+package selfupdate_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/msmania/updater/selfupdate"
+	"github.com/msmania/updater/selfupdate/selfupdatetest"
+)
+
+func Test_Updater_sbom(t *testing.T) {
+	skipIfDisabled(t)
+	sbom := []byte(`{"bomFormat": "CycloneDX", "components": [{"name": "libfoo", "version": "1.0.0",
+		"licenses": [{"license": {"id": "GPL-3.0-only"}}]}]}`)
+	srv := selfupdatetest.NewServer("owner", "app",
+		selfupdatetest.Release{Tag: "v1.1.0", Assets: []selfupdatetest.Asset{
+			{Name: "app-bin", Content: []byte("v1.1")},
+			{Name: "sbom.cdx.json", Content: sbom},
+		}},
+	)
+	defer srv.Close()
+	ctx := context.Background()
+
+	u := newUpdater(t, srv, "v1.0.0")
+	u.SBOMAsset = "sbom.cdx.json"
+	u.SBOMPolicy.DenyLicenses = []string{"GPL-3.0*"}
+	info, err := u.Update(ctx)
+	if !errors.Is(err, selfupdate.ErrPolicyViolation) || info.Decision != selfupdate.DecisionPolicyBlocked {
+		t.Error("expected policy violation, got", info.Decision, err)
+	}
+	if b, _ := os.ReadFile(u.Path); string(b) != "old" {
+		t.Error("blocked release installed")
+	}
+
+	u.SBOMAsset = "missing.spdx.json"
+	u.SBOMPolicy = selfupdate.SBOMPolicy{}
+	if _, err := u.Update(ctx); !errors.Is(err, selfupdate.ErrNoAsset) {
+		t.Error("a missing SBOM must fail the update, got", err)
+	}
+	u.SBOMAsset = "sbom.cdx.json"
+	if _, err := u.Update(ctx); err != nil {
+		t.Error(err)
+	}
+}
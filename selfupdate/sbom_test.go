@@ -0,0 +1,68 @@
+package selfupdate
+
+import (
+	"errors"
+	"testing"
+)
+
+const testSPDX = `{
+  "spdxVersion": "SPDX-2.3",
+  "packages": [
+    {"name": "app", "versionInfo": "1.2.0", "licenseConcluded": "MIT", "licenseDeclared": "NOASSERTION"},
+    {"name": "libfoo", "versionInfo": "0.9.1", "licenseConcluded": "(MIT OR GPL-3.0-only)"}
+  ]
+}`
+
+const testCycloneDX = `{
+  "bomFormat": "CycloneDX",
+  "specVersion": "1.5",
+  "components": [
+    {"name": "libbar", "version": "2.0.0", "licenses": [{"license": {"id": "Apache-2.0"}}]},
+    {"name": "libbaz", "version": "1.0.0", "licenses": [{"expression": "AGPL-3.0-or-later"}]}
+  ],
+  "vulnerabilities": [{"id": "CVE-2024-0001"}]
+}`
+
+func Test_ParseSBOM(t *testing.T) {
+	s, err := ParseSBOM([]byte(testSPDX))
+	if err != nil || s.Format != "spdx" || len(s.Packages) != 2 {
+		t.Fatalf("unexpected SPDX result %+v: %v", s, err)
+	}
+	if p := s.Packages[0]; p.Name != "app" || p.Version != "1.2.0" || len(p.Licenses) != 1 {
+		t.Errorf("unexpected package %+v", p)
+	}
+	s, err = ParseSBOM([]byte(testCycloneDX))
+	if err != nil || s.Format != "cyclonedx" || len(s.Packages) != 2 || len(s.Vulnerabilities) != 1 {
+		t.Fatalf("unexpected CycloneDX result %+v: %v", s, err)
+	}
+	for _, bad := range []string{`not json`, `{"name": "x"}`} {
+		if _, err := ParseSBOM([]byte(bad)); err == nil {
+			t.Error("expected error for " + bad)
+		}
+	}
+}
+
+func Test_SBOMPolicy(t *testing.T) {
+	spdx, _ := ParseSBOM([]byte(testSPDX))
+	cdx, _ := ParseSBOM([]byte(testCycloneDX))
+	verifyOk := func(p SBOMPolicy, s *SBOM) {
+		if err := p.Check(s); err != nil {
+			t.Error(err)
+		}
+	}
+	verifyFail := func(p SBOMPolicy, s *SBOM) {
+		if err := p.Check(s); !errors.Is(err, ErrPolicyViolation) {
+			t.Errorf("%+v: expected ErrPolicyViolation, got %v", p, err)
+		}
+	}
+	verifyOk(SBOMPolicy{}, spdx)
+	verifyOk(SBOMPolicy{DenyLicenses: []string{"BSD-3-Clause"}}, spdx)
+	verifyFail(SBOMPolicy{DenyLicenses: []string{"gpl-3.0*"}}, spdx)
+	verifyOk(SBOMPolicy{DenyLicenses: []string{"GPL-3.0*"}}, cdx)
+	verifyFail(SBOMPolicy{DenyLicenses: []string{"AGPL-3.0*"}}, cdx)
+	verifyFail(SBOMPolicy{DenyPackages: []string{"libfoo"}}, spdx)
+	verifyFail(SBOMPolicy{DenyPackages: []string{"libfoo@0.9.1"}}, spdx)
+	verifyOk(SBOMPolicy{DenyPackages: []string{"libfoo@0.9.2"}}, spdx)
+	verifyFail(SBOMPolicy{DenyVulnerabilities: []string{"CVE-2024-0001"}}, cdx)
+	verifyOk(SBOMPolicy{DenyVulnerabilities: []string{"CVE-2024-0001"}}, spdx)
+}
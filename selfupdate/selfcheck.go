@@ -0,0 +1,172 @@
+package selfupdate
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// CheckStatus is the outcome of one diagnostic of SelfCheck.
+type CheckStatus string
+
+const (
+	CheckPass CheckStatus = "pass"
+	CheckFail CheckStatus = "fail"
+	// CheckSkip marks diagnostics that do not apply, e.g. the state
+	// directory check without a StateDir.
+	CheckSkip CheckStatus = "skip"
+)
+
+// Names of the diagnostics of SelfCheck.
+const (
+	CheckStateDir      = "state-dir"
+	CheckWorkDir       = "work-dir"
+	CheckReleaseSource = "release-source"
+	CheckClock         = "clock"
+	CheckDiskSpace     = "disk-space"
+)
+
+// Check is one diagnostic of a SelfCheckReport.
+type Check struct {
+	Name   string      `json:"name"`
+	Status CheckStatus `json:"status"`
+	Detail string      `json:"detail,omitempty"`
+}
+
+// SelfCheckReport is the result of SelfCheck. Status is CheckFail if any
+// check failed.
+type SelfCheckReport struct {
+	Status        CheckStatus `json:"status"`
+	Version       string      `json:"version"`
+	CheckedAt     time.Time   `json:"checked_at"`
+	StartedAt     time.Time   `json:"started_at"`
+	UptimeSeconds int64       `json:"uptime_seconds"`
+	Checks        []Check     `json:"checks"`
+}
+
+// MaxClockSkew is how far the local clock may be from that of the
+// release source before SelfCheck fails: beyond it, certificate and
+// signature validity and the update policies go wrong.
+const MaxClockSkew = 5 * time.Minute
+
+// SelfCheck runs the diagnostics an update depends on: the state and
+// staging directories are writable, the release source answers, the
+// local clock agrees with it, and the staging file system has room for
+// an update (three times the executable: the download, the extracted
+// file and the copy kept for a rollback). It only writes and removes
+// probe files, so it is safe to run at any time, e.g. as the readiness
+// check of a new version.
+func (u *Updater) SelfCheck(ctx context.Context) SelfCheckReport {
+	now := u.clock().Now()
+	r := SelfCheckReport{
+		Status:        CheckPass,
+		Version:       u.Build.Version,
+		CheckedAt:     now.UTC(),
+		StartedAt:     processStart.UTC(),
+		UptimeSeconds: int64(time.Since(processStart).Seconds()),
+	}
+	add := func(name string, status CheckStatus, detail string) {
+		r.Checks = append(r.Checks, Check{Name: name, Status: status, Detail: detail})
+		if status == CheckFail {
+			r.Status = CheckFail
+		}
+	}
+	addErr := func(name string, err error, detail string) {
+		if err != nil {
+			add(name, CheckFail, err.Error())
+		} else {
+			add(name, CheckPass, detail)
+		}
+	}
+
+	if u.StateDir == "" {
+		add(CheckStateDir, CheckSkip, "no state directory")
+	} else {
+		addErr(CheckStateDir, probeWritable(u.fs(), u.StateDir), u.StateDir)
+	}
+
+	exePath, err := u.path()
+	var workDir string
+	if err == nil {
+		var staged string
+		if staged, err = u.workPath(exePath, ""); err == nil {
+			workDir = filepath.Dir(staged)
+			err = probeWritable(OSFS{}, workDir)
+		}
+	}
+	addErr(CheckWorkDir, err, workDir)
+
+	serverTime, err := u.probeReleaseSource(ctx)
+	addErr(CheckReleaseSource, err, u.apiURL())
+
+	switch {
+	case serverTime.IsZero():
+		add(CheckClock, CheckSkip, "no time from the release source")
+	default:
+		skew := now.Sub(serverTime)
+		// The Date header has a resolution of one second.
+		detail := fmt.Sprintf("%s from the release source", skew.Round(time.Second))
+		if skew.Abs() > MaxClockSkew {
+			add(CheckClock, CheckFail, detail)
+		} else {
+			add(CheckClock, CheckPass, detail)
+		}
+	}
+
+	switch {
+	case workDir == "":
+		add(CheckDiskSpace, CheckSkip, "no staging directory")
+	default:
+		free, err := diskFree(workDir)
+		if err != nil {
+			add(CheckDiskSpace, CheckSkip, err.Error())
+			break
+		}
+		var need int64
+		if fi, err := os.Stat(exePath); err == nil {
+			need = 3 * fi.Size()
+		}
+		detail := fmt.Sprintf("%d bytes free, %d needed", free, need)
+		if free < uint64(need) {
+			add(CheckDiskSpace, CheckFail, detail)
+		} else {
+			add(CheckDiskSpace, CheckPass, detail)
+		}
+	}
+	return r
+}
+
+// probeWritable creates and removes a file in dir.
+func probeWritable(fsys FS, dir string) error {
+	if err := fsys.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	probe := filepath.Join(dir, ".selfcheck-"+strconv.Itoa(os.Getpid()))
+	if err := fsys.WriteFile(probe, nil, 0o644); err != nil {
+		return err
+	}
+	return fsys.Remove(probe)
+}
+
+// probeReleaseSource requests one release from the API and returns the
+// time the server reported, if any.
+func (u *Updater) probeReleaseSource(ctx context.Context) (time.Time, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := newGetRequest(ctx, releasesURL(u.apiURL(), u.Owner, u.Repo)+"?per_page=1")
+	if err != nil {
+		return time.Time{}, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := u.http().do(req)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer drainClose(resp.Body)
+	serverTime, _ := http.ParseTime(resp.Header.Get("Date"))
+	return serverTime, checkResponse(resp)
+}
@@ -0,0 +1,52 @@
+package selfupdate_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/msmania/updater/selfupdate"
+	"github.com/msmania/updater/selfupdate/selfupdatetest"
+)
+
+func Test_Updater_selfCheck(t *testing.T) {
+	skipIfDisabled(t)
+	srv := selfupdatetest.NewServer("owner", "app", selfupdatetest.Release{Tag: "v1.0.0"})
+	defer srv.Close()
+	u := newUpdater(t, srv, "v1.0.0")
+	u.StateDir = filepath.Join(t.TempDir(), "state")
+	statuses := func(r selfupdate.SelfCheckReport) map[string]selfupdate.CheckStatus {
+		m := map[string]selfupdate.CheckStatus{}
+		for _, c := range r.Checks {
+			m[c.Name] = c.Status
+		}
+		return m
+	}
+
+	r := u.SelfCheck(context.Background())
+	got := statuses(r)
+	if r.Status != selfupdate.CheckPass || r.Version != "v1.0.0" || r.StartedAt.IsZero() ||
+		got[selfupdate.CheckStateDir] != selfupdate.CheckPass || got[selfupdate.CheckWorkDir] != selfupdate.CheckPass ||
+		got[selfupdate.CheckReleaseSource] != selfupdate.CheckPass || got[selfupdate.CheckClock] != selfupdate.CheckPass {
+		t.Errorf("expected all checks to pass, got %+v", r)
+	}
+	if entries, _ := os.ReadDir(u.StateDir); len(entries) != 0 {
+		t.Errorf("probe files left behind: %v", entries)
+	}
+
+	u.Clock = selfupdatetest.NewClock(time.Now().Add(-time.Hour))
+	u.StateDir = filepath.Join(u.Path, "state") // below a file
+	u.APIURL = srv.URL + "/missing"
+	r = u.SelfCheck(context.Background())
+	got = statuses(r)
+	if r.Status != selfupdate.CheckFail || got[selfupdate.CheckStateDir] != selfupdate.CheckFail ||
+		got[selfupdate.CheckReleaseSource] != selfupdate.CheckFail || got[selfupdate.CheckClock] != selfupdate.CheckFail {
+		t.Errorf("expected the state directory, release source and clock checks to fail, got %+v", r)
+	}
+	u.APIURL = "http://127.0.0.1:1"
+	if got := statuses(u.SelfCheck(context.Background())); got[selfupdate.CheckClock] != selfupdate.CheckSkip {
+		t.Errorf("expected the clock check to be skipped without a release source, got %s", got[selfupdate.CheckClock])
+	}
+}
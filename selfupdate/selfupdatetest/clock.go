@@ -0,0 +1,147 @@
+package selfupdatetest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/msmania/updater/selfupdate"
+)
+
+// Clock is a selfupdate.Clock that only moves when told to, so tests can
+// run days of schedules in milliseconds:
+//
+//	clock := selfupdatetest.NewClock(start)
+//	u.Clock = clock
+//	go u.RunScheduled(ctx)
+//	clock.BlockUntil(1) // RunScheduled waits for the next window
+//	clock.Advance(24 * time.Hour)
+type Clock struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	pending []*fakeTimer
+}
+
+// NewClock returns a Clock reading now.
+func NewClock(now time.Time) *Clock {
+	c := &Clock{now: now}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *Clock) NewTimer(d time.Duration) selfupdate.Timer {
+	t := &fakeTimer{clock: c, ch: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+func (c *Clock) AfterFunc(d time.Duration, f func()) selfupdate.Timer {
+	t := &fakeTimer{clock: c, fn: f}
+	t.Reset(d)
+	return t
+}
+
+// Advance moves the clock forward by d, firing the timers that become
+// due in the order of their deadlines. AfterFunc callbacks run in their
+// own goroutines, as with package time.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	for {
+		sort.SliceStable(c.pending, func(i, j int) bool { return c.pending[i].when.Before(c.pending[j].when) })
+		if len(c.pending) == 0 || c.pending[0].when.After(end) {
+			break
+		}
+		t := c.pending[0]
+		c.pending = c.pending[1:]
+		if t.when.After(c.now) {
+			c.now = t.when
+		}
+		t.fire(c.now)
+	}
+	c.now = end
+	c.mu.Unlock()
+}
+
+// Set moves the clock to t, which must not be earlier than Now.
+func (c *Clock) Set(t time.Time) {
+	c.Advance(t.Sub(c.Now()))
+}
+
+// BlockUntil waits until at least n timers are pending, letting a test
+// advance the clock only once the code under test is waiting on it.
+func (c *Clock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.pending) < n {
+		c.cond.Wait()
+	}
+}
+
+// Timers returns the number of pending timers.
+func (c *Clock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.pending)
+}
+
+// remove unschedules t and reports whether it was pending. c.mu must be
+// held.
+func (c *Clock) remove(t *fakeTimer) bool {
+	for i, p := range c.pending {
+		if p == t {
+			c.pending = append(c.pending[:i], c.pending[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTimer struct {
+	clock *Clock
+	when  time.Time
+	ch    chan time.Time
+	fn    func()
+}
+
+// fire delivers the timer. clock.mu must be held.
+func (t *fakeTimer) fire(now time.Time) {
+	if t.fn != nil {
+		go t.fn()
+		return
+	}
+	select {
+	case t.ch <- now:
+	default:
+	}
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.ch }
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	return t.clock.remove(t)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	active := c.remove(t)
+	t.when = c.now.Add(d)
+	if d <= 0 {
+		t.fire(c.now)
+		return active
+	}
+	c.pending = append(c.pending, t)
+	c.cond.Broadcast()
+	return active
+}
@@ -0,0 +1,53 @@
+package selfupdatetest
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_Clock(t *testing.T) {
+	start := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	c := NewClock(start)
+	late := c.NewTimer(2 * time.Hour)
+	early := c.NewTimer(time.Hour)
+	called := make(chan time.Time, 1)
+	c.AfterFunc(90*time.Minute, func() { called <- c.Now() })
+	stopped := c.NewTimer(time.Minute)
+	if !stopped.Stop() || c.Timers() != 3 {
+		t.Fatal("Stop must unschedule the timer")
+	}
+
+	c.Advance(time.Hour)
+	select {
+	case at := <-early.C():
+		if !at.Equal(start.Add(time.Hour)) {
+			t.Error("fired at", at)
+		}
+	default:
+		t.Error("due timer not fired")
+	}
+	select {
+	case <-late.C():
+		t.Error("timer fired early")
+	default:
+	}
+
+	c.Advance(time.Hour)
+	if at := <-called; at.Before(start.Add(90 * time.Minute)) {
+		t.Error("AfterFunc ran at", at)
+	}
+	if at := <-late.C(); !at.Equal(start.Add(2 * time.Hour)) {
+		t.Error("fired at", at)
+	}
+	if !c.Now().Equal(start.Add(2*time.Hour)) || c.Timers() != 0 {
+		t.Error("unexpected state", c.Now(), c.Timers())
+	}
+
+	if late.Reset(time.Minute) {
+		t.Error("Reset of a fired timer must report it inactive")
+	}
+	c.Set(start.Add(3 * time.Hour))
+	if at := <-late.C(); !at.Equal(start.Add(2*time.Hour + time.Minute)) {
+		t.Error("reset timer fired at", at)
+	}
+}
@@ -0,0 +1,376 @@
+// Package selfupdatetest provides a fake GitHub release API for
+// integration tests of code built on package selfupdate. Only GitHub is
+// faked because it is the only release source selfupdate reads; there is
+// no GitLab or manifest-file source to test against.
+//
+// A Server serves the release endpoints used by selfupdate plus the asset
+// downloads themselves, so an Updater pointed at Server.URL (via its
+// APIURL field) runs end to end without network access:
+//
+//	srv := selfupdatetest.NewServer("owner", "repo", selfupdatetest.Release{
+//		Tag:    "v1.2.0",
+//		Assets: []selfupdatetest.Asset{{Name: "repo-linux-amd64", Content: bin}},
+//	})
+//	defer srv.Close()
+package selfupdatetest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Release describes a release served by the fake API.
+type Release struct {
+	Tag        string
+	Draft      bool
+	Prerelease bool
+	// PublishedAt defaults to the time the release was added.
+	PublishedAt time.Time
+	Body        string
+	// Author is the login of the publisher; defaults to the owner.
+	Author string
+	Assets []Asset
+	// Checksums adds a "checksums.txt" asset in sha256sum format covering
+	// all other assets.
+	Checksums bool
+}
+
+// Asset is a downloadable release file.
+type Asset struct {
+	Name    string
+	Content []byte
+	// Digest replaces the "sha256:<hex>" digest the API reports for the
+	// asset; NoDigest omits it, as for assets uploaded before GitHub
+	// recorded digests.
+	Digest string
+}
+
+// NoDigest is an Asset.Digest omitting the digest.
+const NoDigest = "-"
+
+// Failure describes an injected fault.
+type Failure struct {
+	// Status, if non-zero, is returned instead of the normal response.
+	Status int
+	// Truncate sends only half of an asset body while declaring the full
+	// Content-Length, simulating a dropped connection.
+	Truncate bool
+	// Delay is slept before responding.
+	Delay time.Duration
+}
+
+type injected struct {
+	prefix    string
+	failure   Failure
+	remaining int
+}
+
+// Server is a fake GitHub API backed by httptest.Server.
+type Server struct {
+	*httptest.Server
+	owner, repo string
+
+	mu        sync.Mutex
+	releases  []Release // oldest first
+	failures  []*injected
+	limited   int
+	limitTill time.Time
+	requests  []string
+}
+
+// NewServer starts a fake API for owner/repo serving the given releases,
+// which are listed oldest first.
+func NewServer(owner, repo string, releases ...Release) *Server {
+	s := &Server{owner: owner, repo: repo}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	for _, r := range releases {
+		s.AddRelease(r)
+	}
+	return s
+}
+
+// AddRelease publishes r as the newest release.
+func (s *Server) AddRelease(r Release) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.PublishedAt.IsZero() {
+		r.PublishedAt = time.Now().UTC()
+	}
+	if r.Checksums {
+		r.Assets = append(r.Assets, Asset{Name: "checksums.txt", Content: ChecksumFile(r.Assets)})
+	}
+	s.releases = append(s.releases, r)
+}
+
+// FailNext injects f into the next count requests whose path starts with
+// prefix ("" matches everything). A count of 0 fails all matching requests.
+func (s *Server) FailNext(prefix string, count int, f Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, &injected{prefix: prefix, failure: f, remaining: count})
+}
+
+// RateLimit rejects the next count API requests with GitHub's primary
+// rate limit response, advertising reset as the reset time.
+func (s *Server) RateLimit(count int, reset time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limited = count
+	s.limitTill = reset
+}
+
+// Requests returns "METHOD /path" for every request received so far.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// DownloadURL returns the URL at which the named asset of tag is served.
+func (s *Server) DownloadURL(tag, name string) string {
+	return fmt.Sprintf("%s/download/%s/%s", s.URL, tag, name)
+}
+
+// ChecksumFile renders assets in sha256sum format.
+func ChecksumFile(assets []Asset) []byte {
+	var b bytes.Buffer
+	for _, a := range assets {
+		sum := sha256.Sum256(a.Content)
+		fmt.Fprintf(&b, "%s  %s\n", hex.EncodeToString(sum[:]), a.Name)
+	}
+	return b.Bytes()
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+	failure, failed := s.takeFailure(r.URL.Path)
+	isAPI := strings.HasPrefix(r.URL.Path, "/repos/")
+	limited := isAPI && s.limited > 0
+	if limited {
+		s.limited--
+	}
+	reset := s.limitTill
+	s.mu.Unlock()
+
+	if failed && failure.Delay > 0 {
+		select {
+		case <-time.After(failure.Delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if limited {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+		http.Error(w, `{"message":"API rate limit exceeded"}`, http.StatusForbidden)
+		return
+	}
+	if failed && failure.Status != 0 {
+		http.Error(w, http.StatusText(failure.Status), failure.Status)
+		return
+	}
+
+	if tag, name, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/download/"), "/"); ok &&
+		strings.HasPrefix(r.URL.Path, "/download/") {
+		s.serveAsset(w, r, tag, name, failed && failure.Truncate)
+		return
+	}
+	base := fmt.Sprintf("/repos/%s/%s/releases", s.owner, s.repo)
+	switch {
+	case r.URL.Path == base:
+		s.serveList(w, r)
+	case r.URL.Path == base+"/latest":
+		s.serveLatest(w)
+	case strings.HasPrefix(r.URL.Path, base+"/tags/"):
+		s.serveTag(w, strings.TrimPrefix(r.URL.Path, base+"/tags/"))
+	default:
+		http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+	}
+}
+
+// takeFailure consumes a matching injected failure. s.mu must be held.
+func (s *Server) takeFailure(path string) (Failure, bool) {
+	for i, f := range s.failures {
+		if !strings.HasPrefix(path, f.prefix) {
+			continue
+		}
+		if f.remaining > 0 {
+			f.remaining--
+			if f.remaining == 0 {
+				s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			}
+		}
+		return f.failure, true
+	}
+	return Failure{}, false
+}
+
+func (s *Server) serveList(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	out := []ghRelease{}
+	for i := len(s.releases) - 1; i >= 0; i-- {
+		out = append(out, s.toJSON(s.releases[i]))
+	}
+	s.mu.Unlock()
+
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if perPage <= 0 {
+		perPage = 30
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page <= 0 {
+		page = 1
+	}
+	start := min((page-1)*perPage, len(out))
+	end := min(start+perPage, len(out))
+	if end < len(out) {
+		next := *r.URL
+		q := next.Query()
+		q.Set("page", strconv.Itoa(page+1))
+		q.Set("per_page", strconv.Itoa(perPage))
+		next.RawQuery = q.Encode()
+		w.Header().Set("Link", fmt.Sprintf(`<%s%s>; rel="next"`, s.URL, next.RequestURI()))
+	}
+	writeJSON(w, out[start:end])
+}
+
+func (s *Server) serveLatest(w http.ResponseWriter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	candidates := make([]Release, 0, len(s.releases))
+	for _, r := range s.releases {
+		if !r.Draft && !r.Prerelease {
+			candidates = append(candidates, r)
+		}
+	}
+	if len(candidates) == 0 {
+		http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+		return
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].PublishedAt.Before(candidates[j].PublishedAt)
+	})
+	writeJSON(w, s.toJSON(candidates[len(candidates)-1]))
+}
+
+func (s *Server) serveTag(w http.ResponseWriter, tag string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.releases {
+		if r.Tag == tag && !r.Draft {
+			writeJSON(w, s.toJSON(r))
+			return
+		}
+	}
+	http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+}
+
+func (s *Server) serveAsset(w http.ResponseWriter, r *http.Request, tag, name string, truncate bool) {
+	s.mu.Lock()
+	var content []byte
+	found := false
+	for _, rel := range s.releases {
+		if rel.Tag != tag {
+			continue
+		}
+		for _, a := range rel.Assets {
+			if a.Name == name {
+				content, found = a.Content, true
+			}
+		}
+	}
+	s.mu.Unlock()
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if truncate {
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.WriteHeader(http.StatusOK)
+		w.Write(content[:len(content)/2])
+		w.(http.Flusher).Flush()
+		// Abort the connection so the client sees an unexpected EOF.
+		panic(http.ErrAbortHandler)
+	}
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(content))
+}
+
+// ---------------------------------------------------------------------
+// GitHub JSON shapes
+// ---------------------------------------------------------------------
+type ghAsset struct {
+	Name               string `json:"name"`
+	Size               int    `json:"size"`
+	ContentType        string `json:"content_type"`
+	Digest             string `json:"digest"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+}
+
+type ghRelease struct {
+	TagName     string    `json:"tag_name"`
+	Name        string    `json:"name"`
+	Body        string    `json:"body"`
+	Draft       bool      `json:"draft"`
+	Prerelease  bool      `json:"prerelease"`
+	PublishedAt time.Time `json:"published_at"`
+	HTMLURL     string    `json:"html_url"`
+	Author      ghUser    `json:"author"`
+	Assets      []ghAsset `json:"assets"`
+}
+
+type ghUser struct {
+	Login string `json:"login"`
+}
+
+func (s *Server) toJSON(r Release) ghRelease {
+	out := ghRelease{
+		TagName:     r.Tag,
+		Name:        r.Tag,
+		Body:        r.Body,
+		Draft:       r.Draft,
+		Prerelease:  r.Prerelease,
+		PublishedAt: r.PublishedAt,
+		HTMLURL:     fmt.Sprintf("%s/%s/%s/releases/tag/%s", s.URL, s.owner, s.repo, r.Tag),
+		Author:      ghUser{Login: r.Author},
+		Assets:      []ghAsset{},
+	}
+	if out.Author.Login == "" {
+		out.Author.Login = s.owner
+	}
+	for _, a := range r.Assets {
+		sum := sha256.Sum256(a.Content)
+		digest := "sha256:" + hex.EncodeToString(sum[:])
+		switch a.Digest {
+		case "":
+		case NoDigest:
+			digest = ""
+		default:
+			digest = a.Digest
+		}
+		out.Assets = append(out.Assets, ghAsset{
+			Name:               a.Name,
+			Size:               len(a.Content),
+			ContentType:        "application/octet-stream",
+			Digest:             digest,
+			BrowserDownloadURL: s.DownloadURL(r.Tag, a.Name),
+		})
+	}
+	return out
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
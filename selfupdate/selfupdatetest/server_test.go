@@ -0,0 +1,98 @@
+package selfupdatetest
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/msmania/updater/selfupdate"
+)
+
+// skipIfDisabled skips tests that need a working Updater in builds with
+// the noselfupdate tag.
+func skipIfDisabled(t *testing.T) {
+	t.Helper()
+	if !selfupdate.Enabled() {
+		t.Skip("built with the noselfupdate tag")
+	}
+}
+
+func newUpdater(t *testing.T, srv *Server, current string) *selfupdate.Updater {
+	path := filepath.Join(t.TempDir(), "app")
+	if err := os.WriteFile(path, []byte("old"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return &selfupdate.Updater{
+		Owner:     "owner",
+		Repo:      "app",
+		AssetName: "app-bin",
+		Build:     selfupdate.BuildInfo{Version: current},
+		APIURL:    srv.URL,
+		Path:      path,
+	}
+}
+
+func Test_Server_upgrade(t *testing.T) {
+	skipIfDisabled(t)
+	srv := NewServer("owner", "app",
+		Release{Tag: "v1.0.0", Assets: []Asset{{Name: "app-bin", Content: []byte("v1")}}},
+		Release{Tag: "v1.1.0", Assets: []Asset{{Name: "app-bin", Content: []byte("v1.1")}}, Checksums: true},
+		Release{Tag: "v2.0.0-rc1", Prerelease: true},
+	)
+	defer srv.Close()
+
+	u := newUpdater(t, srv, "v1.0.0")
+	upgraded, err := u.MaybeUpgrade(context.Background())
+	if err != nil || !upgraded {
+		t.Fatalf("upgrade failed: %v", err)
+	}
+	if b, _ := os.ReadFile(u.Path); string(b) != "v1.1" {
+		t.Error("binary not replaced: " + string(b))
+	}
+
+	u.Build.Version = "v1.1.0"
+	if _, err := u.MaybeUpgrade(context.Background()); !errors.Is(err, selfupdate.ErrAlreadyLatest) {
+		t.Error("expected ErrAlreadyLatest, got", err)
+	}
+}
+
+func Test_Server_failures(t *testing.T) {
+	skipIfDisabled(t)
+	srv := NewServer("owner", "app",
+		Release{Tag: "v1.1.0", Assets: []Asset{{Name: "app-bin", Content: []byte("new binary")}}},
+	)
+	defer srv.Close()
+	u := newUpdater(t, srv, "v1.0.0")
+	ctx := context.Background()
+
+	srv.RateLimit(1, time.Unix(1700000000, 0))
+	if _, err := u.MaybeUpgrade(ctx); !errors.Is(err, selfupdate.ErrRateLimited) {
+		t.Error("expected ErrRateLimited, got", err)
+	}
+
+	srv.FailNext("/download/", 1, Failure{Truncate: true})
+	if _, err := u.MaybeUpgrade(ctx); !errors.Is(err, selfupdate.ErrDownloadInterrupted) {
+		t.Error("expected ErrDownloadInterrupted, got", err)
+	}
+
+	srv.FailNext("/repos/", 1, Failure{Status: 502})
+	var he *selfupdate.HTTPError
+	if _, err := u.MaybeUpgrade(ctx); !errors.As(err, &he) || he.StatusCode != 502 {
+		t.Error("expected HTTPError 502, got", err)
+	}
+
+	u.AssetName = "missing"
+	if _, err := u.MaybeUpgrade(ctx); !errors.Is(err, selfupdate.ErrNoAsset) {
+		t.Error("expected ErrNoAsset, got", err)
+	}
+
+	if b, _ := os.ReadFile(u.Path); string(b) != "old" {
+		t.Error("binary must not change on failure")
+	}
+	if n := len(srv.Requests()); n != 5 {
+		t.Errorf("expected 5 requests, got %d", n)
+	}
+}
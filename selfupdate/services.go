@@ -0,0 +1,100 @@
+package selfupdate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// ServiceRestart lists other services that run the executable the
+// Updater installs, e.g. several systemd instances of one binary. After
+// an upgrade RestartServices restarts them one at a time, waiting for
+// each to become ready before moving to the next, so that a bad release
+// takes down at most one of them.
+type ServiceRestart struct {
+	// Services are restarted in this order.
+	Services []Service
+	// Timeout is how long each service has to become ready; it defaults
+	// to DefaultCanaryTimeout.
+	Timeout time.Duration
+}
+
+// Service is a unit restarted by RestartServices.
+type Service struct {
+	// Unit is the systemd unit, e.g. "worker@2.service".
+	Unit string
+	// ReadyURL must answer with a 2xx status once the restarted service
+	// is ready. Empty waits only for the restart command.
+	ReadyURL string
+}
+
+func (s Service) String() string {
+	if s.ReadyURL == "" {
+		return s.Unit
+	}
+	return s.Unit + "=" + s.ReadyURL
+}
+
+// ParseServices parses a comma-separated list of "unit" or
+// "unit=ready-url" items, keeping their order.
+func ParseServices(s string) ([]Service, error) {
+	var out []Service
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		unit, ready, _ := strings.Cut(item, "=")
+		svc := Service{Unit: strings.TrimSpace(unit), ReadyURL: strings.TrimSpace(ready)}
+		if svc.Unit == "" {
+			return nil, fmt.Errorf("service %q: missing unit", item)
+		}
+		if svc.ReadyURL != "" {
+			if u, err := url.Parse(svc.ReadyURL); err != nil || u.Host == "" {
+				return nil, fmt.Errorf("service %s: invalid ready URL %q", svc.Unit, svc.ReadyURL)
+			}
+		}
+		out = append(out, svc)
+	}
+	return out, nil
+}
+
+// restartUnit restarts a systemd unit. Replaced in tests.
+var restartUnit = func(ctx context.Context, unit string) error {
+	out, err := exec.CommandContext(ctx, "systemctl", "restart", unit).CombinedOutput()
+	if err != nil {
+		if msg := bytes.TrimSpace(out); len(msg) > 0 {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+	}
+	return err
+}
+
+// RestartServices restarts u.Services.Services in order, each only once
+// the previous one is ready. It stops at the first service that fails to
+// restart or to become ready, leaving the rest running their old
+// process, and returns an error naming it.
+func (u *Updater) RestartServices(ctx context.Context) error {
+	services := u.Services.Services
+	timeout := orDefault(u.Services.Timeout, DefaultCanaryTimeout)
+	for i, svc := range services {
+		u.logf("Restarting %s (%d/%d)", svc.Unit, i+1, len(services))
+		if err := restartUnit(ctx, svc.Unit); err != nil {
+			return fmt.Errorf("restarting %s: %w", svc.Unit, err)
+		}
+		if svc.ReadyURL == "" {
+			continue
+		}
+		if err := u.waitReady(ctx, svc.ReadyURL, timeout); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("%s is not ready after %s: %w", svc.Unit, timeout, err)
+		}
+		u.logf("%s is ready", svc.Unit)
+	}
+	return nil
+}
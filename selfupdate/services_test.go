@@ -0,0 +1,69 @@
+package selfupdate
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_ParseServices(t *testing.T) {
+	got, err := ParseServices(" b@1=http://localhost:8081/readyz, a ,,c@2")
+	want := []Service{{"b@1", "http://localhost:8081/readyz"}, {"a", ""}, {"c@2", ""}}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v (%v)", want, got, err)
+	}
+	if got, err := ParseServices(""); err != nil || got != nil {
+		t.Errorf("expected nothing, got %v (%v)", got, err)
+	}
+	for _, s := range []string{"=http://localhost/", "a=localhost", "a,b=:"} {
+		if _, err := ParseServices(s); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+}
+
+func Test_Updater_RestartServices(t *testing.T) {
+	ready := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ready.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer broken.Close()
+
+	saved := restartUnit
+	defer func() { restartUnit = saved }()
+	var restarted []string
+	restartUnit = func(ctx context.Context, unit string) error {
+		restarted = append(restarted, unit)
+		if unit == "fails" {
+			return errors.New("exit status 1")
+		}
+		return nil
+	}
+
+	verify := func(services string, wantRestarted, wantErr string) {
+		t.Helper()
+		list, err := ParseServices(strings.NewReplacer("READY", ready.URL, "BROKEN", broken.URL).Replace(services))
+		if err != nil {
+			t.Fatal(err)
+		}
+		restarted = nil
+		u := &Updater{Services: ServiceRestart{Services: list, Timeout: 10 * time.Millisecond}}
+		err = u.RestartServices(context.Background())
+		if got := strings.Join(restarted, ","); got != wantRestarted {
+			t.Errorf("%s: expected %s restarted, got %s", services, wantRestarted, got)
+		}
+		if wantErr == "" && err != nil || wantErr != "" && (err == nil || !strings.Contains(err.Error(), wantErr)) {
+			t.Errorf("%s: expected error %q, got %v", services, wantErr, err)
+		}
+	}
+	verify("", "", "")
+	verify("a=READY,b,c=READY", "a,b,c", "")
+	verify("a=READY,b=BROKEN,c=READY", "a,b", "b is not ready after 10ms: "+broken.URL+" returned 503")
+	verify("a,fails,c", "a,fails", "restarting fails: exit status 1")
+}
@@ -0,0 +1,184 @@
+package selfupdate
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// stagedUpdate describes the release staged by Update with
+// Updater.Staged, written as "<staged>.json" next to the staged
+// executable; see stagedPath.
+type stagedUpdate struct {
+	Tag    string      `json:"tag"`
+	Name   string      `json:"name"`
+	Size   int64       `json:"size"`
+	SHA256 []byte      `json:"sha256"`
+	SHA512 []byte      `json:"sha512,omitempty"`
+	Aux    []stagedAux `json:"aux,omitempty"`
+	// FileSHA256 is the digest of the staged executable, checked again
+	// before it is installed.
+	FileSHA256 []byte    `json:"file_sha256"`
+	StagedAt   time.Time `json:"staged_at"`
+}
+
+// stagedPath returns where a release for exePath is staged:
+// "<Path>.staged" in WorkDir or next to exePath.
+func (u *Updater) stagedPath(exePath string) (string, error) {
+	return u.workPath(exePath, ".staged")
+}
+
+// stage moves the verified artifact a to the staging path of exePath and
+// records it, together with the staged auxiliary files, for
+// ActivateStaged.
+func (u *Updater) stage(a Artifact, aux []stagedAux, exePath string) error {
+	staged, err := u.stagedPath(exePath)
+	if err != nil {
+		return err
+	}
+	digest, err := fileSHA256(a.Path)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(a.Path, staged); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(stagedUpdate{
+		Tag: a.Tag, Name: a.Name, Size: a.Size, SHA256: a.SHA256, SHA512: a.SHA512,
+		Aux: aux, FileSHA256: digest, StagedAt: time.Now().UTC(),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := staged + ".json.tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, staged+".json")
+}
+
+// readStaged returns the release staged as staged, or nil if none is.
+func readStaged(staged string) (*stagedUpdate, error) {
+	data, err := os.ReadFile(staged + ".json")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s stagedUpdate
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("invalid staged update: %w", err)
+	}
+	return &s, nil
+}
+
+// stagedTag returns the tag of the staged release of exePath, if any.
+func (u *Updater) stagedTag(exePath string) string {
+	staged, err := u.stagedPath(exePath)
+	if err != nil {
+		return ""
+	}
+	s, err := readStaged(staged)
+	if err != nil || s == nil {
+		return ""
+	}
+	if _, err := os.Stat(staged); err != nil {
+		return ""
+	}
+	return s.Tag
+}
+
+// discardStaged removes the release s staged as staged.
+func discardStaged(staged string, s *stagedUpdate) {
+	if s != nil {
+		discardAux(s.Aux)
+	}
+	os.Remove(staged)
+	os.Remove(staged + ".json")
+}
+
+// ActivateStaged installs the release staged by Update with
+// Updater.Staged, if any, and returns its tag; the caller should then
+// exit to be restarted. It returns "" if nothing is staged or the staged
+// release is already running. A staged executable that changed since it
+// was verified is discarded with ErrChecksumMismatch.
+func (u *Updater) ActivateStaged(ctx context.Context) (tag string, err error) {
+	if !Enabled() {
+		return "", nil
+	}
+	exePath, err := u.path()
+	if err == nil {
+		exePath, err = u.overlayPath(exePath)
+	}
+	if err != nil {
+		return "", err
+	}
+	staged, err := u.stagedPath(exePath)
+	if err != nil {
+		return "", err
+	}
+	s, err := readStaged(staged)
+	if err != nil || s == nil {
+		return "", err
+	}
+	if !u.busy.CompareAndSwap(false, true) {
+		return "", ErrBusy
+	}
+	defer u.busy.Store(false)
+	if s.Tag == u.Build.Version {
+		discardStaged(staged, s)
+		return "", nil
+	}
+	info := u.newUpdateInfo()
+	info.Remote = s.Tag
+	start := time.Now()
+	defer func() {
+		info.Durations.Total = time.Since(start)
+		if err == nil && tag == "" {
+			return
+		}
+		e := HistoryEntry{From: info.Current, To: s.Tag, Decision: DecisionUpgraded,
+			Trigger: auditSource(ctx), Durations: info.Durations}
+		if err != nil {
+			e.Decision, e.Error = DecisionFailed, err.Error()
+		}
+		u.recordHistory(e)
+	}()
+
+	digest, err := fileSHA256(staged)
+	if err != nil {
+		discardStaged(staged, s)
+		return "", fmt.Errorf("staged update: %w", err)
+	}
+	if !bytes.Equal(digest, s.FileSHA256) {
+		discardStaged(staged, s)
+		return "", fmt.Errorf("%w: staged %s changed since it was verified", ErrChecksumMismatch, s.Tag)
+	}
+	os.Remove(staged + ".json")
+	a := Artifact{Path: staged, Name: s.Name, Tag: s.Tag, Size: s.Size, SHA256: s.SHA256, SHA512: s.SHA512}
+	if err := u.apply(ctx, a, s.Aux, exePath, info); err != nil {
+		return "", err
+	}
+	u.logf("Activated staged %s – exiting for systemd restart.", s.Tag)
+	return s.Tag, nil
+}
+
+func fileSHA256(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,90 @@
+package selfupdate_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/msmania/updater/selfupdate"
+	"github.com/msmania/updater/selfupdate/selfupdatetest"
+)
+
+func Test_Updater_staged(t *testing.T) {
+	skipIfDisabled(t)
+	srv := selfupdatetest.NewServer("owner", "app",
+		selfupdatetest.Release{Tag: "v1.1.0", Assets: []selfupdatetest.Asset{
+			{Name: "app-bin", Content: []byte("v1.1")},
+			{Name: "app.conf", Content: []byte("conf 1.1")},
+		}},
+	)
+	defer srv.Close()
+	u := newUpdater(t, srv, "v1.0.0")
+	u.Staged = true
+	conf := filepath.Join(filepath.Dir(u.Path), "app.conf")
+	u.AuxFiles = []selfupdate.AuxFile{{Asset: "app.conf", Path: conf}}
+	ctx := context.Background()
+	downloads := func() (n int) {
+		for _, r := range srv.Requests() {
+			if strings.Contains(r, "/download/") {
+				n++
+			}
+		}
+		return n
+	}
+
+	for range 2 {
+		info, err := u.Update(ctx)
+		if err != nil || info.Decision != selfupdate.DecisionStaged {
+			t.Fatalf("expected a staged update, got %s: %v", info.Decision, err)
+		}
+	}
+	if n := downloads(); n != 2 {
+		t.Errorf("expected the release to be downloaded once, got %d downloads", n)
+	}
+	if b, _ := os.ReadFile(u.Path); string(b) != "old" {
+		t.Error("staged update must not replace the binary: " + string(b))
+	}
+	if _, err := os.Stat(conf); !os.IsNotExist(err) {
+		t.Error("staged update must not install auxiliary files")
+	}
+	if st := u.Status(); st.Result != selfupdate.ResultStaged {
+		t.Errorf("unexpected status %q", st.Result)
+	}
+
+	if tag, err := u.ActivateStaged(ctx); err != nil || tag != "v1.1.0" {
+		t.Fatalf("activation failed: %q %v", tag, err)
+	}
+	if b, _ := os.ReadFile(u.Path); string(b) != "v1.1" {
+		t.Error("staged binary not installed: " + string(b))
+	}
+	if b, _ := os.ReadFile(conf); string(b) != "conf 1.1" {
+		t.Error("staged auxiliary file not installed: " + string(b))
+	}
+	if tag, err := u.ActivateStaged(ctx); err != nil || tag != "" {
+		t.Errorf("nothing should be staged: %q %v", tag, err)
+	}
+
+	srv.AddRelease(selfupdatetest.Release{Tag: "v1.2.0", Assets: []selfupdatetest.Asset{
+		{Name: "app-bin", Content: []byte("v1.2")},
+		{Name: "app.conf", Content: []byte("conf 1.2")},
+	}})
+	u.Build.Version = "v1.1.0"
+	if info, err := u.Update(ctx); err != nil || info.Decision != selfupdate.DecisionStaged {
+		t.Fatalf("expected a staged update, got %s: %v", info.Decision, err)
+	}
+	if err := os.WriteFile(u.Path+".staged", []byte("tampered"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u.ActivateStaged(ctx); !errors.Is(err, selfupdate.ErrChecksumMismatch) {
+		t.Error("expected ErrChecksumMismatch, got", err)
+	}
+	if b, _ := os.ReadFile(u.Path); string(b) != "v1.1" {
+		t.Error("tampered staged binary installed: " + string(b))
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(filepath.Dir(u.Path), "*staged*")); len(leftovers) != 0 {
+		t.Error("rejected staged update left behind:", leftovers)
+	}
+}
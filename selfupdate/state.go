@@ -0,0 +1,55 @@
+package selfupdate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// statusFile is the name of the persisted Status below Updater.StateDir.
+const statusFile = "status.json"
+
+// stateSeq makes the temporary files of concurrent writeState calls
+// distinct.
+var stateSeq atomic.Uint64
+
+// writeState atomically replaces StateDir/name in u.FS with v encoded as
+// JSON.
+func (u *Updater) writeState(name string, v any) error {
+	fsys := u.fs()
+	if err := fsys.MkdirAll(u.StateDir, 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(u.StateDir, name)
+	tmp := fmt.Sprintf("%s.%d-%d", path, os.Getpid(), stateSeq.Add(1))
+	if err := fsys.WriteFile(tmp, data, 0o600); err != nil {
+		fsys.Remove(tmp)
+		return err
+	}
+	if err := fsys.Rename(tmp, path); err != nil {
+		fsys.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// readState decodes StateDir/name in u.FS into v. A missing file is not
+// an error and leaves v unchanged.
+func (u *Updater) readState(name string, v any) error {
+	data, err := u.fs().ReadFile(filepath.Join(u.StateDir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
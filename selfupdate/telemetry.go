@@ -0,0 +1,102 @@
+package selfupdate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TelemetryReport is the anonymous outcome of an update attempt, posted
+// to Updater.TelemetryURL. It names no host, path, address or error
+// message, only what helps judge the health of a rollout.
+type TelemetryReport struct {
+	From       string   `json:"from"`
+	To         string   `json:"to,omitempty"`
+	Platform   string   `json:"platform"`
+	Channel    Channel  `json:"channel"`
+	Decision   Decision `json:"decision"`
+	DurationMS int64    `json:"duration_ms"`
+	// ErrorClass names the kind of failure, e.g. "checksum-mismatch";
+	// see ErrorClass.
+	ErrorClass string `json:"error_class,omitempty"`
+}
+
+// telemetryTimeout bounds the post of a TelemetryReport, which holds up
+// the end of an update.
+const telemetryTimeout = 5 * time.Second
+
+// classifiedErrors are the errors ErrorClass names by their message.
+var classifiedErrors = []error{
+	ErrNoAsset, ErrAmbiguousAsset, ErrRateLimited, ErrChecksumMismatch, ErrSignatureInvalid,
+	ErrNoRelease, ErrMajorUpgrade, ErrBusy, ErrCrashLoop, ErrNotLeader, ErrPolicyViolation,
+	ErrRolloutPaused, ErrDownloadInterrupted, ErrSizeMismatch, ErrUnsafeArchive, ErrNotLogged,
+	ErrPackageManaged, ErrDeferred, ErrDisabled, ErrLocked, ErrModified,
+}
+
+// ErrorClass returns a short name for the kind of err that reveals
+// nothing about the host: the sentinel error it matches, such as
+// "checksum-mismatch", else "http-<status>", "timeout", "network" or
+// "other". It returns "" for a nil error.
+func ErrorClass(err error) string {
+	if err == nil {
+		return ""
+	}
+	for _, e := range classifiedErrors {
+		if errors.Is(err, e) {
+			return strings.ReplaceAll(e.Error(), " ", "-")
+		}
+	}
+	var httpErr *HTTPError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &httpErr):
+		return "http-" + strconv.Itoa(httpErr.StatusCode)
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &netErr):
+		return "network"
+	}
+	return "other"
+}
+
+// sendTelemetry posts the outcome of an update to TelemetryURL, if set.
+// Failures are only logged.
+func (u *Updater) sendTelemetry(info *UpdateInfo, err error) {
+	if u.TelemetryURL == "" {
+		return
+	}
+	body, merr := json.Marshal(TelemetryReport{
+		From:       info.Current,
+		To:         info.Remote,
+		Platform:   u.Build.Platform,
+		Channel:    info.Channel,
+		Decision:   info.Decision,
+		DurationMS: info.Durations.Total.Milliseconds(),
+		ErrorClass: ErrorClass(err),
+	})
+	if merr != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), telemetryTimeout)
+	defer cancel()
+	req, rerr := http.NewRequestWithContext(ctx, http.MethodPost, u.TelemetryURL, bytes.NewReader(body))
+	if rerr != nil {
+		u.logf("telemetry: %v", rerr)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, rerr := u.http().do(req)
+	if rerr == nil {
+		defer drainClose(resp.Body)
+		rerr = checkResponse(resp)
+	}
+	if rerr != nil {
+		u.logf("telemetry: %v", rerr)
+	}
+}
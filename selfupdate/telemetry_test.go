@@ -0,0 +1,68 @@
+package selfupdate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_ErrorClass(t *testing.T) {
+	verify := func(err error, want string) {
+		t.Helper()
+		if got := ErrorClass(err); got != want {
+			t.Errorf("ErrorClass(%v) = %q, want %q", err, got, want)
+		}
+	}
+	verify(nil, "")
+	verify(fmt.Errorf("verification failed: %w", ErrChecksumMismatch), "checksum-mismatch")
+	verify(&RateLimitError{}, "rate-limited")
+	verify(&HTTPError{URL: "https://secret.example/x", StatusCode: 503}, "http-503")
+	verify(fmt.Errorf("get: %w", context.DeadlineExceeded), "timeout")
+	verify(&net.OpError{Op: "dial", Err: errors.New("refused")}, "network")
+	verify(errors.New("/home/alice/app: permission denied"), "other")
+}
+
+func Test_Updater_telemetry(t *testing.T) {
+	skipIfDisabled(t)
+	reports := make(chan TelemetryReport, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/telemetry" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		var rep TelemetryReport
+		if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&rep) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		reports <- rep
+	}))
+	defer srv.Close()
+	u := &Updater{Owner: "o", Repo: "r", APIURL: srv.URL,
+		Build: BuildInfo{Version: "v1.0.0", Platform: "linux/arm64"}}
+	if _, err := u.Update(context.Background()); err == nil {
+		t.Fatal("expected the update to fail")
+	}
+	select {
+	case rep := <-reports:
+		t.Errorf("telemetry sent without opting in: %+v", rep)
+	default:
+	}
+
+	u.TelemetryURL = srv.URL + "/telemetry"
+	info, _ := u.Update(context.Background())
+	select {
+	case rep := <-reports:
+		if rep.From != "v1.0.0" || rep.Platform != "linux/arm64" || rep.Decision != DecisionFailed ||
+			rep.ErrorClass != "http-503" || rep.DurationMS != info.Durations.Total.Milliseconds() {
+			t.Errorf("unexpected report %+v", rep)
+		}
+	default:
+		t.Error("no telemetry after a failed update")
+	}
+}
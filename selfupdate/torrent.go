@@ -0,0 +1,588 @@
+package selfupdate
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TorrentMirror may appear in Updater.Mirrors to fetch assets over
+// BitTorrent. The metainfo is the release asset "<asset>.torrent"; pieces
+// come from the peers announced by its HTTP trackers, and whatever they
+// do not deliver from the torrent's web seeds and finally the asset's
+// GitHub URL (BEP 19). Every piece is checked against the SHA-1 of the
+// metainfo and the whole asset against its published digest, which is
+// therefore required. The updater only downloads: it neither accepts
+// connections nor uploads, and peers are dialed directly rather than
+// through Transport.Proxy.
+const TorrentMirror = "torrent"
+
+// TorrentSuffix is appended to an asset name to find its metainfo.
+const TorrentSuffix = ".torrent"
+
+const (
+	maxTorrentSize     = 1 << 20
+	maxPieceLength     = 16 << 20
+	torrentBlockSize   = 16 << 10
+	torrentPeers       = 4 // peers downloaded from at once
+	torrentPeerTimeout = 30 * time.Second
+	// torrentPort is announced to trackers, which require one; nothing
+	// listens on it.
+	torrentPort = 6881
+)
+
+var errBencode = errors.New("invalid bencoding")
+
+// maxBencodeDepth bounds the nesting of bencoded lists and dictionaries.
+const maxBencodeDepth = 32
+
+// bdecode decodes bencoded data (BEP 3) into int64, string, []any and
+// map[string]any values. info is the raw encoding of the top-level
+// "info" entry, whose SHA-1 is the info hash.
+func bdecode(data []byte) (v any, info []byte, err error) {
+	d := &bdecoder{data: data}
+	v, err = d.value(0)
+	if err == nil && d.pos != len(data) {
+		err = errBencode
+	}
+	return v, d.info, err
+}
+
+type bdecoder struct {
+	data []byte
+	pos  int
+	info []byte
+}
+
+func (d *bdecoder) value(depth int) (any, error) {
+	if depth > maxBencodeDepth || d.pos >= len(d.data) {
+		return nil, errBencode
+	}
+	switch c := d.data[d.pos]; {
+	case c == 'i':
+		end := bytes.IndexByte(d.data[d.pos:], 'e')
+		if end < 0 {
+			return nil, errBencode
+		}
+		n, err := strconv.ParseInt(string(d.data[d.pos+1:d.pos+end]), 10, 64)
+		if err != nil {
+			return nil, errBencode
+		}
+		d.pos += end + 1
+		return n, nil
+	case c >= '0' && c <= '9':
+		colon := bytes.IndexByte(d.data[d.pos:], ':')
+		if colon < 0 {
+			return nil, errBencode
+		}
+		start := d.pos + colon + 1
+		n, err := strconv.Atoi(string(d.data[d.pos : d.pos+colon]))
+		if err != nil || n < 0 || n > len(d.data)-start {
+			return nil, errBencode
+		}
+		d.pos = start + n
+		return string(d.data[start:d.pos]), nil
+	case c == 'l':
+		d.pos++
+		list := []any{}
+		for d.pos < len(d.data) && d.data[d.pos] != 'e' {
+			v, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, d.end()
+	case c == 'd':
+		d.pos++
+		dict := map[string]any{}
+		for d.pos < len(d.data) && d.data[d.pos] != 'e' {
+			k, err := d.value(depth + 1)
+			key, ok := k.(string)
+			if err != nil || !ok {
+				return nil, errBencode
+			}
+			start := d.pos
+			v, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			if depth == 0 && key == "info" {
+				d.info = d.data[start:d.pos]
+			}
+			dict[key] = v
+		}
+		return dict, d.end()
+	}
+	return nil, errBencode
+}
+
+// end consumes the 'e' closing a list or dictionary.
+func (d *bdecoder) end() error {
+	if d.pos >= len(d.data) {
+		return errBencode
+	}
+	d.pos++
+	return nil
+}
+
+// torrentMeta is what is used of a single-file metainfo.
+type torrentMeta struct {
+	trackers    []string
+	webSeeds    []string
+	infoHash    [sha1.Size]byte
+	length      int64
+	pieceLength int64
+	pieces      [][sha1.Size]byte
+}
+
+// parseTorrent parses a metainfo file. Torrents of several files are
+// rejected, since a release asset is a single file.
+func parseTorrent(data []byte) (*torrentMeta, error) {
+	v, rawInfo, err := bdecode(data)
+	if err != nil {
+		return nil, err
+	}
+	top, _ := v.(map[string]any)
+	info, _ := top["info"].(map[string]any)
+	if info == nil {
+		return nil, errors.New("metainfo has no info dictionary")
+	}
+	if _, ok := info["files"]; ok {
+		return nil, errors.New("multi-file torrents are not supported")
+	}
+	m := &torrentMeta{infoHash: sha1.Sum(rawInfo)}
+	m.length, _ = info["length"].(int64)
+	m.pieceLength, _ = info["piece length"].(int64)
+	pieces, _ := info["pieces"].(string)
+	if m.length <= 0 || m.pieceLength <= 0 || m.pieceLength > maxPieceLength || len(pieces)%sha1.Size != 0 ||
+		int64(len(pieces)/sha1.Size) != (m.length+m.pieceLength-1)/m.pieceLength {
+		return nil, errors.New("metainfo has an invalid length, piece length or piece hashes")
+	}
+	m.pieces = make([][sha1.Size]byte, len(pieces)/sha1.Size)
+	for i := range m.pieces {
+		copy(m.pieces[i][:], pieces[i*sha1.Size:])
+	}
+
+	if s, ok := top["announce"].(string); ok {
+		m.trackers = append(m.trackers, s)
+	}
+	tiers, _ := top["announce-list"].([]any)
+	for _, tier := range tiers {
+		list, _ := tier.([]any)
+		for _, t := range list {
+			if s, ok := t.(string); ok && !slices.Contains(m.trackers, s) {
+				m.trackers = append(m.trackers, s)
+			}
+		}
+	}
+	name, _ := info["name"].(string)
+	seeds := []any{top["url-list"]}
+	if list, ok := top["url-list"].([]any); ok {
+		seeds = list
+	}
+	for _, s := range seeds {
+		if s, ok := s.(string); ok && s != "" {
+			if strings.HasSuffix(s, "/") {
+				s += url.PathEscape(name)
+			}
+			m.webSeeds = append(m.webSeeds, s)
+		}
+	}
+	return m, nil
+}
+
+// pieceSize returns the length of piece i; the last one may be short.
+func (m *torrentMeta) pieceSize(i int) int64 {
+	return min(m.pieceLength, m.length-int64(i)*m.pieceLength)
+}
+
+// announce asks an HTTP tracker for peers of m and returns their
+// addresses.
+func (f *fetcher) announce(ctx context.Context, tracker string, m *torrentMeta, peerID []byte) ([]string, error) {
+	q := url.Values{
+		"info_hash":  {string(m.infoHash[:])},
+		"peer_id":    {string(peerID)},
+		"port":       {strconv.Itoa(torrentPort)},
+		"uploaded":   {"0"},
+		"downloaded": {"0"},
+		"left":       {strconv.FormatInt(m.length, 10)},
+		"compact":    {"1"},
+		"event":      {"started"},
+	}
+	sep := "?"
+	if strings.Contains(tracker, "?") {
+		sep = "&"
+	}
+	data, err := f.fetchSmall(ctx, tracker+sep+q.Encode(), maxTorrentSize)
+	if err != nil {
+		return nil, err
+	}
+	v, _, err := bdecode(data)
+	if err != nil {
+		return nil, err
+	}
+	resp, _ := v.(map[string]any)
+	if reason, ok := resp["failure reason"].(string); ok {
+		return nil, fmt.Errorf("tracker refused: %s", reason)
+	}
+	var peers []string
+	switch list := resp["peers"].(type) {
+	case string: // compact: 4-byte IPv4 address and port
+		for i := 0; i+6 <= len(list); i += 6 {
+			ip := net.IP([]byte(list[i : i+4]))
+			port := binary.BigEndian.Uint16([]byte(list[i+4 : i+6]))
+			peers = append(peers, net.JoinHostPort(ip.String(), strconv.Itoa(int(port))))
+		}
+	case []any:
+		for _, p := range list {
+			p, _ := p.(map[string]any)
+			ip, _ := p["ip"].(string)
+			port, _ := p["port"].(int64)
+			if ip != "" && port > 0 {
+				peers = append(peers, net.JoinHostPort(ip, strconv.FormatInt(port, 10)))
+			}
+		}
+	}
+	return peers, nil
+}
+
+// torrentJob collects the verified pieces of a torrent in a file.
+type torrentJob struct {
+	meta *torrentMeta
+	out  *os.File
+
+	mu   sync.Mutex
+	done []bool
+	busy []bool
+}
+
+// claim returns a piece that is neither done nor being downloaded and
+// for which has returns true, or -1.
+func (j *torrentJob) claim(has func(int) bool) int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for i := range j.done {
+		if !j.done[i] && !j.busy[i] && has(i) {
+			j.busy[i] = true
+			return i
+		}
+	}
+	return -1
+}
+
+// release gives up a claimed piece.
+func (j *torrentJob) release(i int) {
+	j.mu.Lock()
+	j.busy[i] = false
+	j.mu.Unlock()
+}
+
+// store verifies piece i against its hash and writes it.
+func (j *torrentJob) store(i int, data []byte) error {
+	if sha1.Sum(data) != j.meta.pieces[i] {
+		return fmt.Errorf("piece %d fails its hash", i)
+	}
+	if _, err := j.out.WriteAt(data, int64(i)*j.meta.pieceLength); err != nil {
+		return err
+	}
+	j.mu.Lock()
+	j.done[i], j.busy[i] = true, false
+	j.mu.Unlock()
+	return nil
+}
+
+// missing returns the pieces not stored yet.
+func (j *torrentJob) missing() []int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var out []int
+	for i, ok := range j.done {
+		if !ok {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+// Peer wire message IDs (BEP 3).
+const (
+	msgChoke      = 0
+	msgUnchoke    = 1
+	msgInterested = 2
+	msgHave       = 4
+	msgBitfield   = 5
+	msgRequest    = 6
+	msgPiece      = 7
+)
+
+const torrentProtocol = "\x13BitTorrent protocol"
+
+// leech downloads the pieces addr has until none is left to claim. A
+// piece that fails its hash ends the session.
+func (j *torrentJob) leech(ctx context.Context, addr string, peerID []byte) error {
+	d := net.Dialer{Timeout: torrentPeerTimeout}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	conn.SetDeadline(time.Now().Add(torrentPeerTimeout))
+	hs := append([]byte(torrentProtocol), make([]byte, 8)...)
+	hs = append(append(hs, j.meta.infoHash[:]...), peerID...)
+	if _, err := conn.Write(hs); err != nil {
+		return err
+	}
+	reply := make([]byte, len(hs))
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if string(reply[:len(torrentProtocol)]) != torrentProtocol || !bytes.Equal(reply[28:48], j.meta.infoHash[:]) {
+		return errors.New("handshake for another torrent or protocol")
+	}
+	if err := writePeerMessage(conn, msgInterested, nil); err != nil {
+		return err
+	}
+
+	has := make([]bool, len(j.meta.pieces))
+	choked, piece := true, -1
+	var buf []byte
+	var received int64
+	defer func() {
+		if piece >= 0 {
+			j.release(piece)
+		}
+	}()
+	for {
+		if !choked && piece < 0 {
+			if piece = j.claim(func(i int) bool { return has[i] }); piece < 0 {
+				return nil
+			}
+			buf, received = make([]byte, j.meta.pieceSize(piece)), 0
+			for off := 0; off < len(buf); off += torrentBlockSize {
+				req := make([]byte, 12)
+				binary.BigEndian.PutUint32(req, uint32(piece))
+				binary.BigEndian.PutUint32(req[4:], uint32(off))
+				binary.BigEndian.PutUint32(req[8:], uint32(min(torrentBlockSize, len(buf)-off)))
+				if err := writePeerMessage(conn, msgRequest, req); err != nil {
+					return err
+				}
+			}
+		}
+		conn.SetDeadline(time.Now().Add(torrentPeerTimeout))
+		id, payload, err := readPeerMessage(conn, max(torrentBlockSize+9, len(has)/8+2))
+		if err != nil {
+			return err
+		}
+		switch id {
+		case msgChoke:
+			choked = true
+			if piece >= 0 {
+				j.release(piece)
+				piece = -1
+			}
+		case msgUnchoke:
+			choked = false
+		case msgHave:
+			if len(payload) == 4 {
+				if i := int(binary.BigEndian.Uint32(payload)); i < len(has) {
+					has[i] = true
+				}
+			}
+		case msgBitfield:
+			for i := range has {
+				has[i] = i/8 < len(payload) && payload[i/8]&(0x80>>(i%8)) != 0
+			}
+		case msgPiece:
+			if len(payload) < 8 || piece < 0 || int(binary.BigEndian.Uint32(payload)) != piece {
+				continue
+			}
+			begin, block := int64(binary.BigEndian.Uint32(payload[4:])), payload[8:]
+			if begin+int64(len(block)) > int64(len(buf)) {
+				return errors.New("block out of range")
+			}
+			copy(buf[begin:], block)
+			if received += int64(len(block)); received >= int64(len(buf)) {
+				if err := j.store(piece, buf); err != nil {
+					return err
+				}
+				piece = -1
+			}
+		}
+	}
+}
+
+func writePeerMessage(w io.Writer, id byte, payload []byte) error {
+	msg := make([]byte, 5, 5+len(payload))
+	binary.BigEndian.PutUint32(msg, uint32(1+len(payload)))
+	msg[4] = id
+	_, err := w.Write(append(msg, payload...))
+	return err
+}
+
+// readPeerMessage reads the next message, skipping keep-alives. Messages
+// longer than limit bytes are rejected.
+func readPeerMessage(r io.Reader, limit int) (byte, []byte, error) {
+	for {
+		var n uint32
+		if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+			return 0, nil, err
+		}
+		if n == 0 {
+			continue
+		}
+		if int64(n) > int64(limit) {
+			return 0, nil, fmt.Errorf("peer message of %d bytes", n)
+		}
+		msg := make([]byte, n)
+		if _, err := io.ReadFull(r, msg); err != nil {
+			return 0, nil, err
+		}
+		return msg[0], msg[1:], nil
+	}
+}
+
+// torrentDownload fetches asset to dst through its torrent; see
+// TorrentMirror.
+func (u *Updater) torrentDownload(ctx context.Context, asset *ghAsset, dst string, want Digest,
+	dec Decompressor) (res downloadResult, err error) {
+	ctx, span := u.tracer().Start(ctx, SpanDownload)
+	span.SetAttributes(Attr("updater.asset.url", asset.BrowserDownloadURL+TorrentSuffix))
+	defer func() {
+		span.SetAttributes(Attr("updater.bytes", res.Size), Attr("updater.retries", res.Retries))
+		endSpan(span, err)
+	}()
+	if want.Algorithm == "" {
+		return downloadResult{}, errors.New("torrent downloads need a published digest")
+	}
+	data, err := u.http().fetchSmall(ctx, asset.BrowserDownloadURL+TorrentSuffix, maxTorrentSize)
+	if err != nil {
+		return downloadResult{}, fmt.Errorf("metainfo: %w", err)
+	}
+	meta, err := parseTorrent(data)
+	if err != nil {
+		return downloadResult{}, fmt.Errorf("metainfo: %w", err)
+	}
+	if err := checkSize(meta.length, asset.Size); err != nil {
+		return downloadResult{}, err
+	}
+
+	part, err := os.CreateTemp(filepath.Dir(dst), filepath.Base(dst)+".part*")
+	if err != nil {
+		return downloadResult{}, err
+	}
+	defer os.Remove(part.Name())
+	defer part.Close()
+	job := &torrentJob{meta: meta, out: part,
+		done: make([]bool, len(meta.pieces)), busy: make([]bool, len(meta.pieces))}
+
+	u.torrentPeers(ctx, job)
+	if missing := job.missing(); len(missing) > 0 {
+		seeds := append(meta.webSeeds, asset.BrowserDownloadURL)
+		if res.Retries, err = u.torrentWebSeeds(ctx, job, seeds, missing); err != nil {
+			return res, err
+		}
+	}
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o755)
+	if err != nil {
+		return res, err
+	}
+	opts := downloadOptions{withSHA512: want.Algorithm == "sha512", decompress: dec,
+		maxSize: u.MaxExtractSize, expectedSize: meta.length}
+	hw, finish := opts.sink(out)
+	_, err = io.Copy(hw, io.NewSectionReader(part, 0, meta.length))
+	err = finish(err)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	retries := res.Retries
+	res = hw.result()
+	res.Retries = retries
+	return res, err
+}
+
+// torrentPeers downloads what it can of job from the peers announced by
+// the trackers of the torrent. Failures only leave pieces to the web
+// seeds.
+func (u *Updater) torrentPeers(ctx context.Context, job *torrentJob) {
+	peerID := append([]byte("-UP0001-"), make([]byte, 12)...)
+	rand.Read(peerID[8:])
+	var peers []string
+	for _, tracker := range job.meta.trackers {
+		if !strings.HasPrefix(tracker, "http://") && !strings.HasPrefix(tracker, "https://") {
+			u.logf("Skipping tracker %s: only HTTP trackers are supported", tracker)
+			continue
+		}
+		list, err := u.http().announce(ctx, tracker, job.meta, peerID)
+		if err != nil {
+			u.logf("Tracker %s failed: %v", tracker, err)
+			continue
+		}
+		for _, p := range list {
+			if !slices.Contains(peers, p) {
+				peers = append(peers, p)
+			}
+		}
+	}
+	sem := make(chan struct{}, torrentPeers)
+	var wg sync.WaitGroup
+	for _, p := range peers {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			if err := job.leech(ctx, p, peerID); err != nil {
+				u.logf("Peer %s failed: %v", p, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// torrentWebSeeds fetches the missing pieces of job with ranged requests,
+// trying seeds in order for each piece. It returns the number of retries.
+func (u *Updater) torrentWebSeeds(ctx context.Context, job *torrentJob, seeds []string, missing []int) (int, error) {
+	var retries int
+	for _, i := range missing {
+		from := int64(i) * job.meta.pieceLength
+		to := from + job.meta.pieceSize(i) - 1
+		var errs []error
+		for _, seed := range seeds {
+			data, n, err := u.http().fetchSegment(ctx, seed, "", from, to)
+			retries += n
+			if err == nil {
+				err = job.store(i, data)
+			}
+			if err == nil {
+				errs = nil
+				break
+			}
+			if ctx.Err() != nil {
+				return retries, ctx.Err()
+			}
+			errs = append(errs, fmt.Errorf("web seed %s: %w", seed, err))
+		}
+		if len(errs) > 0 {
+			return retries, fmt.Errorf("piece %d: %w", i, errors.Join(errs...))
+		}
+	}
+	return retries, nil
+}
@@ -0,0 +1,196 @@
+package selfupdate
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// bencode encodes int64, string, []any and map[string]any values.
+func bencode(v any) string {
+	switch v := v.(type) {
+	case int64:
+		return "i" + strconv.FormatInt(v, 10) + "e"
+	case string:
+		return strconv.Itoa(len(v)) + ":" + v
+	case []any:
+		s := "l"
+		for _, e := range v {
+			s += bencode(e)
+		}
+		return s + "e"
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		s := "d"
+		for _, k := range keys {
+			s += bencode(k) + bencode(v[k])
+		}
+		return s + "e"
+	}
+	panic(fmt.Sprintf("cannot bencode %T", v))
+}
+
+func Test_bdecode(t *testing.T) {
+	v, info, err := bdecode([]byte("d4:infod6:lengthi3ee4:listl1:ai-2eee"))
+	if err != nil || string(info) != "d6:lengthi3ee" {
+		t.Fatalf("bdecode = %v, %q, %v", v, info, err)
+	}
+	if list := v.(map[string]any)["list"].([]any); list[0] != "a" || list[1] != int64(-2) {
+		t.Errorf("unexpected list %v", list)
+	}
+	for _, s := range []string{"", "i1", "ixe", "5:abc", "l1:a", "di1e1:ae", "1:ab", strings.Repeat("l", 100)} {
+		if _, _, err := bdecode([]byte(s)); err == nil {
+			t.Errorf("%q must not decode", s)
+		}
+	}
+}
+
+// seedPeer serves the pieces of content marked in have to one peer at a
+// time, corrupting those in corrupt.
+func seedPeer(t *testing.T, infoHash [20]byte, content []byte, pieceLength int, have, corrupt map[int]bool) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	send := func(c net.Conn, id byte, payload []byte) {
+		writePeerMessage(c, id, payload)
+	}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				hs := make([]byte, 68)
+				if _, err := io.ReadFull(c, hs); err != nil || !bytes.Equal(hs[28:48], infoHash[:]) {
+					return
+				}
+				c.Write(hs)
+				bitfield := make([]byte, (len(content)/pieceLength+8)/8)
+				for i := range have {
+					bitfield[i/8] |= 0x80 >> (i % 8)
+				}
+				send(c, msgBitfield, bitfield)
+				for {
+					id, payload, err := readPeerMessage(c, 1<<20)
+					if err != nil {
+						return
+					}
+					switch id {
+					case msgInterested:
+						send(c, msgUnchoke, nil)
+					case msgRequest:
+						i := int(binary.BigEndian.Uint32(payload))
+						begin := int(binary.BigEndian.Uint32(payload[4:]))
+						n := int(binary.BigEndian.Uint32(payload[8:]))
+						block := append([]byte(nil), content[i*pieceLength+begin:i*pieceLength+begin+n]...)
+						if corrupt[i] {
+							block[0] ^= 0xff
+						}
+						send(c, msgPiece, append(payload[:8:8], block...))
+					}
+				}
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func Test_torrentDownload(t *testing.T) {
+	const pieceLength = 32 << 10
+	content := make([]byte, 4*pieceLength-1000)
+	for i := range content {
+		content[i] = byte(i * 7)
+	}
+	var pieces string
+	for off := 0; off < len(content); off += pieceLength {
+		sum := sha1.Sum(content[off:min(off+pieceLength, len(content))])
+		pieces += string(sum[:])
+	}
+	info := map[string]any{"name": "app", "length": int64(len(content)),
+		"piece length": int64(pieceLength), "pieces": pieces}
+	infoHash := sha1.Sum([]byte(bencode(info)))
+	peer := seedPeer(t, infoHash, content, pieceLength, map[int]bool{0: true, 1: true}, map[int]bool{1: true})
+
+	var mu sync.Mutex
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/app.torrent":
+			io.WriteString(w, bencode(map[string]any{"announce": "http://" + r.Host + "/announce",
+				"announce-list": []any{[]any{"udp://tracker.example:1337"}}, "info": info}))
+		case "/announce":
+			if r.URL.Query().Get("info_hash") != string(infoHash[:]) {
+				io.WriteString(w, bencode(map[string]any{"failure reason": "unknown torrent"}))
+				return
+			}
+			host, port, _ := net.SplitHostPort(peer)
+			p, _ := strconv.Atoi(port)
+			compact := append(net.ParseIP(host).To4(), byte(p>>8), byte(p))
+			io.WriteString(w, bencode(map[string]any{"interval": int64(1800), "peers": string(compact)}))
+		case "/app":
+			mu.Lock()
+			ranges = append(ranges, r.Header.Get("Range"))
+			mu.Unlock()
+			http.ServeContent(w, r, "app", time.Time{}, bytes.NewReader(content))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	u := &Updater{HTTPClient: srv.Client()}
+	asset := &ghAsset{Name: "app", Size: int64(len(content)), BrowserDownloadURL: srv.URL + "/app"}
+	sum := sha256.Sum256(content)
+	want := Digest{"sha256", sum[:]}
+	dst := filepath.Join(t.TempDir(), "app")
+	res, err := u.torrentDownload(context.Background(), asset, dst, want, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := res.verify(want); err != nil {
+		t.Error(err)
+	}
+	if got, _ := os.ReadFile(dst); !bytes.Equal(got, content) {
+		t.Error("content mismatch")
+	}
+	// Piece 0 came from the peer; piece 1 failed its hash there.
+	wantRanges := fmt.Sprintf("[bytes=%d-%d bytes=%d-%d bytes=%d-%d]", pieceLength, 2*pieceLength-1,
+		2*pieceLength, 3*pieceLength-1, 3*pieceLength, len(content)-1)
+	if got := fmt.Sprint(ranges); got != wantRanges {
+		t.Errorf("web seed ranges %s, want %s", got, wantRanges)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(dst)); len(entries) != 1 {
+		t.Errorf("partial file left behind: %v", entries)
+	}
+
+	if _, err := u.torrentDownload(context.Background(), asset, dst, Digest{}, nil); err == nil {
+		t.Error("a torrent download without a digest must fail")
+	}
+	asset.Size++
+	if _, err := u.torrentDownload(context.Background(), asset, dst, want, nil); err == nil {
+		t.Error("a torrent of another size must fail")
+	}
+}
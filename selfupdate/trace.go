@@ -0,0 +1,73 @@
+package selfupdate
+
+import "context"
+
+// ---------------------------------------------------------------------
+// Tracing hooks for the update pipeline
+// ---------------------------------------------------------------------
+
+// Span names emitted by the Updater.
+const (
+	SpanUpdate   = "update"
+	SpanCheck    = "check"
+	SpanDownload = "download"
+	SpanVerify   = "verify"
+	SpanInstall  = "install"
+	SpanRestart  = "restart"
+)
+
+// Tracer starts spans for each stage of an update. It is deliberately a
+// small subset of the OpenTelemetry API so an adapter around an SDK tracer
+// is a few lines; OTLPTracer is a dependency-free implementation.
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a single timed operation.
+type Span interface {
+	SetAttributes(attrs ...Attribute)
+	RecordError(err error)
+	End()
+}
+
+// Attribute is a key/value pair attached to a span. Value should be a
+// string, bool, int, int64 or float64.
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// Attr is shorthand for constructing an Attribute.
+func Attr(key string, value any) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+type noopTracer struct{}
+type noopSpan struct{}
+
+func (noopTracer) Start(ctx context.Context, _ string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+func (noopSpan) SetAttributes(...Attribute) {}
+func (noopSpan) RecordError(error)          {}
+func (noopSpan) End()                       {}
+
+func (u *Updater) tracer() Tracer {
+	var t Tracer = noopTracer{}
+	if u.Tracer != nil {
+		t = u.Tracer
+		if o, ok := t.(*OTLPTracer); ok {
+			o.attach(u.Client())
+		}
+	}
+	return eventTracer{next: t, bus: &u.events}
+}
+
+// endSpan records err (if any) on span and ends it.
+func endSpan(span Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
@@ -0,0 +1,295 @@
+package selfupdate
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DefaultRekorURL is the public Sigstore transparency log.
+const DefaultRekorURL = "https://rekor.sigstore.dev"
+
+// DefaultRekorEntrySuffix is the default TransparencyConfig.EntrySuffix.
+const DefaultRekorEntrySuffix = ".rekor"
+
+// TransparencyConfig requires every installed asset to be recorded in a
+// Rekor transparency log. The release references the log entry through
+// an asset named after the installed one plus EntrySuffix, holding the
+// entry UUID. The entry must log the digest of the downloaded bytes and
+// be included in a tree whose checkpoint the log signed with PublicKey,
+// so an artifact swapped after publication no longer matches a logged
+// entry, and a forged entry is not in the public log.
+type TransparencyConfig struct {
+	// URL is the base URL of the log, e.g. DefaultRekorURL. Empty
+	// disables the check.
+	URL string
+	// EntrySuffix defaults to DefaultRekorEntrySuffix.
+	EntrySuffix string
+	// PublicKey is the log's ECDSA or Ed25519 key; see
+	// ParseRekorPublicKey.
+	PublicKey crypto.PublicKey
+}
+
+func (c TransparencyConfig) entrySuffix() string {
+	if c.EntrySuffix == "" {
+		return DefaultRekorEntrySuffix
+	}
+	return c.EntrySuffix
+}
+
+// ParseRekorPublicKey decodes a PEM-encoded PKIX public key of a
+// transparency log, as served at /api/v1/log/publicKey.
+func ParseRekorPublicKey(b []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("no PEM public key")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	switch key.(type) {
+	case *ecdsa.PublicKey, ed25519.PublicKey:
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported public key type %T", key)
+}
+
+// maxRekorEntrySize bounds how much of an entry reference or log entry is
+// read.
+const maxRekorEntrySize = 1 << 20
+
+// rekorEntry is a log entry as returned by /api/v1/log/entries/{uuid}.
+type rekorEntry struct {
+	Body         string `json:"body"`
+	LogIndex     int64  `json:"logIndex"`
+	Verification struct {
+		InclusionProof *struct {
+			Checkpoint string   `json:"checkpoint"`
+			Hashes     []string `json:"hashes"`
+			LogIndex   int64    `json:"logIndex"`
+			RootHash   string   `json:"rootHash"`
+			TreeSize   int64    `json:"treeSize"`
+		} `json:"inclusionProof"`
+	} `json:"verification"`
+}
+
+// rekorBody is the part of a hashedrekord or rekord entry body naming
+// the logged artifact.
+type rekorBody struct {
+	Kind string `json:"kind"`
+	Spec struct {
+		Data struct {
+			Hash struct {
+				Algorithm string `json:"algorithm"`
+				Value     string `json:"value"`
+			} `json:"hash"`
+		} `json:"data"`
+	} `json:"spec"`
+}
+
+// fetchRekorEntry looks up the entry uuid in the log at baseURL.
+func (f *fetcher) fetchRekorEntry(ctx context.Context, baseURL, uuid string) (*rekorEntry, error) {
+	url := strings.TrimSuffix(baseURL, "/") + "/api/v1/log/entries/" + uuid
+	resp, err := f.get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer drainClose(resp.Body)
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+	var entries map[string]rekorEntry
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxRekorEntrySize)).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("invalid log entry: %w", err)
+	}
+	for _, e := range entries {
+		if len(entries) == 1 {
+			return &e, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid log entry: %d entries", len(entries))
+}
+
+// checkTransparency verifies that the asset called name, downloaded as
+// res, is recorded in the transparency log, if one is configured.
+func (u *Updater) checkTransparency(ctx context.Context, rel *ghRelease, name string, res downloadResult) error {
+	cfg := u.Transparency
+	if cfg.URL == "" {
+		return nil
+	}
+	ref, err := rel.findAsset(name + cfg.entrySuffix())
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrNotLogged, err)
+	}
+	data, err := u.http().fetchSmall(ctx, ref.BrowserDownloadURL, maxRekorEntrySize)
+	if err != nil {
+		return err
+	}
+	uuid := strings.TrimSpace(string(data))
+	entry, err := u.http().fetchRekorEntry(ctx, cfg.URL, uuid)
+	if err != nil {
+		return fmt.Errorf("log entry %s: %w", uuid, err)
+	}
+	if err := verifyRekorEntry(entry, uuid, cfg.PublicKey, res.SHA256, res.SHA512); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrNotLogged, name, err)
+	}
+	u.logf("%s is logged at index %d of %s", name, entry.LogIndex, cfg.URL)
+	return nil
+}
+
+// verifyRekorEntry checks that entry logs the given digests, that it is
+// the entry uuid, and that it is included in a tree signed by key.
+func verifyRekorEntry(entry *rekorEntry, uuid string, key crypto.PublicKey, sha256Sum, sha512Sum []byte) error {
+	body, err := base64.StdEncoding.DecodeString(entry.Body)
+	if err != nil {
+		return fmt.Errorf("invalid entry body: %w", err)
+	}
+	var b rekorBody
+	if err := json.Unmarshal(body, &b); err != nil {
+		return fmt.Errorf("invalid entry body: %w", err)
+	}
+	logged, err := hex.DecodeString(b.Spec.Data.Hash.Value)
+	if err != nil || len(logged) == 0 {
+		return fmt.Errorf("%s entry logs no artifact digest", b.Kind)
+	}
+	if err := matchDigest(Digest{Algorithm: b.Spec.Data.Hash.Algorithm, Sum: logged}, sha256Sum, sha512Sum); err != nil {
+		return err
+	}
+
+	leaf := leafHash(body)
+	// A UUID is the leaf hash, optionally prefixed by a 16-digit tree ID.
+	if !strings.HasSuffix(strings.ToLower(uuid), hex.EncodeToString(leaf)) {
+		return fmt.Errorf("entry is not %s", uuid)
+	}
+	proof := entry.Verification.InclusionProof
+	if proof == nil {
+		return errors.New("entry has no inclusion proof")
+	}
+	root, err := hex.DecodeString(proof.RootHash)
+	if err != nil {
+		return fmt.Errorf("invalid root hash: %w", err)
+	}
+	path := make([][]byte, len(proof.Hashes))
+	for i, h := range proof.Hashes {
+		if path[i], err = hex.DecodeString(h); err != nil {
+			return fmt.Errorf("invalid proof hash: %w", err)
+		}
+	}
+	if err := verifyInclusion(leaf, proof.LogIndex, proof.TreeSize, path, root); err != nil {
+		return err
+	}
+	size, cpRoot, err := verifyCheckpoint(proof.Checkpoint, key)
+	if err != nil {
+		return err
+	}
+	if size != proof.TreeSize || !bytes.Equal(cpRoot, root) {
+		return errors.New("checkpoint does not match the inclusion proof")
+	}
+	return nil
+}
+
+// leafHash and nodeHash are the RFC 6962 Merkle tree hashes.
+func leafHash(data []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0})
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func nodeHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{1})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// verifyInclusion checks the inclusion proof of leaf at index in a tree
+// of size entries with the given root (RFC 9162, section 2.1.3.2).
+func verifyInclusion(leaf []byte, index, size int64, path [][]byte, root []byte) error {
+	if index < 0 || index >= size {
+		return fmt.Errorf("index %d outside tree of %d", index, size)
+	}
+	fn, sn, r := index, size-1, leaf
+	for _, p := range path {
+		if sn == 0 {
+			return errors.New("inclusion proof too long")
+		}
+		if fn&1 == 1 || fn == sn {
+			r = nodeHash(p, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = nodeHash(r, p)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	if sn != 0 || !bytes.Equal(r, root) {
+		return errors.New("inclusion proof does not match the root hash")
+	}
+	return nil
+}
+
+// verifyCheckpoint checks the signed note cp, whose text starts with the
+// origin, tree size and base64 root hash lines, against key and returns
+// the size and root.
+func verifyCheckpoint(cp string, key crypto.PublicKey) (size int64, root []byte, err error) {
+	text, sigs, ok := strings.Cut(cp, "\n\n")
+	if !ok {
+		return 0, nil, errors.New("malformed checkpoint")
+	}
+	text += "\n"
+	lines := strings.Split(text, "\n")
+	if len(lines) < 4 {
+		return 0, nil, errors.New("malformed checkpoint")
+	}
+	if size, err = strconv.ParseInt(lines[1], 10, 64); err != nil {
+		return 0, nil, fmt.Errorf("malformed checkpoint size: %w", err)
+	}
+	if root, err = base64.StdEncoding.DecodeString(lines[2]); err != nil {
+		return 0, nil, fmt.Errorf("malformed checkpoint root: %w", err)
+	}
+	for _, line := range strings.Split(sigs, "\n") {
+		fields := strings.Fields(strings.TrimPrefix(line, "— "))
+		if !strings.HasPrefix(line, "— ") || len(fields) != 2 {
+			continue
+		}
+		sig, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil || len(sig) < 5 {
+			continue
+		}
+		// The signature follows a 4-byte key hint.
+		if verifyNote(key, []byte(text), sig[4:]) {
+			return size, root, nil
+		}
+	}
+	return 0, nil, fmt.Errorf("%w: checkpoint not signed by the log key", ErrSignatureInvalid)
+}
+
+func verifyNote(key crypto.PublicKey, text, sig []byte) bool {
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(text)
+		return ecdsa.VerifyASN1(key, digest[:], sig)
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, text, sig)
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package selfupdate_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/msmania/updater/selfupdate"
+	"github.com/msmania/updater/selfupdate/selfupdatetest"
+)
+
+func Test_Updater_transparency(t *testing.T) {
+	skipIfDisabled(t)
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A log holding a single entry: its leaf hash is the tree root and the
+	// entry UUID, and the inclusion proof is empty.
+	logged := sha256.Sum256([]byte("v1.1"))
+	body := fmt.Sprintf(`{"kind":"hashedrekord","spec":{"data":{"hash":{"algorithm":"sha256","value":"%x"}}}}`, logged)
+	root := sha256.Sum256(append([]byte{0}, body...))
+	uuid := fmt.Sprintf("%x", root)
+	note := fmt.Sprintf("rekor.test - 1\n1\n%s\n", base64.StdEncoding.EncodeToString(root[:]))
+	sig := append([]byte{0, 0, 0, 0}, ed25519.Sign(priv, []byte(note))...)
+	rekor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/log/entries/"+uuid {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{uuid: map[string]any{
+			"body":     base64.StdEncoding.EncodeToString([]byte(body)),
+			"logIndex": 0,
+			"verification": map[string]any{"inclusionProof": map[string]any{
+				"checkpoint": note + "\n— rekor.test " + base64.StdEncoding.EncodeToString(sig) + "\n",
+				"hashes":     []string{},
+				"logIndex":   0,
+				"rootHash":   uuid,
+				"treeSize":   1,
+			}},
+		}})
+	}))
+	defer rekor.Close()
+
+	srv := selfupdatetest.NewServer("owner", "app",
+		selfupdatetest.Release{Tag: "v1.1.0", Assets: []selfupdatetest.Asset{
+			{Name: "app-bin", Content: []byte("swapped")},
+			{Name: "app-bin.rekor", Content: []byte(uuid + "\n")},
+		}},
+	)
+	defer srv.Close()
+	u := newUpdater(t, srv, "v1.0.0")
+	u.Transparency = selfupdate.TransparencyConfig{URL: rekor.URL, PublicKey: pub}
+	if _, err := u.Update(context.Background()); !errors.Is(err, selfupdate.ErrNotLogged) {
+		t.Fatal("expected ErrNotLogged for a swapped artifact, got", err)
+	}
+	if b, _ := os.ReadFile(u.Path); string(b) != "old" {
+		t.Errorf("unexpected content %q", b)
+	}
+
+	srv.AddRelease(selfupdatetest.Release{Tag: "v1.2.0", Assets: []selfupdatetest.Asset{{Name: "app-bin", Content: []byte("v1.2")}}})
+	if _, err := u.Update(context.Background()); !errors.Is(err, selfupdate.ErrNotLogged) {
+		t.Fatal("expected ErrNotLogged without an entry reference, got", err)
+	}
+
+	srv.AddRelease(selfupdatetest.Release{Tag: "v1.3.0", Assets: []selfupdatetest.Asset{
+		{Name: "app-bin", Content: []byte("v1.1")},
+		{Name: "app-bin.rekor", Content: []byte(uuid)},
+	}})
+	if _, err := u.Update(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(u.Path); string(b) != "v1.1" {
+		t.Errorf("unexpected content %q", b)
+	}
+}
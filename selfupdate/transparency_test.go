@@ -0,0 +1,149 @@
+package selfupdate
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// testTreeHash and testAuditPath compute the RFC 6962 tree hash and
+// inclusion proof of leaves[m].
+func testTreeHash(leaves [][]byte) []byte {
+	if len(leaves) == 1 {
+		return leafHash(leaves[0])
+	}
+	k := testSplit(len(leaves))
+	return nodeHash(testTreeHash(leaves[:k]), testTreeHash(leaves[k:]))
+}
+
+func testAuditPath(m int, leaves [][]byte) [][]byte {
+	if len(leaves) == 1 {
+		return nil
+	}
+	k := testSplit(len(leaves))
+	if m < k {
+		return append(testAuditPath(m, leaves[:k]), testTreeHash(leaves[k:]))
+	}
+	return append(testAuditPath(m-k, leaves[k:]), testTreeHash(leaves[:k]))
+}
+
+// testSplit returns the largest power of two smaller than n.
+func testSplit(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+func Test_verifyInclusion(t *testing.T) {
+	for size := 1; size <= 9; size++ {
+		leaves := make([][]byte, size)
+		for i := range leaves {
+			leaves[i] = []byte(fmt.Sprint("entry ", i))
+		}
+		root := testTreeHash(leaves)
+		for m := range leaves {
+			path := testAuditPath(m, leaves)
+			if err := verifyInclusion(leafHash(leaves[m]), int64(m), int64(size), path, root); err != nil {
+				t.Errorf("leaf %d of %d: %v", m, size, err)
+			}
+			if err := verifyInclusion(leafHash([]byte("other")), int64(m), int64(size), path, root); err == nil {
+				t.Errorf("leaf %d of %d: expected error for a foreign leaf", m, size)
+			}
+			if size > 1 {
+				if err := verifyInclusion(leafHash(leaves[m]), int64(m), int64(size), path[1:], root); err == nil {
+					t.Errorf("leaf %d of %d: expected error for a short proof", m, size)
+				}
+			}
+		}
+	}
+}
+
+// testRekorEntry returns the UUID and entry logging sum as leaf index of
+// a tree of five entries, with a checkpoint signed by key.
+func testRekorEntry(t *testing.T, key *ecdsa.PrivateKey, sum []byte, index int) (string, *rekorEntry) {
+	body := fmt.Sprintf(`{"apiVersion":"0.0.1","kind":"hashedrekord","spec":{"data":{"hash":{"algorithm":"sha256","value":"%x"}}}}`, sum)
+	leaves := [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d"), []byte("e")}
+	leaves[index] = []byte(body)
+	root := testTreeHash(leaves)
+	text := fmt.Sprintf("rekor.example - 1\n%d\n%s\n", len(leaves), base64.StdEncoding.EncodeToString(root))
+	digest := sha256.Sum256([]byte(text))
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	hint := []byte{1, 2, 3, 4}
+	var e rekorEntry
+	e.Body = base64.StdEncoding.EncodeToString([]byte(body))
+	e.LogIndex = int64(index)
+	raw := fmt.Sprintf(`{"checkpoint": %q, "logIndex": %d, "rootHash": %q, "treeSize": %d, "hashes": []}`,
+		text+"\n— rekor.example "+base64.StdEncoding.EncodeToString(append(hint, sig...))+"\n",
+		index, hex.EncodeToString(root), len(leaves))
+	if err := json.Unmarshal([]byte(raw), &e.Verification.InclusionProof); err != nil {
+		t.Fatal(err)
+	}
+	for _, h := range testAuditPath(index, leaves) {
+		e.Verification.InclusionProof.Hashes = append(e.Verification.InclusionProof.Hashes, hex.EncodeToString(h))
+	}
+	return "0123456789abcdef" + hex.EncodeToString(leafHash([]byte(body))), &e
+}
+
+func Test_verifyRekorEntry(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := ParseRekorPublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	sum := sha256.Sum256([]byte("artifact"))
+	swapped := sha256.Sum256([]byte("swapped"))
+
+	verify := func(name string, uuid string, e *rekorEntry, sum []byte, want error) {
+		t.Helper()
+		err := verifyRekorEntry(e, uuid, pub, sum, nil)
+		switch {
+		case want == errAny && err != nil:
+		case want == nil && err == nil:
+		case want != nil && want != errAny && errors.Is(err, want):
+		default:
+			t.Errorf("%s: expected %v, got %v", name, want, err)
+		}
+	}
+	uuid, e := testRekorEntry(t, key, sum[:], 3)
+	verify("logged", uuid, e, sum[:], nil)
+	verify("swapped artifact", uuid, e, swapped[:], ErrChecksumMismatch)
+
+	otherUUID, _ := testRekorEntry(t, key, swapped[:], 3)
+	verify("other entry", otherUUID, e, sum[:], errAny)
+
+	uuid, e = testRekorEntry(t, other, sum[:], 1)
+	verify("foreign log", uuid, e, sum[:], ErrSignatureInvalid)
+
+	uuid, e = testRekorEntry(t, key, sum[:], 4)
+	e.Verification.InclusionProof.LogIndex = 2
+	verify("wrong index", uuid, e, sum[:], errAny)
+
+	if _, err := ParseRekorPublicKey([]byte("not a key")); err == nil {
+		t.Error("expected error for a malformed key")
+	}
+}
+
+// errAny matches any non-nil error in Test_verifyRekorEntry.
+var errAny = errors.New("any error")
@@ -0,0 +1,1028 @@
+// Package selfupdate replaces the running executable with a newer
+// release published on GitHub.
+package selfupdate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+)
+
+// Updater checks the GitHub releases of Owner/Repo for a version newer
+// than Build.Version and installs it over the running executable.
+type Updater struct {
+	Owner string
+	Repo  string
+	// AssetName is the release asset to install. Defaults to
+	// "<Repo>-<GOOS>-<GOARCH>", matching the CI naming.
+	AssetName string
+	// AssetTemplate, if set, takes precedence over AssetName. It is a
+	// text/template over .Repo, .Tag, .Version (the tag without "v"),
+	// .OS, .Arch and .Ext (".exe" on Windows), e.g.
+	// "{{.Repo}}_{{.Version}}_{{.OS}}_{{.Arch}}.tar.gz".
+	AssetTemplate string
+	// AssetFallbacks are templates like AssetTemplate naming assets to
+	// install, in order, when the release lacks the asset above, e.g.
+	// "{{.Repo}}-{{.OS}}-{{.Arch}}-musl" or a universal archive.
+	AssetFallbacks []string
+	// AssetRegexp, if set, selects the asset instead of the names above:
+	// the one asset whose whole name it matches. No match fails with ErrNoAsset and several
+	// with ErrAmbiguousAsset, both listing the candidates.
+	AssetRegexp *regexp.Regexp
+	// AcceptRelease, if set, is asked about every release that would be
+	// installed and can hold it back with an error, which Update and
+	// Check report as ErrPolicyViolation. For example, to let a release
+	// settle for a day:
+	//
+	//	u.AcceptRelease = func(r *selfupdate.Release) error {
+	//		if age := time.Since(r.PublishedAt); age < 24*time.Hour {
+	//			return fmt.Errorf("published %s ago", age.Round(time.Minute))
+	//		}
+	//		return nil
+	//	}
+	AcceptRelease func(*Release) error
+	// ReleaseRule, if set, is evaluated before AcceptRelease and can hold
+	// back or defer a release; see ReleaseRule.
+	ReleaseRule *ReleaseRule
+	// MinReleaseAge holds back releases published less than that long
+	// ago, letting them soak elsewhere first. Update and Check fail with
+	// a ReleaseAgeError meanwhile, which Status reports as
+	// PendingRelease.
+	MinReleaseAge time.Duration
+	Build         BuildInfo
+	// APIURL overrides DefaultAPIURL, e.g. for GitHub Enterprise or a
+	// selfupdatetest.Server.
+	APIURL string
+	// Path is the file to replace. Defaults to the running executable.
+	Path string
+	// Overlay, if its Dir is set, receives updates instead of a Path on a
+	// read-only file system.
+	Overlay OverlayConfig
+	// WorkDir, if set, holds downloads and staged releases instead of the
+	// directory of Path, so that only the Applier needs to write there;
+	// see CommandApplier. It is created if missing. If it is empty and
+	// the directory of Path is read-only, DefaultCacheDir is used.
+	WorkDir string
+	// ChecksumAsset names a release asset in sha256sum/sha512sum format.
+	// When set, the download is verified against the digest it lists for
+	// the asset and rejected with ErrChecksumMismatch otherwise. Without
+	// it, the digest the release API reports for the asset, if any, is
+	// verified instead.
+	ChecksumAsset string
+	// ManifestSigners, if set, must have signed ChecksumAsset.
+	ManifestSigners ManifestSigners
+	// Connections > 1 downloads the asset over that many parallel ranged
+	// requests of SegmentSize bytes (DefaultSegmentSize if zero), falling
+	// back to a single stream when the server lacks Range support.
+	Connections int
+	SegmentSize int64
+	// ArchiveMember is the file installed from a .zip or (compressed) .tar
+	// asset. Defaults to the base name of Path. Only that member is
+	// extracted, decompressing at most MaxExtractSize bytes
+	// (DefaultMaxExtractSize if zero). The same limit applies to
+	// single-file compressed assets; see RegisterDecompressor.
+	ArchiveMember  string
+	MaxExtractSize int64
+	// MemoryDownloadLimit, if positive, buffers assets of at most that
+	// many bytes in memory and writes them to disk only once their digest
+	// is verified, so a failed or interrupted download of a small
+	// artifact never touches the filesystem. It applies only when the
+	// size is known in advance and the asset is not decompressed while
+	// streaming.
+	MemoryDownloadLimit int64
+	// AuxFiles are installed from the same release together with the
+	// executable. An update installs all of them or none, and with
+	// StateDir, Started and Rollback restore the replaced ones too.
+	AuxFiles []AuxFile
+	// Staged makes Update stop once the release is downloaded and
+	// verified, leaving it staged next to Path. ActivateStaged installs
+	// it, typically at the next start, so only the final rename happens
+	// while the service is down.
+	Staged bool
+	// Policy decides whether automatic updates install a release, and
+	// MaintenanceWindows when PolicyScheduled does; see UpdatePolicy.
+	Policy             UpdatePolicy
+	MaintenanceWindows []MaintenanceWindow
+	// Channel selects eligible releases. The stable channel (the default)
+	// uses GitHub's latest release; other channels list recent releases
+	// and install the highest version they admit.
+	Channel Channel
+	// Constraint limits the versions that may be installed, e.g. to stay
+	// within the current major version. When set, the release list is
+	// searched for the highest version satisfying it.
+	Constraint Constraint
+	// AllowMajorUpgrade permits installing a release whose major version
+	// is higher than the running one. Otherwise such a release is reported
+	// as a *MajorUpgradeError and left for a manual migration.
+	AllowMajorUpgrade bool
+	// AllowPackaged permits replacing an executable installed by a system
+	// package manager; see PackageManager. Otherwise Update leaves it to
+	// the package manager and returns ErrPackageManaged.
+	AllowPackaged bool
+	// UpgradeWithPackageManager makes Update run the upgrade command of
+	// the package manager instead (see Package.UpgradeCommand) where it
+	// knows one, and report DecisionUpgraded if it succeeds. The package
+	// manager installs what it considers the latest version, which may
+	// lag behind the release.
+	UpgradeWithPackageManager bool
+	// Mirrors lists base URLs serving release assets as
+	// "<mirror>/<tag>/<asset>", tried in order before GitHub; include
+	// GitHubMirror to place GitHub elsewhere, and TorrentMirror to try
+	// BitTorrent where it is listed. Failed mirrors are skipped
+	// to the end and, among working ones, the fastest is tried first.
+	// Mirrored bytes are only as trustworthy as the verification, so use
+	// them with ChecksumAsset or a Verifier.
+	Mirrors []string
+	// SBOMAsset, if set, names an SPDX or CycloneDX JSON release asset
+	// that must exist and parse before the release is installed or
+	// rolled out, and must pass SBOMPolicy.
+	SBOMAsset  string
+	SBOMPolicy SBOMPolicy
+	// Transparency requires the installed asset to be recorded in a
+	// transparency log.
+	Transparency TransparencyConfig
+	// Advisories configures an advisory feed that can veto a release.
+	Advisories AdvisoryConfig
+	// Fleet configures check-ins with a fleet server.
+	Fleet FleetConfig
+	// DNS, if its Name is set, discovers releases from DNS TXT records
+	// instead of the GitHub API.
+	DNS DNSSource
+	// Reports configures periodic inventory reports; see RunReports.
+	Reports ReportConfig
+	// TelemetryURL, if set, receives an anonymous TelemetryReport as a
+	// JSON POST after each update that installs, stages or fails. Nothing
+	// is sent otherwise.
+	TelemetryURL string
+	// Transport tunes the HTTP transport shared by all requests.
+	Transport TransportConfig
+	// HTTPClient, if set, is used for all requests instead of a client
+	// over Transport, e.g. to add tracing, a proxy or authentication:
+	// release listing, checksums, signatures, assets, fleet check-ins,
+	// reports, advisories and the transparency log, as well as an
+	// HTTPSemaphore or KubernetesRollout without a Client of its own.
+	HTTPClient *http.Client
+	// Applier installs the verified file over Path. Defaults to
+	// WindowsTwoStep on Windows and AtomicRename elsewhere.
+	Applier Applier
+	// Verifier, if set, must accept the download before it is installed.
+	// See Chain for composing several checks.
+	Verifier Verifier
+	// SignatureSuffix, if set, names a detached signature asset as the
+	// asset name plus this suffix (e.g. ".sig"). Its content is passed to
+	// Verifier as Artifact.Signature; see Ed25519Signature.
+	SignatureSuffix string
+	// Keyring, if set, must accept that signature before Verifier runs.
+	// A key list carried by the release is applied first, so a release
+	// can be signed by a key it introduces.
+	Keyring *Keyring
+	// Logger receives progress messages. Defaults to the standard logger.
+	Logger *log.Logger
+	// Clock drives maintenance windows, crash-loop backoff and the other
+	// schedules; see Clock. Defaults to SystemClock.
+	Clock Clock
+	// StateDir, if set, is where state such as the last Status is kept
+	// across restarts.
+	StateDir string
+	// FS holds the files below StateDir and is what the default Applier
+	// installs into. Defaults to OSFS; with a MemFS, tests of state and
+	// install logic run without real files. Downloads and the kept
+	// previous version still use the OS file system.
+	FS FS
+	// CrashLoop configures crash-loop detection; see Started.
+	CrashLoop CrashLoopConfig
+	// Canary requires a new version to report ready after its first
+	// start; see CanaryConfig.
+	Canary CanaryConfig
+	// Integrity periodically checks that the executable is still the one
+	// installed; see IntegrityConfig.
+	Integrity IntegrityConfig
+	// Services lists other services running the installed executable,
+	// restarted one by one by RestartServices.
+	Services ServiceRestart
+	// Rollout, if set, is asked to deploy a newer release instead of
+	// installing it over Path; see KubernetesRollout.
+	Rollout Rollout
+	// Audit, if set, receives a signed record of every install, rollback
+	// and rollout.
+	Audit *AuditLog
+	// LeaderElection lets only one process update Path: the first to
+	// lock "<Path>.lock" leads until it exits, and Update in any other
+	// process returns ErrNotLeader. Status reports the role and leader.
+	LeaderElection bool
+	// Coordinator, if set, must grant a slot before an update is
+	// installed, limiting how many replicas restart at once. The slot is
+	// held across the restart and released by Started.
+	Coordinator Coordinator
+	// Tracer receives a span per pipeline stage. Nil disables tracing.
+	Tracer Tracer
+	// Messages renders the main log messages, and the notices of the
+	// command; nil uses DefaultMessages.
+	Messages *MessageCatalog
+	// AfterUpgrade is called once an upgrade or rollback requested
+	// through Handler has been installed and answered, typically to exit
+	// for a restart.
+	AfterUpgrade func()
+
+	fetcherOnce sync.Once
+	fetcher     *fetcher
+	mirrors     mirrorSet
+
+	packagedMu   sync.Mutex
+	packagedPath string
+	packagedBy   Package
+
+	busy          atomic.Bool
+	draining      atomic.Bool
+	canaryPending atomic.Bool
+	statusMu      sync.Mutex
+	status        Status
+	rateLimited   time.Time // guarded by statusMu
+	history       []HistoryEntry
+	healthMu      sync.Mutex
+	leader        leadership
+	events        eventBus
+}
+
+// assetName returns the name of the asset to install from release tag.
+func (u *Updater) assetName(tag string) (string, error) {
+	if u.AssetTemplate != "" {
+		return u.expandAssetTemplate(u.AssetTemplate, tag)
+	}
+	if u.AssetName != "" {
+		return u.AssetName, nil
+	}
+	return fmt.Sprintf("%s-%s-%s", u.Repo, runtime.GOOS, runtime.GOARCH), nil
+}
+
+// assetNames returns the names of the assets that may be installed from
+// release tag, in order of preference.
+func (u *Updater) assetNames(tag string) ([]string, error) {
+	name, err := u.assetName(tag)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{name}
+	for _, fallback := range u.AssetFallbacks {
+		name, err := u.expandAssetTemplate(fallback, tag)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (u *Updater) expandAssetTemplate(s, tag string) (string, error) {
+	tmpl, err := parseAssetTemplate(s)
+	if err != nil {
+		return "", err
+	}
+	var ext string
+	if runtime.GOOS == "windows" {
+		ext = ".exe"
+	}
+	var b strings.Builder
+	err = tmpl.Execute(&b, struct{ Repo, Tag, Version, OS, Arch, Ext string }{
+		u.Repo, tag, strings.TrimPrefix(tag, "v"), runtime.GOOS, runtime.GOARCH, ext,
+	})
+	return b.String(), err
+}
+
+func parseAssetTemplate(s string) (*template.Template, error) {
+	tmpl, err := template.New("asset").Option("missingkey=error").Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid asset template: %w", err)
+	}
+	return tmpl, nil
+}
+
+func (u *Updater) logf(format string, args ...any) {
+	if u.Logger != nil {
+		u.Logger.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+func (u *Updater) apiURL() string {
+	if u.APIURL != "" {
+		return u.APIURL
+	}
+	return DefaultAPIURL
+}
+
+func (u *Updater) channel() Channel {
+	if u.Channel != "" {
+		return u.Channel
+	}
+	return ChannelStable
+}
+
+func (u *Updater) path() (string, error) {
+	if u.Path != "" {
+		return u.Path, nil
+	}
+	return os.Executable()
+}
+
+// workPath returns the name of a temporary file for exePath, the base
+// name of exePath plus suffix in WorkDir, or else next to exePath if its
+// directory is writable and in a cache directory (see DefaultCacheDir)
+// if not.
+func (u *Updater) workPath(exePath, suffix string) (string, error) {
+	dir := u.WorkDir
+	if dir == "" {
+		dir = filepath.Dir(exePath)
+		if !dirWritable(dir) {
+			dir = fallbackWorkDir(exePath)
+		}
+	}
+	if dir != filepath.Dir(exePath) {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return "", err
+		}
+	}
+	return filepath.Join(dir, filepath.Base(exePath)+suffix), nil
+}
+
+// dirWritable reports whether a file can be created in dir.
+func dirWritable(dir string) bool {
+	f, err := os.CreateTemp(dir, ".updater-probe-*")
+	if err != nil {
+		return false
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return true
+}
+
+// fallbackWorkDir is the work directory for exePath when its own
+// directory is read-only.
+func fallbackWorkDir(exePath string) string {
+	name := strings.TrimSuffix(filepath.Base(exePath), ".exe")
+	if dir := DefaultCacheDir(name); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.TempDir(), name)
+}
+
+func (u *Updater) userAgent() string {
+	return u.Build.UserAgent(u.Repo)
+}
+
+// http returns the fetcher for u, creating its transport on first use so
+// every request of every check reuses the same connection pool.
+func (u *Updater) http() *fetcher {
+	u.fetcherOnce.Do(func() {
+		client := u.HTTPClient
+		if client == nil {
+			client = &http.Client{Transport: NewTransport(u.Transport)}
+		}
+		u.fetcher = &fetcher{client: client, userAgent: u.userAgent(),
+			clock: func() time.Time { return u.clock().Now() }}
+	})
+	return u.fetcher
+}
+
+// MaybeUpgrade checks for a newer GitHub release, downloads it and replaces
+// the running executable. It reports whether an upgrade was installed, in
+// which case the caller should exit so the supervisor restarts it. When no
+// newer release exists the error wraps ErrAlreadyLatest. Concurrent calls
+// fail with ErrBusy. Update returns the details of the decision.
+func (u *Updater) MaybeUpgrade(ctx context.Context) (bool, error) {
+	info, err := u.Update(ctx)
+	return info.Decision == DecisionUpgraded, err
+}
+
+// Reconfigure applies fn to u, e.g. after a config file changed, unless
+// an update is in progress, in which case it returns ErrBusy. Settings
+// captured on first use, such as Transport and HTTPClient, keep their
+// old values.
+func (u *Updater) Reconfigure(fn func(u *Updater)) error {
+	if !u.busy.CompareAndSwap(false, true) {
+		return ErrBusy
+	}
+	defer u.busy.Store(false)
+	fn(u)
+	return nil
+}
+
+// Check reports whether a newer eligible release exists without
+// downloading it. It fails like MaybeUpgrade, and on success info.Decision
+// is DecisionAvailable. info is never nil.
+func (u *Updater) Check(ctx context.Context) (info *UpdateInfo, err error) {
+	info = u.newUpdateInfo()
+	if !Enabled() {
+		info.Decision = DecisionDisabled
+		return info, ErrDisabled
+	}
+	start := time.Now()
+	defer func() {
+		if err != nil && info.Decision == "" {
+			info.Decision = DecisionFailed
+		}
+		info.Durations.Total = time.Since(start)
+	}()
+	if _, _, err = u.decide(ctx, info); err != nil {
+		return info, err
+	}
+	info.Decision = DecisionAvailable
+	return info, nil
+}
+
+// Resolve looks up the release Update would consider and the asset it
+// would install, without comparing versions or downloading anything, and
+// checks that the other configured assets (checksums, signature, log
+// entry reference, SBOM and auxiliary files) exist in that release. It is meant for validating a
+// configuration; all missing assets are reported in the error.
+func (u *Updater) Resolve(ctx context.Context) (tag string, asset *AssetInfo, err error) {
+	if !Enabled() {
+		return "", nil, ErrDisabled
+	}
+	rel, a, err := u.check(ctx, "")
+	if rel == nil {
+		return "", nil, err
+	}
+	var errs []error
+	if a != nil {
+		asset = &AssetInfo{Name: a.Name, URL: a.BrowserDownloadURL, Size: a.Size}
+		if u.SignatureSuffix != "" {
+			_, serr := rel.findAsset(a.Name + u.SignatureSuffix)
+			errs = append(errs, serr)
+		}
+		if u.Transparency.URL != "" {
+			_, terr := rel.findAsset(a.Name + u.Transparency.entrySuffix())
+			errs = append(errs, terr)
+		}
+	}
+	errs = append(errs, err)
+	for _, name := range []string{u.ChecksumAsset, u.SBOMAsset} {
+		if name != "" {
+			_, ferr := rel.findAsset(name)
+			errs = append(errs, ferr)
+		}
+	}
+	if u.ChecksumAsset != "" && len(u.ManifestSigners.Keys) > 0 {
+		_, merr := rel.findAsset(u.ChecksumAsset + u.ManifestSigners.suffix())
+		errs = append(errs, merr)
+	}
+	for _, f := range u.AuxFiles {
+		if f.Asset == "" || f.Optional {
+			continue
+		}
+		name, err := u.expandAssetTemplate(f.Asset, rel.TagName)
+		if err == nil {
+			_, err = rel.findAsset(name)
+		}
+		errs = append(errs, err)
+	}
+	return rel.TagName, asset, errors.Join(errs...)
+}
+
+// Update installs the newest eligible release if it is newer than the
+// running version. The error is that of MaybeUpgrade; info describes the
+// decision either way and is never nil.
+func (u *Updater) Update(ctx context.Context) (info *UpdateInfo, err error) {
+	info = u.newUpdateInfo()
+	if !Enabled() {
+		info.Decision = DecisionDisabled
+		return info, ErrDisabled
+	}
+	if !u.busy.CompareAndSwap(false, true) {
+		info.Decision = DecisionFailed
+		return info, ErrBusy
+	}
+	defer u.busy.Store(false)
+	ctx = withClient(ctx, u.Client())
+	start := time.Now()
+	defer func() {
+		if err != nil && info.Decision == "" {
+			info.Decision = DecisionFailed
+		}
+		info.Durations.Total = time.Since(start)
+		u.recordStatus(info.Decision, err)
+		switch info.Decision {
+		case DecisionUpgraded, DecisionStaged, DecisionRolloutRequested, DecisionFailed:
+			e := HistoryEntry{From: info.Current, To: info.Remote, Decision: info.Decision,
+				Trigger: auditSource(ctx), Durations: info.Durations}
+			if err != nil {
+				e.Error = err.Error()
+			}
+			u.recordHistory(e)
+			u.sendTelemetry(info, err)
+		}
+	}()
+
+	ctx, span := u.tracer().Start(ctx, SpanUpdate)
+	span.SetAttributes(Attr("updater.version.current", u.Build.Version))
+	defer func() {
+		span.SetAttributes(
+			Attr("updater.upgraded", info.Decision == DecisionUpgraded),
+			Attr("updater.decision", string(info.Decision)),
+		)
+		endSpan(span, err)
+	}()
+
+	if u.LeaderElection {
+		leading, err := u.lead()
+		if err != nil {
+			return info, err
+		}
+		if !leading {
+			info.Decision = DecisionObserving
+			return info, fmt.Errorf("%w: %s leads", ErrNotLeader, u.leaderID())
+		}
+	}
+	if err := u.suspended(); err != nil {
+		info.Decision = DecisionSuspended
+		return info, err
+	}
+	rel, asset, err := u.decide(ctx, info)
+	if err != nil {
+		return info, err
+	}
+	remoteTag := rel.TagName
+	if err := u.deferred(ctx, u.clock().Now()); err != nil {
+		info.Decision = DecisionDeferred
+		u.logf("%s", u.message(MsgDeferred, MessageData{Release: remoteTag, Error: err.Error()}))
+		return info, err
+	}
+	if err := u.checkSBOM(ctx, rel); err != nil {
+		if errors.Is(err, ErrPolicyViolation) {
+			info.Decision = DecisionPolicyBlocked
+		}
+		return info, fmt.Errorf("SBOM check failed: %w", err)
+	}
+	if err := u.checkAdvisories(ctx, remoteTag); err != nil {
+		if errors.Is(err, ErrPolicyViolation) {
+			info.Decision = DecisionPolicyBlocked
+		}
+		return info, fmt.Errorf("advisory check failed: %w", err)
+	}
+	if u.Rollout != nil {
+		u.logf("%s", u.message(MsgRollout, MessageData{Release: remoteTag}))
+		if err := u.Rollout.Rollout(ctx, info); err != nil {
+			return info, fmt.Errorf("rollout failed: %w", err)
+		}
+		info.Decision = DecisionRolloutRequested
+		u.audit(ctx, AuditRollout, remoteTag, "")
+		return info, nil
+	}
+
+	exePath, err := u.path()
+	if err != nil {
+		return info, err
+	}
+	if err := u.checkPackaged(exePath); err != nil {
+		var managed *PackageManagedError
+		if u.UpgradeWithPackageManager && errors.As(err, &managed) && managed.Package.UpgradeCommand() != nil {
+			return info, u.upgradePackage(ctx, managed.Package, info)
+		}
+		info.Decision = DecisionPackageManaged
+		return info, err
+	}
+	runningPath := exePath
+	if exePath, err = u.overlayPath(exePath); err != nil {
+		return info, err
+	}
+	u.logf("%s", u.message(MsgDownloading, MessageData{Release: remoteTag}))
+	if u.Staged && u.stagedTag(exePath) == remoteTag {
+		info.Decision = DecisionStaged
+		u.logf("%s is already staged", remoteTag)
+		return info, nil
+	}
+	tmpPath, err := u.workPath(exePath, ".new")
+	if err != nil {
+		return info, err
+	}
+	want, err := u.expectedDigest(ctx, rel, asset)
+	if err != nil {
+		return info, fmt.Errorf("cannot fetch checksums: %w", err)
+	}
+	if want.Algorithm != "" {
+		info.Asset.Digest = want.String()
+	}
+	base, dec := compressionByName(asset.Name)
+	format := archiveFormat(base)
+	downloadPath := tmpPath
+	if format != "" {
+		if downloadPath, err = u.workPath(exePath, ".download"); err != nil {
+			return info, err
+		}
+		defer os.Remove(downloadPath)
+	}
+	var streamDec Decompressor
+	if format == "" {
+		streamDec = dec
+	}
+	res, err := u.fetchAsset(ctx, remoteTag, asset, downloadPath, want, streamDec, info)
+	if err != nil {
+		return info, err
+	}
+	if err := u.checkTransparency(ctx, rel, asset.Name, res); err != nil {
+		os.Remove(downloadPath)
+		return info, fmt.Errorf("transparency log check failed: %w", err)
+	}
+	if format != "" {
+		member := u.ArchiveMember
+		if member == "" {
+			member = filepath.Base(exePath)
+		}
+		if err := extractMember(downloadPath, format, dec, member, tmpPath, u.MaxExtractSize); err != nil {
+			return info, fmt.Errorf("extract failed: %w", err)
+		}
+	}
+	sig, err := u.signature(ctx, rel, asset.Name)
+	if err != nil {
+		os.Remove(tmpPath)
+		return info, fmt.Errorf("cannot fetch signature: %w", err)
+	}
+	artifact := Artifact{
+		Path:      tmpPath,
+		Name:      asset.Name,
+		Tag:       remoteTag,
+		Size:      res.Size,
+		SHA256:    res.SHA256,
+		SHA512:    res.SHA512,
+		Signature: sig,
+	}
+	if err := u.rotateKeyring(ctx, rel); err != nil {
+		os.Remove(tmpPath)
+		return info, fmt.Errorf("keyring rotation failed: %w", err)
+	}
+	stage := time.Now()
+	err = u.verifyArtifact(ctx, artifact)
+	info.Durations.Verify += time.Since(stage)
+	if err != nil {
+		os.Remove(tmpPath)
+		return info, fmt.Errorf("verification failed: %w", err)
+	}
+	aux, err := u.stageAuxFiles(ctx, rel, downloadPath, format, dec, info)
+	if err != nil {
+		os.Remove(tmpPath)
+		return info, fmt.Errorf("auxiliary file: %w", err)
+	}
+	if u.Staged {
+		if err := u.stage(artifact, aux, exePath); err != nil {
+			os.Remove(tmpPath)
+			discardAux(aux)
+			return info, fmt.Errorf("staging failed: %w", err)
+		}
+		info.Decision = DecisionStaged
+		u.logf("Staged %s; it is installed on the next restart.", remoteTag)
+		return info, nil
+	}
+	if err := u.apply(ctx, artifact, aux, exePath, info); err != nil {
+		return info, err
+	}
+	if exePath != runningPath {
+		u.pointToOverlay(exePath)
+	}
+	info.Decision = DecisionUpgraded
+	u.logf("%s", u.message(MsgUpgraded, MessageData{Release: remoteTag, Decision: info.Decision, Duration: time.Since(start)}))
+	// The restart itself is performed by the caller exiting; this span
+	// marks the hand-off so traces show where the old process stopped.
+	_, restart := u.tracer().Start(ctx, SpanRestart)
+	restart.SetAttributes(Attr("updater.version.new", remoteTag))
+	restart.End()
+	return info, nil
+}
+
+// apply installs the verified artifact a and the staged auxiliary files
+// over exePath, taking ownership of both.
+func (u *Updater) apply(ctx context.Context, a Artifact, aux []stagedAux, exePath string, info *UpdateInfo) error {
+	if u.Coordinator != nil {
+		u.logf("Waiting for a rollout slot…")
+		if err := u.Coordinator.Acquire(ctx); err != nil {
+			os.Remove(a.Path)
+			discardAux(aux)
+			return fmt.Errorf("cannot acquire rollout slot: %w", err)
+		}
+	}
+	if err := u.keepPrevious(exePath); err != nil {
+		u.logf("WARNING: cannot keep %s for rollback: %v", exePath, err)
+	}
+	stage := time.Now()
+	undoAux, commitAux, err := u.installAux(aux)
+	if err == nil {
+		if err = u.install(ctx, a, exePath); err != nil {
+			undoAux()
+		}
+	}
+	info.Durations.Install = time.Since(stage)
+	if err != nil {
+		os.Remove(a.Path)
+		if u.Coordinator != nil {
+			if rerr := u.Coordinator.Release(context.WithoutCancel(ctx)); rerr != nil {
+				u.logf("cannot release rollout slot: %v", rerr)
+			}
+		}
+		return fmt.Errorf("replace failed: %w", err)
+	}
+	commitAux()
+	u.recordInstalled(a.Tag, exePath)
+	u.audit(ctx, AuditInstall, a.Tag, Digest{"sha256", a.SHA256}.String())
+	u.armCanary(a.Tag)
+	return nil
+}
+
+func (u *Updater) newUpdateInfo() *UpdateInfo {
+	return &UpdateInfo{Current: u.Build.Version, Channel: u.channel()}
+}
+
+// decide selects the candidate release and applies the version gates,
+// recording the outcome in info. It returns a nil error only if rel is to
+// be installed.
+func (u *Updater) decide(ctx context.Context, info *UpdateInfo) (*ghRelease, *ghAsset, error) {
+	stage := time.Now()
+	manifest, err := u.fleetCheckin(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("fleet check-in failed: %w", err)
+	}
+	var pin string
+	paused := manifest != nil && manifest.Paused
+	if manifest != nil {
+		if !paused && manifest.Target != "" && manifest.Target != info.Current && !manifest.Eligible {
+			info.Remote, info.Decision = manifest.Target, DecisionExcluded
+			return nil, nil, fmt.Errorf("%w (current=%s remote=%s outside the %d%% rollout)",
+				ErrAlreadyLatest, info.Current, manifest.Target, manifest.RolloutPercent)
+		}
+		pin = manifest.Target
+	}
+	rel, asset, err := u.check(ctx, pin)
+	info.Durations.Check = time.Since(stage)
+	if rel != nil {
+		info.Remote = rel.TagName
+	}
+	if asset != nil {
+		info.Asset = &AssetInfo{Name: asset.Name, URL: asset.BrowserDownloadURL, Size: asset.Size}
+	}
+	if err != nil {
+		if errors.Is(err, ErrNoRelease) {
+			info.Decision = DecisionNoRelease
+		}
+		return nil, nil, fmt.Errorf("cannot query latest release: %w", err)
+	}
+
+	current, remoteTag := info.Current, info.Remote
+	remoteVersion := ParseVersion(remoteTag)
+	localVersion := ParseVersion(current)
+	cmp, err := remoteVersion.Compare(localVersion)
+	switch {
+	case err != nil:
+		info.Decision = DecisionUnparsable
+	case cmp <= 0:
+		info.Decision = DecisionUpToDate
+	case !u.channel().allows(remoteVersion) || !u.Constraint.Check(remoteVersion):
+		info.Decision = DecisionExcluded
+	case u.badVersion(remoteTag):
+		u.logf("Skipping %s, which was rolled back", remoteTag)
+		info.Decision = DecisionExcluded
+	}
+	if info.Decision != "" {
+		return nil, nil, fmt.Errorf("%w (current=%s remote=%s)", ErrAlreadyLatest, current, remoteTag)
+	}
+
+	if remoteVersion.Numbers[0] > localVersion.Numbers[0] && !u.AllowMajorUpgrade {
+		u.logf("WARNING: major upgrade %s -> %s is available but not allowed; "+
+			"install it manually or enable major upgrades", current, remoteTag)
+		info.Decision = DecisionMajorBlocked
+		return nil, nil, &MajorUpgradeError{Current: current, Candidate: remoteTag}
+	}
+	if err := u.checkReleaseRule(rel); err != nil {
+		info.Decision = DecisionPolicyBlocked
+		if errors.Is(err, ErrDeferred) {
+			info.Decision = DecisionDeferred
+		}
+		return nil, nil, err
+	}
+	if err := u.acceptRelease(rel); err != nil {
+		info.Decision = DecisionPolicyBlocked
+		return nil, nil, err
+	}
+	if err := u.checkReleaseAge(rel); err != nil {
+		info.Decision = DecisionDeferred
+		return nil, nil, err
+	}
+	// While paused, checks still run so Status shows what is held back.
+	if paused {
+		info.Decision = DecisionSuspended
+		return nil, nil, fmt.Errorf("%w (current=%s remote=%s)", ErrRolloutPaused, current, remoteTag)
+	}
+	return rel, asset, nil
+}
+
+// check selects the newest release on u's channel satisfying u.Constraint,
+// or the release tagged pin if set, and locates the asset to install.
+func (u *Updater) check(ctx context.Context, pin string) (rel *ghRelease, asset *ghAsset, err error) {
+	ctx, span := u.tracer().Start(ctx, SpanCheck)
+	defer func() {
+		if rel != nil {
+			span.SetAttributes(Attr("updater.version.remote", rel.TagName))
+		}
+		endSpan(span, err)
+	}()
+	span.SetAttributes(Attr("updater.channel", string(u.channel())))
+	if until := u.rateLimitedUntil(); !until.IsZero() {
+		return nil, nil, &RateLimitError{Reset: until}
+	}
+	defer func() { u.noteRateLimit(err) }()
+	switch {
+	case u.DNS.Name != "":
+		rel, asset, err = u.dnsRelease(ctx, pin)
+		return rel, asset, err
+	case pin != "":
+		rel, err = u.http().releaseByTag(ctx, u.apiURL(), u.Owner, u.Repo, pin)
+	default:
+		rel, err = u.newestRelease(ctx)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if u.AssetRegexp != nil {
+		if asset, err = rel.matchAsset(u.AssetRegexp); err == nil {
+			span.SetAttributes(Attr("updater.asset", asset.Name))
+		}
+		return rel, asset, err
+	}
+	names, err := u.assetNames(rel.TagName)
+	if err != nil {
+		return rel, nil, err
+	}
+	for i, name := range names {
+		if asset, err = rel.findAsset(name); err == nil {
+			if i > 0 {
+				u.logf("Release %s lacks %s; falling back to %s", rel.TagName, names[0], name)
+			}
+			span.SetAttributes(Attr("updater.asset", name))
+			return rel, asset, nil
+		}
+	}
+	if len(names) > 1 {
+		err = fmt.Errorf("%w: none of %s in release %s", ErrNoAsset, strings.Join(names, ", "), rel.TagName)
+	}
+	return rel, nil, err
+}
+
+// newestRelease returns the newest GitHub release of u's channel within
+// u.Constraint.
+func (u *Updater) newestRelease(ctx context.Context) (*ghRelease, error) {
+	if u.channel() == ChannelStable && u.Constraint.IsZero() {
+		return u.http().latestRelease(ctx, u.apiURL(), u.Owner, u.Repo)
+	}
+	rels, err := u.http().listReleases(ctx, u.apiURL(), u.Owner, u.Repo)
+	if err != nil {
+		return nil, err
+	}
+	return selectRelease(rels, u.channel(), u.Constraint)
+}
+
+// expectedDigest returns the digest asset must have: the one in the
+// release's checksum asset, if configured, or else the digest GitHub
+// reports for the asset. When both exist they must agree. The zero Digest
+// is returned when neither is available.
+func (u *Updater) expectedDigest(ctx context.Context, rel *ghRelease, asset *ghAsset) (Digest, error) {
+	var api Digest
+	if asset.Digest != "" {
+		d, err := parseDigest(asset.Digest)
+		if err != nil {
+			u.logf("Ignoring the digest of %s: %v", asset.Name, err)
+		}
+		api = d
+	}
+	if u.ChecksumAsset == "" {
+		if len(u.ManifestSigners.Keys) > 0 {
+			return Digest{}, errors.New("manifest signers require ChecksumAsset")
+		}
+		return api, nil
+	}
+	sums, err := u.fetchManifest(ctx, rel)
+	if err != nil {
+		return Digest{}, err
+	}
+	want, ok := sums[asset.Name]
+	if !ok {
+		return Digest{}, fmt.Errorf("%w: no checksum for %s in %s", ErrChecksumMismatch, asset.Name, u.ChecksumAsset)
+	}
+	if api.Algorithm == want.Algorithm && !bytes.Equal(api.Sum, want.Sum) {
+		return Digest{}, fmt.Errorf("%w: %s lists %s for %s, the release API %s",
+			ErrChecksumMismatch, u.ChecksumAsset, want, asset.Name, api)
+	}
+	return want, nil
+}
+
+func (u *Updater) download(ctx context.Context, url, dst string, size int64, want Digest, dec Decompressor) (res downloadResult, err error) {
+	ctx, span := u.tracer().Start(ctx, SpanDownload)
+	span.SetAttributes(Attr("updater.asset.url", url))
+	if size <= 0 {
+		size = u.preflightSize(ctx, url)
+	}
+	opts := downloadOptions{
+		withSHA512:   want.Algorithm == "sha512",
+		connections:  u.Connections,
+		segmentSize:  u.SegmentSize,
+		decompress:   dec,
+		maxSize:      u.MaxExtractSize,
+		expectedSize: size,
+		progress:     u.downloadProgress(size),
+	}
+	if dec == nil && size > 0 && size <= u.MemoryDownloadLimit {
+		buf := bytes.NewBuffer(make([]byte, 0, size))
+		res, err = u.http().downloadTo(ctx, url, buf, opts)
+		res.data = buf.Bytes()
+	} else {
+		res, err = u.http().downloadFile(ctx, url, dst, opts)
+	}
+	span.SetAttributes(Attr("updater.bytes", res.Size), Attr("updater.retries", res.Retries))
+	endSpan(span, err)
+	return res, err
+}
+
+// preflightSize learns the size of the asset at url with a HEAD request,
+// for releases whose API entry does not state it. It returns 0 if the
+// size stays unknown, in which case only Content-Length is enforced.
+func (u *Updater) preflightSize(ctx context.Context, url string) int64 {
+	size, err := u.http().headSize(ctx, url)
+	if err != nil {
+		u.logf("HEAD %s failed: %v", url, err)
+		return 0
+	}
+	return max(size, 0)
+}
+
+// maxSignatureSize bounds how much of a signature asset is read.
+const maxSignatureSize = 64 << 10
+
+// signature fetches the detached signature of the asset called name, or
+// returns nil if SignatureSuffix is not set.
+func (u *Updater) signature(ctx context.Context, rel *ghRelease, name string) ([]byte, error) {
+	if u.SignatureSuffix == "" {
+		return nil, nil
+	}
+	sigAsset, err := rel.findAsset(name + u.SignatureSuffix)
+	if err != nil {
+		return nil, err
+	}
+	return u.http().fetchSmall(ctx, sigAsset.BrowserDownloadURL, maxSignatureSize)
+}
+
+// verify checks the streamed digest against want, if one is known.
+func (u *Updater) verify(ctx context.Context, res downloadResult, want Digest) error {
+	ctx, span := u.tracer().Start(ctx, SpanVerify)
+	var err error
+	if want.Algorithm != "" {
+		span.SetAttributes(Attr("updater.digest", want.String()))
+		err = Checksum(want).Verify(ctx, Artifact{Size: res.Size, SHA256: res.SHA256, SHA512: res.SHA512})
+	}
+	endSpan(span, err)
+	return err
+}
+
+// verifyArtifact runs u.Keyring and u.Verifier, if any, on the file
+// about to be installed.
+func (u *Updater) verifyArtifact(ctx context.Context, a Artifact) error {
+	var v Verifier
+	switch {
+	case u.Keyring != nil && u.Verifier != nil:
+		v = Chain(u.Keyring.Verifier(), u.Verifier)
+	case u.Keyring != nil:
+		v = u.Keyring.Verifier()
+	case u.Verifier != nil:
+		v = u.Verifier
+	default:
+		return nil
+	}
+	ctx, span := u.tracer().Start(ctx, SpanVerify)
+	span.SetAttributes(Attr("updater.verifier", fmt.Sprintf("%T", v)))
+	err := v.Verify(ctx, a)
+	endSpan(span, err)
+	return err
+}
+
+// install replaces exePath. From here on Middleware drains traffic; it
+// keeps doing so after a successful install since a restart follows.
+func (u *Updater) install(ctx context.Context, a Artifact, exePath string) error {
+	ctx, span := u.tracer().Start(ctx, SpanInstall)
+	applier := u.applier()
+	span.SetAttributes(Attr("updater.path", exePath), Attr("updater.applier", fmt.Sprintf("%T", applier)))
+	u.draining.Store(true)
+	err := applier.Apply(ctx, a, exePath)
+	if err != nil {
+		u.draining.Store(false)
+	}
+	endSpan(span, err)
+	return err
+}
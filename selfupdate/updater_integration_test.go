@@ -0,0 +1,51 @@
+package selfupdate_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/msmania/updater/selfupdate"
+	"github.com/msmania/updater/selfupdate/selfupdatetest"
+)
+
+func Test_Updater_assetFallbacks(t *testing.T) {
+	skipIfDisabled(t)
+	srv := selfupdatetest.NewServer("owner", "app",
+		selfupdatetest.Release{Tag: "v1.1.0", Assets: []selfupdatetest.Asset{
+			{Name: "app-universal", Content: []byte("universal")},
+			{Name: "app-bin-musl", Content: []byte("v1.1 musl")},
+		}},
+	)
+	defer srv.Close()
+	u := newUpdater(t, srv, "v1.0.0")
+	if _, err := u.Update(context.Background()); !errors.Is(err, selfupdate.ErrNoAsset) {
+		t.Fatal("expected ErrNoAsset without fallbacks, got", err)
+	}
+	u.AssetFallbacks = []string{"app-bin-static", "app-bin-musl", "app-universal"}
+	info, err := u.Update(context.Background())
+	if err != nil || info.Asset.Name != "app-bin-musl" {
+		t.Fatalf("fallback not installed %+v: %v", info.Asset, err)
+	}
+	if b, _ := os.ReadFile(u.Path); string(b) != "v1.1 musl" {
+		t.Error("unexpected content " + string(b))
+	}
+
+	u.AssetFallbacks = nil
+	u.AssetRegexp = regexp.MustCompile(`app-univ.*`)
+	u.Build.Version = "v1.0.0"
+	if info, err := u.Update(context.Background()); err != nil || info.Asset.Name != "app-universal" {
+		t.Errorf("regexp-selected asset not installed %+v: %v", info.Asset, err)
+	}
+	u.AssetRegexp = nil
+
+	u.AssetFallbacks = []string{"app-bin-static"}
+	u.Build.Version = "v1.0.0"
+	if _, err := u.Update(context.Background()); !errors.Is(err, selfupdate.ErrNoAsset) ||
+		!strings.Contains(err.Error(), "none of app-bin, app-bin-static") {
+		t.Error("expected ErrNoAsset naming the candidates, got", err)
+	}
+}
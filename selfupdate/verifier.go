@@ -0,0 +1,246 @@
+package selfupdate
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// Artifact is a downloaded release asset awaiting installation.
+type Artifact struct {
+	// Path is the local file that will be installed.
+	Path string
+	// Name and Tag identify the asset and its release.
+	Name string
+	Tag  string
+	// Size, SHA256 and SHA512 describe the bytes as published, before any
+	// decompression or extraction. SHA512 is nil unless a sha512 checksum
+	// was requested.
+	Size   int64
+	SHA256 []byte
+	SHA512 []byte
+	// Signature is the content of the detached signature asset, if
+	// Updater.SignatureSuffix is set.
+	Signature []byte
+}
+
+// Verifier validates an Artifact before it is installed. A non-nil error
+// rejects the update.
+type Verifier interface {
+	Verify(ctx context.Context, a Artifact) error
+}
+
+// VerifierFunc adapts a function to the Verifier interface.
+type VerifierFunc func(ctx context.Context, a Artifact) error
+
+func (f VerifierFunc) Verify(ctx context.Context, a Artifact) error {
+	return f(ctx, a)
+}
+
+// Chain returns a Verifier running vs in order and failing on the first
+// error.
+func Chain(vs ...Verifier) Verifier {
+	return chain(vs)
+}
+
+type chain []Verifier
+
+func (c chain) Verify(ctx context.Context, a Artifact) error {
+	for _, v := range c {
+		if err := v.Verify(ctx, a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Checksum accepts artifacts whose published bytes hash to want. A sha512
+// Digest only matches if the download computed SHA512.
+func Checksum(want Digest) Verifier {
+	return VerifierFunc(func(_ context.Context, a Artifact) error {
+		return matchDigest(want, a.SHA256, a.SHA512)
+	})
+}
+
+// matchDigest compares want with the digest of the same algorithm.
+func matchDigest(want Digest, sha256, sha512 []byte) error {
+	var got []byte
+	switch want.Algorithm {
+	case "sha256":
+		got = sha256
+	case "sha512":
+		got = sha512
+	default:
+		return fmt.Errorf("unsupported digest algorithm %q", want.Algorithm)
+	}
+	if !bytes.Equal(got, want.Sum) {
+		return fmt.Errorf("%w: want %s, got %s:%x", ErrChecksumMismatch, want, want.Algorithm, got)
+	}
+	return nil
+}
+
+// SizeRange accepts files to install of min to max bytes inclusive. A
+// max of zero means no upper bound. It catches truncated or placeholder
+// binaries that still carry a valid checksum.
+func SizeRange(min, max int64) Verifier {
+	return VerifierFunc(func(_ context.Context, a Artifact) error {
+		fi, err := os.Stat(a.Path)
+		if err != nil {
+			return err
+		}
+		if fi.Size() < min || (max > 0 && fi.Size() > max) {
+			return fmt.Errorf("%s is %d bytes, want %d..%d", a.Name, fi.Size(), min, max)
+		}
+		return nil
+	})
+}
+
+// ErrNotExecutable is returned by the Executable verifier.
+var ErrNotExecutable = errors.New("not an executable for this platform")
+
+// Executable accepts files that are native executables for the running
+// platform: ELF, Mach-O or PE by GOOS, for GOARCH where the architecture
+// is known. It rejects, for example, an HTML error page or a binary built
+// for another architecture.
+func Executable() Verifier {
+	return VerifierFunc(func(_ context.Context, a Artifact) error {
+		if err := sniffExecutable(a.Path, runtime.GOOS, runtime.GOARCH); err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrNotExecutable, a.Name, err)
+		}
+		return nil
+	})
+}
+
+func sniffExecutable(path, goos, goarch string) error {
+	switch goos {
+	case "windows":
+		f, err := pe.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		want, known := map[string]uint16{
+			"386":   pe.IMAGE_FILE_MACHINE_I386,
+			"amd64": pe.IMAGE_FILE_MACHINE_AMD64,
+			"arm64": pe.IMAGE_FILE_MACHINE_ARM64,
+		}[goarch]
+		if known && f.Machine != want {
+			return fmt.Errorf("machine %#x, want %#x", f.Machine, want)
+		}
+	case "darwin", "ios":
+		want, known := map[string]macho.Cpu{
+			"amd64": macho.CpuAmd64,
+			"arm64": macho.CpuArm64,
+		}[goarch]
+		if fat, err := macho.OpenFat(path); err == nil {
+			defer fat.Close()
+			for _, arch := range fat.Arches {
+				if !known || arch.Cpu == want {
+					return nil
+				}
+			}
+			return fmt.Errorf("universal binary lacks %s", goarch)
+		}
+		f, err := macho.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if known && f.Cpu != want {
+			return fmt.Errorf("cpu %v, want %v", f.Cpu, want)
+		}
+	default:
+		f, err := elf.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		want, known := map[string]elf.Machine{
+			"386":     elf.EM_386,
+			"amd64":   elf.EM_X86_64,
+			"arm":     elf.EM_ARM,
+			"arm64":   elf.EM_AARCH64,
+			"riscv64": elf.EM_RISCV,
+			"ppc64le": elf.EM_PPC64,
+			"s390x":   elf.EM_S390,
+		}[goarch]
+		if known && f.Machine != want {
+			return fmt.Errorf("machine %v, want %v", f.Machine, want)
+		}
+	}
+	return nil
+}
+
+// Ed25519Signature accepts artifacts whose Signature is a valid Ed25519
+// signature, by any of keys, of the raw SHA-256 digest of the published
+// bytes. The signature may be raw (64 bytes), hex or base64.
+func Ed25519Signature(keys ...ed25519.PublicKey) Verifier {
+	return VerifierFunc(func(_ context.Context, a Artifact) error {
+		if len(a.Signature) == 0 {
+			return fmt.Errorf("%w: no signature for %s", ErrSignatureInvalid, a.Name)
+		}
+		sig, err := decodeSignature(a.Signature)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrSignatureInvalid, err)
+		}
+		for _, key := range keys {
+			if ed25519.Verify(key, a.SHA256, sig) {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %s", ErrSignatureInvalid, a.Name)
+	})
+}
+
+func decodeSignature(b []byte) ([]byte, error) {
+	sig, ok := decodeFixed(b, ed25519.SignatureSize)
+	if !ok {
+		return nil, errors.New("malformed signature")
+	}
+	return sig, nil
+}
+
+// decodeFixed decodes size bytes given raw, hex or base64.
+func decodeFixed(b []byte, size int) ([]byte, bool) {
+	if len(b) == size {
+		return b, true
+	}
+	text := string(bytes.TrimSpace(b))
+	if v, err := hex.DecodeString(text); err == nil && len(v) == size {
+		return v, true
+	}
+	if v, err := base64.StdEncoding.DecodeString(text); err == nil && len(v) == size {
+		return v, true
+	}
+	return nil, false
+}
+
+// ParseEd25519PublicKey decodes a raw, hex or base64 Ed25519 public key.
+func ParseEd25519PublicKey(b []byte) (ed25519.PublicKey, error) {
+	key, ok := decodeFixed(b, ed25519.PublicKeySize)
+	if !ok {
+		return nil, errors.New("malformed Ed25519 public key")
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// ParseEd25519PrivateKey decodes a raw, hex or base64 Ed25519 private
+// key, given either as the 32-byte seed or the 64-byte key.
+func ParseEd25519PrivateKey(b []byte) (ed25519.PrivateKey, error) {
+	if seed, ok := decodeFixed(b, ed25519.SeedSize); ok {
+		return ed25519.NewKeyFromSeed(seed), nil
+	}
+	if key, ok := decodeFixed(b, ed25519.PrivateKeySize); ok {
+		return ed25519.PrivateKey(key), nil
+	}
+	return nil, errors.New("malformed Ed25519 private key")
+}
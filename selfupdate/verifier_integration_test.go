@@ -0,0 +1,69 @@
+package selfupdate_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/msmania/updater/selfupdate"
+	"github.com/msmania/updater/selfupdate/selfupdatetest"
+)
+
+type rejectVerifier struct{ seen selfupdate.Artifact }
+
+func (v *rejectVerifier) Verify(ctx context.Context, a selfupdate.Artifact) error {
+	v.seen = a
+	return errors.New("rejected")
+}
+
+func Test_Updater_verifier(t *testing.T) {
+	skipIfDisabled(t)
+	srv := selfupdatetest.NewServer("owner", "app",
+		selfupdatetest.Release{Tag: "v1.1.0", Assets: []selfupdatetest.Asset{{Name: "app_1.1.0", Content: []byte("v1.1")}}},
+	)
+	defer srv.Close()
+	v := &rejectVerifier{}
+	u, err := selfupdate.New("owner", "app",
+		selfupdate.WithBuild(selfupdate.BuildInfo{Version: "v1.0.0"}),
+		selfupdate.WithAssetTemplate("{{.Repo}}_{{.Version}}"),
+		selfupdate.WithVerifier(v),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.APIURL = srv.URL
+	u.Path = newUpdater(t, srv, "v1.0.0").Path
+	if _, err := u.MaybeUpgrade(context.Background()); err == nil || !strings.Contains(err.Error(), "rejected") {
+		t.Error("expected verifier rejection, got", err)
+	}
+	if v.seen.Name != "app_1.1.0" || v.seen.Tag != "v1.1.0" || v.seen.Size != 4 {
+		t.Errorf("unexpected artifact %+v", v.seen)
+	}
+	if b, _ := os.ReadFile(u.Path); string(b) != "old" {
+		t.Error("rejected artifact installed")
+	}
+}
+
+func Test_Updater_signature(t *testing.T) {
+	skipIfDisabled(t)
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	bin := []byte("v1.1")
+	sum := sha256.Sum256(bin)
+	srv := selfupdatetest.NewServer("owner", "app",
+		selfupdatetest.Release{Tag: "v1.1.0", Assets: []selfupdatetest.Asset{
+			{Name: "app-bin", Content: bin},
+			{Name: "app-bin.sig", Content: ed25519.Sign(priv, sum[:])},
+		}},
+	)
+	defer srv.Close()
+	u := newUpdater(t, srv, "v1.0.0")
+	u.SignatureSuffix = ".sig"
+	u.Verifier = selfupdate.Chain(selfupdate.SizeRange(1, 0), selfupdate.Ed25519Signature(pub))
+	if upgraded, err := u.MaybeUpgrade(context.Background()); err != nil || !upgraded {
+		t.Fatalf("signed upgrade failed: %v", err)
+	}
+}
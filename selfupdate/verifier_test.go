@@ -0,0 +1,108 @@
+package selfupdate
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func Test_Chain(t *testing.T) {
+	var calls []string
+	record := func(name string, err error) Verifier {
+		return VerifierFunc(func(context.Context, Artifact) error {
+			calls = append(calls, name)
+			return err
+		})
+	}
+	boom := errors.New("boom")
+	err := Chain(record("a", nil), record("b", boom), record("c", nil)).Verify(context.Background(), Artifact{})
+	if err != boom || len(calls) != 2 {
+		t.Error("chain must stop at the first error", calls, err)
+	}
+}
+
+func Test_Checksum(t *testing.T) {
+	sum := sha256.Sum256([]byte("payload"))
+	a := Artifact{SHA256: sum[:]}
+	ctx := context.Background()
+	if err := Checksum(Digest{"sha256", sum[:]}).Verify(ctx, a); err != nil {
+		t.Error(err)
+	}
+	other := sha256.Sum256([]byte("other"))
+	if err := Checksum(Digest{"sha256", other[:]}).Verify(ctx, a); !errors.Is(err, ErrChecksumMismatch) {
+		t.Error("expected ErrChecksumMismatch, got", err)
+	}
+	if err := Checksum(Digest{"sha512", make([]byte, 64)}).Verify(ctx, a); !errors.Is(err, ErrChecksumMismatch) {
+		t.Error("sha512 must not match without SHA512, got", err)
+	}
+}
+
+func Test_SizeRange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bin")
+	os.WriteFile(path, make([]byte, 100), 0o755)
+	a := Artifact{Path: path, Name: "bin"}
+	ctx := context.Background()
+	if err := SizeRange(10, 0).Verify(ctx, a); err != nil {
+		t.Error(err)
+	}
+	if err := SizeRange(101, 0).Verify(ctx, a); err == nil {
+		t.Error("too small file accepted")
+	}
+	if err := SizeRange(0, 99).Verify(ctx, a); err == nil {
+		t.Error("too large file accepted")
+	}
+}
+
+func Test_Executable(t *testing.T) {
+	ctx := context.Background()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Skip(err)
+	}
+	if err := Executable().Verify(ctx, Artifact{Path: exe, Name: "test"}); err != nil {
+		t.Error("test binary rejected:", err)
+	}
+
+	page := filepath.Join(t.TempDir(), "page")
+	os.WriteFile(page, []byte("<html>Not Found</html>"), 0o644)
+	if err := Executable().Verify(ctx, Artifact{Path: page, Name: "page"}); !errors.Is(err, ErrNotExecutable) {
+		t.Error("expected ErrNotExecutable, got", err)
+	}
+	if runtime.GOOS == "linux" && runtime.GOARCH == "amd64" {
+		if err := sniffExecutable(exe, "linux", "arm64"); err == nil {
+			t.Error("binary for another architecture accepted")
+		}
+	}
+}
+
+func Test_Ed25519Signature(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	otherPub, _, _ := ed25519.GenerateKey(nil)
+	sum := sha256.Sum256([]byte("payload"))
+	sig := ed25519.Sign(priv, sum[:])
+	ctx := context.Background()
+
+	verifyOk := func(signature []byte) {
+		a := Artifact{Name: "app", SHA256: sum[:], Signature: signature}
+		if err := Ed25519Signature(otherPub, pub).Verify(ctx, a); err != nil {
+			t.Error(err)
+		}
+	}
+	verifyFail := func(signature []byte, keys ...ed25519.PublicKey) {
+		a := Artifact{Name: "app", SHA256: sum[:], Signature: signature}
+		if err := Ed25519Signature(keys...).Verify(ctx, a); !errors.Is(err, ErrSignatureInvalid) {
+			t.Error("expected ErrSignatureInvalid, got", err)
+		}
+	}
+	verifyOk(sig)
+	verifyOk([]byte(base64.StdEncoding.EncodeToString(sig) + "\n"))
+	verifyFail(sig, otherPub)
+	verifyFail(nil, pub)
+	verifyFail([]byte("garbage"), pub)
+}
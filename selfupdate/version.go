@@ -0,0 +1,147 @@
+package selfupdate
+
+import (
+	"errors"
+	"math"
+)
+
+type (
+	PreReleaseType int
+	Prerelease     struct {
+		t       PreReleaseType
+		version int
+	}
+	// Version is a parsed "vMAJOR[.MINOR[.PATCH]][-PRE]" tag. It holds no
+	// pointers so parsing does not allocate.
+	Version struct {
+		Original string
+		Parsed   bool
+		Numbers  [3]int
+		// Pre is the zero Prerelease (PrereleaseNone) for final releases.
+		Pre Prerelease
+	}
+)
+
+const (
+	PrereleaseNone PreReleaseType = iota
+	PrereleaseAlpha
+	PrereleaseBeta
+	PrereleaseRC
+)
+
+// prereleasePrefixes lists the recognized pre-release labels.
+var prereleasePrefixes = [...]struct {
+	label string
+	t     PreReleaseType
+}{
+	{"alpha", PrereleaseAlpha},
+	{"beta", PrereleaseBeta},
+	{"rc", PrereleaseRC},
+}
+
+// maxVersionNumber bounds each numeric component so parsing never
+// overflows, whatever the platform int size.
+const maxVersionNumber = math.MaxInt32
+
+// rank orders pre-release types; a final release sorts after all of them.
+func (v Prerelease) rank() int {
+	if v.t == PrereleaseNone {
+		return int(PrereleaseRC) + 1
+	}
+	return int(v.t)
+}
+
+func (v Prerelease) Compare(other Prerelease) int {
+	if v.t != other.t {
+		return v.rank() - other.rank()
+	}
+	return v.version - other.version
+}
+
+// parseNumber reads a run of decimal digits starting at s[i]. It returns
+// the value and the index just past the digits.
+func parseNumber(s string, i int) (n, next int, ok bool) {
+	start := i
+	for ; i < len(s) && s[i] >= '0' && s[i] <= '9'; i++ {
+		n = n*10 + int(s[i]-'0')
+		if n > maxVersionNumber {
+			return 0, i, false
+		}
+	}
+	return n, i, i > start
+}
+
+// parsePreRelease parses a label such as "rc12". The whole string must be
+// consumed.
+func parsePreRelease(s string) (Prerelease, bool) {
+	for _, p := range prereleasePrefixes {
+		if len(s) < len(p.label) || s[:len(p.label)] != p.label {
+			continue
+		}
+		n, next, ok := parseNumber(s, len(p.label))
+		if !ok || next != len(s) {
+			return Prerelease{}, false
+		}
+		return Prerelease{t: p.t, version: n}, true
+	}
+	return Prerelease{}, false
+}
+
+// ParseVersion parses v in a single pass without allocating. On failure
+// the result has Parsed == false and Original == v.
+func ParseVersion(v string) Version {
+	vs := Version{
+		Parsed:   false,
+		Original: v,
+	}
+	if len(v) == 0 || v[0] != 'v' {
+		return vs
+	}
+
+	i := 1
+	for part := 0; ; part++ {
+		n, next, ok := parseNumber(v, i)
+		if !ok {
+			return vs
+		}
+		vs.Numbers[part] = n
+		i = next
+		if i == len(v) || v[i] == '-' {
+			break
+		}
+		if v[i] != '.' || part == len(vs.Numbers)-1 {
+			return vs
+		}
+		i++
+	}
+
+	if i < len(v) {
+		pre, ok := parsePreRelease(v[i+1:])
+		if !ok {
+			return vs
+		}
+		vs.Pre = pre
+	}
+
+	vs.Parsed = true
+	return vs
+}
+
+// IsPrerelease reports whether v carries a pre-release label.
+func (v Version) IsPrerelease() bool {
+	return v.Pre.t != PrereleaseNone
+}
+
+func (v Version) Compare(other Version) (int, error) {
+	if !v.Parsed || !other.Parsed {
+		return 0, errors.New("version not parsed")
+	}
+	for i := range 3 {
+		if v.Numbers[i] > other.Numbers[i] {
+			return 1, nil
+		} else if v.Numbers[i] < other.Numbers[i] {
+			return -1, nil
+		}
+	}
+	return v.Pre.Compare(other.Pre), nil
+}
@@ -0,0 +1,137 @@
+package selfupdate
+
+import "testing"
+
+func Test_isNewer_Release(t *testing.T) {
+	isSameSign := func(a, b int) bool {
+		return (a == 0 && b == 0) || (a > 0 && b > 0) || (a < 0 && b < 0)
+	}
+	verifyOk := func(ver1, ver2 string, expect int) {
+		parsed1 := ParseVersion(ver1)
+		parsed2 := ParseVersion(ver2)
+		if cmp, err := parsed1.Compare(parsed2); err != nil ||
+			!isSameSign(cmp, expect) {
+			t.Error(ver1 + " should not be newer than " + ver2)
+		}
+		if cmp, err := parsed2.Compare(parsed1); err != nil ||
+			!isSameSign(cmp, -expect) {
+			t.Error(ver2 + " should be newer than " + ver1)
+		}
+	}
+	verifyOk("v0.0.1", "v0.0.2", -1)
+	verifyOk("v0.0.1", "v0.0.1", 0)
+	verifyOk("v0.0.1", "v0.1.1", -1)
+	verifyOk("v0.0.1", "v0.0.1-rc1", 1)
+	verifyOk("v0.0.1", "v0.0.2-rc1", -1)
+	verifyOk("v0.0.1", "v0.0.0-rc1", 1)
+	verifyOk("v0.0.1-rc4", "v0.0.0-beta19", 1)
+	verifyOk("v0.0.1-alpha24", "v0.0.0-beta19", 1)
+	verifyOk("v0.0.1-rc0", "v0.0.1-rc1", -1)
+}
+
+func Test_ParseVersion(t *testing.T) {
+	verifyOk := func(v string, ver [3]int, pre Prerelease) {
+		vs := ParseVersion(v)
+		if !vs.Parsed {
+			t.Error("Parse should succeed")
+		}
+		if vs.Numbers[0] != ver[0] ||
+			vs.Numbers[1] != ver[1] ||
+			vs.Numbers[2] != ver[2] {
+			t.Error("Version mismatch")
+		}
+		if vs.Pre != pre {
+			t.Error("PreRelease mismatch")
+		}
+	}
+	verifyOk("v42.8.167", [3]int{42, 8, 167}, Prerelease{})
+	verifyOk("v9999", [3]int{9999, 0, 0}, Prerelease{})
+	verifyOk("v1.2.3-rc123", [3]int{1, 2, 3}, Prerelease{t: PrereleaseRC, version: 123})
+	verifyOk("v1.2.3-alpha1", [3]int{1, 2, 3}, Prerelease{t: PrereleaseAlpha, version: 1})
+	verifyOk("v1.2.3-beta0", [3]int{1, 2, 3}, Prerelease{t: PrereleaseBeta, version: 0})
+	verifyOk("v12345.1-rc123", [3]int{12345, 1, 0}, Prerelease{t: PrereleaseRC, version: 123})
+
+	verifyFail := func(v string) {
+		vs := ParseVersion(v)
+		if vs.Parsed {
+			t.Error("Parse should fail")
+		}
+		if vs.Original != v {
+			t.Error("Original should match")
+		}
+	}
+	verifyFail("v0.0.1-rel0")
+	verifyFail("v0.0.0.1")
+	verifyFail("")
+	verifyFail("v")
+	verifyFail("1.2.3")
+	verifyFail("v1.")
+	verifyFail("v1..2")
+	verifyFail("v+1.2")
+	verifyFail("v1.2.3-")
+	verifyFail("v1.2.3-rc")
+	verifyFail("v1.2.3-rc-1")
+	verifyFail("v1.2.3-rc1x")
+	verifyFail("v99999999999999999999")
+}
+
+func Test_ParseVersion_allocs(t *testing.T) {
+	allocs := testing.AllocsPerRun(100, func() {
+		ParseVersion("v12.345.6789-beta42")
+	})
+	if allocs != 0 {
+		t.Errorf("ParseVersion allocated %v times", allocs)
+	}
+}
+
+func Benchmark_ParseVersion(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		ParseVersion("v12.345.6789-beta42")
+	}
+}
+
+var fuzzSeeds = []string{
+	"v42.8.167", "v9999", "v1.2.3-rc123", "v1.2.3-alpha1", "v1.2.3-beta0",
+	"v0.0.1-rel0", "v0.0.0.1", "v1..2", "v2147483647.0.0", "dev",
+}
+
+func FuzzParseVersion(f *testing.F) {
+	for _, s := range fuzzSeeds {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		vs := ParseVersion(s)
+		if vs.Original != s {
+			t.Fatal("Original should match")
+		}
+		if !vs.Parsed {
+			return
+		}
+		for _, n := range vs.Numbers {
+			if n < 0 || n > maxVersionNumber {
+				t.Fatalf("component out of range: %d", n)
+			}
+		}
+		if cmp, err := vs.Compare(vs); err != nil || cmp != 0 {
+			t.Fatal("version should equal itself")
+		}
+	})
+}
+
+func FuzzCompare(f *testing.F) {
+	for i, s := range fuzzSeeds {
+		f.Add(s, fuzzSeeds[(i+1)%len(fuzzSeeds)])
+	}
+	f.Fuzz(func(t *testing.T, a, b string) {
+		va, vb := ParseVersion(a), ParseVersion(b)
+		ab, errA := va.Compare(vb)
+		ba, errB := vb.Compare(va)
+		if (errA == nil) != (va.Parsed && vb.Parsed) || (errA == nil) != (errB == nil) {
+			t.Fatal("Compare should fail exactly when a side is unparsed")
+		}
+		if (ab > 0) != (ba < 0) || (ab == 0) != (ba == 0) {
+			t.Fatalf("Compare not antisymmetric: %d vs %d", ab, ba)
+		}
+	})
+}
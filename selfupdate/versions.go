@@ -0,0 +1,175 @@
+package selfupdate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// VersionDirs installs each release into a directory of its own,
+// <Root>/versions/<tag>/<name of target>, and atomically repoints the
+// symlink target at it, like rustup and volta do. Earlier versions stay
+// installed, so Activate rolls back without a download, and installs by
+// several users or processes sharing Root are safe: a version directory
+// is built under a temporary name and renamed into place, and the link is
+// replaced by a rename. As with SymlinkSwitch, Updater.Path must name the
+// link itself, since os.Executable resolves symlinks.
+type VersionDirs struct {
+	// Root holds the versions directory, e.g. DefaultDataDir("app").
+	Root string
+	// Keep, if positive, is how many versions to keep after an install,
+	// the newest by version; the active one is always kept.
+	Keep int
+}
+
+func (v VersionDirs) versionsDir() string {
+	return filepath.Join(v.Root, "versions")
+}
+
+func (v VersionDirs) executable(tag, target string) string {
+	return filepath.Join(v.versionsDir(), tag, filepath.Base(target))
+}
+
+func (v VersionDirs) Apply(_ context.Context, a Artifact, target string) error {
+	defer os.Remove(a.Path)
+	if v.Root == "" {
+		return errors.New("versions root not set")
+	}
+	if a.Tag == "" || a.Tag != filepath.Base(a.Tag) || strings.HasPrefix(a.Tag, ".") {
+		return fmt.Errorf("invalid version directory name %q", a.Tag)
+	}
+	if err := v.addVersion(a, target); err != nil {
+		return err
+	}
+	if err := v.Activate(a.Tag, target); err != nil {
+		return err
+	}
+	if v.Keep > 0 {
+		v.prune(a.Tag)
+	}
+	return nil
+}
+
+// addVersion moves the artifact into its version directory unless that
+// already holds the same file.
+func (v VersionDirs) addVersion(a Artifact, target string) error {
+	exe := v.executable(a.Tag, target)
+	if _, err := os.Stat(exe); err == nil {
+		return sameContent(a.Path, exe)
+	}
+	if err := os.MkdirAll(v.versionsDir(), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(v.versionsDir(), "."+a.Tag+".*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	if err := os.Chmod(tmp, 0o755); err != nil {
+		return err
+	}
+	if err := copyFile(a.Path, filepath.Join(tmp, filepath.Base(target))); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Dir(exe)); err != nil {
+		// Another process may have installed the same version meanwhile.
+		if _, serr := os.Stat(exe); serr != nil {
+			return err
+		}
+		return sameContent(a.Path, exe)
+	}
+	syncDir(v.versionsDir())
+	return nil
+}
+
+// sameContent fails unless the files a and b are identical.
+func sameContent(a, b string) error {
+	da, err := fileSHA256(a)
+	if err != nil {
+		return err
+	}
+	db, err := fileSHA256(b)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(da, db) {
+		return fmt.Errorf("%w: %s holds a different build", ErrChecksumMismatch, b)
+	}
+	return nil
+}
+
+// Activate points the symlink target at the installed version tag.
+func (v VersionDirs) Activate(tag, target string) error {
+	exe := v.executable(tag, target)
+	if _, err := os.Stat(exe); err != nil {
+		return fmt.Errorf("version %s is not installed: %w", tag, err)
+	}
+	link := fmt.Sprintf("%s.%d.link", target, os.Getpid())
+	os.Remove(link)
+	if err := os.Symlink(exe, link); err != nil {
+		return err
+	}
+	if err := os.Rename(link, target); err != nil {
+		os.Remove(link)
+		return err
+	}
+	syncDir(filepath.Dir(target))
+	return nil
+}
+
+// Current returns the version the symlink target points at, or "".
+func (v VersionDirs) Current(target string) string {
+	dest, err := os.Readlink(target)
+	if err != nil || !sameDir(filepath.Dir(filepath.Dir(dest)), v.versionsDir()) {
+		return ""
+	}
+	return filepath.Base(filepath.Dir(dest))
+}
+
+// Versions returns the installed versions, newest first.
+func (v VersionDirs) Versions() ([]string, error) {
+	entries, err := os.ReadDir(v.versionsDir())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var tags []string
+	for _, e := range entries {
+		if e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+			tags = append(tags, e.Name())
+		}
+	}
+	slices.SortFunc(tags, func(a, b string) int {
+		if c, err := ParseVersion(b).Compare(ParseVersion(a)); err == nil {
+			return c
+		}
+		return strings.Compare(b, a)
+	})
+	return tags, nil
+}
+
+// prune removes the versions beyond Keep, except active.
+func (v VersionDirs) prune(active string) {
+	tags, err := v.Versions()
+	if err != nil {
+		return
+	}
+	kept := 0
+	for _, tag := range tags {
+		if tag == active || kept < v.Keep-1 {
+			if tag != active {
+				kept++
+			}
+			continue
+		}
+		os.RemoveAll(filepath.Join(v.versionsDir(), tag))
+	}
+}
@@ -0,0 +1,46 @@
+package selfupdate_test
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/msmania/updater/selfupdate"
+	"github.com/msmania/updater/selfupdate/selfupdatetest"
+)
+
+func Test_Updater_versionDirs(t *testing.T) {
+	skipIfDisabled(t)
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+	srv := selfupdatetest.NewServer("owner", "app",
+		selfupdatetest.Release{Tag: "v1.1.0", Assets: []selfupdatetest.Asset{{Name: "app-bin", Content: []byte("v1.1")}}},
+	)
+	defer srv.Close()
+	u := newUpdater(t, srv, "v1.0.0")
+	u.StateDir = t.TempDir()
+	vd := selfupdate.VersionDirs{Root: t.TempDir()}
+	u.Applier = vd
+
+	if _, err := u.Update(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(u.Path); string(b) != "v1.1" || vd.Current(u.Path) != "v1.1.0" {
+		t.Fatalf("link not switched: %q, current %q", b, vd.Current(u.Path))
+	}
+
+	// The original executable predates the layout, so the rollback
+	// installs the kept copy as a version of its own.
+	u.Build.Version = "v1.1.0"
+	if _, err := u.Rollback(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(u.Path); string(b) != "old" || vd.Current(u.Path) != "v1.0.0" {
+		t.Errorf("not rolled back: %q, current %q", b, vd.Current(u.Path))
+	}
+	if tags, _ := vd.Versions(); len(tags) != 2 {
+		t.Errorf("unexpected versions %v", tags)
+	}
+}
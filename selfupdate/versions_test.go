@@ -0,0 +1,84 @@
+package selfupdate
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+func Test_VersionDirs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+	dir := t.TempDir()
+	target := filepath.Join(dir, "bin", "app")
+	os.MkdirAll(filepath.Dir(target), 0o755)
+	os.WriteFile(target, []byte("original"), 0o755)
+	vd := VersionDirs{Root: filepath.Join(dir, "share"), Keep: 2}
+	install := func(tag, content string) error {
+		staged := filepath.Join(dir, "app.new")
+		os.WriteFile(staged, []byte(content), 0o755)
+		err := vd.Apply(context.Background(), Artifact{Path: staged, Tag: tag}, target)
+		if _, serr := os.Stat(staged); !os.IsNotExist(serr) {
+			t.Errorf("%s: staging file left behind", tag)
+		}
+		return err
+	}
+	verify := func(wantCurrent, wantContent string, wantVersions ...string) {
+		t.Helper()
+		if got := vd.Current(target); got != wantCurrent {
+			t.Errorf("Current = %q, want %q", got, wantCurrent)
+		}
+		if b, _ := os.ReadFile(target); string(b) != wantContent {
+			t.Errorf("target runs %q, want %q", b, wantContent)
+		}
+		if got, err := vd.Versions(); err != nil || !slices.Equal(got, wantVersions) {
+			t.Errorf("Versions = %v, %v; want %v", got, err, wantVersions)
+		}
+	}
+
+	if err := install("v1.0.0", "one"); err != nil {
+		t.Fatal(err)
+	}
+	verify("v1.0.0", "one", "v1.0.0")
+	if dest, _ := os.Readlink(target); dest != filepath.Join(vd.Root, "versions", "v1.0.0", "app") {
+		t.Errorf("unexpected link %s", dest)
+	}
+	if err := install("v1.10.0", "ten"); err != nil {
+		t.Fatal(err)
+	}
+	verify("v1.10.0", "ten", "v1.10.0", "v1.0.0")
+
+	// Rolling back needs no artifact.
+	if err := vd.Activate("v1.0.0", target); err != nil {
+		t.Fatal(err)
+	}
+	verify("v1.0.0", "one", "v1.10.0", "v1.0.0")
+	if err := vd.Activate("v0.9.0", target); err == nil {
+		t.Error("activated a version that is not installed")
+	}
+
+	// Reinstalling the same build, e.g. by another user, is fine; a
+	// different build under the same tag is not.
+	if err := install("v1.10.0", "ten"); err != nil {
+		t.Error(err)
+	}
+	if err := install("v1.10.0", "evil"); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("expected ErrChecksumMismatch, got %v", err)
+	}
+	verify("v1.10.0", "ten", "v1.10.0", "v1.0.0")
+
+	// Keep prunes the oldest versions.
+	if err := install("v1.2.0", "two"); err != nil {
+		t.Fatal(err)
+	}
+	verify("v1.2.0", "two", "v1.10.0", "v1.2.0")
+
+	if err := install("../x", "bad"); err == nil {
+		t.Error("accepted a tag escaping the versions directory")
+	}
+}